@@ -9,6 +9,7 @@ type Alert struct {
 	EngineDelay int64
 	Heartbeat   HeartbeatConfig
 	Alerting    Alerting
+	EventChart  EventChart
 }
 
 type SMTPConfig struct {
@@ -35,6 +36,24 @@ type Alerting struct {
 	WebhookBatchSend  bool
 }
 
+// EventChart 发送通知前渲染告警曲线图，邮件中内嵌图片，钉钉、Slack 等通过 $event.ChartUrl 引用，
+// 飞书、Lark 配置应用凭证后上传图片，通过 $event.ChartImageKeys 引用
+type EventChart struct {
+	Enable       bool
+	RangeMinutes int64 // 曲线的时间范围，默认最近 30 分钟
+	Width        int
+	Height       int
+	PublicUrl    string // 图片访问地址的前缀，为空时使用站点设置中的 site_url
+	CacheMinutes int64  // 图片保留的时间，有 redis 时保存在 redis 中，默认 1440 分钟
+	Feishu       ChartUploader
+	Lark         ChartUploader
+}
+
+type ChartUploader struct {
+	AppId     string
+	AppSecret string
+}
+
 type CallPlugin struct {
 	Enable     bool
 	PluginPath string
@@ -63,4 +82,12 @@ func (a *Alert) PreCheck(configDir string) {
 	if a.EngineDelay == 0 {
 		a.EngineDelay = 30
 	}
+
+	if a.EventChart.RangeMinutes == 0 {
+		a.EventChart.RangeMinutes = 30
+	}
+
+	if a.EventChart.CacheMinutes == 0 {
+		a.EventChart.CacheMinutes = 1440
+	}
 }
@@ -10,12 +10,18 @@ import (
 	"github.com/ccfos/nightingale/v6/alert/astats"
 	"github.com/ccfos/nightingale/v6/alert/dispatch"
 	"github.com/ccfos/nightingale/v6/alert/eval"
+	"github.com/ccfos/nightingale/v6/alert/eventchart"
 	"github.com/ccfos/nightingale/v6/alert/naming"
+	"github.com/ccfos/nightingale/v6/alert/pipeline/processor/dsquery"
+	"github.com/ccfos/nightingale/v6/alert/pipeline/processor/eventlimit"
 	"github.com/ccfos/nightingale/v6/alert/process"
 	"github.com/ccfos/nightingale/v6/alert/queue"
 	"github.com/ccfos/nightingale/v6/alert/record"
+	"github.com/ccfos/nightingale/v6/alert/remediation"
 	"github.com/ccfos/nightingale/v6/alert/router"
+	"github.com/ccfos/nightingale/v6/alert/rulegroup"
 	"github.com/ccfos/nightingale/v6/alert/sender"
+	"github.com/ccfos/nightingale/v6/alert/timeline"
 	"github.com/ccfos/nightingale/v6/conf"
 	"github.com/ccfos/nightingale/v6/dumper"
 	"github.com/ccfos/nightingale/v6/memsto"
@@ -49,6 +55,12 @@ func Initialize(configDir string, cryptoKey string) (func(), error) {
 	if err != nil {
 		return nil, err
 	}
+	eventlimit.RegisterRedis(redis)
+	eventchart.RegisterRedis(redis)
+
+	if !config.CacheChange.Disable {
+		memsto.StartCacheChangePoller(ctx, config.CacheChange.FullSyncInterval)
+	}
 
 	syncStats := memsto.NewSyncStats()
 	alertStats := astats.NewSyncStats()
@@ -70,6 +82,7 @@ func Initialize(configDir string, cryptoKey string) (func(), error) {
 
 	promClients := prom.NewPromClient(ctx)
 	dispatch.InitRegisterQueryFunc(promClients)
+	dsquery.RegisterPromClients(promClients)
 
 	externalProcessors := process.NewExternalProcessors()
 
@@ -109,22 +122,41 @@ func Start(alertc aconf.Alert, pushgwc pconf.Pushgw, syncStats *memsto.Stats, al
 
 	naming := naming.NewNaming(ctx, alertc.Heartbeat, alertStats)
 
+	ruleGroupCache := memsto.NewRuleGroupCache(ctx, syncStats)
+
 	writers := writer.NewWriters(pushgwc)
-	record.NewScheduler(alertc, recordingRuleCache, promClients, writers, alertStats, datasourceCache)
+	record.NewScheduler(alertc, recordingRuleCache, promClients, writers, alertStats, datasourceCache, ruleGroupCache)
 
 	eval.NewScheduler(alertc, externalProcessors, alertRuleCache, targetCache, targetsOfAlertRulesCache,
-		busiGroupCache, alertMuteCache, datasourceCache, promClients, naming, ctx, alertStats)
+		busiGroupCache, alertMuteCache, datasourceCache, ruleGroupCache, promClients, naming, ctx, alertStats)
+
+	rulegroup.NewScheduler(alertc, ruleGroupCache, alertRuleCache, recordingRuleCache, targetCache, targetsOfAlertRulesCache,
+		busiGroupCache, alertMuteCache, datasourceCache, promClients, writers, ctx, alertStats)
 
 	eventProcessorCache := memsto.NewEventProcessorCache(ctx, syncStats)
 
 	dp := dispatch.NewDispatch(alertRuleCache, userCache, userGroupCache, alertSubscribeCache, targetCache, notifyConfigCache, taskTplsCache, notifyRuleCache, notifyChannelCache, messageTemplateCache, eventProcessorCache, configCvalCache, alertc.Alerting, ctx, alertStats)
+	if alertc.EventChart.Enable {
+		dp.EventChart = eventchart.New(alertc.EventChart, promClients)
+	}
 	consumer := dispatch.NewConsumer(alertc.Alerting, ctx, dp, promClients, alertMuteCache)
 
 	notifyRecordConsumer := sender.NewNotifyRecordConsumer(ctx)
 
 	go dp.ReloadTpls()
 	go consumer.LoopConsume()
+	if ctx.IsCenter {
+		// 事件处理流程的等待保存在数据库中，由 center 恢复执行
+		go dp.LoopResumePipelineWaits()
+
+		// 故障自愈策略的执行记录保存在数据库中，只在 center 中执行
+		remediationPolicyCache := memsto.NewRemediationPolicyCache(ctx, syncStats)
+		remediator := remediation.NewRemediator(ctx, remediationPolicyCache, taskTplsCache, targetCache, userCache, configCvalCache)
+		dp.Remediate = remediator.Handle
+		go remediator.LoopSyncRuns()
+	}
 	go notifyRecordConsumer.LoopConsume()
+	go timeline.NewConsumer(ctx).LoopConsume()
 
 	go queue.ReportQueueSize(alertStats)
 	go sender.ReportNotifyRecordQueueSize(alertStats)
@@ -27,6 +27,10 @@ type Stats struct {
 	GaugeQuerySeriesCount       *prometheus.GaugeVec
 	GaugeRuleEvalDuration       *prometheus.GaugeVec
 	GaugeNotifyRecordQueueSize  prometheus.Gauge
+
+	CounterRuleGroupEval             *prometheus.CounterVec
+	CounterRuleGroupMissedIterations *prometheus.CounterVec
+	GaugeRuleGroupEvalDuration       *prometheus.GaugeVec
 }
 
 func NewSyncStats() *Stats {
@@ -151,6 +155,28 @@ func NewSyncStats() *Stats {
 		Help:      "Number of var filling query.",
 	}, []string{"rule_id", "datasource_id", "ref", "typ"})
 
+	CounterRuleGroupEval := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "rule_group_eval_total",
+		Help:      "Number of rule group eval.",
+	}, []string{"rule_group_id"})
+
+	// 一次执行超过了规则组的间隔，被跳过的执行次数
+	CounterRuleGroupMissedIterations := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "rule_group_missed_iterations_total",
+		Help:      "Number of rule group iterations missed due to slow eval.",
+	}, []string{"rule_group_id"})
+
+	GaugeRuleGroupEvalDuration := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "rule_group_eval_duration_ms",
+		Help:      "Duration of the last rule group eval in milliseconds.",
+	}, []string{"rule_group_id"})
+
 	prometheus.MustRegister(
 		CounterAlertsTotal,
 		GaugeAlertQueueSize,
@@ -169,6 +195,9 @@ func NewSyncStats() *Stats {
 		GaugeRuleEvalDuration,
 		GaugeNotifyRecordQueueSize,
 		CounterVarFillingQuery,
+		CounterRuleGroupEval,
+		CounterRuleGroupMissedIterations,
+		GaugeRuleGroupEvalDuration,
 	)
 
 	return &Stats{
@@ -189,5 +218,9 @@ func NewSyncStats() *Stats {
 		GaugeRuleEvalDuration:       GaugeRuleEvalDuration,
 		GaugeNotifyRecordQueueSize:  GaugeNotifyRecordQueueSize,
 		CounterVarFillingQuery:      CounterVarFillingQuery,
+
+		CounterRuleGroupEval:             CounterRuleGroupEval,
+		CounterRuleGroupMissedIterations: CounterRuleGroupMissedIterations,
+		GaugeRuleGroupEvalDuration:       GaugeRuleGroupEvalDuration,
 	}
 }
@@ -129,7 +129,13 @@ func (e *Consumer) persist(event *models.AlertCurEvent) {
 	if !e.ctx.IsCenter {
 		event.DB2FE()
 		var err error
-		event.Id, err = poster.PostByUrlsWithResp[int64](e.ctx, "/v1/n9e/event-persist", event)
+		var spooled bool
+		// 中心端不可达时事件暂存到本地，恢复后按顺序补报，此时 event.Id 为 0
+		// 引用该事件的通知记录同样暂存，排在事件之后补报，由中心端按 hash 补全事件 id
+		event.Id, spooled, err = poster.PostByUrlsWithRespOrSpool[int64](e.ctx, "/v1/n9e/event-persist", "", event)
+		if spooled {
+			logger.Warningf("event:%s persist spooled, center api is unreachable", event.Hash)
+		}
 		if err != nil {
 			logger.Errorf("event:%+v persist err:%v", event, err)
 			e.dispatch.Astats.CounterRuleEvalErrorTotal.WithLabelValues(fmt.Sprintf("%v", event.DatasourceId), "persist_event", event.GroupName, fmt.Sprintf("%v", event.RuleId)).Inc()
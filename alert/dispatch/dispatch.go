@@ -15,9 +15,11 @@ import (
 	"github.com/ccfos/nightingale/v6/alert/aconf"
 	"github.com/ccfos/nightingale/v6/alert/astats"
 	"github.com/ccfos/nightingale/v6/alert/common"
+	"github.com/ccfos/nightingale/v6/alert/eventchart"
 	"github.com/ccfos/nightingale/v6/alert/pipeline"
 	"github.com/ccfos/nightingale/v6/alert/pipeline/engine"
 	"github.com/ccfos/nightingale/v6/alert/sender"
+	"github.com/ccfos/nightingale/v6/alert/timeline"
 	"github.com/ccfos/nightingale/v6/memsto"
 	"github.com/ccfos/nightingale/v6/models"
 	"github.com/ccfos/nightingale/v6/pkg/ctx"
@@ -27,7 +29,7 @@ import (
 
 var ShouldSkipNotify func(*ctx.Context, *models.AlertCurEvent, int64) bool
 var SendByNotifyRule func(*ctx.Context, *memsto.UserCacheType, *memsto.UserGroupCacheType, *memsto.NotifyChannelCacheType, *memsto.CvalCache,
-	[]*models.AlertCurEvent, int64, *models.NotifyConfig, *models.NotifyChannelConfig, *models.MessageTemplate)
+	[]*models.AlertCurEvent, int64, *models.NotifyConfig, *models.NotifyChannelConfig, *models.MessageTemplate, func([]*memsto.NotifyResult))
 
 var EventProcessorCache *memsto.EventProcessorCacheType
 
@@ -58,6 +60,8 @@ type Dispatch struct {
 	tpls             map[string]*template.Template
 	ExtraSenders     map[string]sender.Sender
 	BeforeSenderHook func(*models.AlertCurEvent) bool
+	Remediate        func(*models.AlertCurEvent) // 按故障自愈策略处理事件，只在 center 中设置
+	EventChart       *eventchart.Renderer
 	ctx              *ctx.Context
 	Astats           *astats.Stats
 
@@ -167,6 +171,12 @@ func (e *Dispatch) reloadTpls() error {
 func (e *Dispatch) HandleEventWithNotifyRule(eventOrigin *models.AlertCurEvent) {
 
 	if len(eventOrigin.NotifyRuleIds) > 0 {
+		if e.EventChart != nil {
+			// 曲线图只渲染一次，各通知规则的事件副本共享，先拷贝一份避免和旧版通知流程并发读写同一个 event
+			eventOrigin = eventOrigin.DeepCopy()
+			e.EventChart.Attach(eventOrigin, e.configCvalCache.GetSiteInfo().SiteUrl)
+		}
+
 		for _, notifyRuleId := range eventOrigin.NotifyRuleIds {
 			// 深拷贝新的 event，避免并发修改 event 冲突
 			eventCopy := eventOrigin.DeepCopy()
@@ -189,32 +199,41 @@ func (e *Dispatch) HandleEventWithNotifyRule(eventOrigin *models.AlertCurEvent)
 				continue
 			}
 
-			// notify
-			for i := range notifyRule.NotifyConfigs {
-				err := NotifyRuleMatchCheck(&notifyRule.NotifyConfigs[i], eventCopy)
-				if err != nil {
-					logger.Errorf("notify_id: %d, event:%+v, channel_id:%d, template_id: %d, notify_config:%+v, err:%v", notifyRuleId, eventCopy, notifyRule.NotifyConfigs[i].ChannelID, notifyRule.NotifyConfigs[i].TemplateID, notifyRule.NotifyConfigs[i], err)
-					continue
-				}
+			e.notifyByRule(notifyRuleId, notifyRule, eventCopy)
+		}
+	}
+}
 
-				notifyChannel := e.notifyChannelCache.Get(notifyRule.NotifyConfigs[i].ChannelID)
-				messageTemplate := e.messageTemplateCache.Get(notifyRule.NotifyConfigs[i].TemplateID)
-				if notifyChannel == nil {
-					sender.NotifyRecord(e.ctx, []*models.AlertCurEvent{eventCopy}, notifyRuleId, fmt.Sprintf("notify_channel_id:%d", notifyRule.NotifyConfigs[i].ChannelID), "", "", errors.New("notify_channel not found"))
-					logger.Warningf("notify_id: %d, event:%+v, channel_id:%d, template_id: %d, notify_channel not found", notifyRuleId, eventCopy, notifyRule.NotifyConfigs[i].ChannelID, notifyRule.NotifyConfigs[i].TemplateID)
-					continue
-				}
+// notifyByRule 按通知规则的通知配置发送通知
+func (e *Dispatch) notifyByRule(notifyRuleId int64, notifyRule *models.NotifyRule, eventCopy *models.AlertCurEvent) {
+	for i := range notifyRule.NotifyConfigs {
+		err := NotifyRuleMatchCheck(&notifyRule.NotifyConfigs[i], eventCopy)
+		if err != nil {
+			logger.Errorf("notify_id: %d, event:%+v, channel_id:%d, template_id: %d, notify_config:%+v, err:%v", notifyRuleId, eventCopy, notifyRule.NotifyConfigs[i].ChannelID, notifyRule.NotifyConfigs[i].TemplateID, notifyRule.NotifyConfigs[i], err)
+			continue
+		}
 
-				if notifyChannel.RequestType != "flashduty" && notifyChannel.RequestType != "pagerduty" && messageTemplate == nil {
-					logger.Warningf("notify_id: %d, channel_name: %v, event:%+v, template_id: %d, message_template not found", notifyRuleId, notifyChannel.Ident, eventCopy, notifyRule.NotifyConfigs[i].TemplateID)
-					sender.NotifyRecord(e.ctx, []*models.AlertCurEvent{eventCopy}, notifyRuleId, notifyChannel.Name, "", "", errors.New("message_template not found"))
+		if len(notifyRule.NotifyConfigs[i].Fallbacks) > 0 {
+			go SendNotifyRuleMessageWithFallback(e.ctx, e.userCache, e.userGroupCache, e.notifyChannelCache, e.messageTemplateCache, e.configCvalCache, []*models.AlertCurEvent{eventCopy}, notifyRuleId, &notifyRule.NotifyConfigs[i])
+			continue
+		}
 
-					continue
-				}
+		notifyChannel := e.notifyChannelCache.Get(notifyRule.NotifyConfigs[i].ChannelID)
+		messageTemplate := e.messageTemplateCache.Get(notifyRule.NotifyConfigs[i].TemplateID)
+		if notifyChannel == nil {
+			sender.NotifyRecord(e.ctx, []*models.AlertCurEvent{eventCopy}, notifyRuleId, fmt.Sprintf("notify_channel_id:%d", notifyRule.NotifyConfigs[i].ChannelID), "", "", errors.New("notify_channel not found"))
+			logger.Warningf("notify_id: %d, event:%+v, channel_id:%d, template_id: %d, notify_channel not found", notifyRuleId, eventCopy, notifyRule.NotifyConfigs[i].ChannelID, notifyRule.NotifyConfigs[i].TemplateID)
+			continue
+		}
 
-				go SendByNotifyRule(e.ctx, e.userCache, e.userGroupCache, e.notifyChannelCache, e.configCvalCache, []*models.AlertCurEvent{eventCopy}, notifyRuleId, &notifyRule.NotifyConfigs[i], notifyChannel, messageTemplate)
-			}
+		if notifyChannel.RequestType != "flashduty" && notifyChannel.RequestType != "pagerduty" && messageTemplate == nil {
+			logger.Warningf("notify_id: %d, channel_name: %v, event:%+v, template_id: %d, message_template not found", notifyRuleId, notifyChannel.Ident, eventCopy, notifyRule.NotifyConfigs[i].TemplateID)
+			sender.NotifyRecord(e.ctx, []*models.AlertCurEvent{eventCopy}, notifyRuleId, notifyChannel.Name, "", "", errors.New("message_template not found"))
+
+			continue
 		}
+
+		go SendByNotifyRule(e.ctx, e.userCache, e.userGroupCache, e.notifyChannelCache, e.configCvalCache, []*models.AlertCurEvent{eventCopy}, notifyRuleId, &notifyRule.NotifyConfigs[i], notifyChannel, messageTemplate, nil)
 	}
 }
 
@@ -232,9 +251,16 @@ func shouldSkipNotify(ctx *ctx.Context, event *models.AlertCurEvent, notifyRuleI
 }
 
 func HandleEventPipeline(pipelineConfigs []models.PipelineConfig, eventOrigin, event *models.AlertCurEvent, eventProcessorCache *memsto.EventProcessorCacheType, ctx *ctx.Context, id int64, from string) *models.AlertCurEvent {
+	return handleEventPipelineFrom(pipelineConfigs, 0, eventOrigin, event, eventProcessorCache, ctx, id, from)
+}
+
+// handleEventPipelineFrom 从第 start 个事件处理流程开始执行
+// 通知规则的事件处理流程在等待节点暂停时返回 nil，到期后由 LoopResumePipelineWaits 继续执行剩余的流程并发送通知
+func handleEventPipelineFrom(pipelineConfigs []models.PipelineConfig, start int, eventOrigin, event *models.AlertCurEvent, eventProcessorCache *memsto.EventProcessorCacheType, ctx *ctx.Context, id int64, from string) *models.AlertCurEvent {
 	workflowEngine := engine.NewWorkflowEngine(ctx)
 
-	for _, pipelineConfig := range pipelineConfigs {
+	for i := start; i < len(pipelineConfigs); i++ {
+		pipelineConfig := pipelineConfigs[i]
 		if !pipelineConfig.Enable {
 			continue
 		}
@@ -251,9 +277,13 @@ func HandleEventPipeline(pipelineConfigs []models.PipelineConfig, eventOrigin, e
 		}
 
 		// 统一使用工作流引擎执行（兼容线性模式和工作流模式）
+		// 等待需要保存到数据库，只有 center 中通知规则的事件处理流程支持暂停，其他情况下等待节点执行失败
 		triggerCtx := &models.WorkflowTriggerContext{
-			Mode:      models.TriggerModeEvent,
-			TriggerBy: from,
+			Mode:          models.TriggerModeEvent,
+			TriggerBy:     from,
+			Resumable:     from == "notify_rule" && ctx.IsCenter,
+			NotifyRuleId:  id,
+			PipelineIndex: i,
 		}
 
 		resultEvent, result, err := workflowEngine.Execute(eventPipeline, event, triggerCtx)
@@ -262,10 +292,18 @@ func HandleEventPipeline(pipelineConfigs []models.PipelineConfig, eventOrigin, e
 			continue
 		}
 
+		if result.Status == models.ExecutionStatusWaiting {
+			logger.Infof("processor_by_%s_id:%d pipeline_id:%d, event waiting at node %s for %ds, event: %+v", from, id, pipelineConfig.PipelineId, result.WaitNode, result.Wait.Seconds, eventOrigin)
+			return nil
+		}
+
 		if resultEvent == nil {
 			logger.Infof("processor_by_%s_id:%d pipeline_id:%d, event dropped, event: %+v", from, id, pipelineConfig.PipelineId, eventOrigin)
 			if from == "notify_rule" {
 				sender.NotifyRecord(ctx, []*models.AlertCurEvent{eventOrigin}, id, "", "", result.Message, fmt.Errorf("processor_by_%s_id:%d pipeline_id:%d, drop by pipeline", from, id, pipelineConfig.PipelineId))
+			} else if from == "alert_rule" {
+				// 告警规则的事件处理流程在事件持久化之前执行，执行记录关联不到事件，单独记录到时间线
+				timeline.Record(eventOrigin, models.TimelineTypePipeline, fmt.Sprintf("dropped by pipeline %s: %s", eventPipeline.Name, result.Message))
 			}
 			return nil
 		}
@@ -517,17 +555,26 @@ func GetNotifyConfigParams(notifyConfig *models.NotifyConfig, contactKey string,
 	return sendtos, flashDutyChannelIDs, pagerDutyRoutingKeys, customParams
 }
 
-func SendNotifyRuleMessage(ctx *ctx.Context, userCache *memsto.UserCacheType, userGroupCache *memsto.UserGroupCacheType, notifyChannelCache *memsto.NotifyChannelCacheType, configCvalCache *memsto.CvalCache,
-	events []*models.AlertCurEvent, notifyRuleId int64, notifyConfig *models.NotifyConfig, notifyChannel *models.NotifyChannelConfig, messageTemplate *models.MessageTemplate) {
-	if len(events) == 0 {
-		logger.Errorf("notify_id: %d events is empty", notifyRuleId)
-		return
-	}
+// notifyMessage 渲染后的消息内容和从 NotifyConfig 中解析出的发送对象
+type notifyMessage struct {
+	siteUrl              string
+	tplContent           map[string]interface{}
+	sendtos              []string
+	flashDutyChannelIDs  []int64
+	pagerdutyRoutingKeys []string
+	customParams         map[string]string
+}
 
+func buildNotifyMessage(userCache *memsto.UserCacheType, userGroupCache *memsto.UserGroupCacheType, configCvalCache *memsto.CvalCache,
+	events []*models.AlertCurEvent, notifyConfig *models.NotifyConfig, notifyChannel *models.NotifyChannelConfig, messageTemplate *models.MessageTemplate) *notifyMessage {
 	siteInfo := configCvalCache.GetSiteInfo()
-	tplContent := make(map[string]interface{})
+	msg := &notifyMessage{
+		siteUrl:    siteInfo.SiteUrl,
+		tplContent: make(map[string]interface{}),
+	}
+
 	if notifyChannel.RequestType != "flashduty" {
-		tplContent = messageTemplate.RenderEvent(events, siteInfo.SiteUrl)
+		msg.tplContent = messageTemplate.RenderEvent(events, siteInfo.SiteUrl)
 	}
 
 	var contactKey string
@@ -535,31 +582,32 @@ func SendNotifyRuleMessage(ctx *ctx.Context, userCache *memsto.UserCacheType, us
 		contactKey = notifyChannel.ParamConfig.UserInfo.ContactKey
 	}
 
-	sendtos, flashDutyChannelIDs, pagerdutyRoutingKeys, customParams := GetNotifyConfigParams(notifyConfig, contactKey, userCache, userGroupCache)
+	msg.sendtos, msg.flashDutyChannelIDs, msg.pagerdutyRoutingKeys, msg.customParams = GetNotifyConfigParams(notifyConfig, contactKey, userCache, userGroupCache)
+	return msg
+}
 
-	switch notifyChannel.RequestType {
-	case "flashduty":
-		if len(flashDutyChannelIDs) == 0 {
-			flashDutyChannelIDs = []int64{0} // 如果 flashduty 通道没有配置，则使用 0, 给 SendFlashDuty 判断使用, 不给 flashduty 传 channel_id 参数
-		}
+// SendNotifyRuleMessage 按通知媒介的类型发送通知，http 和邮件进入各自的发送队列
+// done 不为空时发送结果交给调用方处理，不再写入通知记录和死信，用于备用媒介判断是否需要切换
+func SendNotifyRuleMessage(ctx *ctx.Context, userCache *memsto.UserCacheType, userGroupCache *memsto.UserGroupCacheType, notifyChannelCache *memsto.NotifyChannelCacheType, configCvalCache *memsto.CvalCache,
+	events []*models.AlertCurEvent, notifyRuleId int64, notifyConfig *models.NotifyConfig, notifyChannel *models.NotifyChannelConfig, messageTemplate *models.MessageTemplate,
+	done func(results []*memsto.NotifyResult)) {
+	if len(events) == 0 {
+		logger.Errorf("notify_id: %d events is empty", notifyRuleId)
+		return
+	}
 
-		for i := range flashDutyChannelIDs {
-			start := time.Now()
-			respBody, err := notifyChannel.SendFlashDuty(events, flashDutyChannelIDs[i], notifyChannelCache.GetHttpClient(notifyChannel.ID))
-			respBody = fmt.Sprintf("send_time: %s duration: %d ms %s", time.Now().Format("2006-01-02 15:04:05"), time.Since(start).Milliseconds(), respBody)
-			logger.Infof("duty_sender notify_id: %d, channel_name: %v, event:%+v, IntegrationUrl: %v dutychannel_id: %v, respBody: %v, err: %v", notifyRuleId, notifyChannel.Name, events[0], notifyChannel.RequestConfig.FlashDutyRequestConfig.IntegrationUrl, flashDutyChannelIDs[i], respBody, err)
-			sender.NotifyRecord(ctx, events, notifyRuleId, notifyChannel.Name, strconv.FormatInt(flashDutyChannelIDs[i], 10), respBody, err)
-		}
+	msg := buildNotifyMessage(userCache, userGroupCache, configCvalCache, events, notifyConfig, notifyChannel, messageTemplate)
 
-	case "pagerduty":
-		for _, routingKey := range pagerdutyRoutingKeys {
-			start := time.Now()
-			respBody, err := notifyChannel.SendPagerDuty(events, routingKey, siteInfo.SiteUrl, notifyChannelCache.GetHttpClient(notifyChannel.ID))
-			respBody = fmt.Sprintf("send_time: %s duration: %d ms %s", time.Now().Format("2006-01-02 15:04:05"), time.Since(start).Milliseconds(), respBody)
-			logger.Infof("pagerduty_sender notify_id: %d, channel_name: %v, event:%+v, respBody: %v, err: %v", notifyRuleId, notifyChannel.Name, events[0], respBody, err)
-			sender.NotifyRecord(ctx, events, notifyRuleId, notifyChannel.Name, "", respBody, err)
+	// 入队失败时的结果
+	failed := func(target string, err error) {
+		if done != nil {
+			done([]*memsto.NotifyResult{{Target: target, Err: err}})
+			return
 		}
+		sender.NotifyRecord(ctx, events, notifyRuleId, notifyChannel.Name, target, "", err)
+	}
 
+	switch notifyChannel.RequestType {
 	case "http":
 		// 使用队列模式处理 http 通知
 		// 创建通知任务
@@ -567,9 +615,10 @@ func SendNotifyRuleMessage(ctx *ctx.Context, userCache *memsto.UserCacheType, us
 			Events:        events,
 			NotifyRuleId:  notifyRuleId,
 			NotifyChannel: notifyChannel,
-			TplContent:    tplContent,
-			CustomParams:  customParams,
-			Sendtos:       sendtos,
+			TplContent:    msg.tplContent,
+			CustomParams:  msg.customParams,
+			Sendtos:       msg.sendtos,
+			Done:          done,
 		}
 
 		// 将任务加入队列
@@ -577,21 +626,88 @@ func SendNotifyRuleMessage(ctx *ctx.Context, userCache *memsto.UserCacheType, us
 		if !success {
 			logger.Errorf("failed to enqueue notify task for channel %d, notify_id: %d", notifyChannel.ID, notifyRuleId)
 			// 如果入队失败，记录错误通知
-			sender.NotifyRecord(ctx, events, notifyRuleId, notifyChannel.Name, getSendTarget(customParams, sendtos), "", errors.New("failed to enqueue notify task, queue is full"))
+			failed(getSendTarget(msg.customParams, msg.sendtos), errors.New("failed to enqueue notify task, queue is full"))
 		}
 
 	case "smtp":
-		notifyChannel.SendEmail(notifyRuleId, events, tplContent, sendtos, notifyChannelCache.GetSmtpClient(notifyChannel.ID))
+		ch := notifyChannelCache.GetSmtpClient(notifyChannel.ID)
+		if ch == nil {
+			logger.Errorf("no smtp sender found for channel %d, notify_id: %d", notifyChannel.ID, notifyRuleId)
+			failed(strings.Join(msg.sendtos, ","), errors.New("smtp sender not found"))
+			return
+		}
+
+		var emailDone func(err error)
+		if done != nil {
+			emailDone = func(err error) {
+				done([]*memsto.NotifyResult{{
+					Target:  strings.Join(msg.sendtos, ","),
+					Err:     err,
+					Message: &models.DeadLetterMessage{Events: events, TplContent: msg.tplContent, Sendtos: msg.sendtos},
+				}})
+			}
+		}
+		notifyChannel.SendEmail(notifyRuleId, events, msg.tplContent, msg.sendtos, ch, emailDone)
+
+	default:
+		results := sendNotifyMessageNow(notifyChannelCache, events, notifyRuleId, notifyChannel, msg)
+		if done != nil {
+			done(results)
+			return
+		}
+
+		task := &memsto.NotifyTask{Events: events, NotifyRuleId: notifyRuleId, NotifyChannel: notifyChannel}
+		for _, ret := range results {
+			sender.NotifyRecord(ctx, events, notifyRuleId, notifyChannel.Name, ret.Target, ret.Resp, ret.Err)
+			memsto.SaveNotifyDeadLetter(ctx, task, ret)
+		}
+	}
+}
+
+// sendNotifyMessageNow 同步发送不经过队列的媒介，返回每个发送对象的结果
+func sendNotifyMessageNow(notifyChannelCache *memsto.NotifyChannelCacheType, events []*models.AlertCurEvent, notifyRuleId int64,
+	notifyChannel *models.NotifyChannelConfig, msg *notifyMessage) []*memsto.NotifyResult {
+	var results []*memsto.NotifyResult
+
+	switch notifyChannel.RequestType {
+	case "flashduty":
+		flashDutyChannelIDs := msg.flashDutyChannelIDs
+		if len(flashDutyChannelIDs) == 0 {
+			flashDutyChannelIDs = []int64{0} // 如果 flashduty 通道没有配置，则使用 0, 给 SendFlashDuty 判断使用, 不给 flashduty 传 channel_id 参数
+		}
+
+		for i := range flashDutyChannelIDs {
+			start := time.Now()
+			respBody, err := notifyChannel.SendFlashDuty(events, flashDutyChannelIDs[i], notifyChannelCache.GetHttpClient(notifyChannel.ID))
+			respBody = fmt.Sprintf("send_time: %s duration: %d ms %s", time.Now().Format("2006-01-02 15:04:05"), time.Since(start).Milliseconds(), respBody)
+			logger.Infof("duty_sender notify_id: %d, channel_name: %v, event:%+v, IntegrationUrl: %v dutychannel_id: %v, respBody: %v, err: %v", notifyRuleId, notifyChannel.Name, events[0], notifyChannel.RequestConfig.FlashDutyRequestConfig.IntegrationUrl, flashDutyChannelIDs[i], respBody, err)
+			results = append(results, &memsto.NotifyResult{Target: strconv.FormatInt(flashDutyChannelIDs[i], 10), Resp: respBody, Err: err,
+				Message: &models.DeadLetterMessage{Events: events, FlashDutyChannelId: flashDutyChannelIDs[i]}})
+		}
+
+	case "pagerduty":
+		for _, routingKey := range msg.pagerdutyRoutingKeys {
+			start := time.Now()
+			respBody, err := notifyChannel.SendPagerDuty(events, routingKey, msg.siteUrl, notifyChannelCache.GetHttpClient(notifyChannel.ID))
+			respBody = fmt.Sprintf("send_time: %s duration: %d ms %s", time.Now().Format("2006-01-02 15:04:05"), time.Since(start).Milliseconds(), respBody)
+			logger.Infof("pagerduty_sender notify_id: %d, channel_name: %v, event:%+v, respBody: %v, err: %v", notifyRuleId, notifyChannel.Name, events[0], respBody, err)
+			results = append(results, &memsto.NotifyResult{Resp: respBody, Err: err,
+				Message: &models.DeadLetterMessage{Events: events, RoutingKey: routingKey, SiteUrl: msg.siteUrl}})
+		}
 
 	case "script":
 		start := time.Now()
-		target, res, err := notifyChannel.SendScript(events, tplContent, customParams, sendtos)
+		target, res, err := notifyChannel.SendScript(events, msg.tplContent, msg.customParams, msg.sendtos)
 		res = fmt.Sprintf("send_time: %s duration: %d ms %s", time.Now().Format("2006-01-02 15:04:05"), time.Since(start).Milliseconds(), res)
-		logger.Infof("script_sender notify_id: %d, channel_name: %v, event:%+v, tplContent:%s, customParams:%v, target:%s, res:%s, err:%v", notifyRuleId, notifyChannel.Name, events[0], tplContent, customParams, target, res, err)
-		sender.NotifyRecord(ctx, events, notifyRuleId, notifyChannel.Name, target, res, err)
+		logger.Infof("script_sender notify_id: %d, channel_name: %v, event:%+v, tplContent:%s, customParams:%v, target:%s, res:%s, err:%v", notifyRuleId, notifyChannel.Name, events[0], msg.tplContent, msg.customParams, target, res, err)
+		results = append(results, &memsto.NotifyResult{Target: target, Resp: res, Err: err,
+			Message: &models.DeadLetterMessage{Events: events, TplContent: msg.tplContent, Sendtos: msg.sendtos, CustomParams: msg.customParams}})
+
 	default:
 		logger.Warningf("notify_id: %d, channel_name: %v, event:%+v send type not found", notifyRuleId, notifyChannel.Name, events[0])
 	}
+
+	return results
 }
 
 func NeedBatchContacts(requestConfig *models.HTTPRequestConfig) bool {
@@ -604,6 +720,10 @@ func NeedBatchContacts(requestConfig *models.HTTPRequestConfig) bool {
 // isSubscribe: 告警事件是否由subscribe的配置产生
 func (e *Dispatch) HandleEventNotify(event *models.AlertCurEvent, isSubscribe bool) {
 	go e.HandleEventWithNotifyRule(event)
+	if !isSubscribe && e.Remediate != nil {
+		go e.Remediate(event.DeepCopy())
+	}
+
 	if event.IsRecovered && event.NotifyRecovered == 0 {
 		return
 	}
@@ -0,0 +1,105 @@
+package dispatch
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/alert/sender"
+	"github.com/ccfos/nightingale/v6/memsto"
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+
+	"github.com/toolkits/pkg/logger"
+)
+
+// 等待队列中的通知发送完成的最长时间，超时按失败处理并切换到下一个媒介
+const fallbackWaitTimeout = 5 * time.Minute
+
+// SendNotifyRuleMessageWithFallback 按顺序同步尝试主通知媒介和备用媒介，直到某个媒介全部发送成功
+// 每一跳都会写入通知记录，便于在事件的通知记录中看到完整的发送路径
+func SendNotifyRuleMessageWithFallback(ctx *ctx.Context, userCache *memsto.UserCacheType, userGroupCache *memsto.UserGroupCacheType,
+	notifyChannelCache *memsto.NotifyChannelCacheType, messageTemplateCache *memsto.MessageTemplateCacheType, configCvalCache *memsto.CvalCache,
+	events []*models.AlertCurEvent, notifyRuleId int64, notifyConfig *models.NotifyConfig) {
+	if len(events) == 0 {
+		logger.Errorf("notify_id: %d events is empty", notifyRuleId)
+		return
+	}
+
+	hops := notifyConfig.Hops()
+	for i, hop := range hops {
+		last := i == len(hops)-1
+
+		notifyChannel := notifyChannelCache.Get(hop.ChannelID)
+		if notifyChannel == nil {
+			sender.NotifyRecord(ctx, events, notifyRuleId, fmt.Sprintf("notify_channel_id:%d", hop.ChannelID), "", "", fallbackError(i, errors.New("notify_channel not found")))
+			continue
+		}
+
+		messageTemplate := messageTemplateCache.Get(hop.TemplateID)
+		if notifyChannel.RequestType != "flashduty" && notifyChannel.RequestType != "pagerduty" && messageTemplate == nil {
+			sender.NotifyRecord(ctx, events, notifyRuleId, notifyChannel.Name, "", "", fallbackError(i, errors.New("message_template not found")))
+			continue
+		}
+
+		results := sendAndWait(ctx, userCache, userGroupCache, notifyChannelCache, configCvalCache, events, notifyRuleId, hop, notifyChannel, messageTemplate)
+
+		failed := len(results) == 0
+		for _, ret := range results {
+			sender.NotifyRecord(ctx, events, notifyRuleId, notifyChannel.Name, ret.Target, fallbackResp(i, ret.Resp), fallbackError(i, ret.Err))
+			if ret.Err != nil {
+				failed = true
+			}
+
+			// 最后一跳仍然失败的通知交给死信重投
+			if last {
+				memsto.SaveNotifyDeadLetter(ctx, &memsto.NotifyTask{Events: events, NotifyRuleId: notifyRuleId, NotifyChannel: notifyChannel}, ret)
+			}
+		}
+
+		if !failed {
+			return
+		}
+
+		if !last {
+			logger.Warningf("notify_id: %d, channel_name: %v, event:%+v, delivery failed, fallback to channel_id: %d", notifyRuleId, notifyChannel.Name, events[0], hops[i+1].ChannelID)
+		}
+	}
+}
+
+// sendAndWait 与不带备用媒介的通知走同一个发送入口（http 和邮件进入队列），等待发送结果以决定是否切换到下一个媒介
+func sendAndWait(ctx *ctx.Context, userCache *memsto.UserCacheType, userGroupCache *memsto.UserGroupCacheType, notifyChannelCache *memsto.NotifyChannelCacheType,
+	configCvalCache *memsto.CvalCache, events []*models.AlertCurEvent, notifyRuleId int64, hop *models.NotifyConfig,
+	notifyChannel *models.NotifyChannelConfig, messageTemplate *models.MessageTemplate) []*memsto.NotifyResult {
+	ch := make(chan []*memsto.NotifyResult, 1)
+	done := func(results []*memsto.NotifyResult) {
+		select {
+		case ch <- results:
+		default:
+		}
+	}
+
+	SendByNotifyRule(ctx, userCache, userGroupCache, notifyChannelCache, configCvalCache, events, notifyRuleId, hop, notifyChannel, messageTemplate, done)
+
+	select {
+	case results := <-ch:
+		return results
+	case <-time.After(fallbackWaitTimeout):
+		return []*memsto.NotifyResult{{Err: fmt.Errorf("no delivery result in %s", fallbackWaitTimeout)}}
+	}
+}
+
+// fallbackResp 备用媒介的通知记录中标明是第几个备用媒介
+func fallbackResp(hop int, resp string) string {
+	if hop == 0 {
+		return resp
+	}
+	return fmt.Sprintf("fallback #%d %s", hop, resp)
+}
+
+func fallbackError(hop int, err error) error {
+	if hop == 0 || err == nil {
+		return err
+	}
+	return fmt.Errorf("fallback #%d %w", hop, err)
+}
@@ -0,0 +1,99 @@
+package dispatch
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/alert/pipeline/engine"
+	"github.com/ccfos/nightingale/v6/alert/sender"
+	"github.com/ccfos/nightingale/v6/models"
+
+	"github.com/toolkits/pkg/logger"
+)
+
+const (
+	pipelineWaitInterval = 5   // unit: s
+	pipelineWaitLease    = 300 // 认领后超过该时长未完成可被重新认领，unit: s
+	pipelineWaitBatch    = 100
+)
+
+// LoopResumePipelineWaits 定期恢复到期的事件处理流程等待，多个实例通过认领避免重复执行
+func (e *Dispatch) LoopResumePipelineWaits() {
+	duration := time.Duration(pipelineWaitInterval) * time.Second
+	for {
+		time.Sleep(duration)
+		e.resumePipelineWaits()
+	}
+}
+
+func (e *Dispatch) resumePipelineWaits() {
+	waits, err := models.EventPipelineWaitsDue(e.ctx, pipelineWaitLease, pipelineWaitBatch)
+	if err != nil {
+		logger.Errorf("failed to get due pipeline waits: %v", err)
+		return
+	}
+
+	for _, wait := range waits {
+		claimed, err := wait.Claim(e.ctx, pipelineWaitLease)
+		if err != nil {
+			logger.Errorf("failed to claim pipeline wait: id=%d, error=%v", wait.Id, err)
+			continue
+		}
+
+		if !claimed {
+			continue
+		}
+
+		status := models.PipelineWaitStatusDone
+		message, err := e.resumePipelineWait(wait)
+		if err != nil {
+			logger.Errorf("failed to resume pipeline wait: id=%d, execution_id=%s, error=%v", wait.Id, wait.ExecutionId, err)
+			status = models.PipelineWaitStatusFailed
+			message = err.Error()
+		}
+
+		if err := wait.Finish(e.ctx, status, message); err != nil {
+			logger.Errorf("failed to finish pipeline wait: id=%d, error=%v", wait.Id, err)
+		}
+	}
+}
+
+// resumePipelineWait 从等待节点之后继续执行，然后执行通知规则中剩余的事件处理流程并发送通知
+func (e *Dispatch) resumePipelineWait(wait *models.EventPipelineWait) (string, error) {
+	notifyRule := e.notifyRuleCache.Get(wait.NotifyRuleId)
+	if notifyRule == nil || !notifyRule.Enable {
+		return fmt.Sprintf("notify rule %d not found or disabled", wait.NotifyRuleId), nil
+	}
+
+	eventPipeline := e.eventProcessorCache.Get(wait.PipelineId)
+	if eventPipeline == nil {
+		return "", fmt.Errorf("event pipeline %d not found", wait.PipelineId)
+	}
+
+	event, result, err := engine.NewWorkflowEngine(e.ctx).Resume(eventPipeline, wait)
+	if err != nil {
+		return "", err
+	}
+
+	if result.Status == models.ExecutionStatusWaiting {
+		return result.Message, nil
+	}
+
+	if event == nil {
+		logger.Infof("processor_by_notify_rule_id:%d pipeline_id:%d, event dropped after waiting, event_hash: %s, message: %s", wait.NotifyRuleId, wait.PipelineId, wait.EventHash, result.Message)
+		if wfCtx, err := wait.GetContext(); err == nil {
+			sender.NotifyRecord(e.ctx, []*models.AlertCurEvent{wfCtx.Event}, wait.NotifyRuleId, "", "", result.Message, fmt.Errorf("processor_by_notify_rule_id:%d pipeline_id:%d, drop by pipeline", wait.NotifyRuleId, wait.PipelineId))
+		}
+		return result.Message, nil
+	}
+
+	eventOrigin := event.DeepCopy()
+	event = handleEventPipelineFrom(notifyRule.PipelineConfigs, wait.PipelineIndex+1, eventOrigin, event, e.eventProcessorCache, e.ctx, wait.NotifyRuleId, "notify_rule")
+	if ShouldSkipNotify(e.ctx, event, wait.NotifyRuleId) {
+		logger.Infof("notify_id: %d, event:%+v, should skip notify", wait.NotifyRuleId, event)
+		return result.Message, nil
+	}
+
+	e.notifyByRule(wait.NotifyRuleId, notifyRule, event)
+	return result.Message, nil
+}
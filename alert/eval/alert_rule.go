@@ -12,6 +12,7 @@ import (
 	"github.com/ccfos/nightingale/v6/alert/process"
 	"github.com/ccfos/nightingale/v6/datasource/commons/eslike"
 	"github.com/ccfos/nightingale/v6/memsto"
+	"github.com/ccfos/nightingale/v6/models"
 	"github.com/ccfos/nightingale/v6/pkg/ctx"
 	"github.com/ccfos/nightingale/v6/prom"
 	"github.com/toolkits/pkg/logger"
@@ -31,6 +32,7 @@ type Scheduler struct {
 	busiGroupCache          *memsto.BusiGroupCacheType
 	alertMuteCache          *memsto.AlertMuteCacheType
 	datasourceCache         *memsto.DatasourceCacheType
+	ruleGroupCache          *memsto.RuleGroupCacheType
 
 	promClients *prom.PromClientMap
 
@@ -43,7 +45,7 @@ type Scheduler struct {
 func NewScheduler(aconf aconf.Alert, externalProcessors *process.ExternalProcessorsType, arc *memsto.AlertRuleCacheType,
 	targetCache *memsto.TargetCacheType, toarc *memsto.TargetsOfAlertRuleCacheType,
 	busiGroupCache *memsto.BusiGroupCacheType, alertMuteCache *memsto.AlertMuteCacheType, datasourceCache *memsto.DatasourceCacheType,
-	promClients *prom.PromClientMap, naming *naming.Naming, ctx *ctx.Context, stats *astats.Stats) *Scheduler {
+	ruleGroupCache *memsto.RuleGroupCacheType, promClients *prom.PromClientMap, naming *naming.Naming, ctx *ctx.Context, stats *astats.Stats) *Scheduler {
 	scheduler := &Scheduler{
 		aconf:      aconf,
 		alertRules: make(map[string]*AlertRuleWorker),
@@ -56,6 +58,7 @@ func NewScheduler(aconf aconf.Alert, externalProcessors *process.ExternalProcess
 		busiGroupCache:          busiGroupCache,
 		alertMuteCache:          alertMuteCache,
 		datasourceCache:         datasourceCache,
+		ruleGroupCache:          ruleGroupCache,
 
 		promClients: promClients,
 		naming:      naming,
@@ -92,6 +95,11 @@ func (s *Scheduler) syncAlertRules() {
 			continue
 		}
 
+		// 属于规则组的规则由规则组按顺序执行
+		if IsGroupable(rule) && s.ruleGroupCache.AlertRuleGrouped(rule.Id) {
+			continue
+		}
+
 		ruleType := rule.GetRuleType()
 		if rule.IsPrometheusRule() || rule.IsInnerRule() {
 			datasourceIds := s.datasourceCache.GetIDsByDsCateAndQueries(rule.Cate, rule.DatasourceQueries)
@@ -127,6 +135,14 @@ func (s *Scheduler) syncAlertRules() {
 			processor := process.NewProcessor(s.aconf.Heartbeat.EngineName, rule, 0, s.alertRuleCache, s.targetCache, s.targetsOfAlertRuleCache, s.busiGroupCache, s.alertMuteCache, s.datasourceCache, s.ctx, s.stats)
 			alertRule := NewAlertRuleWorker(rule, 0, processor, s.promClients, s.ctx)
 			alertRuleWorkers[alertRule.Hash()] = alertRule
+		} else if rule.IsMixedRule() {
+			// 跨数据源规则的每个查询各自指定数据源，不绑定单个数据源，与 host 规则一样按引擎分片
+			if !naming.DatasourceHashRing.IsHit(s.aconf.Heartbeat.EngineName, strconv.FormatInt(rule.Id, 10), s.aconf.Heartbeat.Endpoint) {
+				continue
+			}
+			processor := process.NewProcessor(s.aconf.Heartbeat.EngineName, rule, 0, s.alertRuleCache, s.targetCache, s.targetsOfAlertRuleCache, s.busiGroupCache, s.alertMuteCache, s.datasourceCache, s.ctx, s.stats)
+			alertRule := NewAlertRuleWorker(rule, 0, processor, s.promClients, s.ctx)
+			alertRuleWorkers[alertRule.Hash()] = alertRule
 		} else {
 			// 如果 rule 不是通过 prometheus engine 来告警的，则创建为 externalRule
 			// if rule is not processed by prometheus engine, create it as externalRule
@@ -185,3 +201,8 @@ func (s *Scheduler) syncAlertRules() {
 	}
 	s.ExternalProcessors.ExternalLock.Unlock()
 }
+
+// IsGroupable 由 AlertRuleWorker 执行的规则可以加入规则组，externalRule 不受规则组调度
+func IsGroupable(rule *models.AlertRule) bool {
+	return rule.IsPrometheusRule() || rule.IsInnerRule() || rule.IsHostRule() || rule.IsMixedRule()
+}
@@ -1472,51 +1472,20 @@ func (arw *AlertRuleWorker) GetAnomalyPoint(rule *models.AlertRule, dsId int64)
 		for i, query := range ruleQuery.Queries {
 			seriesTagIndex := make(map[uint64][]uint64)
 
-			plug, exists := dscache.DsCache.Get(rule.Cate, dsId)
-			if !exists {
-				logger.Warningf("rule_eval rid:%d datasource:%d not exists", rule.Id, dsId)
-				arw.Processor.Stats.CounterRuleEvalErrorTotal.WithLabelValues(fmt.Sprintf("%v", arw.Processor.DatasourceId()), GET_CLIENT, arw.Processor.BusiGroupCache.GetNameByBusiGroupId(arw.Rule.GroupId), fmt.Sprintf("%v", arw.Rule.Id)).Inc()
-
-				arw.Processor.Stats.GaugeQuerySeriesCount.WithLabelValues(
-					fmt.Sprintf("%v", arw.Rule.Id),
-					fmt.Sprintf("%v", arw.Processor.DatasourceId()),
-					fmt.Sprintf("%v", i),
-				).Set(-2)
-
-				return points, recoverPoints, fmt.Errorf("rule_eval:%d datasource:%d not exists", rule.Id, dsId)
-			}
-
-			if err = ExecuteQueryTemplate(rule.Cate, query, nil); err != nil {
-				logger.Warningf("rule_eval rid:%d execute query template error: %v", rule.Id, err)
-				arw.Processor.Stats.CounterRuleEvalErrorTotal.WithLabelValues(fmt.Sprintf("%v", arw.Processor.DatasourceId()), EXEC_TEMPLATE, arw.Processor.BusiGroupCache.GetNameByBusiGroupId(arw.Rule.GroupId), fmt.Sprintf("%v", arw.Rule.Id)).Inc()
-				arw.Processor.Stats.GaugeQuerySeriesCount.WithLabelValues(
-					fmt.Sprintf("%v", arw.Rule.Id),
-					fmt.Sprintf("%v", arw.Processor.DatasourceId()),
-					fmt.Sprintf("%v", i),
-				).Set(-3)
-			}
-
-			ctx := context.WithValue(context.Background(), "delay", int64(rule.Delay))
-			series, err := plug.QueryData(ctx, query)
-			arw.Processor.Stats.CounterQueryDataTotal.WithLabelValues(fmt.Sprintf("%d", arw.DatasourceId), fmt.Sprintf("%d", rule.Id)).Inc()
-			if err != nil {
-				logger.Warningf("rule_eval rid:%d query data error: %v", rule.Id, err)
-				arw.Processor.Stats.CounterRuleEvalErrorTotal.WithLabelValues(fmt.Sprintf("%v", arw.Processor.DatasourceId()), GET_CLIENT, arw.Processor.BusiGroupCache.GetNameByBusiGroupId(arw.Rule.GroupId), fmt.Sprintf("%v", arw.Rule.Id)).Inc()
-				arw.Processor.Stats.GaugeQuerySeriesCount.WithLabelValues(
-					fmt.Sprintf("%v", arw.Rule.Id),
-					fmt.Sprintf("%v", arw.Processor.DatasourceId()),
-					fmt.Sprintf("%v", i),
-				).Set(-1)
-
-				return points, recoverPoints, fmt.Errorf("rule_eval:%d query data error: %v", rule.Id, err)
+			var series []models.DataResp
+			if rule.IsMixedRule() {
+				// 跨数据源规则，每个查询发往各自的数据源
+				series, err = arw.queryMixedData(rule, i, query)
+				if err != nil {
+					return points, recoverPoints, err
+				}
+			} else {
+				series, err = arw.queryData(rule, rule.Cate, dsId, i, query)
+				if err != nil {
+					return points, recoverPoints, err
+				}
 			}
 
-			arw.Processor.Stats.GaugeQuerySeriesCount.WithLabelValues(
-				fmt.Sprintf("%v", arw.Rule.Id),
-				fmt.Sprintf("%v", arw.Processor.DatasourceId()),
-				fmt.Sprintf("%v", i),
-			).Set(float64(len(series)))
-
 			//  此条日志很重要，是告警判断的现场值
 			logger.Infof("rule_eval rid:%d req:%+v resp:%v", rule.Id, query, series)
 			for i := 0; i < len(series); i++ {
@@ -1716,6 +1685,56 @@ func (arw *AlertRuleWorker) GetAnomalyPoint(rule *models.AlertRule, dsId int64)
 	return points, recoverPoints, nil
 }
 
+// queryData 通过 dscache 中 cate 对应的数据源插件执行单个查询
+func (arw *AlertRuleWorker) queryData(rule *models.AlertRule, cate string, dsId int64, i int, query interface{}) ([]models.DataResp, error) {
+	plug, exists := dscache.DsCache.Get(cate, dsId)
+	if !exists {
+		logger.Warningf("rule_eval rid:%d datasource:%d not exists", rule.Id, dsId)
+		arw.Processor.Stats.CounterRuleEvalErrorTotal.WithLabelValues(fmt.Sprintf("%v", arw.Processor.DatasourceId()), GET_CLIENT, arw.Processor.BusiGroupCache.GetNameByBusiGroupId(arw.Rule.GroupId), fmt.Sprintf("%v", arw.Rule.Id)).Inc()
+
+		arw.Processor.Stats.GaugeQuerySeriesCount.WithLabelValues(
+			fmt.Sprintf("%v", arw.Rule.Id),
+			fmt.Sprintf("%v", arw.Processor.DatasourceId()),
+			fmt.Sprintf("%v", i),
+		).Set(-2)
+
+		return nil, fmt.Errorf("rule_eval:%d datasource:%d not exists", rule.Id, dsId)
+	}
+
+	if err := ExecuteQueryTemplate(cate, query, nil); err != nil {
+		logger.Warningf("rule_eval rid:%d execute query template error: %v", rule.Id, err)
+		arw.Processor.Stats.CounterRuleEvalErrorTotal.WithLabelValues(fmt.Sprintf("%v", arw.Processor.DatasourceId()), EXEC_TEMPLATE, arw.Processor.BusiGroupCache.GetNameByBusiGroupId(arw.Rule.GroupId), fmt.Sprintf("%v", arw.Rule.Id)).Inc()
+		arw.Processor.Stats.GaugeQuerySeriesCount.WithLabelValues(
+			fmt.Sprintf("%v", arw.Rule.Id),
+			fmt.Sprintf("%v", arw.Processor.DatasourceId()),
+			fmt.Sprintf("%v", i),
+		).Set(-3)
+	}
+
+	ctx := context.WithValue(context.Background(), "delay", int64(rule.Delay))
+	series, err := plug.QueryData(ctx, query)
+	arw.Processor.Stats.CounterQueryDataTotal.WithLabelValues(fmt.Sprintf("%d", arw.DatasourceId), fmt.Sprintf("%d", rule.Id)).Inc()
+	if err != nil {
+		logger.Warningf("rule_eval rid:%d query data error: %v", rule.Id, err)
+		arw.Processor.Stats.CounterRuleEvalErrorTotal.WithLabelValues(fmt.Sprintf("%v", arw.Processor.DatasourceId()), GET_CLIENT, arw.Processor.BusiGroupCache.GetNameByBusiGroupId(arw.Rule.GroupId), fmt.Sprintf("%v", arw.Rule.Id)).Inc()
+		arw.Processor.Stats.GaugeQuerySeriesCount.WithLabelValues(
+			fmt.Sprintf("%v", arw.Rule.Id),
+			fmt.Sprintf("%v", arw.Processor.DatasourceId()),
+			fmt.Sprintf("%v", i),
+		).Set(-1)
+
+		return nil, fmt.Errorf("rule_eval:%d query data error: %v", rule.Id, err)
+	}
+
+	arw.Processor.Stats.GaugeQuerySeriesCount.WithLabelValues(
+		fmt.Sprintf("%v", arw.Rule.Id),
+		fmt.Sprintf("%v", arw.Processor.DatasourceId()),
+		fmt.Sprintf("%v", i),
+	).Set(float64(len(series)))
+
+	return series, nil
+}
+
 // ExecuteQueryTemplate 根据数据源类型对 Query 进行模板渲染处理
 // cate: 数据源类别，如 "mysql", "pgsql" 等
 // query: 查询对象，如果是数据库类型的数据源，会处理其中的 sql 字段
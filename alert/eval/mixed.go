@@ -0,0 +1,153 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/prometheus/common/model"
+	"github.com/toolkits/pkg/logger"
+)
+
+// queryMixedData 跨数据源规则的查询入口
+// 每个查询通过自身的 cate 和 datasource_id 找到对应数据源，prometheus 类查询走 PromClients，
+// 其余类型走 dscache 中的数据源插件，查询结果统一转换为 DataResp，后续按 ref 参与 join 与表达式计算
+func (arw *AlertRuleWorker) queryMixedData(rule *models.AlertRule, i int, query interface{}) ([]models.DataResp, error) {
+	mq, err := models.ParseMixedQuery(query)
+	if err != nil {
+		logger.Warningf("rule_eval rid:%d query:%+v parse mixed query error:%v", rule.Id, query, err)
+		arw.Processor.Stats.CounterRuleEvalErrorTotal.WithLabelValues(fmt.Sprintf("%v", arw.Processor.DatasourceId()), CHECK_QUERY, arw.Processor.BusiGroupCache.GetNameByBusiGroupId(arw.Rule.GroupId), fmt.Sprintf("%v", arw.Rule.Id)).Inc()
+		return nil, fmt.Errorf("rule_eval:%d parse mixed query error: %v", rule.Id, err)
+	}
+
+	if mq.Cate == "" || mq.Cate == models.MIXED || mq.DatasourceId <= 0 {
+		arw.Processor.Stats.CounterRuleEvalErrorTotal.WithLabelValues(fmt.Sprintf("%v", arw.Processor.DatasourceId()), CHECK_QUERY, arw.Processor.BusiGroupCache.GetNameByBusiGroupId(arw.Rule.GroupId), fmt.Sprintf("%v", arw.Rule.Id)).Inc()
+		return nil, fmt.Errorf("rule_eval:%d query %s cate:%s datasource:%d invalid", rule.Id, mq.Ref, mq.Cate, mq.DatasourceId)
+	}
+
+	if mq.Cate != models.PROMETHEUS {
+		return arw.queryData(rule, mq.Cate, mq.DatasourceId, i, query)
+	}
+
+	return arw.queryPromData(rule, mq, i)
+}
+
+func (arw *AlertRuleWorker) queryPromData(rule *models.AlertRule, mq models.MixedQuery, i int) ([]models.DataResp, error) {
+	promql := strings.TrimSpace(mq.PromQl)
+	if promql == "" {
+		arw.Processor.Stats.CounterRuleEvalErrorTotal.WithLabelValues(fmt.Sprintf("%v", arw.Processor.DatasourceId()), CHECK_QUERY, arw.Processor.BusiGroupCache.GetNameByBusiGroupId(arw.Rule.GroupId), fmt.Sprintf("%v", arw.Rule.Id)).Inc()
+		return nil, fmt.Errorf("rule_eval:%d query %s promql is blank", rule.Id, mq.Ref)
+	}
+
+	readerClient := arw.PromClients.GetCli(mq.DatasourceId)
+	if readerClient == nil {
+		logger.Warningf("rule_eval rid:%d datasource:%d reader client is nil", rule.Id, mq.DatasourceId)
+		arw.Processor.Stats.CounterRuleEvalErrorTotal.WithLabelValues(fmt.Sprintf("%v", arw.Processor.DatasourceId()), GET_CLIENT, arw.Processor.BusiGroupCache.GetNameByBusiGroupId(arw.Rule.GroupId), fmt.Sprintf("%v", arw.Rule.Id)).Inc()
+		arw.Processor.Stats.GaugeQuerySeriesCount.WithLabelValues(
+			fmt.Sprintf("%v", arw.Rule.Id),
+			fmt.Sprintf("%v", arw.Processor.DatasourceId()),
+			fmt.Sprintf("%v", i),
+		).Set(-2)
+		return nil, fmt.Errorf("rule_eval:%d datasource:%d not exists", rule.Id, mq.DatasourceId)
+	}
+
+	ts := time.Now().Add(-time.Duration(rule.Delay) * time.Second)
+	arw.Processor.Stats.CounterQueryDataTotal.WithLabelValues(fmt.Sprintf("%d", mq.DatasourceId), fmt.Sprintf("%d", rule.Id)).Inc()
+	value, warnings, err := readerClient.Query(context.Background(), promql, ts)
+	if err != nil {
+		logger.Warningf("rule_eval rid:%d promql:%s query data error: %v", rule.Id, promql, err)
+		arw.Processor.Stats.CounterQueryDataErrorTotal.WithLabelValues(fmt.Sprintf("%d", mq.DatasourceId)).Inc()
+		arw.Processor.Stats.CounterRuleEvalErrorTotal.WithLabelValues(fmt.Sprintf("%v", arw.Processor.DatasourceId()), QUERY_DATA, arw.Processor.BusiGroupCache.GetNameByBusiGroupId(arw.Rule.GroupId), fmt.Sprintf("%v", arw.Rule.Id)).Inc()
+		arw.Processor.Stats.GaugeQuerySeriesCount.WithLabelValues(
+			fmt.Sprintf("%v", arw.Rule.Id),
+			fmt.Sprintf("%v", arw.Processor.DatasourceId()),
+			fmt.Sprintf("%v", i),
+		).Set(-1)
+		return nil, fmt.Errorf("rule_eval:%d query data error: %v", rule.Id, err)
+	}
+
+	if len(warnings) > 0 {
+		logger.Warningf("rule_eval rid:%d promql:%s, warnings:%v", rule.Id, promql, warnings)
+	}
+
+	series := ConvertPromValueToDataResp(value, mq.Ref, promql)
+	arw.Processor.Stats.GaugeQuerySeriesCount.WithLabelValues(
+		fmt.Sprintf("%v", arw.Rule.Id),
+		fmt.Sprintf("%v", arw.Processor.DatasourceId()),
+		fmt.Sprintf("%v", i),
+	).Set(float64(len(series)))
+
+	return series, nil
+}
+
+// ConvertPromValueToDataResp 将 prometheus 查询结果转换为 DataResp，便于与其他数据源的结果一起 join
+func ConvertPromValueToDataResp(value model.Value, ref, promql string) []models.DataResp {
+	var lst []models.DataResp
+	if value == nil {
+		return lst
+	}
+
+	switch value.Type() {
+	case model.ValVector:
+		items, ok := value.(model.Vector)
+		if !ok {
+			return lst
+		}
+
+		for _, item := range items {
+			if math.IsNaN(float64(item.Value)) {
+				continue
+			}
+
+			lst = append(lst, models.DataResp{
+				Ref:    ref,
+				Metric: item.Metric,
+				Values: [][]float64{{float64(item.Timestamp.Unix()), float64(item.Value)}},
+				Query:  promql,
+			})
+		}
+	case model.ValMatrix:
+		items, ok := value.(model.Matrix)
+		if !ok {
+			return lst
+		}
+
+		for _, item := range items {
+			values := make([][]float64, 0, len(item.Values))
+			for _, v := range item.Values {
+				if math.IsNaN(float64(v.Value)) {
+					continue
+				}
+				values = append(values, []float64{float64(v.Timestamp.Unix()), float64(v.Value)})
+			}
+
+			if len(values) == 0 {
+				continue
+			}
+
+			lst = append(lst, models.DataResp{
+				Ref:    ref,
+				Metric: item.Metric,
+				Values: values,
+				Query:  promql,
+			})
+		}
+	case model.ValScalar:
+		item, ok := value.(*model.Scalar)
+		if !ok || math.IsNaN(float64(item.Value)) {
+			return lst
+		}
+
+		lst = append(lst, models.DataResp{
+			Ref:    ref,
+			Metric: model.Metric{},
+			Values: [][]float64{{float64(item.Timestamp.Unix()), float64(item.Value)}},
+			Query:  promql,
+		})
+	}
+
+	return lst
+}
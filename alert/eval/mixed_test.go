@@ -0,0 +1,233 @@
+package eval
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/alert/astats"
+	"github.com/ccfos/nightingale/v6/alert/process"
+	"github.com/ccfos/nightingale/v6/datasource"
+	"github.com/ccfos/nightingale/v6/dscache"
+	"github.com/ccfos/nightingale/v6/memsto"
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/parser"
+	promsdk "github.com/ccfos/nightingale/v6/pkg/prom"
+	"github.com/ccfos/nightingale/v6/prom"
+	"github.com/prometheus/common/model"
+)
+
+func TestConvertPromValueToDataResp(t *testing.T) {
+	vector := model.Vector{
+		&model.Sample{Metric: model.Metric{"service": "api"}, Value: 5, Timestamp: model.TimeFromUnix(100)},
+		&model.Sample{Metric: model.Metric{"service": "web"}, Value: model.SampleValue(math.NaN()), Timestamp: model.TimeFromUnix(100)},
+	}
+
+	got := ConvertPromValueToDataResp(vector, "A", "errors")
+	if len(got) != 1 {
+		t.Fatalf("expect 1 series, got %d", len(got))
+	}
+
+	ts, v, exists := got[0].Last()
+	if !exists || ts != 100 || v != 5 || got[0].Ref != "A" {
+		t.Fatalf("unexpected series: %+v", got[0])
+	}
+}
+
+func TestMixedJoinAndCalc(t *testing.T) {
+	// A 来自 prometheus，C 来自 elasticsearch，按 service 标签对齐后计算 $A / $C
+	promSeries := ConvertPromValueToDataResp(model.Vector{
+		&model.Sample{Metric: model.Metric{"service": "api"}, Value: 12, Timestamp: model.TimeFromUnix(100)},
+		&model.Sample{Metric: model.Metric{"service": "web"}, Value: 1, Timestamp: model.TimeFromUnix(100)},
+	}, "A", "errors")
+	esSeries := []models.DataResp{
+		{Ref: "C", Metric: model.Metric{"service": "api"}, Values: [][]float64{{100, 100}}},
+		{Ref: "C", Metric: model.Metric{"service": "web"}, Values: [][]float64{{100, 100}}},
+	}
+
+	seriesStore := make(map[uint64]models.DataResp)
+	seriesTagIndexes := map[string]map[uint64][]uint64{
+		"A": make(map[uint64][]uint64),
+		"C": make(map[uint64][]uint64),
+	}
+	MakeSeriesMap(promSeries, seriesTagIndexes["A"], seriesStore)
+	MakeSeriesMap(esSeries, seriesTagIndexes["C"], seriesStore)
+
+	trigger := models.Trigger{
+		Exp:     "$A / $C > 0.05",
+		JoinRef: "A",
+		Joins:   []models.Join{{JoinType: "inner_join", Ref: "C", On: []string{"service"}}},
+	}
+
+	triggered := make(map[string]bool)
+	for _, hashes := range ProcessJoins(1, trigger, seriesTagIndexes, seriesStore) {
+		m := make(map[string]interface{})
+		var service string
+		for _, h := range hashes {
+			series := seriesStore[h]
+			_, v, _ := series.Last()
+			m["$"+series.Ref] = v
+			service = string(series.Metric["service"])
+		}
+		triggered[service] = parser.CalcWithRid(trigger.Exp, m, 1)
+	}
+
+	if !triggered["api"] || triggered["web"] {
+		t.Fatalf("unexpected result: %+v", triggered)
+	}
+}
+
+// fakePromAPI 只实现 Query，记录收到的 promql
+type fakePromAPI struct {
+	promsdk.API
+	queries []string
+}
+
+func (f *fakePromAPI) Query(ctx context.Context, query string, ts time.Time) (model.Value, promsdk.Warnings, error) {
+	f.queries = append(f.queries, query)
+	return model.Vector{
+		&model.Sample{Metric: model.Metric{"service": "api"}, Value: 1, Timestamp: model.TimeFromUnix(100)},
+	}, nil, nil
+}
+
+// fakeDatasource 只实现 InitClient 与 QueryData，返回时带上自身 cate，便于断言分发结果
+type fakeDatasource struct {
+	datasource.Datasource
+	cate    string
+	queries []interface{}
+}
+
+func (f *fakeDatasource) InitClient() error {
+	return nil
+}
+
+func (f *fakeDatasource) QueryData(ctx context.Context, query interface{}) ([]models.DataResp, error) {
+	f.queries = append(f.queries, query)
+	return []models.DataResp{
+		{Ref: "B", Metric: model.Metric{"cate": model.LabelValue(f.cate)}, Values: [][]float64{{100, 2}}},
+	}, nil
+}
+
+var (
+	mixedStatsOnce sync.Once
+	mixedStats     *astats.Stats
+)
+
+func newMixedTestWorker(promClients *prom.PromClientMap) *AlertRuleWorker {
+	mixedStatsOnce.Do(func() {
+		mixedStats = astats.NewSyncStats()
+	})
+
+	rule := &models.AlertRule{Id: 1, Cate: models.MIXED}
+	return &AlertRuleWorker{
+		Rule: rule,
+		Processor: &process.Processor{
+			Stats:          mixedStats,
+			BusiGroupCache: &memsto.BusiGroupCacheType{},
+		},
+		PromClients: promClients,
+	}
+}
+
+func TestQueryMixedDataDispatch(t *testing.T) {
+	promAPI := &fakePromAPI{}
+	promClients := &prom.PromClientMap{
+		ReaderClients: make(map[int64]promsdk.API),
+		WriterClients: make(map[int64]promsdk.WriterType),
+	}
+	promClients.Set(9001, promAPI, promsdk.WriterType{})
+
+	mysqlDs := &fakeDatasource{cate: models.MYSQL}
+	esDs := &fakeDatasource{cate: models.ELASTICSEARCH}
+	dscache.DsCache.Put(models.MYSQL, 9002, mysqlDs)
+	dscache.DsCache.Put(models.ELASTICSEARCH, 9003, esDs)
+
+	tests := []struct {
+		name     string
+		query    map[string]interface{}
+		wantErr  string
+		wantCate string
+		check    func(t *testing.T)
+	}{
+		{
+			name:  "prometheus query goes to prom client",
+			query: map[string]interface{}{"ref": "A", "cate": models.PROMETHEUS, "datasource_id": 9001, "prom_ql": "up"},
+			check: func(t *testing.T) {
+				if len(promAPI.queries) != 1 || promAPI.queries[0] != "up" {
+					t.Fatalf("unexpected prom queries: %+v", promAPI.queries)
+				}
+			},
+		},
+		{
+			name:     "mysql query goes to mysql plugin",
+			query:    map[string]interface{}{"ref": "B", "cate": models.MYSQL, "datasource_id": 9002, "sql": "select 1"},
+			wantCate: models.MYSQL,
+		},
+		{
+			name:     "elasticsearch query goes to es plugin",
+			query:    map[string]interface{}{"ref": "B", "cate": models.ELASTICSEARCH, "datasource_id": 9003},
+			wantCate: models.ELASTICSEARCH,
+		},
+		{
+			name:    "datasource of another cate is not used",
+			query:   map[string]interface{}{"ref": "B", "cate": models.ELASTICSEARCH, "datasource_id": 9002},
+			wantErr: "datasource:9002 not exists",
+		},
+		{
+			name:    "missing prom client",
+			query:   map[string]interface{}{"ref": "A", "cate": models.PROMETHEUS, "datasource_id": 9999, "prom_ql": "up"},
+			wantErr: "datasource:9999 not exists",
+		},
+		{
+			name:    "blank promql",
+			query:   map[string]interface{}{"ref": "A", "cate": models.PROMETHEUS, "datasource_id": 9001},
+			wantErr: "promql is blank",
+		},
+		{
+			name:    "missing datasource",
+			query:   map[string]interface{}{"ref": "B", "cate": models.MYSQL},
+			wantErr: "invalid",
+		},
+		{
+			name:    "nested mixed cate",
+			query:   map[string]interface{}{"ref": "B", "cate": models.MIXED, "datasource_id": 9002},
+			wantErr: "invalid",
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			arw := newMixedTestWorker(promClients)
+			series, err := arw.queryMixedData(arw.Rule, i, tt.query)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expect error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(series) != 1 {
+				t.Fatalf("expect 1 series, got %d", len(series))
+			}
+
+			if tt.wantCate != "" && string(series[0].Metric["cate"]) != tt.wantCate {
+				t.Fatalf("expect series from %s, got %+v", tt.wantCate, series[0])
+			}
+
+			if tt.check != nil {
+				tt.check(t)
+			}
+		})
+	}
+
+	if len(mysqlDs.queries) != 1 || len(esDs.queries) != 1 {
+		t.Fatalf("unexpected plugin calls: mysql=%d es=%d", len(mysqlDs.queries), len(esDs.queries))
+	}
+}
@@ -0,0 +1,250 @@
+// Package eventchart 在发送通知前为告警事件渲染最近一段时间的曲线图
+package eventchart
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/alert/aconf"
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/chart"
+	promsdk "github.com/ccfos/nightingale/v6/pkg/prom"
+	"github.com/ccfos/nightingale/v6/pkg/promql"
+	"github.com/ccfos/nightingale/v6/prom"
+
+	"github.com/google/uuid"
+	lark "github.com/larksuite/oapi-sdk-go/v3"
+	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
+	"github.com/prometheus/common/model"
+	"github.com/toolkits/pkg/logger"
+)
+
+const (
+	// 一张图上最多绘制的曲线数量
+	maxSeries = 10
+
+	queryTimeout  = 10 * time.Second
+	uploadTimeout = 10 * time.Second
+
+	// 图片访问路径，由 alert/router 提供，无需登录
+	ImagePath = "/v1/n9e/event-chart/"
+)
+
+type uploader interface {
+	Upload(data []byte) (string, error)
+}
+
+type Renderer struct {
+	conf        aconf.EventChart
+	promClients *prom.PromClientMap
+	uploaders   map[string]uploader
+}
+
+func New(conf aconf.EventChart, promClients *prom.PromClientMap) *Renderer {
+	r := &Renderer{
+		conf:        conf,
+		promClients: promClients,
+		uploaders:   make(map[string]uploader),
+	}
+
+	if conf.Feishu.AppId != "" {
+		r.uploaders["feishu"] = newLarkUploader(conf.Feishu, lark.FeishuBaseUrl)
+	}
+
+	if conf.Lark.AppId != "" {
+		r.uploaders["lark"] = newLarkUploader(conf.Lark, lark.LarkBaseUrl)
+	}
+
+	return r
+}
+
+// Attach 渲染事件的曲线图并填充 ChartImage、ChartUrl、ChartImageKeys，失败时只记录日志，不影响通知发送
+func (r *Renderer) Attach(event *models.AlertCurEvent, siteUrl string) {
+	if r == nil || !r.conf.Enable {
+		return
+	}
+
+	if event.Cate != models.PROMETHEUS || event.PromQl == "" || r.promClients.IsNil(event.DatasourceId) {
+		return
+	}
+
+	series, err := r.query(event)
+	if err != nil {
+		logger.Warningf("event_chart: rule_id=%d hash=%s query failed: %v", event.RuleId, event.Hash, err)
+		return
+	}
+
+	data, err := chart.Render(series, chart.Options{
+		Width:  r.conf.Width,
+		Height: r.conf.Height,
+		Title:  event.RuleName,
+	})
+	if err != nil {
+		logger.Warningf("event_chart: rule_id=%d hash=%s render failed: %v", event.RuleId, event.Hash, err)
+		return
+	}
+
+	event.ChartImage = data
+
+	key := uuid.NewString()
+	save(key, data, time.Duration(r.conf.CacheMinutes)*time.Minute)
+
+	base := r.conf.PublicUrl
+	if base == "" {
+		base = siteUrl
+	}
+	if base != "" {
+		event.ChartUrl = strings.TrimRight(base, "/") + ImagePath + key + ".png"
+	}
+
+	for name, u := range r.uploaders {
+		imageKey, err := u.Upload(data)
+		if err != nil {
+			logger.Warningf("event_chart: rule_id=%d hash=%s upload to %s failed: %v", event.RuleId, event.Hash, name, err)
+			continue
+		}
+
+		if event.ChartImageKeys == nil {
+			event.ChartImageKeys = make(map[string]string)
+		}
+		event.ChartImageKeys[name] = imageKey
+	}
+}
+
+// query 查询告警语句去掉比较运算后的表达式，只保留和事件标签一致的曲线
+func (r *Renderer) query(event *models.AlertCurEvent) ([]chart.Series, error) {
+	queries, err := promql.SplitBinaryOp(event.PromQl)
+	if err != nil || len(queries) == 0 {
+		queries = []string{event.PromQl}
+	}
+	sort.Strings(queries)
+
+	tags := event.TagsMap
+	if len(tags) == 0 {
+		tags = make(map[string]string)
+		for _, pair := range event.TagsJSON {
+			arr := strings.SplitN(pair, "=", 2)
+			if len(arr) == 2 {
+				tags[arr[0]] = arr[1]
+			}
+		}
+	}
+
+	end := time.Now()
+	rangeDuration := time.Duration(r.conf.RangeMinutes) * time.Minute
+	step := rangeDuration / 150
+	if step < time.Second {
+		step = time.Second
+	}
+
+	cli := r.promClients.GetCli(event.DatasourceId)
+
+	var matched, all []chart.Series
+	for _, q := range queries {
+		ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+		value, _, err := cli.QueryRange(ctx, q, promsdk.Range{Start: end.Add(-rangeDuration), End: end, Step: step})
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("query %s: %v", q, err)
+		}
+
+		matrix, ok := value.(model.Matrix)
+		if !ok {
+			continue
+		}
+
+		for _, stream := range matrix {
+			s := chart.Series{Name: seriesName(q, stream.Metric, len(queries) > 1)}
+			for _, v := range stream.Values {
+				s.Points = append(s.Points, chart.Point{T: v.Timestamp.Unix(), V: float64(v.Value)})
+			}
+
+			all = append(all, s)
+			if matchTags(stream.Metric, tags) {
+				matched = append(matched, s)
+			}
+		}
+	}
+
+	// 聚合后的曲线可能和事件标签都对不上，此时画出全部曲线
+	if len(matched) == 0 {
+		matched = all
+	}
+
+	if len(matched) > maxSeries {
+		matched = matched[:maxSeries]
+	}
+
+	return matched, nil
+}
+
+func matchTags(metric model.Metric, tags map[string]string) bool {
+	for name, value := range metric {
+		if name == model.MetricNameLabel {
+			continue
+		}
+
+		if v, has := tags[string(name)]; has && v != string(value) {
+			return false
+		}
+	}
+	return true
+}
+
+func seriesName(query string, metric model.Metric, withQuery bool) string {
+	name := metric.String()
+	if len(metric) == 0 {
+		name = query
+	}
+
+	if withQuery && len(metric) > 0 {
+		name = query + " " + name
+	}
+
+	return name
+}
+
+type larkUploader struct {
+	client *lark.Client
+}
+
+func newLarkUploader(conf aconf.ChartUploader, baseUrl string) *larkUploader {
+	return &larkUploader{
+		client: lark.NewClient(conf.AppId, conf.AppSecret,
+			lark.WithOpenBaseUrl(baseUrl),
+			lark.WithEnableTokenCache(true),
+		),
+	}
+}
+
+// Upload 上传消息图片，返回的 image_key 可以在卡片消息的 img 元素中使用
+func (u *larkUploader) Upload(data []byte) (string, error) {
+	req := larkim.NewCreateImageReqBuilder().
+		Body(larkim.NewCreateImageReqBodyBuilder().
+			ImageType(larkim.ImageTypeMessage).
+			Image(bytes.NewReader(data)).
+			Build()).
+		Build()
+
+	ctx, cancel := context.WithTimeout(context.Background(), uploadTimeout)
+	defer cancel()
+
+	resp, err := u.client.Im.Image.Create(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	if !resp.Success() {
+		return "", fmt.Errorf("code: %d, msg: %s", resp.Code, resp.Msg)
+	}
+
+	if resp.Data == nil || resp.Data.ImageKey == nil {
+		return "", fmt.Errorf("image_key is empty")
+	}
+
+	return *resp.Data.ImageKey, nil
+}
@@ -0,0 +1,137 @@
+package eventchart
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/alert/aconf"
+	"github.com/ccfos/nightingale/v6/models"
+	promsdk "github.com/ccfos/nightingale/v6/pkg/prom"
+	"github.com/ccfos/nightingale/v6/prom"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/common/model"
+	"github.com/redis/go-redis/v9"
+)
+
+type fakeAPI struct {
+	promsdk.API
+	queries []string
+}
+
+func (f *fakeAPI) QueryRange(ctx context.Context, query string, r promsdk.Range) (model.Value, promsdk.Warnings, error) {
+	f.queries = append(f.queries, query)
+
+	var matrix model.Matrix
+	for _, ident := range []string{"host-1", "host-2"} {
+		stream := &model.SampleStream{Metric: model.Metric{"__name__": "cpu_usage_active", "ident": model.LabelValue(ident)}}
+		for t := r.Start; !t.After(r.End); t = t.Add(r.Step) {
+			stream.Values = append(stream.Values, model.SamplePair{Timestamp: model.TimeFromUnix(t.Unix()), Value: 90})
+		}
+		matrix = append(matrix, stream)
+	}
+	return matrix, nil, nil
+}
+
+type fakeUploader struct{}
+
+func (fakeUploader) Upload(data []byte) (string, error) {
+	return "img_v2_test", nil
+}
+
+func TestAttach(t *testing.T) {
+	api := &fakeAPI{}
+	promClients := &prom.PromClientMap{ReaderClients: map[int64]promsdk.API{1: api}}
+
+	r := New(aconf.EventChart{Enable: true, RangeMinutes: 30, CacheMinutes: 10}, promClients)
+	r.uploaders["feishu"] = fakeUploader{}
+
+	event := &models.AlertCurEvent{
+		Cate:         models.PROMETHEUS,
+		DatasourceId: 1,
+		RuleName:     "cpu high",
+		PromQl:       "cpu_usage_active > 80",
+		TagsJSON:     []string{"ident=host-2", "rulename=cpu high"},
+	}
+	r.Attach(event, "http://n9e.example.com/")
+
+	if len(api.queries) != 1 || api.queries[0] != "cpu_usage_active" {
+		t.Fatalf("unexpected queries: %v", api.queries)
+	}
+
+	if len(event.ChartImage) == 0 {
+		t.Fatal("chart image is empty")
+	}
+
+	if !strings.HasPrefix(event.ChartUrl, "http://n9e.example.com"+ImagePath) || !strings.HasSuffix(event.ChartUrl, ".png") {
+		t.Fatalf("unexpected chart url: %s", event.ChartUrl)
+	}
+
+	key := strings.TrimSuffix(strings.TrimPrefix(event.ChartUrl, "http://n9e.example.com"+ImagePath), ".png")
+	if data, has := Get(key); !has || len(data) != len(event.ChartImage) {
+		t.Errorf("chart image not found in store")
+	}
+
+	if event.ChartImageKeys["feishu"] != "img_v2_test" {
+		t.Errorf("unexpected image keys: %v", event.ChartImageKeys)
+	}
+
+	// 非 prometheus 的事件不渲染
+	other := &models.AlertCurEvent{Cate: "elasticsearch", DatasourceId: 1, PromQl: "x"}
+	r.Attach(other, "")
+	if other.ChartImage != nil {
+		t.Errorf("chart should not be rendered for elasticsearch event")
+	}
+}
+
+func TestMatchTags(t *testing.T) {
+	metric := model.Metric{"__name__": "cpu_usage_active", "ident": "host-1"}
+
+	if !matchTags(metric, map[string]string{"ident": "host-1", "rulename": "cpu"}) {
+		t.Errorf("expected metric to match")
+	}
+
+	if matchTags(metric, map[string]string{"ident": "host-2"}) {
+		t.Errorf("expected metric not to match")
+	}
+
+	// 聚合后没有 ident 标签
+	if !matchTags(model.Metric{}, map[string]string{"ident": "host-2"}) {
+		t.Errorf("expected empty metric to match")
+	}
+}
+
+func TestSaveToRedis(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	RegisterRedis(redis.NewClient(&redis.Options{Addr: s.Addr()}))
+	defer RegisterRedis(nil)
+
+	save("redis-key", []byte("png"), time.Minute)
+
+	// 图片只写入 redis，其他实例也能读到
+	store.RLock()
+	_, local := store.images["redis-key"]
+	store.RUnlock()
+	if local {
+		t.Fatal("image should not be kept in memory when redis is available")
+	}
+
+	if data, has := Get("redis-key"); !has || string(data) != "png" {
+		t.Fatalf("get from redis: %q %v", data, has)
+	}
+
+	if ttl := s.TTL(redisKeyPrefix + "redis-key"); ttl != time.Minute {
+		t.Fatalf("ttl = %v", ttl)
+	}
+
+	if _, has := Get("missing"); has {
+		t.Fatal("missing image should not be found")
+	}
+}
@@ -0,0 +1,117 @@
+package eventchart
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/storage"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/toolkits/pkg/logger"
+)
+
+// 内存中最多保留的图片数量，超出后淘汰最早过期的
+const maxImages = 10000
+
+type image struct {
+	data     []byte
+	expireAt time.Time
+}
+
+type imageStore struct {
+	sync.RWMutex
+	images map[string]*image
+}
+
+var store = &imageStore{images: make(map[string]*image)}
+
+const (
+	redisKeyPrefix = "n9e_event_chart:"
+	redisTimeout   = 3 * time.Second
+)
+
+var redisCli storage.Redis
+
+// RegisterRedis 注册 redis 后图片保存在 redis 中，site_url 后面的任意实例都能返回图片；
+// 未注册或写入失败时退回到本进程内存
+func RegisterRedis(r storage.Redis) {
+	redisCli = r
+}
+
+func save(key string, data []byte, ttl time.Duration) {
+	if redisCli != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+		defer cancel()
+
+		err := redisCli.Set(ctx, redisKeyPrefix+key, data, ttl).Err()
+		if err == nil {
+			return
+		}
+		logger.Warningf("event_chart: failed to save image %s to redis: %v", key, err)
+	}
+
+	store.set(key, data, ttl)
+}
+
+func (s *imageStore) set(key string, data []byte, ttl time.Duration) {
+	now := time.Now()
+
+	s.Lock()
+	defer s.Unlock()
+
+	if len(s.images) >= maxImages {
+		s.evict(now)
+	}
+
+	s.images[key] = &image{data: data, expireAt: now.Add(ttl)}
+}
+
+// evict 清理过期的图片，仍然超出上限时淘汰最早过期的一个
+func (s *imageStore) evict(now time.Time) {
+	var (
+		oldestKey string
+		oldest    time.Time
+	)
+
+	for key, img := range s.images {
+		if now.After(img.expireAt) {
+			delete(s.images, key)
+			continue
+		}
+
+		if oldestKey == "" || img.expireAt.Before(oldest) {
+			oldestKey, oldest = key, img.expireAt
+		}
+	}
+
+	if len(s.images) >= maxImages && oldestKey != "" {
+		delete(s.images, oldestKey)
+	}
+}
+
+// Get 获取渲染好的 png 图片，供 HTTP 接口使用
+func Get(key string) ([]byte, bool) {
+	if redisCli != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+		defer cancel()
+
+		data, err := redisCli.Get(ctx, redisKeyPrefix+key).Bytes()
+		if err == nil {
+			return data, true
+		}
+		if err != redis.Nil {
+			logger.Warningf("event_chart: failed to get image %s from redis: %v", key, err)
+		}
+	}
+
+	store.RLock()
+	defer store.RUnlock()
+
+	img, has := store.images[key]
+	if !has || time.Now().After(img.expireAt) {
+		return nil, false
+	}
+
+	return img.data, true
+}
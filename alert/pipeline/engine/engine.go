@@ -2,6 +2,9 @@ package engine
 
 import (
 	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ccfos/nightingale/v6/models"
@@ -34,6 +37,96 @@ func (e *WorkflowEngine) Execute(pipeline *models.EventPipeline, event *models.A
 		}, nil
 	}
 
+	result := e.executeDAG(buildNodeMap(nodes), connections, wfCtx)
+	result.Event = wfCtx.Event
+
+	duration := time.Since(startTime).Milliseconds()
+
+	if triggerCtx != nil && triggerCtx.Mode != "" {
+		executionID := triggerCtx.RequestID
+		if executionID == "" {
+			executionID = uuid.New().String()
+		}
+
+		if result.Status == models.ExecutionStatusWaiting {
+			if err := e.saveWait(pipeline, wfCtx, result, triggerCtx, executionID); err != nil {
+				// 等待保存失败时不暂停，避免事件丢失
+				logger.Errorf("workflow: failed to save wait: pipeline_id=%d, error=%v", pipeline.ID, err)
+				result.Status = models.ExecutionStatusFailed
+				result.ErrorNode = result.WaitNode
+				result.Message = fmt.Sprintf("failed to save wait: %v", err)
+			} else {
+				result.Event = nil
+			}
+		}
+
+		e.saveExecutionRecord(pipeline, wfCtx, result, triggerCtx, executionID, startTime.Unix(), duration)
+	}
+
+	return result.Event, result, nil
+}
+
+// Resume 从等待节点之后继续执行暂停的工作流，wait 由调用方认领
+// 需要检查事件状态时，告警事件已恢复或恢复事件又重新告警，则不再继续执行，返回的事件为 nil
+func (e *WorkflowEngine) Resume(pipeline *models.EventPipeline, wait *models.EventPipelineWait) (*models.AlertCurEvent, *models.WorkflowResult, error) {
+	wfCtx, err := wait.GetContext()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to restore workflow context: %v", err)
+	}
+
+	if wait.Recheck {
+		unchanged, err := e.eventStateUnchanged(wfCtx.Event)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if !unchanged {
+			result := &models.WorkflowResult{
+				Status:  models.ExecutionStatusSuccess,
+				Message: "event state changed while waiting, stopped",
+			}
+			e.updateExecutionRecord(pipeline, wfCtx, result, wait)
+			return nil, result, nil
+		}
+	}
+
+	nodeMap := buildNodeMap(pipeline.GetWorkflowNodes())
+	if _, has := nodeMap[wait.NodeId]; !has {
+		return nil, nil, fmt.Errorf("wait node %s not found in pipeline %d", wait.NodeId, pipeline.ID)
+	}
+
+	subMap, subConns := resumeGraph(wait.NodeId, nodeMap, pipeline.GetWorkflowConnections())
+	result := e.executeDAG(subMap, subConns, wfCtx)
+	result.Event = wfCtx.Event
+
+	if result.Status == models.ExecutionStatusWaiting {
+		// 后续节点中还有等待节点，继续暂停
+		triggerCtx := &models.WorkflowTriggerContext{NotifyRuleId: wait.NotifyRuleId, PipelineIndex: wait.PipelineIndex}
+		if err := e.saveWait(pipeline, wfCtx, result, triggerCtx, wait.ExecutionId); err != nil {
+			logger.Errorf("workflow: failed to save wait: pipeline_id=%d, error=%v", pipeline.ID, err)
+			result.Status = models.ExecutionStatusFailed
+			result.ErrorNode = result.WaitNode
+			result.Message = fmt.Sprintf("failed to save wait: %v", err)
+		} else {
+			result.Event = nil
+		}
+	}
+
+	e.updateExecutionRecord(pipeline, wfCtx, result, wait)
+	return result.Event, result, nil
+}
+
+// eventStateUnchanged 告警事件仍在当前告警中，或恢复事件没有重新告警
+func (e *WorkflowEngine) eventStateUnchanged(event *models.AlertCurEvent) (bool, error) {
+	firing, err := models.AlertCurEventExists(e.ctx, "hash = ?", event.Hash)
+	if err != nil {
+		return false, err
+	}
+
+	return firing != event.IsRecovered, nil
+}
+
+func buildNodeMap(nodes []models.WorkflowNode) map[string]*models.WorkflowNode {
 	nodeMap := make(map[string]*models.WorkflowNode)
 	for i := range nodes {
 		if nodes[i].RetryInterval == 0 {
@@ -46,17 +139,50 @@ func (e *WorkflowEngine) Execute(pipeline *models.EventPipeline, event *models.A
 
 		nodeMap[nodes[i].ID] = &nodes[i]
 	}
+	return nodeMap
+}
 
-	result := e.executeDAG(nodeMap, connections, wfCtx)
-	result.Event = wfCtx.Event
+// resumeGraph 等待节点之后可达的节点，只保留这些节点之间的连接，已执行的节点连入的边不再计入入度；
+// 不依赖等待节点的并行分支在暂停前已经执行完
+func resumeGraph(waitNodeID string, nodeMap map[string]*models.WorkflowNode, connections models.Connections) (map[string]*models.WorkflowNode, models.Connections) {
+	subMap := make(map[string]*models.WorkflowNode)
 
-	duration := time.Since(startTime).Milliseconds()
+	var queue []string
+	for _, targets := range connections[waitNodeID].Main {
+		for _, target := range targets {
+			queue = append(queue, target.Node)
+		}
+	}
 
-	if triggerCtx != nil && triggerCtx.Mode != "" {
-		e.saveExecutionRecord(pipeline, wfCtx, result, triggerCtx, startTime.Unix(), duration)
+	for len(queue) > 0 {
+		nodeID := queue[0]
+		queue = queue[1:]
+
+		if _, has := subMap[nodeID]; has {
+			continue
+		}
+
+		node, exists := nodeMap[nodeID]
+		if !exists || nodeID == waitNodeID {
+			continue
+		}
+		subMap[nodeID] = node
+
+		for _, targets := range connections[nodeID].Main {
+			for _, target := range targets {
+				queue = append(queue, target.Node)
+			}
+		}
+	}
+
+	subConns := make(models.Connections)
+	for nodeID := range subMap {
+		if nodeConns, ok := connections[nodeID]; ok {
+			subConns[nodeID] = nodeConns
+		}
 	}
 
-	return wfCtx.Event, result, nil
+	return subMap, subConns
 }
 
 func (e *WorkflowEngine) initWorkflowContext(pipeline *models.EventPipeline, event *models.AlertCurEvent, triggerCtx *models.WorkflowTriggerContext) *models.WorkflowContext {
@@ -80,6 +206,14 @@ func (e *WorkflowEngine) initWorkflowContext(pipeline *models.EventPipeline, eve
 		metadata["trigger_mode"] = triggerCtx.Mode
 		metadata["trigger_by"] = triggerCtx.TriggerBy
 		stream = triggerCtx.Stream
+
+		if triggerCtx.Resumable {
+			metadata["resumable"] = "true"
+		}
+
+		if triggerCtx.TryRun {
+			metadata["tryrun"] = "true"
+		}
 	}
 
 	return &models.WorkflowContext{
@@ -133,6 +267,8 @@ func (e *WorkflowEngine) executeDAG(nodeMap map[string]*models.WorkflowNode, con
 	executed := make(map[string]bool)
 	// 记录节点的分支选择结果
 	branchResults := make(map[string]*int)
+	// 暂停的等待节点，后继节点在恢复后执行，不依赖它的并行分支在暂停前执行完
+	var waitNode *models.WorkflowNode
 
 	for len(queue) > 0 {
 		// 取出队首节点
@@ -171,6 +307,32 @@ func (e *WorkflowEngine) executeDAG(nodeMap map[string]*models.WorkflowNode, con
 		}
 		executed[nodeID] = true
 
+		// 循环节点：对每个元素执行一次循环体，循环体不参与主流程的调度
+		if nodeOutput != nil && nodeOutput.Loop != nil && nodeResult.Status == "success" {
+			e.executeLoop(node, nodeOutput.Loop, nodeResult, nodeMap, connections, wfCtx)
+		}
+
+		// 等待节点：可以暂停时记录等待结果，后继节点不入队，由调用方保存等待后结束本次执行；
+		// 不能保存等待时节点失败，避免事件没有等待就继续处理
+		if nodeOutput != nil && nodeOutput.Wait != nil && nodeResult.Status == "success" {
+			switch {
+			case wfCtx.IsTryRun():
+				nodeResult.Message = fmt.Sprintf("delay %ds skipped in tryrun", nodeOutput.Wait.Seconds)
+			case wfCtx.Metadata["resumable"] != "true":
+				nodeResult.Status = "failed"
+				nodeResult.Error = "delay node can only wait in notify rule pipelines on center"
+			case waitNode != nil:
+				nodeResult.Status = "failed"
+				nodeResult.Error = fmt.Sprintf("node %s is already waiting, parallel delay nodes are not supported", waitNode.Name)
+			default:
+				nodeResult.Status = models.ExecutionStatusWaiting
+				waitNode = node
+				result.WaitNode = nodeID
+				result.Wait = nodeOutput.Wait
+				continue
+			}
+		}
+
 		// 保存分支结果
 		if nodeResult.BranchIndex != nil {
 			branchResults[nodeID] = nodeResult.BranchIndex
@@ -186,8 +348,10 @@ func (e *WorkflowEngine) executeDAG(nodeMap map[string]*models.WorkflowNode, con
 			}
 		}
 
-		// 检查是否终止
+		// 检查是否终止，已有等待节点时也不再恢复
 		if nodeResult.Status == "terminated" {
+			result.WaitNode = ""
+			result.Wait = nil
 			result.Message = fmt.Sprintf("workflow terminated at node %s", node.Name)
 			return result
 		}
@@ -210,6 +374,11 @@ func (e *WorkflowEngine) executeDAG(nodeMap map[string]*models.WorkflowNode, con
 		}
 	}
 
+	if waitNode != nil {
+		result.Status = models.ExecutionStatusWaiting
+		result.Message = fmt.Sprintf("waiting at node %s for %ds", waitNode.Name, result.Wait.Seconds)
+	}
+
 	return result
 }
 
@@ -326,6 +495,183 @@ func (e *WorkflowEngine) executeNode(node *models.WorkflowNode, wfCtx *models.Wo
 	return nodeResult, nodeOutput
 }
 
+// executeLoop 执行循环节点的循环体，每次迭代使用独立的上下文副本，按 Concurrency 并发执行
+// 任意一次迭代失败时循环节点失败，错误信息汇总所有失败的迭代，是否继续执行由节点的 ContinueOnFail 决定
+func (e *WorkflowEngine) executeLoop(node *models.WorkflowNode, loop *models.LoopOutput, nodeResult *models.NodeExecutionResult,
+	nodeMap map[string]*models.WorkflowNode, connections models.Connections, wfCtx *models.WorkflowContext) {
+	bodyMap, bodyConns, err := loopBody(node.ID, nodeMap, connections)
+	if err != nil {
+		nodeResult.Status = "failed"
+		nodeResult.Error = err.Error()
+		return
+	}
+
+	iterations := make([]*models.IterationResult, len(loop.Items))
+	if len(bodyMap) > 0 {
+		concurrency := loop.Concurrency
+		if concurrency <= 0 {
+			concurrency = 1
+		}
+
+		var stopped atomic.Bool
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, concurrency)
+		for i, item := range loop.Items {
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(i int, item interface{}) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+
+				if stopped.Load() {
+					iterations[i] = &models.IterationResult{Index: i, Item: item, Status: "skipped", Message: "skipped after previous iteration failed"}
+					return
+				}
+
+				iterations[i] = e.executeIteration(bodyMap, bodyConns, wfCtx, loop.ItemVar, i, item)
+				if iterations[i].Status == "failed" && loop.ErrorMode == models.LoopErrorModeFailFast {
+					stopped.Store(true)
+				}
+			}(i, item)
+		}
+		wg.Wait()
+	} else {
+		for i, item := range loop.Items {
+			iterations[i] = &models.IterationResult{Index: i, Item: item, Status: "success", Message: "empty loop body"}
+		}
+	}
+
+	nodeResult.Iterations = iterations
+
+	var succeeded int
+	var errs []string
+	for _, it := range iterations {
+		switch it.Status {
+		case "success":
+			succeeded++
+		case "failed":
+			errs = append(errs, fmt.Sprintf("iteration %d (%v): %s", it.Index, it.Item, it.Message))
+		}
+	}
+
+	nodeResult.Message = fmt.Sprintf("%d/%d iterations succeeded", succeeded, len(iterations))
+	if len(errs) > 0 {
+		nodeResult.Status = "failed"
+		nodeResult.Error = strings.Join(errs, "; ")
+	}
+}
+
+// executeIteration 执行一次迭代，事件和 Vars 使用副本，迭代之间以及迭代与主流程之间互不影响
+func (e *WorkflowEngine) executeIteration(bodyMap map[string]*models.WorkflowNode, bodyConns models.Connections, wfCtx *models.WorkflowContext,
+	itemVar string, index int, item interface{}) *models.IterationResult {
+	startTime := time.Now()
+
+	iterCtx := &models.WorkflowContext{
+		Env:      make(map[string]string, len(wfCtx.Env)),
+		Vars:     make(map[string]interface{}, len(wfCtx.Vars)+2),
+		Metadata: make(map[string]string, len(wfCtx.Metadata)+1),
+	}
+
+	if wfCtx.Event != nil {
+		iterCtx.Event = wfCtx.Event.DeepCopy()
+	}
+
+	for k, v := range wfCtx.Env {
+		iterCtx.Env[k] = v
+	}
+
+	for k, v := range wfCtx.Vars {
+		iterCtx.Vars[k] = v
+	}
+	iterCtx.Vars[itemVar] = item
+	iterCtx.Vars[itemVar+"_index"] = index
+
+	for k, v := range wfCtx.Metadata {
+		iterCtx.Metadata[k] = v
+	}
+	iterCtx.Metadata["iteration"] = fmt.Sprintf("%d", index)
+	// 循环体中不能暂停
+	delete(iterCtx.Metadata, "resumable")
+
+	result := e.executeDAG(bodyMap, bodyConns, iterCtx)
+
+	iteration := &models.IterationResult{
+		Index:       index,
+		Item:        item,
+		Status:      models.ExecutionStatusSuccess,
+		Message:     result.Message,
+		DurationMs:  time.Since(startTime).Milliseconds(),
+		NodeResults: result.NodeResults,
+	}
+
+	if result.Status == models.ExecutionStatusFailed {
+		iteration.Status = "failed"
+	}
+
+	return iteration
+}
+
+// loopBody 循环节点输出 0 可达的所有节点组成循环体，循环体中的节点只能从循环体内部连入
+func loopBody(loopNodeID string, nodeMap map[string]*models.WorkflowNode, connections models.Connections) (map[string]*models.WorkflowNode, models.Connections, error) {
+	bodyMap := make(map[string]*models.WorkflowNode)
+
+	var queue []string
+	if nodeConns, ok := connections[loopNodeID]; ok && len(nodeConns.Main) > 0 {
+		for _, target := range nodeConns.Main[0] {
+			queue = append(queue, target.Node)
+		}
+	}
+
+	for len(queue) > 0 {
+		nodeID := queue[0]
+		queue = queue[1:]
+
+		if _, has := bodyMap[nodeID]; has {
+			continue
+		}
+
+		node, exists := nodeMap[nodeID]
+		if !exists || nodeID == loopNodeID {
+			continue
+		}
+		bodyMap[nodeID] = node
+
+		for _, targets := range connections[nodeID].Main {
+			for _, target := range targets {
+				queue = append(queue, target.Node)
+			}
+		}
+	}
+
+	bodyConns := make(models.Connections)
+	for sourceID, nodeConns := range connections {
+		_, inBody := bodyMap[sourceID]
+		for outputIndex, targets := range nodeConns.Main {
+			for _, target := range targets {
+				if _, has := bodyMap[target.Node]; !has {
+					continue
+				}
+
+				if sourceID == loopNodeID && outputIndex == 0 {
+					continue
+				}
+
+				if !inBody {
+					return nil, nil, fmt.Errorf("node %s in loop body is also connected from node %s outside the loop", target.Node, sourceID)
+				}
+			}
+		}
+
+		if inBody {
+			bodyConns[sourceID] = nodeConns
+		}
+	}
+
+	return bodyMap, bodyConns, nil
+}
+
 // shouldFollowBranch 判断是否应该走某个分支
 func (e *WorkflowEngine) shouldFollowBranch(nodeID string, outputIndex int, branchResults map[string]*int) bool {
 	branchIndex, hasBranch := branchResults[nodeID]
@@ -343,12 +689,7 @@ func (e *WorkflowEngine) shouldFollowBranch(nodeID string, outputIndex int, bran
 	return outputIndex == *branchIndex
 }
 
-func (e *WorkflowEngine) saveExecutionRecord(pipeline *models.EventPipeline, wfCtx *models.WorkflowContext, result *models.WorkflowResult, triggerCtx *models.WorkflowTriggerContext, startTime int64, duration int64) {
-	executionID := triggerCtx.RequestID
-	if executionID == "" {
-		executionID = uuid.New().String()
-	}
-
+func (e *WorkflowEngine) saveExecutionRecord(pipeline *models.EventPipeline, wfCtx *models.WorkflowContext, result *models.WorkflowResult, triggerCtx *models.WorkflowTriggerContext, executionID string, startTime int64, duration int64) {
 	execution := &models.EventPipelineExecution{
 		ID:           executionID,
 		PipelineID:   pipeline.ID,
@@ -358,11 +699,14 @@ func (e *WorkflowEngine) saveExecutionRecord(pipeline *models.EventPipeline, wfC
 		ErrorMessage: result.Message,
 		ErrorNode:    result.ErrorNode,
 		CreatedAt:    startTime,
-		FinishedAt:   time.Now().Unix(),
 		DurationMs:   duration,
 		TriggerBy:    triggerCtx.TriggerBy,
 	}
 
+	if result.Status != models.ExecutionStatusWaiting {
+		execution.FinishedAt = time.Now().Unix()
+	}
+
 	if wfCtx.Event != nil {
 		execution.EventID = wfCtx.Event.Id
 	}
@@ -381,3 +725,72 @@ func (e *WorkflowEngine) saveExecutionRecord(pipeline *models.EventPipeline, wfC
 		logger.Errorf("workflow: failed to save execution record: pipeline_id=%d, error=%v", pipeline.ID, err)
 	}
 }
+
+// updateExecutionRecord 恢复执行后追加节点结果并更新状态，耗时包含等待的时间
+func (e *WorkflowEngine) updateExecutionRecord(pipeline *models.EventPipeline, wfCtx *models.WorkflowContext, result *models.WorkflowResult, wait *models.EventPipelineWait) {
+	execution, err := models.GetEventPipelineExecution(e.ctx, wait.ExecutionId)
+	if err != nil {
+		logger.Errorf("workflow: failed to get execution record: execution_id=%s, error=%v", wait.ExecutionId, err)
+		return
+	}
+
+	nodeResults, err := execution.GetNodeResults()
+	if err != nil {
+		logger.Errorf("workflow: failed to get node results: execution_id=%s, error=%v", wait.ExecutionId, err)
+	}
+
+	// 等待节点的状态更新为执行完成
+	for _, nr := range nodeResults {
+		if nr.NodeID == wait.NodeId && nr.Status == models.ExecutionStatusWaiting {
+			nr.Status = "success"
+			nr.FinishedAt = time.Now().Unix()
+			nr.DurationMs = (nr.FinishedAt - nr.StartedAt) * 1000
+		}
+	}
+
+	if err := execution.SetNodeResults(append(nodeResults, result.NodeResults...)); err != nil {
+		logger.Errorf("workflow: failed to set node results: pipeline_id=%d, error=%v", pipeline.ID, err)
+	}
+
+	now := time.Now().Unix()
+	execution.Status = result.Status
+	execution.ErrorMessage = result.Message
+	execution.ErrorNode = result.ErrorNode
+	execution.DurationMs = (now - execution.CreatedAt) * 1000
+	if result.Status != models.ExecutionStatusWaiting {
+		execution.FinishedAt = now
+	}
+
+	if err := execution.SetEnvSnapshot(wfCtx.SanitizedEnv(pipeline.GetSecretKeys())); err != nil {
+		logger.Errorf("workflow: failed to set env snapshot: pipeline_id=%d, error=%v", pipeline.ID, err)
+	}
+
+	if err := models.UpdateEventPipelineExecution(e.ctx, execution); err != nil {
+		logger.Errorf("workflow: failed to update execution record: execution_id=%s, error=%v", wait.ExecutionId, err)
+	}
+}
+
+// saveWait 保存暂停时的上下文，到期后由 alert 实例恢复执行
+func (e *WorkflowEngine) saveWait(pipeline *models.EventPipeline, wfCtx *models.WorkflowContext, result *models.WorkflowResult, triggerCtx *models.WorkflowTriggerContext, executionID string) error {
+	if wfCtx.Event == nil {
+		return fmt.Errorf("event is nil")
+	}
+
+	wait := &models.EventPipelineWait{
+		ExecutionId:   executionID,
+		PipelineId:    pipeline.ID,
+		NodeId:        result.WaitNode,
+		NotifyRuleId:  triggerCtx.NotifyRuleId,
+		PipelineIndex: triggerCtx.PipelineIndex,
+		EventHash:     wfCtx.Event.Hash,
+		EventId:       wfCtx.Event.Id,
+		Recheck:       result.Wait.Recheck,
+		ResumeAt:      time.Now().Unix() + result.Wait.Seconds,
+	}
+
+	if err := wait.SetContext(wfCtx); err != nil {
+		return err
+	}
+
+	return models.EventPipelineWaitAdd(e.ctx, wait)
+}
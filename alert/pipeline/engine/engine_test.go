@@ -0,0 +1,265 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+
+	_ "github.com/ccfos/nightingale/v6/alert/pipeline/processor/logic"
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+var (
+	collectedMu sync.Mutex
+	collected   []string
+)
+
+// collectProcessor 记录每次迭代的元素，元素为 bad 时返回错误
+type collectProcessor struct{}
+
+func (p *collectProcessor) Init(settings interface{}) (models.Processor, error) {
+	return p, nil
+}
+
+func (p *collectProcessor) Process(ctx *ctx.Context, wfCtx *models.WorkflowContext) (*models.WorkflowContext, string, error) {
+	item := fmt.Sprint(wfCtx.Vars["owner"])
+	if item == "bad" {
+		return wfCtx, "", fmt.Errorf("bad owner")
+	}
+
+	wfCtx.Event.TagsMap["owner"] = item
+
+	collectedMu.Lock()
+	collected = append(collected, fmt.Sprintf("%s:%v", item, wfCtx.Vars["owner_index"]))
+	collectedMu.Unlock()
+	return wfCtx, "collected", nil
+}
+
+func init() {
+	models.RegisterProcessor("test.collect", &collectProcessor{})
+}
+
+func foreachPipeline(items, errorMode string) *models.EventPipeline {
+	return &models.EventPipeline{
+		Nodes: []models.WorkflowNode{
+			{ID: "loop", Name: "loop", Type: "logic.foreach", Config: map[string]interface{}{
+				"items": items, "item_var": "owner", "concurrency": 2, "error_mode": errorMode,
+			}},
+			{ID: "body", Name: "body", Type: "test.collect"},
+			{ID: "done", Name: "done", Type: "test.collect"},
+		},
+		Connections: models.Connections{
+			"loop": {Main: [][]models.ConnectionTarget{{{Node: "body"}}, {{Node: "done"}}}},
+		},
+	}
+}
+
+func TestForeach(t *testing.T) {
+	collected = nil
+	event := &models.AlertCurEvent{TagsMap: map[string]string{}}
+	pipeline := foreachPipeline(`["alice","bob","carol"]`, "")
+
+	_, result, err := NewWorkflowEngine(nil).Execute(pipeline, event, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Status != models.ExecutionStatusSuccess {
+		t.Fatalf("unexpected status %s: %s", result.Status, result.Message)
+	}
+
+	sort.Strings(collected)
+	// done 节点在主流程中执行一次，此时 Vars 中没有 owner
+	if fmt.Sprint(collected) != "[<nil>:<nil> alice:0 bob:1 carol:2]" {
+		t.Fatalf("unexpected iterations: %v", collected)
+	}
+
+	if _, has := event.TagsMap["owner"]; !has || event.TagsMap["owner"] != "<nil>" {
+		t.Fatalf("iterations should not modify the main event: %v", event.TagsMap)
+	}
+
+	loop := result.NodeResults[0]
+	if len(loop.Iterations) != 3 || loop.Iterations[1].Item != "bob" || len(loop.Iterations[1].NodeResults) != 1 {
+		t.Fatalf("unexpected iteration results: %+v", loop.Iterations)
+	}
+}
+
+func TestForeachErrors(t *testing.T) {
+	collected = nil
+	pipeline := foreachPipeline("alice,bad,bob", models.LoopErrorModeContinue)
+
+	_, result, _ := NewWorkflowEngine(nil).Execute(pipeline, &models.AlertCurEvent{TagsMap: map[string]string{}}, nil)
+	if result.Status != models.ExecutionStatusFailed || result.ErrorNode != "loop" {
+		t.Fatalf("unexpected status %s: %s", result.Status, result.Message)
+	}
+
+	loop := result.NodeResults[0]
+	if loop.Message != "2/3 iterations succeeded" || loop.Error != "iteration 1 (bad): node body failed: bad owner" {
+		t.Fatalf("unexpected loop result: %s %s", loop.Message, loop.Error)
+	}
+
+	// 循环节点失败后继续执行后续节点
+	collected = nil
+	pipeline.Nodes[0].ContinueOnFail = true
+	_, result, _ = NewWorkflowEngine(nil).Execute(pipeline, &models.AlertCurEvent{TagsMap: map[string]string{}}, nil)
+	if result.Status != models.ExecutionStatusSuccess || len(collected) != 3 {
+		t.Fatalf("unexpected result %s, collected %v", result.Status, collected)
+	}
+}
+
+func TestLoopBodyConnectedFromOutside(t *testing.T) {
+	nodeMap := map[string]*models.WorkflowNode{"a": {ID: "a"}, "loop": {ID: "loop"}, "body": {ID: "body"}}
+	conns := models.Connections{
+		"a":    {Main: [][]models.ConnectionTarget{{{Node: "loop"}, {Node: "body"}}}},
+		"loop": {Main: [][]models.ConnectionTarget{{{Node: "body"}}}},
+	}
+
+	if _, _, err := loopBody("loop", nodeMap, conns); err == nil {
+		t.Fatal("expected error for body node connected from outside the loop")
+	}
+}
+
+func delayPipeline() *models.EventPipeline {
+	return &models.EventPipeline{
+		ID: 1,
+		Nodes: []models.WorkflowNode{
+			{ID: "first", Name: "first", Type: "test.collect"},
+			{ID: "delay", Name: "delay", Type: "logic.delay", Config: map[string]interface{}{"duration": 60}},
+			{ID: "after", Name: "after", Type: "test.collect"},
+		},
+		Connections: models.Connections{
+			"first": {Main: [][]models.ConnectionTarget{{{Node: "delay"}}}},
+			"delay": {Main: [][]models.ConnectionTarget{{{Node: "after"}}}},
+		},
+	}
+}
+
+func TestDelayNotResumable(t *testing.T) {
+	// 不能保存等待时等待节点失败，不会悄悄跳过等待
+	collected = nil
+	event, result, err := NewWorkflowEngine(nil).Execute(delayPipeline(), &models.AlertCurEvent{TagsMap: map[string]string{}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if event == nil || result.Status != models.ExecutionStatusFailed || result.ErrorNode != "delay" || len(collected) != 1 {
+		t.Fatalf("delay should fail when not resumable: %s %s, collected %v", result.Status, result.Message, collected)
+	}
+
+	// 试运行时跳过等待
+	collected = nil
+	event, result, err = NewWorkflowEngine(nil).Execute(delayPipeline(), &models.AlertCurEvent{TagsMap: map[string]string{}}, &models.WorkflowTriggerContext{TryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if event == nil || result.Status != models.ExecutionStatusSuccess || len(collected) != 2 {
+		t.Fatalf("delay should be skipped in tryrun: %s %s, collected %v", result.Status, result.Message, collected)
+	}
+}
+
+func TestDelayParallelBranch(t *testing.T) {
+	pipeline := delayPipeline()
+	pipeline.Nodes = append(pipeline.Nodes, models.WorkflowNode{ID: "side", Name: "side", Type: "test.collect"})
+	pipeline.Connections["first"] = models.NodeConnections{Main: [][]models.ConnectionTarget{{{Node: "delay"}, {Node: "side"}}}}
+
+	collected = nil
+	triggerCtx := &models.WorkflowTriggerContext{Resumable: true}
+	event, result, err := NewWorkflowEngine(nil).Execute(pipeline, &models.AlertCurEvent{TagsMap: map[string]string{}}, triggerCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 不依赖等待节点的分支在暂停前执行完
+	if event == nil || result.Status != models.ExecutionStatusWaiting || result.WaitNode != "delay" || len(collected) != 2 {
+		t.Fatalf("unexpected result %s %s, collected %v", result.Status, result.Message, collected)
+	}
+
+	subMap, _ := resumeGraph("delay", buildNodeMap(pipeline.Nodes), pipeline.Connections)
+	if len(subMap) != 1 || subMap["after"] == nil {
+		t.Fatalf("unexpected resume graph: %v", subMap)
+	}
+}
+
+func TestDelayWaitAndResume(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:pipeline_wait?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AutoMigrate(&models.EventPipelineExecution{}, &models.EventPipelineWait{}, &models.AlertCurEvent{}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := ctx.NewContext(context.Background(), db, true)
+	e := NewWorkflowEngine(c)
+	pipeline := delayPipeline()
+	triggerCtx := &models.WorkflowTriggerContext{Mode: models.TriggerModeEvent, TriggerBy: "notify_rule", Resumable: true, NotifyRuleId: 2, PipelineIndex: 1}
+
+	execute := func(hash string) *models.EventPipelineWait {
+		collected = nil
+		triggerCtx.RequestID = hash
+		event, result, err := e.Execute(pipeline, &models.AlertCurEvent{Hash: hash, TagsMap: map[string]string{}}, triggerCtx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if event != nil || result.Status != models.ExecutionStatusWaiting || result.WaitNode != "delay" || len(collected) != 1 {
+			t.Fatalf("unexpected result %s %s, collected %v", result.Status, result.Message, collected)
+		}
+
+		wait, err := models.EventPipelineWaitGetByExecutionId(c, hash)
+		if err != nil || wait == nil {
+			t.Fatalf("wait not saved: %v", err)
+		}
+
+		if wait.NotifyRuleId != 2 || wait.PipelineIndex != 1 || !wait.Recheck || wait.ResumeAt < wait.CreateAt+60 {
+			t.Fatalf("unexpected wait: %+v", wait)
+		}
+		return wait
+	}
+
+	// 等待期间事件已恢复，不再执行后续节点
+	wait := execute("recovered")
+	collected = nil
+	event, result, err := e.Resume(pipeline, wait)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if event != nil || len(collected) != 0 {
+		t.Fatalf("event should be dropped after recovery: %s, collected %v", result.Message, collected)
+	}
+
+	// 事件仍在告警，继续执行等待节点之后的节点
+	wait = execute("firing")
+	if err := db.Create(&models.AlertCurEvent{Hash: "firing"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	collected = nil
+	event, result, err = e.Resume(pipeline, wait)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if event == nil || result.Status != models.ExecutionStatusSuccess || len(collected) != 1 {
+		t.Fatalf("unexpected resume result %s %s, collected %v", result.Status, result.Message, collected)
+	}
+
+	execution, err := models.GetEventPipelineExecution(c, "firing")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodeResults, _ := execution.GetNodeResults()
+	if execution.Status != models.ExecutionStatusSuccess || execution.FinishedAt == 0 || len(nodeResults) != 3 || nodeResults[1].Status != "success" {
+		t.Fatalf("unexpected execution record: %+v", execution)
+	}
+}
@@ -3,10 +3,13 @@ package pipeline
 import (
 	_ "github.com/ccfos/nightingale/v6/alert/pipeline/processor/aisummary"
 	_ "github.com/ccfos/nightingale/v6/alert/pipeline/processor/callback"
+	_ "github.com/ccfos/nightingale/v6/alert/pipeline/processor/dsquery"
 	_ "github.com/ccfos/nightingale/v6/alert/pipeline/processor/eventdrop"
+	_ "github.com/ccfos/nightingale/v6/alert/pipeline/processor/eventlimit"
 	_ "github.com/ccfos/nightingale/v6/alert/pipeline/processor/eventupdate"
 	_ "github.com/ccfos/nightingale/v6/alert/pipeline/processor/logic"
 	_ "github.com/ccfos/nightingale/v6/alert/pipeline/processor/relabel"
+	_ "github.com/ccfos/nightingale/v6/alert/pipeline/processor/script"
 )
 
 func Init() {
@@ -0,0 +1,390 @@
+package dsquery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/alert/pipeline/processor/common"
+	"github.com/ccfos/nightingale/v6/dscache"
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+	"github.com/ccfos/nightingale/v6/pkg/tplx"
+	"github.com/ccfos/nightingale/v6/prom"
+
+	promModel "github.com/prometheus/common/model"
+)
+
+// 结果格式常量
+const (
+	ResultModeValues = "values" // 时序数据，每条曲线取最新的值（默认）
+	ResultModeRows   = "rows"   // 原始行，SQL 表格或日志
+)
+
+// 结果保存位置常量
+const (
+	TargetAnnotation = "annotation" // 事件的附加信息
+	TargetEnv        = "env"        // 工作流的 Env，供后续节点使用
+	TargetVars       = "vars"       // 工作流的 Vars，保留原始结构
+)
+
+const (
+	defaultQueryRange   = 300
+	defaultQueryMaxRows = 20
+	defaultQueryTimeout = 10000
+)
+
+var promClients *prom.PromClientMap
+
+// RegisterPromClients 为了避免循环引用，通过外部注入的方式注册 prometheus 客户端
+func RegisterPromClients(pc *prom.PromClientMap) {
+	promClients = pc
+}
+
+// DatasourceQueryConfig 数据源查询处理器配置
+// 使用事件的标签渲染查询语句，查询任意已配置的数据源，将结果写入事件的附加信息或工作流的 Env / Vars
+type DatasourceQueryConfig struct {
+	DatasourceId int64  `json:"datasource_id"`
+	Cate         string `json:"cate"` // 数据源类型：prometheus、mysql、pgsql、ck、doris、elasticsearch、victorialogs 等
+
+	// prometheus 的查询语句，支持 Go 模板语法，例如：rate(http_errors_total{service={{ quote $labels.service }}}[5m])
+	PromQl string `json:"prom_ql,omitempty"`
+	// 其他数据源的查询配置，与告警规则中的查询配置相同，所有字符串都支持 Go 模板语法
+	// 例如：{"sql": "select team from cmdb_host where ident = {{ sqlQuote $labels.ident }}"}
+	// 标签值需要使用 sqlQuote、quote、luceneEscape 转义，SQL 类数据源只允许 SELECT 语句
+	Query map[string]interface{} `json:"query,omitempty"`
+
+	// 查询最近多长时间的数据，unit: s，查询配置中已有 from/to/start/end 时不覆盖
+	Range int64 `json:"range,omitempty"`
+	// 结果格式：values 或 rows
+	ResultMode string `json:"result_mode,omitempty"`
+	// 最多保留的结果数量
+	MaxRows int `json:"max_rows,omitempty"`
+
+	// 结果保存位置：annotation、env 或 vars
+	Target    string `json:"target"`
+	TargetKey string `json:"target_key"`
+
+	Timeout int `json:"timeout,omitempty"` // unit: ms
+}
+
+func init() {
+	models.RegisterProcessor("datasource_query", &DatasourceQueryConfig{})
+}
+
+func (c *DatasourceQueryConfig) Init(settings interface{}) (models.Processor, error) {
+	result, err := common.InitProcessor[*DatasourceQueryConfig](settings)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.DatasourceId <= 0 {
+		return nil, fmt.Errorf("datasource_id is required")
+	}
+
+	if result.Cate == "" {
+		return nil, fmt.Errorf("cate is required")
+	}
+
+	if result.Cate == models.PROMETHEUS {
+		if strings.TrimSpace(result.PromQl) == "" {
+			return nil, fmt.Errorf("prom_ql is required")
+		}
+	} else if len(result.Query) == 0 {
+		return nil, fmt.Errorf("query is required")
+	}
+
+	// 保存时先检查模板本身，渲染后执行前还会再检查一次
+	if sql, ok := querySQL(result.Cate, result.Query); ok {
+		if err := checkReadOnlySQL(result.Cate, tplActionRe.ReplaceAllString(sql, "x")); err != nil {
+			return nil, err
+		}
+	}
+
+	switch result.Target {
+	case TargetAnnotation, TargetEnv, TargetVars:
+	default:
+		return nil, fmt.Errorf("invalid target: %s", result.Target)
+	}
+
+	if result.TargetKey == "" {
+		return nil, fmt.Errorf("target_key is required")
+	}
+
+	if result.ResultMode == "" {
+		result.ResultMode = ResultModeValues
+	}
+
+	if result.ResultMode != ResultModeValues && result.ResultMode != ResultModeRows {
+		return nil, fmt.Errorf("invalid result_mode: %s", result.ResultMode)
+	}
+
+	if result.Range <= 0 {
+		result.Range = defaultQueryRange
+	}
+
+	if result.MaxRows <= 0 {
+		result.MaxRows = defaultQueryMaxRows
+	}
+
+	if result.Timeout <= 0 {
+		result.Timeout = defaultQueryTimeout
+	}
+
+	return result, nil
+}
+
+func (c *DatasourceQueryConfig) Process(ctx *ctx.Context, wfCtx *models.WorkflowContext) (*models.WorkflowContext, string, error) {
+	qctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.Timeout)*time.Millisecond)
+	defer cancel()
+
+	var result []interface{}
+	var err error
+	if c.Cate == models.PROMETHEUS {
+		result, err = c.queryProm(qctx, wfCtx)
+	} else {
+		result, err = c.queryDatasource(qctx, wfCtx)
+	}
+
+	if err != nil {
+		return wfCtx, "", fmt.Errorf("datasource query processor: %v", err)
+	}
+
+	if len(result) > c.MaxRows {
+		result = result[:c.MaxRows]
+	}
+
+	if err := c.save(wfCtx, result); err != nil {
+		return wfCtx, "", fmt.Errorf("datasource query processor: failed to save result: %v", err)
+	}
+
+	return wfCtx, fmt.Sprintf("%d results saved to %s.%s", len(result), c.Target, c.TargetKey), nil
+}
+
+func (c *DatasourceQueryConfig) queryProm(qctx context.Context, wfCtx *models.WorkflowContext) ([]interface{}, error) {
+	if promClients == nil || promClients.IsNil(c.DatasourceId) {
+		return nil, fmt.Errorf("reader client of datasource:%d is nil", c.DatasourceId)
+	}
+
+	promql, err := render(c.PromQl, wfCtx, quoteFuncs(c.Cate))
+	if err != nil {
+		return nil, fmt.Errorf("failed to render prom_ql: %v", err)
+	}
+
+	value, _, err := promClients.GetCli(c.DatasourceId).Query(qctx, promql, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var result []interface{}
+	switch v := value.(type) {
+	case promModel.Vector:
+		for _, s := range v {
+			result = append(result, sampleResult(s.Metric, float64(s.Value)))
+		}
+	case promModel.Matrix:
+		for _, s := range v {
+			if len(s.Values) > 0 {
+				result = append(result, sampleResult(s.Metric, float64(s.Values[len(s.Values)-1].Value)))
+			}
+		}
+	case *promModel.Scalar:
+		result = append(result, sampleResult(nil, float64(v.Value)))
+	}
+
+	return result, nil
+}
+
+func (c *DatasourceQueryConfig) queryDatasource(qctx context.Context, wfCtx *models.WorkflowContext) ([]interface{}, error) {
+	plug, exists := dscache.DsCache.Get(c.Cate, c.DatasourceId)
+	if !exists {
+		return nil, fmt.Errorf("datasource %s:%d not exists", c.Cate, c.DatasourceId)
+	}
+
+	rendered, err := renderValue(c.Query, wfCtx, quoteFuncs(c.Cate))
+	if err != nil {
+		return nil, fmt.Errorf("failed to render query: %v", err)
+	}
+
+	query := rendered.(map[string]interface{})
+	if sql, ok := querySQL(c.Cate, query); ok {
+		if err := checkReadOnlySQL(c.Cate, sql); err != nil {
+			return nil, fmt.Errorf("rendered sql is not allowed: %v", err)
+		}
+	}
+
+	// SQL 类数据源使用 from/to 渲染 $__timeFilter 等宏，ES、VictoriaLogs 使用 start/end
+	now := time.Now().Unix()
+	for _, key := range []string{"from", "start"} {
+		if _, has := query[key]; !has {
+			query[key] = now - c.Range
+		}
+	}
+	for _, key := range []string{"to", "end"} {
+		if _, has := query[key]; !has {
+			query[key] = now
+		}
+	}
+
+	if c.ResultMode == ResultModeRows {
+		rows, _, err := plug.QueryLog(qctx, query)
+		return rows, err
+	}
+
+	series, err := plug.QueryData(qctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []interface{}
+	for _, s := range series {
+		if len(s.Values) == 0 || len(s.Values[len(s.Values)-1]) < 2 {
+			continue
+		}
+		result = append(result, sampleResult(s.Metric, s.Values[len(s.Values)-1][1]))
+	}
+
+	return result, nil
+}
+
+// save 写入 annotation 和 env 时只有一个值时保存为字符串，否则保存为 JSON；写入 vars 时保留原始结构
+func (c *DatasourceQueryConfig) save(wfCtx *models.WorkflowContext, result []interface{}) error {
+	if c.Target == TargetVars {
+		if wfCtx.Vars == nil {
+			wfCtx.Vars = make(map[string]interface{})
+		}
+		wfCtx.Vars[c.TargetKey] = result
+		return nil
+	}
+
+	value, err := resultString(result)
+	if err != nil {
+		return err
+	}
+
+	if c.Target == TargetEnv {
+		if wfCtx.Env == nil {
+			wfCtx.Env = make(map[string]string)
+		}
+		wfCtx.Env[c.TargetKey] = value
+		return nil
+	}
+
+	event := wfCtx.Event
+	if event == nil {
+		return fmt.Errorf("event is nil")
+	}
+
+	if event.AnnotationsJSON == nil {
+		event.AnnotationsJSON = make(map[string]string)
+	}
+	event.AnnotationsJSON[c.TargetKey] = value
+
+	b, err := json.Marshal(event.AnnotationsJSON)
+	if err != nil {
+		return err
+	}
+	event.Annotations = string(b)
+	return nil
+}
+
+func sampleResult(metric promModel.Metric, value float64) map[string]interface{} {
+	labels := make(map[string]string, len(metric))
+	for k, v := range metric {
+		labels[string(k)] = string(v)
+	}
+
+	return map[string]interface{}{
+		"labels": labels,
+		"value":  value,
+	}
+}
+
+// resultString 只有一个值时直接返回该值，例如单行单列的 SQL 结果或单条曲线
+func resultString(result []interface{}) (string, error) {
+	if len(result) == 1 {
+		switch v := result[0].(type) {
+		case map[string]interface{}:
+			if value, has := v["value"]; has && len(v) == 2 {
+				return fmt.Sprint(value), nil
+			}
+			if len(v) == 1 {
+				for _, value := range v {
+					return fmt.Sprint(value), nil
+				}
+			}
+		case string:
+			return v, nil
+		}
+	}
+
+	if len(result) == 0 {
+		return "", nil
+	}
+
+	b, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// renderValue 渲染查询配置中的所有字符串，返回新的对象，不修改配置本身
+func renderValue(v interface{}, wfCtx *models.WorkflowContext, funcs template.FuncMap) (interface{}, error) {
+	switch val := v.(type) {
+	case string:
+		return render(val, wfCtx, funcs)
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			rendered, err := renderValue(item, wfCtx, funcs)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = rendered
+		}
+		return m, nil
+	case []interface{}:
+		lst := make([]interface{}, 0, len(val))
+		for _, item := range val {
+			rendered, err := renderValue(item, wfCtx, funcs)
+			if err != nil {
+				return nil, err
+			}
+			lst = append(lst, rendered)
+		}
+		return lst, nil
+	default:
+		return v, nil
+	}
+}
+
+func render(text string, wfCtx *models.WorkflowContext, funcs template.FuncMap) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	var defs = []string{
+		"{{ $event := .Event }}",
+		"{{ $labels := .Event.TagsMap }}",
+		"{{ $value := .Event.TriggerValue }}",
+		"{{ $env := .Env }}",
+		"{{ $vars := .Vars }}",
+	}
+
+	tpl, err := template.New("datasource_query").Funcs(tplx.TemplateFuncMap).Funcs(funcs).Parse(strings.Join(defs, "") + text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err = tpl.Execute(&buf, wfCtx); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,174 @@
+package dsquery
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/models"
+	promsdk "github.com/ccfos/nightingale/v6/pkg/prom"
+	"github.com/ccfos/nightingale/v6/prom"
+
+	"github.com/prometheus/common/model"
+)
+
+type fakeAPI struct {
+	promsdk.API
+	query string
+}
+
+func (f *fakeAPI) Query(ctx context.Context, query string, ts time.Time) (model.Value, promsdk.Warnings, error) {
+	f.query = query
+	return model.Vector{{Metric: model.Metric{"service": "api"}, Value: 0.5}}, nil, nil
+}
+
+func TestDatasourceQueryProm(t *testing.T) {
+	api := &fakeAPI{}
+	RegisterPromClients(&prom.PromClientMap{ReaderClients: map[int64]promsdk.API{1: api}})
+
+	p, err := (&DatasourceQueryConfig{}).Init(map[string]interface{}{
+		"datasource_id": 1,
+		"cate":          models.PROMETHEUS,
+		"prom_ql":       `rate(http_errors_total{service={{ quote $labels.service }}}[5m])`,
+		"target":        TargetAnnotation,
+		"target_key":    "error_rate",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wfCtx := &models.WorkflowContext{Event: &models.AlertCurEvent{TagsMap: map[string]string{"service": "api"}}}
+	if _, _, err := p.Process(nil, wfCtx); err != nil {
+		t.Fatal(err)
+	}
+
+	if api.query != `rate(http_errors_total{service="api"}[5m])` {
+		t.Fatalf("unexpected query: %s", api.query)
+	}
+
+	if wfCtx.Event.AnnotationsJSON["error_rate"] != "0.5" || wfCtx.Event.Annotations != `{"error_rate":"0.5"}` {
+		t.Fatalf("unexpected annotations: %v", wfCtx.Event.AnnotationsJSON)
+	}
+}
+
+func TestRenderValue(t *testing.T) {
+	query := map[string]interface{}{
+		"sql":  "select team from cmdb_host where ident = {{ sqlQuote $labels.ident }}",
+		"keys": map[string]interface{}{"labelKey": "team"},
+	}
+
+	wfCtx := &models.WorkflowContext{Event: &models.AlertCurEvent{TagsMap: map[string]string{"ident": "host-1"}}}
+	rendered, err := renderValue(query, wfCtx, quoteFuncs(models.MYSQL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rendered.(map[string]interface{})["sql"] != "select team from cmdb_host where ident = 'host-1'" {
+		t.Fatalf("unexpected sql: %v", rendered)
+	}
+
+	// 配置本身不能被修改，同一个处理器会处理多个事件
+	if query["sql"] != "select team from cmdb_host where ident = {{ sqlQuote $labels.ident }}" {
+		t.Fatalf("query config should not be modified: %v", query)
+	}
+}
+
+func TestResultString(t *testing.T) {
+	cases := []struct {
+		result []interface{}
+		want   string
+	}{
+		{nil, ""},
+		{[]interface{}{map[string]interface{}{"team": "sre"}}, "sre"},
+		{[]interface{}{map[string]interface{}{"team": "sre"}, map[string]interface{}{"team": "dba"}}, `[{"team":"sre"},{"team":"dba"}]`},
+	}
+
+	for _, c := range cases {
+		got, err := resultString(c.result)
+		if err != nil || got != c.want {
+			t.Errorf("resultString(%v) = %q, %v, want %q", c.result, got, err, c.want)
+		}
+	}
+}
+
+func TestRenderQuote(t *testing.T) {
+	wfCtx := &models.WorkflowContext{Event: &models.AlertCurEvent{TagsMap: map[string]string{"ident": `x\' or 1=1 -- "a"`}}}
+	cases := []struct {
+		cate string
+		text string
+		want string
+	}{
+		{models.MYSQL, "ident = {{ sqlQuote $labels.ident }}", `ident = 'x\\'' or 1=1 -- "a"'`},
+		{models.POSTGRESQL, "ident = {{ sqlQuote $labels.ident }}", `ident = 'x\'' or 1=1 -- "a"'`},
+		{models.PROMETHEUS, "up{ident={{ quote $labels.ident }}}", `up{ident="x\\' or 1=1 -- \"a\""}`},
+		{models.ELASTICSEARCH, "ident:{{ luceneEscape $labels.ident }}", `ident:x\\'\ or\ 1\=1\ \-\-\ \"a\"`},
+	}
+
+	for _, tc := range cases {
+		got, err := render(tc.text, wfCtx, quoteFuncs(tc.cate))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.cate, got, tc.want)
+		}
+	}
+
+	// 转义后的 SQL 仍然是单条 SELECT 语句
+	for _, cate := range []string{models.MYSQL, models.POSTGRESQL} {
+		sql, _ := render("select team from cmdb_host where ident = {{ sqlQuote $labels.ident }}; delete from cmdb_host", wfCtx, quoteFuncs(cate))
+		if err := checkReadOnlySQL(cate, sql); err == nil {
+			t.Errorf("%s: expected error for %s", cate, sql)
+		}
+		sql, _ = render("select team from cmdb_host where ident = {{ sqlQuote $labels.ident }}", wfCtx, quoteFuncs(cate))
+		if err := checkReadOnlySQL(cate, sql); err != nil {
+			t.Errorf("%s: unexpected error for %s: %v", cate, sql, err)
+		}
+	}
+}
+
+func TestCheckReadOnlySQL(t *testing.T) {
+	cases := []struct {
+		cate string
+		sql  string
+		ok   bool
+	}{
+		{models.MYSQL, "select count(*) from t where name = 'delete me';", true},
+		{models.MYSQL, "SELECT update_at FROM t -- drop table t", true},
+		{models.MYSQL, "with a as (select 1) select * from a", true},
+		{models.CLICKHOUSE, "select name from system.tables where database = 'default'", true},
+		{models.MYSQL, "delete from t", false},
+		{models.MYSQL, "select 1; drop table t", false},
+		{models.MYSQL, "select * from t into outfile '/tmp/t'", false},
+		{models.MYSQL, "select * from t for update", false},
+		{models.MYSQL, "select 'a\\'; drop table t; -- '", true},
+		{models.POSTGRESQL, "select 'a\\'; drop table t; -- '", false},
+		{models.POSTGRESQL, "with d as (delete from t returning *) select * from d", false},
+		{models.POSTGRESQL, "select * from t where a = 'unterminated", false},
+		{models.MYSQL, "select 1 --x; drop table t", false},
+		{models.CLICKHOUSE, "select 1 # ; drop table t", false},
+		{models.MYSQL, "/* comment */ select 1", true},
+		{models.MYSQL, "select 1 /*! ; drop table t */", false},
+		{models.MYSQL, "", false},
+	}
+
+	for _, tc := range cases {
+		err := checkReadOnlySQL(tc.cate, tc.sql)
+		if (err == nil) != tc.ok {
+			t.Errorf("%s %q: got error %v, want ok %v", tc.cate, tc.sql, err, tc.ok)
+		}
+	}
+}
+
+func TestDatasourceQueryInitRejectsWrite(t *testing.T) {
+	_, err := (&DatasourceQueryConfig{}).Init(map[string]interface{}{
+		"datasource_id": 1,
+		"cate":          models.MYSQL,
+		"query":         map[string]interface{}{"sql": "delete from cmdb_host where ident = {{ sqlQuote $labels.ident }}"},
+		"target":        TargetAnnotation,
+		"target_key":    "team",
+	})
+	if err == nil {
+		t.Fatal("expected error for delete statement")
+	}
+}
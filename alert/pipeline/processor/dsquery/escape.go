@@ -0,0 +1,176 @@
+package dsquery
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/ccfos/nightingale/v6/models"
+)
+
+// sqlKeys 各 SQL 类数据源查询配置中保存 SQL 的字段
+var sqlKeys = map[string]string{
+	models.MYSQL:      "sql",
+	models.POSTGRESQL: "sql",
+	models.DORIS:      "sql",
+	models.CLICKHOUSE: "sql",
+	models.TDENGINE:   "query",
+}
+
+// sqlWriteWords SELECT 语句中也可能出现的写操作关键字，如 PostgreSQL 的 WITH ... DELETE、MySQL 的 SELECT ... INTO OUTFILE
+var sqlWriteWords = map[string]struct{}{
+	"insert": {}, "update": {}, "delete": {}, "upsert": {}, "drop": {}, "alter": {}, "create": {}, "truncate": {},
+	"rename": {}, "grant": {}, "revoke": {}, "into": {}, "outfile": {}, "dumpfile": {}, "lock": {},
+}
+
+var (
+	sqlWordRe   = regexp.MustCompile(`[a-z_][a-z0-9_]*`)
+	tplActionRe = regexp.MustCompile(`\{\{.*?\}\}`)
+)
+
+// quoteFuncs 查询语句中引用标签等变量时使用的转义函数，避免标签值中的引号改变查询语句
+//
+//	sqlQuote: 转义为 SQL 字符串，包含两边的单引号，例如 where ident = {{ sqlQuote $labels.ident }}
+//	quote: 转义为双引号字符串，适用于 PromQL、LogsQL，例如 {service={{ quote $labels.service }}}
+//	luceneEscape: 转义 elasticsearch、opensearch 查询语法中的特殊字符，例如 host:{{ luceneEscape $labels.host }}
+func quoteFuncs(cate string) template.FuncMap {
+	return template.FuncMap{
+		"sqlQuote": func(v interface{}) string {
+			return sqlQuote(cate, fmt.Sprint(v))
+		},
+		"quote": func(v interface{}) string {
+			return strconv.Quote(fmt.Sprint(v))
+		},
+		"luceneEscape": func(v interface{}) string {
+			return luceneEscape(fmt.Sprint(v))
+		},
+	}
+}
+
+// backslashEscapes PostgreSQL 默认（standard_conforming_strings）不把反斜杠当作转义字符，其他数据库都会
+func backslashEscapes(cate string) bool {
+	return cate != models.POSTGRESQL
+}
+
+func sqlQuote(cate, s string) string {
+	if backslashEscapes(cate) {
+		s = strings.ReplaceAll(s, `\`, `\\`)
+	}
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+var luceneReplacer = func() *strings.Replacer {
+	var pairs []string
+	for _, ch := range []string{`\`, "+", "-", "=", "&", "|", ">", "<", "!", "(", ")", "{", "}", "[", "]", "^", `"`, "~", "*", "?", ":", "/", " "} {
+		pairs = append(pairs, ch, `\`+ch)
+	}
+	return strings.NewReplacer(pairs...)
+}()
+
+func luceneEscape(s string) string {
+	return luceneReplacer.Replace(s)
+}
+
+// checkReadOnlySQL 处理器会用每个事件的标签渲染后执行查询，只允许单条 SELECT 语句
+// 这里只能拦截明显的写操作，数据源配置的账号仍然应该是只读的
+func checkReadOnlySQL(cate, sql string) error {
+	stmt, err := stripSQLLiterals(cate, sql)
+	if err != nil {
+		return err
+	}
+
+	stmt = strings.TrimRight(strings.TrimSpace(strings.ToLower(stmt)), "; \t\r\n")
+	if strings.Contains(stmt, ";") {
+		return fmt.Errorf("multiple statements are not allowed")
+	}
+
+	words := sqlWordRe.FindAllString(stmt, -1)
+	if len(words) == 0 || (words[0] != "select" && words[0] != "with") {
+		return fmt.Errorf("only select statements are allowed")
+	}
+
+	for _, w := range words {
+		if _, has := sqlWriteWords[w]; has {
+			return fmt.Errorf("only select statements are allowed, found: %s", w)
+		}
+	}
+
+	return nil
+}
+
+// stripSQLLiterals 去掉字符串、带引号的标识符和注释，只保留语句本身，字符串中出现 delete 之类的词不算写操作
+func stripSQLLiterals(cate, sql string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(sql); i++ {
+		ch := sql[i]
+		switch {
+		case ch == '\'' || ch == '"' || ch == '`':
+			end := -1
+			for j := i + 1; j < len(sql); j++ {
+				if sql[j] == '\\' && backslashEscapes(cate) {
+					j++
+					continue
+				}
+				if sql[j] == ch {
+					// 连续两个引号表示引号本身
+					if j+1 < len(sql) && sql[j+1] == ch {
+						j++
+						continue
+					}
+					end = j
+					break
+				}
+			}
+			if end < 0 {
+				return "", fmt.Errorf("unterminated quoted string")
+			}
+			b.WriteByte(' ')
+			i = end
+		case isLineComment(cate, sql[i:]):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				return b.String(), nil
+			}
+			b.WriteByte(' ')
+			i += end
+		case ch == '/' && strings.HasPrefix(sql[i:], "/*!") && (cate == models.MYSQL || cate == models.DORIS):
+			// MySQL 会执行 /*! ... */ 中的内容，当作语句的一部分检查
+			b.WriteByte(' ')
+			i += 2
+		case ch == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				return "", fmt.Errorf("unterminated comment")
+			}
+			b.WriteByte(' ')
+			i += end + 3
+		default:
+			b.WriteByte(ch)
+		}
+	}
+
+	return b.String(), nil
+}
+
+// isLineComment 拿不准是否是注释时不跳过，多检查一些内容只会误拦截，不会放过写操作
+// MySQL 的 -- 后面必须是空白字符，# 注释只有 MySQL 和 Doris 支持
+func isLineComment(cate, s string) bool {
+	if strings.HasPrefix(s, "--") {
+		return len(s) == 2 || strings.ContainsAny(s[2:3], " \t\r\n")
+	}
+
+	return strings.HasPrefix(s, "#") && (cate == models.MYSQL || cate == models.DORIS)
+}
+
+// querySQL SQL 类数据源返回查询配置中的 SQL，其他数据源返回 false
+func querySQL(cate string, query map[string]interface{}) (string, bool) {
+	key, has := sqlKeys[cate]
+	if !has {
+		return "", false
+	}
+
+	sql, _ := query[key].(string)
+	return sql, true
+}
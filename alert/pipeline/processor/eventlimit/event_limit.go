@@ -0,0 +1,176 @@
+package eventlimit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/alert/pipeline/processor/common"
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+	"github.com/ccfos/nightingale/v6/pkg/tplx"
+	"github.com/ccfos/nightingale/v6/storage"
+
+	"github.com/toolkits/pkg/logger"
+	"github.com/toolkits/pkg/str"
+)
+
+const (
+	defaultLimitKey             = "{{ $event.RuleId }}_{{ $event.TargetIdent }}"
+	defaultLimitWindow          = 1800
+	defaultSuppressedAnnotation = "suppressed_count"
+)
+
+var redisCli storage.Redis
+
+// RegisterRedis 为了避免循环引用，通过外部注入的方式注册 redis，开启 shared 的处理器使用 redis 在多个 alert 实例间共享状态
+func RegisterRedis(r storage.Redis) {
+	redisCli = r
+}
+
+// EventLimitConfig 事件去重限流处理器配置
+// 按 Key 对事件分组，每个分组在 Window 秒内最多放行 Limit 个事件，超出的事件被丢弃，
+// 被丢弃的事件数量记录在下一个放行事件的附加信息中。例如：
+//   - 同一个机器同一条规则 30 分钟内最多通知 1 次：key 使用默认值，window 为 1800，limit 为 1
+//   - 每个业务组每分钟最多 10 个事件：key 为 {{ $event.GroupId }}，window 为 60，limit 为 10
+type EventLimitConfig struct {
+	// 分组的 key，支持 Go 模板语法，默认为规则 id 和机器标识
+	Key string `json:"key,omitempty"`
+	// 时间窗口，unit: s
+	Window int64 `json:"window,omitempty"`
+	// 时间窗口内最多放行的事件数量
+	Limit int64 `json:"limit,omitempty"`
+	// 是否对恢复事件也做限制，默认恢复事件总是放行
+	IncludeRecovered bool `json:"include_recovered,omitempty"`
+	// 是否通过 redis 在多个 alert 实例间共享计数，默认每个实例单独计数
+	Shared bool `json:"shared,omitempty"`
+	// 记录被丢弃事件数量的附加信息 key
+	SuppressedAnnotation string `json:"suppressed_annotation,omitempty"`
+
+	// 配置相同的处理器共享计数，配置不同的处理器互不影响
+	namespace string
+}
+
+func init() {
+	models.RegisterProcessor("event_limit", &EventLimitConfig{})
+}
+
+func (c *EventLimitConfig) Init(settings interface{}) (models.Processor, error) {
+	result, err := common.InitProcessor[*EventLimitConfig](settings)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(result.Key) == "" {
+		result.Key = defaultLimitKey
+	}
+
+	if result.Window <= 0 {
+		result.Window = defaultLimitWindow
+	}
+
+	if result.Limit <= 0 {
+		result.Limit = 1
+	}
+
+	if result.SuppressedAnnotation == "" {
+		result.SuppressedAnnotation = defaultSuppressedAnnotation
+	}
+
+	result.namespace = str.MD5(fmt.Sprintf("%s_%d_%d", result.Key, result.Window, result.Limit))
+	return result, nil
+}
+
+func (c *EventLimitConfig) Process(ctx *ctx.Context, wfCtx *models.WorkflowContext) (*models.WorkflowContext, string, error) {
+	event := wfCtx.Event
+	if event == nil {
+		return wfCtx, "", fmt.Errorf("event limit processor: event is nil")
+	}
+
+	if event.IsRecovered && !c.IncludeRecovered {
+		return wfCtx, "recovered event is not limited", nil
+	}
+
+	key, err := c.renderKey(wfCtx)
+	if err != nil {
+		return wfCtx, "", fmt.Errorf("event limit processor: failed to render key: %v", err)
+	}
+
+	now := time.Now().Unix()
+	fullKey := c.namespace + ":" + key
+
+	var allowed bool
+	var count int64
+	if wfCtx.IsTryRun() {
+		// 试运行只查看当前计数，不影响线上事件的限流
+		allowed, count = c.peek(ctx, fullKey, now)
+	} else if c.Shared && redisCli != nil {
+		allowed, count, err = redisAllow(ctx, redisCli, fullKey, c.Window, c.Limit)
+		if err != nil {
+			// redis 不可用时退化为单实例计数，避免事件全部被丢弃
+			logger.Warningf("event limit processor: redis error, fallback to memory: %v", err)
+			allowed, count = limiter.allow(fullKey, c.Window, c.Limit, now)
+		}
+	} else {
+		allowed, count = limiter.allow(fullKey, c.Window, c.Limit, now)
+	}
+
+	if !allowed {
+		logger.Infof("event limit processor: event %s suppressed by key %s, %d suppressed", event.Hash, key, count)
+		wfCtx.Event = nil
+		return wfCtx, fmt.Sprintf("event suppressed by key %s, %d suppressed in window", key, count), nil
+	}
+
+	if count == 0 {
+		return wfCtx, fmt.Sprintf("event forwarded by key %s", key), nil
+	}
+
+	if event.AnnotationsJSON == nil {
+		event.AnnotationsJSON = make(map[string]string)
+	}
+	event.AnnotationsJSON[c.SuppressedAnnotation] = fmt.Sprint(count)
+
+	b, err := json.Marshal(event.AnnotationsJSON)
+	if err != nil {
+		return wfCtx, "", fmt.Errorf("event limit processor: failed to marshal annotations: %v", err)
+	}
+	event.Annotations = string(b)
+
+	return wfCtx, fmt.Sprintf("event forwarded by key %s, %d events suppressed before", key, count), nil
+}
+
+func (c *EventLimitConfig) peek(ctx *ctx.Context, fullKey string, now int64) (bool, int64) {
+	if c.Shared && redisCli != nil {
+		allowed, count, err := redisPeek(ctx, redisCli, fullKey, c.Limit)
+		if err == nil {
+			return allowed, count
+		}
+		logger.Warningf("event limit processor: redis error, fallback to memory: %v", err)
+	}
+
+	return limiter.peek(fullKey, c.Window, c.Limit, now)
+}
+
+func (c *EventLimitConfig) renderKey(wfCtx *models.WorkflowContext) (string, error) {
+	var defs = []string{
+		"{{ $event := .Event }}",
+		"{{ $labels := .Event.TagsMap }}",
+		"{{ $value := .Event.TriggerValue }}",
+		"{{ $env := .Env }}",
+	}
+
+	tpl, err := template.New("event_limit").Funcs(tplx.TemplateFuncMap).Parse(strings.Join(defs, "") + c.Key)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err = tpl.Execute(&buf, wfCtx); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(buf.String()), nil
+}
@@ -0,0 +1,152 @@
+package eventlimit
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestMemoryLimiter(t *testing.T) {
+	l := &memoryLimiter{buckets: make(map[string]*bucket)}
+
+	if ok, n := l.allow("k", 60, 2, 100); !ok || n != 0 {
+		t.Fatalf("first event should be allowed")
+	}
+	if ok, _ := l.allow("k", 60, 2, 110); !ok {
+		t.Fatalf("second event should be allowed")
+	}
+	if ok, n := l.allow("k", 60, 2, 120); ok || n != 1 {
+		t.Fatalf("third event should be suppressed, got %v %d", ok, n)
+	}
+	if ok, n := l.allow("k", 60, 2, 130); ok || n != 2 {
+		t.Fatalf("fourth event should be suppressed, got %v %d", ok, n)
+	}
+
+	// 新窗口的第一个事件带上之前被丢弃的数量
+	if ok, n := l.allow("k", 60, 2, 161); !ok || n != 2 {
+		t.Fatalf("event in new window should be allowed with suppressed count, got %v %d", ok, n)
+	}
+	if ok, n := l.allow("k", 60, 2, 162); !ok || n != 0 {
+		t.Fatalf("suppressed count should be reset, got %v %d", ok, n)
+	}
+}
+
+func newEvent(ident string) *models.AlertCurEvent {
+	return &models.AlertCurEvent{RuleId: 1, TargetIdent: ident, TagsMap: map[string]string{}}
+}
+
+func TestEventLimitProcess(t *testing.T) {
+	p, err := (&EventLimitConfig{}).Init(map[string]interface{}{"window": 3600, "limit": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wfCtx, _, _ := p.Process(nil, &models.WorkflowContext{Event: newEvent("host-1")})
+	if wfCtx.Event == nil {
+		t.Fatal("first event should be forwarded")
+	}
+
+	for i := 0; i < 3; i++ {
+		wfCtx, _, _ = p.Process(nil, &models.WorkflowContext{Event: newEvent("host-1")})
+		if wfCtx.Event != nil {
+			t.Fatal("repeated event should be suppressed")
+		}
+	}
+
+	// 不同的 key 互不影响
+	wfCtx, _, _ = p.Process(nil, &models.WorkflowContext{Event: newEvent("host-2")})
+	if wfCtx.Event == nil {
+		t.Fatal("event of another ident should be forwarded")
+	}
+
+	// 试运行不修改计数
+	tryRun := func(ident string) *models.WorkflowContext {
+		wfCtx, _, _ := p.Process(nil, &models.WorkflowContext{Event: newEvent(ident), Metadata: map[string]string{"tryrun": "true"}})
+		return wfCtx
+	}
+	if tryRun("host-1").Event != nil {
+		t.Fatal("tryrun should report the event as suppressed")
+	}
+	for i := 0; i < 2; i++ {
+		if tryRun("host-3").Event == nil {
+			t.Fatal("tryrun should not consume the limit")
+		}
+	}
+	wfCtx, _, _ = p.Process(nil, &models.WorkflowContext{Event: newEvent("host-3")})
+	if wfCtx.Event == nil {
+		t.Fatal("event after tryrun should be forwarded")
+	}
+
+	// 恢复事件默认不受限制
+	recovered := newEvent("host-1")
+	recovered.IsRecovered = true
+	wfCtx, _, _ = p.Process(nil, &models.WorkflowContext{Event: recovered})
+	if wfCtx.Event == nil {
+		t.Fatal("recovered event should be forwarded")
+	}
+}
+
+func TestRedisAllow(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	c := ctx.NewContext(context.Background(), nil, true)
+
+	if ok, n, err := redisAllow(c, rdb, "k", 60, 1); err != nil || !ok || n != 0 {
+		t.Fatalf("first event should be allowed: %v %d %v", ok, n, err)
+	}
+
+	for i := int64(1); i <= 2; i++ {
+		if ok, n, err := redisAllow(c, rdb, "k", 60, 1); err != nil || ok || n != i {
+			t.Fatalf("event should be suppressed: %v %d %v", ok, n, err)
+		}
+	}
+
+	// 两个 key 带相同的 hash tag，redis cluster 中落在同一个 slot
+	keys := redisKeys("k")
+	if !s.Exists(keys[0]) || !s.Exists(keys[1]) {
+		t.Fatalf("unexpected keys: %v", s.Keys())
+	}
+
+	// 试运行不修改计数
+	if ok, n, err := redisPeek(c, rdb, "k", 1); err != nil || ok || n != 3 {
+		t.Fatalf("peek: %v %d %v", ok, n, err)
+	}
+	if got, _ := s.Get(keys[1]); got != "2" {
+		t.Fatalf("suppressed changed by peek: %s", got)
+	}
+
+	s.FastForward(61e9)
+	if ok, n, err := redisPeek(c, rdb, "k", 1); err != nil || !ok || n != 2 {
+		t.Fatalf("peek in new window: %v %d %v", ok, n, err)
+	}
+	if ok, n, err := redisAllow(c, rdb, "k", 60, 1); err != nil || !ok || n != 2 {
+		t.Fatalf("event in new window should be allowed with suppressed count: %v %d %v", ok, n, err)
+	}
+}
+
+func TestRedisKeysHashTag(t *testing.T) {
+	// redis cluster 只用第一个 { 和之后第一个 } 之间的内容计算 slot
+	hashTag := func(key string) string {
+		start := strings.Index(key, "{")
+		end := strings.Index(key[start+1:], "}")
+		return key[start+1 : start+1+end]
+	}
+
+	for _, key := range []string{"ns:svc=api", "ns:svc={a}", "ns:}x{", "ns:{}"} {
+		keys := redisKeys(key)
+		if hashTag(keys[0]) != hashTag(keys[1]) || len(hashTag(keys[0])) != 40 {
+			t.Errorf("%s: keys %v should share the sha1 hash tag", key, keys)
+		}
+	}
+}
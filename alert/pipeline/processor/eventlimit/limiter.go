@@ -0,0 +1,159 @@
+package eventlimit
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+	"github.com/ccfos/nightingale/v6/storage"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisKeyPrefix = "n9e_event_limit:"
+	// 被丢弃事件数量的保留时间，超过后不再附加到下一个放行的事件上，unit: s
+	suppressedTTL = 7 * 86400
+	// 内存计数的清理周期，unit: s
+	sweepInterval = 60
+)
+
+// bucket 固定时间窗口的计数
+type bucket struct {
+	windowStart int64
+	count       int64
+	suppressed  int64
+	lastSeen    int64
+}
+
+type memoryLimiter struct {
+	sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep int64
+}
+
+var limiter = &memoryLimiter{buckets: make(map[string]*bucket)}
+
+// allow 放行时返回之前被丢弃的事件数量并清零，丢弃时返回累计被丢弃的事件数量
+func (l *memoryLimiter) allow(key string, window, limit, now int64) (bool, int64) {
+	l.Lock()
+	defer l.Unlock()
+
+	l.sweep(now)
+
+	b, has := l.buckets[key]
+	if !has {
+		b = &bucket{windowStart: now}
+		l.buckets[key] = b
+	}
+
+	if now >= b.windowStart+window {
+		b.windowStart = now
+		b.count = 0
+	}
+	b.lastSeen = now
+
+	if b.count >= limit {
+		b.suppressed++
+		return false, b.suppressed
+	}
+
+	b.count++
+	suppressed := b.suppressed
+	b.suppressed = 0
+	return true, suppressed
+}
+
+// peek 返回 allow 的结果但不修改计数，用于试运行
+func (l *memoryLimiter) peek(key string, window, limit, now int64) (bool, int64) {
+	l.Lock()
+	defer l.Unlock()
+
+	b, has := l.buckets[key]
+	if !has {
+		return true, 0
+	}
+
+	count := b.count
+	if now >= b.windowStart+window {
+		count = 0
+	}
+
+	if count >= limit {
+		return false, b.suppressed + 1
+	}
+	return true, b.suppressed
+}
+
+// sweep 清理长时间没有事件的计数，窗口过期且没有被丢弃的事件时即可清理
+func (l *memoryLimiter) sweep(now int64) {
+	if now-l.lastSweep < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if now-b.lastSeen > suppressedTTL || (b.suppressed == 0 && now-b.windowStart > suppressedTTL/7) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// redisAllowScript KEYS[1] 为窗口计数，KEYS[2] 为被丢弃的事件数量；ARGV 为窗口、上限、被丢弃数量的保留时间
+var redisAllowScript = redis.NewScript(`
+local c = redis.call('INCR', KEYS[1])
+if c == 1 then
+	redis.call('EXPIRE', KEYS[1], ARGV[1])
+end
+if c > tonumber(ARGV[2]) then
+	local s = redis.call('INCR', KEYS[2])
+	redis.call('EXPIRE', KEYS[2], ARGV[3])
+	return {0, s}
+end
+local s = tonumber(redis.call('GET', KEYS[2]) or '0')
+redis.call('DEL', KEYS[2])
+return {1, s}
+`)
+
+// redisKeys 两个 key 使用相同的 hash tag，redis cluster 中落在同一个 slot，才能在同一个脚本中操作
+// 标签值中可能有 { 或 }，会改变 hash tag 的范围，所以使用渲染结果的 sha1 作为 hash tag
+func redisKeys(key string) []string {
+	sum := sha1.Sum([]byte(key))
+	tag := "{" + hex.EncodeToString(sum[:]) + "}"
+	return []string{redisKeyPrefix + tag + ":count", redisKeyPrefix + tag + ":suppressed"}
+}
+
+func redisAllow(c *ctx.Context, r storage.Redis, key string, window, limit int64) (bool, int64, error) {
+	keys := redisKeys(key)
+	ret, err := redisAllowScript.Run(c.Ctx, r, keys, window, limit, suppressedTTL).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+
+	if len(ret) != 2 {
+		return false, 0, fmt.Errorf("unexpected result: %v", ret)
+	}
+
+	return ret[0] == 1, ret[1], nil
+}
+
+// redisPeek 返回 redisAllow 的结果但不修改计数，用于试运行
+func redisPeek(c *ctx.Context, r storage.Redis, key string, limit int64) (bool, int64, error) {
+	keys := redisKeys(key)
+
+	var count, suppressed int64
+	for i, dest := range []*int64{&count, &suppressed} {
+		n, err := r.Get(c.Ctx, keys[i]).Int64()
+		if err != nil && err != redis.Nil {
+			return false, 0, err
+		}
+		*dest = n
+	}
+
+	if count >= limit {
+		return false, suppressed + 1, nil
+	}
+	return true, suppressed, nil
+}
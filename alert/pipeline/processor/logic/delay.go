@@ -0,0 +1,62 @@
+package logic
+
+import (
+	"fmt"
+
+	"github.com/ccfos/nightingale/v6/alert/pipeline/processor/common"
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+)
+
+const maxDelaySeconds = 86400
+
+// DelayConfig Delay 等待处理器配置
+// 事件在该节点暂停 Duration 秒，到期后检查事件是否仍在告警，仍在告警时才继续执行后续节点，
+// 用于在打电话、自愈等代价较高的操作前过滤短暂的抖动。等待保存在数据库中，实例重启后不会丢失。
+// 只有 center 中通知规则的事件处理流程支持暂停，试运行时跳过等待，
+// 其他场景（edge、告警规则的事件处理流程、API 触发）无法保存等待，节点执行失败
+type DelayConfig struct {
+	// 等待时长，unit: s
+	Duration int64 `json:"duration"`
+	// 到期后是否检查事件仍在告警，默认检查
+	Recheck *bool `json:"recheck,omitempty"`
+}
+
+func init() {
+	models.RegisterProcessor("logic.delay", &DelayConfig{})
+}
+
+func (c *DelayConfig) Init(settings interface{}) (models.Processor, error) {
+	result, err := common.InitProcessor[*DelayConfig](settings)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Duration <= 0 || result.Duration > maxDelaySeconds {
+		return nil, fmt.Errorf("duration should be between 1 and %d seconds", maxDelaySeconds)
+	}
+
+	if result.Recheck == nil {
+		recheck := true
+		result.Recheck = &recheck
+	}
+
+	return result, nil
+}
+
+// Process 实现 Processor 接口，只有试运行单个处理器时会调用，暂停只能由工作流引擎执行
+func (c *DelayConfig) Process(ctx *ctx.Context, wfCtx *models.WorkflowContext) (*models.WorkflowContext, string, error) {
+	return wfCtx, fmt.Sprintf("delay %ds skipped", c.Duration), nil
+}
+
+// ProcessWithBranch 实现 BranchProcessor 接口
+func (c *DelayConfig) ProcessWithBranch(ctx *ctx.Context, wfCtx *models.WorkflowContext) (*models.NodeOutput, error) {
+	return &models.NodeOutput{
+		WfCtx:   wfCtx,
+		Message: fmt.Sprintf("wait for %ds", c.Duration),
+		Wait: &models.WaitOutput{
+			Seconds: c.Duration,
+			Recheck: *c.Recheck,
+		},
+	}, nil
+}
@@ -0,0 +1,243 @@
+package logic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"github.com/ccfos/nightingale/v6/alert/pipeline/processor/common"
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+	"github.com/ccfos/nightingale/v6/pkg/tplx"
+)
+
+// 列表来源常量
+const (
+	ForeachSourceExpression = "expression" // 表达式模式（默认）
+	ForeachSourceVars       = "vars"       // 直接读取 Vars 中的列表
+	ForeachSourceLabel      = "label"      // 按分隔符拆分标签值
+)
+
+const (
+	defaultForeachMaxItems = 100
+	maxForeachMaxItems     = 1000
+	maxForeachConcurrency  = 20
+)
+
+// ForeachConfig Foreach 循环处理器配置
+// 输出 0 连接循环体，对列表中的每个元素执行一次；所有迭代结束后走输出 1
+type ForeachConfig struct {
+	// 列表来源：expression、vars 或 label
+	Source string `json:"source,omitempty"`
+
+	// 表达式模式配置
+	// 列表表达式（支持 Go 模板语法），渲染结果为 JSON 数组，或按 Separator 分隔的字符串
+	// 例如：{{ $env.owners }}、{{ jsonMarshal (index .Vars "owners") }}
+	Items string `json:"items,omitempty"`
+
+	// vars / label 模式配置，Vars 或标签的 key
+	Key string `json:"key,omitempty"`
+
+	// 字符串的分隔符，默认为逗号
+	Separator string `json:"separator,omitempty"`
+
+	// 元素在 Vars 中的变量名，默认为 item，迭代序号保存在 Vars["<item_var>_index"]
+	ItemVar string `json:"item_var,omitempty"`
+
+	// 同时执行的迭代数量，默认为 1，即依次执行
+	Concurrency int `json:"concurrency,omitempty"`
+
+	// 最多迭代的元素数量，超出的元素被忽略，默认为 100
+	MaxItems int `json:"max_items,omitempty"`
+
+	// 错误处理方式：continue（默认）或 fail_fast
+	ErrorMode string `json:"error_mode,omitempty"`
+}
+
+func init() {
+	models.RegisterProcessor("logic.foreach", &ForeachConfig{})
+}
+
+func (c *ForeachConfig) Init(settings interface{}) (models.Processor, error) {
+	result, err := common.InitProcessor[*ForeachConfig](settings)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Source == "" {
+		result.Source = ForeachSourceExpression
+	}
+
+	switch result.Source {
+	case ForeachSourceExpression:
+		if strings.TrimSpace(result.Items) == "" {
+			return nil, fmt.Errorf("items is required")
+		}
+	case ForeachSourceVars, ForeachSourceLabel:
+		if result.Key == "" {
+			return nil, fmt.Errorf("key is required")
+		}
+	default:
+		return nil, fmt.Errorf("invalid source: %s", result.Source)
+	}
+
+	if result.Separator == "" {
+		result.Separator = ","
+	}
+
+	if result.ItemVar == "" {
+		result.ItemVar = "item"
+	}
+
+	if result.Concurrency <= 0 {
+		result.Concurrency = 1
+	}
+
+	if result.Concurrency > maxForeachConcurrency {
+		result.Concurrency = maxForeachConcurrency
+	}
+
+	if result.MaxItems <= 0 {
+		result.MaxItems = defaultForeachMaxItems
+	}
+
+	if result.MaxItems > maxForeachMaxItems {
+		result.MaxItems = maxForeachMaxItems
+	}
+
+	switch result.ErrorMode {
+	case "":
+		result.ErrorMode = models.LoopErrorModeContinue
+	case models.LoopErrorModeContinue, models.LoopErrorModeFailFast:
+	default:
+		return nil, fmt.Errorf("invalid error_mode: %s", result.ErrorMode)
+	}
+
+	return result, nil
+}
+
+// Process 实现 Processor 接口，循环体只能由工作流引擎执行，这里只解析列表
+func (c *ForeachConfig) Process(ctx *ctx.Context, wfCtx *models.WorkflowContext) (*models.WorkflowContext, string, error) {
+	items, err := c.resolveItems(wfCtx)
+	if err != nil {
+		return wfCtx, "", fmt.Errorf("foreach processor: failed to resolve items: %v", err)
+	}
+
+	return wfCtx, fmt.Sprintf("%d items resolved", len(items)), nil
+}
+
+// ProcessWithBranch 实现 BranchProcessor 接口
+func (c *ForeachConfig) ProcessWithBranch(ctx *ctx.Context, wfCtx *models.WorkflowContext) (*models.NodeOutput, error) {
+	items, err := c.resolveItems(wfCtx)
+	if err != nil {
+		return nil, fmt.Errorf("foreach processor: failed to resolve items: %v", err)
+	}
+
+	// 循环体执行完成后走输出 1（done 分支）
+	doneIndex := 1
+	return &models.NodeOutput{
+		WfCtx:       wfCtx,
+		Message:     fmt.Sprintf("%d items to iterate", len(items)),
+		BranchIndex: &doneIndex,
+		Loop: &models.LoopOutput{
+			Items:       items,
+			ItemVar:     c.ItemVar,
+			Concurrency: c.Concurrency,
+			ErrorMode:   c.ErrorMode,
+		},
+	}, nil
+}
+
+// resolveItems 解析要迭代的列表，超过 MaxItems 的部分被截断
+func (c *ForeachConfig) resolveItems(wfCtx *models.WorkflowContext) ([]interface{}, error) {
+	var items []interface{}
+	var err error
+
+	switch c.Source {
+	case ForeachSourceVars:
+		items, err = toItems(wfCtx.Vars[c.Key], c.Separator)
+	case ForeachSourceLabel:
+		if wfCtx.Event != nil {
+			items = splitItems(wfCtx.Event.TagsMap[c.Key], c.Separator)
+		}
+	default:
+		items, err = c.renderItems(wfCtx)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(items) > c.MaxItems {
+		items = items[:c.MaxItems]
+	}
+
+	return items, nil
+}
+
+func (c *ForeachConfig) renderItems(wfCtx *models.WorkflowContext) ([]interface{}, error) {
+	var defs = []string{
+		"{{ $event := .Event }}",
+		"{{ $labels := .Event.TagsMap }}",
+		"{{ $value := .Event.TriggerValue }}",
+		"{{ $env := .Env }}",
+	}
+
+	text := strings.Join(append(defs, c.Items), "")
+
+	tpl, err := template.New("foreach_items").Funcs(tplx.TemplateFuncMap).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err = tpl.Execute(&buf, wfCtx); err != nil {
+		return nil, err
+	}
+
+	return toItems(buf.String(), c.Separator)
+}
+
+// toItems 将列表类型的值转换为元素列表，字符串按 JSON 数组或分隔符解析
+func toItems(v interface{}, separator string) ([]interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	if s, ok := v.(string); ok {
+		s = strings.TrimSpace(s)
+		if strings.HasPrefix(s, "[") {
+			var items []interface{}
+			if err := json.Unmarshal([]byte(s), &items); err != nil {
+				return nil, fmt.Errorf("failed to parse items as json array: %v", err)
+			}
+			return items, nil
+		}
+		return splitItems(s, separator), nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("items is not a list: %T", v)
+	}
+
+	items := make([]interface{}, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		items = append(items, rv.Index(i).Interface())
+	}
+	return items, nil
+}
+
+func splitItems(s, separator string) []interface{} {
+	var items []interface{}
+	for _, item := range strings.Split(s, separator) {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,341 @@
+package script
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/alert/pipeline/processor/common"
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/vm"
+	"github.com/toolkits/pkg/logger"
+)
+
+const (
+	defaultScriptTimeout = 100  // unit: ms
+	maxScriptTimeout     = 5000 // unit: ms
+	maxScriptLength      = 10000
+	maxScriptSteps       = 1000000
+
+	// stepFunc 编译时插入到 map、filter 等函数的每次迭代中，不在文档中列出
+	stepFunc = "__step"
+)
+
+// ScriptConfig 脚本处理器配置
+// 使用 expr 表达式语言（https://expr-lang.org）处理事件，脚本只能访问下面列出的变量和函数，不能访问文件、网络和当前时间，
+// 相同的事件总是得到相同的结果。脚本对事件的修改在执行成功后才生效，执行出错或超时时事件保持不变
+//
+// 可读取的变量：
+//
+//	labels、annotations、env、vars、severity，以及 event 中的 rule_id、rule_name、cate、group_name、
+//	target_ident、trigger_value、trigger_time、first_trigger_time、is_recovered、datasource_id
+//
+// 修改事件的函数，返回值均为 true：
+//
+//	set_label(key, value)、del_label(key)、set_annotation(key, value)、del_annotation(key)、
+//	set_severity(n)、set_env(key, value)、drop()
+//
+// set_severity 的 n 只能是 1、2、3，其他值会使脚本执行失败，事件保持不变
+// map、filter 等函数的迭代总次数不能超过 1000000 次，超过时脚本执行失败
+//
+// 多个操作可以放在数组中依次执行，例如：
+//
+//	let team = labels.service in ["mysql", "redis"] ? "dba" : "sre";
+//	[
+//	  set_label("team", team),
+//	  labels.env == "test" ? set_severity(3) : true,
+//	  trigger_value < 1 ? drop() : true
+//	]
+type ScriptConfig struct {
+	Script  string `json:"script"`
+	Timeout int    `json:"timeout,omitempty"` // unit: ms
+
+	program *vm.Program
+}
+
+func init() {
+	models.RegisterProcessor("script", &ScriptConfig{})
+}
+
+func (c *ScriptConfig) Init(settings interface{}) (models.Processor, error) {
+	result, err := common.InitProcessor[*ScriptConfig](settings)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Script = strings.TrimSpace(result.Script)
+	if result.Script == "" {
+		return nil, fmt.Errorf("script is blank")
+	}
+
+	if len(result.Script) > maxScriptLength {
+		return nil, fmt.Errorf("script is too long, max length: %d", maxScriptLength)
+	}
+
+	if result.Timeout <= 0 {
+		result.Timeout = defaultScriptTimeout
+	}
+
+	if result.Timeout > maxScriptTimeout {
+		result.Timeout = maxScriptTimeout
+	}
+
+	// 编译时使用空事件的变量做类型检查，执行时使用实际事件的变量
+	proto := newScriptState(&models.WorkflowContext{Event: &models.AlertCurEvent{}})
+	result.program, err = expr.Compile(result.Script, expr.Env(proto.env()), expr.DisableBuiltin("now"), expr.Patch(stepPatcher{}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile script: %v", err)
+	}
+
+	return result, nil
+}
+
+func (c *ScriptConfig) Process(ctx *ctx.Context, wfCtx *models.WorkflowContext) (*models.WorkflowContext, string, error) {
+	if wfCtx.Event == nil {
+		return wfCtx, "", fmt.Errorf("script processor: event is nil")
+	}
+
+	runCtx, cancel := context.WithTimeout(context.Background(), time.Duration(c.Timeout)*time.Millisecond)
+	defer cancel()
+
+	state := newScriptState(wfCtx)
+	state.ctx = runCtx
+	env := state.env()
+
+	// expr 的执行无法从外部中断，脚本中只有 map、filter 等函数会循环，每次迭代都会检查步数和超时，
+	// 超时后执行脚本的 goroutine 在下一次迭代时退出，脚本的内存占用由 vm.MemoryBudget 限制
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+
+		_, err := expr.Run(c.program, env)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return wfCtx, "", fmt.Errorf("script processor: failed to run script: %v", err)
+		}
+	case <-runCtx.Done():
+		return wfCtx, "", fmt.Errorf("script processor: script timeout after %dms", c.Timeout)
+	}
+
+	msg := state.apply(wfCtx)
+	logger.Debugf("processor script event:%d result: %s", wfCtx.Event.Id, msg)
+
+	if state.dropped {
+		wfCtx.Event = nil
+		return wfCtx, "drop event success", nil
+	}
+
+	return wfCtx, msg, nil
+}
+
+// scriptState 脚本执行期间的事件副本，脚本执行成功后由 apply 写回
+type scriptState struct {
+	ctx   context.Context
+	event *models.AlertCurEvent
+	steps int
+
+	labels      map[string]string
+	annotations map[string]string
+	envs        map[string]string
+	vars        map[string]interface{}
+	severity    int
+	dropped     bool
+
+	labelsChanged      bool
+	annotationsChanged bool
+	envChanged         bool
+}
+
+func newScriptState(wfCtx *models.WorkflowContext) *scriptState {
+	event := wfCtx.Event
+	s := &scriptState{
+		event:       event,
+		labels:      make(map[string]string, len(event.TagsMap)),
+		annotations: make(map[string]string, len(event.AnnotationsJSON)),
+		envs:        make(map[string]string, len(wfCtx.Env)),
+		vars:        make(map[string]interface{}, len(wfCtx.Vars)),
+		severity:    event.Severity,
+	}
+
+	for k, v := range event.TagsMap {
+		s.labels[k] = v
+	}
+
+	for k, v := range event.AnnotationsJSON {
+		s.annotations[k] = v
+	}
+
+	for k, v := range wfCtx.Env {
+		s.envs[k] = v
+	}
+
+	for k, v := range wfCtx.Vars {
+		s.vars[k] = v
+	}
+
+	return s
+}
+
+func (s *scriptState) env() map[string]interface{} {
+	e := s.event
+	return map[string]interface{}{
+		"labels":      s.labels,
+		"annotations": s.annotations,
+		"env":         s.envs,
+		"vars":        s.vars,
+		"severity":    s.severity,
+
+		"rule_id":            e.RuleId,
+		"rule_name":          e.RuleName,
+		"cate":               e.Cate,
+		"group_name":         e.GroupName,
+		"target_ident":       e.TargetIdent,
+		"trigger_value":      e.TriggerValue,
+		"trigger_time":       e.TriggerTime,
+		"first_trigger_time": e.FirstTriggerTime,
+		"is_recovered":       e.IsRecovered,
+		"datasource_id":      e.DatasourceId,
+
+		"set_label": func(key, value string) bool {
+			s.labels[key] = value
+			s.labelsChanged = true
+			return true
+		},
+		"del_label": func(key string) bool {
+			delete(s.labels, key)
+			s.labelsChanged = true
+			return true
+		},
+		"set_annotation": func(key, value string) bool {
+			s.annotations[key] = value
+			s.annotationsChanged = true
+			return true
+		},
+		"del_annotation": func(key string) bool {
+			delete(s.annotations, key)
+			s.annotationsChanged = true
+			return true
+		},
+		"set_severity": func(severity int) (bool, error) {
+			if severity < 1 || severity > 3 {
+				return false, fmt.Errorf("invalid severity %d, should be 1, 2 or 3", severity)
+			}
+			s.severity = severity
+			return true, nil
+		},
+		"set_env": func(key, value string) bool {
+			s.envs[key] = value
+			s.envChanged = true
+			return true
+		},
+		"drop": func() bool {
+			s.dropped = true
+			return true
+		},
+
+		stepFunc: func(v interface{}) (interface{}, error) {
+			s.steps++
+			if s.steps > maxScriptSteps {
+				return nil, fmt.Errorf("script exceeded %d steps", maxScriptSteps)
+			}
+			if s.ctx != nil && s.ctx.Err() != nil {
+				return nil, s.ctx.Err()
+			}
+			return v, nil
+		},
+	}
+}
+
+// stepPatcher 把 map、filter 等函数的迭代体 x 改写为 __step(x)，限制脚本的执行步数
+type stepPatcher struct{}
+
+func (stepPatcher) Visit(node *ast.Node) {
+	closure, ok := (*node).(*ast.ClosureNode)
+	if !ok {
+		return
+	}
+
+	closure.Node = &ast.CallNode{
+		Callee:    &ast.IdentifierNode{Value: stepFunc},
+		Arguments: []ast.Node{closure.Node},
+	}
+}
+
+// apply 将脚本的修改写回事件和工作流上下文，返回修改的摘要
+func (s *scriptState) apply(wfCtx *models.WorkflowContext) string {
+	event := wfCtx.Event
+	var changes []string
+
+	if s.labelsChanged {
+		event.TagsJSON = orderedTags(event.TagsJSON, s.labels)
+		event.TagsMap = s.labels
+		event.Tags = strings.Join(event.TagsJSON, ",,")
+		changes = append(changes, "labels")
+	}
+
+	if s.annotationsChanged {
+		event.AnnotationsJSON = s.annotations
+		if b, err := json.Marshal(event.AnnotationsJSON); err == nil {
+			event.Annotations = string(b)
+		}
+		changes = append(changes, "annotations")
+	}
+
+	if s.severity != event.Severity {
+		changes = append(changes, fmt.Sprintf("severity %d -> %d", event.Severity, s.severity))
+		event.Severity = s.severity
+	}
+
+	if s.envChanged {
+		wfCtx.Env = s.envs
+		changes = append(changes, "env")
+	}
+
+	if len(changes) == 0 {
+		return "script executed, event not changed"
+	}
+
+	return "script executed, changed: " + strings.Join(changes, ", ")
+}
+
+// orderedTags 保持原有标签的顺序，新增的标签按 key 排序追加在后面
+func orderedTags(original []string, labels map[string]string) []string {
+	tags := make([]string, 0, len(labels))
+	seen := make(map[string]struct{}, len(labels))
+	for _, tag := range original {
+		key := strings.SplitN(tag, "=", 2)[0]
+		if value, has := labels[key]; has {
+			tags = append(tags, key+"="+value)
+			seen[key] = struct{}{}
+		}
+	}
+
+	var keys []string
+	for key := range labels {
+		if _, has := seen[key]; !has {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		tags = append(tags, key+"="+labels[key])
+	}
+
+	return tags
+}
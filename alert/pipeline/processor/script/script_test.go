@@ -0,0 +1,180 @@
+package script
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/models"
+)
+
+func newEvent() *models.AlertCurEvent {
+	return &models.AlertCurEvent{
+		Severity:        2,
+		TriggerValue:    "0.5",
+		TagsJSON:        []string{"service=mysql", "env=test"},
+		TagsMap:         map[string]string{"service": "mysql", "env": "test"},
+		AnnotationsJSON: map[string]string{},
+	}
+}
+
+func TestScriptProcess(t *testing.T) {
+	p, err := (&ScriptConfig{}).Init(map[string]interface{}{
+		"script": `let team = labels.service in ["mysql", "redis"] ? "dba" : "sre";
+[
+  labels.env == "test" ? set_severity(3) : true,
+  set_label("team", team),
+  del_label("env"),
+  set_annotation("owner", team + "@example.com"),
+  set_env("team", team)
+]`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wfCtx := &models.WorkflowContext{Event: newEvent(), Env: map[string]string{}}
+	wfCtx, msg, err := p.Process(nil, wfCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := wfCtx.Event
+	if event.Tags != "service=mysql,,team=dba" || event.TagsMap["team"] != "dba" {
+		t.Fatalf("unexpected tags: %s", event.Tags)
+	}
+
+	if event.Annotations != `{"owner":"dba@example.com"}` {
+		t.Fatalf("unexpected annotations: %s", event.Annotations)
+	}
+
+	if event.Severity != 3 || wfCtx.Env["team"] != "dba" {
+		t.Fatalf("unexpected severity %d or env %v", event.Severity, wfCtx.Env)
+	}
+
+	if msg != "script executed, changed: labels, annotations, severity 2 -> 3, env" {
+		t.Fatalf("unexpected message: %s", msg)
+	}
+}
+
+func TestScriptDrop(t *testing.T) {
+	p, err := (&ScriptConfig{}).Init(map[string]interface{}{"script": `float(trigger_value) < 1 ? drop() : true`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wfCtx, _, err := p.Process(nil, &models.WorkflowContext{Event: newEvent()})
+	if err != nil || wfCtx.Event != nil {
+		t.Fatalf("event should be dropped, err: %v", err)
+	}
+}
+
+func TestScriptErrors(t *testing.T) {
+	if _, err := (&ScriptConfig{}).Init(map[string]interface{}{"script": `set_label("a")`}); err == nil {
+		t.Fatal("expected compile error")
+	}
+
+	if _, err := (&ScriptConfig{}).Init(map[string]interface{}{"script": `now()`}); err == nil {
+		t.Fatal("now() should be disabled")
+	}
+
+	// 执行出错时事件保持不变
+	p, err := (&ScriptConfig{}).Init(map[string]interface{}{"script": `[set_label("team", "sre"), int(labels.service) > 0]`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := newEvent()
+	if _, _, err := p.Process(nil, &models.WorkflowContext{Event: event}); err == nil {
+		t.Fatal("expected runtime error")
+	}
+
+	if _, has := event.TagsMap["team"]; has {
+		t.Fatal("event should not be changed when script fails")
+	}
+
+	// 级别只能是 1、2、3
+	p, err = (&ScriptConfig{}).Init(map[string]interface{}{"script": `set_severity(0)`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event = newEvent()
+	severity := event.Severity
+	if _, _, err := p.Process(nil, &models.WorkflowContext{Event: event}); err == nil || !strings.Contains(err.Error(), "invalid severity") {
+		t.Fatalf("expected invalid severity error, got %v", err)
+	}
+
+	if event.Severity != severity {
+		t.Fatal("severity should not be changed when script fails")
+	}
+
+	// 超时
+	p, err = (&ScriptConfig{}).Init(map[string]interface{}{
+		"script":  `len(filter(1..1000000, {# % 7 == 0 && string(#) != ""})) > 0`,
+		"timeout": 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := p.Process(nil, &models.WorkflowContext{Event: newEvent()}); err == nil {
+		t.Fatal("expected timeout or memory budget error")
+	}
+}
+
+func TestScriptSteps(t *testing.T) {
+	// 插入步数检查后迭代函数的结果不变
+	p, err := (&ScriptConfig{}).Init(map[string]interface{}{
+		"script": `sum(map([1, 2, 3], # * 2)) == 12 && all(keys(labels), # != "") && len(filter(1..10, # % 2 == 0)) == 5 ? set_label("ok", "1") : true`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wfCtx, _, err := p.Process(nil, &models.WorkflowContext{Event: newEvent()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wfCtx.Event.TagsMap["ok"] != "1" {
+		t.Fatalf("unexpected tags: %v", wfCtx.Event.TagsMap)
+	}
+
+	// 每次迭代占用内存很少的嵌套循环不受 vm.MemoryBudget 限制，由步数限制
+	p, err = (&ScriptConfig{}).Init(map[string]interface{}{
+		"script":  `let xs = 1..1500; all(xs, all(xs, # > 0))`,
+		"timeout": maxScriptTimeout,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := p.Process(nil, &models.WorkflowContext{Event: newEvent()}); err == nil || !strings.Contains(err.Error(), "steps") {
+		t.Fatalf("expected steps exceeded error, got %v", err)
+	}
+}
+
+func TestScriptTimeoutStopsVM(t *testing.T) {
+	p, err := (&ScriptConfig{}).Init(map[string]interface{}{
+		"script":  `let xs = 1..1000; all(xs, all(xs, # > 0))`,
+		"timeout": 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := runtime.NumGoroutine()
+	if _, _, err := p.Process(nil, &models.WorkflowContext{Event: newEvent()}); err == nil {
+		t.Fatal("expected timeout error")
+	}
+
+	// 超时后执行脚本的 goroutine 在下一次迭代时退出
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("script goroutine still running after timeout")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/ccfos/nightingale/v6/alert/mute"
 	"github.com/ccfos/nightingale/v6/alert/pipeline/processor/relabel"
 	"github.com/ccfos/nightingale/v6/alert/queue"
+	"github.com/ccfos/nightingale/v6/alert/timeline"
 	"github.com/ccfos/nightingale/v6/memsto"
 	"github.com/ccfos/nightingale/v6/models"
 	"github.com/ccfos/nightingale/v6/pkg/ctx"
@@ -164,6 +165,7 @@ func (p *Processor) Handle(anomalyPoints []models.AnomalyPoint, from string, inh
 		isMuted, detail, muteId := mute.IsMuted(cachedRule, event, p.TargetCache, p.alertMuteCache)
 		if isMuted {
 			logger.Infof("rule_eval:%s is muted, detail:%s event:%v", p.Key(), detail, event)
+			timeline.Record(event, models.TimelineTypeMute, fmt.Sprintf("muted by alert mute %d: %s", muteId, detail))
 			p.Stats.CounterMuteTotal.WithLabelValues(
 				fmt.Sprintf("%v", event.GroupName),
 				fmt.Sprintf("%v", p.rule.Id),
@@ -175,6 +177,7 @@ func (p *Processor) Handle(anomalyPoints []models.AnomalyPoint, from string, inh
 
 		if dispatch.EventMuteHook(event) {
 			logger.Infof("rule_eval:%s is muted by hook event:%v", p.Key(), event)
+			timeline.Record(event, models.TimelineTypeMute, "muted by hook")
 			p.Stats.CounterMuteTotal.WithLabelValues(
 				fmt.Sprintf("%v", event.GroupName),
 				fmt.Sprintf("%v", p.rule.Id),
@@ -343,6 +346,7 @@ func (p *Processor) HandleRecoverEvent(hashArr []string, now int64, inhibit bool
 
 		if e.Severity > event.Severity {
 			// hash 对应的恢复事件的被抑制了，把之前的事件删除
+			timeline.Record(&e, models.TimelineTypeInhibit, fmt.Sprintf("recovery inhibited by severity %d", event.Severity))
 			p.fires.Delete(e.Hash)
 			p.pendings.Delete(e.Hash)
 			models.AlertCurEventDelByHash(p.ctx, e.Hash)
@@ -461,6 +465,7 @@ func (p *Processor) inhibitEvent(events []*models.AlertCurEvent, highSeverity in
 	for _, event := range events {
 		if p.inhibit && event.Severity > highSeverity {
 			logger.Debugf("rule_eval:%s event:%+v inhibit highSeverity:%d", p.Key(), event, highSeverity)
+			timeline.Record(event, models.TimelineTypeInhibit, fmt.Sprintf("inhibited by severity %d", highSeverity))
 			continue
 		}
 		p.fireEvent(event)
@@ -586,6 +591,9 @@ func (p *Processor) fillTags(anomalyPoint models.AnomalyPoint) {
 		tagsMap[string(label)] = string(value)
 	}
 
+	// 补充 Kubernetes 服务发现得到的 label、annotation 等元数据，不覆盖曲线本身的标签
+	p.fillK8sTags(tagsMap)
+
 	var e = &models.AlertCurEvent{
 		TagsMap: tagsMap,
 	}
@@ -625,6 +633,25 @@ func (p *Processor) fillTags(anomalyPoint models.AnomalyPoint) {
 	p.tagsArr = labelMapToArr(tagsMap)
 }
 
+func (p *Processor) fillK8sTags(tagsMap map[string]string) {
+	if p.TargetCache == nil {
+		return
+	}
+
+	for _, ident := range models.K8sTargetIdentsByLabels(tagsMap) {
+		target, has := p.TargetCache.Get(ident)
+		if !has || target == nil {
+			continue
+		}
+
+		for k, v := range target.GetHostTagsMap() {
+			if _, exists := tagsMap[k]; !exists {
+				tagsMap[k] = v
+			}
+		}
+	}
+}
+
 func (p *Processor) mayHandleIdent(event *models.AlertCurEvent) {
 	// handle ident
 	if ident, has := event.TagsMap["ident"]; has {
@@ -0,0 +1,245 @@
+package record
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/memsto"
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+	promsdk "github.com/ccfos/nightingale/v6/pkg/prom"
+	"github.com/ccfos/nightingale/v6/prom"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/toolkits/pkg/logger"
+)
+
+const (
+	backfillScanPeriod = 10 * time.Second
+	// 认领后超过 lease 秒没有保存进度，认为执行的实例已退出，其他实例可以接手
+	backfillClaimLease = 300
+	// 两次保存进度之间大约发出的查询时长，unit: s
+	backfillProgressSeconds = 30
+	// 区间查询每次最多查询的时间点数量
+	backfillRangeSteps = 720
+)
+
+var errBackfillCanceled = errors.New("backfill canceled")
+
+// Backfiller 执行记录规则的回填任务，任务保存在数据库中，多个 center 实例通过认领保证同一个任务只有一个实例执行
+// PromQl 规则使用区间查询，QueryConfigs 规则在每个时间点上执行一次查询，查询速率受任务的 qps 限制
+type Backfiller struct {
+	ctx             *ctx.Context
+	datasourceCache *memsto.DatasourceCacheType
+	promClients     *prom.PromClientMap
+}
+
+func NewBackfiller(ctx *ctx.Context, datasourceCache *memsto.DatasourceCacheType, promClients *prom.PromClientMap) *Backfiller {
+	return &Backfiller{
+		ctx:             ctx,
+		datasourceCache: datasourceCache,
+		promClients:     promClients,
+	}
+}
+
+func (b *Backfiller) Start() {
+	go func() {
+		for {
+			b.runUnfinished()
+			time.Sleep(backfillScanPeriod)
+		}
+	}()
+}
+
+func (b *Backfiller) runUnfinished() {
+	lst, err := models.RecordingRuleBackfillUnfinished(b.ctx)
+	if err != nil {
+		logger.Errorf("backfill: failed to get unfinished jobs: %v", err)
+		return
+	}
+
+	for _, job := range lst {
+		ok, err := job.Claim(b.ctx, backfillClaimLease)
+		if err != nil {
+			logger.Errorf("backfill: failed to claim job %d: %v", job.Id, err)
+			continue
+		}
+
+		if !ok {
+			continue
+		}
+
+		b.Run(job)
+	}
+}
+
+// Run 从 job.Done 开始执行已认领的任务，直到完成、失败或被取消
+func (b *Backfiller) Run(job *models.RecordingRuleBackfill) {
+	logger.Infof("backfill: job %d of rule %d started at step %d/%d", job.Id, job.RuleId, job.Done, job.Total)
+
+	err := b.run(job)
+	if errors.Is(err, errBackfillCanceled) {
+		logger.Infof("backfill: job %d canceled at step %d/%d", job.Id, job.Done, job.Total)
+		return
+	}
+
+	status := models.BackfillStatusSucceeded
+	if err != nil {
+		logger.Errorf("backfill: job %d failed at step %d/%d: %v", job.Id, job.Done, job.Total, err)
+		status = models.BackfillStatusFailed
+		job.SetError(err.Error())
+	}
+
+	if err := job.Finish(b.ctx, status); err != nil {
+		logger.Errorf("backfill: failed to update job %d: %v", job.Id, err)
+	}
+}
+
+func (b *Backfiller) run(job *models.RecordingRuleBackfill) error {
+	rule, err := models.RecordingRuleGetById(b.ctx, job.RuleId)
+	if err != nil {
+		return err
+	}
+
+	if rule == nil {
+		return fmt.Errorf("recording rule %d not found", job.RuleId)
+	}
+
+	promql := strings.TrimSpace(rule.PromQl)
+	var promDsIds []int64
+	if promql != "" {
+		promDsIds = b.datasourceCache.GetIDsByDsCateAndQueries(models.PROMETHEUS, rule.DatasourceQueries)
+	}
+
+	var configs []models.QueryConfig
+	for _, config := range rule.QueryConfigsJson {
+		if len(config.Queries) > 0 && config.WriteDatasourceId > 0 {
+			configs = append(configs, config)
+		}
+	}
+
+	if len(promDsIds) == 0 && len(configs) == 0 {
+		return fmt.Errorf("nothing to backfill, no datasource matched or no query configured")
+	}
+
+	throttle := time.NewTicker(time.Second / time.Duration(job.Qps))
+	defer throttle.Stop()
+
+	chunk := backfillChunkSteps(job.Qps, len(promDsIds), configs)
+	for job.Done < job.Total {
+		n := chunk
+		if job.Done+n > job.Total {
+			n = job.Total - job.Done
+		}
+
+		start := job.StepAt(job.Done)
+		end := job.StepAt(job.Done + n - 1)
+
+		for _, dsId := range promDsIds {
+			<-throttle.C
+			samples, err := b.backfillPromQl(rule, promql, dsId, start, end, job.Step)
+			if err != nil {
+				return fmt.Errorf("datasource %d: %v", dsId, err)
+			}
+			job.Samples += samples
+		}
+
+		for _, config := range configs {
+			for at := start; at <= end; at += job.Step {
+				for range config.Queries {
+					<-throttle.C
+				}
+
+				samples, err := b.backfillQueryConfig(rule, config, at, job.Step)
+				if err != nil {
+					return fmt.Errorf("%s at %d: %v", config.NewMetric, at, err)
+				}
+				job.Samples += samples
+			}
+		}
+
+		job.Done += n
+		ok, err := job.UpdateProgress(b.ctx)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return errBackfillCanceled
+		}
+	}
+
+	return nil
+}
+
+func (b *Backfiller) backfillPromQl(rule *models.RecordingRule, promql string, dsId, start, end, step int64) (int64, error) {
+	if b.promClients.IsNil(dsId) {
+		return 0, fmt.Errorf("client is nil")
+	}
+
+	value, _, err := b.promClients.GetCli(dsId).QueryRange(context.Background(), promql, promsdk.Range{
+		Start: time.Unix(start, 0),
+		End:   time.Unix(end, 0),
+		Step:  time.Duration(step) * time.Second,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return b.write(dsId, ConvertToTimeSeries(value, rule))
+}
+
+func (b *Backfiller) backfillQueryConfig(rule *models.RecordingRule, config models.QueryConfig, at, step int64) (int64, error) {
+	if b.promClients.IsNil(config.WriteDatasourceId) {
+		return 0, fmt.Errorf("writer client of datasource %d is nil", config.WriteDatasourceId)
+	}
+
+	ts, err := EvalQueryConfig(rule, config, b.datasourceCache, b.promClients, at, step)
+	if err != nil {
+		return 0, err
+	}
+
+	return b.write(config.WriteDatasourceId, ts)
+}
+
+func (b *Backfiller) write(dsId int64, ts []prompb.TimeSeries) (int64, error) {
+	if len(ts) == 0 {
+		return 0, nil
+	}
+
+	if err := b.promClients.GetWriterCli(dsId).Write(ts); err != nil {
+		return 0, err
+	}
+
+	var samples int64
+	for i := range ts {
+		samples += int64(len(ts[i].Samples))
+	}
+	return samples, nil
+}
+
+// backfillChunkSteps 每次保存进度前计算的时间点数量，按 qps 估算使两次保存间隔在 backfillProgressSeconds 左右，避免认领过期
+func backfillChunkSteps(qps, promDatasources int, configs []models.QueryConfig) int64 {
+	perStep := 0
+	for _, config := range configs {
+		perStep += len(config.Queries)
+	}
+
+	if perStep == 0 {
+		return backfillRangeSteps
+	}
+
+	chunk := (qps*backfillProgressSeconds - promDatasources) / perStep
+	if chunk < 1 {
+		chunk = 1
+	}
+
+	if chunk > backfillRangeSteps {
+		chunk = backfillRangeSteps
+	}
+
+	return int64(chunk)
+}
@@ -0,0 +1,179 @@
+package record
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/memsto"
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+	promsdk "github.com/ccfos/nightingale/v6/pkg/prom"
+	"github.com/ccfos/nightingale/v6/prom"
+
+	"github.com/glebarez/sqlite"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/api"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+	"gorm.io/gorm"
+)
+
+type fakeAPI struct {
+	promsdk.API
+}
+
+func (f *fakeAPI) QueryRange(ctx context.Context, query string, r promsdk.Range) (model.Value, promsdk.Warnings, error) {
+	stream := &model.SampleStream{Metric: model.Metric{"job": "n9e"}}
+	for t := r.Start; !t.After(r.End); t = t.Add(r.Step) {
+		stream.Values = append(stream.Values, model.SamplePair{Timestamp: model.TimeFromUnix(t.Unix()), Value: 1})
+	}
+	return model.Matrix{stream}, nil, nil
+}
+
+type remoteWriteServer struct {
+	sync.Mutex
+	samples []prompb.Sample
+}
+
+func (s *remoteWriteServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	data, err := snappy.Decode(nil, body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var req prompb.WriteRequest
+	if err := proto.Unmarshal(data, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.Lock()
+	for _, ts := range req.Timeseries {
+		s.samples = append(s.samples, ts.Samples...)
+	}
+	s.Unlock()
+}
+
+func TestBackfillerRun(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:backfill?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AutoMigrate(&models.RecordingRule{}, &models.RecordingRuleBackfill{}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := ctx.NewContext(context.Background(), db, true)
+
+	rule := &models.RecordingRule{
+		Name:              "job_up",
+		PromQl:            "sum(up) by (job)",
+		DatasourceQueries: []models.DatasourceQuery{{MatchType: 0, Op: "in", Values: []interface{}{1}}},
+		PromEvalInterval:  60,
+	}
+	if err := db.Create(rule).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &remoteWriteServer{}
+	hs := httptest.NewServer(srv)
+	defer hs.Close()
+
+	cli, err := api.NewClient(api.Config{Address: hs.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	promClients := &prom.PromClientMap{
+		ReaderClients: map[int64]promsdk.API{1: &fakeAPI{}},
+		WriterClients: map[int64]promsdk.WriterType{1: promsdk.NewWriter(cli, promsdk.ClientOptions{Url: hs.URL})},
+	}
+
+	dsCache := &memsto.DatasourceCacheType{}
+	dsCache.Set(map[int64]*models.Datasource{1: {Id: 1, Name: "prom", PluginType: models.PROMETHEUS}}, 1, 1)
+
+	end := time.Now().Add(-time.Hour).Unix()
+	job := &models.RecordingRuleBackfill{RuleId: rule.Id, Start: end - 3000, End: end, Qps: 100}
+	if err := job.Verify(rule); err != nil {
+		t.Fatal(err)
+	}
+
+	if job.Step != 60 || job.Total != 51 {
+		t.Fatalf("unexpected step %d total %d", job.Step, job.Total)
+	}
+
+	if err := job.Add(c); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := job.Claim(c, backfillClaimLease); err != nil || !ok {
+		t.Fatalf("claim failed: %v %v", ok, err)
+	}
+
+	// 刚认领的任务其他实例不能重复认领
+	other, _ := models.RecordingRuleBackfillGetById(c, job.Id)
+	if ok, _ := other.Claim(c, backfillClaimLease); ok {
+		t.Fatalf("running job should not be claimed again")
+	}
+
+	NewBackfiller(c, dsCache, promClients).Run(job)
+
+	got, err := models.RecordingRuleBackfillGetById(c, job.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Status != models.BackfillStatusSucceeded || got.Done != 51 || got.Samples != 51 {
+		t.Fatalf("unexpected job: %+v", got)
+	}
+
+	if len(srv.samples) != 51 || srv.samples[0].Timestamp != (end-3000)*1000 {
+		t.Fatalf("unexpected samples written: %d", len(srv.samples))
+	}
+
+	// 取消后执行中的任务在保存进度时停止，状态保持为已取消
+	job2 := &models.RecordingRuleBackfill{RuleId: rule.Id, Start: end - 3000, End: end, Qps: 100}
+	if err := job2.Verify(rule); err != nil {
+		t.Fatal(err)
+	}
+	if err := job2.Add(c); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := job2.Claim(c, backfillClaimLease); !ok {
+		t.Fatal("claim failed")
+	}
+
+	canceled, _ := models.RecordingRuleBackfillGetById(c, job2.Id)
+	if err := canceled.Cancel(c); err != nil {
+		t.Fatal(err)
+	}
+
+	NewBackfiller(c, dsCache, promClients).Run(job2)
+
+	got, _ = models.RecordingRuleBackfillGetById(c, job2.Id)
+	if got.Status != models.BackfillStatusCanceled {
+		t.Fatalf("expected canceled, got %s", got.Status)
+	}
+}
+
+func TestBackfillVerify(t *testing.T) {
+	rule := &models.RecordingRule{}
+	now := time.Now().Unix()
+
+	if err := (&models.RecordingRuleBackfill{Start: now - 60, End: now + 3600}).Verify(rule); err == nil {
+		t.Errorf("expected error for future end time")
+	}
+
+	if err := (&models.RecordingRuleBackfill{Start: now - 86400*365, End: now, Step: 15}).Verify(rule); err == nil {
+		t.Errorf("expected error for too many steps")
+	}
+}
@@ -0,0 +1,400 @@
+package record
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/alert/astats"
+	"github.com/ccfos/nightingale/v6/alert/eval"
+	"github.com/ccfos/nightingale/v6/dscache"
+	"github.com/ccfos/nightingale/v6/memsto"
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/hash"
+	"github.com/ccfos/nightingale/v6/pkg/parser"
+	"github.com/ccfos/nightingale/v6/prom"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/robfig/cron/v3"
+	"github.com/toolkits/pkg/logger"
+	"github.com/toolkits/pkg/str"
+)
+
+// QueryRecordRuleContext 执行 QueryConfigs 中的一组查询，查询可以来自 ClickHouse、MySQL、ES 等任意数据源，
+// 查询结果按标签关联后用 Exp 计算出新的值，以 NewMetric 为指标名写入 WriteDatasourceId 对应的时序库
+type QueryRecordRuleContext struct {
+	index int
+	quit  chan struct{}
+
+	scheduler       *cron.Cron
+	rule            *models.RecordingRule
+	config          models.QueryConfig
+	datasourceCache *memsto.DatasourceCacheType
+	promClients     *prom.PromClientMap
+	stats           *astats.Stats
+}
+
+func NewQueryRecordRuleContext(rule *models.RecordingRule, index int, datasourceCache *memsto.DatasourceCacheType, promClients *prom.PromClientMap, stats *astats.Stats) *QueryRecordRuleContext {
+	rrc := &QueryRecordRuleContext{
+		index:           index,
+		quit:            make(chan struct{}),
+		rule:            rule,
+		config:          rule.QueryConfigsJson[index],
+		datasourceCache: datasourceCache,
+		promClients:     promClients,
+		stats:           stats,
+	}
+
+	cronPattern := rule.CronPattern
+	if cronPattern == "" && rule.PromEvalInterval != 0 {
+		cronPattern = fmt.Sprintf("@every %ds", rule.PromEvalInterval)
+	}
+
+	rrc.scheduler = cron.New(cron.WithSeconds(), cron.WithChain(cron.SkipIfStillRunning(cron.DefaultLogger)))
+	_, err := rrc.scheduler.AddFunc(cronPattern, func() {
+		rrc.Eval()
+	})
+
+	if err != nil {
+		logger.Errorf("add cron pattern error: %v", err)
+	}
+
+	return rrc
+}
+
+func (rrc *QueryRecordRuleContext) Key() string {
+	return fmt.Sprintf("record-query-%d-%d", rrc.rule.Id, rrc.index)
+}
+
+func (rrc *QueryRecordRuleContext) Hash() string {
+	config, _ := json.Marshal(rrc.config)
+	return str.MD5(fmt.Sprintf("%d_%d_%s_%s_%s_%s",
+		rrc.rule.Id,
+		rrc.index,
+		rrc.rule.CronPattern,
+		config,
+		rrc.rule.AppendTags,
+		rrc.rule.Name,
+	))
+}
+
+func (rrc *QueryRecordRuleContext) Prepare() {}
+
+func (rrc *QueryRecordRuleContext) Start() {
+	logger.Infof("eval:%s started", rrc.Key())
+	rrc.scheduler.Start()
+}
+
+func (rrc *QueryRecordRuleContext) Eval() {
+	writeDsId := fmt.Sprintf("%d", rrc.config.WriteDatasourceId)
+	rrc.stats.CounterRecordEval.WithLabelValues(writeDsId).Inc()
+
+	if rrc.promClients.IsNil(rrc.config.WriteDatasourceId) {
+		logger.Errorf("eval:%s writer client of datasource:%d is nil", rrc.Key(), rrc.config.WriteDatasourceId)
+		rrc.stats.CounterRecordEvalErrorTotal.WithLabelValues(writeDsId).Inc()
+		return
+	}
+
+	ts, err := EvalQueryConfig(rrc.rule, rrc.config, rrc.datasourceCache, rrc.promClients, 0, 0)
+	if err != nil {
+		logger.Errorf("eval:%s error:%v", rrc.Key(), err)
+		rrc.stats.CounterRecordEvalErrorTotal.WithLabelValues(writeDsId).Inc()
+		return
+	}
+
+	if len(ts) == 0 {
+		return
+	}
+
+	if err := rrc.promClients.GetWriterCli(rrc.config.WriteDatasourceId).Write(ts); err != nil {
+		logger.Errorf("eval:%s write to datasource:%d error:%v", rrc.Key(), rrc.config.WriteDatasourceId, err)
+		rrc.stats.CounterRecordEvalErrorTotal.WithLabelValues(writeDsId).Inc()
+	}
+}
+
+// EvalQueryConfig 执行 config 中的查询并用 Exp 计算出要写入的曲线
+// at 为 0 时按当前时间减去 Delay 查询；回填时 at 为历史时间点，查询 [at-window, at] 区间的数据
+func EvalQueryConfig(rule *models.RecordingRule, config models.QueryConfig, datasourceCache *memsto.DatasourceCacheType,
+	promClients *prom.PromClientMap, at, window int64) ([]prompb.TimeSeries, error) {
+	seriesByRef := make(map[string][]models.DataResp)
+	for i, query := range config.Queries {
+		ref := queryRef(query, i)
+		series, err := queryAllDatasources(rule, config, query, ref, datasourceCache, promClients, at, window)
+		if err != nil {
+			return nil, fmt.Errorf("query %s: %v", ref, err)
+		}
+		seriesByRef[ref] = series
+	}
+
+	points, err := CombineSeries(seriesByRef, recordExp(config))
+	if err != nil {
+		return nil, fmt.Errorf("exp %s: %v", config.Exp, err)
+	}
+
+	// 回填时统一使用计算的时间点，避免 SQL 返回的时间戳落在区间开头
+	if at > 0 {
+		for i := range points {
+			points[i].Timestamp = at
+		}
+	}
+
+	return PointsToTimeSeries(points, config.NewMetric, rule), nil
+}
+
+// queryAllDatasources 在查询匹配的所有数据源上执行，结果合并
+func queryAllDatasources(rule *models.RecordingRule, config models.QueryConfig, query models.Query, ref string,
+	datasourceCache *memsto.DatasourceCacheType, promClients *prom.PromClientMap, at, window int64) ([]models.DataResp, error) {
+	dsIds := datasourceCache.GetIDsByDsCateAndQueries(query.Cate, query.DatasourceQueries)
+	if len(dsIds) == 0 {
+		return nil, fmt.Errorf("no datasource of %s matched", query.Cate)
+	}
+
+	var lst []models.DataResp
+	for _, dsId := range dsIds {
+		series, err := queryDatasource(rule, config, query, ref, dsId, promClients, at, window)
+		if err != nil {
+			return nil, err
+		}
+		lst = append(lst, series...)
+	}
+
+	return lst, nil
+}
+
+func queryDatasource(rule *models.RecordingRule, config models.QueryConfig, query models.Query, ref string, dsId int64,
+	promClients *prom.PromClientMap, at, window int64) ([]models.DataResp, error) {
+	if query.Cate == models.PROMETHEUS {
+		mq, err := models.ParseMixedQuery(query.Config)
+		if err != nil {
+			return nil, err
+		}
+
+		promql := strings.TrimSpace(mq.PromQl)
+		if promql == "" {
+			return nil, fmt.Errorf("prom_ql is blank")
+		}
+
+		if promClients.IsNil(dsId) {
+			return nil, fmt.Errorf("reader client of datasource:%d is nil", dsId)
+		}
+
+		ts := time.Now().Add(-time.Duration(config.Delay) * time.Second)
+		if at > 0 {
+			ts = time.Unix(at, 0)
+		}
+
+		value, _, err := promClients.GetCli(dsId).Query(context.Background(), promql, ts)
+		if err != nil {
+			return nil, err
+		}
+		return eval.ConvertPromValueToDataResp(value, ref, promql), nil
+	}
+
+	plug, exists := dscache.DsCache.Get(query.Cate, dsId)
+	if !exists {
+		return nil, fmt.Errorf("datasource %s:%d not exists", query.Cate, dsId)
+	}
+
+	// 规则缓存中的查询是共享的，模板渲染和时间区间只作用在副本上
+	queryConfig := copyQueryConfig(query.Config)
+	if err := eval.ExecuteQueryTemplate(query.Cate, queryConfig, nil); err != nil {
+		logger.Warningf("record rule:%d execute query template error: %v", rule.Id, err)
+	}
+
+	ctx := context.Background()
+	if at > 0 {
+		setQueryRange(queryConfig, at-window, at)
+	} else {
+		ctx = context.WithValue(ctx, "delay", int64(config.Delay))
+	}
+
+	return plug.QueryData(ctx, queryConfig)
+}
+
+func copyQueryConfig(config interface{}) interface{} {
+	m, ok := config.(map[string]interface{})
+	if !ok {
+		return config
+	}
+
+	ret := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		ret[k] = v
+	}
+	return ret
+}
+
+// setQueryRange SQL 类数据源使用 from/to 渲染 $__timeFilter 等宏，ES、VictoriaLogs 使用 start/end
+func setQueryRange(config interface{}, start, end int64) {
+	m, ok := config.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	m["from"] = start
+	m["to"] = end
+	m["start"] = start
+	m["end"] = end
+}
+
+func (rrc *QueryRecordRuleContext) Stop() {
+	logger.Infof("%s stopped", rrc.Key())
+
+	c := rrc.scheduler.Stop()
+	<-c.Done()
+	close(rrc.quit)
+}
+
+// queryRef 查询配置中没有 ref 时按顺序使用 A、B、C
+func queryRef(query models.Query, i int) string {
+	if ref, err := eval.GetQueryRef(query.Config); err == nil && ref != "" {
+		return ref
+	}
+	return string(rune('A' + i))
+}
+
+// recordExp 只有一个查询时 Exp 可以为空，直接使用查询结果
+func recordExp(config models.QueryConfig) string {
+	if strings.TrimSpace(config.Exp) != "" || len(config.Queries) != 1 {
+		return config.Exp
+	}
+	return "$" + queryRef(config.Queries[0], 0)
+}
+
+type RecordPoint struct {
+	Metric    model.Metric
+	Timestamp int64 // unix 秒
+	Value     float64
+}
+
+// CombineSeries 把标签相同的曲线关联起来，取各自最新的值代入 exp 计算，
+// 没有标签的曲线（如 SQL 中的 count(*)）视为常量，可以和其他任意曲线计算
+func CombineSeries(seriesByRef map[string][]models.DataResp, exp string) ([]RecordPoint, error) {
+	exp = strings.TrimSpace(exp)
+	if exp == "" {
+		return nil, fmt.Errorf("exp is blank")
+	}
+
+	type group struct {
+		metric    model.Metric
+		values    map[string]float64
+		timestamp float64
+	}
+
+	groups := make(map[uint64]*group)
+	constants := make(map[string]float64)
+	var constantTs float64
+
+	refs := make([]string, 0, len(seriesByRef))
+	for ref := range seriesByRef {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+
+	for _, ref := range refs {
+		series := seriesByRef[ref]
+		for i := range series {
+			ts, value, exists := series[i].Last()
+			if !exists || math.IsNaN(value) {
+				continue
+			}
+
+			if len(labelsWithoutName(series[i].Metric)) == 0 && len(series) == 1 {
+				constants[ref] = value
+				constantTs = math.Max(constantTs, ts)
+				continue
+			}
+
+			tagHash := hash.GetTagHash(series[i].Metric)
+			g, has := groups[tagHash]
+			if !has {
+				g = &group{metric: labelsWithoutName(series[i].Metric), values: make(map[string]float64)}
+				groups[tagHash] = g
+			}
+			g.values[ref] = value
+			g.timestamp = math.Max(g.timestamp, ts)
+		}
+	}
+
+	// 所有查询都只有常量时，结果也是一条没有标签的曲线
+	if len(groups) == 0 && len(constants) > 0 {
+		groups[0] = &group{metric: model.Metric{}, values: make(map[string]float64), timestamp: constantTs}
+	}
+
+	var points []RecordPoint
+	for _, g := range groups {
+		m := make(map[string]interface{})
+		for ref, value := range constants {
+			m["$"+ref] = value
+		}
+		for ref, value := range g.values {
+			m["$"+ref] = value
+		}
+
+		// exp 中引用的查询在这组标签下没有数据，无法计算
+		if missingRef(exp, refs, m) {
+			continue
+		}
+
+		value, err := parser.MathCalc(exp, m)
+		if err != nil {
+			return nil, err
+		}
+
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+
+		ts := int64(math.Max(g.timestamp, constantTs))
+		if ts == 0 {
+			ts = time.Now().Unix()
+		}
+
+		points = append(points, RecordPoint{Metric: g.metric, Timestamp: ts, Value: value})
+	}
+
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Metric.String() < points[j].Metric.String()
+	})
+
+	return points, nil
+}
+
+func missingRef(exp string, refs []string, m map[string]interface{}) bool {
+	for _, ref := range refs {
+		if !strings.Contains(exp, "$"+ref) {
+			continue
+		}
+		if _, has := m["$"+ref]; !has {
+			return true
+		}
+	}
+	return false
+}
+
+func labelsWithoutName(metric model.Metric) model.Metric {
+	m := make(model.Metric, len(metric))
+	for k, v := range metric {
+		if k == model.MetricNameLabel {
+			continue
+		}
+		m[k] = v
+	}
+	return m
+}
+
+func PointsToTimeSeries(points []RecordPoint, metricName string, rule *models.RecordingRule) []prompb.TimeSeries {
+	lst := make([]prompb.TimeSeries, 0, len(points))
+	for _, p := range points {
+		lst = append(lst, prompb.TimeSeries{
+			Labels:  labelsToLabelsProto(p.Metric, metricName, rule),
+			Samples: []prompb.Sample{{Timestamp: p.Timestamp * 1000, Value: p.Value}},
+		})
+	}
+	return lst
+}
@@ -0,0 +1,80 @@
+package record
+
+import (
+	"testing"
+
+	"github.com/ccfos/nightingale/v6/models"
+
+	"github.com/prometheus/common/model"
+)
+
+func TestCombineSeries(t *testing.T) {
+	seriesByRef := map[string][]models.DataResp{
+		"A": {
+			{Ref: "A", Metric: model.Metric{"__name__": "orders", "region": "bj"}, Values: [][]float64{{100, 1}, {160, 10}}},
+			{Ref: "A", Metric: model.Metric{"__name__": "orders", "region": "sh"}, Values: [][]float64{{160, 30}}},
+			{Ref: "A", Metric: model.Metric{"__name__": "orders", "region": "gz"}, Values: [][]float64{{160, 5}}},
+		},
+		"B": {
+			{Ref: "B", Metric: model.Metric{"region": "bj"}, Values: [][]float64{{160, 2}}},
+			{Ref: "B", Metric: model.Metric{"region": "sh"}, Values: [][]float64{{170, 3}}},
+		},
+		// SQL 中 count(*) 这类没有标签的结果
+		"C": {
+			{Ref: "C", Metric: model.Metric{}, Values: [][]float64{{150, 100}}},
+		},
+	}
+
+	points, err := CombineSeries(seriesByRef, "($A / $B) * $C")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// gz 没有 B 的数据，不参与计算
+	if len(points) != 2 {
+		t.Fatalf("expected 2 points, got %v", points)
+	}
+
+	if points[0].Metric["region"] != "bj" || points[0].Value != 500 || points[0].Timestamp != 160 {
+		t.Errorf("unexpected point: %+v", points[0])
+	}
+
+	if points[1].Metric["region"] != "sh" || points[1].Value != 1000 || points[1].Timestamp != 170 {
+		t.Errorf("unexpected point: %+v", points[1])
+	}
+
+	if _, err := CombineSeries(seriesByRef, ""); err == nil {
+		t.Errorf("expected error for blank exp")
+	}
+}
+
+func TestCombineSeriesScalar(t *testing.T) {
+	seriesByRef := map[string][]models.DataResp{
+		"A": {{Ref: "A", Values: [][]float64{{100, 42}}}},
+	}
+
+	config := models.QueryConfig{Queries: []models.Query{{Cate: "mysql", Config: map[string]interface{}{"ref": "A"}}}}
+	points, err := CombineSeries(seriesByRef, recordExp(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(points) != 1 || points[0].Value != 42 || points[0].Timestamp != 100 {
+		t.Fatalf("unexpected points: %v", points)
+	}
+
+	rule := &models.RecordingRule{Name: "ignored", AppendTagsJSON: []string{"source=mysql"}}
+	ts := PointsToTimeSeries(points, "orders_total", rule)
+	if len(ts) != 1 || ts[0].Samples[0].Timestamp != 100000 {
+		t.Fatalf("unexpected series: %v", ts)
+	}
+
+	labels := make(map[string]string)
+	for _, l := range ts[0].Labels {
+		labels[l.Name] = l.Value
+	}
+
+	if labels["__name__"] != "orders_total" || labels["source"] != "mysql" {
+		t.Errorf("unexpected labels: %v", labels)
+	}
+}
@@ -30,7 +30,7 @@ func ConvertToTimeSeries(value model.Value, rule *models.RecordingRule) (lst []p
 			s := prompb.Sample{}
 			s.Timestamp = time.Unix(item.Timestamp.Unix(), 0).UnixNano() / 1e6
 			s.Value = float64(item.Value)
-			l := labelsToLabelsProto(item.Metric, rule)
+			l := labelsToLabelsProto(item.Metric, rule.Name, rule)
 			lst = append(lst, prompb.TimeSeries{
 				Labels:  l,
 				Samples: []prompb.Sample{s},
@@ -52,7 +52,7 @@ func ConvertToTimeSeries(value model.Value, rule *models.RecordingRule) (lst []p
 			if math.IsNaN(float64(last.Value)) {
 				continue
 			}
-			l := labelsToLabelsProto(item.Metric, rule)
+			l := labelsToLabelsProto(item.Metric, rule.Name, rule)
 			var slst []prompb.Sample
 			for _, v := range item.Values {
 				if math.IsNaN(float64(v.Value)) {
@@ -89,11 +89,11 @@ func ConvertToTimeSeries(value model.Value, rule *models.RecordingRule) (lst []p
 	return
 }
 
-func labelsToLabelsProto(labels model.Metric, rule *models.RecordingRule) (result []prompb.Label) {
+func labelsToLabelsProto(labels model.Metric, metricName string, rule *models.RecordingRule) (result []prompb.Label) {
 	//name
 	nameLs := prompb.Label{
 		Name:  LabelName,
-		Value: rule.Name,
+		Value: metricName,
 	}
 	result = append(result, nameLs)
 	for k, v := range labels {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ccfos/nightingale/v6/alert/aconf"
@@ -14,9 +15,18 @@ import (
 	"github.com/ccfos/nightingale/v6/pushgw/writer"
 )
 
+// RecordRule 由 Scheduler 统一调度，PromQl 规则和 QueryConfigs 规则都实现该接口
+type RecordRule interface {
+	Key() string
+	Hash() string
+	Prepare()
+	Start()
+	Stop()
+}
+
 type Scheduler struct {
 	// key: hash
-	recordRules map[string]*RecordRuleContext
+	recordRules map[string]RecordRule
 
 	aconf aconf.Alert
 
@@ -28,12 +38,13 @@ type Scheduler struct {
 	stats *astats.Stats
 
 	datasourceCache *memsto.DatasourceCacheType
+	ruleGroupCache  *memsto.RuleGroupCacheType
 }
 
-func NewScheduler(aconf aconf.Alert, rrc *memsto.RecordingRuleCacheType, promClients *prom.PromClientMap, writers *writer.WritersType, stats *astats.Stats, datasourceCache *memsto.DatasourceCacheType) *Scheduler {
+func NewScheduler(aconf aconf.Alert, rrc *memsto.RecordingRuleCacheType, promClients *prom.PromClientMap, writers *writer.WritersType, stats *astats.Stats, datasourceCache *memsto.DatasourceCacheType, ruleGroupCache *memsto.RuleGroupCacheType) *Scheduler {
 	scheduler := &Scheduler{
 		aconf:       aconf,
-		recordRules: make(map[string]*RecordRuleContext),
+		recordRules: make(map[string]RecordRule),
 
 		recordingRuleCache: rrc,
 
@@ -43,6 +54,7 @@ func NewScheduler(aconf aconf.Alert, rrc *memsto.RecordingRuleCacheType, promCli
 		stats: stats,
 
 		datasourceCache: datasourceCache,
+		ruleGroupCache:  ruleGroupCache,
 	}
 
 	go scheduler.LoopSyncRules(context.Background())
@@ -64,20 +76,41 @@ func (s *Scheduler) LoopSyncRules(ctx context.Context) {
 
 func (s *Scheduler) syncRecordRules() {
 	ids := s.recordingRuleCache.GetRuleIds()
-	recordRules := make(map[string]*RecordRuleContext)
+	recordRules := make(map[string]RecordRule)
 	for _, id := range ids {
 		rule := s.recordingRuleCache.Get(id)
 		if rule == nil {
 			continue
 		}
 
-		datasourceIds := s.datasourceCache.GetIDsByDsCateAndQueries("prometheus", rule.DatasourceQueries)
-		for _, dsId := range datasourceIds {
-			if !naming.DatasourceHashRing.IsHit(strconv.FormatInt(dsId, 10), fmt.Sprintf("%d", rule.Id), s.aconf.Heartbeat.Endpoint) {
+		// 属于规则组的规则由规则组按顺序执行
+		if s.ruleGroupCache.RecordingRuleGrouped(rule.Id) {
+			continue
+		}
+
+		if strings.TrimSpace(rule.PromQl) != "" {
+			datasourceIds := s.datasourceCache.GetIDsByDsCateAndQueries("prometheus", rule.DatasourceQueries)
+			for _, dsId := range datasourceIds {
+				if !naming.DatasourceHashRing.IsHit(strconv.FormatInt(dsId, 10), fmt.Sprintf("%d", rule.Id), s.aconf.Heartbeat.Endpoint) {
+					continue
+				}
+
+				recordRule := NewRecordRuleContext(rule, dsId, s.promClients, s.writers, s.stats)
+				recordRules[recordRule.Hash()] = recordRule
+			}
+		}
+
+		// QueryConfigs 可能查询多个数据源，按写入的数据源分片，保证只有一个 alert 实例执行
+		for i, config := range rule.QueryConfigsJson {
+			if len(config.Queries) == 0 || config.WriteDatasourceId <= 0 {
+				continue
+			}
+
+			if !naming.DatasourceHashRing.IsHit(strconv.FormatInt(config.WriteDatasourceId, 10), fmt.Sprintf("%d-%d", rule.Id, i), s.aconf.Heartbeat.Endpoint) {
 				continue
 			}
 
-			recordRule := NewRecordRuleContext(rule, dsId, s.promClients, s.writers, s.stats)
+			recordRule := NewQueryRecordRuleContext(rule, i, s.datasourceCache, s.promClients, s.stats)
 			recordRules[recordRule.Hash()] = recordRule
 		}
 	}
@@ -0,0 +1,145 @@
+package remediation
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/models"
+
+	imodels "github.com/flashcatcloud/ibex/src/models"
+	"github.com/toolkits/pkg/logger"
+)
+
+const (
+	syncInterval  = 10 // unit: s
+	syncBatchSize = 100
+	// 任务超过该时长仍没有结果时不再等待，unit: s
+	maxTaskDuration = 86400
+	// 任务输出的最大长度
+	maxOutputLength = 60000
+)
+
+// LoopSyncRuns 定期处理审批超时、执行审批通过的记录，并把自愈任务的结果和输出写回执行记录
+func (r *Remediator) LoopSyncRuns() {
+	duration := time.Duration(syncInterval) * time.Second
+	for {
+		time.Sleep(duration)
+		r.expirePending()
+		r.executeApproved()
+		r.syncRunning()
+	}
+}
+
+func (r *Remediator) expirePending() {
+	runs, err := models.RemediationRunsByStatus(r.ctx, models.RemediationStatusPendingApproval, syncBatchSize)
+	if err != nil {
+		logger.Errorf("remediation: failed to get pending runs: %v", err)
+		return
+	}
+
+	now := time.Now().Unix()
+	for _, run := range runs {
+		if run.ExpireAt > now {
+			continue
+		}
+
+		if _, err := run.Transit(r.ctx, models.RemediationStatusPendingApproval, map[string]interface{}{
+			"status":  models.RemediationStatusExpired,
+			"message": "approval timeout",
+		}); err != nil {
+			logger.Errorf("remediation: run_id=%d failed to update: %v", run.Id, err)
+		}
+	}
+}
+
+// executeApproved 审批通过时事件可能已经恢复，只对仍在告警的事件执行
+func (r *Remediator) executeApproved() {
+	runs, err := models.RemediationRunsByStatus(r.ctx, models.RemediationStatusApproved, syncBatchSize)
+	if err != nil {
+		logger.Errorf("remediation: failed to get approved runs: %v", err)
+		return
+	}
+
+	for _, run := range runs {
+		event, err := models.AlertCurEventGetById(r.ctx, run.EventId)
+		if err != nil {
+			logger.Errorf("remediation: run_id=%d failed to get event: %v", run.Id, err)
+			continue
+		}
+
+		if event == nil {
+			if _, err := run.Transit(r.ctx, models.RemediationStatusApproved, map[string]interface{}{
+				"status":  models.RemediationStatusSkipped,
+				"message": "event recovered before execution",
+			}); err != nil {
+				logger.Errorf("remediation: run_id=%d failed to update: %v", run.Id, err)
+			}
+			continue
+		}
+
+		event.DB2FE()
+		r.execute(run, event)
+	}
+}
+
+func (r *Remediator) syncRunning() {
+	if imodels.DB() == nil {
+		return
+	}
+
+	runs, err := models.RemediationRunsByStatus(r.ctx, models.RemediationStatusRunning, syncBatchSize)
+	if err != nil {
+		logger.Errorf("remediation: failed to get running runs: %v", err)
+		return
+	}
+
+	now := time.Now().Unix()
+	for _, run := range runs {
+		if run.TaskId == 0 {
+			continue
+		}
+
+		taskHost, err := imodels.TaskHostGet(run.TaskId, run.Host)
+		if err != nil {
+			logger.Errorf("remediation: run_id=%d failed to get task %d: %v", run.Id, run.TaskId, err)
+			continue
+		}
+
+		if taskHost == nil || taskHost.Status == "waiting" || taskHost.Status == "running" {
+			if now-run.CreateAt > maxTaskDuration {
+				if _, err := run.Transit(r.ctx, models.RemediationStatusRunning, map[string]interface{}{
+					"status":  models.RemediationStatusFailed,
+					"message": fmt.Sprintf("task %d has no result after %ds", run.TaskId, maxTaskDuration),
+				}); err != nil {
+					logger.Errorf("remediation: run_id=%d failed to update: %v", run.Id, err)
+				}
+			}
+			continue
+		}
+
+		status := models.RemediationStatusFailed
+		if taskHost.Status == "success" {
+			status = models.RemediationStatusSuccess
+		}
+
+		if _, err := run.Transit(r.ctx, models.RemediationStatusRunning, map[string]interface{}{
+			"status":  status,
+			"message": fmt.Sprintf("task %d %s", run.TaskId, taskHost.Status),
+			"output":  taskOutput(taskHost.Stdout, taskHost.Stderr),
+		}); err != nil {
+			logger.Errorf("remediation: run_id=%d failed to update: %v", run.Id, err)
+		}
+	}
+}
+
+func taskOutput(stdout, stderr string) string {
+	output := stdout
+	if stderr != "" {
+		output += "\n[stderr]\n" + stderr
+	}
+
+	if len(output) > maxOutputLength {
+		output = output[:maxOutputLength]
+	}
+	return output
+}
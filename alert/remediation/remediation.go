@@ -0,0 +1,355 @@
+package remediation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/alert/common"
+	"github.com/ccfos/nightingale/v6/alert/sender"
+	"github.com/ccfos/nightingale/v6/memsto"
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+	"github.com/ccfos/nightingale/v6/storage"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/toolkits/pkg/logger"
+)
+
+const (
+	lockKeyPrefix = "n9e_remediation_lock:"
+	// 加锁后只检查限制和写入执行记录，很快就会释放，超时是为了避免实例异常退出后一直不能执行
+	lockTTL  = 10 * time.Second
+	lockWait = 5 * time.Second
+)
+
+var redisCli storage.Redis
+
+// RegisterRedis 注册 redis 后多个 center 之间对同一个策略加锁，保证冷却时间和每小时执行次数的限制不会被并发的事件突破
+func RegisterRedis(r storage.Redis) {
+	redisCli = r
+}
+
+// unlockScript 只删除自己加的锁，避免锁超时后删除其他实例的锁
+var unlockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Remediator 按故障自愈策略处理告警事件，执行记录保存在数据库中，只在 center 中运行
+type Remediator struct {
+	ctx             *ctx.Context
+	policyCache     *memsto.RemediationPolicyCacheType
+	taskTplCache    *memsto.TaskTplCache
+	targetCache     *memsto.TargetCacheType
+	userCache       *memsto.UserCacheType
+	configCvalCache *memsto.CvalCache
+	client          *http.Client
+
+	// 每个策略一个锁，同一个实例内的事件不用等待 redis
+	locks sync.Map
+}
+
+func NewRemediator(ctx *ctx.Context, policyCache *memsto.RemediationPolicyCacheType, taskTplCache *memsto.TaskTplCache,
+	targetCache *memsto.TargetCacheType, userCache *memsto.UserCacheType, configCvalCache *memsto.CvalCache) *Remediator {
+	return &Remediator{
+		ctx:             ctx,
+		policyCache:     policyCache,
+		taskTplCache:    taskTplCache,
+		targetCache:     targetCache,
+		userCache:       userCache,
+		configCvalCache: configCvalCache,
+		client:          &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Handle 告警事件匹配业务组中的自愈策略时，按策略的限制试运行、发起审批或执行自愈脚本，
+// 恢复事件和重复通知的事件不处理，同一次告警只自愈一次
+func (r *Remediator) Handle(event *models.AlertCurEvent) {
+	if event.IsRecovered || event.NotifyCurNumber > 1 {
+		return
+	}
+
+	for _, policy := range r.policyCache.GetByGroupId(event.GroupId) {
+		if !match(policy, event) {
+			continue
+		}
+
+		if err := r.apply(policy, event); err != nil {
+			logger.Errorf("remediation: policy_id=%d event_hash=%s failed to apply: %v", policy.Id, event.Hash, err)
+		}
+	}
+}
+
+func match(policy *models.RemediationPolicy, event *models.AlertCurEvent) bool {
+	if policy.GroupId != event.GroupId {
+		return false
+	}
+
+	if len(policy.RuleIds) > 0 && !contains(policy.RuleIds, event.RuleId) {
+		return false
+	}
+
+	if len(policy.Severities) > 0 && !contains(policy.Severities, event.Severity) {
+		return false
+	}
+
+	if len(policy.LabelFilters) > 0 {
+		// 拷贝一份，避免修改缓存中的策略
+		filters := make([]models.TagFilter, len(policy.LabelFilters))
+		copy(filters, policy.LabelFilters)
+
+		tagFilters, err := models.ParseTagFilter(filters)
+		if err != nil {
+			logger.Errorf("remediation: policy_id=%d failed to parse label filters: %v", policy.Id, err)
+			return false
+		}
+
+		if !common.MatchTags(event.TagsMap, tagFilters) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func contains[T comparable](lst []T, v T) bool {
+	for _, item := range lst {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+// eventHost 自愈脚本在事件的机器上执行
+func eventHost(event *models.AlertCurEvent) string {
+	if event.TargetIdent != "" {
+		return event.TargetIdent
+	}
+	return event.TagsMap["ident"]
+}
+
+func (r *Remediator) apply(policy *models.RemediationPolicy, event *models.AlertCurEvent) error {
+	run := &models.RemediationRun{
+		PolicyId:   policy.Id,
+		PolicyName: policy.Name,
+		GroupId:    policy.GroupId,
+		EventId:    event.Id,
+		EventHash:  event.Hash,
+		RuleId:     event.RuleId,
+		RuleName:   event.RuleName,
+		Host:       eventHost(event),
+		TaskTplId:  policy.TaskTplId,
+	}
+
+	if run.Host == "" {
+		run.Status = models.RemediationStatusSkipped
+		run.Message = "no host found in event"
+		return run.Add(r.ctx)
+	}
+
+	// 检查限制和写入执行记录之间不能有其他事件写入，否则并发的事件都能通过检查
+	unlock, err := r.lockPolicy(policy.Id)
+	if err != nil {
+		return err
+	}
+	err = r.record(policy, run)
+	unlock()
+	if err != nil {
+		return err
+	}
+
+	switch run.Status {
+	case models.RemediationStatusPendingApproval:
+		r.requestApproval(policy, run, event)
+	case models.RemediationStatusApproved:
+		r.execute(run, event)
+	}
+	return nil
+}
+
+// record 按策略的限制确定执行记录的状态并保存，调用方需要对策略加锁
+func (r *Remediator) record(policy *models.RemediationPolicy, run *models.RemediationRun) error {
+	reason, err := r.limited(policy, run.Host)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case reason != "":
+		run.Status = models.RemediationStatusSkipped
+		run.Message = reason
+	case policy.DryRun:
+		run.Status = models.RemediationStatusDryRun
+		run.Message = fmt.Sprintf("dry run, task tpl %d would run on %s", policy.TaskTplId, run.Host)
+	case policy.ApprovalRequired:
+		run.Status = models.RemediationStatusPendingApproval
+		run.Token = uuid.New().String()
+		run.ExpireAt = time.Now().Unix() + policy.ApprovalTimeout
+		run.Message = "waiting for approval"
+	default:
+		run.Status = models.RemediationStatusApproved
+	}
+
+	return run.Add(r.ctx)
+}
+
+// lockPolicy 对策略加锁，返回释放锁的函数；redis 不可用时只在本实例内加锁
+func (r *Remediator) lockPolicy(policyId int64) (func(), error) {
+	v, _ := r.locks.LoadOrStore(policyId, &sync.Mutex{})
+	mu := v.(*sync.Mutex)
+	mu.Lock()
+
+	if redisCli == nil {
+		return mu.Unlock, nil
+	}
+
+	key := fmt.Sprintf("%s%d", lockKeyPrefix, policyId)
+	token := uuid.New().String()
+	deadline := time.Now().Add(lockWait)
+	for {
+		ok, err := redisCli.SetNX(context.Background(), key, token, lockTTL).Result()
+		if err != nil {
+			logger.Warningf("remediation: policy_id=%d failed to lock in redis: %v", policyId, err)
+			return mu.Unlock, nil
+		}
+
+		if ok {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			mu.Unlock()
+			return nil, fmt.Errorf("timeout waiting for lock of policy %d", policyId)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	return func() {
+		if err := unlockScript.Run(context.Background(), redisCli, []string{key}, token).Err(); err != nil {
+			logger.Warningf("remediation: policy_id=%d failed to unlock in redis: %v", policyId, err)
+		}
+		mu.Unlock()
+	}, nil
+}
+
+// limited 返回跳过执行的原因，冷却时间按机器计算，每小时的执行次数按策略计算
+func (r *Remediator) limited(policy *models.RemediationPolicy, host string) (string, error) {
+	now := time.Now().Unix()
+	if policy.Cooldown > 0 {
+		count, err := models.RemediationRunCount(r.ctx, policy.Id, host, now-policy.Cooldown)
+		if err != nil {
+			return "", err
+		}
+
+		if count > 0 {
+			return fmt.Sprintf("in cooldown, already ran on %s within %ds", host, policy.Cooldown), nil
+		}
+	}
+
+	count, err := models.RemediationRunCount(r.ctx, policy.Id, "", now-3600)
+	if err != nil {
+		return "", err
+	}
+
+	if count >= int64(policy.MaxRunsPerHour) {
+		return fmt.Sprintf("rate limited, already ran %d times in the last hour", count), nil
+	}
+
+	return "", nil
+}
+
+// execute 认领审批通过的记录并下发自愈任务，任务的结果由 syncRunning 更新
+func (r *Remediator) execute(run *models.RemediationRun, event *models.AlertCurEvent) {
+	claimed, err := run.Transit(r.ctx, models.RemediationStatusApproved, map[string]interface{}{
+		"status":  models.RemediationStatusRunning,
+		"message": "creating task",
+	})
+	if err != nil || !claimed {
+		if err != nil {
+			logger.Errorf("remediation: run_id=%d failed to claim: %v", run.Id, err)
+		}
+		return
+	}
+
+	taskId, err := sender.CallIbex(r.ctx, run.TaskTplId, run.Host, r.taskTplCache, r.targetCache, r.userCache, event, "")
+	fields := map[string]interface{}{"task_id": taskId, "message": fmt.Sprintf("task %d created", taskId)}
+	if err != nil {
+		fields["status"] = models.RemediationStatusFailed
+		fields["message"] = fmt.Sprintf("failed to create task: %v", err)
+	}
+
+	if _, err := run.Transit(r.ctx, models.RemediationStatusRunning, fields); err != nil {
+		logger.Errorf("remediation: run_id=%d failed to update: %v", run.Id, err)
+	}
+}
+
+// requestApproval 把审批链接发送到策略配置的地址，没有配置时只能在页面上审批
+func (r *Remediator) requestApproval(policy *models.RemediationPolicy, run *models.RemediationRun, event *models.AlertCurEvent) {
+	if policy.ApprovalWebhook == "" {
+		return
+	}
+
+	siteUrl := strings.TrimRight(r.configCvalCache.GetSiteInfo().SiteUrl, "/")
+	approvalUrl := func(action string) string {
+		return fmt.Sprintf("%s/api/n9e/remediation-approval?token=%s&action=%s", siteUrl, url.QueryEscape(run.Token), action)
+	}
+
+	body := map[string]interface{}{
+		"text": fmt.Sprintf("Remediation policy %s wants to run task tpl %d on %s for alert %s, approve: %s , reject: %s , expire at: %s",
+			policy.Name, policy.TaskTplId, run.Host, event.RuleName, approvalUrl("approve"), approvalUrl("reject"),
+			time.Unix(run.ExpireAt, 0).Format("2006-01-02 15:04:05")),
+		"policy_id":   policy.Id,
+		"policy_name": policy.Name,
+		"run_id":      run.Id,
+		"task_tpl_id": policy.TaskTplId,
+		"host":        run.Host,
+		"rule_name":   event.RuleName,
+		"severity":    event.Severity,
+		"event_hash":  event.Hash,
+		"approve_url": approvalUrl("approve"),
+		"reject_url":  approvalUrl("reject"),
+		"expire_at":   run.ExpireAt,
+	}
+
+	err := r.post(policy.ApprovalWebhook, body)
+	if err == nil {
+		return
+	}
+
+	logger.Errorf("remediation: run_id=%d failed to send approval request: %v", run.Id, err)
+	if _, err := run.Transit(r.ctx, models.RemediationStatusPendingApproval, map[string]interface{}{
+		"message": fmt.Sprintf("waiting for approval, failed to send approval request: %v", err),
+	}); err != nil {
+		logger.Errorf("remediation: run_id=%d failed to update: %v", run.Id, err)
+	}
+}
+
+func (r *Remediator) post(url string, body interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := r.client.Post(url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,234 @@
+package remediation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/memsto"
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/glebarez/sqlite"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+func newTestRemediator(t *testing.T, policies ...*models.RemediationPolicy) *Remediator {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AutoMigrate(&models.RemediationRun{}, &models.AlertCurEvent{}); err != nil {
+		t.Fatal(err)
+	}
+
+	m := make(map[int64]*models.RemediationPolicy)
+	for _, p := range policies {
+		m[p.Id] = p
+	}
+	policyCache := &memsto.RemediationPolicyCacheType{}
+	policyCache.Set(m, int64(len(m)), 0)
+
+	return NewRemediator(ctx.NewContext(context.Background(), db, true), policyCache, nil, nil, nil, &memsto.CvalCache{})
+}
+
+func testEvent(ident string) *models.AlertCurEvent {
+	return &models.AlertCurEvent{
+		Id:          1,
+		Hash:        "hash-" + ident,
+		GroupId:     1,
+		RuleId:      10,
+		Severity:    2,
+		TargetIdent: ident,
+		TagsMap:     map[string]string{"ident": ident, "service": "mysql"},
+	}
+}
+
+func runs(t *testing.T, r *Remediator, hash string) []*models.RemediationRun {
+	lst, err := models.RemediationRunsByEventHash(r.ctx, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lst
+}
+
+func TestMatch(t *testing.T) {
+	event := testEvent("host1")
+	cases := []struct {
+		policy *models.RemediationPolicy
+		want   bool
+	}{
+		{&models.RemediationPolicy{GroupId: 1}, true},
+		{&models.RemediationPolicy{GroupId: 2}, false},
+		{&models.RemediationPolicy{GroupId: 1, RuleIds: []int64{10, 11}, Severities: []int{2}}, true},
+		{&models.RemediationPolicy{GroupId: 1, Severities: []int{1}}, false},
+		{&models.RemediationPolicy{GroupId: 1, LabelFilters: []models.TagFilter{{Key: "service", Func: "==", Value: "mysql"}}}, true},
+		{&models.RemediationPolicy{GroupId: 1, LabelFilters: []models.TagFilter{{Key: "service", Func: "==", Value: "redis"}}}, false},
+	}
+
+	for i, c := range cases {
+		if got := match(c.policy, event); got != c.want {
+			t.Fatalf("case %d: expected %v, got %v", i, c.want, got)
+		}
+	}
+}
+
+func TestGuardRails(t *testing.T) {
+	policy := &models.RemediationPolicy{Id: 1, GroupId: 1, TaskTplId: 5, DryRun: true, Cooldown: 600, MaxRunsPerHour: 2}
+	r := newTestRemediator(t, policy)
+
+	// 冷却时间按机器计算，同一台机器第二次跳过
+	r.Handle(testEvent("host1"))
+	r.Handle(testEvent("host1"))
+	lst := runs(t, r, "hash-host1")
+	if len(lst) != 2 || lst[0].Status != models.RemediationStatusDryRun || lst[1].Status != models.RemediationStatusSkipped {
+		t.Fatalf("unexpected runs: %+v %+v", lst[0], lst[1])
+	}
+
+	// 每小时执行次数按策略计算，第三台机器超过上限
+	r.Handle(testEvent("host2"))
+	r.Handle(testEvent("host3"))
+	if lst := runs(t, r, "hash-host3"); len(lst) != 1 || lst[0].Status != models.RemediationStatusSkipped {
+		t.Fatalf("expected rate limited, got %+v", lst)
+	}
+
+	// 恢复事件不处理
+	event := testEvent("host4")
+	event.IsRecovered = true
+	r.Handle(event)
+	if lst := runs(t, r, "hash-host4"); len(lst) != 0 {
+		t.Fatalf("recovered event should be ignored, got %+v", lst)
+	}
+
+	// 重复通知的事件不处理
+	event = testEvent("host5")
+	event.NotifyCurNumber = 2
+	r.Handle(event)
+	if lst := runs(t, r, "hash-host5"); len(lst) != 0 {
+		t.Fatalf("repeated notification should be ignored, got %+v", lst)
+	}
+}
+
+func TestGuardRailsConcurrent(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	RegisterRedis(redis.NewClient(&redis.Options{Addr: s.Addr()}))
+	defer RegisterRedis(nil)
+
+	// 两个 center 同时处理同一个策略的事件，每小时只能执行一次
+	policy := &models.RemediationPolicy{Id: 1, GroupId: 1, TaskTplId: 5, DryRun: true, MaxRunsPerHour: 1}
+	centers := []*Remediator{newTestRemediator(t, policy), newTestRemediator(t, policy)}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			centers[i%2].Handle(testEvent(fmt.Sprintf("host%d", i)))
+		}(i)
+	}
+	wg.Wait()
+
+	count, err := models.RemediationRunCount(centers[0].ctx, policy.Id, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("expected 1 run, got %d", count)
+	}
+
+	if s.Exists(lockKeyPrefix + "1") {
+		t.Fatal("lock should be released")
+	}
+
+	// 其他 center 持有锁时等待释放
+	unlock, err := centers[0].lockPolicy(policy.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locked := make(chan struct{})
+	go func() {
+		unlock, err := centers[1].lockPolicy(policy.Id)
+		if err == nil {
+			unlock()
+		}
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("lock of another center should wait")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	unlock()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("lock should be acquired after release")
+	}
+}
+
+func TestApproval(t *testing.T) {
+	var body map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		json.NewDecoder(req.Body).Decode(&body)
+	}))
+	defer srv.Close()
+
+	policy := &models.RemediationPolicy{Id: 1, Name: "restart", GroupId: 1, TaskTplId: 5, MaxRunsPerHour: 10,
+		ApprovalRequired: true, ApprovalWebhook: srv.URL, ApprovalTimeout: 3600}
+	r := newTestRemediator(t, policy)
+
+	r.Handle(testEvent("host1"))
+	lst := runs(t, r, "hash-host1")
+	if len(lst) != 1 || lst[0].Status != models.RemediationStatusPendingApproval || lst[0].TokenHash == "" {
+		t.Fatalf("unexpected run: %+v", lst)
+	}
+
+	approveUrl, _ := body["approve_url"].(string)
+	token := strings.TrimSuffix(strings.TrimPrefix(approveUrl, "/api/n9e/remediation-approval?token="), "&action=approve")
+	if body["host"] != "host1" || token == "" || token == approveUrl {
+		t.Fatalf("unexpected approval request: %v", body)
+	}
+
+	// 数据库中只保存凭证的摘要
+	if lst[0].TokenHash != models.RemediationTokenHash(token) || lst[0].TokenHash == token {
+		t.Fatalf("unexpected token hash: %s", lst[0].TokenHash)
+	}
+
+	run, err := models.RemediationRunGetByToken(r.ctx, token)
+	if err != nil || run == nil {
+		t.Fatalf("run not found by token: %v", err)
+	}
+
+	if err := run.Approve(r.ctx, true, "root"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重复审批失败
+	if err := run.Approve(r.ctx, false, "root"); err == nil {
+		t.Fatal("expected error for approving twice")
+	}
+
+	// 审批通过时事件已恢复，不再执行
+	r.executeApproved()
+	run, _ = models.RemediationRunGetById(r.ctx, run.Id)
+	if run.Status != models.RemediationStatusSkipped || run.ApproveBy != "root" {
+		t.Fatalf("unexpected run after approval: %+v", run)
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/ccfos/nightingale/v6/alert/aconf"
 	"github.com/ccfos/nightingale/v6/alert/astats"
+	"github.com/ccfos/nightingale/v6/alert/eventchart"
 	"github.com/ccfos/nightingale/v6/alert/process"
 	"github.com/ccfos/nightingale/v6/memsto"
 	"github.com/ccfos/nightingale/v6/pkg/ctx"
@@ -39,6 +40,9 @@ func New(httpConfig httpx.Config, alert aconf.Alert, amc *memsto.AlertMuteCacheT
 }
 
 func (rt *Router) Config(r *gin.Engine) {
+	// 告警曲线图需要在 IM 客户端中直接打开，key 为随机生成，不做鉴权
+	r.GET(eventchart.ImagePath+":key", rt.eventChart)
+
 	if !rt.HTTP.APIForService.Enable {
 		return
 	}
@@ -0,0 +1,21 @@
+package router
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ccfos/nightingale/v6/alert/eventchart"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (rt *Router) eventChart(c *gin.Context) {
+	data, has := eventchart.Get(strings.TrimSuffix(c.Param("key"), ".png"))
+	if !has {
+		c.String(http.StatusNotFound, "chart not found or expired")
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Data(http.StatusOK, "image/png", data)
+}
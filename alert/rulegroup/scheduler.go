@@ -0,0 +1,211 @@
+package rulegroup
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/alert/aconf"
+	"github.com/ccfos/nightingale/v6/alert/astats"
+	"github.com/ccfos/nightingale/v6/alert/eval"
+	"github.com/ccfos/nightingale/v6/alert/naming"
+	"github.com/ccfos/nightingale/v6/alert/process"
+	"github.com/ccfos/nightingale/v6/alert/record"
+	"github.com/ccfos/nightingale/v6/memsto"
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+	"github.com/ccfos/nightingale/v6/prom"
+	"github.com/ccfos/nightingale/v6/pushgw/writer"
+
+	"github.com/toolkits/pkg/logger"
+)
+
+// Scheduler 同步启用的规则组，每个规则组作为一个整体按 DatasourceId 在 alert 实例间分片
+type Scheduler struct {
+	// key: hash
+	workers map[string]*Worker
+
+	aconf aconf.Alert
+
+	ruleGroupCache          *memsto.RuleGroupCacheType
+	alertRuleCache          *memsto.AlertRuleCacheType
+	recordingRuleCache      *memsto.RecordingRuleCacheType
+	targetCache             *memsto.TargetCacheType
+	targetsOfAlertRuleCache *memsto.TargetsOfAlertRuleCacheType
+	busiGroupCache          *memsto.BusiGroupCacheType
+	alertMuteCache          *memsto.AlertMuteCacheType
+	datasourceCache         *memsto.DatasourceCacheType
+
+	promClients *prom.PromClientMap
+	writers     *writer.WritersType
+
+	ctx   *ctx.Context
+	stats *astats.Stats
+}
+
+func NewScheduler(aconf aconf.Alert, rgc *memsto.RuleGroupCacheType, arc *memsto.AlertRuleCacheType, rrc *memsto.RecordingRuleCacheType,
+	targetCache *memsto.TargetCacheType, toarc *memsto.TargetsOfAlertRuleCacheType, busiGroupCache *memsto.BusiGroupCacheType,
+	alertMuteCache *memsto.AlertMuteCacheType, datasourceCache *memsto.DatasourceCacheType, promClients *prom.PromClientMap,
+	writers *writer.WritersType, ctx *ctx.Context, stats *astats.Stats) *Scheduler {
+	scheduler := &Scheduler{
+		workers: make(map[string]*Worker),
+
+		aconf: aconf,
+
+		ruleGroupCache:          rgc,
+		alertRuleCache:          arc,
+		recordingRuleCache:      rrc,
+		targetCache:             targetCache,
+		targetsOfAlertRuleCache: toarc,
+		busiGroupCache:          busiGroupCache,
+		alertMuteCache:          alertMuteCache,
+		datasourceCache:         datasourceCache,
+
+		promClients: promClients,
+		writers:     writers,
+
+		ctx:   ctx,
+		stats: stats,
+	}
+
+	go scheduler.LoopSyncRuleGroups(context.Background())
+	return scheduler
+}
+
+func (s *Scheduler) LoopSyncRuleGroups(ctx context.Context) {
+	time.Sleep(time.Duration(s.aconf.EngineDelay) * time.Second)
+	duration := 9000 * time.Millisecond
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(duration):
+			s.syncRuleGroups()
+		}
+	}
+}
+
+func (s *Scheduler) syncRuleGroups() {
+	workers := make(map[string]*Worker)
+	for _, id := range s.ruleGroupCache.GetGroupIds() {
+		group := s.ruleGroupCache.Get(id)
+		if group == nil {
+			continue
+		}
+
+		if !naming.DatasourceHashRing.IsHit(strconv.FormatInt(group.DatasourceId, 10), fmt.Sprintf("rule-group-%d", group.Id), s.aconf.Heartbeat.Endpoint) {
+			continue
+		}
+
+		members := s.members(group)
+		if len(members) == 0 {
+			continue
+		}
+
+		worker := NewWorker(group, members, s.stats)
+		workers[worker.Hash()] = worker
+	}
+
+	for hash, worker := range workers {
+		if _, has := s.workers[hash]; !has {
+			worker.Prepare()
+			worker.Start()
+			s.workers[hash] = worker
+		}
+	}
+
+	for hash, worker := range s.workers {
+		if _, has := workers[hash]; !has {
+			worker.Stop()
+			delete(s.workers, hash)
+		}
+	}
+}
+
+// members 按组内顺序创建规则的执行单元，已删除或禁用的规则跳过
+func (s *Scheduler) members(group *models.RuleGroup) []member {
+	cronPattern := fmt.Sprintf("@every %ds", group.Interval)
+
+	var lst []member
+	for _, m := range group.Rules {
+		switch m.Type {
+		case models.RuleGroupMemberRecording:
+			cached := s.recordingRuleCache.Get(m.Id)
+			if cached == nil {
+				continue
+			}
+
+			// 缓存中的规则是共享的，使用副本覆盖调度周期
+			rule := *cached
+			rule.CronPattern = cronPattern
+
+			if strings.TrimSpace(rule.PromQl) != "" && s.datasourceMatched(models.PROMETHEUS, rule.DatasourceQueries, group.DatasourceId) {
+				lst = append(lst, record.NewRecordRuleContext(&rule, group.DatasourceId, s.promClients, s.writers, s.stats))
+			}
+
+			for i, config := range rule.QueryConfigsJson {
+				if len(config.Queries) == 0 || config.WriteDatasourceId <= 0 {
+					continue
+				}
+				lst = append(lst, record.NewQueryRecordRuleContext(&rule, i, s.datasourceCache, s.promClients, s.stats))
+			}
+		case models.RuleGroupMemberAlert:
+			cached := s.alertRuleCache.Get(m.Id)
+			if cached == nil || !eval.IsGroupable(cached) {
+				continue
+			}
+
+			rule := *cached
+			rule.CronPattern = cronPattern
+
+			for _, dsId := range s.alertRuleDatasourceIds(&rule, group) {
+				processor := process.NewProcessor(s.aconf.Heartbeat.EngineName, &rule, dsId, s.alertRuleCache, s.targetCache, s.targetsOfAlertRuleCache,
+					s.busiGroupCache, s.alertMuteCache, s.datasourceCache, s.ctx, s.stats)
+				lst = append(lst, eval.NewAlertRuleWorker(&rule, dsId, processor, s.promClients, s.ctx))
+			}
+		}
+	}
+
+	return lst
+}
+
+// alertRuleDatasourceIds host 规则和跨数据源规则不绑定数据源；prometheus 规则只在规则组的数据源上执行；
+// 其他数据源类型的规则在其匹配的所有数据源上执行
+func (s *Scheduler) alertRuleDatasourceIds(rule *models.AlertRule, group *models.RuleGroup) []int64 {
+	if rule.IsHostRule() || rule.IsMixedRule() {
+		return []int64{0}
+	}
+
+	var dsIds []int64
+	if rule.IsPrometheusRule() {
+		if s.datasourceMatched(rule.Cate, rule.DatasourceQueries, group.DatasourceId) {
+			dsIds = []int64{group.DatasourceId}
+		}
+	} else {
+		dsIds = s.datasourceCache.GetIDsByDsCateAndQueries(rule.Cate, rule.DatasourceQueries)
+	}
+
+	ruleType := rule.GetRuleType()
+	ret := make([]int64, 0, len(dsIds))
+	for _, dsId := range dsIds {
+		ds := s.datasourceCache.GetById(dsId)
+		if ds == nil || ds.PluginType != ruleType || ds.Status != "enabled" {
+			logger.Debugf("rule_group:%d datasource %d of alert rule %d is not available", group.Id, dsId, rule.Id)
+			continue
+		}
+		ret = append(ret, dsId)
+	}
+
+	return ret
+}
+
+func (s *Scheduler) datasourceMatched(cate string, queries []models.DatasourceQuery, dsId int64) bool {
+	for _, id := range s.datasourceCache.GetIDsByDsCateAndQueries(cate, queries) {
+		if id == dsId {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package rulegroup
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/alert/astats"
+	"github.com/ccfos/nightingale/v6/models"
+
+	"github.com/robfig/cron/v3"
+	"github.com/toolkits/pkg/logger"
+	"github.com/toolkits/pkg/str"
+)
+
+// member 组内的一条规则，由 Worker 同步调用 Eval，不启动规则自己的 cron
+// eval.AlertRuleWorker、record.RecordRuleContext、record.QueryRecordRuleContext 都实现了该接口
+type member interface {
+	Key() string
+	Hash() string
+	Prepare()
+	Eval()
+	Stop()
+}
+
+// Worker 按规则组的间隔，依次执行组内的规则，上一条规则执行完成后才执行下一条
+type Worker struct {
+	group   *models.RuleGroup
+	members []member
+	stats   *astats.Stats
+
+	scheduler *cron.Cron
+}
+
+func NewWorker(group *models.RuleGroup, members []member, stats *astats.Stats) *Worker {
+	w := &Worker{
+		group:   group,
+		members: members,
+		stats:   stats,
+	}
+
+	// 执行时间超过间隔时跳过下一次执行，记录为 missed iteration
+	w.scheduler = cron.New(cron.WithSeconds(), cron.WithChain(cron.SkipIfStillRunning(cron.DefaultLogger)))
+	_, err := w.scheduler.AddFunc(fmt.Sprintf("@every %ds", group.Interval), func() {
+		w.Eval()
+	})
+
+	if err != nil {
+		logger.Errorf("rule_group:%d add cron pattern error: %v", group.Id, err)
+	}
+
+	return w
+}
+
+func (w *Worker) Key() string {
+	return fmt.Sprintf("rule-group-%d", w.group.Id)
+}
+
+// Hash 规则组或组内任意规则有变化时都会变化，调度器据此重建 Worker
+func (w *Worker) Hash() string {
+	hashes := make([]string, 0, len(w.members))
+	for _, m := range w.members {
+		hashes = append(hashes, m.Hash())
+	}
+
+	return str.MD5(fmt.Sprintf("%d_%d_%d_%s",
+		w.group.Id,
+		w.group.DatasourceId,
+		w.group.Interval,
+		strings.Join(hashes, "_"),
+	))
+}
+
+func (w *Worker) Prepare() {
+	for _, m := range w.members {
+		m.Prepare()
+	}
+}
+
+func (w *Worker) Start() {
+	logger.Infof("rule_group:%s started, members:%d", w.Key(), len(w.members))
+	w.scheduler.Start()
+}
+
+func (w *Worker) Eval() {
+	groupId := fmt.Sprintf("%d", w.group.Id)
+	w.stats.CounterRuleGroupEval.WithLabelValues(groupId).Inc()
+
+	begin := time.Now()
+	for _, m := range w.members {
+		m.Eval()
+	}
+	duration := time.Since(begin)
+
+	w.stats.GaugeRuleGroupEvalDuration.WithLabelValues(groupId).Set(float64(duration.Milliseconds()))
+
+	interval := time.Duration(w.group.Interval) * time.Second
+	if interval > 0 && duration > interval {
+		missed := int64(duration / interval)
+		w.stats.CounterRuleGroupMissedIterations.WithLabelValues(groupId).Add(float64(missed))
+		logger.Warningf("rule_group:%s eval took %v, longer than interval %v, %d iterations missed", w.Key(), duration, interval, missed)
+		return
+	}
+
+	logger.Debugf("rule_group:%s finished, duration:%v", w.Key(), duration)
+}
+
+func (w *Worker) Stop() {
+	logger.Infof("rule_group:%s stopped", w.Key())
+
+	c := w.scheduler.Stop()
+	<-c.Done()
+
+	for _, m := range w.members {
+		m.Stop()
+	}
+}
@@ -0,0 +1,75 @@
+package rulegroup
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/alert/astats"
+	"github.com/ccfos/nightingale/v6/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type fakeMember struct {
+	id    int
+	sleep time.Duration
+	order *[]int
+}
+
+func (m *fakeMember) Key() string  { return fmt.Sprintf("fake-%d", m.id) }
+func (m *fakeMember) Hash() string { return m.Key() }
+func (m *fakeMember) Prepare()     {}
+func (m *fakeMember) Stop()        {}
+
+func (m *fakeMember) Eval() {
+	time.Sleep(m.sleep)
+	*m.order = append(*m.order, m.id)
+}
+
+func testStats() *astats.Stats {
+	return &astats.Stats{
+		CounterRuleGroupEval:             prometheus.NewCounterVec(prometheus.CounterOpts{Name: "eval"}, []string{"rule_group_id"}),
+		CounterRuleGroupMissedIterations: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "missed"}, []string{"rule_group_id"}),
+		GaugeRuleGroupEvalDuration:       prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "duration"}, []string{"rule_group_id"}),
+	}
+}
+
+func TestWorkerEvalOrder(t *testing.T) {
+	var order []int
+	members := []member{
+		&fakeMember{id: 1, sleep: 20 * time.Millisecond, order: &order},
+		&fakeMember{id: 2, order: &order},
+		&fakeMember{id: 3, sleep: 10 * time.Millisecond, order: &order},
+	}
+
+	stats := testStats()
+	w := NewWorker(&models.RuleGroup{Id: 1, Interval: 60}, members, stats)
+	w.Eval()
+
+	if fmt.Sprint(order) != "[1 2 3]" {
+		t.Fatalf("unexpected eval order: %v", order)
+	}
+
+	if v := testutil.ToFloat64(stats.CounterRuleGroupEval.WithLabelValues("1")); v != 1 {
+		t.Fatalf("unexpected eval count: %v", v)
+	}
+
+	if v := testutil.ToFloat64(stats.CounterRuleGroupMissedIterations.WithLabelValues("1")); v != 0 {
+		t.Fatalf("unexpected missed iterations: %v", v)
+	}
+}
+
+func TestWorkerMissedIterations(t *testing.T) {
+	var order []int
+	members := []member{&fakeMember{id: 1, sleep: 2100 * time.Millisecond, order: &order}}
+
+	stats := testStats()
+	w := NewWorker(&models.RuleGroup{Id: 2, Interval: 1}, members, stats)
+	w.Eval()
+
+	if v := testutil.ToFloat64(stats.CounterRuleGroupMissedIterations.WithLabelValues("2")); v != 2 {
+		t.Fatalf("unexpected missed iterations: %v", v)
+	}
+}
@@ -154,7 +154,13 @@ func NotifyRecord(ctx *ctx.Context, evts []*models.AlertCurEvent, notifyRuleID i
 	}
 
 	if !ctx.IsCenter {
-		err := poster.PostByUrls(ctx, "/v1/n9e/notify-record", notis)
+		var err error
+		if referSpooledEvent(notis) {
+			// 事件暂存在本地还没有落库，通知记录也要排在事件之后重放，中心端才能找到事件 id
+			err = poster.SpoolByUrls(ctx, "/v1/n9e/notify-record", "", notis)
+		} else {
+			err = poster.PostByUrls(ctx, "/v1/n9e/notify-record", notis)
+		}
 		if err != nil {
 			logger.Errorf("add notis:%v failed, err: %v", notis, err)
 		}
@@ -164,6 +170,15 @@ func NotifyRecord(ctx *ctx.Context, evts []*models.AlertCurEvent, notifyRuleID i
 	PushNotifyRecords(notis)
 }
 
+func referSpooledEvent(notis []*models.NotificationRecord) bool {
+	for _, noti := range notis {
+		if noti.EventHash != "" {
+			return true
+		}
+	}
+	return false
+}
+
 func doSend(url string, body interface{}, channel string, stats *astats.Stats) (string, error) {
 	stats.AlertNotifyTotal.WithLabelValues(channel).Inc()
 
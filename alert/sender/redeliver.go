@@ -0,0 +1,201 @@
+package sender
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+
+	"github.com/toolkits/pkg/logger"
+)
+
+const (
+	redeliverBatchSize   = 100
+	redeliverScanPeriod  = 10 * time.Second
+	redeliverClaimLease  = 300 // 认领后在 lease 秒内其他实例不会重复发送
+	defaultRedeliverMax  = 10
+	defaultRedeliverBase = 30 * time.Second
+	defaultRedeliverCap  = time.Hour
+)
+
+// Redeliverer 周期性扫描到期的通知死信并重新发送，状态保存在数据库中，进程重启后会继续重投
+type Redeliverer struct {
+	ctx          *ctx.Context
+	maxAttempts  int
+	baseInterval time.Duration
+	maxInterval  time.Duration
+}
+
+// NewRedeliverer baseInterval、maxInterval 单位为秒，传 0 使用默认值
+func NewRedeliverer(ctx *ctx.Context, maxAttempts, baseInterval, maxInterval int) *Redeliverer {
+	r := &Redeliverer{
+		ctx:          ctx,
+		maxAttempts:  maxAttempts,
+		baseInterval: time.Duration(baseInterval) * time.Second,
+		maxInterval:  time.Duration(maxInterval) * time.Second,
+	}
+
+	if r.maxAttempts <= 0 {
+		r.maxAttempts = defaultRedeliverMax
+	}
+
+	if r.baseInterval <= 0 {
+		r.baseInterval = defaultRedeliverBase
+	}
+
+	if r.maxInterval <= 0 {
+		r.maxInterval = defaultRedeliverCap
+	}
+
+	return r
+}
+
+func (r *Redeliverer) Start() {
+	go func() {
+		for {
+			r.redeliverDue()
+			time.Sleep(redeliverScanPeriod)
+		}
+	}()
+}
+
+// Backoff 第 attempts 次失败后的等待时间，指数增长，不超过 maxInterval
+func (r *Redeliverer) Backoff(attempts int) time.Duration {
+	d := r.baseInterval
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= r.maxInterval {
+			return r.maxInterval
+		}
+	}
+	return d
+}
+
+func (r *Redeliverer) redeliverDue() {
+	lst, err := models.NotifyDeadLetterDue(r.ctx, time.Now().Unix(), redeliverBatchSize)
+	if err != nil {
+		logger.Errorf("failed to query notify dead letters: %v", err)
+		return
+	}
+
+	clients := make(map[int64]*http.Client)
+	for _, dl := range lst {
+		ok, err := dl.Claim(r.ctx, redeliverClaimLease)
+		if err != nil {
+			logger.Errorf("failed to claim notify dead letter %d: %v", dl.Id, err)
+			continue
+		}
+
+		if !ok {
+			// 已被其他实例处理
+			continue
+		}
+
+		r.Redeliver(dl, clients, false)
+	}
+}
+
+// Redeliver 重新发送一条死信并写入通知记录；manual 表示手动重发，失败时不计入自动重投的退避
+func (r *Redeliverer) Redeliver(dl *models.NotifyDeadLetter, clients map[int64]*http.Client, manual bool) error {
+	resp, err := r.send(dl, clients)
+	NotifyRecord(r.ctx, dl.AlertEvents(), dl.NotifyRuleId, dl.ChannelName, dl.Target, resp, err)
+
+	dl.Attempts++
+	if err == nil {
+		dl.Status = models.DeadLetterStatusSucceeded
+		dl.SetError("")
+	} else {
+		dl.SetError(err.Error())
+		if manual {
+			dl.Status = models.DeadLetterStatusPending
+			dl.NextRetryAt = time.Now().Add(r.Backoff(1)).Unix()
+		} else if dl.Attempts >= r.maxAttempts {
+			dl.Status = models.DeadLetterStatusExhausted
+		} else {
+			dl.NextRetryAt = time.Now().Add(r.Backoff(dl.Attempts)).Unix()
+		}
+	}
+
+	if uerr := dl.UpdateResult(r.ctx); uerr != nil {
+		logger.Errorf("failed to update notify dead letter %d: %v", dl.Id, uerr)
+	}
+
+	logger.Infof("redeliver notify dead letter id:%d notify_id:%d channel:%s target:%s attempts:%d status:%s err:%v",
+		dl.Id, dl.NotifyRuleId, dl.ChannelName, dl.Target, dl.Attempts, dl.Status, err)
+	return err
+}
+
+// Resend 手动重发，先认领记录，避免和后台重投或其他人的手动重发同时发送
+func (r *Redeliverer) Resend(dl *models.NotifyDeadLetter, clients map[int64]*http.Client) error {
+	ok, err := dl.Claim(r.ctx, redeliverClaimLease)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return fmt.Errorf("dead letter is being redelivered, try again later")
+	}
+
+	return r.Redeliver(dl, clients, true)
+}
+
+func (r *Redeliverer) send(dl *models.NotifyDeadLetter, clients map[int64]*http.Client) (string, error) {
+	if dl.Request == nil && dl.Message == nil {
+		return "", fmt.Errorf("request payload is empty")
+	}
+
+	if err := dl.DecryptSecret(r.ctx); err != nil {
+		return "", err
+	}
+
+	lst, err := models.NotifyChannelGets(r.ctx, dl.ChannelId, "", "", -1)
+	if err != nil {
+		return "", err
+	}
+
+	if len(lst) == 0 {
+		return "", fmt.Errorf("notify channel %d not found", dl.ChannelId)
+	}
+
+	channel := lst[0]
+	if !channel.Enable {
+		return "", fmt.Errorf("notify channel %s is disabled", channel.Name)
+	}
+
+	if (channel.RequestType == "http") != (dl.Request != nil) {
+		return "", fmt.Errorf("notify channel %s request type changed to %s", channel.Name, channel.RequestType)
+	}
+
+	var client *http.Client
+	switch channel.RequestType {
+	case "http", "flashduty", "pagerduty":
+		var has bool
+		client, has = clients[channel.ID]
+		if !has {
+			client, err = models.GetHTTPClient(channel)
+			if err != nil {
+				return "", err
+			}
+			clients[channel.ID] = client
+		}
+	}
+
+	msg := dl.Message
+	switch channel.RequestType {
+	case "http":
+		return channel.SendHTTPRequest(dl.Request, client)
+	case "flashduty":
+		return channel.SendFlashDuty(msg.Events, msg.FlashDutyChannelId, client)
+	case "pagerduty":
+		return channel.SendPagerDuty(msg.Events, msg.RoutingKey, msg.SiteUrl, client)
+	case "smtp":
+		return "", channel.SendEmailNow(msg.Events, msg.TplContent, msg.Sendtos)
+	case "script":
+		_, res, err := channel.SendScript(msg.Events, msg.TplContent, msg.CustomParams, msg.Sendtos)
+		return res, err
+	default:
+		return "", fmt.Errorf("notify channel %s request type %s not supported", channel.Name, channel.RequestType)
+	}
+}
@@ -0,0 +1,239 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/memsto"
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestRedelivererBackoff(t *testing.T) {
+	r := NewRedeliverer(nil, 0, 10, 60)
+
+	cases := map[int]time.Duration{
+		1: 10 * time.Second,
+		2: 20 * time.Second,
+		3: 40 * time.Second,
+		4: 60 * time.Second,
+		9: 60 * time.Second,
+	}
+
+	for attempts, want := range cases {
+		if got := r.Backoff(attempts); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+
+	if r.maxAttempts != defaultRedeliverMax {
+		t.Errorf("maxAttempts = %d, want %d", r.maxAttempts, defaultRedeliverMax)
+	}
+}
+
+func newRedeliverCtx(t *testing.T, url string) (*ctx.Context, *models.NotifyChannelConfig) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sqlite 只有 integer 主键才会自增，先建表再补齐其他列
+	if err := db.Exec("create table notify_dead_letter (id integer primary key autoincrement)").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AutoMigrate(&models.Configs{}, &models.NotifyChannelConfig{}, &models.NotifyDeadLetter{}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := ctx.NewContext(context.Background(), db, true)
+	channel := &models.NotifyChannelConfig{
+		Name:        "webhook",
+		Ident:       "webhook",
+		RequestType: "http",
+		Enable:      true,
+		RequestConfig: &models.RequestConfig{
+			HTTPRequestConfig: &models.HTTPRequestConfig{URL: url, Method: "POST", Timeout: 1000, RetryTimes: 1},
+		},
+	}
+	if err := db.Create(channel).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return c, channel
+}
+
+func newHTTPDeadLetter(t *testing.T, c *ctx.Context, channel *models.NotifyChannelConfig) *models.NotifyDeadLetter {
+	events := []*models.AlertCurEvent{{Id: 1, Hash: "h1"}}
+	req := &models.HTTPNotifyRequest{
+		Url:        channel.RequestConfig.HTTPRequestConfig.URL,
+		Headers:    map[string]string{"Authorization": "Bearer secret-token"},
+		Parameters: map[string]string{"access_token": "secret-param"},
+		Body:       `{"text":"cpu high"}`,
+	}
+
+	dl := models.NewNotifyDeadLetter(events, 1, channel, "ops", req, nil, errors.New("connection refused"))
+	if err := dl.Add(c); err != nil {
+		t.Fatal(err)
+	}
+
+	return dl
+}
+
+func TestRedeliverDeadLetter(t *testing.T) {
+	var auth, token atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth.Store(r.Header.Get("Authorization"))
+		token.Store(r.URL.Query().Get("access_token"))
+	}))
+	defer srv.Close()
+
+	c, channel := newRedeliverCtx(t, srv.URL)
+	dl := newHTTPDeadLetter(t, c, channel)
+
+	// 鉴权信息只能以密文保存
+	var raw struct {
+		Request string
+		Secret  string
+	}
+	if err := models.DB(c).Table("notify_dead_letter").Select("request", "secret").Where("id = ?", dl.Id).Scan(&raw).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"secret-token", "secret-param"} {
+		if strings.Contains(raw.Request, s) || strings.Contains(raw.Secret, s) {
+			t.Fatalf("%s stored in plaintext: request=%s secret=%s", s, raw.Request, raw.Secret)
+		}
+	}
+	if raw.Secret == "" {
+		t.Fatal("secret not saved")
+	}
+
+	NewRedeliverer(c, 3, 1, 1).redeliverDue()
+
+	if got, _ := auth.Load().(string); got != "Bearer secret-token" {
+		t.Fatalf("Authorization = %q", got)
+	}
+	if got, _ := token.Load().(string); got != "secret-param" {
+		t.Fatalf("access_token = %q", got)
+	}
+
+	lst, err := models.NotifyDeadLetterGetsByIds(c, []int64{dl.Id})
+	if err != nil || len(lst) != 1 {
+		t.Fatalf("get dead letter: %v %v", lst, err)
+	}
+	if lst[0].Status != models.DeadLetterStatusSucceeded || lst[0].Attempts != 1 {
+		t.Fatalf("status = %s, attempts = %d", lst[0].Status, lst[0].Attempts)
+	}
+}
+
+func TestRedeliverDeadLetterExhausted(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	c, channel := newRedeliverCtx(t, srv.URL)
+	dl := newHTTPDeadLetter(t, c, channel)
+
+	r := NewRedeliverer(c, 2, 1, 1)
+	for i := 0; i < 2; i++ {
+		if err := models.DB(c).Model(&models.NotifyDeadLetter{}).Where("id = ?", dl.Id).Update("next_retry_at", 0).Error; err != nil {
+			t.Fatal(err)
+		}
+		r.redeliverDue()
+	}
+
+	lst, err := models.NotifyDeadLetterGetsByIds(c, []int64{dl.Id})
+	if err != nil || len(lst) != 1 {
+		t.Fatalf("get dead letter: %v %v", lst, err)
+	}
+	if lst[0].Status != models.DeadLetterStatusExhausted || lst[0].Attempts != 2 {
+		t.Fatalf("status = %s, attempts = %d", lst[0].Status, lst[0].Attempts)
+	}
+}
+
+func TestResendClaim(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer srv.Close()
+
+	c, channel := newRedeliverCtx(t, srv.URL)
+	newHTTPDeadLetter(t, c, channel)
+
+	lst, err := models.NotifyDeadLetterDue(c, time.Now().Unix(), 10)
+	if err != nil || len(lst) != 1 {
+		t.Fatalf("due dead letters: %v %v", lst, err)
+	}
+
+	// 手动重发和后台重投读到同一条记录，只有先认领的一方会发送
+	stale := *lst[0]
+	r := NewRedeliverer(c, 3, 1, 1)
+	if err := r.Resend(lst[0], make(map[int64]*http.Client)); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := stale.Claim(c, redeliverClaimLease); err != nil || ok {
+		t.Fatalf("stale claim = %v, %v", ok, err)
+	}
+
+	if err := r.Resend(&stale, make(map[int64]*http.Client)); err == nil {
+		t.Fatal("resend of a claimed dead letter should fail")
+	}
+
+	if n := atomic.LoadInt32(&hits); n != 1 {
+		t.Fatalf("sent %d times", n)
+	}
+}
+
+func TestSaveNotifyDeadLetterMessage(t *testing.T) {
+	c, channel := newRedeliverCtx(t, "http://127.0.0.1")
+	channel.RequestType = "script"
+
+	events := []*models.AlertCurEvent{{Id: 2, Hash: "h2", RuleName: "disk full"}}
+	task := &memsto.NotifyTask{Events: events, NotifyRuleId: 3, NotifyChannel: channel}
+
+	// 发送成功不保存
+	memsto.SaveNotifyDeadLetter(c, task, &memsto.NotifyResult{Target: "ops", Message: &models.DeadLetterMessage{Events: events}})
+
+	memsto.SaveNotifyDeadLetter(c, task, &memsto.NotifyResult{
+		Target: "ops",
+		Err:    errors.New("exit status 1"),
+		Message: &models.DeadLetterMessage{
+			Events:       events,
+			TplContent:   map[string]interface{}{"content": "disk full"},
+			Sendtos:      []string{"ops"},
+			CustomParams: map[string]string{"token": "secret-param"},
+		},
+	})
+
+	lst, err := models.NotifyDeadLetterGets(c, 3, "", "", 10, 0)
+	if err != nil || len(lst) != 1 {
+		t.Fatalf("get dead letters: %v %v", lst, err)
+	}
+
+	dl := lst[0]
+	if dl.Request != nil || dl.Message == nil || len(dl.Message.Events) != 1 || dl.Message.Events[0].RuleName != "disk full" {
+		t.Fatalf("unexpected payload: %+v", dl)
+	}
+	if dl.Message.CustomParams != nil || strings.Contains(dl.Secret, "secret-param") {
+		t.Fatalf("custom params stored in plaintext: %+v %s", dl.Message.CustomParams, dl.Secret)
+	}
+
+	if err := dl.DecryptSecret(c); err != nil {
+		t.Fatal(err)
+	}
+	if dl.Message.CustomParams["token"] != "secret-param" {
+		t.Fatalf("custom params = %v", dl.Message.CustomParams)
+	}
+}
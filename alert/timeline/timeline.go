@@ -0,0 +1,105 @@
+package timeline
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+
+	"github.com/toolkits/pkg/container/list"
+	"github.com/toolkits/pkg/logger"
+)
+
+// 同一个事件的相同记录在该时间内只保存一次，避免每个评估周期都记录一次屏蔽、抑制，unit: s
+const dedupWindow = 1800
+
+// 事件时间线队列，最大长度 100000
+var Queue = list.NewSafeListLimited(100000)
+
+var (
+	lock     sync.Mutex
+	recorded = make(map[string]int64)
+	sweepAt  int64
+)
+
+// Record 记录告警引擎对事件的处理，如屏蔽、抑制等，异步保存
+func Record(event *models.AlertCurEvent, typ, message string) {
+	entry := models.NewEventTimeline(event, typ, message)
+	if !shouldRecord(entry) {
+		return
+	}
+
+	if ok := Queue.PushFront(entry); !ok {
+		logger.Warningf("event timeline queue is full, entry: %+v", entry)
+	}
+}
+
+// Push 保存边缘机房上报的时间线条目，边缘机房已经去重
+// 若队列满 则返回 error
+func Push(lst []*models.EventTimeline) error {
+	for _, entry := range lst {
+		if ok := Queue.PushFront(entry); !ok {
+			logger.Warningf("event timeline queue is full, entry: %+v", entry)
+			return errors.New("event timeline queue is full")
+		}
+	}
+
+	return nil
+}
+
+func shouldRecord(entry *models.EventTimeline) bool {
+	key := entry.EventHash + "|" + entry.Type + "|" + entry.Message
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if entry.CreateAt-sweepAt > dedupWindow {
+		for k, t := range recorded {
+			if entry.CreateAt-t >= dedupWindow {
+				delete(recorded, k)
+			}
+		}
+		sweepAt = entry.CreateAt
+	}
+
+	if t, has := recorded[key]; has && entry.CreateAt-t < dedupWindow {
+		return false
+	}
+
+	recorded[key] = entry.CreateAt
+	return true
+}
+
+type Consumer struct {
+	ctx *ctx.Context
+}
+
+func NewConsumer(ctx *ctx.Context) *Consumer {
+	return &Consumer{
+		ctx: ctx,
+	}
+}
+
+// LoopConsume 每 100ms 检测一次队列，边缘机房通过中心端保存
+func (c *Consumer) LoopConsume() {
+	duration := time.Duration(100) * time.Millisecond
+	for {
+		time.Sleep(duration)
+
+		items := Queue.PopBackBy(100)
+		if len(items) == 0 {
+			continue
+		}
+
+		lst := make([]*models.EventTimeline, 0, len(items))
+		for _, item := range items {
+			lst = append(lst, item.(*models.EventTimeline))
+		}
+
+		if err := models.EventTimelineAdds(c.ctx, lst); err != nil {
+			logger.Errorf("failed to add event timelines: %v", err)
+		}
+	}
+}
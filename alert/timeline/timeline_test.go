@@ -0,0 +1,32 @@
+package timeline
+
+import (
+	"testing"
+
+	"github.com/ccfos/nightingale/v6/models"
+)
+
+func TestShouldRecord(t *testing.T) {
+	entry := &models.EventTimeline{EventHash: "h1", Type: models.TimelineTypeMute, Message: "muted by alert mute 1", CreateAt: 10000}
+	if !shouldRecord(entry) {
+		t.Fatal("first entry should be recorded")
+	}
+
+	dup := *entry
+	dup.CreateAt += 60
+	if shouldRecord(&dup) {
+		t.Fatal("duplicated entry within window should be skipped")
+	}
+
+	other := dup
+	other.Message = "muted by alert mute 2"
+	if !shouldRecord(&other) {
+		t.Fatal("entry with different message should be recorded")
+	}
+
+	later := *entry
+	later.CreateAt += dedupWindow
+	if !shouldRecord(&later) {
+		t.Fatal("entry after window should be recorded again")
+	}
+}
@@ -0,0 +1,169 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/center/cconf"
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+	"github.com/ccfos/nightingale/v6/pushgw/kafka"
+
+	"github.com/IBM/sarama"
+	"github.com/toolkits/pkg/logger"
+)
+
+const exportQueueSize = 10000
+
+// Auditor 负责持久化审计日志，并按配置异步导出到 webhook 或 kafka
+type Auditor struct {
+	ctx  *ctx.Context
+	conf cconf.Audit
+
+	queue    chan *models.AuditLog
+	client   *http.Client
+	producer kafka.Producer
+}
+
+func New(ctx *ctx.Context, conf cconf.Audit) *Auditor {
+	a := &Auditor{
+		ctx:  ctx,
+		conf: conf,
+	}
+
+	if !conf.Enable {
+		return a
+	}
+
+	if conf.Webhook.Enable && conf.Webhook.Url != "" {
+		timeout := conf.Webhook.Timeout * time.Millisecond
+		if timeout == 0 {
+			timeout = 5 * time.Second
+		}
+		a.client = &http.Client{Timeout: timeout}
+	}
+
+	if conf.Kafka.Enable && len(conf.Kafka.Brokers) > 0 && conf.Kafka.Topic != "" {
+		producer, err := newKafkaProducer(conf.Kafka)
+		if err != nil {
+			logger.Errorf("audit: failed to init kafka producer: %v", err)
+		} else {
+			a.producer = producer
+		}
+	}
+
+	if a.client != nil || a.producer != nil {
+		a.queue = make(chan *models.AuditLog, exportQueueSize)
+		go a.loopExport()
+	}
+
+	return a
+}
+
+func newKafkaProducer(opt cconf.AuditKafka) (kafka.Producer, error) {
+	cfg := sarama.NewConfig()
+	if opt.Timeout != 0 {
+		cfg.Producer.Timeout = time.Duration(opt.Timeout) * time.Second
+	}
+
+	if opt.Version != "" {
+		kafkaVersion, err := sarama.ParseKafkaVersion(opt.Version)
+		if err != nil {
+			logger.Warningf("audit: parse kafka version got error: %v", err)
+		} else {
+			cfg.Version = kafkaVersion
+		}
+	}
+
+	typ := opt.Typ
+	if typ == "" {
+		typ = kafka.AsyncProducer
+	}
+
+	return kafka.New(typ, opt.Brokers, cfg)
+}
+
+func (a *Auditor) Enabled() bool {
+	return a != nil && a.conf.Enable
+}
+
+// Record 写入审计日志，写库失败只记录错误日志，不影响业务请求
+func (a *Auditor) Record(log *models.AuditLog) {
+	if !a.Enabled() || log == nil {
+		return
+	}
+
+	if log.CreatedAt == 0 {
+		log.CreatedAt = time.Now().Unix()
+	}
+
+	if err := log.Add(a.ctx); err != nil {
+		logger.Errorf("audit: failed to add audit log: %+v, error: %v", log, err)
+	}
+
+	if a.queue == nil {
+		return
+	}
+
+	select {
+	case a.queue <- log:
+	default:
+		logger.Warningf("audit: export queue is full, drop audit log id:%d", log.Id)
+	}
+}
+
+func (a *Auditor) loopExport() {
+	for log := range a.queue {
+		body, err := json.Marshal(log)
+		if err != nil {
+			logger.Errorf("audit: failed to marshal audit log id:%d error: %v", log.Id, err)
+			continue
+		}
+
+		if a.client != nil {
+			if err := a.exportWebhook(body); err != nil {
+				logger.Errorf("audit: failed to export audit log id:%d to webhook: %v", log.Id, err)
+			}
+		}
+
+		if a.producer != nil {
+			msg := &sarama.ProducerMessage{
+				Topic: a.conf.Kafka.Topic,
+				Key:   sarama.StringEncoder(log.ResourceType),
+				Value: sarama.ByteEncoder(body),
+			}
+			if err := a.producer.Send(msg); err != nil {
+				logger.Errorf("audit: failed to export audit log id:%d to kafka: %v", log.Id, err)
+			}
+		}
+	}
+}
+
+func (a *Auditor) exportWebhook(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, a.conf.Webhook.Url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range a.conf.Webhook.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("status code: %d, body: %s", resp.StatusCode, string(respBody))
+	}
+
+	return nil
+}
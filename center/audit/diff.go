@@ -0,0 +1,161 @@
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const redactedValue = "******"
+
+// 审计日志中需要脱敏的字段，按字段名（小写）包含关系匹配
+var sensitiveKeys = []string{
+	"password",
+	"passwd",
+	"oldpass",
+	"newpass",
+	"recovery_code",
+	"secret",
+	"token",
+	"private_key",
+	"credential",
+	"access_key",
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Marshal 将对象序列化为 json 并对敏感字段做脱敏，nil 返回空字符串
+func Marshal(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+
+	return Sanitize(bs)
+}
+
+// Sanitize 对 json 文本中的敏感字段做脱敏，非 json 内容原样返回
+func Sanitize(bs []byte) string {
+	var obj interface{}
+	if err := json.Unmarshal(bs, &obj); err != nil {
+		return string(bs)
+	}
+
+	if obj == nil {
+		return ""
+	}
+
+	out, err := json.Marshal(redact(obj))
+	if err != nil {
+		return string(bs)
+	}
+
+	return string(out)
+}
+
+func redact(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			if isSensitiveKey(k) {
+				if s, ok := item.(string); ok && s == "" {
+					continue
+				}
+				val[k] = redactedValue
+				continue
+			}
+			val[k] = redact(item)
+		}
+		return val
+	case []interface{}:
+		for i := range val {
+			val[i] = redact(val[i])
+		}
+		return val
+	default:
+		return v
+	}
+}
+
+type Change struct {
+	Path   string      `json:"path"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// Diff 对比变更前后的 json 快照，返回按字段路径排序的变更列表（json 格式）
+// 对象逐字段递归比较，数组整体比较；任一侧为空时返回空字符串
+func Diff(before, after string) string {
+	if before == "" || after == "" {
+		return ""
+	}
+
+	var b, a interface{}
+	if err := json.Unmarshal([]byte(before), &b); err != nil {
+		return ""
+	}
+
+	if err := json.Unmarshal([]byte(after), &a); err != nil {
+		return ""
+	}
+
+	changes := make([]Change, 0)
+	diffValue("", b, a, &changes)
+	if len(changes) == 0 {
+		return ""
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	bs, err := json.Marshal(changes)
+	if err != nil {
+		return ""
+	}
+
+	return string(bs)
+}
+
+func diffValue(path string, before, after interface{}, changes *[]Change) {
+	bm, bok := before.(map[string]interface{})
+	am, aok := after.(map[string]interface{})
+	if bok && aok {
+		keys := make(map[string]struct{}, len(bm)+len(am))
+		for k := range bm {
+			keys[k] = struct{}{}
+		}
+		for k := range am {
+			keys[k] = struct{}{}
+		}
+
+		for k := range keys {
+			diffValue(joinPath(path, k), bm[k], am[k], changes)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(before, after) {
+		*changes = append(*changes, Change{Path: path, Before: before, After: after})
+	}
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
@@ -0,0 +1,54 @@
+package audit
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	before := `{"1":{"name":"cpu","disabled":0,"tags":["a"],"extra":{"k":"v"}}}`
+	after := `{"1":{"name":"cpu","disabled":1,"tags":["a","b"],"extra":{"k":"v"}}}`
+
+	var changes []Change
+	if err := json.Unmarshal([]byte(Diff(before, after)), &changes); err != nil {
+		t.Fatalf("unmarshal diff error: %v", err)
+	}
+
+	if len(changes) != 2 {
+		t.Fatalf("expect 2 changes, got %+v", changes)
+	}
+
+	if changes[0].Path != "1.disabled" || changes[1].Path != "1.tags" {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+
+	if Diff(before, before) != "" {
+		t.Fatalf("expect empty diff for identical snapshots")
+	}
+}
+
+func TestMarshalRedact(t *testing.T) {
+	obj := map[string]interface{}{
+		"username": "root",
+		"password": "123456",
+		"oldpass":  "123456",
+		"settings": map[string]interface{}{
+			"basic.password": "xx",
+			"token":          "",
+		},
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal([]byte(Marshal(obj)), &got); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+
+	if got["password"] != redactedValue || got["oldpass"] != redactedValue || got["username"] != "root" {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+
+	settings := got["settings"].(map[string]interface{})
+	if settings["basic.password"] != redactedValue || settings["token"] != "" {
+		t.Fatalf("unexpected settings: %+v", settings)
+	}
+}
@@ -19,8 +19,13 @@ type Center struct {
 	EventHistoryGroupView     bool
 	CleanNotifyRecordDay      int
 	CleanPipelineExecutionDay int
+	MaxConfigVersions         int
 	MigrateBusiGroupLabel     bool
 	RSA                       httpx.RSAConfig
+	Audit                     Audit
+	NotifyRedeliver           NotifyRedeliver
+	Report                    Report
+	Kubernetes                []Kubernetes
 }
 
 type Plugin struct {
@@ -36,6 +41,64 @@ type FlashDuty struct {
 	Timeout time.Duration
 }
 
+// NotifyRedeliver http 通知发送失败后的重投配置
+type NotifyRedeliver struct {
+	Disable       bool
+	MaxAttempts   int
+	BaseInterval  int // unit: s
+	MaxInterval   int // unit: s
+	RetentionDays int
+}
+
+// Report 定期告警报告的调度配置，多个 center 实例时可以只在部分实例上开启
+type Report struct {
+	Disable bool
+}
+
+// Kubernetes 从集群中发现 node、pod、service 并注册为机器，多个 center 实例时只需在一个实例上配置
+type Kubernetes struct {
+	Name           string   // 集群名称，写入 k8s_cluster 标签，多个集群之间不能重复
+	Kubeconfig     string   // kubeconfig 文件路径，为空时使用 in-cluster 配置
+	Context        string   // kubeconfig 中的 context，为空时使用 current-context
+	Resources      []string // node、pod、service，默认只发现 node
+	Namespaces     []string // 只发现这些 namespace 下的 pod、service，为空时不限制
+	LabelSelector  string
+	Annotations    []string // 作为标签保留的 annotation，默认不保留
+	ResyncInterval int64    // unit: s
+	DefaultGroupId int64    // 没有匹配到 GroupRules 时归属的业务组，0 表示不归属
+	GroupRules     []KubernetesGroupRule
+}
+
+// KubernetesGroupRule 按 namespace 或 label 把对象归属到业务组，可以同时匹配多条
+type KubernetesGroupRule struct {
+	Namespace string // 支持 * 通配，如 prod-*
+	Label     string // key=value
+	GroupId   int64
+}
+
+type Audit struct {
+	Enable        bool
+	RetentionDays int
+	Webhook       AuditWebhook
+	Kafka         AuditKafka
+}
+
+type AuditWebhook struct {
+	Enable  bool
+	Url     string
+	Headers map[string]string
+	Timeout time.Duration // unit: ms
+}
+
+type AuditKafka struct {
+	Enable  bool
+	Typ     string
+	Brokers []string
+	Topic   string
+	Version string
+	Timeout int64 // unit: s
+}
+
 type AnonymousAccess struct {
 	PromQuerier bool
 	AlertDetail bool
@@ -120,6 +120,14 @@ ops:
       cname: Recording Rule - Modify
     - name: /recording-rules/del
       cname: Recording Rule - Delete
+    - name: /rule-groups
+      cname: Rule Group - View
+    - name: /rule-groups/add
+      cname: Rule Group - Add
+    - name: /rule-groups/put
+      cname: Rule Group - Modify
+    - name: /rule-groups/del
+      cname: Rule Group - Delete
     - name: /log/explorer
       cname: Logs Explorer
     - name: /log/index-patterns # 前端有个管理索引模式的页面，所以需要一个权限点来控制，后面应该改成侧拉板
@@ -168,6 +176,22 @@ ops:
       cname: Subscribing Rule - Modify
     - name: /alert-subscribes/del
       cname: Subscribing Rule - Delete
+    - name: /slos
+      cname: SLO - View
+    - name: /slos/add
+      cname: SLO - Add
+    - name: /slos/put
+      cname: SLO - Modify
+    - name: /slos/del
+      cname: SLO - Delete
+    - name: /reports
+      cname: Report - View
+    - name: /reports/add
+      cname: Report - Add
+    - name: /reports/put
+      cname: Report - Modify
+    - name: /reports/del
+      cname: Report - Delete
     - name: /job-tpls
       cname: Self-healing-Script - View
     - name: /job-tpls/add
@@ -182,6 +206,16 @@ ops:
       cname: Self-healing-Job - Add
     - name: /job-tasks/put
       cname: Self-healing-Job - Modify
+    - name: /remediation-policies
+      cname: Self-healing-Policy - View
+    - name: /remediation-policies/add
+      cname: Self-healing-Policy - Add
+    - name: /remediation-policies/put
+      cname: Self-healing-Policy - Modify
+    - name: /remediation-policies/del
+      cname: Self-healing-Policy - Delete
+    - name: /remediation-runs/approve
+      cname: Self-healing-Policy - Approve Run
     - name: /alert-cur-events
       cname: Active Event - View
     - name: /alert-cur-events/del
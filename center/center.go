@@ -12,11 +12,18 @@ import (
 	"github.com/ccfos/nightingale/v6/alert"
 	"github.com/ccfos/nightingale/v6/alert/astats"
 	"github.com/ccfos/nightingale/v6/alert/dispatch"
+	"github.com/ccfos/nightingale/v6/alert/eventchart"
+	"github.com/ccfos/nightingale/v6/alert/pipeline/processor/dsquery"
+	"github.com/ccfos/nightingale/v6/alert/pipeline/processor/eventlimit"
 	"github.com/ccfos/nightingale/v6/alert/process"
+	"github.com/ccfos/nightingale/v6/alert/record"
+	"github.com/ccfos/nightingale/v6/alert/remediation"
 	alertrt "github.com/ccfos/nightingale/v6/alert/router"
+	"github.com/ccfos/nightingale/v6/alert/sender"
 	"github.com/ccfos/nightingale/v6/center/cconf"
 	"github.com/ccfos/nightingale/v6/center/cconf/rsa"
 	"github.com/ccfos/nightingale/v6/center/integration"
+	"github.com/ccfos/nightingale/v6/center/k8s"
 	"github.com/ccfos/nightingale/v6/center/metas"
 	centerrt "github.com/ccfos/nightingale/v6/center/router"
 	"github.com/ccfos/nightingale/v6/center/sso"
@@ -85,6 +92,15 @@ func Initialize(configDir string, cryptoKey string) (func(), error) {
 	if err != nil {
 		return nil, err
 	}
+	eventlimit.RegisterRedis(redis)
+	eventchart.RegisterRedis(redis)
+	remediation.RegisterRedis(redis)
+
+	if !config.CacheChange.Disable {
+		if err := memsto.StartCacheChangePublisher(ctx, db, redis, config.CacheChange.FullSyncInterval); err != nil {
+			return nil, err
+		}
+	}
 
 	metas := metas.New(redis)
 	idents := idents.New(ctx, redis, config.Pushgw)
@@ -122,6 +138,7 @@ func Initialize(configDir string, cryptoKey string) (func(), error) {
 	promClients := prom.NewPromClient(ctx)
 
 	dispatch.InitRegisterQueryFunc(promClients)
+	dsquery.RegisterPromClients(promClients)
 
 	externalProcessors := process.NewExternalProcessors()
 
@@ -135,11 +152,33 @@ func Initialize(configDir string, cryptoKey string) (func(), error) {
 
 	go cron.CleanNotifyRecord(ctx, config.Center.CleanNotifyRecordDay)
 	go cron.CleanPipelineExecution(ctx, config.Center.CleanPipelineExecutionDay)
+	go cron.CleanAuditLog(ctx, config.Center.Audit.RetentionDays)
+	go cron.CleanNotifyDeadLetter(ctx, config.Center.NotifyRedeliver.RetentionDays)
+
+	if !config.Center.NotifyRedeliver.Disable {
+		redeliver := config.Center.NotifyRedeliver
+		sender.NewRedeliverer(ctx, redeliver.MaxAttempts, redeliver.BaseInterval, redeliver.MaxInterval).Start()
+	}
+
+	record.NewBackfiller(ctx, dsCache, promClients).Start()
 
 	alertrtRouter := alertrt.New(config.HTTP, config.Alert, alertMuteCache, targetCache, busiGroupCache, alertStats, ctx, externalProcessors)
 	centerRouter := centerrt.New(config.HTTP, config.Center, config.Alert, config.Ibex,
 		cconf.Operations, dsCache, notifyConfigCache, promClients,
 		redis, sso, ctx, metas, idents, targetCache, userCache, userGroupCache, userTokenCache)
+	if !config.Center.Report.Disable {
+		centerRouter.Reporter.Start()
+	}
+
+	for _, cluster := range config.Center.Kubernetes {
+		discoverer, err := k8s.New(ctx, cluster)
+		if err != nil {
+			return nil, err
+		}
+		discoverer.DeleteHook = centerRouter.TargetDeleteHook
+		discoverer.Start()
+	}
+
 	pushgwRouter := pushgwrt.New(config.HTTP, config.Pushgw, config.Alert, targetCache, busiGroupCache, idents, metas, writers, ctx)
 
 	r := httpx.GinEngine(config.Global.RunMode, config.HTTP, configCvalCache.PrintBodyPaths, configCvalCache.PrintAccessLog)
@@ -0,0 +1,420 @@
+// Package k8s 通过 Kubernetes API 发现 node、pod、service，注册为机器并同步 label、annotation 到 host_tags
+package k8s
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/center/cconf"
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+
+	"github.com/toolkits/pkg/logger"
+	"gorm.io/gorm"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	listerv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	defaultResyncInterval = 60
+
+	// 对象变化后延迟一会儿再同步，合并短时间内的多次变化
+	syncDelay = 5 * time.Second
+)
+
+type Discoverer struct {
+	ctx    *ctx.Context
+	conf   cconf.Kubernetes
+	client kubernetes.Interface
+
+	factory       informers.SharedInformerFactory
+	nodeLister    listerv1.NodeLister
+	podLister     listerv1.PodLister
+	serviceLister listerv1.ServiceLister
+	namespaces    map[string]struct{}
+
+	// 删除消失的对象对应的机器时调用，和页面上删除机器使用同一个 hook
+	DeleteHook models.TargetDeleteHookFunc
+
+	trigger chan struct{}
+}
+
+func New(c *ctx.Context, conf cconf.Kubernetes) (*Discoverer, error) {
+	restConfig, err := buildRestConfig(conf)
+	if err != nil {
+		return nil, fmt.Errorf("kubernetes cluster %s: %v", conf.Name, err)
+	}
+
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("kubernetes cluster %s: %v", conf.Name, err)
+	}
+
+	return NewWithClient(c, conf, client)
+}
+
+// NewWithClient 使用指定的 client 创建，便于测试时传入 fake clientset
+func NewWithClient(c *ctx.Context, conf cconf.Kubernetes, client kubernetes.Interface) (*Discoverer, error) {
+	if conf.Name == "" {
+		return nil, fmt.Errorf("kubernetes cluster name is blank")
+	}
+
+	if len(conf.Resources) == 0 {
+		conf.Resources = []string{models.K8sKindNode}
+	}
+
+	if conf.ResyncInterval <= 0 {
+		conf.ResyncInterval = defaultResyncInterval
+	}
+
+	if conf.LabelSelector != "" {
+		if _, err := labels.Parse(conf.LabelSelector); err != nil {
+			return nil, fmt.Errorf("kubernetes cluster %s: invalid label selector: %v", conf.Name, err)
+		}
+	}
+
+	for _, rule := range conf.GroupRules {
+		if rule.Label != "" && !strings.Contains(rule.Label, "=") {
+			return nil, fmt.Errorf("kubernetes cluster %s: invalid group rule label %s, should be key=value", conf.Name, rule.Label)
+		}
+	}
+
+	d := &Discoverer{
+		ctx:        c,
+		conf:       conf,
+		client:     client,
+		namespaces: make(map[string]struct{}),
+		DeleteHook: func(tx *gorm.DB, idents []string) error { return nil },
+		trigger:    make(chan struct{}, 1),
+	}
+
+	for _, ns := range conf.Namespaces {
+		d.namespaces[ns] = struct{}{}
+	}
+
+	d.factory = informers.NewSharedInformerFactoryWithOptions(client, time.Duration(conf.ResyncInterval)*time.Second,
+		informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+			o.LabelSelector = conf.LabelSelector
+		}))
+
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { d.notify() },
+		DeleteFunc: func(obj interface{}) { d.notify() },
+	}
+
+	for _, resource := range conf.Resources {
+		var informer cache.SharedIndexInformer
+		switch resource {
+		case models.K8sKindNode:
+			nodes := d.factory.Core().V1().Nodes()
+			d.nodeLister, informer = nodes.Lister(), nodes.Informer()
+		case models.K8sKindPod:
+			pods := d.factory.Core().V1().Pods()
+			d.podLister, informer = pods.Lister(), pods.Informer()
+		case models.K8sKindService:
+			services := d.factory.Core().V1().Services()
+			d.serviceLister, informer = services.Lister(), services.Informer()
+		default:
+			return nil, fmt.Errorf("kubernetes cluster %s: unsupported resource %s", conf.Name, resource)
+		}
+
+		if _, err := informer.AddEventHandler(handler); err != nil {
+			return nil, err
+		}
+	}
+
+	return d, nil
+}
+
+func buildRestConfig(conf cconf.Kubernetes) (*rest.Config, error) {
+	if conf.Kubeconfig == "" {
+		return rest.InClusterConfig()
+	}
+
+	rules := &clientcmd.ClientConfigLoadingRules{ExplicitPath: conf.Kubeconfig}
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: conf.Context}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+}
+
+func (d *Discoverer) Start() {
+	go d.run(make(chan struct{}))
+}
+
+func (d *Discoverer) run(stop <-chan struct{}) {
+	d.factory.Start(stop)
+	for typ, synced := range d.factory.WaitForCacheSync(stop) {
+		if !synced {
+			logger.Errorf("kubernetes cluster %s: failed to sync %v", d.conf.Name, typ)
+			return
+		}
+	}
+
+	ticker := time.NewTicker(time.Duration(d.conf.ResyncInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		if err := d.Sync(); err != nil {
+			logger.Errorf("kubernetes cluster %s: failed to sync targets: %v", d.conf.Name, err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		case <-d.trigger:
+			time.Sleep(syncDelay)
+		}
+	}
+}
+
+func (d *Discoverer) notify() {
+	select {
+	case d.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Sync 对比 informer 缓存中的对象和数据库中的机器，新增、更新、删除机器并绑定业务组
+func (d *Discoverer) Sync() error {
+	desired, groups, err := d.collect()
+	if err != nil {
+		return err
+	}
+
+	existing, err := models.K8sTargetsGet(d.ctx, d.conf.Name)
+	if err != nil {
+		return err
+	}
+
+	existingMap := make(map[string]*models.Target, len(existing))
+	for _, t := range existing {
+		existingMap[t.Ident] = t
+	}
+
+	if err := models.K8sTargetsSave(d.ctx, desired, existingMap); err != nil {
+		return err
+	}
+
+	if err := d.bindGroups(groups); err != nil {
+		return err
+	}
+
+	desiredSet := make(map[string]struct{}, len(desired))
+	for _, t := range desired {
+		desiredSet[t.Ident] = struct{}{}
+	}
+
+	var gone []string
+	for _, t := range existing {
+		if _, has := desiredSet[t.Ident]; !has {
+			gone = append(gone, t.Ident)
+		}
+	}
+
+	if len(gone) > 0 {
+		logger.Infof("kubernetes cluster %s: delete targets %v", d.conf.Name, gone)
+		return models.TargetDel(d.ctx, gone, d.DeleteHook)
+	}
+
+	return nil
+}
+
+// bindGroups 只补充缺少的业务组，不解绑页面上手动设置的业务组
+func (d *Discoverer) bindGroups(groups map[string][]int64) error {
+	if len(groups) == 0 {
+		return nil
+	}
+
+	bound, err := models.TargetBusiGroupsGetAll(d.ctx)
+	if err != nil {
+		return err
+	}
+
+	missing := make(map[int64][]string)
+	for ident, gids := range groups {
+		for _, gid := range gids {
+			if !containsInt64(bound[ident], gid) {
+				missing[gid] = append(missing[gid], ident)
+			}
+		}
+	}
+
+	for gid, idents := range missing {
+		if err := models.TargetBindBgids(d.ctx, idents, []int64{gid}, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *Discoverer) collect() ([]*models.Target, map[string][]int64, error) {
+	var targets []*models.Target
+	groups := make(map[string][]int64)
+
+	add := func(kind string, meta metav1.ObjectMeta, ip string, extra map[string]string) {
+		t := &models.Target{
+			Ident:    models.K8sTargetIdent(d.conf.Name, kind, meta.Namespace, meta.Name),
+			HostIp:   ip,
+			HostTags: d.hostTags(kind, meta, extra),
+		}
+		targets = append(targets, t)
+
+		if gids := d.groupIds(meta.Namespace, meta.Labels); len(gids) > 0 {
+			groups[t.Ident] = gids
+		}
+	}
+
+	if d.nodeLister != nil {
+		nodes, err := d.nodeLister.List(labels.Everything())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, node := range nodes {
+			add(models.K8sKindNode, node.ObjectMeta, nodeIp(node), nil)
+		}
+	}
+
+	if d.podLister != nil {
+		pods, err := d.podLister.List(labels.Everything())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, pod := range pods {
+			// 已经结束的 pod 不再作为机器
+			if !d.watchNamespace(pod.Namespace) || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+				continue
+			}
+
+			extra := map[string]string{"k8s_node": pod.Spec.NodeName}
+			if len(pod.OwnerReferences) > 0 {
+				extra["k8s_owner_kind"] = pod.OwnerReferences[0].Kind
+				extra["k8s_owner_name"] = pod.OwnerReferences[0].Name
+			}
+			add(models.K8sKindPod, pod.ObjectMeta, pod.Status.PodIP, extra)
+		}
+	}
+
+	if d.serviceLister != nil {
+		services, err := d.serviceLister.List(labels.Everything())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, svc := range services {
+			if !d.watchNamespace(svc.Namespace) {
+				continue
+			}
+
+			ip := svc.Spec.ClusterIP
+			if ip == corev1.ClusterIPNone {
+				ip = ""
+			}
+			add(models.K8sKindService, svc.ObjectMeta, ip, map[string]string{"k8s_service_type": string(svc.Spec.Type)})
+		}
+	}
+
+	return targets, groups, nil
+}
+
+func (d *Discoverer) watchNamespace(namespace string) bool {
+	if len(d.namespaces) == 0 {
+		return true
+	}
+
+	_, has := d.namespaces[namespace]
+	return has
+}
+
+func (d *Discoverer) hostTags(kind string, meta metav1.ObjectMeta, extra map[string]string) []string {
+	tags := map[string]string{
+		models.K8sClusterTagKey: d.conf.Name,
+		"k8s_kind":              kind,
+		"k8s_name":              meta.Name,
+		"k8s_namespace":         meta.Namespace,
+	}
+
+	for k, v := range extra {
+		tags[k] = v
+	}
+
+	for k, v := range meta.Labels {
+		tags[models.K8sTagKey("k8s_label_", k)] = v
+	}
+
+	for _, k := range d.conf.Annotations {
+		if v, has := meta.Annotations[k]; has {
+			tags[models.K8sTagKey("k8s_annotation_", k)] = v
+		}
+	}
+
+	lst := make([]string, 0, len(tags))
+	for k, v := range tags {
+		// host_tags 按 = 分隔，值为空或者包含 = 的无法解析
+		if v == "" || strings.Contains(v, "=") {
+			continue
+		}
+		lst = append(lst, k+"="+v)
+	}
+	sort.Strings(lst)
+
+	return lst
+}
+
+func (d *Discoverer) groupIds(namespace string, lbs map[string]string) []int64 {
+	var gids []int64
+	for _, rule := range d.conf.GroupRules {
+		if rule.Namespace != "" {
+			if matched, _ := path.Match(rule.Namespace, namespace); !matched {
+				continue
+			}
+		}
+
+		if rule.Label != "" {
+			arr := strings.SplitN(rule.Label, "=", 2)
+			if v, has := lbs[arr[0]]; !has || v != arr[1] {
+				continue
+			}
+		}
+
+		if !containsInt64(gids, rule.GroupId) {
+			gids = append(gids, rule.GroupId)
+		}
+	}
+
+	if len(gids) == 0 && d.conf.DefaultGroupId > 0 {
+		gids = append(gids, d.conf.DefaultGroupId)
+	}
+
+	return gids
+}
+
+func nodeIp(node *corev1.Node) string {
+	for _, addr := range node.Status.Addresses {
+		if addr.Type == corev1.NodeInternalIP {
+			return addr.Address
+		}
+	}
+	return ""
+}
+
+func containsInt64(lst []int64, v int64) bool {
+	for _, item := range lst {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
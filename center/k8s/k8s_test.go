@@ -0,0 +1,229 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/center/cconf"
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDiscovererSync(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:k8s?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AutoMigrate(&models.Target{}, &models.TargetBusiGroup{}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := ctx.NewContext(context.Background(), db, true)
+
+	// 通过心跳上报的机器不受影响
+	if err := db.Create(&models.Target{Ident: "host-1"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	client := fake.NewSimpleClientset(
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: map[string]string{"kubernetes.io/arch": "amd64"}},
+			Status:     corev1.NodeStatus{Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "10.0.0.1"}}},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "mysql-0",
+				Namespace:       "prod-db",
+				Labels:          map[string]string{"team": "db"},
+				Annotations:     map[string]string{"owner": "alice", "kubectl.kubernetes.io/last-applied-configuration": "{}"},
+				OwnerReferences: []metav1.OwnerReference{{Kind: "StatefulSet", Name: "mysql"}},
+			},
+			Spec:   corev1.PodSpec{NodeName: "node-1"},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "172.16.0.2"},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "job-1", Namespace: "prod-db"},
+			Status:     corev1.PodStatus{Phase: corev1.PodSucceeded},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "dev"},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "mysql", Namespace: "prod-db"},
+			Spec:       corev1.ServiceSpec{ClusterIP: corev1.ClusterIPNone, Type: corev1.ServiceTypeClusterIP},
+		},
+	)
+
+	d, err := NewWithClient(c, cconf.Kubernetes{
+		Name:           "prod",
+		Resources:      []string{"node", "pod", "service"},
+		Namespaces:     []string{"prod-db"},
+		Annotations:    []string{"owner"},
+		DefaultGroupId: 1,
+		GroupRules: []cconf.KubernetesGroupRule{
+			{Namespace: "prod-*", GroupId: 2},
+			{Label: "team=db", GroupId: 3},
+		},
+	}, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	d.factory.Start(stop)
+	d.factory.WaitForCacheSync(stop)
+
+	if err := d.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	targets, err := models.K8sTargetsGet(c, "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]*models.Target)
+	for _, target := range targets {
+		got[target.Ident] = target
+	}
+
+	if len(got) != 3 || got["node:prod/node-1"] == nil || got["pod:prod/prod-db/mysql-0"] == nil || got["service:prod/prod-db/mysql"] == nil {
+		t.Fatalf("unexpected targets: %v", got)
+	}
+
+	pod := got["pod:prod/prod-db/mysql-0"]
+	tags := pod.GetHostTagsMap()
+	if pod.HostIp != "172.16.0.2" || tags["k8s_cluster"] != "prod" || tags["k8s_label_team"] != "db" ||
+		tags["k8s_owner_kind"] != "StatefulSet" || tags["k8s_node"] != "node-1" || tags["k8s_annotation_owner"] != "alice" {
+		t.Errorf("unexpected pod target: %s %v", pod.HostIp, pod.HostTags)
+	}
+
+	if _, has := tags["k8s_annotation_kubectl_kubernetes_io_last_applied_configuration"]; has {
+		t.Errorf("annotation not in whitelist should be ignored")
+	}
+
+	if node := got["node:prod/node-1"]; node.HostIp != "10.0.0.1" || node.GetHostTagsMap()["k8s_label_kubernetes_io_arch"] != "amd64" {
+		t.Errorf("unexpected node target: %s %v", node.HostIp, node.HostTags)
+	}
+
+	gids, err := models.TargetGroupIdsGetByIdent(c, "pod:prod/prod-db/mysql-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gids) != 2 {
+		t.Errorf("expected pod bound to group 2 and 3, got %v", gids)
+	}
+
+	gids, err = models.TargetGroupIdsGetByIdent(c, "node:prod/node-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gids) != 1 || gids[0] != 1 {
+		t.Errorf("expected node bound to default group, got %v", gids)
+	}
+
+	// 对象删除后对应的机器也删除
+	if err := client.CoreV1().Pods("prod-db").Delete(context.Background(), "mysql-0", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 100; i++ {
+		pods, _ := d.podLister.List(labels.Everything())
+		if len(pods) == 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := d.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if target, _ := models.TargetGetByIdent(c, "pod:prod/prod-db/mysql-0"); target != nil {
+		t.Errorf("target of deleted pod should be removed")
+	}
+
+	if target, _ := models.TargetGetByIdent(c, "host-1"); target == nil {
+		t.Errorf("target reported by agent should not be removed")
+	}
+}
+
+func TestNewWithClientInvalid(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	if _, err := NewWithClient(nil, cconf.Kubernetes{}, client); err == nil {
+		t.Errorf("expected error for blank name")
+	}
+
+	if _, err := NewWithClient(nil, cconf.Kubernetes{Name: "a", Resources: []string{"deployment"}}, client); err == nil {
+		t.Errorf("expected error for unsupported resource")
+	}
+
+	if _, err := NewWithClient(nil, cconf.Kubernetes{Name: "a", GroupRules: []cconf.KubernetesGroupRule{{Label: "team"}}}, client); err == nil {
+		t.Errorf("expected error for invalid group rule")
+	}
+}
+
+func TestDiscovererSyncClusters(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:k8s_clusters?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AutoMigrate(&models.Target{}, &models.TargetBusiGroup{}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := ctx.NewContext(context.Background(), db, true)
+
+	// 不同集群中同名的 node 各自注册为机器
+	for _, cluster := range []string{"prod", "test"} {
+		client := fake.NewSimpleClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}})
+
+		d, err := NewWithClient(c, cconf.Kubernetes{Name: cluster}, client)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stop := make(chan struct{})
+		d.factory.Start(stop)
+		d.factory.WaitForCacheSync(stop)
+
+		err = d.Sync()
+		close(stop)
+		if err != nil {
+			t.Fatalf("cluster %s: %v", cluster, err)
+		}
+	}
+
+	for _, cluster := range []string{"prod", "test"} {
+		targets, err := models.K8sTargetsGet(c, cluster)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(targets) != 1 || targets[0].Ident != "node:"+cluster+"/node-1" {
+			t.Fatalf("cluster %s: unexpected targets %v", cluster, targets)
+		}
+	}
+
+	idents := models.K8sTargetIdentsByLabels(map[string]string{"cluster": "test", "namespace": "db", "pod": "mysql-0", "node": "node-1"})
+	if len(idents) != 2 || idents[0] != "pod:test/db/mysql-0" || idents[1] != "node:test/node-1" {
+		t.Fatalf("unexpected idents: %v", idents)
+	}
+
+	if idents := models.K8sTargetIdentsByLabels(map[string]string{"node": "node-1"}); len(idents) != 0 {
+		t.Fatalf("idents without cluster: %v", idents)
+	}
+}
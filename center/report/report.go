@@ -0,0 +1,363 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/alert/dispatch"
+	"github.com/ccfos/nightingale/v6/memsto"
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+	"github.com/ccfos/nightingale/v6/prom"
+	"github.com/ccfos/nightingale/v6/storage"
+
+	"github.com/prometheus/common/model"
+	"github.com/robfig/cron/v3"
+	"github.com/toolkits/pkg/logger"
+)
+
+const (
+	reloadInterval = time.Minute
+	lockKeyPrefix  = "/n9e/report/lock/"
+)
+
+// Reporter 按报告配置的 cron 表达式生成告警报告，并通过通知媒介发送
+// 多个 center 实例同时运行时，通过 redis 锁保证同一时刻只有一个实例发送
+type Reporter struct {
+	ctx            *ctx.Context
+	promClients    *prom.PromClientMap
+	userCache      *memsto.UserCacheType
+	userGroupCache *memsto.UserGroupCacheType
+	redis          storage.Redis
+
+	cron    *cron.Cron
+	mu      sync.Mutex
+	entries map[int64]*entry
+}
+
+type entry struct {
+	id      cron.EntryID
+	version string
+}
+
+func New(ctx *ctx.Context, promClients *prom.PromClientMap, userCache *memsto.UserCacheType,
+	userGroupCache *memsto.UserGroupCacheType, redis storage.Redis) *Reporter {
+	return &Reporter{
+		ctx:            ctx,
+		promClients:    promClients,
+		userCache:      userCache,
+		userGroupCache: userGroupCache,
+		redis:          redis,
+		cron:           cron.New(cron.WithSeconds()),
+		entries:        make(map[int64]*entry),
+	}
+}
+
+func (r *Reporter) Start() {
+	r.cron.Start()
+	go func() {
+		for {
+			r.reload()
+			time.Sleep(reloadInterval)
+		}
+	}()
+}
+
+// reload 同步数据库中的报告配置，cron 表达式或配置有变化时重新注册
+func (r *Reporter) reload() {
+	lst, err := models.ReportEnabledGets(r.ctx)
+	if err != nil {
+		logger.Errorf("report: failed to get reports: %v", err)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	exists := make(map[int64]struct{}, len(lst))
+	for _, rpt := range lst {
+		exists[rpt.Id] = struct{}{}
+
+		version := fmt.Sprintf("%s_%d", rpt.CronPattern, rpt.UpdateAt)
+		if e, has := r.entries[rpt.Id]; has {
+			if e.version == version {
+				continue
+			}
+			r.cron.Remove(e.id)
+			delete(r.entries, rpt.Id)
+		}
+
+		id := rpt.Id
+		eid, err := r.cron.AddFunc(rpt.CronPattern, func() { r.run(id) })
+		if err != nil {
+			logger.Errorf("report: failed to schedule report %d, cron pattern: %s, error: %v", id, rpt.CronPattern, err)
+			continue
+		}
+
+		r.entries[id] = &entry{id: eid, version: version}
+	}
+
+	for id, e := range r.entries {
+		if _, has := exists[id]; !has {
+			r.cron.Remove(e.id)
+			delete(r.entries, id)
+		}
+	}
+}
+
+func (r *Reporter) run(id int64) {
+	rpt, err := models.ReportGetById(r.ctx, id)
+	if err != nil {
+		logger.Errorf("report: failed to get report %d: %v", id, err)
+		return
+	}
+
+	if rpt == nil || rpt.Disabled == 1 {
+		return
+	}
+
+	now := time.Now()
+	if !r.lock(id, now) {
+		logger.Debugf("report: report %d is sent by another instance", id)
+		return
+	}
+
+	err = r.Send(rpt, now)
+	if err != nil {
+		logger.Errorf("report: failed to send report %d: %v", id, err)
+	} else {
+		logger.Infof("report: report %d sent", id)
+	}
+
+	if err := rpt.UpdateResult(r.ctx, now.Unix(), err); err != nil {
+		logger.Errorf("report: failed to update result of report %d: %v", id, err)
+	}
+}
+
+// lock 同一个报告一分钟内只发送一次，没有配置 redis 时不加锁
+func (r *Reporter) lock(id int64, now time.Time) bool {
+	if r.redis == nil {
+		return true
+	}
+
+	key := fmt.Sprintf("%s%d/%d", lockKeyPrefix, id, now.Unix()/60)
+	ok, err := r.redis.SetNX(context.Background(), key, 1, 2*time.Minute).Result()
+	if err != nil {
+		logger.Warningf("report: failed to lock report %d: %v", id, err)
+		return true
+	}
+	return ok
+}
+
+// Generate 统计告警数据并执行报告中的查询
+func (r *Reporter) Generate(rpt *models.Report, now time.Time) (*models.ReportData, error) {
+	data, err := models.BuildReportData(r.ctx, rpt, now)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range rpt.Panels {
+		data.Panels = append(data.Panels, r.queryPanel(p, now))
+	}
+
+	return data, nil
+}
+
+func (r *Reporter) queryPanel(p models.ReportPanel, now time.Time) *models.ReportPanelResult {
+	res := &models.ReportPanelResult{Name: p.Name, Columns: []string{}, Rows: [][]string{}}
+	if res.Name == "" {
+		res.Name = p.PromQl
+	}
+
+	cli := r.promClients.GetCli(p.DatasourceId)
+	if cli == nil {
+		res.Error = fmt.Sprintf("datasource %d is not a prometheus-like datasource", p.DatasourceId)
+		return res
+	}
+
+	value, _, err := cli.Query(context.Background(), p.PromQl, now)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	res.Columns, res.Rows = valueToTable(value, p.Limit)
+	return res
+}
+
+// valueToTable 把即时查询结果转换成表格，标签作为列，最后一列为值
+func valueToTable(value model.Value, limit int) ([]string, [][]string) {
+	var samples []*model.Sample
+	switch v := value.(type) {
+	case model.Vector:
+		samples = v
+	case model.Matrix:
+		for _, s := range v {
+			if len(s.Values) == 0 {
+				continue
+			}
+			last := s.Values[len(s.Values)-1]
+			samples = append(samples, &model.Sample{Metric: s.Metric, Value: last.Value, Timestamp: last.Timestamp})
+		}
+	case *model.Scalar:
+		return []string{"value"}, [][]string{{formatValue(v.Value)}}
+	default:
+		return []string{}, [][]string{}
+	}
+
+	labelSet := make(map[string]struct{})
+	for _, s := range samples {
+		for name := range s.Metric {
+			if name == model.MetricNameLabel {
+				continue
+			}
+			labelSet[string(name)] = struct{}{}
+		}
+	}
+
+	columns := make([]string, 0, len(labelSet)+1)
+	for name := range labelSet {
+		columns = append(columns, name)
+	}
+	sort.Strings(columns)
+
+	rows := make([][]string, 0, len(samples))
+	for i, s := range samples {
+		if limit > 0 && i >= limit {
+			break
+		}
+
+		row := make([]string, 0, len(columns)+1)
+		for _, name := range columns {
+			row = append(row, string(s.Metric[model.LabelName(name)]))
+		}
+		rows = append(rows, append(row, formatValue(s.Value)))
+	}
+
+	return append(columns, "value"), rows
+}
+
+func formatValue(v model.SampleValue) string {
+	return strconv.FormatFloat(float64(v), 'f', -1, 64)
+}
+
+// Render 生成报告并使用报告关联的消息模板渲染
+func (r *Reporter) Render(rpt *models.Report, now time.Time) (*models.NotifyChannelConfig, map[string]interface{}, error) {
+	channel, err := models.NotifyChannelGet(r.ctx, "id = ?", rpt.ChannelId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if channel == nil {
+		return nil, nil, fmt.Errorf("notify channel %d not found", rpt.ChannelId)
+	}
+
+	tpl := models.DefaultReportTemplate(channel)
+	if rpt.TemplateId > 0 {
+		tpl, err = models.MessageTemplateGet(r.ctx, "id = ?", rpt.TemplateId)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if tpl == nil {
+			return nil, nil, fmt.Errorf("message template %d not found", rpt.TemplateId)
+		}
+	}
+
+	data, err := r.Generate(rpt, now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	siteUrl, _ := models.ConfigsGetSiteUrl(r.ctx)
+	return channel, tpl.RenderReport(data, siteUrl), nil
+}
+
+// Send 生成并发送报告，只支持 smtp 和 http 类型的通知媒介
+func (r *Reporter) Send(rpt *models.Report, now time.Time) error {
+	channel, content, err := r.Render(rpt, now)
+	if err != nil {
+		return err
+	}
+
+	if !channel.Enable {
+		return fmt.Errorf("notify channel %s is not enabled", channel.Name)
+	}
+
+	var contactKey string
+	if channel.ParamConfig != nil && channel.ParamConfig.UserInfo != nil {
+		contactKey = channel.ParamConfig.UserInfo.ContactKey
+	}
+
+	sendtos, _, _, customParams := dispatch.GetNotifyConfigParams(&models.NotifyConfig{Params: stringParams(rpt.Params)},
+		contactKey, r.userCache, r.userGroupCache)
+
+	// http 类型媒介的请求体模板会引用 $event，这里构造一个只有规则名的占位事件
+	events := []*models.AlertCurEvent{{RuleName: rpt.Name, IsRecovered: true}}
+
+	switch channel.RequestType {
+	case "smtp":
+		if len(sendtos) == 0 {
+			return fmt.Errorf("no valid email address in the user and team")
+		}
+
+		if _, ok := content["subject"].(string); !ok {
+			return fmt.Errorf("subject is missing in message template")
+		}
+
+		if _, ok := content["content"].(string); !ok {
+			return fmt.Errorf("content is missing in message template")
+		}
+
+		return channel.SendEmailNow(events, content, sendtos)
+	case "http":
+		if channel.RequestConfig == nil || channel.RequestConfig.HTTPRequestConfig == nil {
+			return fmt.Errorf("http request config is nil")
+		}
+
+		client, err := models.GetHTTPClient(channel)
+		if err != nil {
+			return err
+		}
+
+		if dispatch.NeedBatchContacts(channel.RequestConfig.HTTPRequestConfig) || len(sendtos) == 0 {
+			_, err = channel.SendHTTP(events, content, customParams, sendtos, client)
+			return err
+		}
+
+		for i := range sendtos {
+			if _, err := channel.SendHTTP(events, content, customParams, []string{sendtos[i]}, client); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported request type: %s", channel.RequestType)
+	}
+}
+
+// stringParams 自定义参数统一转换成字符串，GetNotifyConfigParams 要求非 id 类参数为字符串
+func stringParams(params map[string]interface{}) map[string]interface{} {
+	ret := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		switch k {
+		case "user_ids", "user_group_ids", "ids", "pagerduty_integration_keys", "pagerduty_integration_ids":
+			ret[k] = v
+			continue
+		}
+
+		switch val := v.(type) {
+		case string:
+			ret[k] = val
+		default:
+			bs, _ := json.Marshal(val)
+			ret[k] = string(bs)
+		}
+	}
+	return ret
+}
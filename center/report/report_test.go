@@ -0,0 +1,34 @@
+package report
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/common/model"
+)
+
+func TestValueToTable(t *testing.T) {
+	vector := model.Vector{
+		{Metric: model.Metric{"__name__": "up", "instance": "a:9100", "job": "node"}, Value: 1},
+		{Metric: model.Metric{"__name__": "up", "instance": "b:9100"}, Value: 0.5},
+	}
+
+	columns, rows := valueToTable(vector, 10)
+	if !reflect.DeepEqual(columns, []string{"instance", "job", "value"}) {
+		t.Fatalf("unexpected columns: %v", columns)
+	}
+
+	expected := [][]string{{"a:9100", "node", "1"}, {"b:9100", "", "0.5"}}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Fatalf("unexpected rows: %v", rows)
+	}
+
+	if _, rows := valueToTable(vector, 1); len(rows) != 1 {
+		t.Errorf("expected rows to be limited to 1, got %d", len(rows))
+	}
+
+	columns, rows = valueToTable(&model.Scalar{Value: 3}, 10)
+	if !reflect.DeepEqual(columns, []string{"value"}) || !reflect.DeepEqual(rows, [][]string{{"3"}}) {
+		t.Errorf("unexpected scalar table: %v %v", columns, rows)
+	}
+}
@@ -9,9 +9,11 @@ import (
 	"time"
 
 	"github.com/ccfos/nightingale/v6/alert/aconf"
+	"github.com/ccfos/nightingale/v6/center/audit"
 	"github.com/ccfos/nightingale/v6/center/cconf"
 	"github.com/ccfos/nightingale/v6/center/cstats"
 	"github.com/ccfos/nightingale/v6/center/metas"
+	"github.com/ccfos/nightingale/v6/center/report"
 	"github.com/ccfos/nightingale/v6/center/sso"
 	"github.com/ccfos/nightingale/v6/conf"
 	_ "github.com/ccfos/nightingale/v6/front/statik"
@@ -50,6 +52,8 @@ type Router struct {
 	UserCache         *memsto.UserCacheType
 	UserGroupCache    *memsto.UserGroupCacheType
 	UserTokenCache    *memsto.UserTokenCacheType
+	Auditor           *audit.Auditor
+	Reporter          *report.Reporter
 	Ctx               *ctx.Context
 
 	HeartbeatHook       HeartbeatHookFunc
@@ -79,6 +83,8 @@ func New(httpConfig httpx.Config, center cconf.Center, alert aconf.Alert, ibex c
 		UserCache:           uc,
 		UserGroupCache:      ugc,
 		UserTokenCache:      utc,
+		Auditor:             audit.New(ctx, center.Audit),
+		Reporter:            report.New(ctx, pc, uc, ugc, redis),
 		Ctx:                 ctx,
 		HeartbeatHook:       func(ident string) map[string]interface{} { return nil },
 		TargetDeleteHook:    func(tx *gorm.DB, idents []string) error { return nil },
@@ -177,7 +183,7 @@ func (rt *Router) Config(r *gin.Engine) {
 	pages := r.Group(pagesPrefix)
 	{
 
-		pages.DELETE("/datasource/series", rt.auth(), rt.admin(), rt.deleteDatasourceSeries)
+		pages.DELETE("/datasource/series", rt.auth(), rt.admin(), rt.audit(models.AuditResourceDatasource, ""), rt.deleteDatasourceSeries)
 		if rt.Center.AnonymousAccess.PromQuerier {
 			pages.Any("/proxy/:id/*url", rt.dsProxy)
 			pages.POST("/query-range-batch", rt.promBatchQueryRange)
@@ -243,6 +249,8 @@ func (rt *Router) Config(r *gin.Engine) {
 		pages.POST("/auth/refresh", rt.jwtMock(), rt.refreshPost)
 		pages.POST("/auth/captcha", rt.jwtMock(), rt.generateCaptcha)
 		pages.POST("/auth/captcha-verify", rt.jwtMock(), rt.captchaVerify)
+		pages.POST("/auth/mfa/enroll", rt.jwtMock(), rt.mfaLoginEnroll)
+		pages.POST("/auth/mfa/verify", rt.jwtMock(), rt.mfaLoginVerify)
 		pages.GET("/auth/ifshowcaptcha", rt.ifShowCaptcha)
 
 		pages.GET("/auth/sso-config", rt.ssoConfigNameGet)
@@ -268,70 +276,78 @@ func (rt *Router) Config(r *gin.Engine) {
 
 		pages.GET("/self/perms", rt.auth(), rt.user(), rt.permsGets)
 		pages.GET("/self/profile", rt.auth(), rt.user(), rt.selfProfileGet)
-		pages.PUT("/self/profile", rt.auth(), rt.user(), rt.selfProfilePut)
-		pages.PUT("/self/password", rt.auth(), rt.user(), rt.selfPasswordPut)
+		pages.PUT("/self/profile", rt.auth(), rt.user(), rt.audit(models.AuditResourceUser, ""), rt.selfProfilePut)
+		pages.PUT("/self/password", rt.auth(), rt.user(), rt.audit(models.AuditResourceUser, ""), rt.selfPasswordPut)
 		pages.GET("/self/token", rt.auth(), rt.user(), rt.getToken)
-		pages.POST("/self/token", rt.auth(), rt.user(), rt.addToken)
-		pages.DELETE("/self/token/:id", rt.auth(), rt.user(), rt.deleteToken)
+		pages.POST("/self/token", rt.auth(), rt.user(), rt.audit(models.AuditResourceUserToken, ""), rt.addToken)
+		pages.DELETE("/self/token/:id", rt.auth(), rt.user(), rt.audit(models.AuditResourceUserToken, "id"), rt.deleteToken)
+		pages.GET("/self/mfa", rt.auth(), rt.user(), rt.selfMfaGet)
+		pages.POST("/self/mfa/enroll", rt.auth(), rt.user(), rt.audit(models.AuditResourceUser, ""), rt.selfMfaEnroll)
+		pages.POST("/self/mfa/activate", rt.auth(), rt.user(), rt.audit(models.AuditResourceUser, ""), rt.selfMfaActivate)
+		pages.POST("/self/mfa/recovery-codes", rt.auth(), rt.user(), rt.audit(models.AuditResourceUser, ""), rt.selfMfaRecoveryCodes)
+		pages.DELETE("/self/mfa", rt.auth(), rt.user(), rt.audit(models.AuditResourceUser, ""), rt.selfMfaDel)
 
 		pages.GET("/users", rt.auth(), rt.user(), rt.perm("/users"), rt.userGets)
-		pages.POST("/users", rt.auth(), rt.user(), rt.perm("/users/add"), rt.userAddPost)
+		pages.POST("/users", rt.auth(), rt.user(), rt.perm("/users/add"), rt.audit(models.AuditResourceUser, ""), rt.userAddPost)
 		pages.GET("/user/:id/profile", rt.auth(), rt.userProfileGet)
-		pages.PUT("/user/:id/profile", rt.auth(), rt.user(), rt.perm("/users/put"), rt.userProfilePut)
-		pages.PUT("/user/:id/password", rt.auth(), rt.user(), rt.perm("/users/put"), rt.userPasswordPut)
-		pages.DELETE("/user/:id", rt.auth(), rt.user(), rt.perm("/users/del"), rt.userDel)
+		pages.PUT("/user/:id/profile", rt.auth(), rt.user(), rt.perm("/users/put"), rt.audit(models.AuditResourceUser, "id"), rt.userProfilePut)
+		pages.PUT("/user/:id/password", rt.auth(), rt.user(), rt.perm("/users/put"), rt.audit(models.AuditResourceUser, "id"), rt.userPasswordPut)
+		pages.DELETE("/user/:id", rt.auth(), rt.user(), rt.perm("/users/del"), rt.audit(models.AuditResourceUser, "id"), rt.userDel)
+		pages.DELETE("/user/:id/mfa", rt.auth(), rt.user(), rt.perm("/users/put"), rt.audit(models.AuditResourceUser, "id"), rt.userMfaReset)
+		pages.GET("/mfa-policy", rt.auth(), rt.admin(), rt.mfaPolicyGet)
+		pages.PUT("/mfa-policy", rt.auth(), rt.admin(), rt.audit(models.AuditResourceMfaPolicy, ""), rt.mfaPolicyPut)
 
 		pages.GET("/metric-views", rt.auth(), rt.metricViewGets)
-		pages.DELETE("/metric-views", rt.auth(), rt.user(), rt.metricViewDel)
-		pages.POST("/metric-views", rt.auth(), rt.user(), rt.metricViewAdd)
-		pages.PUT("/metric-views", rt.auth(), rt.user(), rt.metricViewPut)
+		pages.DELETE("/metric-views", rt.auth(), rt.user(), rt.audit(models.AuditResourceView, ""), rt.metricViewDel)
+		pages.POST("/metric-views", rt.auth(), rt.user(), rt.audit(models.AuditResourceView, ""), rt.metricViewAdd)
+		pages.PUT("/metric-views", rt.auth(), rt.user(), rt.audit(models.AuditResourceView, ""), rt.metricViewPut)
 
 		pages.GET("/builtin-metric-filters", rt.auth(), rt.user(), rt.metricFilterGets)
-		pages.DELETE("/builtin-metric-filters", rt.auth(), rt.user(), rt.metricFilterDel)
-		pages.POST("/builtin-metric-filters", rt.auth(), rt.user(), rt.metricFilterAdd)
-		pages.PUT("/builtin-metric-filters", rt.auth(), rt.user(), rt.metricFilterPut)
+		pages.DELETE("/builtin-metric-filters", rt.auth(), rt.user(), rt.audit(models.AuditResourceView, ""), rt.metricFilterDel)
+		pages.POST("/builtin-metric-filters", rt.auth(), rt.user(), rt.audit(models.AuditResourceView, ""), rt.metricFilterAdd)
+		pages.PUT("/builtin-metric-filters", rt.auth(), rt.user(), rt.audit(models.AuditResourceView, ""), rt.metricFilterPut)
 		pages.POST("/builtin-metric-promql", rt.auth(), rt.user(), rt.getMetricPromql)
 
-		pages.POST("/builtin-metrics", rt.auth(), rt.user(), rt.perm("/builtin-metrics/add"), rt.builtinMetricsAdd)
-		pages.PUT("/builtin-metrics", rt.auth(), rt.user(), rt.perm("/builtin-metrics/put"), rt.builtinMetricsPut)
-		pages.DELETE("/builtin-metrics", rt.auth(), rt.user(), rt.perm("/builtin-metrics/del"), rt.builtinMetricsDel)
+		pages.POST("/builtin-metrics", rt.auth(), rt.user(), rt.perm("/builtin-metrics/add"), rt.audit(models.AuditResourceBuiltinMetric, ""), rt.builtinMetricsAdd)
+		pages.PUT("/builtin-metrics", rt.auth(), rt.user(), rt.perm("/builtin-metrics/put"), rt.audit(models.AuditResourceBuiltinMetric, ""), rt.builtinMetricsPut)
+		pages.DELETE("/builtin-metrics", rt.auth(), rt.user(), rt.perm("/builtin-metrics/del"), rt.audit(models.AuditResourceBuiltinMetric, ""), rt.builtinMetricsDel)
 		pages.GET("/builtin-metrics", rt.auth(), rt.user(), rt.builtinMetricsGets)
 		pages.GET("/builtin-metrics/types", rt.auth(), rt.user(), rt.builtinMetricsTypes)
 		pages.GET("/builtin-metrics/types/default", rt.auth(), rt.user(), rt.builtinMetricsDefaultTypes)
 		pages.GET("/builtin-metrics/collectors", rt.auth(), rt.user(), rt.builtinMetricsCollectors)
 
 		pages.GET("/user-groups", rt.auth(), rt.user(), rt.userGroupGets)
-		pages.POST("/user-groups", rt.auth(), rt.user(), rt.perm("/user-groups/add"), rt.userGroupAdd)
+		pages.POST("/user-groups", rt.auth(), rt.user(), rt.perm("/user-groups/add"), rt.audit(models.AuditResourceUserGroup, ""), rt.userGroupAdd)
 		pages.GET("/user-group/:id", rt.auth(), rt.user(), rt.userGroupGet)
-		pages.PUT("/user-group/:id", rt.auth(), rt.user(), rt.perm("/user-groups/put"), rt.userGroupWrite(), rt.userGroupPut)
-		pages.DELETE("/user-group/:id", rt.auth(), rt.user(), rt.perm("/user-groups/del"), rt.userGroupWrite(), rt.userGroupDel)
-		pages.POST("/user-group/:id/members", rt.auth(), rt.user(), rt.perm("/user-groups/put"), rt.userGroupWrite(), rt.userGroupMemberAdd)
-		pages.DELETE("/user-group/:id/members", rt.auth(), rt.user(), rt.perm("/user-groups/put"), rt.userGroupWrite(), rt.userGroupMemberDel)
+		pages.PUT("/user-group/:id", rt.auth(), rt.user(), rt.perm("/user-groups/put"), rt.userGroupWrite(), rt.audit(models.AuditResourceUserGroup, "id"), rt.userGroupPut)
+		pages.DELETE("/user-group/:id", rt.auth(), rt.user(), rt.perm("/user-groups/del"), rt.userGroupWrite(), rt.audit(models.AuditResourceUserGroup, "id"), rt.userGroupDel)
+		pages.POST("/user-group/:id/members", rt.auth(), rt.user(), rt.perm("/user-groups/put"), rt.userGroupWrite(), rt.audit(models.AuditResourceUserGroup, "id"), rt.userGroupMemberAdd)
+		pages.DELETE("/user-group/:id/members", rt.auth(), rt.user(), rt.perm("/user-groups/put"), rt.userGroupWrite(), rt.audit(models.AuditResourceUserGroup, "id"), rt.userGroupMemberDel)
 
 		pages.GET("/busi-groups", rt.auth(), rt.user(), rt.busiGroupGets)
-		pages.POST("/busi-groups", rt.auth(), rt.user(), rt.perm("/busi-groups/add"), rt.busiGroupAdd)
+		pages.POST("/busi-groups", rt.auth(), rt.user(), rt.perm("/busi-groups/add"), rt.audit(models.AuditResourceBusiGroup, ""), rt.busiGroupAdd)
 		pages.GET("/busi-groups/alertings", rt.auth(), rt.busiGroupAlertingsGets)
 		pages.GET("/busi-group/:id", rt.auth(), rt.user(), rt.bgro(), rt.busiGroupGet)
-		pages.PUT("/busi-group/:id", rt.auth(), rt.user(), rt.perm("/busi-groups/put"), rt.bgrw(), rt.busiGroupPut)
-		pages.POST("/busi-group/:id/members", rt.auth(), rt.user(), rt.perm("/busi-groups/put"), rt.bgrw(), rt.busiGroupMemberAdd)
-		pages.DELETE("/busi-group/:id/members", rt.auth(), rt.user(), rt.perm("/busi-groups/put"), rt.bgrw(), rt.busiGroupMemberDel)
-		pages.DELETE("/busi-group/:id", rt.auth(), rt.user(), rt.perm("/busi-groups/del"), rt.bgrw(), rt.busiGroupDel)
+		pages.PUT("/busi-group/:id", rt.auth(), rt.user(), rt.perm("/busi-groups/put"), rt.bgrw(), rt.audit(models.AuditResourceBusiGroup, "id"), rt.busiGroupPut)
+		pages.POST("/busi-group/:id/members", rt.auth(), rt.user(), rt.perm("/busi-groups/put"), rt.bgrw(), rt.audit(models.AuditResourceBusiGroup, "id"), rt.busiGroupMemberAdd)
+		pages.DELETE("/busi-group/:id/members", rt.auth(), rt.user(), rt.perm("/busi-groups/put"), rt.bgrw(), rt.audit(models.AuditResourceBusiGroup, "id"), rt.busiGroupMemberDel)
+		pages.DELETE("/busi-group/:id", rt.auth(), rt.user(), rt.perm("/busi-groups/del"), rt.bgrw(), rt.audit(models.AuditResourceBusiGroup, "id"), rt.busiGroupDel)
 		pages.GET("/busi-group/:id/perm/:perm", rt.auth(), rt.user(), rt.checkBusiGroupPerm)
 		pages.GET("/busi-groups/tags", rt.auth(), rt.user(), rt.busiGroupsGetTags)
 
 		pages.GET("/targets", rt.auth(), rt.user(), rt.targetGets)
-		pages.POST("/target-update", rt.auth(), rt.targetUpdate)
+		pages.POST("/target-update", rt.auth(), rt.audit(models.AuditResourceTarget, ""), rt.targetUpdate)
 		pages.GET("/target/extra-meta", rt.auth(), rt.user(), rt.targetExtendInfoByIdent)
 		pages.POST("/target/list", rt.auth(), rt.user(), rt.targetGetsByHostFilter)
-		pages.DELETE("/targets", rt.auth(), rt.user(), rt.perm("/targets/del"), rt.targetDel)
+		pages.DELETE("/targets", rt.auth(), rt.user(), rt.perm("/targets/del"), rt.audit(models.AuditResourceTarget, ""), rt.targetDel)
 		pages.GET("/targets/tags", rt.auth(), rt.user(), rt.targetGetTags)
-		pages.POST("/targets/tags", rt.auth(), rt.user(), rt.perm("/targets/put"), rt.targetBindTagsByFE)
-		pages.DELETE("/targets/tags", rt.auth(), rt.user(), rt.perm("/targets/put"), rt.targetUnbindTagsByFE)
-		pages.PUT("/targets/note", rt.auth(), rt.user(), rt.perm("/targets/put"), rt.targetUpdateNote)
-		pages.PUT("/targets/bgids", rt.auth(), rt.user(), rt.perm("/targets/put"), rt.targetBindBgids)
+		pages.POST("/targets/tags", rt.auth(), rt.user(), rt.perm("/targets/put"), rt.audit(models.AuditResourceTarget, ""), rt.targetBindTagsByFE)
+		pages.DELETE("/targets/tags", rt.auth(), rt.user(), rt.perm("/targets/put"), rt.audit(models.AuditResourceTarget, ""), rt.targetUnbindTagsByFE)
+		pages.PUT("/targets/note", rt.auth(), rt.user(), rt.perm("/targets/put"), rt.audit(models.AuditResourceTarget, ""), rt.targetUpdateNote)
+		pages.PUT("/targets/bgids", rt.auth(), rt.user(), rt.perm("/targets/put"), rt.audit(models.AuditResourceTarget, ""), rt.targetBindBgids)
 
-		pages.POST("/builtin-cate-favorite", rt.auth(), rt.user(), rt.builtinCateFavoriteAdd)
-		pages.DELETE("/builtin-cate-favorite/:name", rt.auth(), rt.user(), rt.builtinCateFavoriteDel)
+		pages.POST("/builtin-cate-favorite", rt.auth(), rt.user(), rt.audit(models.AuditResourceView, ""), rt.builtinCateFavoriteAdd)
+		pages.DELETE("/builtin-cate-favorite/:name", rt.auth(), rt.user(), rt.audit(models.AuditResourceView, ""), rt.builtinCateFavoriteDel)
 
 		pages.GET("/integrations/icon/:cate/:name", rt.builtinIcon)
 
@@ -345,25 +361,27 @@ func (rt *Router) Config(r *gin.Engine) {
 		pages.GET("/busi-groups/public-boards", rt.auth(), rt.user(), rt.perm("/dashboards"), rt.publicBoardGets)
 		pages.GET("/busi-groups/boards", rt.auth(), rt.user(), rt.perm("/dashboards"), rt.boardGetsByGids)
 		pages.GET("/busi-group/:id/boards", rt.auth(), rt.user(), rt.perm("/dashboards"), rt.bgro(), rt.boardGets)
-		pages.POST("/busi-group/:id/boards", rt.auth(), rt.user(), rt.perm("/dashboards/add"), rt.bgrw(), rt.boardAdd)
-		pages.POST("/busi-group/:id/board/:bid/clone", rt.auth(), rt.user(), rt.perm("/dashboards/add"), rt.bgrw(), rt.boardClone)
-		pages.POST("/busi-groups/boards/clones", rt.auth(), rt.user(), rt.perm("/dashboards/add"), rt.boardBatchClone)
+		pages.POST("/busi-group/:id/boards", rt.auth(), rt.user(), rt.perm("/dashboards/add"), rt.bgrw(), rt.audit(models.AuditResourceBoard, ""), rt.boardAdd)
+		pages.POST("/busi-group/:id/board/:bid/clone", rt.auth(), rt.user(), rt.perm("/dashboards/add"), rt.bgrw(), rt.audit(models.AuditResourceBoard, ""), rt.boardClone)
+		pages.POST("/busi-groups/boards/clones", rt.auth(), rt.user(), rt.perm("/dashboards/add"), rt.audit(models.AuditResourceBoard, ""), rt.boardBatchClone)
+		pages.POST("/dashboards/grafana-convert", rt.auth(), rt.user(), rt.perm("/dashboards/add"), rt.boardGrafanaConvert)
+		pages.POST("/busi-group/:id/boards/grafana-import", rt.auth(), rt.user(), rt.perm("/dashboards/add"), rt.bgrw(), rt.audit(models.AuditResourceBoard, ""), rt.boardGrafanaImport)
 
 		pages.GET("/boards", rt.auth(), rt.user(), rt.boardGetsByBids)
 		pages.GET("/board/:bid", rt.boardGet)
 		pages.GET("/board/:bid/pure", rt.boardPureGet)
-		pages.PUT("/board/:bid", rt.auth(), rt.user(), rt.perm("/dashboards/put"), rt.boardPut)
-		pages.PUT("/board/:bid/configs", rt.auth(), rt.user(), rt.perm("/dashboards/put"), rt.boardPutConfigs)
-		pages.PUT("/board/:bid/public", rt.auth(), rt.user(), rt.perm("/dashboards/put"), rt.boardPutPublic)
-		pages.DELETE("/boards", rt.auth(), rt.user(), rt.perm("/dashboards/del"), rt.boardDel)
+		pages.PUT("/board/:bid", rt.auth(), rt.user(), rt.perm("/dashboards/put"), rt.audit(models.AuditResourceBoard, "bid"), rt.boardPut)
+		pages.PUT("/board/:bid/configs", rt.auth(), rt.user(), rt.perm("/dashboards/put"), rt.audit(models.AuditResourceBoard, "bid"), rt.configVersion(models.AuditResourceBoard, "bid"), rt.boardPutConfigs)
+		pages.PUT("/board/:bid/public", rt.auth(), rt.user(), rt.perm("/dashboards/put"), rt.audit(models.AuditResourceBoard, "bid"), rt.boardPutPublic)
+		pages.DELETE("/boards", rt.auth(), rt.user(), rt.perm("/dashboards/del"), rt.audit(models.AuditResourceBoard, ""), rt.boardDel)
 
 		pages.GET("/share-charts", rt.chartShareGets)
-		pages.POST("/share-charts", rt.auth(), rt.chartShareAdd)
+		pages.POST("/share-charts", rt.auth(), rt.audit(models.AuditResourceChartShare, ""), rt.chartShareAdd)
 
-		pages.POST("/dashboard-annotations", rt.auth(), rt.user(), rt.perm("/dashboards/put"), rt.dashAnnotationAdd)
+		pages.POST("/dashboard-annotations", rt.auth(), rt.user(), rt.perm("/dashboards/put"), rt.audit(models.AuditResourceDashAnnotation, ""), rt.dashAnnotationAdd)
 		pages.GET("/dashboard-annotations", rt.dashAnnotationGets)
-		pages.PUT("/dashboard-annotation/:id", rt.auth(), rt.user(), rt.perm("/dashboards/put"), rt.dashAnnotationPut)
-		pages.DELETE("/dashboard-annotation/:id", rt.auth(), rt.user(), rt.perm("/dashboards/del"), rt.dashAnnotationDel)
+		pages.PUT("/dashboard-annotation/:id", rt.auth(), rt.user(), rt.perm("/dashboards/put"), rt.audit(models.AuditResourceDashAnnotation, "id"), rt.dashAnnotationPut)
+		pages.DELETE("/dashboard-annotation/:id", rt.auth(), rt.user(), rt.perm("/dashboards/del"), rt.audit(models.AuditResourceDashAnnotation, "id"), rt.dashAnnotationDel)
 
 		// pages.GET("/alert-rules/builtin/alerts-cates", rt.auth(), rt.user(), rt.builtinAlertCateGets)
 		// pages.GET("/alert-rules/builtin/list", rt.auth(), rt.user(), rt.builtinAlertRules)
@@ -371,192 +389,234 @@ func (rt *Router) Config(r *gin.Engine) {
 
 		pages.GET("/busi-groups/alert-rules", rt.auth(), rt.user(), rt.perm("/alert-rules"), rt.alertRuleGetsByGids)
 		pages.GET("/busi-group/:id/alert-rules", rt.auth(), rt.user(), rt.perm("/alert-rules"), rt.alertRuleGets)
-		pages.POST("/busi-group/:id/alert-rules", rt.auth(), rt.user(), rt.perm("/alert-rules/add"), rt.bgrw(), rt.alertRuleAddByFE)
-		pages.POST("/busi-group/:id/alert-rules/import", rt.auth(), rt.user(), rt.perm("/alert-rules/add"), rt.bgrw(), rt.alertRuleAddByImport)
+		pages.POST("/busi-group/:id/alert-rules", rt.auth(), rt.user(), rt.perm("/alert-rules/add"), rt.bgrw(), rt.audit(models.AuditResourceAlertRule, ""), rt.alertRuleAddByFE)
+		pages.POST("/busi-group/:id/alert-rules/import", rt.auth(), rt.user(), rt.perm("/alert-rules/add"), rt.bgrw(), rt.audit(models.AuditResourceAlertRule, ""), rt.alertRuleAddByImport)
 		pages.POST("/busi-group/:id/alert-rules/import-prom-rule", rt.auth(),
-			rt.user(), rt.perm("/alert-rules/add"), rt.bgrw(), rt.alertRuleAddByImportPromRule)
-		pages.DELETE("/busi-group/:id/alert-rules", rt.auth(), rt.user(), rt.perm("/alert-rules/del"), rt.bgrw(), rt.alertRuleDel)
-		pages.PUT("/busi-group/:id/alert-rules/fields", rt.auth(), rt.user(), rt.perm("/alert-rules/put"), rt.bgrw(), rt.alertRulePutFields)
-		pages.PUT("/busi-group/:id/alert-rule/:arid", rt.auth(), rt.user(), rt.perm("/alert-rules/put"), rt.alertRulePutByFE)
+			rt.user(), rt.perm("/alert-rules/add"), rt.bgrw(), rt.audit(models.AuditResourceAlertRule, ""), rt.alertRuleAddByImportPromRule)
+		pages.DELETE("/busi-group/:id/alert-rules", rt.auth(), rt.user(), rt.perm("/alert-rules/del"), rt.bgrw(), rt.audit(models.AuditResourceAlertRule, ""), rt.alertRuleDel)
+		pages.PUT("/busi-group/:id/alert-rules/fields", rt.auth(), rt.user(), rt.perm("/alert-rules/put"), rt.bgrw(), rt.audit(models.AuditResourceAlertRule, ""), rt.configVersion(models.AuditResourceAlertRule, ""), rt.alertRulePutFields)
+		pages.PUT("/busi-group/:id/alert-rule/:arid", rt.auth(), rt.user(), rt.perm("/alert-rules/put"), rt.audit(models.AuditResourceAlertRule, "arid"), rt.configVersion(models.AuditResourceAlertRule, "arid"), rt.alertRulePutByFE)
 		pages.GET("/alert-rule/:arid", rt.auth(), rt.user(), rt.perm("/alert-rules"), rt.alertRuleGet)
 		pages.GET("/alert-rule/:arid/pure", rt.auth(), rt.user(), rt.perm("/alert-rules"), rt.alertRulePureGet)
 		pages.PUT("/busi-group/alert-rule/validate", rt.auth(), rt.user(), rt.perm("/alert-rules/put"), rt.alertRuleValidation)
 		pages.POST("/relabel-test", rt.auth(), rt.user(), rt.relabelTest)
-		pages.POST("/busi-group/:id/alert-rules/clone", rt.auth(), rt.user(), rt.perm("/alert-rules/add"), rt.bgrw(), rt.cloneToMachine)
-		pages.POST("/busi-groups/alert-rules/clones", rt.auth(), rt.user(), rt.perm("/alert-rules/add"), rt.batchAlertRuleClone)
+		pages.POST("/busi-group/:id/alert-rules/clone", rt.auth(), rt.user(), rt.perm("/alert-rules/add"), rt.bgrw(), rt.audit(models.AuditResourceAlertRule, ""), rt.cloneToMachine)
+		pages.POST("/busi-groups/alert-rules/clones", rt.auth(), rt.user(), rt.perm("/alert-rules/add"), rt.audit(models.AuditResourceAlertRule, ""), rt.batchAlertRuleClone)
 		pages.POST("/busi-group/alert-rules/notify-tryrun", rt.auth(), rt.user(), rt.perm("/alert-rules/add"), rt.alertRuleNotifyTryRun)
 		pages.POST("/busi-group/alert-rules/enable-tryrun", rt.auth(), rt.user(), rt.perm("/alert-rules/add"), rt.alertRuleEnableTryRun)
 
 		pages.GET("/busi-groups/recording-rules", rt.auth(), rt.user(), rt.perm("/recording-rules"), rt.recordingRuleGetsByGids)
 		pages.GET("/busi-group/:id/recording-rules", rt.auth(), rt.user(), rt.perm("/recording-rules"), rt.recordingRuleGets)
-		pages.POST("/busi-group/:id/recording-rules", rt.auth(), rt.user(), rt.perm("/recording-rules/add"), rt.bgrw(), rt.recordingRuleAddByFE)
-		pages.DELETE("/busi-group/:id/recording-rules", rt.auth(), rt.user(), rt.perm("/recording-rules/del"), rt.bgrw(), rt.recordingRuleDel)
-		pages.PUT("/busi-group/:id/recording-rule/:rrid", rt.auth(), rt.user(), rt.perm("/recording-rules/put"), rt.bgrw(), rt.recordingRulePutByFE)
+		pages.POST("/busi-group/:id/recording-rules", rt.auth(), rt.user(), rt.perm("/recording-rules/add"), rt.bgrw(), rt.audit(models.AuditResourceRecordingRule, ""), rt.recordingRuleAddByFE)
+		pages.DELETE("/busi-group/:id/recording-rules", rt.auth(), rt.user(), rt.perm("/recording-rules/del"), rt.bgrw(), rt.audit(models.AuditResourceRecordingRule, ""), rt.recordingRuleDel)
+		pages.PUT("/busi-group/:id/recording-rule/:rrid", rt.auth(), rt.user(), rt.perm("/recording-rules/put"), rt.bgrw(), rt.audit(models.AuditResourceRecordingRule, "rrid"), rt.configVersion(models.AuditResourceRecordingRule, "rrid"), rt.recordingRulePutByFE)
 		pages.GET("/recording-rule/:rrid", rt.auth(), rt.user(), rt.perm("/recording-rules"), rt.recordingRuleGet)
-		pages.PUT("/busi-group/:id/recording-rules/fields", rt.auth(), rt.user(), rt.perm("/recording-rules/put"), rt.recordingRulePutFields)
+		pages.PUT("/busi-group/:id/recording-rules/fields", rt.auth(), rt.user(), rt.perm("/recording-rules/put"), rt.audit(models.AuditResourceRecordingRule, ""), rt.configVersion(models.AuditResourceRecordingRule, ""), rt.recordingRulePutFields)
+		pages.GET("/recording-rule/:rrid/backfills", rt.auth(), rt.user(), rt.perm("/recording-rules"), rt.recordingRuleBackfillGets)
+		pages.POST("/busi-group/:id/recording-rule/:rrid/backfills", rt.auth(), rt.user(), rt.perm("/recording-rules/put"), rt.bgrw(), rt.audit(models.AuditResourceRecordingRule, "rrid"), rt.recordingRuleBackfillAdd)
+		pages.PUT("/busi-group/:id/recording-rule/:rrid/backfill/:bid/cancel", rt.auth(), rt.user(), rt.perm("/recording-rules/put"), rt.bgrw(), rt.audit(models.AuditResourceRecordingRule, "rrid"), rt.recordingRuleBackfillCancel)
+
+		pages.GET("/busi-group/:id/rule-groups", rt.auth(), rt.user(), rt.perm("/rule-groups"), rt.bgro(), rt.ruleGroupGets)
+		pages.POST("/busi-group/:id/rule-groups", rt.auth(), rt.user(), rt.perm("/rule-groups/add"), rt.bgrw(), rt.audit(models.AuditResourceRuleGroup, ""), rt.ruleGroupAdd)
+		pages.DELETE("/busi-group/:id/rule-groups", rt.auth(), rt.user(), rt.perm("/rule-groups/del"), rt.bgrw(), rt.audit(models.AuditResourceRuleGroup, ""), rt.ruleGroupDel)
+		pages.GET("/rule-group/:rgid", rt.auth(), rt.user(), rt.perm("/rule-groups"), rt.ruleGroupGet)
+		pages.PUT("/busi-group/:id/rule-group/:rgid", rt.auth(), rt.user(), rt.perm("/rule-groups/put"), rt.bgrw(), rt.audit(models.AuditResourceRuleGroup, "rgid"), rt.ruleGroupPut)
+
+		pages.GET("/busi-group/:id/remediation-policies", rt.auth(), rt.user(), rt.perm("/remediation-policies"), rt.bgro(), rt.remediationPolicyGets)
+		pages.POST("/busi-group/:id/remediation-policies", rt.auth(), rt.user(), rt.perm("/remediation-policies/add"), rt.bgrw(), rt.audit(models.AuditResourceRemediationPolicy, ""), rt.remediationPolicyAdd)
+		pages.DELETE("/busi-group/:id/remediation-policies", rt.auth(), rt.user(), rt.perm("/remediation-policies/del"), rt.bgrw(), rt.audit(models.AuditResourceRemediationPolicy, ""), rt.remediationPolicyDel)
+		pages.GET("/remediation-policy/:rpid", rt.auth(), rt.user(), rt.perm("/remediation-policies"), rt.remediationPolicyGet)
+		pages.PUT("/busi-group/:id/remediation-policy/:rpid", rt.auth(), rt.user(), rt.perm("/remediation-policies/put"), rt.bgrw(), rt.audit(models.AuditResourceRemediationPolicy, "rpid"), rt.remediationPolicyPut)
+		pages.GET("/busi-group/:id/remediation-runs", rt.auth(), rt.user(), rt.perm("/remediation-policies"), rt.bgro(), rt.remediationRunGets)
+		pages.PUT("/busi-group/:id/remediation-run/:rrid/approval", rt.auth(), rt.user(), rt.perm("/remediation-runs/approve"), rt.bgrw(), rt.audit(models.AuditResourceRemediationRun, "rrid"), rt.remediationRunApprove)
+		pages.GET("/remediation-approval", rt.remediationApprovalConfirm)
+		pages.POST("/remediation-approval", rt.remediationApproveByToken)
 
 		pages.GET("/busi-groups/alert-mutes", rt.auth(), rt.user(), rt.perm("/alert-mutes"), rt.alertMuteGetsByGids)
 		pages.GET("/busi-group/:id/alert-mutes", rt.auth(), rt.user(), rt.perm("/alert-mutes"), rt.bgro(), rt.alertMuteGetsByBG)
 		pages.POST("/busi-group/:id/alert-mutes/preview", rt.auth(), rt.user(), rt.perm("/alert-mutes/add"), rt.bgrw(), rt.alertMutePreview)
-		pages.POST("/busi-group/:id/alert-mutes", rt.auth(), rt.user(), rt.perm("/alert-mutes/add"), rt.bgrw(), rt.alertMuteAdd)
-		pages.DELETE("/busi-group/:id/alert-mutes", rt.auth(), rt.user(), rt.perm("/alert-mutes/del"), rt.bgrw(), rt.alertMuteDel)
-		pages.PUT("/busi-group/:id/alert-mute/:amid", rt.auth(), rt.user(), rt.perm("/alert-mutes/put"), rt.alertMutePutByFE)
+		pages.POST("/busi-group/:id/alert-mutes", rt.auth(), rt.user(), rt.perm("/alert-mutes/add"), rt.bgrw(), rt.audit(models.AuditResourceAlertMute, ""), rt.alertMuteAdd)
+		pages.DELETE("/busi-group/:id/alert-mutes", rt.auth(), rt.user(), rt.perm("/alert-mutes/del"), rt.bgrw(), rt.audit(models.AuditResourceAlertMute, ""), rt.alertMuteDel)
+		pages.PUT("/busi-group/:id/alert-mute/:amid", rt.auth(), rt.user(), rt.perm("/alert-mutes/put"), rt.audit(models.AuditResourceAlertMute, "amid"), rt.alertMutePutByFE)
 		pages.GET("/busi-group/:id/alert-mute/:amid", rt.auth(), rt.user(), rt.perm("/alert-mutes"), rt.alertMuteGet)
-		pages.PUT("/busi-group/:id/alert-mutes/fields", rt.auth(), rt.user(), rt.perm("/alert-mutes/put"), rt.bgrw(), rt.alertMutePutFields)
+		pages.PUT("/busi-group/:id/alert-mutes/fields", rt.auth(), rt.user(), rt.perm("/alert-mutes/put"), rt.bgrw(), rt.audit(models.AuditResourceAlertMute, ""), rt.alertMutePutFields)
 		pages.POST("/alert-mute-tryrun", rt.auth(), rt.user(), rt.perm("/alert-mutes/add"), rt.alertMuteTryRun)
 
 		pages.GET("/busi-groups/alert-subscribes", rt.auth(), rt.user(), rt.perm("/alert-subscribes"), rt.alertSubscribeGetsByGids)
 		pages.GET("/busi-group/:id/alert-subscribes", rt.auth(), rt.user(), rt.perm("/alert-subscribes"), rt.bgro(), rt.alertSubscribeGets)
 		pages.GET("/alert-subscribe/:sid", rt.auth(), rt.user(), rt.perm("/alert-subscribes"), rt.alertSubscribeGet)
-		pages.POST("/busi-group/:id/alert-subscribes", rt.auth(), rt.user(), rt.perm("/alert-subscribes/add"), rt.bgrw(), rt.alertSubscribeAdd)
-		pages.PUT("/busi-group/:id/alert-subscribes", rt.auth(), rt.user(), rt.perm("/alert-subscribes/put"), rt.bgrw(), rt.alertSubscribePut)
-		pages.DELETE("/busi-group/:id/alert-subscribes", rt.auth(), rt.user(), rt.perm("/alert-subscribes/del"), rt.bgrw(), rt.alertSubscribeDel)
+		pages.POST("/busi-group/:id/alert-subscribes", rt.auth(), rt.user(), rt.perm("/alert-subscribes/add"), rt.bgrw(), rt.audit(models.AuditResourceAlertSubscribe, ""), rt.alertSubscribeAdd)
+		pages.PUT("/busi-group/:id/alert-subscribes", rt.auth(), rt.user(), rt.perm("/alert-subscribes/put"), rt.bgrw(), rt.audit(models.AuditResourceAlertSubscribe, ""), rt.alertSubscribePut)
+		pages.DELETE("/busi-group/:id/alert-subscribes", rt.auth(), rt.user(), rt.perm("/alert-subscribes/del"), rt.bgrw(), rt.audit(models.AuditResourceAlertSubscribe, ""), rt.alertSubscribeDel)
 		pages.POST("/alert-subscribe/alert-subscribes-tryrun", rt.auth(), rt.user(), rt.perm("/alert-subscribes/add"), rt.alertSubscribeTryRun)
 
+		pages.GET("/busi-group/:id/slos", rt.auth(), rt.user(), rt.perm("/slos"), rt.bgro(), rt.sloGets)
+		pages.POST("/busi-group/:id/slos", rt.auth(), rt.user(), rt.perm("/slos/add"), rt.bgrw(), rt.audit(models.AuditResourceSlo, ""), rt.sloAdd)
+		pages.PUT("/busi-group/:id/slo/:sid", rt.auth(), rt.user(), rt.perm("/slos/put"), rt.bgrw(), rt.audit(models.AuditResourceSlo, "sid"), rt.sloPut)
+		pages.DELETE("/busi-group/:id/slos", rt.auth(), rt.user(), rt.perm("/slos/del"), rt.bgrw(), rt.audit(models.AuditResourceSlo, ""), rt.sloDel)
+		pages.GET("/slo/:sid", rt.auth(), rt.user(), rt.perm("/slos"), rt.sloGet)
+		pages.GET("/slo/:sid/budget", rt.auth(), rt.user(), rt.perm("/slos"), rt.sloBudget)
+		pages.GET("/slo/:sid/budget-history", rt.auth(), rt.user(), rt.perm("/slos"), rt.sloBudgetHistory)
+
+		pages.GET("/reports", rt.auth(), rt.user(), rt.perm("/reports"), rt.reportGets)
+		pages.POST("/reports", rt.auth(), rt.user(), rt.perm("/reports/add"), rt.audit(models.AuditResourceReport, ""), rt.reportAdd)
+		pages.DELETE("/reports", rt.auth(), rt.user(), rt.perm("/reports/del"), rt.audit(models.AuditResourceReport, ""), rt.reportDel)
+		pages.GET("/report/:id", rt.auth(), rt.user(), rt.perm("/reports"), rt.reportGet)
+		pages.PUT("/report/:id", rt.auth(), rt.user(), rt.perm("/reports/put"), rt.audit(models.AuditResourceReport, "id"), rt.reportPut)
+		pages.GET("/report/:id/preview", rt.auth(), rt.user(), rt.perm("/reports"), rt.reportPreview)
+		pages.POST("/report/:id/run", rt.auth(), rt.user(), rt.perm("/reports/put"), rt.audit(models.AuditResourceReport, "id"), rt.reportRun)
+
 		pages.GET("/alert-cur-event/:eid", rt.alertCurEventGet)
 		pages.GET("/alert-his-event/:eid", rt.alertHisEventGet)
 		pages.GET("/event-notify-records/:eid", rt.notificationRecordList)
+		pages.GET("/event-timeline/:hash", rt.auth(), rt.user(), rt.perm("/alert-his-events"), rt.eventTimelineGet)
 
 		// card logic
 		pages.GET("/alert-cur-events/list", rt.auth(), rt.user(), rt.alertCurEventsList)
 		pages.GET("/alert-cur-events/card", rt.auth(), rt.user(), rt.alertCurEventsCard)
 		pages.POST("/alert-cur-events/card/details", rt.auth(), rt.alertCurEventsCardDetails)
 		pages.GET("/alert-his-events/list", rt.auth(), rt.user(), rt.alertHisEventsList)
-		pages.DELETE("/alert-his-events", rt.auth(), rt.admin(), rt.alertHisEventsDelete)
-		pages.DELETE("/alert-cur-events", rt.auth(), rt.user(), rt.perm("/alert-cur-events/del"), rt.alertCurEventDel)
+		pages.DELETE("/alert-his-events", rt.auth(), rt.admin(), rt.audit(models.AuditResourceAlertEvent, ""), rt.alertHisEventsDelete)
+		pages.DELETE("/alert-cur-events", rt.auth(), rt.user(), rt.perm("/alert-cur-events/del"), rt.audit(models.AuditResourceAlertEvent, ""), rt.alertCurEventDel)
 		pages.GET("/alert-cur-events/stats", rt.auth(), rt.alertCurEventsStatistics)
+		pages.GET("/alert-analytics", rt.auth(), rt.user(), rt.perm("/alert-his-events"), rt.alertAnalytics)
+		pages.GET("/alert-analytics/channels", rt.auth(), rt.user(), rt.perm("/alert-his-events"), rt.alertAnalyticsChannels)
+		pages.GET("/alert-analytics/export", rt.auth(), rt.user(), rt.perm("/alert-his-events"), rt.alertAnalyticsExport)
 
 		pages.GET("/alert-aggr-views", rt.auth(), rt.alertAggrViewGets)
-		pages.DELETE("/alert-aggr-views", rt.auth(), rt.user(), rt.alertAggrViewDel)
-		pages.POST("/alert-aggr-views", rt.auth(), rt.user(), rt.alertAggrViewAdd)
-		pages.PUT("/alert-aggr-views", rt.auth(), rt.user(), rt.alertAggrViewPut)
+		pages.DELETE("/alert-aggr-views", rt.auth(), rt.user(), rt.audit(models.AuditResourceView, ""), rt.alertAggrViewDel)
+		pages.POST("/alert-aggr-views", rt.auth(), rt.user(), rt.audit(models.AuditResourceView, ""), rt.alertAggrViewAdd)
+		pages.PUT("/alert-aggr-views", rt.auth(), rt.user(), rt.audit(models.AuditResourceView, ""), rt.alertAggrViewPut)
 
 		pages.GET("/busi-groups/task-tpls", rt.auth(), rt.user(), rt.perm("/job-tpls"), rt.taskTplGetsByGids)
 		pages.GET("/busi-group/:id/task-tpls", rt.auth(), rt.user(), rt.perm("/job-tpls"), rt.bgro(), rt.taskTplGets)
-		pages.POST("/busi-group/:id/task-tpls", rt.auth(), rt.user(), rt.perm("/job-tpls/add"), rt.bgrw(), rt.taskTplAdd)
-		pages.DELETE("/busi-group/:id/task-tpl/:tid", rt.auth(), rt.user(), rt.perm("/job-tpls/del"), rt.bgrw(), rt.taskTplDel)
-		pages.POST("/busi-group/:id/task-tpls/tags", rt.auth(), rt.user(), rt.perm("/job-tpls/put"), rt.bgrw(), rt.taskTplBindTags)
-		pages.DELETE("/busi-group/:id/task-tpls/tags", rt.auth(), rt.user(), rt.perm("/job-tpls/put"), rt.bgrw(), rt.taskTplUnbindTags)
+		pages.POST("/busi-group/:id/task-tpls", rt.auth(), rt.user(), rt.perm("/job-tpls/add"), rt.bgrw(), rt.audit(models.AuditResourceTaskTpl, ""), rt.taskTplAdd)
+		pages.DELETE("/busi-group/:id/task-tpl/:tid", rt.auth(), rt.user(), rt.perm("/job-tpls/del"), rt.bgrw(), rt.audit(models.AuditResourceTaskTpl, "tid"), rt.taskTplDel)
+		pages.POST("/busi-group/:id/task-tpls/tags", rt.auth(), rt.user(), rt.perm("/job-tpls/put"), rt.bgrw(), rt.audit(models.AuditResourceTaskTpl, ""), rt.taskTplBindTags)
+		pages.DELETE("/busi-group/:id/task-tpls/tags", rt.auth(), rt.user(), rt.perm("/job-tpls/put"), rt.bgrw(), rt.audit(models.AuditResourceTaskTpl, ""), rt.taskTplUnbindTags)
 		pages.GET("/busi-group/:id/task-tpl/:tid", rt.auth(), rt.user(), rt.perm("/job-tpls"), rt.bgro(), rt.taskTplGet)
-		pages.PUT("/busi-group/:id/task-tpl/:tid", rt.auth(), rt.user(), rt.perm("/job-tpls/put"), rt.bgrw(), rt.taskTplPut)
+		pages.PUT("/busi-group/:id/task-tpl/:tid", rt.auth(), rt.user(), rt.perm("/job-tpls/put"), rt.bgrw(), rt.audit(models.AuditResourceTaskTpl, "tid"), rt.taskTplPut)
 
 		pages.GET("/busi-groups/tasks", rt.auth(), rt.user(), rt.perm("/job-tasks"), rt.taskGetsByGids)
 		pages.GET("/busi-group/:id/tasks", rt.auth(), rt.user(), rt.perm("/job-tasks"), rt.bgro(), rt.taskGets)
-		pages.POST("/busi-group/:id/tasks", rt.auth(), rt.user(), rt.perm("/job-tasks/add"), rt.bgrw(), rt.taskAdd)
+		pages.POST("/busi-group/:id/tasks", rt.auth(), rt.user(), rt.perm("/job-tasks/add"), rt.bgrw(), rt.audit(models.AuditResourceTask, ""), rt.taskAdd)
 
 		pages.GET("/servers", rt.auth(), rt.user(), rt.serversGet)
 		pages.GET("/server-clusters", rt.auth(), rt.user(), rt.serverClustersGet)
 
 		pages.POST("/datasource/list", rt.auth(), rt.user(), rt.datasourceList)
 		pages.POST("/datasource/plugin/list", rt.auth(), rt.pluginList)
-		pages.POST("/datasource/upsert", rt.auth(), rt.admin(), rt.datasourceUpsert)
+		pages.POST("/datasource/upsert", rt.auth(), rt.admin(), rt.audit(models.AuditResourceDatasource, ""), rt.datasourceUpsert)
 		pages.POST("/datasource/desc", rt.auth(), rt.admin(), rt.datasourceGet)
-		pages.POST("/datasource/status/update", rt.auth(), rt.admin(), rt.datasourceUpdataStatus)
-		pages.DELETE("/datasource/", rt.auth(), rt.admin(), rt.datasourceDel)
+		pages.POST("/datasource/status/update", rt.auth(), rt.admin(), rt.audit(models.AuditResourceDatasource, ""), rt.datasourceUpdataStatus)
+		pages.DELETE("/datasource/", rt.auth(), rt.admin(), rt.audit(models.AuditResourceDatasource, ""), rt.datasourceDel)
 
 		pages.GET("/roles", rt.auth(), rt.user(), rt.roleGets)
-		pages.POST("/roles", rt.auth(), rt.user(), rt.perm("/roles/add"), rt.roleAdd)
-		pages.PUT("/roles", rt.auth(), rt.user(), rt.perm("/roles/put"), rt.rolePut)
-		pages.DELETE("/role/:id", rt.auth(), rt.user(), rt.perm("/roles/del"), rt.roleDel)
+		pages.POST("/roles", rt.auth(), rt.user(), rt.perm("/roles/add"), rt.audit(models.AuditResourceRole, ""), rt.roleAdd)
+		pages.PUT("/roles", rt.auth(), rt.user(), rt.perm("/roles/put"), rt.audit(models.AuditResourceRole, ""), rt.rolePut)
+		pages.DELETE("/role/:id", rt.auth(), rt.user(), rt.perm("/roles/del"), rt.audit(models.AuditResourceRole, "id"), rt.roleDel)
 
 		pages.GET("/role/:id/ops", rt.auth(), rt.user(), rt.perm("/roles"), rt.operationOfRole)
-		pages.PUT("/role/:id/ops", rt.auth(), rt.user(), rt.perm("/roles/put"), rt.roleBindOperation)
+		pages.PUT("/role/:id/ops", rt.auth(), rt.user(), rt.perm("/roles/put"), rt.audit(models.AuditResourceRole, "id"), rt.roleBindOperation)
 		pages.GET("/operation", rt.operations)
 
 		pages.GET("/notify-tpls", rt.auth(), rt.user(), rt.notifyTplGets)
-		pages.PUT("/notify-tpl/content", rt.auth(), rt.user(), rt.notifyTplUpdateContent)
-		pages.PUT("/notify-tpl", rt.auth(), rt.user(), rt.notifyTplUpdate)
-		pages.POST("/notify-tpl", rt.auth(), rt.user(), rt.notifyTplAdd)
-		pages.DELETE("/notify-tpl/:id", rt.auth(), rt.user(), rt.notifyTplDel)
+		pages.PUT("/notify-tpl/content", rt.auth(), rt.user(), rt.audit(models.AuditResourceNotifyTpl, ""), rt.notifyTplUpdateContent)
+		pages.PUT("/notify-tpl", rt.auth(), rt.user(), rt.audit(models.AuditResourceNotifyTpl, ""), rt.notifyTplUpdate)
+		pages.POST("/notify-tpl", rt.auth(), rt.user(), rt.audit(models.AuditResourceNotifyTpl, ""), rt.notifyTplAdd)
+		pages.DELETE("/notify-tpl/:id", rt.auth(), rt.user(), rt.audit(models.AuditResourceNotifyTpl, "id"), rt.notifyTplDel)
 		pages.POST("/notify-tpl/preview", rt.auth(), rt.user(), rt.notifyTplPreview)
 
 		pages.GET("/sso-configs", rt.auth(), rt.admin(), rt.ssoConfigGets)
-		pages.PUT("/sso-config", rt.auth(), rt.admin(), rt.ssoConfigUpdate)
+		pages.PUT("/sso-config", rt.auth(), rt.admin(), rt.audit(models.AuditResourceSystemConfig, ""), rt.ssoConfigUpdate)
 
 		pages.GET("/webhooks", rt.auth(), rt.user(), rt.webhookGets)
-		pages.PUT("/webhooks", rt.auth(), rt.admin(), rt.webhookPuts)
+		pages.PUT("/webhooks", rt.auth(), rt.admin(), rt.audit(models.AuditResourceSystemConfig, ""), rt.webhookPuts)
 
 		pages.GET("/notify-script", rt.auth(), rt.user(), rt.perm("/help/notification-settings"), rt.notifyScriptGet)
-		pages.PUT("/notify-script", rt.auth(), rt.admin(), rt.notifyScriptPut)
+		pages.PUT("/notify-script", rt.auth(), rt.admin(), rt.audit(models.AuditResourceSystemConfig, ""), rt.notifyScriptPut)
 
 		pages.GET("/notify-channel", rt.auth(), rt.user(), rt.perm("/help/notification-settings"), rt.notifyChannelGets)
-		pages.PUT("/notify-channel", rt.auth(), rt.admin(), rt.notifyChannelPuts)
+		pages.PUT("/notify-channel", rt.auth(), rt.admin(), rt.audit(models.AuditResourceSystemConfig, ""), rt.notifyChannelPuts)
 
 		pages.GET("/notify-contact", rt.auth(), rt.user(), rt.notifyContactGets)
-		pages.PUT("/notify-contact", rt.auth(), rt.admin(), rt.notifyContactPuts)
+		pages.PUT("/notify-contact", rt.auth(), rt.admin(), rt.audit(models.AuditResourceSystemConfig, ""), rt.notifyContactPuts)
 
 		pages.GET("/notify-config", rt.auth(), rt.user(), rt.perm("/help/notification-settings"), rt.notifyConfigGet)
-		pages.PUT("/notify-config", rt.auth(), rt.admin(), rt.notifyConfigPut)
+		pages.PUT("/notify-config", rt.auth(), rt.admin(), rt.audit(models.AuditResourceSystemConfig, ""), rt.notifyConfigPut)
 		pages.PUT("/smtp-config-test", rt.auth(), rt.admin(), rt.attemptSendEmail)
 
 		pages.GET("/es-index-pattern", rt.auth(), rt.esIndexPatternGet)
 		pages.GET("/es-index-pattern-list", rt.auth(), rt.esIndexPatternGetList)
-		pages.POST("/es-index-pattern", rt.auth(), rt.user(), rt.perm("/log/index-patterns/add"), rt.esIndexPatternAdd)
-		pages.PUT("/es-index-pattern", rt.auth(), rt.user(), rt.perm("/log/index-patterns/put"), rt.esIndexPatternPut)
-		pages.DELETE("/es-index-pattern", rt.auth(), rt.user(), rt.perm("/log/index-patterns/del"), rt.esIndexPatternDel)
+		pages.POST("/es-index-pattern", rt.auth(), rt.user(), rt.perm("/log/index-patterns/add"), rt.audit(models.AuditResourceEsIndexPattern, ""), rt.esIndexPatternAdd)
+		pages.PUT("/es-index-pattern", rt.auth(), rt.user(), rt.perm("/log/index-patterns/put"), rt.audit(models.AuditResourceEsIndexPattern, ""), rt.esIndexPatternPut)
+		pages.DELETE("/es-index-pattern", rt.auth(), rt.user(), rt.perm("/log/index-patterns/del"), rt.audit(models.AuditResourceEsIndexPattern, ""), rt.esIndexPatternDel)
 
 		pages.GET("/embedded-dashboards", rt.auth(), rt.user(), rt.perm("/embedded-dashboards"), rt.embeddedDashboardsGet)
-		pages.PUT("/embedded-dashboards", rt.auth(), rt.user(), rt.perm("/embedded-dashboards/put"), rt.embeddedDashboardsPut)
+		pages.PUT("/embedded-dashboards", rt.auth(), rt.user(), rt.perm("/embedded-dashboards/put"), rt.audit(models.AuditResourceEmbeddedProduct, ""), rt.embeddedDashboardsPut)
 
 		// 获取 embedded-product 列表
 		pages.GET("/embedded-product", rt.auth(), rt.user(), rt.embeddedProductGets)
 		pages.GET("/embedded-product/:id", rt.auth(), rt.user(), rt.embeddedProductGet)
-		pages.POST("/embedded-product", rt.auth(), rt.user(), rt.perm("/embedded-product/add"), rt.embeddedProductAdd)
-		pages.PUT("/embedded-product/:id", rt.auth(), rt.user(), rt.perm("/embedded-product/put"), rt.embeddedProductPut)
-		pages.DELETE("/embedded-product/:id", rt.auth(), rt.user(), rt.perm("/embedded-product/delete"), rt.embeddedProductDelete)
+		pages.POST("/embedded-product", rt.auth(), rt.user(), rt.perm("/embedded-product/add"), rt.audit(models.AuditResourceEmbeddedProduct, ""), rt.embeddedProductAdd)
+		pages.PUT("/embedded-product/:id", rt.auth(), rt.user(), rt.perm("/embedded-product/put"), rt.audit(models.AuditResourceEmbeddedProduct, "id"), rt.embeddedProductPut)
+		pages.DELETE("/embedded-product/:id", rt.auth(), rt.user(), rt.perm("/embedded-product/delete"), rt.audit(models.AuditResourceEmbeddedProduct, "id"), rt.embeddedProductDelete)
 
 		pages.GET("/user-variable-configs", rt.auth(), rt.user(), rt.perm("/help/variable-configs"), rt.userVariableConfigGets)
-		pages.POST("/user-variable-config", rt.auth(), rt.user(), rt.perm("/help/variable-configs"), rt.userVariableConfigAdd)
-		pages.PUT("/user-variable-config/:id", rt.auth(), rt.user(), rt.perm("/help/variable-configs"), rt.userVariableConfigPut)
-		pages.DELETE("/user-variable-config/:id", rt.auth(), rt.user(), rt.perm("/help/variable-configs"), rt.userVariableConfigDel)
+		pages.POST("/user-variable-config", rt.auth(), rt.user(), rt.perm("/help/variable-configs"), rt.audit(models.AuditResourceUserVariable, ""), rt.userVariableConfigAdd)
+		pages.PUT("/user-variable-config/:id", rt.auth(), rt.user(), rt.perm("/help/variable-configs"), rt.audit(models.AuditResourceUserVariable, "id"), rt.userVariableConfigPut)
+		pages.DELETE("/user-variable-config/:id", rt.auth(), rt.user(), rt.perm("/help/variable-configs"), rt.audit(models.AuditResourceUserVariable, "id"), rt.userVariableConfigDel)
 
 		pages.GET("/config", rt.auth(), rt.admin(), rt.configGetByKey)
-		pages.PUT("/config", rt.auth(), rt.admin(), rt.configPutByKey)
+		pages.PUT("/config", rt.auth(), rt.admin(), rt.audit(models.AuditResourceSystemConfig, ""), rt.configPutByKey)
 		pages.GET("/site-info", rt.siteInfo)
 
 		// source token 相关路由
-		pages.POST("/source-token", rt.auth(), rt.user(), rt.sourceTokenAdd)
+		pages.POST("/source-token", rt.auth(), rt.user(), rt.audit(models.AuditResourceSourceToken, ""), rt.sourceTokenAdd)
 
 		// for admin api
 		pages.GET("/user/busi-groups", rt.auth(), rt.admin(), rt.userBusiGroupsGets)
 
 		pages.GET("/builtin-components", rt.auth(), rt.user(), rt.builtinComponentsGets)
-		pages.POST("/builtin-components", rt.auth(), rt.user(), rt.perm("/components/add"), rt.builtinComponentsAdd)
-		pages.PUT("/builtin-components", rt.auth(), rt.user(), rt.perm("/components/put"), rt.builtinComponentsPut)
-		pages.DELETE("/builtin-components", rt.auth(), rt.user(), rt.perm("/components/del"), rt.builtinComponentsDel)
+		pages.POST("/builtin-components", rt.auth(), rt.user(), rt.perm("/components/add"), rt.audit(models.AuditResourceBuiltinComponent, ""), rt.builtinComponentsAdd)
+		pages.PUT("/builtin-components", rt.auth(), rt.user(), rt.perm("/components/put"), rt.audit(models.AuditResourceBuiltinComponent, ""), rt.builtinComponentsPut)
+		pages.DELETE("/builtin-components", rt.auth(), rt.user(), rt.perm("/components/del"), rt.audit(models.AuditResourceBuiltinComponent, ""), rt.builtinComponentsDel)
 
 		pages.GET("/builtin-payloads", rt.auth(), rt.user(), rt.builtinPayloadsGets)
 		pages.GET("/builtin-payloads/cates", rt.auth(), rt.user(), rt.builtinPayloadcatesGet)
-		pages.POST("/builtin-payloads", rt.auth(), rt.user(), rt.perm("/components/add"), rt.builtinPayloadsAdd)
-		pages.PUT("/builtin-payloads", rt.auth(), rt.user(), rt.perm("/components/put"), rt.builtinPayloadsPut)
-		pages.DELETE("/builtin-payloads", rt.auth(), rt.user(), rt.perm("/components/del"), rt.builtinPayloadsDel)
+		pages.POST("/builtin-payloads", rt.auth(), rt.user(), rt.perm("/components/add"), rt.audit(models.AuditResourceBuiltinPayload, ""), rt.builtinPayloadsAdd)
+		pages.PUT("/builtin-payloads", rt.auth(), rt.user(), rt.perm("/components/put"), rt.audit(models.AuditResourceBuiltinPayload, ""), rt.builtinPayloadsPut)
+		pages.DELETE("/builtin-payloads", rt.auth(), rt.user(), rt.perm("/components/del"), rt.audit(models.AuditResourceBuiltinPayload, ""), rt.builtinPayloadsDel)
 		pages.GET("/builtin-payload", rt.auth(), rt.user(), rt.builtinPayloadsGetByUUID)
 
-		pages.POST("/message-templates", rt.auth(), rt.user(), rt.perm("/notification-templates/add"), rt.messageTemplatesAdd)
-		pages.DELETE("/message-templates", rt.auth(), rt.user(), rt.perm("/notification-templates/del"), rt.messageTemplatesDel)
-		pages.PUT("/message-template/:id", rt.auth(), rt.user(), rt.perm("/notification-templates/put"), rt.messageTemplatePut)
+		pages.POST("/message-templates", rt.auth(), rt.user(), rt.perm("/notification-templates/add"), rt.audit(models.AuditResourceMessageTemplate, ""), rt.messageTemplatesAdd)
+		pages.DELETE("/message-templates", rt.auth(), rt.user(), rt.perm("/notification-templates/del"), rt.audit(models.AuditResourceMessageTemplate, ""), rt.messageTemplatesDel)
+		pages.PUT("/message-template/:id", rt.auth(), rt.user(), rt.perm("/notification-templates/put"), rt.audit(models.AuditResourceMessageTemplate, "id"), rt.configVersion(models.AuditResourceMessageTemplate, "id"), rt.messageTemplatePut)
 		pages.GET("/message-template/:id", rt.auth(), rt.user(), rt.perm("/notification-templates"), rt.messageTemplateGet)
 		pages.GET("/message-templates", rt.auth(), rt.user(), rt.messageTemplatesGet)
 		pages.POST("/events-message", rt.auth(), rt.user(), rt.eventsMessage)
 
-		pages.POST("/notify-rules", rt.auth(), rt.user(), rt.perm("/notification-rules/add"), rt.notifyRulesAdd)
-		pages.DELETE("/notify-rules", rt.auth(), rt.user(), rt.perm("/notification-rules/del"), rt.notifyRulesDel)
-		pages.PUT("/notify-rule/:id", rt.auth(), rt.user(), rt.perm("/notification-rules/put"), rt.notifyRulePut)
+		pages.POST("/notify-rules", rt.auth(), rt.user(), rt.perm("/notification-rules/add"), rt.audit(models.AuditResourceNotifyRule, ""), rt.notifyRulesAdd)
+		pages.DELETE("/notify-rules", rt.auth(), rt.user(), rt.perm("/notification-rules/del"), rt.audit(models.AuditResourceNotifyRule, ""), rt.notifyRulesDel)
+		pages.PUT("/notify-rule/:id", rt.auth(), rt.user(), rt.perm("/notification-rules/put"), rt.audit(models.AuditResourceNotifyRule, "id"), rt.configVersion(models.AuditResourceNotifyRule, "id"), rt.notifyRulePut)
 		pages.GET("/notify-rule/:id", rt.auth(), rt.user(), rt.perm("/notification-rules"), rt.notifyRuleGet)
 		pages.GET("/notify-rules", rt.auth(), rt.user(), rt.perm("/notification-rules"), rt.notifyRulesGet)
 		pages.POST("/notify-rule/test", rt.auth(), rt.user(), rt.perm("/notification-rules"), rt.notifyTest)
 		pages.GET("/notify-rule/custom-params", rt.auth(), rt.user(), rt.perm("/notification-rules"), rt.notifyRuleCustomParamsGet)
 		pages.POST("/notify-rule/event-pipelines-tryrun", rt.auth(), rt.user(), rt.perm("/notification-rules/add"), rt.tryRunEventProcessorByNotifyRule)
+		pages.GET("/notify-rule/:id/dead-letters", rt.auth(), rt.user(), rt.perm("/notification-rules"), rt.notifyDeadLetterGets)
+		pages.POST("/notify-rule/:id/dead-letters/resend", rt.auth(), rt.user(), rt.perm("/notification-rules/put"), rt.audit(models.AuditResourceNotifyDeadLetter, ""), rt.notifyDeadLetterResend)
+		pages.DELETE("/notify-rule/:id/dead-letters", rt.auth(), rt.user(), rt.perm("/notification-rules/put"), rt.audit(models.AuditResourceNotifyDeadLetter, ""), rt.notifyDeadLetterDel)
 
 		pages.GET("/event-tagkeys", rt.auth(), rt.user(), rt.eventTagKeys)
 		pages.GET("/event-tagvalues", rt.auth(), rt.user(), rt.eventTagValues)
 
 		// 事件Pipeline相关路由
 		pages.GET("/event-pipelines", rt.auth(), rt.user(), rt.perm("/event-pipelines"), rt.eventPipelinesList)
-		pages.POST("/event-pipeline", rt.auth(), rt.user(), rt.perm("/event-pipelines/add"), rt.addEventPipeline)
-		pages.PUT("/event-pipeline", rt.auth(), rt.user(), rt.perm("/event-pipelines/put"), rt.updateEventPipeline)
+		pages.POST("/event-pipeline", rt.auth(), rt.user(), rt.perm("/event-pipelines/add"), rt.audit(models.AuditResourceEventPipeline, ""), rt.addEventPipeline)
+		pages.PUT("/event-pipeline", rt.auth(), rt.user(), rt.perm("/event-pipelines/put"), rt.audit(models.AuditResourceEventPipeline, ""), rt.updateEventPipeline)
 		pages.GET("/event-pipeline/:id", rt.auth(), rt.user(), rt.perm("/event-pipelines"), rt.getEventPipeline)
-		pages.DELETE("/event-pipelines", rt.auth(), rt.user(), rt.perm("/event-pipelines/del"), rt.deleteEventPipelines)
+		pages.DELETE("/event-pipelines", rt.auth(), rt.user(), rt.perm("/event-pipelines/del"), rt.audit(models.AuditResourceEventPipeline, ""), rt.deleteEventPipelines)
 		pages.POST("/event-pipeline-tryrun", rt.auth(), rt.user(), rt.perm("/event-pipelines"), rt.tryRunEventPipeline)
 		pages.POST("/event-processor-tryrun", rt.auth(), rt.user(), rt.perm("/event-pipelines"), rt.tryRunEventProcessor)
 
@@ -571,11 +631,19 @@ func (rt *Router) Config(r *gin.Engine) {
 		pages.GET("/event-pipeline/:id/execution/:exec_id", rt.auth(), rt.user(), rt.perm("/event-pipelines"), rt.getEventPipelineExecution)
 		pages.GET("/event-pipeline-execution/:exec_id", rt.auth(), rt.user(), rt.perm("/event-pipelines"), rt.getEventPipelineExecution)
 		pages.GET("/event-pipeline/:id/execution-stats", rt.auth(), rt.user(), rt.perm("/event-pipelines"), rt.getEventPipelineExecutionStats)
-		pages.POST("/event-pipeline-executions/clean", rt.auth(), rt.user(), rt.admin(), rt.cleanEventPipelineExecutions)
+		pages.POST("/event-pipeline-executions/clean", rt.auth(), rt.user(), rt.admin(), rt.audit(models.AuditResourcePipelineExecution, ""), rt.cleanEventPipelineExecutions)
+
+		pages.POST("/notify-channel-configs", rt.auth(), rt.user(), rt.perm("/notification-channels/add"), rt.audit(models.AuditResourceNotifyChannel, ""), rt.notifyChannelsAdd)
+		pages.DELETE("/notify-channel-configs", rt.auth(), rt.user(), rt.perm("/notification-channels/del"), rt.audit(models.AuditResourceNotifyChannel, ""), rt.notifyChannelsDel)
+		pages.PUT("/notify-channel-config/:id", rt.auth(), rt.user(), rt.perm("/notification-channels/put"), rt.audit(models.AuditResourceNotifyChannel, "id"), rt.notifyChannelPut)
+
+		pages.GET("/audit-logs", rt.auth(), rt.admin(), rt.auditLogGets)
+		pages.GET("/audit-log/:id", rt.auth(), rt.admin(), rt.auditLogGet)
 
-		pages.POST("/notify-channel-configs", rt.auth(), rt.user(), rt.perm("/notification-channels/add"), rt.notifyChannelsAdd)
-		pages.DELETE("/notify-channel-configs", rt.auth(), rt.user(), rt.perm("/notification-channels/del"), rt.notifyChannelsDel)
-		pages.PUT("/notify-channel-config/:id", rt.auth(), rt.user(), rt.perm("/notification-channels/put"), rt.notifyChannelPut)
+		pages.GET("/config-versions/:type/:id", rt.auth(), rt.user(), rt.configVersionGets)
+		pages.GET("/config-versions/:type/:id/diff", rt.auth(), rt.user(), rt.configVersionDiff)
+		pages.GET("/config-version/:type/:id/:version", rt.auth(), rt.user(), rt.configVersionGet)
+		pages.POST("/config-version/:type/:id/:version/restore", rt.auth(), rt.user(), rt.configVersionAudit(), rt.configVersionRestore)
 		pages.GET("/notify-channel-config/:id", rt.auth(), rt.user(), rt.perm("/notification-channels"), rt.notifyChannelGet)
 		pages.GET("/notify-channel-configs", rt.auth(), rt.user(), rt.perm("/notification-channels"), rt.notifyChannelsGet)
 		pages.GET("/simplified-notify-channel-configs", rt.notifyChannelsGetForNormalUser)
@@ -587,11 +655,11 @@ func (rt *Router) Config(r *gin.Engine) {
 
 		// saved view 查询条件保存相关路由
 		pages.GET("/saved-views", rt.auth(), rt.user(), rt.savedViewGets)
-		pages.POST("/saved-views", rt.auth(), rt.user(), rt.savedViewAdd)
-		pages.PUT("/saved-view/:id", rt.auth(), rt.user(), rt.savedViewPut)
-		pages.DELETE("/saved-view/:id", rt.auth(), rt.user(), rt.savedViewDel)
-		pages.POST("/saved-view/:id/favorite", rt.auth(), rt.user(), rt.savedViewFavoriteAdd)
-		pages.DELETE("/saved-view/:id/favorite", rt.auth(), rt.user(), rt.savedViewFavoriteDel)
+		pages.POST("/saved-views", rt.auth(), rt.user(), rt.audit(models.AuditResourceView, ""), rt.savedViewAdd)
+		pages.PUT("/saved-view/:id", rt.auth(), rt.user(), rt.audit(models.AuditResourceView, "id"), rt.savedViewPut)
+		pages.DELETE("/saved-view/:id", rt.auth(), rt.user(), rt.audit(models.AuditResourceView, "id"), rt.savedViewDel)
+		pages.POST("/saved-view/:id/favorite", rt.auth(), rt.user(), rt.audit(models.AuditResourceView, "id"), rt.savedViewFavoriteAdd)
+		pages.DELETE("/saved-view/:id/favorite", rt.auth(), rt.user(), rt.audit(models.AuditResourceView, "id"), rt.savedViewFavoriteDel)
 	}
 
 	r.GET("/api/n9e/versions", func(c *gin.Context) {
@@ -616,9 +684,9 @@ func (rt *Router) Config(r *gin.Engine) {
 		}
 		{
 			service.Any("/prometheus/*url", rt.dsProxy)
-			service.POST("/users", rt.userAddPost)
-			service.PUT("/user/:id", rt.userProfilePutByService)
-			service.DELETE("/user/:id", rt.userDel)
+			service.POST("/users", rt.audit(models.AuditResourceUser, ""), rt.userAddPost)
+			service.PUT("/user/:id", rt.audit(models.AuditResourceUser, "id"), rt.userProfilePutByService)
+			service.DELETE("/user/:id", rt.audit(models.AuditResourceUser, "id"), rt.userDel)
 			service.GET("/users", rt.userFindAll)
 
 			service.GET("/user-groups", rt.userGroupGetsByService)
@@ -627,19 +695,19 @@ func (rt *Router) Config(r *gin.Engine) {
 			service.GET("/targets", rt.targetGetsByService)
 			service.GET("/target/extra-meta", rt.targetExtendInfoByIdent)
 			service.POST("/target/list", rt.targetGetsByHostFilter)
-			service.DELETE("/targets", rt.targetDelByService)
+			service.DELETE("/targets", rt.audit(models.AuditResourceTarget, ""), rt.targetDelByService)
 			service.GET("/targets/tags", rt.targetGetTags)
-			service.POST("/targets/tags", rt.targetBindTagsByService)
-			service.DELETE("/targets/tags", rt.targetUnbindTagsByService)
-			service.PUT("/targets/note", rt.targetUpdateNoteByService)
-			service.PUT("/targets/bgid", rt.targetUpdateBgidByService)
+			service.POST("/targets/tags", rt.audit(models.AuditResourceTarget, ""), rt.targetBindTagsByService)
+			service.DELETE("/targets/tags", rt.audit(models.AuditResourceTarget, ""), rt.targetUnbindTagsByService)
+			service.PUT("/targets/note", rt.audit(models.AuditResourceTarget, ""), rt.targetUpdateNoteByService)
+			service.PUT("/targets/bgid", rt.audit(models.AuditResourceTarget, ""), rt.targetUpdateBgidByService)
 
 			service.POST("/targets-of-host-query", rt.targetsOfHostQuery)
 
-			service.POST("/alert-rules", rt.alertRuleAddByService)
-			service.POST("/alert-rule-add", rt.alertRuleAddOneByService)
-			service.DELETE("/alert-rules", rt.alertRuleDelByService)
-			service.PUT("/alert-rule/:arid", rt.alertRulePutByService)
+			service.POST("/alert-rules", rt.audit(models.AuditResourceAlertRule, ""), rt.alertRuleAddByService)
+			service.POST("/alert-rule-add", rt.audit(models.AuditResourceAlertRule, ""), rt.alertRuleAddOneByService)
+			service.DELETE("/alert-rules", rt.audit(models.AuditResourceAlertRule, ""), rt.alertRuleDelByService)
+			service.PUT("/alert-rule/:arid", rt.audit(models.AuditResourceAlertRule, "arid"), rt.alertRulePutByService)
 			service.GET("/alert-rule/:arid", rt.alertRuleGet)
 			service.GET("/alert-rules", rt.alertRulesGetByService)
 
@@ -654,11 +722,13 @@ func (rt *Router) Config(r *gin.Engine) {
 			service.GET("/servers-active", rt.serversActive)
 
 			service.GET("/recording-rules", rt.recordingRuleGetsByService)
+			service.GET("/rule-groups", rt.ruleGroupGetsByService)
+			service.GET("/cache-changes", rt.cacheChangesGet)
 
 			service.GET("/alert-mutes", rt.alertMuteGets)
 			service.GET("/active-alert-mutes", rt.activeAlertMuteGets)
-			service.POST("/alert-mutes", rt.alertMuteAddByService)
-			service.DELETE("/alert-mutes", rt.alertMuteDel)
+			service.POST("/alert-mutes", rt.audit(models.AuditResourceAlertMute, ""), rt.alertMuteAddByService)
+			service.DELETE("/alert-mutes", rt.audit(models.AuditResourceAlertMute, ""), rt.alertMuteDel)
 
 			service.GET("/alert-cur-events", rt.alertCurEventsList)
 			service.GET("/alert-cur-events-get-by-rid", rt.alertCurEventsGetByRid)
@@ -673,9 +743,9 @@ func (rt *Router) Config(r *gin.Engine) {
 			service.GET("/configs", rt.configsGet)
 			service.GET("/config", rt.configGetByKey)
 			service.GET("/all-configs", rt.configGetAll)
-			service.PUT("/configs", rt.configsPut)
-			service.POST("/configs", rt.configsPost)
-			service.DELETE("/configs", rt.configsDel)
+			service.PUT("/configs", rt.audit(models.AuditResourceSystemConfig, ""), rt.configsPut)
+			service.POST("/configs", rt.audit(models.AuditResourceSystemConfig, ""), rt.configsPost)
+			service.DELETE("/configs", rt.audit(models.AuditResourceSystemConfig, ""), rt.configsDel)
 
 			service.POST("/conf-prop/encrypt", rt.confPropEncrypt)
 			service.POST("/conf-prop/decrypt", rt.confPropDecrypt)
@@ -691,6 +761,8 @@ func (rt *Router) Config(r *gin.Engine) {
 			service.GET("/targets-of-alert-rule", rt.targetsOfAlertRule)
 
 			service.POST("/notify-record", rt.notificationRecordAdd)
+			service.POST("/event-timelines", rt.eventTimelineAdd)
+			service.POST("/notify-dead-letter", rt.notifyDeadLetterAdd)
 
 			service.GET("/alert-cur-events-del-by-hash", rt.alertCurEventDelByHash)
 
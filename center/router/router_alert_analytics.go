@@ -0,0 +1,131 @@
+package router
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/toolkits/pkg/ginx"
+)
+
+const maxAnalyticsBuckets = 1000
+
+func (rt *Router) alertAnalyticsQuery(c *gin.Context) *models.AlertAnalyticsQuery {
+	now := time.Now().Unix()
+	q := &models.AlertAnalyticsQuery{
+		Etime: ginx.QueryInt64(c, "etime", now),
+	}
+	q.Stime = ginx.QueryInt64(c, "stime", q.Etime-7*86400)
+	if q.Stime >= q.Etime {
+		ginx.Bomb(http.StatusBadRequest, "stime must be less than etime")
+	}
+
+	bgids, err := GetBusinessGroupIds(c, rt.Ctx, rt.Center.EventHistoryGroupView, ginx.QueryBool(c, "my_groups", false))
+	ginx.Dangerous(err)
+	q.GroupIds = bgids
+
+	for _, s := range strings.Fields(strings.ReplaceAll(ginx.QueryStr(c, "severities", ""), ",", " ")) {
+		severity, err := strconv.Atoi(s)
+		if err != nil {
+			ginx.Bomb(http.StatusBadRequest, "invalid severity: %s", s)
+		}
+		q.Severities = append(q.Severities, severity)
+	}
+
+	for _, s := range strings.Fields(strings.ReplaceAll(ginx.QueryStr(c, "rids", ""), ",", " ")) {
+		rid, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			ginx.Bomb(http.StatusBadRequest, "invalid rule id: %s", s)
+		}
+		q.RuleIds = append(q.RuleIds, rid)
+	}
+
+	return q
+}
+
+func (rt *Router) alertAnalyticsStats(c *gin.Context, q *models.AlertAnalyticsQuery) ([]*models.AlertAnalyticsStat, string) {
+	groupBy := ginx.QueryStr(c, "group_by", models.AnalyticsGroupByRule)
+	bucket := ginx.QueryInt64(c, "bucket", 86400)
+	if groupBy == models.AnalyticsGroupByTime && bucket > 0 && (q.Etime-q.Stime)/bucket > maxAnalyticsBuckets {
+		ginx.Bomb(http.StatusBadRequest, "too many buckets, please increase bucket")
+	}
+
+	stats, err := models.AlertAnalytics(rt.Ctx, q, groupBy, bucket, ginx.QueryInt(c, "limit", 0))
+	if err != nil {
+		ginx.Bomb(http.StatusBadRequest, "%v", err)
+	}
+
+	return stats, groupBy
+}
+
+// alertAnalytics 按规则、业务组、级别或时间桶统计告警，limit 配合 group_by=rule 即为最吵的规则排行
+func (rt *Router) alertAnalytics(c *gin.Context) {
+	q := rt.alertAnalyticsQuery(c)
+	stats, _ := rt.alertAnalyticsStats(c, q)
+	ginx.NewRender(c).Data(stats, nil)
+}
+
+func (rt *Router) alertAnalyticsChannels(c *gin.Context) {
+	lst, err := models.NotifyCountsByChannel(rt.Ctx, rt.alertAnalyticsQuery(c))
+	ginx.NewRender(c).Data(lst, err)
+}
+
+// alertAnalyticsExport 导出 csv，每个通知媒介一列
+func (rt *Router) alertAnalyticsExport(c *gin.Context) {
+	q := rt.alertAnalyticsQuery(c)
+	stats, groupBy := rt.alertAnalyticsStats(c, q)
+
+	channelSet := make(map[string]struct{})
+	for _, stat := range stats {
+		for channel := range stat.Notifications {
+			channelSet[channel] = struct{}{}
+		}
+	}
+
+	channels := make([]string, 0, len(channelSet))
+	for channel := range channelSet {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+
+	header := []string{groupBy, "name", "events", "series", "flapping_ratio", "mtta_seconds", "mttr_seconds"}
+	for _, channel := range channels {
+		header = append(header, "notify_"+channel)
+	}
+
+	filename := fmt.Sprintf("alert-analytics-%s-%s.csv", groupBy, time.Unix(q.Etime, 0).Format("20060102"))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+
+	w := csv.NewWriter(c.Writer)
+	w.Write(header)
+	for _, stat := range stats {
+		key := stat.Key
+		if groupBy == models.AnalyticsGroupByTime {
+			ts, _ := strconv.ParseInt(stat.Key, 10, 64)
+			key = time.Unix(ts, 0).Format(time.RFC3339)
+		}
+
+		row := []string{
+			key,
+			stat.Name,
+			strconv.FormatInt(stat.Events, 10),
+			strconv.FormatInt(stat.Series, 10),
+			strconv.FormatFloat(stat.FlappingRatio, 'f', -1, 64),
+			strconv.FormatFloat(stat.MTTA.Seconds, 'f', 0, 64),
+			strconv.FormatFloat(stat.MTTR.Seconds, 'f', 0, 64),
+		}
+		for _, channel := range channels {
+			row = append(row, strconv.FormatInt(stat.Notifications[channel], 10))
+		}
+		w.Write(row)
+	}
+	w.Flush()
+}
@@ -240,6 +240,8 @@ func (rt *Router) alertCurEventGet(c *gin.Context) {
 		rt.auth()(c)
 		rt.user()(c)
 		rt.bgroCheck(c, event.GroupId)
+	} else {
+		rt.headerTokenBusiGroupCheck(c, event.GroupId)
 	}
 
 	ginx.NewRender(c).Data(event, err)
@@ -142,6 +142,8 @@ func (rt *Router) alertHisEventGet(c *gin.Context) {
 		rt.auth()(c)
 		rt.user()(c)
 		rt.bgroCheck(c, event.GroupId)
+	} else {
+		rt.headerTokenBusiGroupCheck(c, event.GroupId)
 	}
 
 	ruleConfig, needReset := models.FillRuleConfigTplName(rt.Ctx, event.RuleConfig)
@@ -156,7 +158,17 @@ func (rt *Router) alertHisEventGet(c *gin.Context) {
 	ginx.NewRender(c).Data(TransferEventToCur(rt.Ctx, event), err)
 }
 
+// GetBusinessGroupIds 返回事件列表可以查询的业务组，使用了限制业务组的 token 时只返回 token 允许的业务组
 func GetBusinessGroupIds(c *gin.Context, ctx *ctx.Context, onlySelfGroupView bool, myGroups bool) ([]int64, error) {
+	bgids, err := businessGroupIds(c, ctx, onlySelfGroupView, myGroups)
+	if err != nil {
+		return nil, err
+	}
+
+	return tokenBusiGroupIds(c, bgids), nil
+}
+
+func businessGroupIds(c *gin.Context, ctx *ctx.Context, onlySelfGroupView bool, myGroups bool) ([]int64, error) {
 	bgid := ginx.QueryInt64(c, "bgid", 0)
 	var bgids []int64
 
@@ -72,6 +72,8 @@ func (rt *Router) alertRuleGetsByGids(c *gin.Context) {
 		}
 	}
 
+	gids = tokenBusiGroupIds(c, gids)
+
 	ars, err := models.AlertRuleGetsByBGIds(rt.Ctx, gids)
 	if err == nil {
 		cache := make(map[int64]*models.UserGroup)
@@ -125,7 +127,16 @@ func (rt *Router) alertRulesGetByService(c *gin.Context) {
 	}
 
 	disabled := ginx.QueryInt(c, "disabled", -1)
-	ars, err := models.AlertRulesGetsBy(rt.Ctx, prods, query, algorithm, cluster, cates, disabled)
+
+	var ars []*models.AlertRule
+	var err error
+	if ids := strx.IdsInt64ForAPI(ginx.QueryStr(c, "ids", "")); len(ids) > 0 {
+		// 边缘机房增量更新缓存
+		ars, err = models.AlertRuleEnabledGetsByIds(rt.Ctx, ids)
+	} else {
+		ars, err = models.AlertRulesGetsBy(rt.Ctx, prods, query, algorithm, cluster, cates, disabled)
+	}
+
 	if err == nil {
 		cache := make(map[int64]*models.UserGroup)
 		for i := 0; i < len(ars); i++ {
@@ -611,6 +622,8 @@ func (rt *Router) alertRuleGet(c *gin.Context) {
 		return
 	}
 
+	rt.tokenBusiGroupCheck(c, ar.GroupId)
+
 	if len(ar.DatasourceQueries) != 0 {
 		ar.DatasourceIdsJson = rt.DatasourceCache.GetIDsByDsCateAndQueries(ar.Cate, ar.DatasourceQueries)
 	}
@@ -633,6 +646,8 @@ func (rt *Router) alertRulePureGet(c *gin.Context) {
 		return
 	}
 
+	rt.tokenBusiGroupCheck(c, ar.GroupId)
+
 	ginx.NewRender(c).Data(ar, err)
 }
 
@@ -692,7 +707,7 @@ func (rt *Router) alertRuleCallbacks(c *gin.Context) {
 	bussGroupIds, err := models.MyBusiGroupIds(rt.Ctx, user.Id)
 	ginx.Dangerous(err)
 
-	ars, err := models.AlertRuleGetsByBGIds(rt.Ctx, bussGroupIds)
+	ars, err := models.AlertRuleGetsByBGIds(rt.Ctx, tokenBusiGroupIds(c, bussGroupIds))
 	ginx.Dangerous(err)
 
 	var callbacks []string
@@ -54,6 +54,8 @@ func (rt *Router) alertSubscribeGetsByGids(c *gin.Context) {
 		}
 	}
 
+	gids = tokenBusiGroupIds(c, gids)
+
 	lst, err := models.AlertSubscribeGetsByBGIds(rt.Ctx, gids)
 	ginx.Dangerous(err)
 
@@ -81,6 +83,8 @@ func (rt *Router) alertSubscribeGet(c *gin.Context) {
 		return
 	}
 
+	rt.tokenBusiGroupCheck(c, sub.GroupId)
+
 	ugcache := make(map[int64]*models.UserGroup)
 	ginx.Dangerous(sub.FillUserGroups(rt.Ctx, ugcache))
 
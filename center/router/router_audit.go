@@ -0,0 +1,432 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ccfos/nightingale/v6/center/audit"
+	"github.com/ccfos/nightingale/v6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/toolkits/pkg/errorx"
+	"github.com/toolkits/pkg/ginx"
+	"github.com/toolkits/pkg/logger"
+)
+
+// auditWriter 记录响应内容，用于提取接口的错误信息以及新建资源的 id
+type auditWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *auditWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *auditWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// audit 记录配置变更的审计日志
+// idParam 为 url 中资源 id 的参数名，为空时从请求体中解析（支持 {"id":1}、{"ids":[1,2]}、[1,2]、[{"id":1}] 几种格式）
+func (rt *Router) audit(resourceType, idParam string) gin.HandlerFunc {
+	return rt.auditAs(resourceType, idParam, "")
+}
+
+// auditAs 与 audit 相同，action 不为空时不再按请求方法推断操作类型
+func (rt *Router) auditAs(resourceType, idParam, action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !rt.Auditor.Enabled() {
+			c.Next()
+			return
+		}
+
+		var reqBody []byte
+		if c.Request.Body != nil {
+			reqBody, _ = io.ReadAll(c.Request.Body)
+			c.Request.Body = io.NopCloser(bytes.NewReader(reqBody))
+		}
+
+		var ids []int64
+		if idParam != "" {
+			if id, err := strconv.ParseInt(c.Param(idParam), 10, 64); err == nil {
+				ids = append(ids, id)
+			}
+		} else {
+			ids = parseAuditIds(reqBody)
+		}
+
+		act := action
+		if act == "" {
+			act = auditAction(c.Request.Method)
+		}
+		var before string
+		if act != models.AuditActionCreate {
+			before = rt.auditSnapshot(resourceType, ids)
+		}
+
+		writer := &auditWriter{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = writer
+
+		// 服务接口没有登录用户，记录 basic auth 的用户名
+		username := c.GetString("username")
+		if username == "" {
+			username = c.GetString(gin.AuthUserKey)
+		}
+
+		log := &models.AuditLog{
+			Username:     username,
+			SourceIp:     c.ClientIP(),
+			ResourceType: resourceType,
+			Action:       act,
+			Method:       c.Request.Method,
+			Path:         c.FullPath(),
+			Before:       before,
+		}
+
+		defer func() {
+			if r := recover(); r != nil {
+				log.Status = models.AuditStatusFailed
+				if e, ok := r.(errorx.PageError); ok {
+					log.Message = e.Message
+				} else {
+					log.Message = fmt.Sprintf("%v", r)
+				}
+				log.ResourceIds = joinAuditIds(ids)
+				rt.Auditor.Record(log)
+				panic(r)
+			}
+		}()
+
+		c.Next()
+
+		msg, createdId := parseAuditResponse(writer.body.Bytes())
+		if msg != "" || c.Writer.Status() >= http.StatusBadRequest {
+			log.Status = models.AuditStatusFailed
+			log.Message = msg
+		} else {
+			log.Status = models.AuditStatusSuccess
+		}
+
+		if len(ids) == 0 && createdId > 0 {
+			ids = append(ids, createdId)
+		}
+		log.ResourceIds = joinAuditIds(ids)
+
+		if log.Status == models.AuditStatusSuccess {
+			if act != models.AuditActionDelete && len(ids) > 0 {
+				log.After = rt.auditSnapshot(resourceType, ids)
+			}
+
+			if log.After == "" && act != models.AuditActionDelete && len(reqBody) > 0 {
+				log.After = audit.Sanitize(reqBody)
+			}
+
+			log.Diff = audit.Diff(log.Before, log.After)
+		}
+
+		if len(log.Message) > 1024 {
+			log.Message = log.Message[:1024]
+		}
+
+		rt.Auditor.Record(log)
+	}
+}
+
+func auditAction(method string) string {
+	switch method {
+	case http.MethodPost:
+		return models.AuditActionCreate
+	case http.MethodDelete:
+		return models.AuditActionDelete
+	default:
+		return models.AuditActionUpdate
+	}
+}
+
+func parseAuditIds(body []byte) []int64 {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil
+	}
+
+	var obj struct {
+		Id  int64   `json:"id"`
+		Ids []int64 `json:"ids"`
+	}
+
+	if body[0] == '{' {
+		if err := json.Unmarshal(body, &obj); err != nil {
+			return nil
+		}
+
+		if len(obj.Ids) > 0 {
+			return obj.Ids
+		}
+
+		if obj.Id > 0 {
+			return []int64{obj.Id}
+		}
+
+		return nil
+	}
+
+	var nums []int64
+	if err := json.Unmarshal(body, &nums); err == nil {
+		return nums
+	}
+
+	var objs []struct {
+		Id int64 `json:"id"`
+	}
+	if err := json.Unmarshal(body, &objs); err != nil {
+		return nil
+	}
+
+	var ids []int64
+	for _, o := range objs {
+		if o.Id > 0 {
+			ids = append(ids, o.Id)
+		}
+	}
+
+	return ids
+}
+
+// parseAuditResponse 兼容 {"dat","err"} 与 {"data","error"} 两种响应格式
+func parseAuditResponse(body []byte) (string, int64) {
+	var resp struct {
+		Dat   interface{} `json:"dat"`
+		Err   interface{} `json:"err"`
+		Data  interface{} `json:"data"`
+		Error interface{} `json:"error"`
+	}
+
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", 0
+	}
+
+	var msg string
+	for _, e := range []interface{}{resp.Err, resp.Error} {
+		if s, ok := e.(string); ok && s != "" {
+			msg = s
+			break
+		}
+	}
+
+	var id int64
+	for _, d := range []interface{}{resp.Dat, resp.Data} {
+		if f, ok := d.(float64); ok && f > 0 {
+			id = int64(f)
+			break
+		}
+	}
+
+	return msg, id
+}
+
+func joinAuditIds(ids []int64) string {
+	strs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		strs = append(strs, strconv.FormatInt(id, 10))
+	}
+
+	s := strings.Join(strs, ",")
+	if len(s) > 1024 {
+		s = s[:1024]
+	}
+	return s
+}
+
+// auditSnapshot 获取资源当前状态，以 id 为 key 序列化为 json，敏感字段脱敏
+func (rt *Router) auditSnapshot(resourceType string, ids []int64) string {
+	if len(ids) == 0 {
+		return ""
+	}
+
+	snapshot := make(map[string]interface{}, len(ids))
+	for _, id := range ids {
+		obj, err := rt.auditLoad(resourceType, id)
+		if err != nil {
+			logger.Warningf("audit: failed to load %s:%d error: %v", resourceType, id, err)
+			continue
+		}
+
+		if obj != nil {
+			snapshot[strconv.FormatInt(id, 10)] = obj
+		}
+	}
+
+	if len(snapshot) == 0 {
+		return ""
+	}
+
+	return audit.Marshal(snapshot)
+}
+
+func (rt *Router) auditLoad(resourceType string, id int64) (interface{}, error) {
+	switch resourceType {
+	case models.AuditResourceAlertRule:
+		obj, err := models.AlertRuleGetById(rt.Ctx, id)
+		if err != nil || obj == nil {
+			return nil, err
+		}
+		return obj, nil
+	case models.AuditResourceRecordingRule:
+		obj, err := models.RecordingRuleGetById(rt.Ctx, id)
+		if err != nil || obj == nil {
+			return nil, err
+		}
+		return obj, obj.DB2FE()
+	case models.AuditResourceAlertMute:
+		obj, err := models.AlertMuteGetById(rt.Ctx, id)
+		if err != nil || obj == nil {
+			return nil, err
+		}
+		return obj, obj.DB2FE()
+	case models.AuditResourceAlertSubscribe:
+		obj, err := models.AlertSubscribeGet(rt.Ctx, "id=?", id)
+		if err != nil || obj == nil {
+			return nil, err
+		}
+		return obj, obj.DB2FE()
+	case models.AuditResourceNotifyRule:
+		obj, err := models.NotifyRuleGet(rt.Ctx, "id=?", id)
+		if err != nil || obj == nil {
+			return nil, err
+		}
+		obj.DB2FE()
+		return obj, nil
+	case models.AuditResourceNotifyChannel:
+		obj, err := models.NotifyChannelGet(rt.Ctx, "id=?", id)
+		if err != nil || obj == nil {
+			return nil, err
+		}
+		return obj, nil
+	case models.AuditResourceMessageTemplate:
+		obj, err := models.MessageTemplateGet(rt.Ctx, "id=?", id)
+		if err != nil || obj == nil {
+			return nil, err
+		}
+		obj.DB2FE()
+		return obj, nil
+	case models.AuditResourceSlo:
+		obj, err := models.SloGetById(rt.Ctx, id)
+		if err != nil || obj == nil {
+			return nil, err
+		}
+		return obj, nil
+	case models.AuditResourceReport:
+		obj, err := models.ReportGetById(rt.Ctx, id)
+		if err != nil || obj == nil {
+			return nil, err
+		}
+		return obj, nil
+	case models.AuditResourceRuleGroup:
+		obj, err := models.RuleGroupGetById(rt.Ctx, id)
+		if err != nil || obj == nil {
+			return nil, err
+		}
+		return obj, nil
+	case models.AuditResourceRemediationPolicy:
+		obj, err := models.RemediationPolicyGetById(rt.Ctx, id)
+		if err != nil || obj == nil {
+			return nil, err
+		}
+		return obj, nil
+	case models.AuditResourceRemediationRun:
+		obj, err := models.RemediationRunGetById(rt.Ctx, id)
+		if err != nil || obj == nil {
+			return nil, err
+		}
+		return obj, nil
+	case models.AuditResourceBoard:
+		obj, err := models.BoardGetByID(rt.Ctx, id)
+		if err != nil || obj == nil {
+			return nil, err
+		}
+		obj.Configs, err = models.BoardPayloadGet(rt.Ctx, id)
+		return obj, err
+	case models.AuditResourceDatasource:
+		obj, err := models.DatasourceGet(rt.Ctx, id)
+		if err != nil || obj == nil {
+			return nil, err
+		}
+		return obj, obj.DB2FE()
+	case models.AuditResourceUser:
+		obj, err := models.UserGetById(rt.Ctx, id)
+		if err != nil || obj == nil {
+			return nil, err
+		}
+		return obj, nil
+	case models.AuditResourceUserGroup:
+		obj, err := models.UserGroupGetById(rt.Ctx, id)
+		if err != nil || obj == nil {
+			return nil, err
+		}
+		return obj, nil
+	case models.AuditResourceBusiGroup:
+		obj, err := models.BusiGroupGetById(rt.Ctx, id)
+		if err != nil || obj == nil {
+			return nil, err
+		}
+		return obj, nil
+	case models.AuditResourceRole:
+		obj, err := models.RoleGet(rt.Ctx, "id=?", id)
+		if err != nil || obj == nil {
+			return nil, err
+		}
+		return obj, nil
+	case models.AuditResourceEventPipeline:
+		obj, err := models.GetEventPipeline(rt.Ctx, id)
+		if err != nil || obj == nil {
+			return nil, err
+		}
+		return obj, nil
+	}
+
+	return nil, nil
+}
+
+func (rt *Router) auditLogGets(c *gin.Context) {
+	limit := ginx.QueryInt(c, "limit", 20)
+	q := models.AuditLogQuery{
+		Username:     ginx.QueryStr(c, "username", ""),
+		ResourceType: ginx.QueryStr(c, "resource_type", ""),
+		ResourceId:   ginx.QueryStr(c, "resource_id", ""),
+		Action:       ginx.QueryStr(c, "action", ""),
+		Status:       ginx.QueryStr(c, "status", ""),
+		Stime:        ginx.QueryInt64(c, "stime", 0),
+		Etime:        ginx.QueryInt64(c, "etime", 0),
+		Query:        ginx.QueryStr(c, "query", ""),
+	}
+
+	total, err := models.AuditLogTotal(rt.Ctx, q)
+	ginx.Dangerous(err)
+
+	list, err := models.AuditLogGets(rt.Ctx, q, limit, ginx.Offset(c, limit))
+	ginx.Dangerous(err)
+
+	ginx.NewRender(c).Data(gin.H{
+		"list":  list,
+		"total": total,
+	}, nil)
+}
+
+func (rt *Router) auditLogGet(c *gin.Context) {
+	log, err := models.AuditLogGetById(rt.Ctx, ginx.UrlParamInt64(c, "id"))
+	ginx.Dangerous(err)
+
+	if log == nil {
+		ginx.Bomb(http.StatusNotFound, "No such audit log")
+	}
+
+	ginx.NewRender(c).Data(log, nil)
+}
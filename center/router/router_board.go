@@ -70,6 +70,7 @@ func (rt *Router) boardGet(c *gin.Context) {
 		rt.user()(c)
 
 		me := c.MustGet("user").(*models.User)
+		rt.tokenBusiGroupCheck(c, board.GroupId)
 		if !me.IsAdmin() {
 			// check permission
 			rt.bgroCheck(c, board.GroupId)
@@ -83,14 +84,17 @@ func (rt *Router) boardGet(c *gin.Context) {
 		rt.user()(c)
 
 		me := c.MustGet("user").(*models.User)
-		if !me.IsAdmin() {
-			bgids, err := models.MyBusiGroupIds(rt.Ctx, me.Id)
-			ginx.Dangerous(err)
-			if len(bgids) == 0 {
-				ginx.Bomb(http.StatusForbidden, "forbidden")
+		if !me.IsAdmin() || tokenBusiGroupRestricted(c) {
+			var bgids []int64
+			if !me.IsAdmin() {
+				bgids, err = models.MyBusiGroupIds(rt.Ctx, me.Id)
+				ginx.Dangerous(err)
+				if len(bgids) == 0 {
+					ginx.Bomb(http.StatusForbidden, "forbidden")
+				}
 			}
 
-			ok, err := models.BoardBusigroupCheck(rt.Ctx, board.Id, bgids)
+			ok, err := models.BoardBusigroupCheck(rt.Ctx, board.Id, tokenBusiGroupIds(c, bgids))
 			ginx.Dangerous(err)
 			if !ok {
 				ginx.Bomb(http.StatusForbidden, "forbidden")
@@ -141,6 +145,7 @@ func (rt *Router) boardDel(c *gin.Context) {
 		}
 
 		me := c.MustGet("user").(*models.User)
+		rt.tokenBusiGroupCheck(c, board.GroupId)
 		if !me.IsAdmin() {
 			// check permission
 			rt.bgrwCheck(c, board.GroupId)
@@ -171,6 +176,7 @@ func (rt *Router) boardPut(c *gin.Context) {
 	me := c.MustGet("user").(*models.User)
 	bo := rt.Board(ginx.UrlParamInt64(c, "bid"))
 
+	rt.tokenBusiGroupCheck(c, bo.GroupId)
 	if !me.IsAdmin() {
 		// check permission
 		rt.bgrwCheck(c, bo.GroupId)
@@ -210,6 +216,7 @@ func (rt *Router) boardPutConfigs(c *gin.Context) {
 	}
 
 	// check permission
+	rt.tokenBusiGroupCheck(c, bo.GroupId)
 	if !me.IsAdmin() {
 		rt.bgrwCheck(c, bo.GroupId)
 	}
@@ -233,6 +240,7 @@ func (rt *Router) boardPutPublic(c *gin.Context) {
 	bo := rt.Board(ginx.UrlParamInt64(c, "bid"))
 
 	// check permission
+	rt.tokenBusiGroupCheck(c, bo.GroupId)
 	if !me.IsAdmin() {
 		rt.bgrwCheck(c, bo.GroupId)
 	}
@@ -269,7 +277,12 @@ func (rt *Router) publicBoardGets(c *gin.Context) {
 	bgids, err := models.MyBusiGroupIds(rt.Ctx, me.Id)
 	ginx.Dangerous(err)
 
-	boardIds, err := models.BoardIdsByBusiGroupIds(rt.Ctx, bgids)
+	if len(bgids) == 0 {
+		// 不属于任何业务组时只能看到公开给所有人和登录用户的仪表盘，不能当作不限制业务组
+		bgids = []int64{-1}
+	}
+
+	boardIds, err := models.BoardIdsByBusiGroupIds(rt.Ctx, tokenBusiGroupIds(c, bgids))
 	ginx.Dangerous(err)
 
 	boards, err := models.BoardGets(rt.Ctx, "", "public=1 and (public_cate in (?) or id in (?))", []int64{0, 1}, boardIds)
@@ -298,6 +311,8 @@ func (rt *Router) boardGetsByGids(c *gin.Context) {
 		}
 	}
 
+	gids = tokenBusiGroupIds(c, gids)
+
 	boardBusigroups, err := models.BoardBusigroupGets(rt.Ctx)
 	ginx.Dangerous(err)
 	m := make(map[int64][]int64)
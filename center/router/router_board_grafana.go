@@ -0,0 +1,62 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/grafana"
+
+	"github.com/gin-gonic/gin"
+	"github.com/toolkits/pkg/ginx"
+)
+
+func grafanaConvert(c *gin.Context) *grafana.Board {
+	data, err := c.GetRawData()
+	ginx.Dangerous(err)
+
+	board, err := grafana.Convert(data)
+	if err != nil {
+		ginx.Bomb(http.StatusBadRequest, "%v", err)
+	}
+
+	if name := ginx.QueryStr(c, "name", ""); name != "" {
+		board.Name = name
+	}
+
+	return board
+}
+
+// boardGrafanaConvert 只转换不保存，请求体为 Grafana dashboard json，用于导入前预览转换结果和 warnings
+func (rt *Router) boardGrafanaConvert(c *gin.Context) {
+	ginx.NewRender(c).Data(grafanaConvert(c), nil)
+}
+
+// boardGrafanaImport 转换 Grafana dashboard json 并创建仪表盘
+func (rt *Router) boardGrafanaImport(c *gin.Context) {
+	converted := grafanaConvert(c)
+	if converted.Name == "" {
+		ginx.Bomb(http.StatusBadRequest, "dashboard name is blank")
+	}
+
+	configs, err := json.Marshal(converted.Configs)
+	ginx.Dangerous(err)
+
+	me := c.MustGet("user").(*models.User)
+	board := &models.Board{
+		GroupId:  ginx.UrlParamInt64(c, "id"),
+		Name:     converted.Name,
+		Tags:     converted.Tags,
+		Configs:  string(configs),
+		CreateBy: me.Username,
+		UpdateBy: me.Username,
+	}
+
+	ginx.Dangerous(board.Add(rt.Ctx))
+	ginx.Dangerous(models.BoardPayloadSave(rt.Ctx, board.Id, board.Configs))
+
+	ginx.NewRender(c).Data(gin.H{
+		"board":    board,
+		"warnings": converted.Warnings,
+	}, nil)
+}
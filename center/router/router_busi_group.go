@@ -116,6 +116,16 @@ func (rt *Router) busiGroupGets(c *gin.Context) {
 
 	me := c.MustGet("user").(*models.User)
 	lst, err := me.BusiGroups(rt.Ctx, limit, query, all)
+	if token := requestUserToken(c); token != nil {
+		allowed := lst[:0]
+		for _, bg := range lst {
+			if token.AllowBusiGroup(bg.Id) {
+				allowed = append(allowed, bg)
+			}
+		}
+		lst = allowed
+	}
+
 	if len(lst) == 0 {
 		lst = []models.BusiGroup{}
 	}
@@ -0,0 +1,26 @@
+package router
+
+import (
+	"time"
+
+	"github.com/ccfos/nightingale/v6/memsto"
+
+	"github.com/gin-gonic/gin"
+	"github.com/toolkits/pkg/ginx"
+)
+
+// maxCacheChangeWait 需要小于 http server 的 WriteTimeout
+const maxCacheChangeWait = 30
+
+// cacheChangesGet 边缘机房长轮询配置变更
+func (rt *Router) cacheChangesGet(c *gin.Context) {
+	wait := ginx.QueryInt(c, "wait", maxCacheChangeWait)
+	if wait < 0 || wait > maxCacheChangeWait {
+		wait = maxCacheChangeWait
+	}
+
+	epoch := ginx.QueryStr(c, "epoch", "")
+	seq := ginx.QueryInt64(c, "seq", 0)
+
+	ginx.NewRender(c).Data(memsto.CacheChangesSince(epoch, seq, time.Duration(wait)*time.Second), nil)
+}
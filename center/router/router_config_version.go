@@ -0,0 +1,404 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/center/audit"
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/slice"
+
+	"github.com/gin-gonic/gin"
+	"github.com/toolkits/pkg/ginx"
+	"github.com/toolkits/pkg/logger"
+)
+
+// 支持版本管理的资源类型，以及查看和回滚时需要的权限点
+var configVersionPerms = map[string][2]string{
+	models.AuditResourceAlertRule:       {"/alert-rules", "/alert-rules/put"},
+	models.AuditResourceRecordingRule:   {"/recording-rules", "/recording-rules/put"},
+	models.AuditResourceBoard:           {"/dashboards", "/dashboards/put"},
+	models.AuditResourceNotifyRule:      {"/notification-rules", "/notification-rules/put"},
+	models.AuditResourceMessageTemplate: {"/notification-templates", "/notification-templates/put"},
+}
+
+func (rt *Router) maxConfigVersions() int {
+	if rt.Center.MaxConfigVersions > 0 {
+		return rt.Center.MaxConfigVersions
+	}
+	return models.DefaultMaxConfigVersions
+}
+
+// configVersion 在更新成功后为资源保存一个版本快照
+// 资源第一次被修改时，会先把修改前的内容保存为初始版本，保证可以回滚到修改前的状态
+// idParam 为空时从请求体中解析 id，格式与 audit 中间件一致
+func (rt *Router) configVersion(resourceType, idParam string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ids []int64
+		if idParam != "" {
+			if id, err := rt.configVersionResourceId(resourceType, c.Param(idParam)); err == nil && id > 0 {
+				ids = append(ids, id)
+			}
+		} else if c.Request.Body != nil {
+			body, _ := io.ReadAll(c.Request.Body)
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			ids = parseAuditIds(body)
+		}
+
+		if len(ids) == 0 {
+			c.Next()
+			return
+		}
+
+		for _, id := range ids {
+			latest, err := models.ConfigVersionLatest(rt.Ctx, resourceType, id)
+			if err != nil {
+				logger.Warningf("config version: failed to get latest version of %s:%d error: %v", resourceType, id, err)
+				continue
+			}
+
+			if latest == nil {
+				rt.saveConfigVersion(resourceType, id, "initial version", "")
+			}
+		}
+
+		writer := &auditWriter{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = writer
+
+		c.Next()
+
+		msg, _ := parseAuditResponse(writer.body.Bytes())
+		if msg != "" || c.Writer.Status() >= http.StatusBadRequest {
+			return
+		}
+
+		username := c.GetString("username")
+		for _, id := range ids {
+			rt.saveConfigVersion(resourceType, id, "", username)
+		}
+	}
+}
+
+// configVersionAudit 回滚接口的资源类型在 url 中，回滚记录为对该资源的更新
+func (rt *Router) configVersionAudit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rt.auditAs(c.Param("type"), "id", models.AuditActionUpdate)(c)
+	}
+}
+
+// configVersionResourceId 仪表盘的 url 参数可能是 ident，需要转换为 id
+func (rt *Router) configVersionResourceId(resourceType, param string) (int64, error) {
+	if resourceType != models.AuditResourceBoard {
+		return strconv.ParseInt(param, 10, 64)
+	}
+
+	bo, err := models.BoardGet(rt.Ctx, "id = ? or ident = ?", param, param)
+	if err != nil || bo == nil {
+		return 0, err
+	}
+
+	return bo.Id, nil
+}
+
+func (rt *Router) saveConfigVersion(resourceType string, id int64, note, username string) {
+	content, err := rt.configVersionContent(resourceType, id)
+	if err != nil {
+		logger.Warningf("config version: failed to load %s:%d error: %v", resourceType, id, err)
+		return
+	}
+
+	if content == "" {
+		return
+	}
+
+	err = models.ConfigVersionAdd(rt.Ctx, resourceType, id, content, note, username, rt.maxConfigVersions())
+	if err != nil {
+		logger.Errorf("config version: failed to save %s:%d error: %v", resourceType, id, err)
+	}
+}
+
+// configVersionContent 获取资源当前的内容，仪表盘只保存 payload
+func (rt *Router) configVersionContent(resourceType string, id int64) (string, error) {
+	var obj interface{}
+	switch resourceType {
+	case models.AuditResourceAlertRule:
+		ar, err := models.AlertRuleGetById(rt.Ctx, id)
+		if err != nil || ar == nil {
+			return "", err
+		}
+		obj = ar
+	case models.AuditResourceRecordingRule:
+		rr, err := models.RecordingRuleGetById(rt.Ctx, id)
+		if err != nil || rr == nil {
+			return "", err
+		}
+		if err := rr.DB2FE(); err != nil {
+			return "", err
+		}
+		obj = rr
+	case models.AuditResourceBoard:
+		return models.BoardPayloadGet(rt.Ctx, id)
+	case models.AuditResourceNotifyRule:
+		nr, err := models.NotifyRuleGet(rt.Ctx, "id = ?", id)
+		if err != nil || nr == nil {
+			return "", err
+		}
+		nr.DB2FE()
+		obj = nr
+	case models.AuditResourceMessageTemplate:
+		mt, err := models.MessageTemplateGet(rt.Ctx, "id = ?", id)
+		if err != nil || mt == nil {
+			return "", err
+		}
+		mt.DB2FE()
+		obj = mt
+	default:
+		return "", fmt.Errorf("resource type %s not support versioning", resourceType)
+	}
+
+	bs, err := json.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+
+	return string(bs), nil
+}
+
+// configVersionCheck 校验当前用户对资源的查看或回滚权限
+func (rt *Router) configVersionCheck(c *gin.Context, resourceType string, id int64, write bool) {
+	perms, has := configVersionPerms[resourceType]
+	if !has {
+		ginx.Bomb(http.StatusBadRequest, "resource type %s not support versioning", resourceType)
+	}
+
+	me := c.MustGet("user").(*models.User)
+	op := perms[0]
+	if write {
+		op = perms[1]
+	}
+
+	can, err := me.CheckPerm(rt.Ctx, op)
+	ginx.Dangerous(err)
+	if !can {
+		ginx.Bomb(http.StatusForbidden, "forbidden")
+	}
+
+	var groupId int64
+	var userGroupIds []int64
+	switch resourceType {
+	case models.AuditResourceAlertRule:
+		ar, err := models.AlertRuleGetById(rt.Ctx, id)
+		ginx.Dangerous(err)
+		if ar == nil {
+			ginx.Bomb(http.StatusNotFound, "No such AlertRule")
+		}
+		groupId = ar.GroupId
+	case models.AuditResourceRecordingRule:
+		rr, err := models.RecordingRuleGetById(rt.Ctx, id)
+		ginx.Dangerous(err)
+		if rr == nil {
+			ginx.Bomb(http.StatusNotFound, "No such recording rule")
+		}
+		groupId = rr.GroupId
+	case models.AuditResourceBoard:
+		bo, err := models.BoardGetByID(rt.Ctx, id)
+		ginx.Dangerous(err)
+		if bo == nil {
+			ginx.Bomb(http.StatusNotFound, "No such dashboard")
+		}
+		groupId = bo.GroupId
+	case models.AuditResourceNotifyRule:
+		nr, err := models.NotifyRuleGet(rt.Ctx, "id = ?", id)
+		ginx.Dangerous(err)
+		if nr == nil {
+			ginx.Bomb(http.StatusNotFound, "notify rule not found")
+		}
+		userGroupIds = nr.UserGroupIds
+	case models.AuditResourceMessageTemplate:
+		mt, err := models.MessageTemplateGet(rt.Ctx, "id = ?", id)
+		ginx.Dangerous(err)
+		if mt == nil {
+			ginx.Bomb(http.StatusNotFound, "message template not found")
+		}
+		userGroupIds = mt.UserGroupIds
+	}
+
+	if groupId > 0 {
+		if write {
+			rt.bgrwCheck(c, groupId)
+		} else {
+			rt.bgroCheck(c, groupId)
+		}
+		return
+	}
+
+	if me.IsAdmin() {
+		return
+	}
+
+	gids, err := models.MyGroupIds(rt.Ctx, me.Id)
+	ginx.Dangerous(err)
+	if !slice.HaveIntersection(gids, userGroupIds) {
+		ginx.Bomb(http.StatusForbidden, "forbidden")
+	}
+}
+
+func (rt *Router) configVersionGets(c *gin.Context) {
+	typ := ginx.UrlParamStr(c, "type")
+	id := ginx.UrlParamInt64(c, "id")
+	rt.configVersionCheck(c, typ, id, false)
+
+	lst, err := models.ConfigVersionGets(rt.Ctx, typ, id)
+	ginx.NewRender(c).Data(lst, err)
+}
+
+func (rt *Router) configVersionGet(c *gin.Context) {
+	typ := ginx.UrlParamStr(c, "type")
+	id := ginx.UrlParamInt64(c, "id")
+	rt.configVersionCheck(c, typ, id, false)
+
+	v, err := models.ConfigVersionGet(rt.Ctx, typ, id, ginx.UrlParamInt64(c, "version"))
+	ginx.Dangerous(err)
+	if v == nil {
+		ginx.Bomb(http.StatusNotFound, "No such version")
+	}
+
+	ginx.NewRender(c).Data(v, nil)
+}
+
+// configVersionDiff 对比两个版本，to 为空时与资源当前内容对比
+func (rt *Router) configVersionDiff(c *gin.Context) {
+	typ := ginx.UrlParamStr(c, "type")
+	id := ginx.UrlParamInt64(c, "id")
+	rt.configVersionCheck(c, typ, id, false)
+
+	from, err := models.ConfigVersionGet(rt.Ctx, typ, id, ginx.QueryInt64(c, "from"))
+	ginx.Dangerous(err)
+	if from == nil {
+		ginx.Bomb(http.StatusNotFound, "No such version")
+	}
+
+	var toContent string
+	if toVersion := ginx.QueryInt64(c, "to", 0); toVersion > 0 {
+		to, err := models.ConfigVersionGet(rt.Ctx, typ, id, toVersion)
+		ginx.Dangerous(err)
+		if to == nil {
+			ginx.Bomb(http.StatusNotFound, "No such version")
+		}
+		toContent = to.Content
+	} else {
+		toContent, err = rt.configVersionContent(typ, id)
+		ginx.Dangerous(err)
+	}
+
+	changes := json.RawMessage("[]")
+	if diff := audit.Diff(from.Content, toContent); diff != "" {
+		changes = json.RawMessage(diff)
+	}
+
+	ginx.NewRender(c).Data(gin.H{
+		"from":    from.Content,
+		"to":      toContent,
+		"changes": changes,
+	}, nil)
+}
+
+// configVersionRestore 将资源回滚到指定版本，回滚后的内容会保存为一个新版本
+func (rt *Router) configVersionRestore(c *gin.Context) {
+	typ := ginx.UrlParamStr(c, "type")
+	id := ginx.UrlParamInt64(c, "id")
+	version := ginx.UrlParamInt64(c, "version")
+	rt.configVersionCheck(c, typ, id, true)
+
+	v, err := models.ConfigVersionGet(rt.Ctx, typ, id, version)
+	ginx.Dangerous(err)
+	if v == nil {
+		ginx.Bomb(http.StatusNotFound, "No such version")
+	}
+
+	me := c.MustGet("user").(*models.User)
+
+	// 回滚前当前内容可能还没有版本记录，先保存一份
+	rt.saveConfigVersion(typ, id, "", me.Username)
+	ginx.Dangerous(rt.restoreConfigVersion(typ, id, v.Content, me.Username))
+	rt.saveConfigVersion(typ, id, fmt.Sprintf("restore from version %d", version), me.Username)
+
+	ginx.NewRender(c).Message(nil)
+}
+
+func (rt *Router) restoreConfigVersion(resourceType string, id int64, content, username string) error {
+	switch resourceType {
+	case models.AuditResourceAlertRule:
+		ar, err := models.AlertRuleGetById(rt.Ctx, id)
+		if err != nil {
+			return err
+		}
+
+		var f models.AlertRule
+		if err := json.Unmarshal([]byte(content), &f); err != nil {
+			return err
+		}
+
+		f.UpdateBy = username
+		return ar.Update(rt.Ctx, f)
+	case models.AuditResourceRecordingRule:
+		rr, err := models.RecordingRuleGetById(rt.Ctx, id)
+		if err != nil {
+			return err
+		}
+
+		var f models.RecordingRule
+		if err := json.Unmarshal([]byte(content), &f); err != nil {
+			return err
+		}
+
+		f.UpdateBy = username
+		return rr.Update(rt.Ctx, f)
+	case models.AuditResourceBoard:
+		bo, err := models.BoardGetByID(rt.Ctx, id)
+		if err != nil {
+			return err
+		}
+
+		bo.UpdateBy = username
+		bo.UpdateAt = time.Now().Unix()
+		if err := bo.Update(rt.Ctx, "update_by", "update_at"); err != nil {
+			return err
+		}
+
+		return models.BoardPayloadSave(rt.Ctx, id, content)
+	case models.AuditResourceNotifyRule:
+		nr, err := models.NotifyRuleGet(rt.Ctx, "id = ?", id)
+		if err != nil {
+			return err
+		}
+
+		var f models.NotifyRule
+		if err := json.Unmarshal([]byte(content), &f); err != nil {
+			return err
+		}
+
+		f.UpdateBy = username
+		return nr.Update(rt.Ctx, f)
+	case models.AuditResourceMessageTemplate:
+		mt, err := models.MessageTemplateGet(rt.Ctx, "id = ?", id)
+		if err != nil {
+			return err
+		}
+
+		var f models.MessageTemplate
+		if err := json.Unmarshal([]byte(content), &f); err != nil {
+			return err
+		}
+
+		f.UpdateBy = username
+		return mt.Update(rt.Ctx, f)
+	}
+
+	return fmt.Errorf("resource type %s not support versioning", resourceType)
+}
@@ -0,0 +1,134 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ccfos/nightingale/v6/center/audit"
+	"github.com/ccfos/nightingale/v6/center/cconf"
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/aop"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func newConfigVersionRouter(t *testing.T) (*Router, *gin.Engine) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AutoMigrate(&models.MessageTemplate{}, &models.ConfigVersion{}, &models.AuditLog{}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := ctx.NewContext(context.Background(), db, true)
+	rt := &Router{Ctx: c, Auditor: audit.New(c, cconf.Audit{Enable: true})}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(aop.Recovery())
+	login := func(c *gin.Context) {
+		c.Set("username", "root")
+		c.Set("user", &models.User{Username: "root", RolesLst: []string{models.AdminRole}})
+	}
+	r.PUT("/message-template/:id", login, rt.configVersion(models.AuditResourceMessageTemplate, "id"), func(c *gin.Context) {
+		var f models.MessageTemplate
+		if err := c.ShouldBindJSON(&f); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"err": err.Error()})
+			return
+		}
+
+		mt, _ := models.MessageTemplateGet(rt.Ctx, "id = ?", c.Param("id"))
+		c.JSON(http.StatusOK, gin.H{"err": errString(mt.Update(rt.Ctx, f))})
+	})
+	r.POST("/config-version/:type/:id/:version/restore", login, rt.configVersionAudit(), rt.configVersionRestore)
+
+	return rt, r
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func doJSON(t *testing.T, r *gin.Engine, method, url string, body interface{}) *httptest.ResponseRecorder {
+	bs, _ := json.Marshal(body)
+	req := httptest.NewRequest(method, url, bytes.NewReader(bs))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestConfigVersionRestore(t *testing.T) {
+	rt, r := newConfigVersionRouter(t)
+
+	mt := &models.MessageTemplate{Name: "tpl", Ident: "tpl", Content: map[string]string{"content": "v1"}}
+	if err := models.Insert(rt.Ctx, mt); err != nil {
+		t.Fatal(err)
+	}
+
+	// 第一次修改时先保存修改前的初始版本
+	update := *mt
+	update.Content = map[string]string{"content": "v2"}
+	if w := doJSON(t, r, http.MethodPut, "/message-template/1", update); w.Code != http.StatusOK {
+		t.Fatalf("update failed: %s", w.Body.String())
+	}
+
+	lst, err := models.ConfigVersionGets(rt.Ctx, models.AuditResourceMessageTemplate, mt.ID)
+	if err != nil || len(lst) != 2 {
+		t.Fatalf("expected 2 versions, got %+v %v", lst, err)
+	}
+
+	if w := doJSON(t, r, http.MethodPost, "/config-version/message_template/1/1/restore", nil); w.Code != http.StatusOK {
+		t.Fatalf("restore failed: %s", w.Body.String())
+	}
+
+	got, err := models.MessageTemplateGet(rt.Ctx, "id = ?", mt.ID)
+	if err != nil || got.Content["content"] != "v1" || got.UpdateBy != "root" {
+		t.Fatalf("unexpected template after restore: %+v %v", got, err)
+	}
+
+	// 回滚后的内容保存为新版本
+	latest, err := models.ConfigVersionLatest(rt.Ctx, models.AuditResourceMessageTemplate, mt.ID)
+	if err != nil || latest.Version != 3 || latest.Note != "restore from version 1" {
+		t.Fatalf("unexpected latest version: %+v %v", latest, err)
+	}
+
+	var logs []*models.AuditLog
+	if err := models.DB(rt.Ctx).Find(&logs).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logs) != 1 || logs[0].ResourceType != models.AuditResourceMessageTemplate || logs[0].ResourceIds != "1" ||
+		logs[0].Username != "root" || logs[0].Status != models.AuditStatusSuccess || logs[0].Diff == "" {
+		t.Fatalf("unexpected audit logs: %+v", logs[0])
+	}
+}
+
+func TestConfigVersionRestoreNotFound(t *testing.T) {
+	rt, r := newConfigVersionRouter(t)
+
+	mt := &models.MessageTemplate{Name: "tpl", Ident: "tpl", Content: map[string]string{"content": "v1"}}
+	if err := models.Insert(rt.Ctx, mt); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := doJSON(t, r, http.MethodPost, "/config-version/message_template/1/9/restore", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d %s", w.Code, w.Body.String())
+	}
+
+	if w := doJSON(t, r, http.MethodPost, "/config-version/target/1/1/restore", nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unsupported type, got %d %s", w.Code, w.Body.String())
+	}
+}
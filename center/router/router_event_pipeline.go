@@ -185,6 +185,7 @@ func (rt *Router) tryRunEventPipeline(c *gin.Context) {
 		Mode:         models.TriggerModeAPI,
 		TriggerBy:    me.Username,
 		EnvOverrides: f.EnvVariables,
+		TryRun:       true,
 	}
 
 	resultEvent, result, err := workflowEngine.Execute(&f.PipelineConfig, event, triggerCtx)
@@ -225,8 +226,9 @@ func (rt *Router) tryRunEventProcessor(c *gin.Context) {
 		ginx.Bomb(200, "get processor err: %+v", err)
 	}
 	wfCtx := &models.WorkflowContext{
-		Event: event,
-		Vars:  make(map[string]interface{}),
+		Event:    event,
+		Vars:     make(map[string]interface{}),
+		Metadata: map[string]string{"tryrun": "true"},
 	}
 	wfCtx, res, err := processor.Process(rt.Ctx, wfCtx)
 	if err != nil {
@@ -266,8 +268,9 @@ func (rt *Router) tryRunEventProcessorByNotifyRule(c *gin.Context) {
 	}
 
 	wfCtx := &models.WorkflowContext{
-		Event: event,
-		Vars:  make(map[string]interface{}),
+		Event:    event,
+		Vars:     make(map[string]interface{}),
+		Metadata: map[string]string{"tryrun": "true"},
 	}
 	for _, pl := range pipelines {
 		for _, p := range pl.ProcessorConfigs {
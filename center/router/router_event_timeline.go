@@ -0,0 +1,33 @@
+package router
+
+import (
+	"github.com/ccfos/nightingale/v6/alert/timeline"
+	"github.com/ccfos/nightingale/v6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/toolkits/pkg/ginx"
+)
+
+// eventTimelineGet 按事件 hash 返回告警从首次触发到恢复的全部处理过程
+func (rt *Router) eventTimelineGet(c *gin.Context) {
+	hash := ginx.UrlParamStr(c, "hash")
+	groupId, exists, err := models.EventTimelineGroupId(rt.Ctx, hash)
+	ginx.Dangerous(err)
+	if !exists {
+		ginx.Bomb(404, "No such alert event")
+	}
+
+	rt.bgroCheck(c, groupId)
+
+	lst, err := models.EventTimelineGets(rt.Ctx, hash)
+	ginx.NewRender(c).Data(lst, err)
+}
+
+func (rt *Router) eventTimelineAdd(c *gin.Context) {
+	var req []*models.EventTimeline
+	ginx.BindJSON(c, &req)
+	err := timeline.Push(req)
+	ginx.Dangerous(err, 429)
+
+	ginx.NewRender(c).Data(nil, err)
+}
@@ -30,6 +30,8 @@ func (rt *Router) statistic(c *gin.Context) {
 		model = models.BusiGroup{}
 	case "recording_rule":
 		model = models.RecordingRule{}
+	case "rule_group":
+		model = models.RuleGroup{}
 	case "target":
 		model = models.Target{}
 	case "user":
@@ -84,17 +84,27 @@ func (rt *Router) loginPost(c *gin.Context) {
 		return
 	}
 
+	// 本地账号开启了 MFA 或角色要求 MFA 时，先返回 mfa_token，校验动态码后再签发 jwt
+	if challenge := rt.mfaChallenge(c, user); challenge != nil {
+		ginx.NewRender(c).Data(challenge, nil)
+		return
+	}
+
+	ginx.NewRender(c).Data(rt.issueLoginTokens(c, user), nil)
+}
+
+func (rt *Router) issueLoginTokens(c *gin.Context, user *models.User) gin.H {
 	userIdentity := fmt.Sprintf("%d-%s", user.Id, user.Username)
 
 	ts, err := rt.createTokens(rt.HTTP.JWTAuth.SigningKey, userIdentity)
 	ginx.Dangerous(err)
 	ginx.Dangerous(rt.createAuth(c.Request.Context(), userIdentity, ts))
 
-	ginx.NewRender(c).Data(gin.H{
+	return gin.H{
 		"user":          user,
 		"access_token":  ts.AccessToken,
 		"refresh_token": ts.RefreshToken,
-	}, nil)
+	}
 }
 
 func (rt *Router) logoutPost(c *gin.Context) {
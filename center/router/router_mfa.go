@@ -0,0 +1,333 @@
+package router
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/totp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/toolkits/pkg/ginx"
+	"github.com/toolkits/pkg/logger"
+)
+
+const (
+	mfaIssuer       = "Nightingale"
+	mfaChallengeTTL = 5 * time.Minute
+	mfaMaxAttempts  = 5
+	mfaValidateSkew = 1
+	mfaChallengeKey = "mfa_challenge_"
+	mfaAttemptsKey  = "mfa_attempts_"
+)
+
+// mfaChallenge 判断本地账号登录是否需要二次验证，需要时生成一次性的 mfa_token 并返回给前端
+// 返回 nil 表示不需要 MFA，可以直接签发 jwt
+func (rt *Router) mfaChallenge(c *gin.Context, user *models.User) gin.H {
+	if user.Belong != "" {
+		// SSO 账号由外部系统负责认证
+		return nil
+	}
+
+	m, err := models.UserMfaGet(rt.Ctx, user.Id)
+	ginx.Dangerous(err)
+
+	enrolled := m != nil && m.Enabled
+	if !enrolled {
+		policy, err := models.MfaPolicyGet(rt.Ctx)
+		ginx.Dangerous(err)
+
+		if !policy.Required(user) {
+			return nil
+		}
+	}
+
+	token := uuid.NewString()
+	err = rt.Redis.Set(c.Request.Context(), rt.wrapJwtKey(mfaChallengeKey+token), user.Id, mfaChallengeTTL).Err()
+	ginx.Dangerous(err)
+
+	return gin.H{
+		"mfa_required": true,
+		"mfa_enrolled": enrolled,
+		"mfa_token":    token,
+	}
+}
+
+// mfaChallengeUser 根据 mfa_token 获取登录中的用户，超过最大尝试次数后 mfa_token 失效
+func (rt *Router) mfaChallengeUser(c *gin.Context, token string) *models.User {
+	if token == "" {
+		ginx.Bomb(http.StatusBadRequest, "mfa_token required")
+	}
+
+	ctx := c.Request.Context()
+	key := rt.wrapJwtKey(mfaChallengeKey + token)
+	val, err := rt.Redis.Get(ctx, key).Result()
+	if err != nil || val == "" {
+		ginx.Bomb(http.StatusUnauthorized, "mfa_token expired, please login again")
+	}
+
+	attemptsKey := rt.wrapJwtKey(mfaAttemptsKey + token)
+	attempts, err := rt.Redis.Incr(ctx, attemptsKey).Result()
+	ginx.Dangerous(err)
+	rt.Redis.Expire(ctx, attemptsKey, mfaChallengeTTL)
+
+	if attempts > mfaMaxAttempts {
+		rt.Redis.Del(ctx, key, attemptsKey)
+		ginx.Bomb(http.StatusUnauthorized, "too many attempts, please login again")
+	}
+
+	userId, err := strconv.ParseInt(val, 10, 64)
+	ginx.Dangerous(err)
+
+	user, err := models.UserGetById(rt.Ctx, userId)
+	ginx.Dangerous(err)
+	if user == nil {
+		ginx.Bomb(http.StatusUnauthorized, "unauthorized")
+	}
+
+	return user
+}
+
+// mfaChallengeConsume 校验通过后使用 GETDEL 原子地取出并删除 mfa_token，并发请求中只有一个能完成登录
+func (rt *Router) mfaChallengeConsume(c *gin.Context, token string, userId int64) {
+	ctx := c.Request.Context()
+	val, err := rt.Redis.GetDel(ctx, rt.wrapJwtKey(mfaChallengeKey+token)).Result()
+	rt.Redis.Del(ctx, rt.wrapJwtKey(mfaAttemptsKey+token))
+
+	if err != nil || val != strconv.FormatInt(userId, 10) {
+		ginx.Bomb(http.StatusUnauthorized, "mfa_token expired, please login again")
+	}
+}
+
+type mfaLoginForm struct {
+	MfaToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// mfaLoginEnroll 角色要求 MFA 但尚未绑定的用户，在登录过程中生成密钥
+func (rt *Router) mfaLoginEnroll(c *gin.Context) {
+	var f mfaLoginForm
+	ginx.BindJSON(c, &f)
+
+	user := rt.mfaChallengeUser(c, f.MfaToken)
+
+	m, err := models.UserMfaGet(rt.Ctx, user.Id)
+	ginx.Dangerous(err)
+	if m != nil && m.Enabled {
+		ginx.Bomb(http.StatusBadRequest, "mfa already enabled")
+	}
+
+	ginx.NewRender(c).Data(rt.mfaPrepare(user), nil)
+}
+
+// mfaLoginVerify 校验动态码或恢复码后签发 jwt，如果是首次绑定，同时返回恢复码
+func (rt *Router) mfaLoginVerify(c *gin.Context) {
+	var f mfaLoginForm
+	ginx.BindJSON(c, &f)
+
+	user := rt.mfaChallengeUser(c, f.MfaToken)
+
+	m, err := models.UserMfaGet(rt.Ctx, user.Id)
+	ginx.Dangerous(err)
+	if m == nil || m.Secret == "" {
+		ginx.Bomb(http.StatusBadRequest, "mfa not enrolled")
+	}
+
+	if m.Enabled {
+		rt.mfaVerify(m, f.Code, f.RecoveryCode)
+	} else if !rt.mfaCheckCode(m, f.Code) {
+		ginx.Bomb(http.StatusUnauthorized, "invalid mfa code")
+	}
+
+	rt.mfaChallengeConsume(c, f.MfaToken, user.Id)
+
+	var recoveryCodes []string
+	if !m.Enabled {
+		recoveryCodes, err = m.Enable(rt.Ctx)
+		ginx.Dangerous(err)
+	}
+	logger.Infof("username:%s login with mfa from:%s", user.Username, c.ClientIP())
+
+	data := rt.issueLoginTokens(c, user)
+	if len(recoveryCodes) > 0 {
+		data["recovery_codes"] = recoveryCodes
+	}
+
+	ginx.NewRender(c).Data(data, nil)
+}
+
+// mfaCheckCode 校验动态码，同一个动态码以及更早时间步的动态码只能使用一次
+func (rt *Router) mfaCheckCode(m *models.UserMfa, code string) bool {
+	secret, err := m.PlainSecret(rt.Ctx)
+	ginx.Dangerous(err)
+
+	counter, ok := totp.Match(secret, code, time.Now(), mfaValidateSkew)
+	if !ok {
+		return false
+	}
+
+	used, err := m.UseCounter(rt.Ctx, counter)
+	ginx.Dangerous(err)
+
+	return used
+}
+
+// mfaVerify 校验动态码，动态码为空时尝试恢复码
+func (rt *Router) mfaVerify(m *models.UserMfa, code, recoveryCode string) {
+	if code != "" {
+		if !rt.mfaCheckCode(m, code) {
+			ginx.Bomb(http.StatusUnauthorized, "invalid mfa code")
+		}
+		return
+	}
+
+	if recoveryCode == "" {
+		ginx.Bomb(http.StatusBadRequest, "code required")
+	}
+
+	ok, err := m.UseRecoveryCode(rt.Ctx, recoveryCode)
+	ginx.Dangerous(err)
+	if !ok {
+		ginx.Bomb(http.StatusUnauthorized, "invalid recovery code")
+	}
+}
+
+func (rt *Router) mfaPrepare(user *models.User) gin.H {
+	secret, err := totp.GenerateSecret()
+	ginx.Dangerous(err)
+	ginx.Dangerous(models.UserMfaPrepare(rt.Ctx, user.Id, secret))
+
+	return gin.H{
+		"secret": secret,
+		"uri":    totp.ProvisioningURI(mfaIssuer, user.Username, secret),
+	}
+}
+
+func (rt *Router) selfMfaGet(c *gin.Context) {
+	me := c.MustGet("user").(*models.User)
+
+	m, err := models.UserMfaGet(rt.Ctx, me.Id)
+	ginx.Dangerous(err)
+
+	policy, err := models.MfaPolicyGet(rt.Ctx)
+	ginx.Dangerous(err)
+
+	ginx.NewRender(c).Data(gin.H{
+		"enabled":  m != nil && m.Enabled,
+		"required": me.Belong == "" && policy.Required(me),
+	}, nil)
+}
+
+func (rt *Router) selfMfaEnroll(c *gin.Context) {
+	me := c.MustGet("user").(*models.User)
+	if me.Belong != "" {
+		ginx.Bomb(http.StatusBadRequest, "mfa is only available for local accounts")
+	}
+
+	m, err := models.UserMfaGet(rt.Ctx, me.Id)
+	ginx.Dangerous(err)
+	if m != nil && m.Enabled {
+		ginx.Bomb(http.StatusBadRequest, "mfa already enabled")
+	}
+
+	ginx.NewRender(c).Data(rt.mfaPrepare(me), nil)
+}
+
+type mfaCodeForm struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+func (rt *Router) selfMfaActivate(c *gin.Context) {
+	var f mfaCodeForm
+	ginx.BindJSON(c, &f)
+
+	me := c.MustGet("user").(*models.User)
+	m, err := models.UserMfaGet(rt.Ctx, me.Id)
+	ginx.Dangerous(err)
+	if m == nil || m.Secret == "" {
+		ginx.Bomb(http.StatusBadRequest, "mfa not enrolled")
+	}
+
+	if m.Enabled {
+		ginx.Bomb(http.StatusBadRequest, "mfa already enabled")
+	}
+
+	if !rt.mfaCheckCode(m, f.Code) {
+		ginx.Bomb(http.StatusBadRequest, "invalid mfa code")
+	}
+
+	codes, err := m.Enable(rt.Ctx)
+	ginx.NewRender(c).Data(codes, err)
+}
+
+func (rt *Router) selfMfaRecoveryCodes(c *gin.Context) {
+	var f mfaCodeForm
+	ginx.BindJSON(c, &f)
+
+	me := c.MustGet("user").(*models.User)
+	m := rt.selfMfaEnabled(me)
+	rt.mfaVerify(m, f.Code, "")
+
+	codes, err := m.RegenerateRecoveryCodes(rt.Ctx)
+	ginx.NewRender(c).Data(codes, err)
+}
+
+func (rt *Router) selfMfaDel(c *gin.Context) {
+	var f mfaCodeForm
+	ginx.BindJSON(c, &f)
+
+	me := c.MustGet("user").(*models.User)
+
+	policy, err := models.MfaPolicyGet(rt.Ctx)
+	ginx.Dangerous(err)
+	if policy.Required(me) {
+		ginx.Bomb(http.StatusBadRequest, "mfa is required by your role and cannot be disabled")
+	}
+
+	m := rt.selfMfaEnabled(me)
+	rt.mfaVerify(m, f.Code, f.RecoveryCode)
+
+	ginx.NewRender(c).Message(models.UserMfaDel(rt.Ctx, me.Id))
+}
+
+func (rt *Router) selfMfaEnabled(me *models.User) *models.UserMfa {
+	m, err := models.UserMfaGet(rt.Ctx, me.Id)
+	ginx.Dangerous(err)
+	if m == nil || !m.Enabled {
+		ginx.Bomb(http.StatusBadRequest, "mfa not enabled")
+	}
+
+	return m
+}
+
+// userMfaReset 管理员重置用户的 MFA，用户下次登录时重新绑定
+func (rt *Router) userMfaReset(c *gin.Context) {
+	user := User(rt.Ctx, ginx.UrlParamInt64(c, "id"))
+	me := c.MustGet("user").(*models.User)
+
+	ginx.Dangerous(models.UserMfaDel(rt.Ctx, user.Id))
+	logger.Infof("mfa of user:%s reset by %s", user.Username, me.Username)
+
+	ginx.NewRender(c).Message(nil)
+}
+
+func (rt *Router) mfaPolicyGet(c *gin.Context) {
+	policy, err := models.MfaPolicyGet(rt.Ctx)
+	ginx.NewRender(c).Data(policy, err)
+}
+
+func (rt *Router) mfaPolicyPut(c *gin.Context) {
+	var f models.MfaPolicy
+	ginx.BindJSON(c, &f)
+
+	if f.RequiredRoles == nil {
+		f.RequiredRoles = []string{}
+	}
+
+	me := c.MustGet("user").(*models.User)
+	ginx.NewRender(c).Message(models.MfaPolicySet(rt.Ctx, &f, me.Username))
+}
@@ -0,0 +1,36 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ccfos/nightingale/v6/pkg/aop"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestMfaChallengeConsumeOnce(t *testing.T) {
+	s := miniredis.RunT(t)
+	rt := &Router{Redis: redis.NewClient(&redis.Options{Addr: s.Addr()})}
+	s.Set(mfaChallengeKey+"tk", "7")
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(aop.Recovery())
+	r.POST("/consume", func(c *gin.Context) {
+		rt.mfaChallengeConsume(c, "tk", 7)
+		c.Status(http.StatusOK)
+	})
+
+	// 同一个 mfa_token 只能完成一次登录
+	for i, want := range []int{http.StatusOK, http.StatusUnauthorized} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/consume", nil))
+		if w.Code != want {
+			t.Fatalf("request %d: want %d, got %d", i, want, w.Code)
+		}
+	}
+}
@@ -46,6 +46,8 @@ func (rt *Router) alertMuteGetsByGids(c *gin.Context) {
 		}
 	}
 
+	gids = tokenBusiGroupIds(c, gids)
+
 	lst, err := models.AlertMuteGetsByBGIds(rt.Ctx, gids)
 
 	ginx.NewRender(c).Data(lst, err)
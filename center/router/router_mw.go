@@ -79,10 +79,12 @@ func (rt *Router) tokenAuth() gin.HandlerFunc {
 			}
 			token := c.GetHeader(tokenKey)
 			if token != "" {
-				user := rt.UserTokenCache.GetByToken(token)
+				user, userToken := rt.UserTokenCache.GetByToken(token)
 				if user != nil && user.Username != "" {
+					rt.userTokenCheck(c, userToken)
 					c.Set("userid", user.Id)
 					c.Set("username", user.Username)
+					c.Set("user_token", userToken)
 					c.Next()
 					return
 				}
@@ -196,6 +198,7 @@ func (rt *Router) bgro() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		me := c.MustGet("user").(*models.User)
 		bg := BusiGroup(rt.Ctx, ginx.UrlParamInt64(c, "id"))
+		rt.tokenBusiGroupCheck(c, bg.Id)
 
 		can, err := me.CanDoBusiGroup(rt.Ctx, bg)
 		ginx.Dangerous(err)
@@ -209,6 +212,127 @@ func (rt *Router) bgro() gin.HandlerFunc {
 	}
 }
 
+// 只读 token 可以访问的查询类 POST 接口
+var readOnlyPostPaths = map[string]struct{}{
+	"/api/n9e/query-range-batch":   {},
+	"/api/n9e/query-instant-batch": {},
+	"/api/n9e/datasource/query":    {},
+	"/api/n9e/ds-query":            {},
+	"/api/n9e/logs-query":          {},
+	"/api/n9e/log-query-batch":     {},
+	"/api/n9e/log-query":           {},
+	"/api/n9e/datasource/list":     {},
+	"/api/n9e/target/list":         {},
+}
+
+// userTokenCheck 校验个人 token 的 scope 和 api 前缀限制
+func (rt *Router) userTokenCheck(c *gin.Context, token *models.UserToken) {
+	if token == nil {
+		return
+	}
+
+	if !token.AllowPath(c.Request.URL.Path) {
+		ginx.Bomb(http.StatusForbidden, "token is not allowed to access this api")
+	}
+
+	if token.Scope != models.TokenScopeReadOnly {
+		return
+	}
+
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return
+	case http.MethodPost:
+		if _, has := readOnlyPostPaths[c.FullPath()]; has {
+			return
+		}
+	}
+
+	ginx.Bomb(http.StatusForbidden, "token is read-only")
+}
+
+// revokeUserToken 删除 token 后立即在本实例中失效，删除操作会通过缓存变更通知其他实例重新同步 token
+func (rt *Router) revokeUserToken(tokenHash string) {
+	rt.UserTokenCache.Revoke(tokenHash)
+}
+
+// requestUserToken 返回本次请求使用的个人 token，通过登录认证时返回 nil
+func requestUserToken(c *gin.Context) *models.UserToken {
+	v, has := c.Get("user_token")
+	if !has {
+		return nil
+	}
+
+	token, _ := v.(*models.UserToken)
+	return token
+}
+
+// tokenBusiGroupIds 个人 token 限制了业务组时，把列表接口的业务组范围收窄到 token 允许的业务组
+// gids 为空表示不限制业务组；收窄后没有可访问的业务组时返回 [-1]，避免查询到全部数据或未归组的数据
+func tokenBusiGroupIds(c *gin.Context, gids []int64) []int64 {
+	token := requestUserToken(c)
+	if token == nil || len(token.BusiGroupIds) == 0 {
+		return gids
+	}
+
+	if len(gids) == 0 {
+		return append([]int64{}, token.BusiGroupIds...)
+	}
+
+	ret := make([]int64, 0, len(gids))
+	for _, gid := range gids {
+		if token.AllowBusiGroup(gid) {
+			ret = append(ret, gid)
+		}
+	}
+
+	if len(ret) == 0 {
+		return []int64{-1}
+	}
+
+	return ret
+}
+
+// tokenBusiGroupRestricted 本次请求使用的个人 token 是否限制了业务组
+func tokenBusiGroupRestricted(c *gin.Context) bool {
+	token := requestUserToken(c)
+	return token != nil && len(token.BusiGroupIds) > 0
+}
+
+// tokenBusiGroupCheck 个人 token 限制了业务组时，只能访问指定的业务组，管理员也不例外
+func (rt *Router) tokenBusiGroupCheck(c *gin.Context, bgid int64) {
+	token := requestUserToken(c)
+	if token == nil {
+		return
+	}
+
+	if !token.AllowBusiGroup(bgid) {
+		ginx.Bomb(http.StatusForbidden, "token is not allowed to access this busi group")
+	}
+}
+
+// headerTokenBusiGroupCheck 允许匿名访问的接口不走认证，请求仍然带了个人 token 时也按 token 的业务组范围检查
+func (rt *Router) headerTokenBusiGroupCheck(c *gin.Context, bgid int64) {
+	if !rt.HTTP.TokenAuth.Enable {
+		return
+	}
+
+	tokenKey := rt.HTTP.TokenAuth.HeaderUserTokenKey
+	if tokenKey == "" {
+		tokenKey = DefaultTokenKey
+	}
+
+	token := c.GetHeader(tokenKey)
+	if token == "" {
+		return
+	}
+
+	_, userToken := rt.UserTokenCache.GetByToken(token)
+	if userToken != nil && !userToken.AllowBusiGroup(bgid) {
+		ginx.Bomb(http.StatusForbidden, "token is not allowed to access this busi group")
+	}
+}
+
 // bgrw 逐步要被干掉，不安全
 func (rt *Router) Bgrw() gin.HandlerFunc {
 	return rt.bgrw()
@@ -218,6 +342,7 @@ func (rt *Router) bgrw() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		me := c.MustGet("user").(*models.User)
 		bg := BusiGroup(rt.Ctx, ginx.UrlParamInt64(c, "id"))
+		rt.tokenBusiGroupCheck(c, bg.Id)
 
 		can, err := me.CanDoBusiGroup(rt.Ctx, bg, "rw")
 		ginx.Dangerous(err)
@@ -235,6 +360,7 @@ func (rt *Router) bgrw() gin.HandlerFunc {
 func (rt *Router) bgrwCheck(c *gin.Context, bgid int64) {
 	me := c.MustGet("user").(*models.User)
 	bg := BusiGroup(rt.Ctx, bgid)
+	rt.tokenBusiGroupCheck(c, bg.Id)
 
 	can, err := me.CanDoBusiGroup(rt.Ctx, bg, "rw")
 	ginx.Dangerous(err)
@@ -262,6 +388,7 @@ func (rt *Router) bgrwChecks(c *gin.Context, bgids []int64) {
 func (rt *Router) bgroCheck(c *gin.Context, bgid int64) {
 	me := c.MustGet("user").(*models.User)
 	bg := BusiGroup(rt.Ctx, bgid)
+	rt.tokenBusiGroupCheck(c, bg.Id)
 
 	can, err := me.CanDoBusiGroup(rt.Ctx, bg)
 	ginx.Dangerous(err)
@@ -0,0 +1,98 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/aop"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestTokenBusiGroupIds(t *testing.T) {
+	restricted := &models.UserToken{BusiGroupIds: []int64{1, 2}}
+	cases := []struct {
+		name  string
+		token *models.UserToken
+		gids  []int64
+		want  []int64
+	}{
+		{"no token", nil, []int64{3}, []int64{3}},
+		{"no token all groups", nil, nil, nil},
+		{"unrestricted token", &models.UserToken{}, []int64{3}, []int64{3}},
+		{"all groups", restricted, nil, []int64{1, 2}},
+		{"intersect", restricted, []int64{2, 3}, []int64{2}},
+		{"no group left", restricted, []int64{0, 3}, []int64{-1}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			if tc.token != nil {
+				c.Set("user_token", tc.token)
+			}
+
+			if got := tokenBusiGroupIds(c, tc.gids); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAddTokenByToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rt := &Router{}
+
+	r := gin.New()
+	r.Use(aop.Recovery())
+	r.POST("/self/token", func(c *gin.Context) {
+		c.Set("username", "root")
+		c.Set("user_token", &models.UserToken{BusiGroupIds: []int64{1}})
+	}, rt.addToken)
+
+	w := doJSON(t, r, http.MethodPost, "/self/token", map[string]interface{}{"token_name": "escalated"})
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("want 403, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAlertRuleGetByRestrictedToken(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AutoMigrate(&models.AlertRule{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ar := range []*models.AlertRule{{Id: 1, GroupId: 1, Name: "in scope"}, {Id: 2, GroupId: 2, Name: "out of scope"}} {
+		if err := db.Create(ar).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gin.SetMode(gin.TestMode)
+	rt := &Router{Ctx: ctx.NewContext(context.Background(), db, true)}
+
+	r := gin.New()
+	r.Use(aop.Recovery())
+	r.GET("/alert-rule/:arid/pure", func(c *gin.Context) {
+		c.Set("user_token", &models.UserToken{BusiGroupIds: []int64{1}})
+	}, rt.alertRulePureGet)
+
+	for arid, want := range map[int64]int{1: http.StatusOK, 2: http.StatusForbidden} {
+		w := doJSON(t, r, http.MethodGet, fmt.Sprintf("/alert-rule/%d/pure", arid), nil)
+		if w.Code != want {
+			t.Fatalf("rule %d: want %d, got %d: %s", arid, want, w.Code, w.Body.String())
+		}
+	}
+}
@@ -35,6 +35,8 @@ type Record struct {
 func (rt *Router) notificationRecordAdd(c *gin.Context) {
 	var req []*models.NotificationRecord
 	ginx.BindJSON(c, &req)
+	ginx.Dangerous(models.ResolveNotificationRecordEventIds(rt.Ctx, req))
+
 	err := sender.PushNotifyRecords(req)
 	ginx.Dangerous(err, 429)
 
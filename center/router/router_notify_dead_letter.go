@@ -0,0 +1,144 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/ccfos/nightingale/v6/alert/sender"
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/slice"
+
+	"github.com/gin-gonic/gin"
+	"github.com/toolkits/pkg/ginx"
+)
+
+const maxDeadLetterResend = 100
+
+// notifyDeadLetterAdd 边缘机房发送失败的通知由中心端保存并重投
+func (rt *Router) notifyDeadLetterAdd(c *gin.Context) {
+	var dl models.NotifyDeadLetter
+	ginx.BindJSON(c, &dl)
+
+	ginx.NewRender(c).Message(dl.Add(rt.Ctx))
+}
+
+// notifyDeadLetterRule 校验当前用户是否有权限查看通知规则
+func (rt *Router) notifyDeadLetterRule(c *gin.Context) *models.NotifyRule {
+	me := c.MustGet("user").(*models.User)
+
+	nr, err := models.NotifyRuleGet(rt.Ctx, "id = ?", ginx.UrlParamInt64(c, "id"))
+	ginx.Dangerous(err)
+	if nr == nil {
+		ginx.Bomb(http.StatusNotFound, "notify rule not found")
+	}
+
+	if me.IsAdmin() {
+		return nr
+	}
+
+	gids, err := models.MyGroupIds(rt.Ctx, me.Id)
+	ginx.Dangerous(err)
+
+	if !slice.HaveIntersection(gids, nr.UserGroupIds) {
+		ginx.Bomb(http.StatusForbidden, "forbidden")
+	}
+
+	return nr
+}
+
+func (rt *Router) notifyDeadLetterGets(c *gin.Context) {
+	nr := rt.notifyDeadLetterRule(c)
+
+	limit := ginx.QueryInt(c, "limit", 20)
+	status := ginx.QueryStr(c, "status", "")
+	query := ginx.QueryStr(c, "query", "")
+
+	total, err := models.NotifyDeadLetterTotal(rt.Ctx, nr.ID, status, query)
+	ginx.Dangerous(err)
+
+	list, err := models.NotifyDeadLetterGets(rt.Ctx, nr.ID, status, query, limit, ginx.Offset(c, limit))
+	ginx.Dangerous(err)
+
+	for _, dl := range list {
+		// header、query 参数和自定义参数中可能有鉴权信息，只展示请求地址和内容
+		if dl.Request != nil {
+			dl.Request.Headers = nil
+			dl.Request.Parameters = nil
+		}
+		if dl.Message != nil {
+			dl.Message.CustomParams = nil
+			dl.Message.RoutingKey = ""
+		}
+	}
+
+	ginx.NewRender(c).Data(gin.H{
+		"list":  list,
+		"total": total,
+	}, nil)
+}
+
+// notifyDeadLetterRuleIds 只保留属于当前通知规则的记录
+func (rt *Router) notifyDeadLetterRuleIds(nr *models.NotifyRule, ids []int64) []*models.NotifyDeadLetter {
+	if len(ids) == 0 {
+		ginx.Bomb(http.StatusBadRequest, "ids empty")
+	}
+
+	if len(ids) > maxDeadLetterResend {
+		ginx.Bomb(http.StatusBadRequest, "too many ids, max: %d", maxDeadLetterResend)
+	}
+
+	lst, err := models.NotifyDeadLetterGetsByIds(rt.Ctx, ids)
+	ginx.Dangerous(err)
+
+	ret := make([]*models.NotifyDeadLetter, 0, len(lst))
+	for _, dl := range lst {
+		if dl.NotifyRuleId == nr.ID {
+			ret = append(ret, dl)
+		}
+	}
+
+	return ret
+}
+
+// notifyDeadLetterResend 立即重发选中的记录，返回每条记录的发送结果
+func (rt *Router) notifyDeadLetterResend(c *gin.Context) {
+	var f idsForm
+	ginx.BindJSON(c, &f)
+
+	nr := rt.notifyDeadLetterRule(c)
+	lst := rt.notifyDeadLetterRuleIds(nr, f.Ids)
+
+	redeliver := rt.Center.NotifyRedeliver
+	r := sender.NewRedeliverer(rt.Ctx, redeliver.MaxAttempts, redeliver.BaseInterval, redeliver.MaxInterval)
+
+	clients := make(map[int64]*http.Client)
+	result := make(map[int64]string, len(lst))
+	for _, dl := range lst {
+		if dl.Status == models.DeadLetterStatusSucceeded {
+			result[dl.Id] = "already succeeded"
+			continue
+		}
+
+		if err := r.Resend(dl, clients); err != nil {
+			result[dl.Id] = err.Error()
+		} else {
+			result[dl.Id] = ""
+		}
+	}
+
+	ginx.NewRender(c).Data(result, nil)
+}
+
+func (rt *Router) notifyDeadLetterDel(c *gin.Context) {
+	var f idsForm
+	ginx.BindJSON(c, &f)
+
+	nr := rt.notifyDeadLetterRule(c)
+	lst := rt.notifyDeadLetterRuleIds(nr, f.Ids)
+
+	ids := make([]int64, 0, len(lst))
+	for _, dl := range lst {
+		ids = append(ids, dl.Id)
+	}
+
+	ginx.NewRender(c).Message(models.NotifyDeadLetterDel(rt.Ctx, ids))
+}
@@ -38,11 +38,19 @@ func (rt *Router) recordingRuleGetsByGids(c *gin.Context) {
 		}
 	}
 
+	gids = tokenBusiGroupIds(c, gids)
+
 	ars, err := models.RecordingRuleGetsByBGIds(rt.Ctx, gids)
 	ginx.NewRender(c).Data(ars, err)
 }
 
 func (rt *Router) recordingRuleGetsByService(c *gin.Context) {
+	ids := strx.IdsInt64ForAPI(ginx.QueryStr(c, "ids", ""))
+	if len(ids) > 0 {
+		ginx.NewRender(c).Data(models.RecordingRuleEnabledGetsByIds(rt.Ctx, ids))
+		return
+	}
+
 	ars, err := models.RecordingRuleEnabledGets(rt.Ctx)
 	ginx.NewRender(c).Data(ars, err)
 }
@@ -58,6 +66,8 @@ func (rt *Router) recordingRuleGet(c *gin.Context) {
 		return
 	}
 
+	rt.tokenBusiGroupCheck(c, ar.GroupId)
+
 	ginx.NewRender(c).Data(ar, err)
 }
 
@@ -168,3 +178,52 @@ func (rt *Router) recordingRulePutFields(c *gin.Context) {
 
 	ginx.NewRender(c).Message(nil)
 }
+
+func (rt *Router) recordingRuleCheck(c *gin.Context, rrid int64) *models.RecordingRule {
+	ar, err := models.RecordingRuleGetById(rt.Ctx, rrid)
+	ginx.Dangerous(err)
+
+	if ar == nil {
+		ginx.Bomb(http.StatusNotFound, "No such recording rule")
+	}
+
+	return ar
+}
+
+func (rt *Router) recordingRuleBackfillGets(c *gin.Context) {
+	ar := rt.recordingRuleCheck(c, ginx.UrlParamInt64(c, "rrid"))
+	rt.bgroCheck(c, ar.GroupId)
+
+	lst, err := models.RecordingRuleBackfillGets(rt.Ctx, ar.Id)
+	ginx.NewRender(c).Data(lst, err)
+}
+
+// recordingRuleBackfillAdd 创建回填任务，由 center 后台认领执行，通过列表接口查看进度
+func (rt *Router) recordingRuleBackfillAdd(c *gin.Context) {
+	var f models.RecordingRuleBackfill
+	ginx.BindJSON(c, &f)
+
+	ar := rt.recordingRuleCheck(c, ginx.UrlParamInt64(c, "rrid"))
+	rt.bgrwCheck(c, ar.GroupId)
+
+	f.RuleId = ar.Id
+	f.CreateBy = c.MustGet("username").(string)
+	ginx.Dangerous(f.Verify(ar))
+	ginx.Dangerous(f.Add(rt.Ctx))
+
+	ginx.NewRender(c).Data(f.Id, nil)
+}
+
+func (rt *Router) recordingRuleBackfillCancel(c *gin.Context) {
+	ar := rt.recordingRuleCheck(c, ginx.UrlParamInt64(c, "rrid"))
+	rt.bgrwCheck(c, ar.GroupId)
+
+	job, err := models.RecordingRuleBackfillGetById(rt.Ctx, ginx.UrlParamInt64(c, "bid"))
+	ginx.Dangerous(err)
+
+	if job == nil || job.RuleId != ar.Id {
+		ginx.Bomb(http.StatusNotFound, "No such backfill")
+	}
+
+	ginx.NewRender(c).Message(job.Cancel(rt.Ctx))
+}
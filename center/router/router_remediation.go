@@ -0,0 +1,260 @@
+package router
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/center/audit"
+	"github.com/ccfos/nightingale/v6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/toolkits/pkg/ginx"
+)
+
+func (rt *Router) remediationPolicyGets(c *gin.Context) {
+	lst, err := models.RemediationPolicyGets(rt.Ctx, ginx.UrlParamInt64(c, "id"))
+	ginx.NewRender(c).Data(lst, err)
+}
+
+func (rt *Router) remediationPolicyGet(c *gin.Context) {
+	policy := rt.remediationPolicyCheck(c, ginx.UrlParamInt64(c, "rpid"))
+	rt.bgroCheck(c, policy.GroupId)
+	ginx.NewRender(c).Data(policy, nil)
+}
+
+func (rt *Router) remediationPolicyCheck(c *gin.Context, id int64) *models.RemediationPolicy {
+	policy, err := models.RemediationPolicyGetById(rt.Ctx, id)
+	ginx.Dangerous(err)
+
+	if policy == nil {
+		ginx.Bomb(http.StatusNotFound, "No such remediation policy")
+	}
+
+	return policy
+}
+
+func (rt *Router) remediationPolicyAdd(c *gin.Context) {
+	var f models.RemediationPolicy
+	ginx.BindJSON(c, &f)
+
+	username := c.MustGet("username").(string)
+	f.GroupId = ginx.UrlParamInt64(c, "id")
+	f.CreateBy = username
+	f.UpdateBy = username
+
+	ginx.Dangerous(f.Add(rt.Ctx))
+	ginx.NewRender(c).Data(f.Id, nil)
+}
+
+func (rt *Router) remediationPolicyPut(c *gin.Context) {
+	var f models.RemediationPolicy
+	ginx.BindJSON(c, &f)
+
+	policy := rt.remediationPolicyCheck(c, ginx.UrlParamInt64(c, "rpid"))
+	if policy.GroupId != ginx.UrlParamInt64(c, "id") {
+		ginx.Bomb(http.StatusForbidden, "forbidden")
+	}
+
+	f.UpdateBy = c.MustGet("username").(string)
+	ginx.NewRender(c).Message(policy.Update(rt.Ctx, f))
+}
+
+func (rt *Router) remediationPolicyDel(c *gin.Context) {
+	var f idsForm
+	ginx.BindJSON(c, &f)
+	f.Verify()
+
+	ginx.NewRender(c).Message(models.RemediationPolicyDels(rt.Ctx, f.Ids, ginx.UrlParamInt64(c, "id")))
+}
+
+func (rt *Router) remediationRunGets(c *gin.Context) {
+	policyId := ginx.QueryInt64(c, "policy_id", 0)
+	status := ginx.QueryStr(c, "status", "")
+	limit := ginx.QueryInt(c, "limit", 20)
+	offset := ginx.QueryInt(c, "p", 1)
+
+	if limit <= 0 || limit > 1000 {
+		limit = 20
+	}
+	if offset <= 0 {
+		offset = 1
+	}
+
+	lst, total, err := models.RemediationRunGets(rt.Ctx, ginx.UrlParamInt64(c, "id"), policyId, status, limit, (offset-1)*limit)
+	ginx.Dangerous(err)
+
+	ginx.NewRender(c).Data(gin.H{
+		"list":  lst,
+		"total": total,
+	}, nil)
+}
+
+type remediationApprovalForm struct {
+	Action string `json:"action"` // approve or reject
+}
+
+func (rt *Router) remediationRunApprove(c *gin.Context) {
+	var f remediationApprovalForm
+	ginx.BindJSON(c, &f)
+
+	run, err := models.RemediationRunGetById(rt.Ctx, ginx.UrlParamInt64(c, "rrid"))
+	ginx.Dangerous(err)
+
+	if run == nil || run.GroupId != ginx.UrlParamInt64(c, "id") {
+		ginx.Bomb(http.StatusNotFound, "No such remediation run")
+	}
+
+	approve := rt.remediationApprovalAction(f.Action)
+	ginx.NewRender(c).Message(run.Approve(rt.Ctx, approve, c.MustGet("username").(string)))
+}
+
+var remediationApprovalPage = template.Must(template.New("remediation_approval").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Remediation approval</title></head>
+<body>
+{{- if .Run }}
+<p>Remediation policy <b>{{ .Run.PolicyName }}</b> wants to run task tpl {{ .Run.TaskTplId }} on <b>{{ .Run.Host }}</b> for alert {{ .Run.RuleName }}.</p>
+<p>Expire at: {{ .ExpireAt }}</p>
+<form method="post">
+<input type="hidden" name="token" value="{{ .Token }}">
+<input type="hidden" name="action" value="{{ .Action }}">
+<label>Your name: <input type="text" name="approver" required maxlength="32"></label>
+<button type="submit">{{ .Action }}</button>
+</form>
+{{- else }}
+<p>{{ .Message }}</p>
+{{- end }}
+</body>
+</html>
+`))
+
+type remediationApprovalPageData struct {
+	Run      *models.RemediationRun
+	Token    string
+	Action   string
+	ExpireAt string
+	Message  string
+}
+
+func renderRemediationApprovalPage(c *gin.Context, status int, data remediationApprovalPageData) {
+	var buf bytes.Buffer
+	ginx.Dangerous(remediationApprovalPage.Execute(&buf, data))
+	c.Data(status, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// remediationApprovalConfirm 审批链接发送到 IM 或 HTTP 回调中，不需要登录，由链接中的 token 校验。
+// IM 等客户端会预先访问链接生成预览，GET 只返回确认页面，在页面上填写审批人提交后才审批
+func (rt *Router) remediationApprovalConfirm(c *gin.Context) {
+	token := ginx.QueryStr(c, "token")
+	action := ginx.QueryStr(c, "action")
+	rt.remediationApprovalAction(action)
+
+	run, err := models.RemediationRunGetByToken(rt.Ctx, token)
+	ginx.Dangerous(err)
+
+	if run == nil {
+		renderRemediationApprovalPage(c, http.StatusNotFound, remediationApprovalPageData{Message: "No such remediation run"})
+		return
+	}
+
+	if run.Status != models.RemediationStatusPendingApproval {
+		renderRemediationApprovalPage(c, http.StatusOK, remediationApprovalPageData{Message: "remediation run is " + run.Status + ", not pending approval"})
+		return
+	}
+
+	renderRemediationApprovalPage(c, http.StatusOK, remediationApprovalPageData{
+		Run:      run,
+		Token:    token,
+		Action:   action,
+		ExpireAt: time.Unix(run.ExpireAt, 0).Format("2006-01-02 15:04:05"),
+	})
+}
+
+// remediationApproveByToken 确认页面提交的审批，审批人记录为页面上填写的名字和来源 IP，审计日志中标注为未验证
+func (rt *Router) remediationApproveByToken(c *gin.Context) {
+	approve := rt.remediationApprovalAction(c.PostForm("action"))
+
+	approver := strings.TrimSpace(c.PostForm("approver"))
+	if approver == "" {
+		renderRemediationApprovalPage(c, http.StatusBadRequest, remediationApprovalPageData{Message: "approver is required"})
+		return
+	}
+
+	run, err := models.RemediationRunGetByToken(rt.Ctx, c.PostForm("token"))
+	ginx.Dangerous(err)
+
+	if run == nil {
+		renderRemediationApprovalPage(c, http.StatusNotFound, remediationApprovalPageData{Message: "No such remediation run"})
+		return
+	}
+
+	approveBy := approver + "@" + c.ClientIP()
+	if len(approveBy) > 64 {
+		approveBy = approveBy[:64]
+	}
+
+	before := rt.auditSnapshot(models.AuditResourceRemediationRun, []int64{run.Id})
+	err = run.Approve(rt.Ctx, approve, approveBy)
+	rt.auditApproveByToken(c, run.Id, approveBy, before, err)
+	if err != nil {
+		renderRemediationApprovalPage(c, http.StatusBadRequest, remediationApprovalPageData{Message: err.Error()})
+		return
+	}
+
+	msg := "rejected"
+	if approve {
+		msg = "approved, task will run on " + run.Host + " shortly"
+	}
+	renderRemediationApprovalPage(c, http.StatusOK, remediationApprovalPageData{Message: msg})
+}
+
+// auditApproveByToken 审批链接不需要登录，审批人是页面上填写的名字，没有经过验证，审计日志的用户名中明确标注
+func (rt *Router) auditApproveByToken(c *gin.Context, runId int64, approveBy, before string, err error) {
+	if !rt.Auditor.Enabled() {
+		return
+	}
+
+	const unverified = " (unverified)"
+	if len(approveBy)+len(unverified) > 64 {
+		approveBy = approveBy[:64-len(unverified)]
+	}
+
+	log := &models.AuditLog{
+		Username:     approveBy + unverified,
+		SourceIp:     c.ClientIP(),
+		ResourceType: models.AuditResourceRemediationRun,
+		ResourceIds:  strconv.FormatInt(runId, 10),
+		Action:       models.AuditActionUpdate,
+		Method:       c.Request.Method,
+		Path:         c.FullPath(),
+		Before:       before,
+		Status:       models.AuditStatusSuccess,
+		Message:      "approved by approval link, approver name is not verified",
+	}
+
+	if err != nil {
+		log.Status = models.AuditStatusFailed
+		log.Message = err.Error()
+	} else {
+		log.After = rt.auditSnapshot(models.AuditResourceRemediationRun, []int64{runId})
+		log.Diff = audit.Diff(log.Before, log.After)
+	}
+
+	rt.Auditor.Record(log)
+}
+
+func (rt *Router) remediationApprovalAction(action string) bool {
+	switch action {
+	case "approve":
+		return true
+	case "reject":
+		return false
+	default:
+		ginx.Bomb(http.StatusBadRequest, "action should be approve or reject")
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/center/audit"
+	"github.com/ccfos/nightingale/v6/center/cconf"
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/aop"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func newRemediationRouter(t *testing.T) (*Router, *gin.Engine) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AutoMigrate(&models.RemediationRun{}, &models.AuditLog{}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := ctx.NewContext(context.Background(), db, true)
+	rt := &Router{Ctx: c, Auditor: audit.New(c, cconf.Audit{Enable: true})}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(aop.Recovery())
+	login := func(c *gin.Context) {
+		c.Set("username", "root")
+	}
+	r.PUT("/busi-group/:id/remediation-run/:rrid/approval", login, rt.audit(models.AuditResourceRemediationRun, "rrid"), rt.remediationRunApprove)
+	r.GET("/remediation-approval", rt.remediationApprovalConfirm)
+	r.POST("/remediation-approval", rt.remediationApproveByToken)
+
+	return rt, r
+}
+
+func addPendingRun(t *testing.T, rt *Router, token string) *models.RemediationRun {
+	run := &models.RemediationRun{PolicyId: 1, PolicyName: "restart", GroupId: 1, Host: "host1", TaskTplId: 5,
+		Status: models.RemediationStatusPendingApproval, Token: token, ExpireAt: time.Now().Unix() + 3600}
+	if err := run.Add(rt.Ctx); err != nil {
+		t.Fatal(err)
+	}
+	return run
+}
+
+func postForm(r *gin.Engine, path string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRemediationApproveByToken(t *testing.T) {
+	rt, r := newRemediationRouter(t)
+	run := addPendingRun(t, rt, "tk")
+
+	// 打开链接只返回确认页面，不审批
+	req := httptest.NewRequest(http.MethodGet, "/remediation-approval?token=tk&action=approve", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `<form method="post">`) {
+		t.Fatalf("unexpected confirm page: %d %s", w.Code, w.Body.String())
+	}
+
+	if got, _ := models.RemediationRunGetById(rt.Ctx, run.Id); got.Status != models.RemediationStatusPendingApproval {
+		t.Fatalf("GET should not approve, status: %s", got.Status)
+	}
+
+	// 必须填写审批人
+	if w := postForm(r, "/remediation-approval", url.Values{"token": {"tk"}, "action": {"approve"}}); w.Code != http.StatusBadRequest {
+		t.Fatalf("want 400, got %d", w.Code)
+	}
+
+	w = postForm(r, "/remediation-approval", url.Values{"token": {"tk"}, "action": {"approve"}, "approver": {"alice"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("approve failed: %d %s", w.Code, w.Body.String())
+	}
+
+	got, _ := models.RemediationRunGetById(rt.Ctx, run.Id)
+	if got.Status != models.RemediationStatusApproved || !strings.HasPrefix(got.ApproveBy, "alice@") {
+		t.Fatalf("unexpected run: %+v", got)
+	}
+
+	// 页面上填写的审批人没有经过验证，审计日志中明确标注
+	var logs []*models.AuditLog
+	if err := models.DB(rt.Ctx).Find(&logs).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logs) != 1 || logs[0].ResourceIds != "1" || logs[0].Status != models.AuditStatusSuccess ||
+		!strings.HasPrefix(logs[0].Username, "alice@") || !strings.HasSuffix(logs[0].Username, "(unverified)") {
+		t.Fatalf("unexpected audit logs: %+v", logs)
+	}
+}
+
+func TestRemediationRunApproveAudit(t *testing.T) {
+	rt, r := newRemediationRouter(t)
+	run := addPendingRun(t, rt, "tk")
+
+	if w := doJSON(t, r, http.MethodPut, "/busi-group/1/remediation-run/1/approval", map[string]string{"action": "reject"}); w.Code != http.StatusOK {
+		t.Fatalf("reject failed: %d %s", w.Code, w.Body.String())
+	}
+
+	var logs []*models.AuditLog
+	if err := models.DB(rt.Ctx).Find(&logs).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logs) != 1 || logs[0].ResourceType != models.AuditResourceRemediationRun || logs[0].ResourceIds != "1" ||
+		logs[0].Username != "root" || logs[0].Status != models.AuditStatusSuccess {
+		t.Fatalf("unexpected audit logs: %+v", logs)
+	}
+
+	if got, _ := models.RemediationRunGetById(rt.Ctx, run.Id); got.Status != models.RemediationStatusRejected || got.ApproveBy != "root" {
+		t.Fatalf("unexpected run: %+v", got)
+	}
+}
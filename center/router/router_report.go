@@ -0,0 +1,117 @@
+package router
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/toolkits/pkg/ginx"
+)
+
+func (rt *Router) reportGets(c *gin.Context) {
+	lst, err := models.ReportGets(rt.Ctx, ginx.QueryStr(c, "query", ""))
+	ginx.NewRender(c).Data(lst, err)
+}
+
+func (rt *Router) reportGet(c *gin.Context) {
+	ginx.NewRender(c).Data(rt.reportCheck(c, ginx.UrlParamInt64(c, "id")), nil)
+}
+
+func (rt *Router) reportCheck(c *gin.Context, id int64) *models.Report {
+	rpt, err := models.ReportGetById(rt.Ctx, id)
+	ginx.Dangerous(err)
+
+	if rpt == nil {
+		ginx.Bomb(http.StatusNotFound, "No such report")
+	}
+
+	return rpt
+}
+
+// reportBusiGroupCheck 报告只能汇总当前用户可以访问的业务组，为空表示所有业务组，只有不受 token 限制的管理员可以使用
+func (rt *Router) reportBusiGroupCheck(c *gin.Context, bgids []int64) {
+	allowed, err := GetBusinessGroupIds(c, rt.Ctx, true, false)
+	ginx.Dangerous(err)
+
+	// 管理员且 token 未限制业务组时 allowed 为空，不做限制
+	if len(allowed) == 0 {
+		return
+	}
+
+	if len(bgids) == 0 {
+		ginx.Bomb(http.StatusForbidden, "busi_group_ids is required, only admin can report on all busi groups")
+	}
+
+	for _, bgid := range bgids {
+		if !slices.Contains(allowed, bgid) {
+			ginx.Bomb(http.StatusForbidden, "no permission to access busi group: %d", bgid)
+		}
+	}
+}
+
+func (rt *Router) reportAdd(c *gin.Context) {
+	var f models.Report
+	ginx.BindJSON(c, &f)
+
+	username := c.MustGet("username").(string)
+	f.Id = 0
+	f.LastRunAt = 0
+	f.LastStatus = ""
+	f.LastError = ""
+	f.CreateBy = username
+	f.UpdateBy = username
+
+	rt.reportBusiGroupCheck(c, f.BusiGroupIds)
+	ginx.Dangerous(f.Add(rt.Ctx))
+	ginx.NewRender(c).Data(f.Id, nil)
+}
+
+func (rt *Router) reportPut(c *gin.Context) {
+	var f models.Report
+	ginx.BindJSON(c, &f)
+
+	rpt := rt.reportCheck(c, ginx.UrlParamInt64(c, "id"))
+	rt.reportBusiGroupCheck(c, rpt.BusiGroupIds)
+	rt.reportBusiGroupCheck(c, f.BusiGroupIds)
+
+	f.UpdateBy = c.MustGet("username").(string)
+	ginx.NewRender(c).Message(rpt.Update(rt.Ctx, f))
+}
+
+func (rt *Router) reportDel(c *gin.Context) {
+	var f idsForm
+	ginx.BindJSON(c, &f)
+	f.Verify()
+
+	for _, id := range f.Ids {
+		rpt := rt.reportCheck(c, id)
+		rt.reportBusiGroupCheck(c, rpt.BusiGroupIds)
+	}
+
+	ginx.NewRender(c).Message(models.ReportDels(rt.Ctx, f.Ids))
+}
+
+// reportPreview 按当前时间生成报告，返回渲染后的内容，不发送
+func (rt *Router) reportPreview(c *gin.Context) {
+	rpt := rt.reportCheck(c, ginx.UrlParamInt64(c, "id"))
+	rt.reportBusiGroupCheck(c, rpt.BusiGroupIds)
+
+	_, content, err := rt.Reporter.Render(rpt, time.Now())
+	ginx.Dangerous(err)
+
+	ginx.NewRender(c).Data(content, nil)
+}
+
+// reportRun 立即发送一次报告，并记录发送结果
+func (rt *Router) reportRun(c *gin.Context) {
+	rpt := rt.reportCheck(c, ginx.UrlParamInt64(c, "id"))
+	rt.reportBusiGroupCheck(c, rpt.BusiGroupIds)
+
+	now := time.Now()
+	err := rt.Reporter.Send(rpt, now)
+	ginx.Dangerous(rpt.UpdateResult(rt.Ctx, now.Unix(), err))
+	ginx.NewRender(c).Message(err)
+}
@@ -0,0 +1,84 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/aop"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestReportBusiGroupCheck(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AutoMigrate(&models.Report{}, &models.UserGroupMember{}, &models.BusiGroupMember{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 用户 1 通过团队 10 属于业务组 1
+	if err := db.Create(&models.UserGroupMember{GroupId: 10, UserId: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.BusiGroupMember{BusiGroupId: 1, UserGroupId: 10, PermFlag: "rw"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	rt := &Router{Ctx: ctx.NewContext(context.Background(), db, true)}
+
+	cases := []struct {
+		name  string
+		user  *models.User
+		token *models.UserToken
+		bgids []int64
+		want  int
+	}{
+		{"member", &models.User{Id: 1}, nil, []int64{1}, http.StatusOK},
+		{"not member", &models.User{Id: 1}, nil, []int64{1, 2}, http.StatusForbidden},
+		{"all groups", &models.User{Id: 1}, nil, nil, http.StatusForbidden},
+		{"admin all groups", &models.User{Id: 2, RolesLst: []string{models.AdminRole}}, nil, nil, http.StatusOK},
+		{"admin restricted token", &models.User{Id: 2, RolesLst: []string{models.AdminRole}}, &models.UserToken{BusiGroupIds: []int64{1}}, nil, http.StatusForbidden},
+		{"admin token out of scope", &models.User{Id: 2, RolesLst: []string{models.AdminRole}}, &models.UserToken{BusiGroupIds: []int64{1}}, []int64{2}, http.StatusForbidden},
+	}
+
+	for i, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			id := int64(i + 1)
+			if err := db.Create(&models.Report{Id: id, Name: tc.name, BusiGroupIds: tc.bgids}).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			r := gin.New()
+			r.Use(aop.Recovery())
+			r.DELETE("/reports", func(c *gin.Context) {
+				c.Set("user", tc.user)
+				if tc.token != nil {
+					c.Set("user_token", tc.token)
+				}
+			}, rt.reportDel)
+
+			w := doJSON(t, r, http.MethodDelete, "/reports", map[string]interface{}{"ids": []int64{id}})
+			if w.Code != tc.want {
+				t.Fatalf("want %d, got %d: %s", tc.want, w.Code, w.Body.String())
+			}
+
+			rpt, err := models.ReportGetById(rt.Ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if deleted := rpt == nil; deleted != (tc.want == http.StatusOK) {
+				t.Fatalf("report %s deleted: %v", fmt.Sprint(tc.bgids), deleted)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/ccfos/nightingale/v6/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/toolkits/pkg/ginx"
+)
+
+func (rt *Router) ruleGroupGets(c *gin.Context) {
+	lst, err := models.RuleGroupGets(rt.Ctx, ginx.UrlParamInt64(c, "id"))
+	ginx.NewRender(c).Data(lst, err)
+}
+
+func (rt *Router) ruleGroupGetsByService(c *gin.Context) {
+	lst, err := models.RuleGroupGetsByCluster(rt.Ctx)
+	ginx.NewRender(c).Data(lst, err)
+}
+
+func (rt *Router) ruleGroupGet(c *gin.Context) {
+	rg := rt.ruleGroupCheck(c, ginx.UrlParamInt64(c, "rgid"))
+	rt.bgroCheck(c, rg.GroupId)
+	ginx.NewRender(c).Data(rg, nil)
+}
+
+func (rt *Router) ruleGroupCheck(c *gin.Context, id int64) *models.RuleGroup {
+	rg, err := models.RuleGroupGetById(rt.Ctx, id)
+	ginx.Dangerous(err)
+
+	if rg == nil {
+		ginx.Bomb(http.StatusNotFound, "No such rule group")
+	}
+
+	return rg
+}
+
+func (rt *Router) ruleGroupAdd(c *gin.Context) {
+	var f models.RuleGroup
+	ginx.BindJSON(c, &f)
+
+	username := c.MustGet("username").(string)
+	f.GroupId = ginx.UrlParamInt64(c, "id")
+	f.CreateBy = username
+	f.UpdateBy = username
+
+	ginx.Dangerous(f.Add(rt.Ctx))
+	ginx.NewRender(c).Data(f.Id, nil)
+}
+
+func (rt *Router) ruleGroupPut(c *gin.Context) {
+	var f models.RuleGroup
+	ginx.BindJSON(c, &f)
+
+	rg := rt.ruleGroupCheck(c, ginx.UrlParamInt64(c, "rgid"))
+	if rg.GroupId != ginx.UrlParamInt64(c, "id") {
+		ginx.Bomb(http.StatusForbidden, "forbidden")
+	}
+
+	f.UpdateBy = c.MustGet("username").(string)
+	ginx.NewRender(c).Message(rg.Update(rt.Ctx, f))
+}
+
+func (rt *Router) ruleGroupDel(c *gin.Context) {
+	var f idsForm
+	ginx.BindJSON(c, &f)
+	f.Verify()
+
+	ginx.NewRender(c).Message(models.RuleGroupDels(rt.Ctx, f.Ids, ginx.UrlParamInt64(c, "id")))
+}
@@ -1,6 +1,8 @@
 package router
 
 import (
+	"net/http"
+
 	"github.com/ccfos/nightingale/v6/models"
 	"github.com/ccfos/nightingale/v6/pkg/flashduty"
 	"github.com/ccfos/nightingale/v6/pkg/ormx"
@@ -85,8 +87,11 @@ func (rt *Router) selfPasswordPut(c *gin.Context) {
 }
 
 type tokenForm struct {
-	TokenName string `json:"token_name"`
-	Token     string `json:"token"`
+	TokenName    string   `json:"token_name"`
+	Scope        string   `json:"scope"`
+	BusiGroupIds []int64  `json:"busi_group_ids"`
+	ApiPrefixes  []string `json:"api_prefixes"`
+	ExpireAt     int64    `json:"expire_at"`
 }
 
 func (rt *Router) getToken(c *gin.Context) {
@@ -95,7 +100,13 @@ func (rt *Router) getToken(c *gin.Context) {
 	ginx.NewRender(c).Data(tokens, err)
 }
 
+// addToken 创建 token，明文只在本次响应中返回，之后无法再查看
+// token 不能用来创建或删除 token，否则受限的 token 可以给自己签发不受限的 token
 func (rt *Router) addToken(c *gin.Context) {
+	if requestUserToken(c) != nil {
+		ginx.Bomb(http.StatusForbidden, "cannot manage tokens with a token")
+	}
+
 	var f tokenForm
 	ginx.BindJSON(c, &f)
 
@@ -111,11 +122,21 @@ func (rt *Router) addToken(c *gin.Context) {
 		}
 	}
 
-	token, err := models.AddToken(rt.Ctx, username, uuid.New().String(), f.TokenName)
+	token, err := models.AddToken(rt.Ctx, username, uuid.New().String(), models.UserToken{
+		TokenName:    f.TokenName,
+		Scope:        f.Scope,
+		BusiGroupIds: f.BusiGroupIds,
+		ApiPrefixes:  f.ApiPrefixes,
+		ExpireAt:     f.ExpireAt,
+	})
 	ginx.NewRender(c).Data(token, err)
 }
 
 func (rt *Router) deleteToken(c *gin.Context) {
+	if requestUserToken(c) != nil {
+		ginx.Bomb(http.StatusForbidden, "cannot manage tokens with a token")
+	}
+
 	id := ginx.UrlParamInt64(c, "id")
 	username := c.MustGet("username").(string)
 	tokenCount, err := models.CountToken(rt.Ctx, username)
@@ -126,5 +147,14 @@ func (rt *Router) deleteToken(c *gin.Context) {
 		return
 	}
 
-	ginx.NewRender(c).Message(models.DeleteToken(rt.Ctx, id))
+	token, err := models.UserTokenGet(rt.Ctx, username, id)
+	ginx.Dangerous(err)
+	if token == nil {
+		ginx.Bomb(http.StatusNotFound, "No such token")
+	}
+
+	ginx.Dangerous(models.DeleteToken(rt.Ctx, username, id))
+	rt.revokeUserToken(token.TokenHash)
+
+	ginx.NewRender(c).Message(nil)
 }
@@ -0,0 +1,202 @@
+package router
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/models"
+	pkgprom "github.com/ccfos/nightingale/v6/pkg/prom"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/common/model"
+	"github.com/toolkits/pkg/ginx"
+)
+
+const maxSloBudgetPoints = 1440
+
+func (rt *Router) sloGets(c *gin.Context) {
+	lst, err := models.SloGets(rt.Ctx, ginx.UrlParamInt64(c, "id"), ginx.QueryStr(c, "query", ""))
+	ginx.NewRender(c).Data(lst, err)
+}
+
+func (rt *Router) sloGet(c *gin.Context) {
+	slo := rt.sloCheck(c, ginx.UrlParamInt64(c, "sid"))
+	rt.bgroCheck(c, slo.GroupId)
+	ginx.NewRender(c).Data(slo, nil)
+}
+
+func (rt *Router) sloCheck(c *gin.Context, id int64) *models.Slo {
+	slo, err := models.SloGetById(rt.Ctx, id)
+	ginx.Dangerous(err)
+
+	if slo == nil {
+		ginx.Bomb(http.StatusNotFound, "No such SLO")
+	}
+
+	return slo
+}
+
+func (rt *Router) sloDatasourceCheck(datasourceId int64) {
+	if rt.PromClients.GetCli(datasourceId) == nil {
+		ginx.Bomb(http.StatusBadRequest, "datasource %d is not a prometheus-like datasource", datasourceId)
+	}
+}
+
+func (rt *Router) sloAdd(c *gin.Context) {
+	var f models.Slo
+	ginx.BindJSON(c, &f)
+
+	rt.sloDatasourceCheck(f.DatasourceId)
+
+	username := c.MustGet("username").(string)
+	f.Id = 0
+	f.AlertRuleId = 0
+	f.GroupId = ginx.UrlParamInt64(c, "id")
+	f.CreateBy = username
+	f.UpdateBy = username
+
+	ginx.Dangerous(f.Add(rt.Ctx))
+	ginx.NewRender(c).Data(f, nil)
+}
+
+func (rt *Router) sloPut(c *gin.Context) {
+	var f models.Slo
+	ginx.BindJSON(c, &f)
+
+	slo := rt.sloCheck(c, ginx.UrlParamInt64(c, "sid"))
+	if slo.GroupId != ginx.UrlParamInt64(c, "id") {
+		ginx.Bomb(http.StatusForbidden, "forbidden")
+	}
+
+	rt.sloDatasourceCheck(f.DatasourceId)
+
+	f.UpdateBy = c.MustGet("username").(string)
+	ginx.NewRender(c).Message(slo.Update(rt.Ctx, f))
+}
+
+func (rt *Router) sloDel(c *gin.Context) {
+	var f idsForm
+	ginx.BindJSON(c, &f)
+	f.Verify()
+
+	bgid := ginx.UrlParamInt64(c, "id")
+	for _, id := range f.Ids {
+		slo, err := models.SloGetById(rt.Ctx, id)
+		ginx.Dangerous(err)
+
+		if slo == nil || slo.GroupId != bgid {
+			continue
+		}
+
+		ginx.Dangerous(slo.Del(rt.Ctx))
+	}
+
+	ginx.NewRender(c).Message(nil)
+}
+
+// sloBudget 当前窗口内的错误预算以及各告警窗口的燃烧率
+func (rt *Router) sloBudget(c *gin.Context) {
+	slo := rt.sloCheck(c, ginx.UrlParamInt64(c, "sid"))
+	rt.bgroCheck(c, slo.GroupId)
+
+	cli := rt.PromClients.GetCli(slo.DatasourceId)
+	if cli == nil {
+		ginx.Bomb(http.StatusBadRequest, "no such datasource id: %d", slo.DatasourceId)
+	}
+
+	now := time.Now()
+	ratio, err := sloQueryErrorRatio(cli, slo, slo.Window(), now)
+	ginx.Dangerous(err)
+
+	budget := slo.NewBudget(ratio)
+	for _, tier := range slo.BurnRateTiers() {
+		for _, w := range []time.Duration{tier.ShortWindow, tier.LongWindow} {
+			key := models.SloWindowString(w)
+			if _, exists := budget.BurnRates[key]; exists {
+				continue
+			}
+
+			r, err := sloQueryErrorRatio(cli, slo, w, now)
+			ginx.Dangerous(err)
+			budget.BurnRates[key] = slo.BudgetConsumed(r)
+		}
+	}
+
+	ginx.NewRender(c).Data(budget, nil)
+}
+
+// sloBudgetHistory 每个时间点往前一个 SLO 窗口内的预算消耗情况
+func (rt *Router) sloBudgetHistory(c *gin.Context) {
+	slo := rt.sloCheck(c, ginx.UrlParamInt64(c, "sid"))
+	rt.bgroCheck(c, slo.GroupId)
+
+	cli := rt.PromClients.GetCli(slo.DatasourceId)
+	if cli == nil {
+		ginx.Bomb(http.StatusBadRequest, "no such datasource id: %d", slo.DatasourceId)
+	}
+
+	end := ginx.QueryInt64(c, "end", time.Now().Unix())
+	start := ginx.QueryInt64(c, "start", end-int64(slo.Window().Seconds()))
+	step := ginx.QueryInt64(c, "step", 0)
+	if start >= end {
+		ginx.Bomb(http.StatusBadRequest, "start must be less than end")
+	}
+
+	if step <= 0 {
+		step = (end - start) / 200
+		if step < 60 {
+			step = 60
+		}
+	}
+
+	if (end-start)/step > maxSloBudgetPoints {
+		ginx.Bomb(http.StatusBadRequest, "too many points, please increase step")
+	}
+
+	r := pkgprom.Range{
+		Start: time.Unix(start, 0),
+		End:   time.Unix(end, 0),
+		Step:  time.Duration(step) * time.Second,
+	}
+
+	value, _, err := cli.QueryRange(context.Background(), sloMaxQuery(slo, slo.Window()), r)
+	ginx.Dangerous(err)
+
+	points := []models.SloBudgetPoint{}
+	if matrix, ok := value.(model.Matrix); ok && len(matrix) > 0 {
+		for _, p := range matrix[0].Values {
+			points = append(points, slo.NewBudgetPoint(p.Timestamp.Unix(), sloValue(p.Value)))
+		}
+	}
+
+	ginx.NewRender(c).Data(points, nil)
+}
+
+// sloMaxQuery 查询结果有多条曲线时取最差的一条
+func sloMaxQuery(slo *models.Slo, window time.Duration) string {
+	return "max(" + slo.ErrorRatioQuery(window) + ")"
+}
+
+func sloQueryErrorRatio(cli pkgprom.API, slo *models.Slo, window time.Duration, ts time.Time) (float64, error) {
+	value, _, err := cli.Query(context.Background(), sloMaxQuery(slo, window), ts)
+	if err != nil {
+		return 0, err
+	}
+
+	if vector, ok := value.(model.Vector); ok && len(vector) > 0 {
+		return sloValue(vector[0].Value), nil
+	}
+
+	return 0, nil
+}
+
+// sloValue 没有请求时 good/total 为 NaN，按没有错误处理
+func sloValue(v model.SampleValue) float64 {
+	f := float64(v)
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0
+	}
+	return f
+}
@@ -77,6 +77,9 @@ func (rt *Router) targetGets(c *gin.Context) {
 		}
 	}
 
+	// 限制了业务组的 token 看不到未归组的机器
+	bgids = tokenBusiGroupIds(c, bgids)
+
 	options := []models.BuildTargetWhereOption{
 		models.BuildTargetWhereWithBgids(bgids),
 		models.BuildTargetWhereWithDsIds(dsIds),
@@ -60,6 +60,8 @@ func (rt *Router) taskGetsByGids(c *gin.Context) {
 		}
 	}
 
+	gids = tokenBusiGroupIds(c, gids)
+
 	mine := ginx.QueryBool(c, "mine", false)
 	days := ginx.QueryInt64(c, "days", 7)
 	limit := ginx.QueryInt(c, "limit", 20)
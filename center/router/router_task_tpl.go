@@ -55,6 +55,8 @@ func (rt *Router) taskTplGetsByGids(c *gin.Context) {
 		}
 	}
 
+	gids = tokenBusiGroupIds(c, gids)
+
 	total, err := models.TaskTplTotal(rt.Ctx, gids, query)
 	ginx.Dangerous(err)
 
@@ -1,9 +1,43 @@
 package cli
 
 import (
+	"encoding/json"
+	"os"
+
 	"github.com/ccfos/nightingale/v6/cli/upgrade"
+	"github.com/ccfos/nightingale/v6/pkg/grafana"
 )
 
 func Upgrade(configFile string) error {
 	return upgrade.Upgrade(configFile)
 }
+
+// ConvertGrafana 转换 Grafana dashboard json 文件，输出的 json 可以直接在页面上导入，返回转换过程中的 warnings
+func ConvertGrafana(input, output string) ([]string, error) {
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return nil, err
+	}
+
+	board, err := grafana.Convert(data)
+	if err != nil {
+		return nil, err
+	}
+
+	bs, err := json.MarshalIndent(map[string]interface{}{
+		"name":    board.Name,
+		"tags":    board.Tags,
+		"configs": board.Configs,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	if output == "" {
+		_, err = os.Stdout.Write(append(bs, '\n'))
+	} else {
+		err = os.WriteFile(output, bs, 0644)
+	}
+
+	return board.Warnings, err
+}
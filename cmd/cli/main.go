@@ -13,6 +13,8 @@ var (
 	upgrade     = flag.Bool("upgrade", false, "Upgrade the database.")
 	showVersion = flag.Bool("version", false, "Show version.")
 	configFile  = flag.String("config", "", "Specify webapi.conf of v5.x version")
+	grafana     = flag.String("grafana", "", "Convert the specified grafana dashboard json file to n9e dashboard.")
+	output      = flag.String("output", "", "Output file of the converted dashboard, default is stdout.")
 )
 
 func main() {
@@ -37,4 +39,17 @@ func main() {
 		fmt.Print("Upgrade successfully.")
 		os.Exit(0)
 	}
+
+	if *grafana != "" {
+		warnings, err := cli.ConvertGrafana(*grafana, *output)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		for _, w := range warnings {
+			fmt.Fprintln(os.Stderr, "warning:", w)
+		}
+		os.Exit(0)
+	}
 }
@@ -8,6 +8,8 @@ import (
 	"github.com/ccfos/nightingale/v6/alert"
 	"github.com/ccfos/nightingale/v6/alert/astats"
 	"github.com/ccfos/nightingale/v6/alert/dispatch"
+	"github.com/ccfos/nightingale/v6/alert/eventchart"
+	"github.com/ccfos/nightingale/v6/alert/pipeline/processor/dsquery"
 	"github.com/ccfos/nightingale/v6/alert/process"
 	alertrt "github.com/ccfos/nightingale/v6/alert/router"
 	"github.com/ccfos/nightingale/v6/center/metas"
@@ -19,6 +21,7 @@ import (
 	"github.com/ccfos/nightingale/v6/pkg/httpx"
 	"github.com/ccfos/nightingale/v6/pkg/logx"
 	"github.com/ccfos/nightingale/v6/pkg/macros"
+	"github.com/ccfos/nightingale/v6/pkg/poster"
 	"github.com/ccfos/nightingale/v6/prom"
 	"github.com/ccfos/nightingale/v6/pushgw/idents"
 	pushgwrt "github.com/ccfos/nightingale/v6/pushgw/router"
@@ -43,11 +46,21 @@ func Initialize(configDir string, cryptoKey string) (func(), error) {
 	}
 	ctx := ctx.NewContext(context.Background(), nil, false, config.CenterApi)
 
+	// 中心端不可达时事件和心跳先暂存到本地
+	if err := poster.StartOfflineSpool(config.CenterApi); err != nil {
+		return nil, fmt.Errorf("failed to init offline spool: %v", err)
+	}
+
 	var redis storage.Redis
 	redis, err = storage.NewRedis(config.Redis)
 	if err != nil {
 		return nil, err
 	}
+	eventchart.RegisterRedis(redis)
+
+	if !config.CacheChange.Disable {
+		memsto.StartCacheChangePoller(ctx, config.CacheChange.FullSyncInterval)
+	}
 
 	syncStats := memsto.NewSyncStats()
 
@@ -81,6 +94,7 @@ func Initialize(configDir string, cryptoKey string) (func(), error) {
 		promClients := prom.NewPromClient(ctx)
 
 		dispatch.InitRegisterQueryFunc(promClients)
+		dsquery.RegisterPromClients(promClients)
 
 		externalProcessors := process.NewExternalProcessors()
 
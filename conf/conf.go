@@ -24,6 +24,8 @@ type ConfigType struct {
 	Redis     storage.RedisConfig
 	CenterApi CenterApi
 
+	CacheChange CacheChange
+
 	Pushgw pconf.Pushgw
 	Alert  aconf.Alert
 	Center cconf.Center
@@ -35,6 +37,19 @@ type CenterApi struct {
 	BasicAuthUser string
 	BasicAuthPass string
 	Timeout       int64
+
+	// 边缘机房离线生存：同步成功后把配置签名落盘，中心端不可达时从本地快照启动，
+	// 离线期间产生的事件和心跳也暂存在这个目录，为空表示不启用
+	OfflineDir   string
+	SnapshotKey  string // 快照签名密钥，为空时使用 BasicAuthPass
+	MaxSpoolSize int64  // unit: MB, 离线暂存数据上限，默认 256MB
+}
+
+// CacheChange 配置变更推送，中心端通过 redis pub/sub 广播，边缘机房长轮询中心端
+// 收到推送后 memsto 缓存立即更新，定时全量同步只作为兜底
+type CacheChange struct {
+	Disable          bool
+	FullSyncInterval int64 // unit: s, 推送正常时的全量同步间隔，默认 60s
 }
 
 type GlobalConfig struct {
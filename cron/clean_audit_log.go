@@ -0,0 +1,58 @@
+package cron
+
+import (
+	"time"
+
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+
+	"github.com/robfig/cron/v3"
+	"github.com/toolkits/pkg/logger"
+)
+
+func cleanAuditLogInBatches(ctx *ctx.Context, day int) {
+	threshold := time.Now().Unix() - 86400*int64(day)
+	var totalDeleted int64
+
+	for {
+		deleted, err := models.DeleteAuditLogsInBatches(ctx, threshold, defaultBatchSize)
+		if err != nil {
+			logger.Errorf("Failed to clean audit logs in batch: %v", err)
+			return
+		}
+
+		totalDeleted += deleted
+
+		if deleted < int64(defaultBatchSize) {
+			break
+		}
+
+		time.Sleep(time.Duration(defaultSleepMs) * time.Millisecond)
+	}
+
+	if totalDeleted > 0 {
+		logger.Infof("Cleaned %d audit logs older than %d days", totalDeleted, day)
+	}
+}
+
+// CleanAuditLog starts a cron job to clean expired audit logs in batches
+// Runs daily at 5:00 AM
+// day: 审计日志保留天数，默认 180 天
+func CleanAuditLog(ctx *ctx.Context, day int) {
+	c := cron.New()
+	if day < 1 {
+		day = 180 // default retention: 180 days
+	}
+
+	_, err := c.AddFunc("0 5 * * *", func() {
+		cleanAuditLogInBatches(ctx, day)
+	})
+
+	if err != nil {
+		logger.Errorf("Failed to add clean audit log cron job: %v", err)
+		return
+	}
+
+	c.Start()
+	logger.Infof("Audit log cleanup cron started, retention: %d days", day)
+}
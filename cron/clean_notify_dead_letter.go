@@ -0,0 +1,58 @@
+package cron
+
+import (
+	"time"
+
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+
+	"github.com/robfig/cron/v3"
+	"github.com/toolkits/pkg/logger"
+)
+
+func cleanNotifyDeadLetterInBatches(ctx *ctx.Context, day int) {
+	threshold := time.Now().Unix() - 86400*int64(day)
+	var totalDeleted int64
+
+	for {
+		deleted, err := models.DeleteNotifyDeadLettersInBatches(ctx, threshold, defaultBatchSize)
+		if err != nil {
+			logger.Errorf("Failed to clean notify dead letters in batch: %v", err)
+			return
+		}
+
+		totalDeleted += deleted
+
+		if deleted < int64(defaultBatchSize) {
+			break
+		}
+
+		time.Sleep(time.Duration(defaultSleepMs) * time.Millisecond)
+	}
+
+	if totalDeleted > 0 {
+		logger.Infof("Cleaned %d notify dead letters older than %d days", totalDeleted, day)
+	}
+}
+
+// CleanNotifyDeadLetter starts a cron job to clean finished notify dead letters in batches
+// Runs daily at 5:30 AM
+// day: 重投成功或放弃的死信保留天数，默认 30 天
+func CleanNotifyDeadLetter(ctx *ctx.Context, day int) {
+	c := cron.New()
+	if day < 1 {
+		day = 30 // default retention: 30 days
+	}
+
+	_, err := c.AddFunc("30 5 * * *", func() {
+		cleanNotifyDeadLetterInBatches(ctx, day)
+	})
+
+	if err != nil {
+		logger.Errorf("Failed to add clean notify dead letter cron job: %v", err)
+		return
+	}
+
+	c.Start()
+	logger.Infof("Notify dead letter cleanup cron started, retention: %d days", day)
+}
@@ -17,6 +17,14 @@ func cleanNotifyRecord(ctx *ctx.Context, day int) {
 		logger.Errorf("Failed to clean notify record: %v", err)
 	}
 
+	// 已结束的故障自愈执行记录、事件时间线和通知记录保留相同的天数
+	if _, err := models.DeleteRemediationRuns(ctx, lastWeek); err != nil {
+		logger.Errorf("Failed to clean remediation run: %v", err)
+	}
+
+	if _, err := models.DeleteEventTimelines(ctx, lastWeek); err != nil {
+		logger.Errorf("Failed to clean event timeline: %v", err)
+	}
 }
 
 // 每天凌晨1点执行清理任务
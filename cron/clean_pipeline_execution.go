@@ -41,6 +41,17 @@ func cleanPipelineExecutionInBatches(ctx *ctx.Context, day int) {
 	if totalDeleted > 0 {
 		logger.Infof("Cleaned %d pipeline execution records older than %d days", totalDeleted, day)
 	}
+
+	// 已结束的等待和执行记录保留相同的天数
+	deleted, err := models.DeleteEventPipelineWaits(ctx, threshold)
+	if err != nil {
+		logger.Errorf("Failed to clean pipeline wait records: %v", err)
+		return
+	}
+
+	if deleted > 0 {
+		logger.Infof("Cleaned %d pipeline wait records older than %d days", deleted, day)
+	}
 }
 
 // CleanPipelineExecution starts a cron job to clean old pipeline execution records in batches
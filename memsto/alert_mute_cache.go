@@ -18,6 +18,7 @@ type AlertMuteCacheType struct {
 	statLastUpdated int64
 	ctx             *ctx.Context
 	stats           *Stats
+	changes         *cacheChanges
 
 	sync.RWMutex
 	mutes map[int64][]*models.AlertMute // key: busi_group_id
@@ -29,6 +30,7 @@ func NewAlertMuteCache(ctx *ctx.Context, stats *Stats) *AlertMuteCacheType {
 		statLastUpdated: -1,
 		ctx:             ctx,
 		stats:           stats,
+		changes:         watchCacheChanges("alert_mute"),
 		mutes:           make(map[int64][]*models.AlertMute),
 	}
 	amc.SyncAlertMutes()
@@ -95,9 +97,12 @@ func (amc *AlertMuteCacheType) SyncAlertMutes() {
 }
 
 func (amc *AlertMuteCacheType) loopSyncAlertMutes() {
-	duration := time.Duration(9000) * time.Millisecond
 	for {
-		time.Sleep(duration)
+		if amc.changes.waitChanged(syncInterval()) {
+			// 收到变更通知，跳过统计信息比较直接全量同步
+			amc.statTotal = -1
+		}
+
 		if err := amc.syncAlertMutes(); err != nil {
 			logger.Warning("failed to sync alert mutes:", err)
 		}
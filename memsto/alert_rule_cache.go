@@ -18,6 +18,7 @@ type AlertRuleCacheType struct {
 	statLastUpdated int64
 	ctx             *ctx.Context
 	stats           *Stats
+	changes         *cacheChanges
 
 	sync.RWMutex
 	rules map[int64]*models.AlertRule // key: rule id
@@ -29,6 +30,7 @@ func NewAlertRuleCache(ctx *ctx.Context, stats *Stats) *AlertRuleCacheType {
 		statLastUpdated: -1,
 		ctx:             ctx,
 		stats:           stats,
+		changes:         watchCacheChanges("alert_rule"),
 		rules:           make(map[int64]*models.AlertRule),
 	}
 	arc.SyncAlertRules()
@@ -92,9 +94,23 @@ func (arc *AlertRuleCacheType) SyncAlertRules() {
 }
 
 func (arc *AlertRuleCacheType) loopSyncAlertRules() {
-	duration := time.Duration(9000) * time.Millisecond
 	for {
-		time.Sleep(duration)
+		ids, full := arc.changes.wait(syncInterval())
+		if len(ids) > 0 {
+			err := arc.applyChanges(ids)
+			if err == nil {
+				continue
+			}
+
+			logger.Warning("failed to apply alert rule changes:", err)
+			full = true
+		}
+
+		if full {
+			// 跳过统计信息比较直接全量同步
+			arc.statTotal = -1
+		}
+
 		if err := arc.syncAlertRules(); err != nil {
 			logger.Warning("failed to sync alert rules:", err)
 		}
@@ -136,3 +152,36 @@ func (arc *AlertRuleCacheType) syncAlertRules() error {
 
 	return nil
 }
+
+// applyChanges 只重新加载发生变更的规则，查不到的说明已被删除或禁用
+// 先查统计信息再查规则，即使读到的是事务提交前的数据，下一次轮询时统计信息也会变化从而触发全量同步
+func (arc *AlertRuleCacheType) applyChanges(ids []int64) error {
+	start := time.Now()
+	stat, err := models.AlertRuleStatistics(arc.ctx)
+	if err != nil {
+		return errors.WithMessage(err, "failed to exec AlertRuleStatistics")
+	}
+
+	lst, err := models.AlertRuleEnabledGetsByIds(arc.ctx, ids)
+	if err != nil {
+		return errors.WithMessage(err, "failed to exec AlertRuleEnabledGetsByIds")
+	}
+
+	arc.Lock()
+	for _, id := range ids {
+		delete(arc.rules, id)
+	}
+	for i := 0; i < len(lst); i++ {
+		arc.rules[lst[i].Id] = lst[i]
+	}
+	total := len(arc.rules)
+	arc.Unlock()
+
+	arc.statTotal = stat.Total
+	arc.statLastUpdated = stat.LastUpdated
+
+	ms := time.Since(start).Milliseconds()
+	dumper.PutSyncRecord("alert_rules", start.Unix(), ms, total, fmt.Sprintf("incremental, changed ids: %v", ids))
+
+	return nil
+}
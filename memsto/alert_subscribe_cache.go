@@ -18,6 +18,7 @@ type AlertSubscribeCacheType struct {
 	statLastUpdated int64
 	ctx             *ctx.Context
 	stats           *Stats
+	changes         *cacheChanges
 
 	sync.RWMutex
 	subs map[int64][]*models.AlertSubscribe
@@ -29,6 +30,7 @@ func NewAlertSubscribeCache(ctx *ctx.Context, stats *Stats) *AlertSubscribeCache
 		statLastUpdated: -1,
 		ctx:             ctx,
 		stats:           stats,
+		changes:         watchCacheChanges("alert_subscribe"),
 		subs:            make(map[int64][]*models.AlertSubscribe),
 	}
 	asc.SyncAlertSubscribes()
@@ -108,9 +110,12 @@ func (c *AlertSubscribeCacheType) SyncAlertSubscribes() {
 }
 
 func (c *AlertSubscribeCacheType) loopSyncAlertSubscribes() {
-	duration := time.Duration(9000) * time.Millisecond
 	for {
-		time.Sleep(duration)
+		if c.changes.waitChanged(syncInterval()) {
+			// 收到变更通知，跳过统计信息比较直接全量同步
+			c.statTotal = -1
+		}
+
 		if err := c.syncAlertSubscribes(); err != nil {
 			logger.Warning("failed to sync alert subscribes:", err)
 		}
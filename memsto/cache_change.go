@@ -0,0 +1,340 @@
+package memsto
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+	"github.com/ccfos/nightingale/v6/pkg/poster"
+	"github.com/ccfos/nightingale/v6/storage"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/toolkits/pkg/logger"
+	"gorm.io/gorm"
+)
+
+const (
+	cacheChangeChannel     = "n9e_cache_change"
+	cacheChangeBufferSize  = 4096
+	defaultSyncInterval    = 9 * time.Second
+	defaultFullSyncSeconds = 60
+)
+
+// CacheChangeTables 会推送变更通知的表，缓存收到通知后立即更新，不用等下一次轮询
+var CacheChangeTables = []string{
+	"alert_rule", "recording_rule", "alert_mute", "alert_subscribe",
+	"notify_rule", "notify_channel", "message_template", "datasource", "rule_group", "user_token", "remediation_policy",
+}
+
+// cacheChanges 合并某个缓存关心的变更通知，由缓存自己的同步协程消费
+type cacheChanges struct {
+	sync.Mutex
+	ids  map[int64]struct{}
+	full bool
+	ch   chan struct{}
+}
+
+func (c *cacheChanges) push(ids []int64) {
+	c.Lock()
+	if len(ids) == 0 {
+		c.full = true
+	}
+	for _, id := range ids {
+		c.ids[id] = struct{}{}
+	}
+	c.Unlock()
+
+	select {
+	case c.ch <- struct{}{}:
+	default:
+	}
+}
+
+// wait 等待变更通知或者到达轮询周期
+// ids 不为空表示需要增量更新这些对象，full 为 true 表示需要强制全量同步，两者都为空表示周期性检查
+func (c *cacheChanges) wait(d time.Duration) ([]int64, bool) {
+	select {
+	case <-c.ch:
+	case <-time.After(d):
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	ids := make([]int64, 0, len(c.ids))
+	for id := range c.ids {
+		ids = append(ids, id)
+	}
+
+	full := c.full
+	c.ids = make(map[int64]struct{})
+	c.full = false
+
+	if full {
+		return nil, true
+	}
+
+	return ids, false
+}
+
+// waitChanged 不支持增量更新的缓存使用，有任何变更都全量同步
+func (c *cacheChanges) waitChanged(d time.Duration) bool {
+	ids, full := c.wait(d)
+	return full || len(ids) > 0
+}
+
+// CacheChangeBatch 长轮询接口的返回，Resync 为 true 表示边缘机房错过了部分通知，需要全量同步
+type CacheChangeBatch struct {
+	Epoch   string                `json:"epoch"`
+	Seq     int64                 `json:"seq"`
+	Resync  bool                  `json:"resync"`
+	Changes []*models.CacheChange `json:"changes"`
+}
+
+type cacheChangeEntry struct {
+	seq    int64
+	change *models.CacheChange
+}
+
+type cacheChangeBus struct {
+	sync.RWMutex
+	watchers map[string][]*cacheChanges // key: table
+
+	connected        atomic.Bool
+	fullSyncInterval time.Duration
+
+	// 中心端保存最近的变更，供边缘机房长轮询，epoch 区分不同的中心端进程
+	epoch  string
+	seq    int64
+	buf    []cacheChangeEntry
+	notify chan struct{}
+}
+
+var changeBus = &cacheChangeBus{
+	watchers:         make(map[string][]*cacheChanges),
+	fullSyncInterval: defaultFullSyncSeconds * time.Second,
+	epoch:            uuid.NewString(),
+	notify:           make(chan struct{}),
+}
+
+func watchCacheChanges(tables ...string) *cacheChanges {
+	c := &cacheChanges{
+		ids: make(map[int64]struct{}),
+		ch:  make(chan struct{}, 1),
+	}
+
+	changeBus.Lock()
+	for _, t := range tables {
+		changeBus.watchers[t] = append(changeBus.watchers[t], c)
+	}
+	changeBus.Unlock()
+
+	return c
+}
+
+// syncInterval 收到推送时缓存随时更新，轮询只作为兜底，间隔可以放大
+func syncInterval() time.Duration {
+	if changeBus.connected.Load() {
+		return changeBus.fullSyncInterval
+	}
+	return defaultSyncInterval
+}
+
+func setFullSyncInterval(seconds int64) {
+	if seconds <= 0 {
+		seconds = defaultFullSyncSeconds
+	}
+	changeBus.fullSyncInterval = time.Duration(seconds) * time.Second
+}
+
+func (b *cacheChangeBus) dispatch(c *models.CacheChange) {
+	b.Lock()
+	b.seq++
+	b.buf = append(b.buf, cacheChangeEntry{seq: b.seq, change: c})
+	if len(b.buf) > cacheChangeBufferSize {
+		b.buf = b.buf[len(b.buf)-cacheChangeBufferSize:]
+	}
+	close(b.notify)
+	b.notify = make(chan struct{})
+
+	var watchers []*cacheChanges
+	if c.Op == models.CacheChangeResync {
+		for _, ws := range b.watchers {
+			watchers = append(watchers, ws...)
+		}
+	} else {
+		watchers = b.watchers[c.Table]
+	}
+	b.Unlock()
+
+	for _, w := range watchers {
+		if c.Op == models.CacheChangeResync {
+			w.push(nil)
+		} else {
+			w.push(c.Ids)
+		}
+	}
+}
+
+// CacheChangesSince 返回 seq 之后的变更，没有新变更时最多等待 wait
+func CacheChangesSince(epoch string, seq int64, wait time.Duration) *CacheChangeBatch {
+	b := changeBus
+
+	b.RLock()
+	notify := b.notify
+	current := b.seq
+	b.RUnlock()
+
+	if epoch == b.epoch && seq == current {
+		select {
+		case <-notify:
+		case <-time.After(wait):
+		}
+	}
+
+	b.RLock()
+	defer b.RUnlock()
+
+	batch := &CacheChangeBatch{Epoch: b.epoch, Seq: b.seq, Changes: []*models.CacheChange{}}
+	if epoch != b.epoch || seq > b.seq || (len(b.buf) > 0 && seq < b.buf[0].seq-1) {
+		batch.Resync = true
+		return batch
+	}
+
+	for _, e := range b.buf {
+		if e.seq > seq {
+			batch.Changes = append(batch.Changes, e.change)
+		}
+	}
+
+	return batch
+}
+
+type redisSubscriber interface {
+	Subscribe(ctx context.Context, channels ...string) *redis.PubSub
+}
+
+// StartCacheChangePublisher 中心端在配置表写入成功后发布变更通知
+// 通过 redis pub/sub 广播给所有中心端实例，redis 不支持订阅时只通知本进程
+func StartCacheChangePublisher(ctx *ctx.Context, db *gorm.DB, rds storage.Redis, fullSyncInterval int64) error {
+	setFullSyncInterval(fullSyncInterval)
+
+	sub, ok := rds.(redisSubscriber)
+	if !ok {
+		// 其他中心端收不到通知，保持 connected 为 false，所有缓存继续使用默认的轮询周期
+		logger.Warning("redis does not support pub/sub, cache changes only take effect in this process")
+		return models.RegisterCacheChangeCallbacks(db, CacheChangeTables, changeBus.dispatch)
+	}
+
+	go subscribeCacheChanges(ctx, sub)
+
+	return models.RegisterCacheChangeCallbacks(db, CacheChangeTables, func(c *models.CacheChange) {
+		bs, err := json.Marshal(c)
+		if err == nil {
+			err = rds.Publish(ctx.Ctx, cacheChangeChannel, bs).Err()
+		}
+
+		if err != nil {
+			logger.Warningf("failed to publish cache change %+v: %v", c, err)
+			changeBus.dispatch(c)
+		}
+	})
+}
+
+func subscribeCacheChanges(ctx *ctx.Context, sub redisSubscriber) {
+	ps := sub.Subscribe(ctx.Ctx, cacheChangeChannel)
+	defer ps.Close()
+
+	subscribed := false
+	for {
+		msg, err := ps.Receive(ctx.Ctx)
+		if err != nil {
+			if changeBus.connected.Swap(false) {
+				logger.Warningf("cache change subscription broken: %v", err)
+			}
+			time.Sleep(time.Second)
+			continue
+		}
+
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			changeBus.connected.Store(true)
+			if subscribed {
+				// 重新连上后可能错过了部分通知
+				changeBus.dispatch(&models.CacheChange{Op: models.CacheChangeResync})
+			}
+			subscribed = true
+		case *redis.Message:
+			var c models.CacheChange
+			if err := json.Unmarshal([]byte(m.Payload), &c); err != nil {
+				logger.Warningf("failed to decode cache change %s: %v", m.Payload, err)
+				continue
+			}
+			changeBus.dispatch(&c)
+		}
+	}
+}
+
+// StartCacheChangePoller 边缘机房通过长轮询中心端获取变更通知，失败时退回定时全量同步
+func StartCacheChangePoller(ctx *ctx.Context, fullSyncInterval int64) {
+	setFullSyncInterval(fullSyncInterval)
+	go pollCacheChanges(ctx)
+}
+
+func pollCacheChanges(ctx *ctx.Context) {
+	const wait = 30 * time.Second
+
+	cfg := ctx.CenterApi
+	if cfg.Timeout < wait.Milliseconds()+10000 {
+		cfg.Timeout = wait.Milliseconds() + 10000
+	}
+
+	var epoch string
+	var seq int64
+	var idx int
+	first := true
+
+	for {
+		if len(cfg.Addrs) == 0 {
+			logger.Warning("no center api addresses configured, cache changes disabled")
+			return
+		}
+
+		// 保持连接同一个中心端，避免不同实例的 epoch 不一致导致反复全量同步
+		addr := cfg.Addrs[idx%len(cfg.Addrs)]
+		u := fmt.Sprintf("%s/v1/n9e/cache-changes?epoch=%s&seq=%d&wait=%d", addr, url.QueryEscape(epoch), seq, int(wait.Seconds()))
+
+		batch, err := poster.GetByUrl[*CacheChangeBatch](u, cfg)
+		if err != nil || batch == nil {
+			if changeBus.connected.Swap(false) {
+				logger.Warningf("failed to poll cache changes from %s: %v", addr, err)
+			}
+			idx++
+			epoch = ""
+			time.Sleep(5 * time.Second)
+			continue
+		}
+
+		changeBus.connected.Store(true)
+		epoch, seq = batch.Epoch, batch.Seq
+
+		if batch.Resync {
+			if !first {
+				changeBus.dispatch(&models.CacheChange{Op: models.CacheChangeResync})
+			}
+		} else {
+			for _, c := range batch.Changes {
+				changeBus.dispatch(c)
+			}
+		}
+
+		first = false
+	}
+}
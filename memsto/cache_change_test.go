@@ -0,0 +1,94 @@
+package memsto
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+
+	"github.com/glebarez/sqlite"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+func TestCacheChangesMerge(t *testing.T) {
+	c := watchCacheChanges("test_merge")
+
+	changeBus.dispatch(&models.CacheChange{Table: "test_merge", Op: models.CacheChangeUpsert, Ids: []int64{1, 2}})
+	changeBus.dispatch(&models.CacheChange{Table: "test_merge", Op: models.CacheChangeDelete, Ids: []int64{2, 3}})
+	changeBus.dispatch(&models.CacheChange{Table: "other", Op: models.CacheChangeUpsert, Ids: []int64{4}})
+
+	ids, full := c.wait(time.Second)
+	if full || len(ids) != 3 {
+		t.Fatalf("expected 3 merged ids, got %v full=%v", ids, full)
+	}
+
+	changeBus.dispatch(&models.CacheChange{Table: "test_merge", Op: models.CacheChangeUpsert, Ids: []int64{5}})
+	changeBus.dispatch(&models.CacheChange{Table: "test_merge", Op: models.CacheChangeDelete})
+
+	ids, full = c.wait(time.Second)
+	if !full || len(ids) != 0 {
+		t.Fatalf("expected full sync, got %v full=%v", ids, full)
+	}
+
+	changeBus.dispatch(&models.CacheChange{Op: models.CacheChangeResync})
+	if !c.waitChanged(time.Second) {
+		t.Fatalf("expected resync to reach every watcher")
+	}
+
+	if c.waitChanged(10 * time.Millisecond) {
+		t.Fatalf("expected no change")
+	}
+}
+
+func TestCacheChangesSince(t *testing.T) {
+	batch := CacheChangesSince("", 0, 0)
+	if !batch.Resync {
+		t.Fatalf("expected resync for unknown epoch")
+	}
+
+	epoch, seq := batch.Epoch, batch.Seq
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		changeBus.dispatch(&models.CacheChange{Table: "test_since", Op: models.CacheChangeUpsert, Ids: []int64{1}})
+	}()
+
+	batch = CacheChangesSince(epoch, seq, time.Second)
+	if batch.Resync || len(batch.Changes) != 1 || batch.Seq != seq+1 {
+		t.Fatalf("unexpected batch: %+v", batch)
+	}
+
+	if batch.Changes[0].Table != "test_since" {
+		t.Fatalf("unexpected change: %+v", batch.Changes[0])
+	}
+
+	batch = CacheChangesSince(epoch, seq+1, 10*time.Millisecond)
+	if batch.Resync || len(batch.Changes) != 0 {
+		t.Fatalf("expected empty batch after timeout: %+v", batch)
+	}
+}
+
+// noPubSubRedis 只实现命令接口，不支持订阅，类似部分 redis 代理
+type noPubSubRedis struct {
+	redis.Cmdable
+}
+
+func TestCacheChangePublisherWithoutPubSub(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := ctx.NewContext(context.Background(), db, true)
+	if err := StartCacheChangePublisher(c, db, noPubSubRedis{}, 60); err != nil {
+		t.Fatal(err)
+	}
+
+	// 其他中心端收不到通知，不能放大轮询周期
+	if changeBus.connected.Load() || syncInterval() != defaultSyncInterval {
+		t.Fatalf("expected default sync interval without pub/sub, got %v", syncInterval())
+	}
+}
@@ -19,6 +19,7 @@ type DatasourceCacheType struct {
 	statLastUpdated     int64
 	ctx                 *ctx.Context
 	stats               *Stats
+	changes             *cacheChanges
 	DatasourceCheckHook func(*gin.Context) bool
 	DatasourceFilter    func([]*models.Datasource, *models.User) []*models.Datasource
 
@@ -34,6 +35,7 @@ func NewDatasourceCache(ctx *ctx.Context, stats *Stats) *DatasourceCacheType {
 		statLastUpdated:     -1,
 		ctx:                 ctx,
 		stats:               stats,
+		changes:             watchCacheChanges("datasource"),
 		ds:                  make(map[int64]*models.Datasource),
 		CateToIDs:           make(map[string]map[int64]*models.Datasource),
 		CateToNames:         make(map[string]map[string]int64),
@@ -98,9 +100,12 @@ func (d *DatasourceCacheType) SyncDatasources() {
 }
 
 func (d *DatasourceCacheType) loopSyncDatasources() {
-	duration := time.Duration(9000) * time.Millisecond
 	for {
-		time.Sleep(duration)
+		if d.changes.waitChanged(syncInterval()) {
+			// 收到变更通知，跳过统计信息比较直接全量同步
+			d.statTotal = -1
+		}
+
 		if err := d.syncDatasources(); err != nil {
 			logger.Warning("failed to sync datasources:", err)
 		}
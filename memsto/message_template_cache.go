@@ -18,6 +18,7 @@ type MessageTemplateCacheType struct {
 	statLastUpdated int64
 	ctx             *ctx.Context
 	stats           *Stats
+	changes         *cacheChanges
 
 	sync.RWMutex
 	templates map[int64]*models.MessageTemplate // key: template id
@@ -29,6 +30,7 @@ func NewMessageTemplateCache(ctx *ctx.Context, stats *Stats) *MessageTemplateCac
 		statLastUpdated: -1,
 		ctx:             ctx,
 		stats:           stats,
+		changes:         watchCacheChanges("message_template"),
 		templates:       make(map[int64]*models.MessageTemplate),
 	}
 	mtc.SyncMessageTemplates()
@@ -92,9 +94,12 @@ func (mtc *MessageTemplateCacheType) SyncMessageTemplates() {
 }
 
 func (mtc *MessageTemplateCacheType) loopSyncMessageTemplates() {
-	duration := time.Duration(9000) * time.Millisecond
 	for {
-		time.Sleep(duration)
+		if mtc.changes.waitChanged(syncInterval()) {
+			// 收到变更通知，跳过统计信息比较直接全量同步
+			mtc.statTotal = -1
+		}
+
 		if err := mtc.syncMessageTemplates(); err != nil {
 			logger.Warning("failed to sync message templates:", err)
 		}
@@ -28,6 +28,8 @@ type NotifyTask struct {
 	TplContent    map[string]interface{}
 	CustomParams  map[string]string
 	Sendtos       []string
+	// Done 不为空时，发送结果交给调用方处理，不再写入通知记录和死信，用于备用媒介判断是否需要切换
+	Done func(results []*NotifyResult)
 }
 
 // NotifyRecordFunc 通知记录函数类型
@@ -38,6 +40,7 @@ type NotifyChannelCacheType struct {
 	statLastUpdated int64
 	ctx             *ctx.Context
 	stats           *Stats
+	changes         *cacheChanges
 
 	sync.RWMutex
 	channels      map[int64]*models.NotifyChannelConfig // key: channel id
@@ -60,6 +63,7 @@ func NewNotifyChannelCache(ctx *ctx.Context, stats *Stats) *NotifyChannelCacheTy
 		statLastUpdated: -1,
 		ctx:             ctx,
 		stats:           stats,
+		changes:         watchCacheChanges("notify_channel"),
 		channels:        make(map[int64]*models.NotifyChannelConfig),
 		channelsQueue:   make(map[int64]*list.SafeListLimited),
 		queueQuitCh:     make(map[int64]chan struct{}),
@@ -273,39 +277,80 @@ func (ncc *NotifyChannelCacheType) startNotifyConsumer(channelID int64, queue *l
 	}
 }
 
+// NotifyResult 一次发送的结果，Request 为渲染后的 http 请求，Message 为其他媒介渲染后的消息，渲染失败时为空
+type NotifyResult struct {
+	Target  string
+	Resp    string
+	Err     error
+	Request *models.HTTPNotifyRequest
+	Message *models.DeadLetterMessage
+}
+
 // processNotifyTask 处理通知任务（仅处理 http 类型）
 func (ncc *NotifyChannelCacheType) processNotifyTask(task *NotifyTask) {
+	results := ncc.SendHTTPNow(task)
+	if task.Done != nil {
+		task.Done(results)
+		return
+	}
+
+	for _, ret := range results {
+		// 调用通知记录回调函数
+		if ncc.notifyRecordFunc != nil {
+			ncc.notifyRecordFunc(ncc.ctx, task.Events, task.NotifyRuleId, task.NotifyChannel.Name, ret.Target, ret.Resp, ret.Err)
+		}
+
+		SaveNotifyDeadLetter(ncc.ctx, task, ret)
+	}
+}
+
+// SendHTTPNow 不经过队列直接发送，通知记录和死信由调用方根据结果处理
+func (ncc *NotifyChannelCacheType) SendHTTPNow(task *NotifyTask) []*NotifyResult {
 	httpClient := ncc.GetHttpClient(task.NotifyChannel.ID)
 	logger.Debugf("processNotifyTask: task: %+v", task)
 
+	var results []*NotifyResult
 	// 现在只处理 http 类型，flashduty 保持直接发送
 	if task.NotifyChannel.RequestType == "http" {
 		if len(task.Sendtos) == 0 || ncc.needBatchContacts(task.NotifyChannel.RequestConfig.HTTPRequestConfig) {
-			start := time.Now()
-			resp, err := task.NotifyChannel.SendHTTP(task.Events, task.TplContent, task.CustomParams, task.Sendtos, httpClient)
-			resp = fmt.Sprintf("send_time: %s duration: %d ms %s", time.Now().Format("2006-01-02 15:04:05"), time.Since(start).Milliseconds(), resp)
-			logger.Infof("http_sendernotify_id: %d, channel_name: %v, event:%+v, tplContent:%v, customParams:%v, userInfo:%+v, respBody: %v, err: %v",
-				task.NotifyRuleId, task.NotifyChannel.Name, task.Events[0], task.TplContent, task.CustomParams, task.Sendtos, resp, err)
-
-			// 调用通知记录回调函数
-			if ncc.notifyRecordFunc != nil {
-				ncc.notifyRecordFunc(ncc.ctx, task.Events, task.NotifyRuleId, task.NotifyChannel.Name, ncc.getSendTarget(task.CustomParams, task.Sendtos), resp, err)
-			}
+			results = append(results, ncc.sendHTTP(task, task.Sendtos, httpClient))
 		} else {
 			for i := range task.Sendtos {
-				start := time.Now()
-				resp, err := task.NotifyChannel.SendHTTP(task.Events, task.TplContent, task.CustomParams, []string{task.Sendtos[i]}, httpClient)
-				resp = fmt.Sprintf("send_time: %s duration: %d ms %s", time.Now().Format("2006-01-02 15:04:05"), time.Since(start).Milliseconds(), resp)
-				logger.Infof("http_sender notify_id: %d, channel_name: %v, event:%+v, tplContent:%v, customParams:%v, userInfo:%+v, respBody: %v, err: %v",
-					task.NotifyRuleId, task.NotifyChannel.Name, task.Events[0], task.TplContent, task.CustomParams, task.Sendtos[i], resp, err)
-
-				// 调用通知记录回调函数
-				if ncc.notifyRecordFunc != nil {
-					ncc.notifyRecordFunc(ncc.ctx, task.Events, task.NotifyRuleId, task.NotifyChannel.Name, ncc.getSendTarget(task.CustomParams, []string{task.Sendtos[i]}), resp, err)
-				}
+				results = append(results, ncc.sendHTTP(task, []string{task.Sendtos[i]}, httpClient))
 			}
 		}
 	}
+
+	return results
+}
+
+func (ncc *NotifyChannelCacheType) sendHTTP(task *NotifyTask, sendtos []string, httpClient *http.Client) *NotifyResult {
+	start := time.Now()
+	ret := &NotifyResult{Target: ncc.getSendTarget(task.CustomParams, sendtos)}
+
+	ret.Request, ret.Err = task.NotifyChannel.RenderHTTPRequest(task.Events, task.TplContent, task.CustomParams, sendtos)
+	if ret.Err == nil {
+		ret.Resp, ret.Err = task.NotifyChannel.SendHTTPRequest(ret.Request, httpClient)
+	}
+
+	ret.Resp = fmt.Sprintf("send_time: %s duration: %d ms %s", time.Now().Format("2006-01-02 15:04:05"), time.Since(start).Milliseconds(), ret.Resp)
+	logger.Infof("http_sender notify_id: %d, channel_name: %v, event:%+v, tplContent:%v, customParams:%v, userInfo:%+v, respBody: %v, err: %v",
+		task.NotifyRuleId, task.NotifyChannel.Name, task.Events[0], task.TplContent, task.CustomParams, sendtos, ret.Resp, ret.Err)
+
+	return ret
+}
+
+// SaveNotifyDeadLetter 重试后仍然失败的请求保存为死信，由中心端后台任务继续重投
+// 渲染失败重投也没有意义，只保存发送失败的请求
+func SaveNotifyDeadLetter(ctx *ctx.Context, task *NotifyTask, ret *NotifyResult) {
+	if ret.Err == nil || (ret.Request == nil && ret.Message == nil) {
+		return
+	}
+
+	dl := models.NewNotifyDeadLetter(task.Events, task.NotifyRuleId, task.NotifyChannel, ret.Target, ret.Request, ret.Message, ret.Err)
+	if err := dl.Add(ctx); err != nil {
+		logger.Errorf("failed to save notify dead letter, notify_id: %d, channel_name: %v, err: %v", task.NotifyRuleId, task.NotifyChannel.Name, err)
+	}
 }
 
 // 判断是否需要批量发送联系人
@@ -397,9 +442,12 @@ func (ncc *NotifyChannelCacheType) SyncNotifyChannels() {
 }
 
 func (ncc *NotifyChannelCacheType) loopSyncNotifyChannels() {
-	duration := time.Duration(9000) * time.Millisecond
 	for {
-		time.Sleep(duration)
+		if ncc.changes.waitChanged(syncInterval()) {
+			// 收到变更通知，跳过统计信息比较直接全量同步
+			ncc.statTotal = -1
+		}
+
 		if err := ncc.syncNotifyChannels(); err != nil {
 			logger.Warning("failed to sync notify channels:", err)
 		}
@@ -503,10 +551,11 @@ func (ncc *NotifyChannelCacheType) startEmailSender(chID int64, smtp *models.SMT
 					m.Mail.GetHeader("Subject"), m.Mail.GetHeader("To"))
 			}
 
-			// 记录通知详情
-			if ncc.notifyRecordFunc != nil {
-				target := strings.Join(m.Mail.GetHeader("To"), ",")
-				ncc.notifyRecordFunc(ncc.ctx, m.Events, m.NotifyRuleId, "Email", target, "success", err)
+			target := strings.Join(m.Mail.GetHeader("To"), ",")
+			if m.Done != nil {
+				m.Done(err)
+			} else {
+				ncc.emailResult(chID, m, target, err)
 			}
 			size++
 
@@ -531,6 +580,25 @@ func (ncc *NotifyChannelCacheType) startEmailSender(chID int64, smtp *models.SMT
 	}
 }
 
+// emailResult 写入邮件的通知记录，重试后仍然失败的邮件交给死信重投
+func (ncc *NotifyChannelCacheType) emailResult(chID int64, m *models.EmailContext, target string, err error) {
+	if ncc.notifyRecordFunc != nil {
+		ncc.notifyRecordFunc(ncc.ctx, m.Events, m.NotifyRuleId, "Email", target, "success", err)
+	}
+
+	if err == nil || m.NotifyRuleId == 0 {
+		return
+	}
+
+	if channel := ncc.Get(chID); channel != nil {
+		SaveNotifyDeadLetter(ncc.ctx, &NotifyTask{Events: m.Events, NotifyRuleId: m.NotifyRuleId, NotifyChannel: channel}, &NotifyResult{
+			Target:  target,
+			Err:     err,
+			Message: &models.DeadLetterMessage{Events: m.Events, TplContent: m.TplContent, Sendtos: m.Sendtos},
+		})
+	}
+}
+
 func (ncc *NotifyChannelCacheType) dialSmtp(quitCh chan struct{}, d *gomail.Dialer) gomail.SendCloser {
 	for {
 		select {
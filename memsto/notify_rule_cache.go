@@ -18,6 +18,7 @@ type NotifyRuleCacheType struct {
 	statLastUpdated int64
 	ctx             *ctx.Context
 	stats           *Stats
+	changes         *cacheChanges
 
 	sync.RWMutex
 	rules map[int64]*models.NotifyRule // key: rule id
@@ -29,6 +30,7 @@ func NewNotifyRuleCache(ctx *ctx.Context, stats *Stats) *NotifyRuleCacheType {
 		statLastUpdated: -1,
 		ctx:             ctx,
 		stats:           stats,
+		changes:         watchCacheChanges("notify_rule"),
 		rules:           make(map[int64]*models.NotifyRule),
 	}
 	nrc.SyncNotifyRules()
@@ -92,9 +94,12 @@ func (nrc *NotifyRuleCacheType) SyncNotifyRules() {
 }
 
 func (nrc *NotifyRuleCacheType) loopSyncNotifyRules() {
-	duration := time.Duration(9000) * time.Millisecond
 	for {
-		time.Sleep(duration)
+		if nrc.changes.waitChanged(syncInterval()) {
+			// 收到变更通知，跳过统计信息比较直接全量同步
+			nrc.statTotal = -1
+		}
+
 		if err := nrc.syncNotifyRules(); err != nil {
 			logger.Warning("failed to sync notify rules:", err)
 		}
@@ -18,6 +18,7 @@ type RecordingRuleCacheType struct {
 	statLastUpdated int64
 	ctx             *ctx.Context
 	stats           *Stats
+	changes         *cacheChanges
 
 	sync.RWMutex
 	rules map[int64]*models.RecordingRule // key: rule id
@@ -29,6 +30,7 @@ func NewRecordingRuleCache(ctx *ctx.Context, stats *Stats) *RecordingRuleCacheTy
 		statLastUpdated: -1,
 		ctx:             ctx,
 		stats:           stats,
+		changes:         watchCacheChanges("recording_rule"),
 		rules:           make(map[int64]*models.RecordingRule),
 	}
 	rrc.SyncRecordingRules()
@@ -92,9 +94,23 @@ func (rrc *RecordingRuleCacheType) SyncRecordingRules() {
 }
 
 func (rrc *RecordingRuleCacheType) loopSyncRecordingRules() {
-	duration := time.Duration(9000) * time.Millisecond
 	for {
-		time.Sleep(duration)
+		ids, full := rrc.changes.wait(syncInterval())
+		if len(ids) > 0 {
+			err := rrc.applyChanges(ids)
+			if err == nil {
+				continue
+			}
+
+			logger.Warning("failed to apply recording rule changes:", err)
+			full = true
+		}
+
+		if full {
+			// 跳过统计信息比较直接全量同步
+			rrc.statTotal = -1
+		}
+
 		if err := rrc.syncRecordingRules(); err != nil {
 			logger.Warning("failed to sync recording rules:", err)
 		}
@@ -137,3 +153,36 @@ func (rrc *RecordingRuleCacheType) syncRecordingRules() error {
 
 	return nil
 }
+
+// applyChanges 只重新加载发生变更的规则，查不到的说明已被删除或禁用
+// 先查统计信息再查规则，即使读到的是事务提交前的数据，下一次轮询时统计信息也会变化从而触发全量同步
+func (rrc *RecordingRuleCacheType) applyChanges(ids []int64) error {
+	start := time.Now()
+	stat, err := models.RecordingRuleStatistics(rrc.ctx)
+	if err != nil {
+		return errors.WithMessage(err, "failed to exec RecordingRuleStatistics")
+	}
+
+	lst, err := models.RecordingRuleEnabledGetsByIds(rrc.ctx, ids)
+	if err != nil {
+		return errors.WithMessage(err, "failed to exec RecordingRuleEnabledGetsByIds")
+	}
+
+	rrc.Lock()
+	for _, id := range ids {
+		delete(rrc.rules, id)
+	}
+	for i := 0; i < len(lst); i++ {
+		rrc.rules[lst[i].Id] = lst[i]
+	}
+	total := len(rrc.rules)
+	rrc.Unlock()
+
+	rrc.statTotal = stat.Total
+	rrc.statLastUpdated = stat.LastUpdated
+
+	ms := time.Since(start).Milliseconds()
+	dumper.PutSyncRecord("recording_rules", start.Unix(), ms, total, fmt.Sprintf("incremental, changed ids: %v", ids))
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package memsto
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/dumper"
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+
+	"github.com/pkg/errors"
+	"github.com/toolkits/pkg/logger"
+)
+
+// RemediationPolicyCacheType 启用的故障自愈策略，自愈只在 center 中执行，直接从数据库同步
+type RemediationPolicyCacheType struct {
+	statTotal       int64
+	statLastUpdated int64
+	ctx             *ctx.Context
+	stats           *Stats
+	changes         *cacheChanges
+
+	sync.RWMutex
+	policies map[int64]*models.RemediationPolicy // key: policy id
+}
+
+func NewRemediationPolicyCache(ctx *ctx.Context, stats *Stats) *RemediationPolicyCacheType {
+	rpc := &RemediationPolicyCacheType{
+		statTotal:       -1,
+		statLastUpdated: -1,
+		ctx:             ctx,
+		stats:           stats,
+		changes:         watchCacheChanges("remediation_policy"),
+		policies:        make(map[int64]*models.RemediationPolicy),
+	}
+	rpc.SyncRemediationPolicies()
+	return rpc
+}
+
+func (rpc *RemediationPolicyCacheType) StatChanged(total, lastUpdated int64) bool {
+	if rpc.statTotal == total && rpc.statLastUpdated == lastUpdated {
+		return false
+	}
+
+	return true
+}
+
+func (rpc *RemediationPolicyCacheType) Set(m map[int64]*models.RemediationPolicy, total, lastUpdated int64) {
+	rpc.Lock()
+	rpc.policies = m
+	rpc.Unlock()
+
+	// only one goroutine used, so no need lock
+	rpc.statTotal = total
+	rpc.statLastUpdated = lastUpdated
+}
+
+func (rpc *RemediationPolicyCacheType) Get(id int64) *models.RemediationPolicy {
+	rpc.RLock()
+	defer rpc.RUnlock()
+	return rpc.policies[id]
+}
+
+// GetByGroupId 业务组中启用的策略
+func (rpc *RemediationPolicyCacheType) GetByGroupId(groupId int64) []*models.RemediationPolicy {
+	rpc.RLock()
+	defer rpc.RUnlock()
+
+	var lst []*models.RemediationPolicy
+	for _, p := range rpc.policies {
+		if p.GroupId == groupId {
+			lst = append(lst, p)
+		}
+	}
+	return lst
+}
+
+func (rpc *RemediationPolicyCacheType) SyncRemediationPolicies() {
+	err := rpc.syncRemediationPolicies()
+	if err != nil {
+		fmt.Println("failed to sync remediation policies:", err)
+		exit(1)
+	}
+
+	go rpc.loopSyncRemediationPolicies()
+}
+
+func (rpc *RemediationPolicyCacheType) loopSyncRemediationPolicies() {
+	for {
+		if rpc.changes.waitChanged(syncInterval()) {
+			rpc.statTotal = -1
+		}
+
+		if err := rpc.syncRemediationPolicies(); err != nil {
+			logger.Warning("failed to sync remediation policies:", err)
+		}
+	}
+}
+
+func (rpc *RemediationPolicyCacheType) syncRemediationPolicies() error {
+	start := time.Now()
+
+	stat, err := models.RemediationPolicyStatistics(rpc.ctx)
+	if err != nil {
+		dumper.PutSyncRecord("remediation_policies", start.Unix(), -1, -1, "failed to query statistics: "+err.Error())
+		return errors.WithMessage(err, "failed to exec RemediationPolicyStatistics")
+	}
+
+	if !rpc.StatChanged(stat.Total, stat.LastUpdated) {
+		rpc.stats.GaugeCronDuration.WithLabelValues("sync_remediation_policies").Set(0)
+		rpc.stats.GaugeSyncNumber.WithLabelValues("sync_remediation_policies").Set(0)
+		dumper.PutSyncRecord("remediation_policies", start.Unix(), -1, -1, "not changed")
+		return nil
+	}
+
+	lst, err := models.RemediationPolicyGetsEnabled(rpc.ctx)
+	if err != nil {
+		dumper.PutSyncRecord("remediation_policies", start.Unix(), -1, -1, "failed to query records: "+err.Error())
+		return errors.WithMessage(err, "failed to exec RemediationPolicyGetsEnabled")
+	}
+
+	m := make(map[int64]*models.RemediationPolicy)
+	for i := 0; i < len(lst); i++ {
+		m[lst[i].Id] = lst[i]
+	}
+
+	rpc.Set(m, stat.Total, stat.LastUpdated)
+
+	ms := time.Since(start).Milliseconds()
+	rpc.stats.GaugeCronDuration.WithLabelValues("sync_remediation_policies").Set(float64(ms))
+	rpc.stats.GaugeSyncNumber.WithLabelValues("sync_remediation_policies").Set(float64(len(m)))
+	dumper.PutSyncRecord("remediation_policies", start.Unix(), ms, len(m), "success")
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package memsto
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/dumper"
+	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+
+	"github.com/pkg/errors"
+	"github.com/toolkits/pkg/logger"
+)
+
+type RuleGroupCacheType struct {
+	statTotal       int64
+	statLastUpdated int64
+	ctx             *ctx.Context
+	stats           *Stats
+	changes         *cacheChanges
+
+	sync.RWMutex
+	groups         map[int64]*models.RuleGroup // key: rule group id
+	alertRules     map[int64]int64             // key: alert rule id value: rule group id
+	recordingRules map[int64]int64             // key: recording rule id value: rule group id
+}
+
+func NewRuleGroupCache(ctx *ctx.Context, stats *Stats) *RuleGroupCacheType {
+	rgc := &RuleGroupCacheType{
+		statTotal:       -1,
+		statLastUpdated: -1,
+		ctx:             ctx,
+		stats:           stats,
+		changes:         watchCacheChanges("rule_group"),
+		groups:          make(map[int64]*models.RuleGroup),
+		alertRules:      make(map[int64]int64),
+		recordingRules:  make(map[int64]int64),
+	}
+	rgc.SyncRuleGroups()
+	return rgc
+}
+
+func (rgc *RuleGroupCacheType) StatChanged(total, lastUpdated int64) bool {
+	if rgc.statTotal == total && rgc.statLastUpdated == lastUpdated {
+		return false
+	}
+
+	return true
+}
+
+func (rgc *RuleGroupCacheType) Set(m map[int64]*models.RuleGroup, total, lastUpdated int64) {
+	alertRules := make(map[int64]int64)
+	recordingRules := make(map[int64]int64)
+	for _, g := range m {
+		for _, id := range g.MemberIds(models.RuleGroupMemberAlert) {
+			alertRules[id] = g.Id
+		}
+		for _, id := range g.MemberIds(models.RuleGroupMemberRecording) {
+			recordingRules[id] = g.Id
+		}
+	}
+
+	rgc.Lock()
+	rgc.groups = m
+	rgc.alertRules = alertRules
+	rgc.recordingRules = recordingRules
+	rgc.Unlock()
+
+	// only one goroutine used, so no need lock
+	rgc.statTotal = total
+	rgc.statLastUpdated = lastUpdated
+}
+
+func (rgc *RuleGroupCacheType) Get(id int64) *models.RuleGroup {
+	rgc.RLock()
+	defer rgc.RUnlock()
+	return rgc.groups[id]
+}
+
+func (rgc *RuleGroupCacheType) GetGroupIds() []int64 {
+	rgc.RLock()
+	defer rgc.RUnlock()
+
+	list := make([]int64, 0, len(rgc.groups))
+	for id := range rgc.groups {
+		list = append(list, id)
+	}
+
+	return list
+}
+
+// AlertRuleGrouped 告警规则属于启用的规则组时，由规则组调度执行
+func (rgc *RuleGroupCacheType) AlertRuleGrouped(ruleId int64) bool {
+	rgc.RLock()
+	defer rgc.RUnlock()
+	_, has := rgc.alertRules[ruleId]
+	return has
+}
+
+// RecordingRuleGrouped 记录规则属于启用的规则组时，由规则组调度执行
+func (rgc *RuleGroupCacheType) RecordingRuleGrouped(ruleId int64) bool {
+	rgc.RLock()
+	defer rgc.RUnlock()
+	_, has := rgc.recordingRules[ruleId]
+	return has
+}
+
+func (rgc *RuleGroupCacheType) SyncRuleGroups() {
+	err := rgc.syncRuleGroups()
+	if err != nil {
+		fmt.Println("failed to sync rule groups:", err)
+		exit(1)
+	}
+
+	go rgc.loopSyncRuleGroups()
+}
+
+func (rgc *RuleGroupCacheType) loopSyncRuleGroups() {
+	for {
+		// 规则组数量少，收到变更通知时直接全量同步
+		if rgc.changes.waitChanged(syncInterval()) {
+			rgc.statTotal = -1
+		}
+
+		if err := rgc.syncRuleGroups(); err != nil {
+			logger.Warning("failed to sync rule groups:", err)
+		}
+	}
+}
+
+func (rgc *RuleGroupCacheType) syncRuleGroups() error {
+	start := time.Now()
+
+	stat, err := models.RuleGroupStatistics(rgc.ctx)
+	if err != nil {
+		dumper.PutSyncRecord("rule_groups", start.Unix(), -1, -1, "failed to query statistics: "+err.Error())
+		return errors.WithMessage(err, "failed to exec RuleGroupStatistics")
+	}
+
+	if !rgc.StatChanged(stat.Total, stat.LastUpdated) {
+		rgc.stats.GaugeCronDuration.WithLabelValues("sync_rule_groups").Set(0)
+		rgc.stats.GaugeSyncNumber.WithLabelValues("sync_rule_groups").Set(0)
+		dumper.PutSyncRecord("rule_groups", start.Unix(), -1, -1, "not changed")
+		return nil
+	}
+
+	lst, err := models.RuleGroupGetsByCluster(rgc.ctx)
+	if err != nil {
+		dumper.PutSyncRecord("rule_groups", start.Unix(), -1, -1, "failed to query records: "+err.Error())
+		return errors.WithMessage(err, "failed to exec RuleGroupGetsByCluster")
+	}
+
+	m := make(map[int64]*models.RuleGroup)
+	for i := 0; i < len(lst); i++ {
+		m[lst[i].Id] = lst[i]
+	}
+
+	rgc.Set(m, stat.Total, stat.LastUpdated)
+
+	ms := time.Since(start).Milliseconds()
+	rgc.stats.GaugeCronDuration.WithLabelValues("sync_rule_groups").Set(float64(ms))
+	rgc.stats.GaugeSyncNumber.WithLabelValues("sync_rule_groups").Set(float64(len(m)))
+	dumper.PutSyncRecord("rule_groups", start.Unix(), ms, len(m), "success")
+
+	return nil
+}
@@ -13,49 +13,81 @@ import (
 	"github.com/toolkits/pkg/logger"
 )
 
+type userTokenEntry struct {
+	user  *models.User
+	token *models.UserToken
+}
+
 type UserTokenCacheType struct {
-	statTotal int64
-	ctx       *ctx.Context
-	stats     *Stats
+	statTotal       int64
+	statLastUpdated int64
+	userStat        models.Statistics
+	ctx             *ctx.Context
+	stats           *Stats
+	changes         *cacheChanges
 
 	sync.RWMutex
-	tokens         map[string]*models.User
+	// key: token hash
+	tokens         map[string]*userTokenEntry
 	tokensLastUsed map[string]int64
 }
 
 func NewUserTokenCache(ctx *ctx.Context, stats *Stats) *UserTokenCacheType {
 	utc := &UserTokenCacheType{
-		statTotal:      -1,
-		ctx:            ctx,
-		stats:          stats,
-		tokens:         make(map[string]*models.User),
-		tokensLastUsed: make(map[string]int64),
+		statTotal:       -1,
+		statLastUpdated: -1,
+		userStat:        models.Statistics{Total: -1, LastUpdated: -1},
+		ctx:             ctx,
+		stats:           stats,
+		changes:         watchCacheChanges("user_token"),
+		tokens:          make(map[string]*userTokenEntry),
+		tokensLastUsed:  make(map[string]int64),
 	}
 	utc.SyncUserTokens()
 	return utc
 }
 
-func (utc *UserTokenCacheType) StatChanged(total int64) bool {
-	if utc.statTotal == total {
+// StatChanged token 的数量、最大 id 以及用户信息任一变化都需要重新同步
+func (utc *UserTokenCacheType) StatChanged(total, lastUpdated int64, userStat models.Statistics) bool {
+	if utc.statTotal == total && utc.statLastUpdated == lastUpdated && utc.userStat == userStat {
 		return false
 	}
 	return true
 }
 
-func (utc *UserTokenCacheType) Set(tokenUsers map[string]*models.User, total int64) {
+func (utc *UserTokenCacheType) Set(tokens map[string]*userTokenEntry, total, lastUpdated int64, userStat models.Statistics) {
 	utc.Lock()
-	utc.tokens = tokenUsers
+	utc.tokens = tokens
 	utc.Unlock()
 
 	utc.statTotal = total
+	utc.statLastUpdated = lastUpdated
+	utc.userStat = userStat
 }
 
-func (utc *UserTokenCacheType) GetByToken(token string) *models.User {
+// GetByToken 根据明文 token 查找用户，已过期的 token 返回 nil
+func (utc *UserTokenCacheType) GetByToken(token string) (*models.User, *models.UserToken) {
+	hash := models.UserTokenHash(token)
+	now := time.Now().Unix()
+
 	utc.Lock()
 	defer utc.Unlock()
-	utc.tokensLastUsed[token] = time.Now().Unix()
 
-	return utc.tokens[token]
+	entry, has := utc.tokens[hash]
+	if !has || entry.token.Expired(now) {
+		return nil, nil
+	}
+
+	utc.tokensLastUsed[hash] = now
+	return entry.user, entry.token
+}
+
+// Revoke 立即在本实例中使 token 失效，其他实例收到 user_token 的变更通知后重新同步
+func (utc *UserTokenCacheType) Revoke(tokenHash string) {
+	utc.Lock()
+	delete(utc.tokens, tokenHash)
+	delete(utc.tokensLastUsed, tokenHash)
+	utc.Unlock()
 }
 
 func (utc *UserTokenCacheType) SyncUserTokens() {
@@ -80,9 +112,13 @@ func (utc *UserTokenCacheType) loopUpdateUserTokenLastUsedTime() {
 }
 
 func (utc *UserTokenCacheType) loopSyncUserTokens() {
-	duration := time.Duration(9000) * time.Millisecond
 	for {
-		time.Sleep(duration)
+		// 用户信息的变化没有变更通知，这里保持固定的轮询周期
+		if utc.changes.waitChanged(defaultSyncInterval) {
+			// 收到 token 变更通知（如删除），跳过统计信息比较直接全量同步
+			utc.statTotal = -1
+		}
+
 		if err := utc.syncUserTokens(); err != nil {
 			logger.Warning("failed to sync user tokens:", err)
 		}
@@ -94,23 +130,23 @@ func (utc *UserTokenCacheType) updateUserTokenLastUsedTime() error {
 	now := time.Now().Unix()
 
 	utc.Lock()
-	for token, lastUsedTime := range utc.tokensLastUsed {
+	for hash, lastUsedTime := range utc.tokensLastUsed {
 		if lastUsedTime == 0 {
 			continue
 		}
 
 		if now-lastUsedTime > 1800 {
 			// 如果 token 已经 30 分钟没有使用，不再更新数据库
-			delete(utc.tokensLastUsed, token)
+			delete(utc.tokensLastUsed, hash)
 			continue
 		}
 
-		tokenLastUsedMap[token] = lastUsedTime
+		tokenLastUsedMap[hash] = lastUsedTime
 	}
 	utc.Unlock()
 
-	for token, lastUsedTime := range tokenLastUsedMap {
-		err := models.UserTokenUpdateLastUsedTime(utc.ctx, token, lastUsedTime)
+	for hash, lastUsedTime := range tokenLastUsedMap {
+		err := models.UserTokenUpdateLastUsedTime(utc.ctx, hash, lastUsedTime)
 		if err != nil {
 			logger.Warning("failed to update user token last used time:", err)
 			continue
@@ -123,13 +159,19 @@ func (utc *UserTokenCacheType) updateUserTokenLastUsedTime() error {
 func (utc *UserTokenCacheType) syncUserTokens() error {
 	start := time.Now()
 
-	total, err := models.UserTokenTotal(utc.ctx)
+	stat, err := models.UserTokenStatistics(utc.ctx)
 	if err != nil {
 		dumper.PutSyncRecord("user_tokens", start.Unix(), -1, -1, "failed to query statistics: "+err.Error())
 		return errors.WithMessage(err, "failed to exec UserTokenStatistics")
 	}
 
-	if !utc.StatChanged(total) {
+	userStat, err := models.UserStatistics(utc.ctx)
+	if err != nil {
+		dumper.PutSyncRecord("user_tokens", start.Unix(), -1, -1, "failed to query statistics: "+err.Error())
+		return errors.WithMessage(err, "failed to exec UserStatistics")
+	}
+
+	if !utc.StatChanged(stat.Total, stat.LastUpdated, *userStat) {
 		utc.stats.GaugeCronDuration.WithLabelValues("sync_user_tokens").Set(0)
 		utc.stats.GaugeSyncNumber.WithLabelValues("sync_user_tokens").Set(0)
 		dumper.PutSyncRecord("user_tokens", start.Unix(), -1, -1, "not changed")
@@ -153,17 +195,22 @@ func (utc *UserTokenCacheType) syncUserTokens() error {
 		userMap[user.Username] = user
 	}
 
-	tokenUsers := make(map[string]*models.User)
+	tokenUsers := make(map[string]*userTokenEntry)
 	for _, token := range lst {
 		user, ok := userMap[token.Username]
 		if !ok {
 			continue
 		}
 
-		tokenUsers[token.Token] = user
+		if token.TokenHash == "" {
+			// 历史数据未完成摘要迁移
+			continue
+		}
+
+		tokenUsers[token.TokenHash] = &userTokenEntry{user: user, token: token}
 	}
 
-	utc.Set(tokenUsers, total)
+	utc.Set(tokenUsers, stat.Total, stat.LastUpdated, *userStat)
 
 	ms := time.Since(start).Milliseconds()
 	utc.stats.GaugeCronDuration.WithLabelValues("sync_user_tokens").Set(float64(ms))
@@ -0,0 +1,358 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+
+	"gorm.io/gorm"
+)
+
+// AlertAnalyticsQuery 告警统计的公共过滤条件，时间范围左闭右开，GroupIds 为空表示所有业务组
+type AlertAnalyticsQuery struct {
+	GroupIds   []int64 `json:"group_ids"`
+	Severities []int   `json:"severities"`
+	RuleIds    []int64 `json:"rule_ids"`
+	Stime      int64   `json:"stime"`
+	Etime      int64   `json:"etime"`
+}
+
+// filter 业务组、级别、规则过滤条件，alias 为 alert_his_event 表的别名
+func (q *AlertAnalyticsQuery) filter(session *gorm.DB, alias string) *gorm.DB {
+	if len(q.GroupIds) > 0 {
+		session = session.Where(alias+"group_id in ?", q.GroupIds)
+	}
+	if len(q.Severities) > 0 {
+		session = session.Where(alias+"severity in ?", q.Severities)
+	}
+	if len(q.RuleIds) > 0 {
+		session = session.Where(alias+"rule_id in ?", q.RuleIds)
+	}
+	return session
+}
+
+// firingEvents 时间范围内触发的告警，不包括恢复事件
+func (q *AlertAnalyticsQuery) firingEvents(ctx *ctx.Context) *gorm.DB {
+	session := DB(ctx).Model(&AlertHisEvent{}).Where("is_recovered = 0 and trigger_time >= ? and trigger_time < ?", q.Stime, q.Etime)
+	return q.filter(session, "")
+}
+
+type BusiGroupAlertCount struct {
+	GroupId    int64         `json:"group_id"`
+	GroupName  string        `json:"group_name"`
+	Total      int64         `json:"total"`
+	Severities map[int]int64 `json:"severities"` // key: 告警级别
+}
+
+type RuleAlertCount struct {
+	RuleId    int64  `json:"rule_id"`
+	RuleName  string `json:"rule_name"`
+	GroupId   int64  `json:"group_id"`
+	GroupName string `json:"group_name"`
+	Count     int64  `json:"count"`
+}
+
+// MeanDuration 平均耗时，Count 为参与计算的事件数，没有事件时 Seconds 为 0
+type MeanDuration struct {
+	Count   int64   `json:"count"`
+	Seconds float64 `json:"seconds"`
+}
+
+// AlertCountsByBusiGroup 按业务组和告警级别统计告警数量，按总数倒序
+func AlertCountsByBusiGroup(ctx *ctx.Context, q *AlertAnalyticsQuery) ([]*BusiGroupAlertCount, error) {
+	var rows []struct {
+		GroupId   int64
+		GroupName string
+		Severity  int
+		Count     int64
+	}
+
+	err := q.firingEvents(ctx).Select("group_id, group_name, severity, count(*) as count").
+		Group("group_id, group_name, severity").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[int64]*BusiGroupAlertCount)
+	for _, r := range rows {
+		c, has := m[r.GroupId]
+		if !has {
+			c = &BusiGroupAlertCount{GroupId: r.GroupId, GroupName: r.GroupName, Severities: make(map[int]int64)}
+			m[r.GroupId] = c
+		}
+		c.Total += r.Count
+		c.Severities[r.Severity] += r.Count
+	}
+
+	lst := make([]*BusiGroupAlertCount, 0, len(m))
+	for _, c := range m {
+		lst = append(lst, c)
+	}
+
+	sort.Slice(lst, func(i, j int) bool {
+		if lst[i].Total != lst[j].Total {
+			return lst[i].Total > lst[j].Total
+		}
+		return lst[i].GroupId < lst[j].GroupId
+	})
+
+	return lst, nil
+}
+
+// TopNoisyRules 告警次数最多的规则
+func TopNoisyRules(ctx *ctx.Context, q *AlertAnalyticsQuery, limit int) ([]*RuleAlertCount, error) {
+	var lst []*RuleAlertCount
+	err := q.firingEvents(ctx).Select("rule_id, max(rule_name) as rule_name, group_id, max(group_name) as group_name, count(*) as count").
+		Group("rule_id, group_id").Order("count desc, rule_id").Limit(limit).Scan(&lst).Error
+	return lst, err
+}
+
+// AlertMTTR 时间范围内恢复的告警，从首次触发到恢复的平均时间
+func AlertMTTR(ctx *ctx.Context, q *AlertAnalyticsQuery) (*MeanDuration, error) {
+	session := DB(ctx).Model(&AlertHisEvent{}).Where("is_recovered = 1 and recover_time >= ? and recover_time < ?", q.Stime, q.Etime)
+	session = q.filter(session, "")
+
+	return scanMeanDuration(session.Select("count(*) as count, " +
+		"avg(recover_time - case when first_trigger_time > 0 then first_trigger_time else trigger_time end) as seconds"))
+}
+
+// AlertMTTA 告警触发到第一次成功通知到人的平均时间
+// 事件没有认领操作，这里以首次通知成功作为响应时间
+func AlertMTTA(ctx *ctx.Context, q *AlertAnalyticsQuery) (*MeanDuration, error) {
+	return scanMeanDuration(q.firstNotified(ctx).Select("count(*) as count, avg(n.first_at - e.trigger_time) as seconds"))
+}
+
+// firstNotified 时间范围内触发且通知成功过的告警，n.first_at 为第一次通知成功的时间
+func (q *AlertAnalyticsQuery) firstNotified(ctx *ctx.Context) *gorm.DB {
+	first := DB(ctx).Model(&NotificationRecord{}).Select("event_id, min(created_at) as first_at").
+		Where("status = ?", NotiStatusSuccess).Group("event_id")
+
+	session := DB(ctx).Table("alert_his_event e").Joins("join (?) n on n.event_id = e.id", first).
+		Where("e.is_recovered = 0 and e.trigger_time >= ? and e.trigger_time < ?", q.Stime, q.Etime)
+	return q.filter(session, "e.")
+}
+
+func scanMeanDuration(session *gorm.DB) (*MeanDuration, error) {
+	var row struct {
+		Count   int64
+		Seconds sql.NullFloat64
+	}
+
+	if err := session.Scan(&row).Error; err != nil {
+		return nil, err
+	}
+
+	d := &MeanDuration{Count: row.Count}
+	if row.Seconds.Valid {
+		d.Seconds = row.Seconds.Float64
+	}
+	return d, nil
+}
+
+const (
+	AnalyticsGroupByRule      = "rule"
+	AnalyticsGroupByBusiGroup = "busi_group"
+	AnalyticsGroupBySeverity  = "severity"
+	AnalyticsGroupByTime      = "time"
+)
+
+// AlertAnalyticsStat 按某个维度聚合的告警统计
+// Series 为不同告警曲线（hash）的数量，FlappingRatio 为同一曲线重复触发的事件占比
+type AlertAnalyticsStat struct {
+	Key           string           `json:"key"`
+	Name          string           `json:"name"`
+	Events        int64            `json:"events"`
+	Series        int64            `json:"series"`
+	FlappingRatio float64          `json:"flapping_ratio"`
+	MTTA          *MeanDuration    `json:"mtta"`
+	MTTR          *MeanDuration    `json:"mttr"`
+	Notifications map[string]int64 `json:"notifications"` // key: 通知媒介，只统计发送成功的
+}
+
+// analyticsDimension 返回分组字段和名称字段的 sql 表达式，timeCol 为按时间分桶时使用的时间字段
+// 取模运算在 mysql、postgres、sqlite 中都返回整数，用它来计算时间桶
+func analyticsDimension(groupBy string, bucket int64, alias, timeCol string) (string, string, error) {
+	switch groupBy {
+	case AnalyticsGroupByRule:
+		return alias + "rule_id", "max(" + alias + "rule_name)", nil
+	case AnalyticsGroupByBusiGroup:
+		return alias + "group_id", "max(" + alias + "group_name)", nil
+	case AnalyticsGroupBySeverity:
+		return alias + "severity", "''", nil
+	case AnalyticsGroupByTime:
+		if bucket <= 0 {
+			return "", "", fmt.Errorf("bucket must be greater than 0")
+		}
+		col := alias + timeCol
+		return fmt.Sprintf("(%s - %s %% %d)", col, col, bucket), "''", nil
+	default:
+		return "", "", fmt.Errorf("invalid group_by: %s", groupBy)
+	}
+}
+
+// AlertAnalytics 按规则、业务组、级别或时间桶统计告警数量、重复触发比例、MTTA、MTTR 以及各通知媒介的通知次数
+// 按时间分组时结果按时间升序，其他维度按告警数量倒序，limit 大于 0 时只返回前 limit 条
+func AlertAnalytics(ctx *ctx.Context, q *AlertAnalyticsQuery, groupBy string, bucket int64, limit int) ([]*AlertAnalyticsStat, error) {
+	key, name, err := analyticsDimension(groupBy, bucket, "", "trigger_time")
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Dim    string
+		Name   string
+		Events int64
+		Series int64
+	}
+
+	err = q.firingEvents(ctx).Select(fmt.Sprintf("%s as dim, %s as name, count(*) as events, count(distinct hash) as series", key, name)).
+		Group(key).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]*AlertAnalyticsStat, len(rows))
+	for _, r := range rows {
+		stat := &AlertAnalyticsStat{
+			Key:           r.Dim,
+			Name:          r.Name,
+			Events:        r.Events,
+			Series:        r.Series,
+			MTTA:          &MeanDuration{},
+			MTTR:          &MeanDuration{},
+			Notifications: make(map[string]int64),
+		}
+		if r.Events > 0 {
+			stat.FlappingRatio = math.Round(float64(r.Events-r.Series)/float64(r.Events)*1e4) / 1e4
+		}
+		m[r.Dim] = stat
+	}
+
+	// MTTA 按告警触发时间归属
+	ekey, _, _ := analyticsDimension(groupBy, bucket, "e.", "trigger_time")
+	mtta, err := scanMeanDurations(q.firstNotified(ctx).
+		Select(fmt.Sprintf("%s as dim, count(*) as count, avg(n.first_at - e.trigger_time) as seconds", ekey)).Group(ekey))
+	if err != nil {
+		return nil, err
+	}
+
+	// MTTR 按恢复时间归属，时间范围内恢复但在范围外触发的告警，在其他维度下也需要单独建一行
+	rkey, rname, _ := analyticsDimension(groupBy, bucket, "", "recover_time")
+	recovered := q.filter(DB(ctx).Model(&AlertHisEvent{}).Where("is_recovered = 1 and recover_time >= ? and recover_time < ?", q.Stime, q.Etime), "")
+	var mttrRows []struct {
+		Dim     string
+		Name    string
+		Count   int64
+		Seconds sql.NullFloat64
+	}
+	err = recovered.Select(fmt.Sprintf("%s as dim, %s as name, count(*) as count, "+
+		"avg(recover_time - case when first_trigger_time > 0 then first_trigger_time else trigger_time end) as seconds", rkey, rname)).
+		Group(rkey).Scan(&mttrRows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var notifyRows []struct {
+		Dim     string
+		Channel string
+		Count   int64
+	}
+	err = q.filter(DB(ctx).Table("notification_record n").Joins("join alert_his_event e on e.id = n.event_id").
+		Where("n.status = ? and e.is_recovered = 0 and e.trigger_time >= ? and e.trigger_time < ?", NotiStatusSuccess, q.Stime, q.Etime), "e.").
+		Select(fmt.Sprintf("%s as dim, n.channel as channel, count(*) as count", ekey)).Group(ekey + ", n.channel").Scan(&notifyRows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for dim, d := range mtta {
+		if stat, has := m[dim]; has {
+			stat.MTTA = d
+		}
+	}
+
+	for _, r := range mttrRows {
+		stat, has := m[r.Dim]
+		if !has {
+			stat = &AlertAnalyticsStat{Key: r.Dim, Name: r.Name, MTTA: &MeanDuration{}, Notifications: make(map[string]int64)}
+			m[r.Dim] = stat
+		}
+		stat.MTTR = &MeanDuration{Count: r.Count}
+		if r.Seconds.Valid {
+			stat.MTTR.Seconds = r.Seconds.Float64
+		}
+	}
+
+	for _, r := range notifyRows {
+		if stat, has := m[r.Dim]; has {
+			stat.Notifications[r.Channel] += r.Count
+		}
+	}
+
+	lst := make([]*AlertAnalyticsStat, 0, len(m))
+	for _, stat := range m {
+		lst = append(lst, stat)
+	}
+
+	sort.Slice(lst, func(i, j int) bool {
+		ki, _ := strconv.ParseInt(lst[i].Key, 10, 64)
+		kj, _ := strconv.ParseInt(lst[j].Key, 10, 64)
+		if groupBy == AnalyticsGroupByTime {
+			return ki < kj
+		}
+		if lst[i].Events != lst[j].Events {
+			return lst[i].Events > lst[j].Events
+		}
+		return ki < kj
+	})
+
+	if limit > 0 && len(lst) > limit {
+		lst = lst[:limit]
+	}
+
+	return lst, nil
+}
+
+func scanMeanDurations(session *gorm.DB) (map[string]*MeanDuration, error) {
+	var rows []struct {
+		Dim     string
+		Count   int64
+		Seconds sql.NullFloat64
+	}
+
+	if err := session.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]*MeanDuration, len(rows))
+	for _, r := range rows {
+		d := &MeanDuration{Count: r.Count}
+		if r.Seconds.Valid {
+			d.Seconds = r.Seconds.Float64
+		}
+		m[r.Dim] = d
+	}
+	return m, nil
+}
+
+type ChannelNotifyCount struct {
+	Channel string `json:"channel"`
+	Total   int64  `json:"total"`
+	Success int64  `json:"success"`
+	Failed  int64  `json:"failed"`
+}
+
+// NotifyCountsByChannel 时间范围内各通知媒介的发送次数，包括告警和恢复通知
+func NotifyCountsByChannel(ctx *ctx.Context, q *AlertAnalyticsQuery) ([]*ChannelNotifyCount, error) {
+	var lst []*ChannelNotifyCount
+	err := q.filter(DB(ctx).Table("notification_record n").Joins("join alert_his_event e on e.id = n.event_id").
+		Where("n.created_at >= ? and n.created_at < ?", q.Stime, q.Etime), "e.").
+		Select("n.channel as channel, count(*) as total, "+
+			"sum(case when n.status = ? then 1 else 0 end) as success, "+
+			"sum(case when n.status = ? then 0 else 1 end) as failed", NotiStatusSuccess, NotiStatusSuccess).
+		Group("n.channel").Order("total desc").Scan(&lst).Error
+	return lst, err
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestAlertAnalytics(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AutoMigrate(&AlertHisEvent{}, &NotificationRecord{}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := ctx.NewContext(context.Background(), db, true)
+
+	events := []*AlertHisEvent{
+		// 规则 1 同一曲线触发两次，第一次 60 秒后恢复
+		{Id: 1, RuleId: 1, RuleName: "cpu", GroupId: 1, GroupName: "infra", Severity: 1, Hash: "a", TriggerTime: 1000},
+		{Id: 2, RuleId: 1, RuleName: "cpu", GroupId: 1, GroupName: "infra", Severity: 1, Hash: "a", IsRecovered: 1, TriggerTime: 1000, FirstTriggerTime: 1000, RecoverTime: 1060},
+		{Id: 3, RuleId: 1, RuleName: "cpu", GroupId: 1, GroupName: "infra", Severity: 1, Hash: "a", TriggerTime: 4000},
+		{Id: 4, RuleId: 2, RuleName: "disk", GroupId: 2, GroupName: "db", Severity: 2, Hash: "b", TriggerTime: 4100},
+	}
+	for _, e := range events {
+		if err := db.Create(e).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records := []*NotificationRecord{
+		{EventId: 1, Channel: "email", Status: NotiStatusSuccess, CreatedAt: 1030},
+		{EventId: 1, Channel: "dingtalk", Status: NotiStatusSuccess, CreatedAt: 1010},
+		{EventId: 3, Channel: "email", Status: 2, CreatedAt: 4005},
+		{EventId: 4, Channel: "email", Status: NotiStatusSuccess, CreatedAt: 4120},
+	}
+	for _, r := range records {
+		if err := db.Create(r).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	q := &AlertAnalyticsQuery{Stime: 0, Etime: 10000}
+
+	stats, err := AlertAnalytics(c, q, AnalyticsGroupByRule, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stats) != 2 {
+		t.Fatalf("expected 2 rules, got %d", len(stats))
+	}
+
+	cpu := stats[0]
+	if cpu.Key != "1" || cpu.Name != "cpu" || cpu.Events != 2 || cpu.Series != 1 || cpu.FlappingRatio != 0.5 {
+		t.Errorf("unexpected stat of rule 1: %+v", cpu)
+	}
+
+	if cpu.MTTA.Count != 1 || cpu.MTTA.Seconds != 10 {
+		t.Errorf("unexpected mtta of rule 1: %+v", cpu.MTTA)
+	}
+
+	if cpu.MTTR.Count != 1 || cpu.MTTR.Seconds != 60 {
+		t.Errorf("unexpected mttr of rule 1: %+v", cpu.MTTR)
+	}
+
+	if cpu.Notifications["email"] != 1 || cpu.Notifications["dingtalk"] != 1 {
+		t.Errorf("unexpected notifications of rule 1: %+v", cpu.Notifications)
+	}
+
+	stats, err = AlertAnalytics(c, q, AnalyticsGroupByTime, 3600, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stats) != 2 || stats[0].Key != "0" || stats[0].Events != 1 || stats[1].Key != "3600" || stats[1].Events != 2 {
+		t.Errorf("unexpected time buckets: %+v %+v", stats[0], stats[1])
+	}
+
+	if _, err := AlertAnalytics(c, q, "ident", 0, 0); err == nil {
+		t.Errorf("expected error for invalid group_by")
+	}
+
+	channels, err := NotifyCountsByChannel(c, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(channels) != 2 || channels[0].Channel != "email" || channels[0].Total != 3 || channels[0].Failed != 1 {
+		t.Errorf("unexpected channel counts: %+v", channels)
+	}
+}
@@ -84,6 +84,11 @@ type AlertCurEvent struct {
 	NotifyVersion int                `json:"notify_version"  gorm:"-"` // 0: old, 1: new
 	NotifyRules   []*EventNotifyRule `json:"notify_rules" gorm:"-"`
 	RecoverTime   int64              `json:"recover_time" gorm:"-"`
+
+	// 告警曲线图，由告警引擎在发送通知前渲染，不落库
+	ChartUrl       string            `json:"chart_url,omitempty" gorm:"-"`        // 图片的访问地址，可在钉钉、Slack 等 markdown 模板中引用
+	ChartImageKeys map[string]string `json:"chart_image_keys,omitempty" gorm:"-"` // 上传到 IM 后得到的图片 key，如 feishu、lark
+	ChartImage     []byte            `json:"-" gorm:"-"`                          // png 原始内容，用于邮件内嵌
 }
 
 type EventNotifyRule struct {
@@ -1059,6 +1064,15 @@ func (e *AlertCurEvent) DeepCopy() *AlertCurEvent {
 		copy(eventCopy.NotifyRuleIds, e.NotifyRuleIds)
 	}
 
+	if e.ChartImageKeys != nil {
+		eventCopy.ChartImageKeys = make(map[string]string, len(e.ChartImageKeys))
+		for k, v := range e.ChartImageKeys {
+			eventCopy.ChartImageKeys[k] = v
+		}
+	}
+
+	// ChartImage 渲染后只读，浅拷贝共享即可
+
 	eventCopy.RuleConfigJson = e.RuleConfigJson
 	eventCopy.ExtraConfig = e.ExtraConfig
 
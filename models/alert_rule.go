@@ -35,6 +35,9 @@ const (
 
 	CLICKHOUSE   = "ck"
 	VICTORIALOGS = "victorialogs"
+
+	// MIXED 跨数据源告警规则，每个查询指定各自的 cate 与数据源
+	MIXED = "mixed"
 )
 
 const (
@@ -246,6 +249,15 @@ type Join struct {
 	On       []string `json:"on"`
 }
 
+// MixedQuery 跨数据源规则中单个查询的公共字段，其余字段交给 cate 对应的数据源插件解析
+type MixedQuery struct {
+	Ref          string `json:"ref"`
+	Cate         string `json:"cate"`
+	DatasourceId int64  `json:"datasource_id"`
+	PromQl       string `json:"prom_ql"` // cate 为 prometheus 时使用
+	Unit         string `json:"unit"`
+}
+
 var DataSourceQueryAll = DatasourceQuery{
 	MatchType: 2,
 	Op:        "in",
@@ -526,6 +538,12 @@ func (ar *AlertRule) Verify() error {
 		return err
 	}
 
+	if ar.IsMixedRule() {
+		if err := ar.validateMixedQueries(); err != nil {
+			return err
+		}
+	}
+
 	if ar.NotifyVersion == 0 {
 		// 如果是旧版本，则清空 NotifyRuleIds
 		ar.NotifyRuleIds = []int64{}
@@ -544,6 +562,76 @@ func (ar *AlertRule) Verify() error {
 	return nil
 }
 
+// mixedQueryCates 跨数据源规则中单个查询允许使用的数据源类型
+var mixedQueryCates = map[string]struct{}{
+	PROMETHEUS:    {},
+	ELASTICSEARCH: {},
+	OPENSEARCH:    {},
+	TDENGINE:      {},
+	MYSQL:         {},
+	POSTGRESQL:    {},
+	DORIS:         {},
+	CLICKHOUSE:    {},
+	VICTORIALOGS:  {},
+}
+
+func (ar *AlertRule) validateMixedQueries() error {
+	var ruleQuery RuleQuery
+	if err := json.Unmarshal([]byte(ar.RuleConfig), &ruleQuery); err != nil {
+		return fmt.Errorf("rule_config invalid: %v", err)
+	}
+
+	if len(ruleQuery.Queries) == 0 {
+		return errors.New("queries is blank")
+	}
+
+	refs := make(map[string]struct{}, len(ruleQuery.Queries))
+	for i := range ruleQuery.Queries {
+		query, err := ParseMixedQuery(ruleQuery.Queries[i])
+		if err != nil {
+			return err
+		}
+
+		if query.Ref == "" {
+			return fmt.Errorf("query[%d] ref is blank", i)
+		}
+
+		if _, exists := refs[query.Ref]; exists {
+			return fmt.Errorf("query ref %s duplicated", query.Ref)
+		}
+		refs[query.Ref] = struct{}{}
+
+		if _, ok := mixedQueryCates[query.Cate]; !ok {
+			return fmt.Errorf("query %s cate(%s) invalid", query.Ref, query.Cate)
+		}
+
+		if query.DatasourceId <= 0 {
+			return fmt.Errorf("query %s datasource_id is blank", query.Ref)
+		}
+
+		if query.Cate == PROMETHEUS && strings.TrimSpace(query.PromQl) == "" {
+			return fmt.Errorf("query %s prom_ql is blank", query.Ref)
+		}
+	}
+
+	return nil
+}
+
+// ParseMixedQuery 解析跨数据源规则中单个查询的公共字段
+func ParseMixedQuery(query interface{}) (MixedQuery, error) {
+	var mq MixedQuery
+	bs, err := json.Marshal(query)
+	if err != nil {
+		return mq, err
+	}
+
+	if err := json.Unmarshal(bs, &mq); err != nil {
+		return mq, err
+	}
+
+	return mq, nil
+}
+
 func (ar *AlertRule) validateCronPattern() error {
 	if ar.CronPattern == "" {
 		return nil
@@ -1105,6 +1193,31 @@ func AlertRuleGetsAll(ctx *ctx.Context) ([]*AlertRule, error) {
 	return lst, nil
 }
 
+// AlertRuleEnabledGetsByIds 缓存增量更新时使用，只返回启用的规则
+func AlertRuleEnabledGetsByIds(ctx *ctx.Context, ids []int64) ([]*AlertRule, error) {
+	if !ctx.IsCenter {
+		lst, err := poster.GetByUrls[[]*AlertRule](ctx, "/v1/n9e/alert-rules?disabled=0&ids="+JoinIds(ids))
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < len(lst); i++ {
+			lst[i].FE2DB()
+		}
+		return lst, err
+	}
+
+	var lst []*AlertRule
+	err := DB(ctx).Where("id in ? and disabled = ?", ids, 0).Find(&lst).Error
+	if err != nil {
+		return lst, err
+	}
+
+	for i := 0; i < len(lst); i++ {
+		lst[i].DB2FE()
+	}
+	return lst, nil
+}
+
 func AlertRulesGetsBy(ctx *ctx.Context, prods []string, query, algorithm, cluster string,
 	cates []string, disabled int) ([]*AlertRule, error) {
 	session := DB(ctx)
@@ -1208,6 +1321,10 @@ func (ar *AlertRule) IsTdengineRule() bool {
 	return ar.Cate == TDENGINE
 }
 
+func (ar *AlertRule) IsMixedRule() bool {
+	return ar.Cate == MIXED
+}
+
 func (ar *AlertRule) IsHostRule() bool {
 	return ar.Prod == HOST
 }
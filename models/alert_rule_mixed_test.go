@@ -0,0 +1,81 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateMixedQueries(t *testing.T) {
+	tests := []struct {
+		name       string
+		ruleConfig string
+		wantErr    string
+	}{
+		{
+			name:       "valid",
+			ruleConfig: `{"queries":[{"ref":"A","cate":"prometheus","datasource_id":1,"prom_ql":"up"},{"ref":"B","cate":"elasticsearch","datasource_id":2}]}`,
+		},
+		{
+			name:       "invalid rule config",
+			ruleConfig: `{"queries":`,
+			wantErr:    "rule_config invalid",
+		},
+		{
+			name:       "no queries",
+			ruleConfig: `{"queries":[]}`,
+			wantErr:    "queries is blank",
+		},
+		{
+			name:       "blank ref",
+			ruleConfig: `{"queries":[{"cate":"prometheus","datasource_id":1,"prom_ql":"up"}]}`,
+			wantErr:    "query[0] ref is blank",
+		},
+		{
+			name:       "duplicated ref",
+			ruleConfig: `{"queries":[{"ref":"A","cate":"prometheus","datasource_id":1,"prom_ql":"up"},{"ref":"A","cate":"mysql","datasource_id":2}]}`,
+			wantErr:    "query ref A duplicated",
+		},
+		{
+			name:       "missing cate",
+			ruleConfig: `{"queries":[{"ref":"A","datasource_id":1}]}`,
+			wantErr:    "query A cate() invalid",
+		},
+		{
+			name:       "nested mixed cate",
+			ruleConfig: `{"queries":[{"ref":"A","cate":"mixed","datasource_id":1}]}`,
+			wantErr:    "query A cate(mixed) invalid",
+		},
+		{
+			name:       "unknown cate",
+			ruleConfig: `{"queries":[{"ref":"A","cate":"influxdb","datasource_id":1}]}`,
+			wantErr:    "query A cate(influxdb) invalid",
+		},
+		{
+			name:       "missing datasource",
+			ruleConfig: `{"queries":[{"ref":"A","cate":"mysql"}]}`,
+			wantErr:    "query A datasource_id is blank",
+		},
+		{
+			name:       "blank promql",
+			ruleConfig: `{"queries":[{"ref":"A","cate":"prometheus","datasource_id":1,"prom_ql":" "}]}`,
+			wantErr:    "query A prom_ql is blank",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ar := &AlertRule{Cate: MIXED, RuleConfig: tt.ruleConfig}
+			err := ar.validateMixedQueries()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expect error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
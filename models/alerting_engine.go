@@ -142,7 +142,8 @@ func AlertingEngineHeartbeatWithCluster(ctx *ctx.Context, instance, cluster stri
 			EngineCluster: cluster,
 			DatasourceId:  datasourceId,
 		}
-		err := poster.PostByUrls(ctx, "/v1/n9e/server-heartbeat", info)
+		key := fmt.Sprintf("%s/%s/%d", instance, cluster, datasourceId)
+		_, err := poster.PostByUrlsOrSpool(ctx, "/v1/n9e/server-heartbeat", key, info)
 		return err
 	}
 
@@ -0,0 +1,185 @@
+package models
+
+import (
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+
+	"gorm.io/gorm"
+)
+
+// 审计日志中的资源类型
+const (
+	AuditResourceAlertRule         = "alert_rule"
+	AuditResourceRecordingRule     = "recording_rule"
+	AuditResourceAlertMute         = "alert_mute"
+	AuditResourceAlertSubscribe    = "alert_subscribe"
+	AuditResourceNotifyRule        = "notify_rule"
+	AuditResourceNotifyChannel     = "notify_channel"
+	AuditResourceMessageTemplate   = "message_template"
+	AuditResourceBoard             = "board"
+	AuditResourceDatasource        = "datasource"
+	AuditResourceUser              = "user"
+	AuditResourceUserGroup         = "user_group"
+	AuditResourceBusiGroup         = "busi_group"
+	AuditResourceRole              = "role"
+	AuditResourceEventPipeline     = "event_pipeline"
+	AuditResourceSlo               = "slo"
+	AuditResourceReport            = "report"
+	AuditResourceRuleGroup         = "rule_group"
+	AuditResourceRemediationPolicy = "remediation_policy"
+	AuditResourceRemediationRun    = "remediation_run"
+	AuditResourceUserToken         = "user_token"
+	AuditResourceMfaPolicy         = "mfa_policy"
+	AuditResourceTarget            = "target"
+	AuditResourceBuiltinMetric     = "builtin_metric"
+	AuditResourceBuiltinComponent  = "builtin_component"
+	AuditResourceBuiltinPayload    = "builtin_payload"
+	AuditResourceDashAnnotation    = "dashboard_annotation"
+	AuditResourceChartShare        = "chart_share"
+	AuditResourceAlertEvent        = "alert_event"
+	AuditResourceTaskTpl           = "task_tpl"
+	AuditResourceTask              = "task"
+	AuditResourceNotifyTpl         = "notify_tpl"
+	AuditResourceNotifyDeadLetter  = "notify_dead_letter"
+	AuditResourceSystemConfig      = "system_config"
+	AuditResourceEsIndexPattern    = "es_index_pattern"
+	AuditResourceEmbeddedProduct   = "embedded_product"
+	AuditResourceUserVariable      = "user_variable"
+	AuditResourceSourceToken       = "source_token"
+	AuditResourcePipelineExecution = "event_pipeline_execution"
+	AuditResourceView              = "view"
+)
+
+// 审计日志中的操作类型
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+)
+
+const (
+	AuditStatusSuccess = "success"
+	AuditStatusFailed  = "failed"
+)
+
+// AuditLog 配置变更审计日志，只追加不修改
+type AuditLog struct {
+	Id           int64  `json:"id" gorm:"primaryKey;type:bigint;autoIncrement"`
+	Username     string `json:"username" gorm:"type:varchar(64);not null;default:'';index:idx_audit_username"`
+	SourceIp     string `json:"source_ip" gorm:"type:varchar(64);not null;default:''"`
+	ResourceType string `json:"resource_type" gorm:"type:varchar(64);not null;default:'';index:idx_audit_resource_time,priority:1"`
+	ResourceIds  string `json:"resource_ids" gorm:"type:varchar(1024);not null;default:''"`
+	Action       string `json:"action" gorm:"type:varchar(32);not null;default:''"`
+	Method       string `json:"method" gorm:"type:varchar(16);not null;default:''"`
+	Path         string `json:"path" gorm:"type:varchar(255);not null;default:''"`
+	Status       string `json:"status" gorm:"type:varchar(16);not null;default:''"`
+	Message      string `json:"message" gorm:"type:varchar(1024);not null;default:''"`
+	Before       string `json:"before,omitempty" gorm:"type:mediumtext"`
+	After        string `json:"after,omitempty" gorm:"type:mediumtext"`
+	Diff         string `json:"diff,omitempty" gorm:"type:mediumtext"`
+	CreatedAt    int64  `json:"created_at" gorm:"type:bigint;not null;index:idx_audit_created_at;index:idx_audit_resource_time,priority:2"`
+}
+
+func (a *AuditLog) TableName() string {
+	return "audit_log"
+}
+
+func (a *AuditLog) Add(ctx *ctx.Context) error {
+	return Insert(ctx, a)
+}
+
+type AuditLogQuery struct {
+	Username     string
+	ResourceType string
+	ResourceId   string
+	Action       string
+	Status       string
+	Stime        int64
+	Etime        int64
+	Query        string
+}
+
+func auditLogSession(ctx *ctx.Context, q AuditLogQuery) *gorm.DB {
+	session := DB(ctx).Model(&AuditLog{})
+
+	if q.Username != "" {
+		session = session.Where("username = ?", q.Username)
+	}
+
+	if q.ResourceType != "" {
+		session = session.Where("resource_type = ?", q.ResourceType)
+	}
+
+	if q.ResourceId != "" {
+		// resource_ids 以逗号分隔存储，前后补逗号做精确匹配
+		session = session.Where("resource_ids = ? OR resource_ids LIKE ? OR resource_ids LIKE ? OR resource_ids LIKE ?",
+			q.ResourceId, q.ResourceId+",%", "%,"+q.ResourceId, "%,"+q.ResourceId+",%")
+	}
+
+	if q.Action != "" {
+		session = session.Where("action = ?", q.Action)
+	}
+
+	if q.Status != "" {
+		session = session.Where("status = ?", q.Status)
+	}
+
+	if q.Stime > 0 {
+		session = session.Where("created_at >= ?", q.Stime)
+	}
+
+	if q.Etime > 0 {
+		session = session.Where("created_at <= ?", q.Etime)
+	}
+
+	if q.Query != "" {
+		session = session.Where("path LIKE ? OR message LIKE ?", "%"+q.Query+"%", "%"+q.Query+"%")
+	}
+
+	return session
+}
+
+func AuditLogTotal(ctx *ctx.Context, q AuditLogQuery) (int64, error) {
+	return Count(auditLogSession(ctx, q))
+}
+
+// AuditLogGets 列表接口不返回 before/after/diff 大字段，详情通过 AuditLogGetById 获取
+func AuditLogGets(ctx *ctx.Context, q AuditLogQuery, limit, offset int) ([]*AuditLog, error) {
+	var lst []*AuditLog
+	err := auditLogSession(ctx, q).
+		Select("id", "username", "source_ip", "resource_type", "resource_ids", "action", "method", "path", "status", "message", "created_at").
+		Order("id desc").Limit(limit).Offset(offset).Find(&lst).Error
+	return lst, err
+}
+
+func AuditLogGetById(ctx *ctx.Context, id int64) (*AuditLog, error) {
+	var lst []*AuditLog
+	err := DB(ctx).Where("id = ?", id).Find(&lst).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if len(lst) == 0 {
+		return nil, nil
+	}
+
+	return lst[0], nil
+}
+
+// DeleteAuditLogsInBatches 分批删除过期审计日志，返回本次删除的数量
+func DeleteAuditLogsInBatches(ctx *ctx.Context, beforeTime int64, limit int) (int64, error) {
+	var ids []int64
+	err := DB(ctx).Model(&AuditLog{}).
+		Where("created_at < ?", beforeTime).
+		Limit(limit).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return 0, err
+	}
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	result := DB(ctx).Where("id IN ?", ids).Delete(&AuditLog{})
+	return result.RowsAffected, result.Error
+}
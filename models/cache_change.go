@@ -0,0 +1,106 @@
+package models
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const (
+	CacheChangeUpsert = "upsert"
+	CacheChangeDelete = "delete"
+	CacheChangeResync = "resync" // 通知订阅方全量同步，比如边缘机房重新连上中心端
+)
+
+// CacheChange 配置表的变更通知，Ids 为空表示无法确定具体对象，订阅方需要全量同步这张表
+type CacheChange struct {
+	Table string  `json:"table"`
+	Op    string  `json:"op"`
+	Ids   []int64 `json:"ids"`
+}
+
+// RegisterCacheChangeCallbacks 在 create/update/delete 执行成功后发布变更通知，只处理 tables 中的表
+// 通过 Where 条件批量更新或删除时拿不到 id，发布的通知不带 Ids
+func RegisterCacheChangeCallbacks(db *gorm.DB, tables []string, publish func(*CacheChange)) error {
+	watched := make(map[string]struct{}, len(tables))
+	for _, t := range tables {
+		watched[t] = struct{}{}
+	}
+
+	callback := func(op string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			if tx.Error != nil || tx.RowsAffected == 0 || tx.Statement.Schema == nil {
+				return
+			}
+
+			if _, has := watched[tx.Statement.Table]; !has {
+				return
+			}
+
+			publish(&CacheChange{
+				Table: tx.Statement.Table,
+				Op:    op,
+				Ids:   statementIds(tx),
+			})
+		}
+	}
+
+	cb := db.Callback()
+	if err := cb.Create().After("gorm:commit_or_rollback_transaction").Register("n9e:cache_change_create", callback(CacheChangeUpsert)); err != nil {
+		return err
+	}
+
+	if err := cb.Update().After("gorm:commit_or_rollback_transaction").Register("n9e:cache_change_update", callback(CacheChangeUpsert)); err != nil {
+		return err
+	}
+
+	return cb.Delete().After("gorm:commit_or_rollback_transaction").Register("n9e:cache_change_delete", callback(CacheChangeDelete))
+}
+
+// statementIds 从 Model 或 Dest 中取主键，零值忽略
+func statementIds(tx *gorm.DB) []int64 {
+	field := tx.Statement.Schema.PrioritizedPrimaryField
+	if field == nil {
+		return nil
+	}
+
+	var ids []int64
+	collect := func(rv reflect.Value) {
+		v, zero := field.ValueOf(tx.Statement.Context, rv)
+		if zero {
+			return
+		}
+
+		switch id := v.(type) {
+		case int64:
+			ids = append(ids, id)
+		case int:
+			ids = append(ids, int64(id))
+		}
+	}
+
+	rv := reflect.Indirect(tx.Statement.ReflectValue)
+	switch rv.Kind() {
+	case reflect.Struct:
+		collect(rv)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			item := reflect.Indirect(rv.Index(i))
+			if item.Kind() == reflect.Struct {
+				collect(item)
+			}
+		}
+	}
+
+	return ids
+}
+
+func JoinIds(ids []int64) string {
+	arr := make([]string, 0, len(ids))
+	for _, id := range ids {
+		arr = append(arr, strconv.FormatInt(id, 10))
+	}
+	return strings.Join(arr, ",")
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestCacheChangeCallbacks(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AutoMigrate(&UserMfa{}, &AuditLog{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var changes []*CacheChange
+	err = RegisterCacheChangeCallbacks(db, []string{"user_mfa"}, func(c *CacheChange) {
+		changes = append(changes, c)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := &UserMfa{UserId: 1}
+	db.Create(v)
+	db.Model(v).Update("enabled", true)
+	db.Where("user_id = ?", 1).Delete(&UserMfa{})
+	db.Create(&AuditLog{Username: "root"})
+
+	if len(changes) != 3 {
+		t.Fatalf("expected 3 changes, got %d", len(changes))
+	}
+
+	if changes[0].Op != CacheChangeUpsert || len(changes[0].Ids) != 1 || changes[0].Ids[0] != v.Id {
+		t.Errorf("unexpected create change: %+v", changes[0])
+	}
+
+	if changes[1].Op != CacheChangeUpsert || len(changes[1].Ids) != 1 {
+		t.Errorf("unexpected update change: %+v", changes[1])
+	}
+
+	if changes[2].Op != CacheChangeDelete || len(changes[2].Ids) != 0 {
+		t.Errorf("delete by condition should not carry ids: %+v", changes[2])
+	}
+}
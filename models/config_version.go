@@ -0,0 +1,116 @@
+package models
+
+import (
+	"time"
+
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+)
+
+const DefaultMaxConfigVersions = 20
+
+// 并发保存时版本号可能冲突，冲突后重新计算版本号的次数
+const configVersionAddRetries = 3
+
+// ConfigVersion 配置对象的历史版本快照，resource_type 取值与审计日志的资源类型一致
+type ConfigVersion struct {
+	Id           int64  `json:"id" gorm:"primaryKey;type:bigint;autoIncrement"`
+	ResourceType string `json:"resource_type" gorm:"type:varchar(64);not null;default:'';uniqueIndex:uk_config_version,priority:1"`
+	ResourceId   int64  `json:"resource_id" gorm:"type:bigint;not null;default:0;uniqueIndex:uk_config_version,priority:2"`
+	Version      int64  `json:"version" gorm:"type:bigint;not null;default:0;uniqueIndex:uk_config_version,priority:3"`
+	Content      string `json:"content,omitempty" gorm:"type:mediumtext"`
+	Note         string `json:"note" gorm:"type:varchar(255);not null;default:''"`
+	CreateAt     int64  `json:"create_at" gorm:"type:bigint;not null;default:0"`
+	CreateBy     string `json:"create_by" gorm:"type:varchar(64);not null;default:''"`
+}
+
+func (v *ConfigVersion) TableName() string {
+	return "config_version"
+}
+
+func ConfigVersionLatest(ctx *ctx.Context, resourceType string, resourceId int64) (*ConfigVersion, error) {
+	var lst []*ConfigVersion
+	err := DB(ctx).Where("resource_type = ? and resource_id = ?", resourceType, resourceId).
+		Order("version desc").Limit(1).Find(&lst).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if len(lst) == 0 {
+		return nil, nil
+	}
+
+	return lst[0], nil
+}
+
+// ConfigVersionAdd 保存一个新版本，内容与最新版本相同时跳过；超出 maxVersions 的旧版本会被删除
+// 同一资源的版本号有唯一索引，并发保存冲突时按最新版本重新计算版本号
+func ConfigVersionAdd(ctx *ctx.Context, resourceType string, resourceId int64, content, note, username string, maxVersions int) error {
+	var version int64
+	for i := 0; ; i++ {
+		latest, err := ConfigVersionLatest(ctx, resourceType, resourceId)
+		if err != nil {
+			return err
+		}
+
+		version = 1
+		if latest != nil {
+			if latest.Content == content {
+				return nil
+			}
+			version = latest.Version + 1
+		}
+
+		obj := &ConfigVersion{
+			ResourceType: resourceType,
+			ResourceId:   resourceId,
+			Version:      version,
+			Content:      content,
+			Note:         note,
+			CreateAt:     time.Now().Unix(),
+			CreateBy:     username,
+		}
+
+		err = Insert(ctx, obj)
+		if err == nil {
+			break
+		}
+
+		// 版本号已被其他请求占用时重试，其他错误直接返回
+		taken, e := ConfigVersionGet(ctx, resourceType, resourceId, version)
+		if e != nil || taken == nil || i+1 >= configVersionAddRetries {
+			return err
+		}
+	}
+
+	if maxVersions <= 0 {
+		maxVersions = DefaultMaxConfigVersions
+	}
+
+	return DB(ctx).Where("resource_type = ? and resource_id = ? and version <= ?", resourceType, resourceId, version-int64(maxVersions)).
+		Delete(&ConfigVersion{}).Error
+}
+
+// ConfigVersionGets 版本列表，不返回 content
+func ConfigVersionGets(ctx *ctx.Context, resourceType string, resourceId int64) ([]*ConfigVersion, error) {
+	var lst []*ConfigVersion
+	err := DB(ctx).Model(&ConfigVersion{}).
+		Select("id", "resource_type", "resource_id", "version", "note", "create_at", "create_by").
+		Where("resource_type = ? and resource_id = ?", resourceType, resourceId).
+		Order("version desc").Find(&lst).Error
+	return lst, err
+}
+
+func ConfigVersionGet(ctx *ctx.Context, resourceType string, resourceId, version int64) (*ConfigVersion, error) {
+	var lst []*ConfigVersion
+	err := DB(ctx).Where("resource_type = ? and resource_id = ? and version = ?", resourceType, resourceId, version).
+		Find(&lst).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if len(lst) == 0 {
+		return nil, nil
+	}
+
+	return lst[0], nil
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestConfigVersionAdd(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:TestConfigVersionAdd?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AutoMigrate(&ConfigVersion{}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := ctx.NewContext(context.Background(), db, true)
+
+	for _, content := range []string{"v1", "v1", "v2", "v3", "v4"} {
+		if err := ConfigVersionAdd(c, AuditResourceAlertRule, 1, content, "", "root", 3); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 内容相同的版本跳过，超出上限的旧版本被删除
+	lst, err := ConfigVersionGets(c, AuditResourceAlertRule, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lst) != 3 || lst[0].Version != 4 || lst[2].Version != 2 {
+		t.Fatalf("unexpected versions: %+v", lst)
+	}
+
+	// 同一资源的版本号不能重复
+	if err := Insert(c, &ConfigVersion{ResourceType: AuditResourceAlertRule, ResourceId: 1, Version: 4, Content: "dup"}); err == nil {
+		t.Fatal("expected duplicate version to be rejected")
+	}
+
+	if err := ConfigVersionAdd(c, AuditResourceBoard, 1, "v1", "", "root", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := ConfigVersionGet(c, AuditResourceBoard, 1, 1)
+	if err != nil || v == nil || v.Content != "v1" {
+		t.Fatalf("unexpected board version: %+v %v", v, err)
+	}
+}
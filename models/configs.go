@@ -48,6 +48,8 @@ const (
 	RSA_PASSWORD             = "rsa_password"
 	JWT_SIGNING_KEY          = "jwt_signing_key"
 	PHONE_ENCRYPTION_ENABLED = "phone_encryption_enabled" // 手机号加密开关
+	NOTIFY_DEAD_LETTER_KEY   = "notify_dead_letter_key"   // 通知死信加密密钥
+	USER_MFA_KEY             = "user_mfa_key"             // MFA 密钥加密密钥
 )
 
 // 手机号加密配置缓存
@@ -523,3 +525,54 @@ func ConfigCvalStatistics(context *ctx.Context) (*Statistics, error) {
 	}
 	return stats[0], nil
 }
+
+// generatedKey 加密保存敏感字段使用的密钥，首次使用时随机生成并保存在 configs 表中
+type generatedKey struct {
+	sync.Mutex
+	ckey string
+	val  string
+}
+
+func (k *generatedKey) get(ctx *ctx.Context) (string, error) {
+	k.Lock()
+	defer k.Unlock()
+
+	if k.val != "" {
+		return k.val, nil
+	}
+
+	val, err := firstConfigVal(ctx, k.ckey)
+	if err != nil {
+		return "", err
+	}
+
+	if val == "" {
+		now := time.Now().Unix()
+		content := fmt.Sprintf("%s%d%d%s", runner.Hostname, os.Getpid(), time.Now().UnixNano(), str.RandLetters(6))
+		err = DB(ctx).Create(&Configs{Ckey: k.ckey, Cval: str.MD5(content), CreateBy: "system", UpdateBy: "system", CreateAt: now, UpdateAt: now}).Error
+		if err != nil {
+			return "", err
+		}
+
+		// 多个实例同时生成时都以最先写入的为准
+		if val, err = firstConfigVal(ctx, k.ckey); err != nil {
+			return "", err
+		}
+
+		if val == "" {
+			return "", fmt.Errorf("%s not found", k.ckey)
+		}
+	}
+
+	k.val = val
+	return val, nil
+}
+
+func firstConfigVal(ctx *ctx.Context, ckey string) (string, error) {
+	var lst []string
+	err := DB(ctx).Model(&Configs{}).Where("ckey = ? and external = ?", ckey, 0).Order("id").Limit(1).Pluck("cval", &lst).Error
+	if err != nil || len(lst) == 0 {
+		return "", err
+	}
+	return lst[0], nil
+}
@@ -12,6 +12,7 @@ const (
 	ExecutionStatusRunning = "running"
 	ExecutionStatusSuccess = "success"
 	ExecutionStatusFailed  = "failed"
+	ExecutionStatusWaiting = "waiting" // 在等待节点暂停，到期后继续执行
 )
 
 // EventPipelineExecution 工作流执行记录
@@ -24,7 +25,7 @@ type EventPipelineExecution struct {
 	// 触发模式：event（告警触发）、api（API触发）、cron（定时触发）
 	Mode string `json:"mode" gorm:"type:varchar(16);index"`
 
-	// 状态：running、success、failed
+	// 状态：running、success、failed、waiting
 	Status string `json:"status" gorm:"type:varchar(16);index"`
 
 	// 各节点执行结果（JSON）
@@ -212,6 +213,7 @@ type EventPipelineExecutionStatistics struct {
 	Success   int64 `json:"success"`
 	Failed    int64 `json:"failed"`
 	Running   int64 `json:"running"`
+	Waiting   int64 `json:"waiting"`
 	AvgDurMs  int64 `json:"avg_duration_ms"`
 	LastRunAt int64 `json:"last_run_at"`
 }
@@ -244,6 +246,12 @@ func GetEventPipelineExecutionStatistics(c *ctx.Context, pipelineID int64) (*Eve
 		return nil, err
 	}
 
+	// 等待中
+	err = DB(c).Model(&EventPipelineExecution{}).Where("pipeline_id = ? AND status = ?", pipelineID, ExecutionStatusWaiting).Count(&stats.Waiting).Error
+	if err != nil {
+		return nil, err
+	}
+
 	// 平均耗时
 	var avgDur struct {
 		AvgDur float64 `gorm:"column:avg_dur"`
@@ -272,6 +280,7 @@ type EventPipelineExecutionDetail struct {
 	EventPipelineExecution
 	NodeResultsParsed []*NodeExecutionResult `json:"node_results_parsed"`
 	EnvSnapshotParsed map[string]string      `json:"env_snapshot_parsed"`
+	Wait              *EventPipelineWait     `json:"wait,omitempty"` // 在等待节点暂停过的执行
 }
 
 // GetEventPipelineExecutionDetail 获取执行详情
@@ -299,5 +308,12 @@ func GetEventPipelineExecutionDetail(c *ctx.Context, id string) (*EventPipelineE
 	}
 	detail.EnvSnapshotParsed = envSnapshot
 
+	if execution.Mode == TriggerModeEvent {
+		detail.Wait, err = EventPipelineWaitGetByExecutionId(c, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return detail, nil
 }
@@ -0,0 +1,178 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+)
+
+// 等待状态常量
+const (
+	PipelineWaitStatusWaiting  = "waiting"
+	PipelineWaitStatusResuming = "resuming"
+	PipelineWaitStatusDone     = "done"
+	PipelineWaitStatusFailed   = "failed"
+)
+
+// EventPipelineWait 工作流在等待节点暂停时保存的上下文，到期后由 alert 实例认领并从等待节点之后继续执行
+// 保存在数据库中，实例重启后未到期和未执行完成的等待不会丢失
+type EventPipelineWait struct {
+	Id          int64  `json:"id" gorm:"primaryKey"`
+	ExecutionId string `json:"execution_id" gorm:"type:varchar(36);not null;default:'';index"`
+	PipelineId  int64  `json:"pipeline_id" gorm:"type:bigint;not null;default:0"`
+	NodeId      string `json:"node_id" gorm:"type:varchar(64);not null;default:''"` // 等待节点的 id
+
+	// 恢复执行后继续执行通知规则中剩余的事件处理流程并发送通知
+	NotifyRuleId  int64 `json:"notify_rule_id" gorm:"type:bigint;not null;default:0"`
+	PipelineIndex int   `json:"pipeline_index" gorm:"type:int;not null;default:0"`
+
+	EventHash string `json:"event_hash" gorm:"type:varchar(64);not null;default:''"`
+	EventId   int64  `json:"event_id" gorm:"type:bigint;not null;default:0"`
+	Recheck   bool   `json:"recheck"`
+
+	// 暂停时的工作流上下文
+	Event    string `json:"-" gorm:"type:mediumtext"`
+	Env      string `json:"-" gorm:"type:text"`
+	Vars     string `json:"-" gorm:"type:text"`
+	Metadata string `json:"-" gorm:"type:text"`
+
+	ResumeAt int64  `json:"resume_at" gorm:"type:bigint;not null;default:0;index"`
+	Status   string `json:"status" gorm:"type:varchar(16);not null;default:'';index"`
+	Message  string `json:"message" gorm:"type:varchar(1024);not null;default:''"`
+	CreateAt int64  `json:"create_at" gorm:"type:bigint;not null;default:0"`
+	UpdateAt int64  `json:"update_at" gorm:"type:bigint;not null;default:0"`
+}
+
+func (w *EventPipelineWait) TableName() string {
+	return "event_pipeline_wait"
+}
+
+// SetContext 保存暂停时的事件和工作流上下文
+func (w *EventPipelineWait) SetContext(wfCtx *WorkflowContext) error {
+	event, err := json.Marshal(wfCtx.Event)
+	if err != nil {
+		return err
+	}
+
+	env, err := json.Marshal(wfCtx.Env)
+	if err != nil {
+		return err
+	}
+
+	vars, err := json.Marshal(wfCtx.Vars)
+	if err != nil {
+		return err
+	}
+
+	metadata, err := json.Marshal(wfCtx.Metadata)
+	if err != nil {
+		return err
+	}
+
+	w.Event = string(event)
+	w.Env = string(env)
+	w.Vars = string(vars)
+	w.Metadata = string(metadata)
+	return nil
+}
+
+// GetContext 恢复暂停时的工作流上下文，事件的 db 字段由 json 字段重新生成
+func (w *EventPipelineWait) GetContext() (*WorkflowContext, error) {
+	wfCtx := &WorkflowContext{
+		Env:      make(map[string]string),
+		Vars:     make(map[string]interface{}),
+		Metadata: make(map[string]string),
+	}
+
+	var event AlertCurEvent
+	if err := json.Unmarshal([]byte(w.Event), &event); err != nil {
+		return nil, err
+	}
+	event.FE2DB()
+	event.FillTagsMap()
+	wfCtx.Event = &event
+
+	if w.Env != "" {
+		if err := json.Unmarshal([]byte(w.Env), &wfCtx.Env); err != nil {
+			return nil, err
+		}
+	}
+
+	if w.Vars != "" {
+		if err := json.Unmarshal([]byte(w.Vars), &wfCtx.Vars); err != nil {
+			return nil, err
+		}
+	}
+
+	if w.Metadata != "" {
+		if err := json.Unmarshal([]byte(w.Metadata), &wfCtx.Metadata); err != nil {
+			return nil, err
+		}
+	}
+
+	return wfCtx, nil
+}
+
+// Claim 认领到期的等待，执行中的等待超过 lease 秒没有完成时认为执行的实例已退出，可以被重新认领
+func (w *EventPipelineWait) Claim(c *ctx.Context, lease int64) (bool, error) {
+	now := time.Now().Unix()
+	ret := DB(c).Model(&EventPipelineWait{}).
+		Where("id = ? and (status = ? or (status = ? and update_at < ?))", w.Id, PipelineWaitStatusWaiting, PipelineWaitStatusResuming, now-lease).
+		Updates(map[string]interface{}{"status": PipelineWaitStatusResuming, "update_at": now})
+	if ret.Error != nil {
+		return false, ret.Error
+	}
+
+	if ret.RowsAffected == 0 {
+		return false, nil
+	}
+
+	w.Status = PipelineWaitStatusResuming
+	w.UpdateAt = now
+	return true, nil
+}
+
+func (w *EventPipelineWait) Finish(c *ctx.Context, status, message string) error {
+	if len(message) > 1024 {
+		message = message[:1024]
+	}
+
+	w.Status = status
+	w.Message = message
+	w.UpdateAt = time.Now().Unix()
+	return DB(c).Model(w).Select("status", "message", "update_at").Updates(w).Error
+}
+
+func EventPipelineWaitAdd(c *ctx.Context, w *EventPipelineWait) error {
+	now := time.Now().Unix()
+	w.Status = PipelineWaitStatusWaiting
+	w.CreateAt = now
+	w.UpdateAt = now
+	return Insert(c, w)
+}
+
+// EventPipelineWaitsDue 到期未执行，以及执行中但认领已过期的等待
+func EventPipelineWaitsDue(c *ctx.Context, lease int64, limit int) ([]*EventPipelineWait, error) {
+	now := time.Now().Unix()
+	var lst []*EventPipelineWait
+	err := DB(c).Where("(status = ? and resume_at <= ?) or (status = ? and update_at < ?)",
+		PipelineWaitStatusWaiting, now, PipelineWaitStatusResuming, now-lease).
+		Order("resume_at").Limit(limit).Find(&lst).Error
+	return lst, err
+}
+
+func EventPipelineWaitGetByExecutionId(c *ctx.Context, executionId string) (*EventPipelineWait, error) {
+	var lst []*EventPipelineWait
+	err := DB(c).Where("execution_id = ?", executionId).Order("id desc").Find(&lst).Error
+	if err != nil || len(lst) == 0 {
+		return nil, err
+	}
+	return lst[0], nil
+}
+
+// DeleteEventPipelineWaits 删除已结束的等待，未结束的等待保留
+func DeleteEventPipelineWaits(c *ctx.Context, beforeTime int64) (int64, error) {
+	ret := DB(c).Where("create_at < ? and status in ?", beforeTime, []string{PipelineWaitStatusDone, PipelineWaitStatusFailed}).Delete(&EventPipelineWait{})
+	return ret.RowsAffected, ret.Error
+}
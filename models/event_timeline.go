@@ -0,0 +1,252 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+	"github.com/ccfos/nightingale/v6/pkg/poster"
+)
+
+// 事件时间线条目的类型
+const (
+	TimelineTypeTrigger     = "trigger"
+	TimelineTypeRenotify    = "renotify"
+	TimelineTypeRecover     = "recover"
+	TimelineTypeNotify      = "notify"
+	TimelineTypePipeline    = "pipeline"
+	TimelineTypeMute        = "mute"
+	TimelineTypeInhibit     = "inhibit"
+	TimelineTypeTask        = "task"
+	TimelineTypeRemediation = "remediation"
+)
+
+// 时间线中每种数据源最多读取的记录数
+const maxTimelineRecords = 500
+
+// EventTimeline 告警引擎记录的、其他表中没有的事件处理过程，如屏蔽、抑制、告警规则的事件处理流程丢弃事件等。
+// 这些操作发生在事件持久化之前，只能按事件的 hash 关联
+type EventTimeline struct {
+	Id        int64  `json:"id" gorm:"primaryKey"`
+	EventHash string `json:"event_hash" gorm:"type:varchar(64);not null;default:'';index"`
+	RuleId    int64  `json:"rule_id" gorm:"type:bigint;not null;default:0"`
+	Type      string `json:"type" gorm:"type:varchar(32);not null;default:''"`
+	Message   string `json:"message" gorm:"type:varchar(1024);not null;default:''"`
+	CreateAt  int64  `json:"create_at" gorm:"type:bigint;not null;default:0;index"`
+}
+
+func (t *EventTimeline) TableName() string {
+	return "event_timeline"
+}
+
+// EventTimelineAdds 批量保存时间线条目，边缘机房通过中心端保存
+func EventTimelineAdds(ctx *ctx.Context, lst []*EventTimeline) error {
+	if len(lst) == 0 {
+		return nil
+	}
+
+	if !ctx.IsCenter {
+		return poster.PostByUrls(ctx, "/v1/n9e/event-timelines", lst)
+	}
+
+	for _, t := range lst {
+		t.Id = 0
+		if len(t.Message) > 1024 {
+			t.Message = t.Message[:1024]
+		}
+	}
+
+	return DB(ctx).CreateInBatches(lst, 100).Error
+}
+
+func DeleteEventTimelines(ctx *ctx.Context, beforeTime int64) (int64, error) {
+	ret := DB(ctx).Where("create_at < ?", beforeTime).Delete(&EventTimeline{})
+	return ret.RowsAffected, ret.Error
+}
+
+// EventTimelineGroupId 返回事件最近一次所属的业务组，用于校验权限，事件不存在时返回 false
+func EventTimelineGroupId(ctx *ctx.Context, hash string) (int64, bool, error) {
+	var lst []*AlertHisEvent
+	err := DB(ctx).Select("id", "group_id").Where("hash = ?", hash).Order("id desc").Limit(1).Find(&lst).Error
+	if err != nil || len(lst) == 0 {
+		return 0, false, err
+	}
+	return lst[0].GroupId, true, nil
+}
+
+// EventTimelineItem 事件时间线中的一条记录，Detail 为原始记录
+type EventTimelineItem struct {
+	Time    int64       `json:"time"`
+	Type    string      `json:"type"`
+	EventId int64       `json:"event_id,omitempty"` // 对应的历史事件 id
+	Message string      `json:"message"`
+	Detail  interface{} `json:"detail,omitempty"`
+}
+
+// EventTimelineGets 按事件 hash 汇总各数据源中的记录，按时间排序：
+// 历史事件中的首次告警、重复通知和恢复，通知记录，事件处理流程的执行记录，自愈任务和自愈策略的执行记录，
+// 以及告警引擎记录的屏蔽、抑制等
+func EventTimelineGets(ctx *ctx.Context, hash string) ([]*EventTimelineItem, error) {
+	var hisEvents []*AlertHisEvent
+	err := DB(ctx).Select("id", "is_recovered", "severity", "trigger_time", "trigger_value", "recover_time", "notify_cur_number").
+		Where("hash = ?", hash).Order("id desc").Limit(maxTimelineRecords).Find(&hisEvents).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// 按发生的顺序处理，才能区分首次告警和重复通知
+	sort.Slice(hisEvents, func(i, j int) bool { return hisEvents[i].Id < hisEvents[j].Id })
+
+	items := hisEventTimeline(hisEvents)
+
+	ids := make([]int64, 0, len(hisEvents))
+	for _, e := range hisEvents {
+		ids = append(ids, e.Id)
+	}
+
+	if len(ids) > 0 {
+		var records []*NotificationRecord
+		if err := DB(ctx).Where("event_id in ?", ids).Order("id desc").Limit(maxTimelineRecords).Find(&records).Error; err != nil {
+			return nil, err
+		}
+
+		for _, r := range records {
+			status := "success"
+			if r.Status == NotiStatusFailure {
+				status = "failed"
+			}
+			items = append(items, &EventTimelineItem{
+				Time:    r.CreatedAt,
+				Type:    TimelineTypeNotify,
+				EventId: r.EventId,
+				Message: fmt.Sprintf("notify by %s to %s %s", r.Channel, r.Target, status),
+				Detail:  r,
+			})
+		}
+
+		var executions []*EventPipelineExecution
+		if err := DB(ctx).Omit("node_results", "env_snapshot").Where("event_id in ?", ids).
+			Order("created_at desc").Limit(maxTimelineRecords).Find(&executions).Error; err != nil {
+			return nil, err
+		}
+
+		for _, e := range executions {
+			msg := fmt.Sprintf("pipeline %s %s", e.PipelineName, e.Status)
+			if e.ErrorMessage != "" {
+				msg += ": " + e.ErrorMessage
+			}
+			items = append(items, &EventTimelineItem{
+				Time:    e.CreatedAt,
+				Type:    TimelineTypePipeline,
+				EventId: e.EventID,
+				Message: msg,
+				Detail:  e,
+			})
+		}
+	}
+
+	runs, err := RemediationRunsByEventHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	// 自愈策略下发的任务在执行记录中展示，不再重复展示任务
+	remediationTasks := make(map[int64]struct{})
+	for _, r := range runs {
+		if r.TaskId > 0 {
+			remediationTasks[r.TaskId] = struct{}{}
+		}
+		items = append(items, &EventTimelineItem{
+			Time:    r.CreateAt,
+			Type:    TimelineTypeRemediation,
+			EventId: r.EventId,
+			Message: fmt.Sprintf("remediation policy %s on %s %s: %s", r.PolicyName, r.Host, r.Status, r.Message),
+			Detail:  r,
+		})
+	}
+
+	if len(ids) > 0 {
+		var tasks []*TaskRecord
+		if err := DB(ctx).Omit("ibex_auth_pass").Where("event_id in ?", ids).Order("id desc").Limit(maxTimelineRecords).Find(&tasks).Error; err != nil {
+			return nil, err
+		}
+
+		for _, t := range tasks {
+			if _, has := remediationTasks[t.Id]; has {
+				continue
+			}
+			items = append(items, &EventTimelineItem{
+				Time:    t.CreateAt,
+				Type:    TimelineTypeTask,
+				EventId: t.EventId,
+				Message: fmt.Sprintf("task %d created: %s", t.Id, t.Title),
+				Detail:  t,
+			})
+		}
+	}
+
+	var entries []*EventTimeline
+	if err := DB(ctx).Where("event_hash = ?", hash).Order("id desc").Limit(maxTimelineRecords).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	for _, t := range entries {
+		items = append(items, &EventTimelineItem{
+			Time:    t.CreateAt,
+			Type:    t.Type,
+			Message: t.Message,
+			Detail:  t,
+		})
+	}
+
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Time < items[j].Time })
+	return items, nil
+}
+
+// hisEventTimeline 每次告警、重复通知和恢复都会保存一条历史事件，hisEvents 按 id 升序
+func hisEventTimeline(hisEvents []*AlertHisEvent) []*EventTimelineItem {
+	var items []*EventTimelineItem
+	var prev *AlertHisEvent
+	for _, e := range hisEvents {
+		if e.IsRecovered == 1 {
+			items = append(items, &EventTimelineItem{
+				Time:    e.RecoverTime,
+				Type:    TimelineTypeRecover,
+				EventId: e.Id,
+				Message: fmt.Sprintf("recovered, value: %s", e.TriggerValue),
+			})
+			prev = e
+			continue
+		}
+
+		if prev == nil || prev.IsRecovered == 1 || e.NotifyCurNumber <= 1 {
+			items = append(items, &EventTimelineItem{
+				Time:    e.TriggerTime,
+				Type:    TimelineTypeTrigger,
+				EventId: e.Id,
+				Message: fmt.Sprintf("triggered, severity: %d, value: %s", e.Severity, e.TriggerValue),
+			})
+		} else {
+			items = append(items, &EventTimelineItem{
+				Time:    e.TriggerTime,
+				Type:    TimelineTypeRenotify,
+				EventId: e.Id,
+				Message: fmt.Sprintf("re-notified #%d, severity: %d, value: %s", e.NotifyCurNumber, e.Severity, e.TriggerValue),
+			})
+		}
+		prev = e
+	}
+	return items
+}
+
+// NewEventTimeline 构造告警引擎记录的时间线条目
+func NewEventTimeline(event *AlertCurEvent, typ, message string) *EventTimeline {
+	return &EventTimeline{
+		EventHash: event.Hash,
+		RuleId:    event.RuleId,
+		Type:      typ,
+		Message:   message,
+		CreateAt:  time.Now().Unix(),
+	}
+}
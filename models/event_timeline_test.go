@@ -0,0 +1,72 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestEventTimelineGets(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:TestEventTimelineGets?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AutoMigrate(&AlertHisEvent{}, &NotificationRecord{}, &EventPipelineExecution{}, &TaskRecord{},
+		&RemediationRun{}, &EventTimeline{}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := ctx.NewContext(context.Background(), db, true)
+
+	rows := []interface{}{
+		// 首次告警、重复通知、恢复
+		&AlertHisEvent{Id: 1, GroupId: 3, Hash: "a", Severity: 3, TriggerTime: 1000, NotifyCurNumber: 1},
+		&AlertHisEvent{Id: 2, GroupId: 3, Hash: "a", Severity: 3, TriggerTime: 1600, NotifyCurNumber: 2},
+		&AlertHisEvent{Id: 3, GroupId: 3, Hash: "a", Severity: 3, TriggerTime: 1600, IsRecovered: 1, RecoverTime: 2000},
+		&AlertHisEvent{Id: 4, GroupId: 3, Hash: "b", Severity: 1, TriggerTime: 1000, NotifyCurNumber: 1},
+		&NotificationRecord{EventId: 1, Channel: "email", Target: "root", Status: NotiStatusSuccess, CreatedAt: 1001},
+		&NotificationRecord{EventId: 4, Channel: "email", Target: "root", Status: NotiStatusSuccess, CreatedAt: 1001},
+		&EventPipelineExecution{ID: "e1", EventID: 1, PipelineName: "enrich", Status: ExecutionStatusSuccess, CreatedAt: 1000},
+		&TaskRecord{Id: 7, EventId: 2, Title: "restart", CreateAt: 1700},
+		&TaskRecord{Id: 8, EventId: 2, Title: "manual", CreateAt: 1800},
+		&RemediationRun{EventId: 2, EventHash: "a", PolicyName: "restart", TaskId: 7, Status: RemediationStatusRunning, CreateAt: 1700},
+		&EventTimeline{EventHash: "a", Type: TimelineTypeMute, Message: "muted by hook", CreateAt: 900},
+	}
+	for _, r := range rows {
+		if err := db.Create(r).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	groupId, exists, err := EventTimelineGroupId(c, "a")
+	if err != nil || !exists || groupId != 3 {
+		t.Fatalf("unexpected group id: %d %v %v", groupId, exists, err)
+	}
+
+	items, err := EventTimelineGets(c, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{TimelineTypeMute, TimelineTypeTrigger, TimelineTypePipeline, TimelineTypeNotify,
+		TimelineTypeRenotify, TimelineTypeRemediation, TimelineTypeTask, TimelineTypeRecover}
+	if len(items) != len(want) {
+		t.Fatalf("expected %d items, got %d", len(want), len(items))
+	}
+
+	for i, typ := range want {
+		if items[i].Type != typ {
+			t.Fatalf("item %d: expected %s, got %s: %s", i, typ, items[i].Type, items[i].Message)
+		}
+	}
+
+	// 自愈策略下发的任务只展示在执行记录中
+	if items[6].Detail.(*TaskRecord).Id != 8 {
+		t.Fatalf("unexpected task: %+v", items[6].Detail)
+	}
+}
@@ -1,9 +1,11 @@
 package migrate
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/ccfos/nightingale/v6/models"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
 	"github.com/ccfos/nightingale/v6/pkg/ormx"
 
 	imodels "github.com/flashcatcloud/ibex/src/models"
@@ -68,8 +70,10 @@ func MigrateTables(db *gorm.DB) error {
 		&Board{}, &BoardBusigroup{}, &Users{}, &SsoConfig{}, &models.BuiltinMetric{},
 		&models.MetricFilter{}, &models.NotificationRecord{}, &models.TargetBusiGroup{},
 		&models.UserToken{}, &models.DashAnnotation{}, MessageTemplate{}, NotifyRule{}, NotifyChannelConfig{}, &EsIndexPatternMigrate{},
-		&models.EventPipeline{}, &models.EventPipelineExecution{}, &models.EmbeddedProduct{}, &models.SourceToken{},
-		&models.SavedView{}, &models.UserViewFavorite{}}
+		&models.EventPipeline{}, &models.EventPipelineExecution{}, &models.EventPipelineWait{}, &models.EmbeddedProduct{}, &models.SourceToken{},
+		&models.SavedView{}, &models.UserViewFavorite{}, &models.AuditLog{}, &models.ConfigVersion{}, &models.UserMfa{}, &models.NotifyDeadLetter{},
+		&models.Slo{}, &models.Report{}, &models.RecordingRuleBackfill{}, &models.RuleGroup{},
+		&models.RemediationPolicy{}, &models.RemediationRun{}, &models.EventTimeline{}}
 
 	if isPostgres(db) {
 		dts = append(dts, &models.PostgresBuiltinComponent{})
@@ -132,9 +136,70 @@ func MigrateTables(db *gorm.DB) error {
 	// 删除 builtin_metrics 表的 idx_collector_typ_name 唯一索引
 	DropUniqueFiledLimit(db, &models.BuiltinMetric{}, "idx_collector_typ_name", "idx_collector_typ_name")
 
+	// 早期 audit_log 的 idx_audit_resource 包含 resource_ids，mysql utf8mb4 下超出索引长度限制，已改为 resource_type + created_at
+	if db.Migrator().HasTable(&models.AuditLog{}) && db.Migrator().HasIndex(&models.AuditLog{}, "idx_audit_resource") {
+		if err := db.Migrator().DropIndex(&models.AuditLog{}, "idx_audit_resource"); err != nil {
+			logger.Errorf("failed to DropIndex(idx_audit_resource) error: %v", err)
+		}
+	}
+
+	MigrateUserTokenHash(db)
+	MigrateRemediationTokenHash(db)
+
+	if err := models.UserMfaEncryptSecrets(ctx.NewContext(context.Background(), db, true)); err != nil {
+		logger.Errorf("failed to encrypt user mfa secrets: %v", err)
+	}
+
 	return nil
 }
 
+// MigrateUserTokenHash 历史 token 以明文保存，迁移为 sha256 摘要，明文只保留脱敏后的前缀
+func MigrateUserTokenHash(db *gorm.DB) {
+	var tokens []*models.UserToken
+	err := db.Where("token_hash = '' and token <> ''").Find(&tokens).Error
+	if err != nil {
+		logger.Errorf("failed to query user tokens to migrate: %v", err)
+		return
+	}
+
+	for _, t := range tokens {
+		err := db.Model(&models.UserToken{}).Where("id = ?", t.Id).Updates(map[string]interface{}{
+			"token":      models.UserTokenMask(t.Token),
+			"token_hash": models.UserTokenHash(t.Token),
+		}).Error
+		if err != nil {
+			logger.Errorf("failed to migrate user token id:%d err:%v", t.Id, err)
+		}
+	}
+}
+
+// MigrateRemediationTokenHash 早期审批凭证以明文保存在 token 列，迁移为 sha256 摘要并清空明文，该列不再使用
+func MigrateRemediationTokenHash(db *gorm.DB) {
+	if !db.Migrator().HasColumn(&models.RemediationRun{}, "token") {
+		return
+	}
+
+	var runs []struct {
+		Id    int64
+		Token string
+	}
+	err := db.Table("remediation_run").Select("id, token").Where("token_hash = '' and token <> ''").Find(&runs).Error
+	if err != nil {
+		logger.Errorf("failed to query remediation runs to migrate: %v", err)
+		return
+	}
+
+	for _, r := range runs {
+		err := db.Table("remediation_run").Where("id = ?", r.Id).Updates(map[string]interface{}{
+			"token":      "",
+			"token_hash": models.RemediationTokenHash(r.Token),
+		}).Error
+		if err != nil {
+			logger.Errorf("failed to migrate remediation run id:%d err:%v", r.Id, err)
+		}
+	}
+}
+
 func DropUniqueFiledLimit(db *gorm.DB, dst interface{}, uniqueFiled string, pgUniqueFiled string) { // UNIQUE KEY (`ckey`)
 	// 先检查表是否存在，如果不存在则直接返回
 	if !db.Migrator().HasTable(dst) {
@@ -21,10 +21,14 @@ type NotificationRecord struct {
 	Target       string `json:"target" gorm:"type:varchar(1024);not null;comment:notification target"`
 	Details      string `json:"details" gorm:"type:varchar(2048);default:'';comment:notification other info"`
 	CreatedAt    int64  `json:"created_at" gorm:"type:bigint;not null;comment:create time"`
+
+	// 边缘机房的事件暂存在本地还没有落库时 EventId 为 0，中心端收到通知记录时按 hash 和触发时间找到落库后的事件
+	EventHash        string `json:"event_hash,omitempty" gorm:"-"`
+	EventTriggerTime int64  `json:"event_trigger_time,omitempty" gorm:"-"`
 }
 
 func NewNotificationRecord(event *AlertCurEvent, notifyRuleID int64, channel, target string) *NotificationRecord {
-	return &NotificationRecord{
+	n := &NotificationRecord{
 		NotifyRuleID: notifyRuleID,
 		EventId:      event.Id,
 		SubId:        event.SubRuleId,
@@ -32,6 +36,35 @@ func NewNotificationRecord(event *AlertCurEvent, notifyRuleID int64, channel, ta
 		Status:       NotiStatusSuccess,
 		Target:       target,
 	}
+
+	if event.Id == 0 {
+		n.EventHash = event.Hash
+		n.EventTriggerTime = event.TriggerTime
+	}
+
+	return n
+}
+
+// ResolveNotificationRecordEventIds 补全引用了暂存事件的通知记录的事件 id，找不到时保持为 0
+func ResolveNotificationRecordEventIds(ctx *ctx.Context, lst []*NotificationRecord) error {
+	for _, n := range lst {
+		if n.EventId != 0 || n.EventHash == "" {
+			continue
+		}
+
+		var ids []int64
+		err := DB(ctx).Model(&AlertHisEvent{}).Where("hash = ? and trigger_time = ?", n.EventHash, n.EventTriggerTime).
+			Order("id desc").Limit(1).Pluck("id", &ids).Error
+		if err != nil {
+			return err
+		}
+
+		if len(ids) > 0 {
+			n.EventId = ids[0]
+		}
+	}
+
+	return nil
 }
 
 func (n *NotificationRecord) SetStatus(status int) {
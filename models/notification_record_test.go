@@ -0,0 +1,50 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestResolveNotificationRecordEventIds(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:TestResolveNotificationRecordEventIds?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AutoMigrate(&AlertHisEvent{}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := ctx.NewContext(context.Background(), db, true)
+
+	for _, e := range []*AlertHisEvent{
+		{Id: 1, Hash: "a", TriggerTime: 1000},
+		{Id: 2, Hash: "a", TriggerTime: 1600},
+		{Id: 3, Hash: "b", TriggerTime: 1000},
+	} {
+		if err := db.Create(e).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 事件暂存在边缘机房时通知记录中只有 hash 和触发时间
+	spooled := NewNotificationRecord(&AlertCurEvent{Hash: "a", TriggerTime: 1600}, 1, "email", "root")
+	persisted := NewNotificationRecord(&AlertCurEvent{Id: 3, Hash: "b", TriggerTime: 1000}, 1, "email", "root")
+	missing := NewNotificationRecord(&AlertCurEvent{Hash: "c", TriggerTime: 1000}, 1, "email", "root")
+	if persisted.EventHash != "" {
+		t.Fatalf("persisted event should not carry hash")
+	}
+
+	if err := ResolveNotificationRecordEventIds(c, []*NotificationRecord{spooled, persisted, missing}); err != nil {
+		t.Fatal(err)
+	}
+
+	if spooled.EventId != 2 || persisted.EventId != 3 || missing.EventId != 0 {
+		t.Fatalf("event ids = %d %d %d, want 2 3 0", spooled.EventId, persisted.EventId, missing.EventId)
+	}
+}
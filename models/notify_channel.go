@@ -39,6 +39,9 @@ type EmailContext struct {
 	NotifyRuleId int64
 	Events       []*AlertCurEvent
 	Mail         *gomail.Message
+	TplContent   map[string]interface{} // 发送失败时保存为死信
+	Sendtos      []string
+	Done         func(err error) // 不为空时发送结果交给调用方处理，不再写入通知记录和死信
 }
 
 // NotifyChannelConfig 通知媒介
@@ -673,17 +676,29 @@ func (ncc *NotifyChannelConfig) SendPagerDuty(events []*AlertCurEvent, routingKe
 	return strings.Join(responses, " | "), nil
 }
 
+// HTTPNotifyRequest 渲染后的 http 通知请求，发送失败时会原样保存下来用于重投
+type HTTPNotifyRequest struct {
+	Url        string            `json:"url"`
+	Headers    map[string]string `json:"headers"`
+	Parameters map[string]string `json:"parameters"`
+	Body       string            `json:"body"`
+}
+
 func (ncc *NotifyChannelConfig) SendHTTP(events []*AlertCurEvent, tpl map[string]interface{}, params map[string]string, sendtos []string, client *http.Client) (string, error) {
-	if client == nil {
-		return "", fmt.Errorf("http client not found")
+	req, err := ncc.RenderHTTPRequest(events, tpl, params, sendtos)
+	if err != nil {
+		return "", err
 	}
 
+	return ncc.SendHTTPRequest(req, client)
+}
+
+// RenderHTTPRequest 将 MessageTemplate 与变量配置的信息渲染成 http 请求
+func (ncc *NotifyChannelConfig) RenderHTTPRequest(events []*AlertCurEvent, tpl map[string]interface{}, params map[string]string, sendtos []string) (*HTTPNotifyRequest, error) {
 	if len(events) == 0 {
-		return "", fmt.Errorf("events is empty")
+		return nil, fmt.Errorf("events is empty")
 	}
 
-	httpConfig := ncc.RequestConfig.HTTPRequestConfig
-
 	// MessageTemplate
 	fullTpl := make(map[string]interface{})
 
@@ -701,13 +716,34 @@ func (ncc *NotifyChannelConfig) SendHTTP(events []*AlertCurEvent, tpl map[string
 	body, err := ncc.parseRequestBody(fullTpl)
 	if err != nil {
 		logger.Errorf("failed to parse request body: %v, event: %v", err, events)
-		return "", err
+		return nil, err
 	}
 
 	// 替换 URL Header Parameters 中的变量
 	url, headers, parameters := ncc.replaceVariables(fullTpl)
 	logger.Infof("url: %v, headers: %v, parameters: %v", url, headers, parameters)
 
+	return &HTTPNotifyRequest{
+		Url:        url,
+		Headers:    headers,
+		Parameters: parameters,
+		Body:       string(body),
+	}, nil
+}
+
+// SendHTTPRequest 发送已渲染好的请求，失败时按 RetryTimes 和 RetryInterval 重试
+func (ncc *NotifyChannelConfig) SendHTTPRequest(r *HTTPNotifyRequest, client *http.Client) (string, error) {
+	if client == nil {
+		return "", fmt.Errorf("http client not found")
+	}
+
+	if ncc.RequestConfig == nil || ncc.RequestConfig.HTTPRequestConfig == nil {
+		return "", fmt.Errorf("http request config not found")
+	}
+
+	httpConfig := ncc.RequestConfig.HTTPRequestConfig
+	url, headers, parameters, body := r.Url, r.Headers, r.Parameters, []byte(r.Body)
+
 	// 重试机制
 	var lastErrorMessage string
 	for i := 0; i < httpConfig.RetryTimes; i++ {
@@ -1000,13 +1036,41 @@ func needsTemplateRendering(s string) bool {
 	return strings.Contains(s, "{{") && strings.Contains(s, "}}")
 }
 
-func (ncc *NotifyChannelConfig) SendEmail(notifyRuleId int64, events []*AlertCurEvent, tpl map[string]interface{}, sendtos []string, ch chan *EmailContext) {
+func (ncc *NotifyChannelConfig) SendEmail(notifyRuleId int64, events []*AlertCurEvent, tpl map[string]interface{}, sendtos []string, ch chan *EmailContext, done func(err error)) {
+	m := ncc.newEmailMessage(events, tpl, sendtos)
+	ch <- &EmailContext{NotifyRuleId: notifyRuleId, Events: events, Mail: m, TplContent: tpl, Sendtos: sendtos, Done: done}
+}
+
+// 一封邮件中最多内嵌的曲线图数量
+const maxEmailCharts = 5
+
+// newEmailMessage 构造邮件，事件带有曲线图时以内嵌图片的形式附在正文后面
+func (ncc *NotifyChannelConfig) newEmailMessage(events []*AlertCurEvent, tpl map[string]interface{}, sendtos []string) *gomail.Message {
 	m := gomail.NewMessage()
 	m.SetHeader("From", ncc.RequestConfig.SMTPRequestConfig.From)
 	m.SetHeader("To", sendtos...)
 	m.SetHeader("Subject", tpl["subject"].(string))
-	m.SetBody("text/html", tpl["content"].(string))
-	ch <- &EmailContext{notifyRuleId, events, m}
+
+	content := tpl["content"].(string)
+	var charts strings.Builder
+	embedded := 0
+	for _, event := range events {
+		if len(event.ChartImage) == 0 || embedded >= maxEmailCharts {
+			continue
+		}
+
+		img := event.ChartImage
+		name := fmt.Sprintf("chart-%d.png", embedded)
+		m.Embed(name, gomail.SetCopyFunc(func(w io.Writer) error {
+			_, err := w.Write(img)
+			return err
+		}))
+		charts.WriteString(fmt.Sprintf(`<p><img src="cid:%s" alt="%s"/></p>`, name, template.HTMLEscapeString(event.RuleName)))
+		embedded++
+	}
+
+	m.SetBody("text/html", content+charts.String())
+	return m
 }
 
 func (ncc *NotifyChannelConfig) SendEmailNow(events []*AlertCurEvent, tpl map[string]interface{}, sendtos []string) error {
@@ -1021,12 +1085,7 @@ func (ncc *NotifyChannelConfig) SendEmailNow(events []*AlertCurEvent, tpl map[st
 		return err
 	}
 
-	m := gomail.NewMessage()
-	m.SetHeader("From", ncc.RequestConfig.SMTPRequestConfig.From)
-	m.SetHeader("To", sendtos...)
-	m.SetHeader("Subject", tpl["subject"].(string))
-	m.SetBody("text/html", tpl["content"].(string))
-	return gomail.Send(s, m)
+	return gomail.Send(s, ncc.newEmailMessage(events, tpl, sendtos))
 }
 
 func (ncc *NotifyChannelConfig) Verify() error {
@@ -0,0 +1,288 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+	"github.com/ccfos/nightingale/v6/pkg/poster"
+	"github.com/ccfos/nightingale/v6/pkg/secu"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DeadLetterStatusPending   = "pending"   // 等待自动重投
+	DeadLetterStatusSucceeded = "succeeded" // 重投成功
+	DeadLetterStatusExhausted = "exhausted" // 超过最大重投次数，需要人工处理
+)
+
+// NotifyDeadLetter 通知发送失败后保存的渲染结果，由后台任务按指数退避重投，也可以手动重发
+// http 媒介保存渲染后的请求，其他媒介保存渲染后的消息；请求头、参数等可能包含鉴权信息的内容加密后保存在 Secret 中
+type NotifyDeadLetter struct {
+	Id           int64              `json:"id" gorm:"primaryKey;type:bigint;autoIncrement"`
+	NotifyRuleId int64              `json:"notify_rule_id" gorm:"type:bigint;not null;default:0;index:idx_dead_letter_rule"`
+	ChannelId    int64              `json:"channel_id" gorm:"type:bigint;not null;default:0"`
+	ChannelName  string             `json:"channel_name" gorm:"type:varchar(255);not null;default:''"`
+	Target       string             `json:"target" gorm:"type:varchar(1024);not null;default:''"`
+	Events       []DeadLetterEvent  `json:"events" gorm:"type:text;serializer:json"`
+	Request      *HTTPNotifyRequest `json:"request" gorm:"type:mediumtext;serializer:json"`
+	Message      *DeadLetterMessage `json:"message" gorm:"type:mediumtext;serializer:json"`
+	Secret       string             `json:"-" gorm:"type:text"`
+	Status       string             `json:"status" gorm:"type:varchar(32);not null;default:'';index:idx_dead_letter_retry,priority:1"`
+	Attempts     int                `json:"attempts" gorm:"type:int;not null;default:0"`
+	NextRetryAt  int64              `json:"next_retry_at" gorm:"type:bigint;not null;default:0;index:idx_dead_letter_retry,priority:2"`
+	LastError    string             `json:"last_error" gorm:"type:varchar(2048);not null;default:''"`
+	CreateAt     int64              `json:"create_at" gorm:"type:bigint;not null;default:0"`
+	UpdateAt     int64              `json:"update_at" gorm:"type:bigint;not null;default:0"`
+}
+
+// DeadLetterEvent 重投成功或失败时需要据此写入通知记录
+type DeadLetterEvent struct {
+	Id        int64  `json:"id"`
+	SubRuleId int64  `json:"sub_rule_id"`
+	Hash      string `json:"hash"`
+}
+
+// DeadLetterMessage 非 http 媒介的渲染结果，重投时按媒介类型重新发送
+type DeadLetterMessage struct {
+	Events             []*AlertCurEvent       `json:"events"`
+	TplContent         map[string]interface{} `json:"tpl_content,omitempty"`
+	Sendtos            []string               `json:"sendtos,omitempty"`
+	CustomParams       map[string]string      `json:"custom_params,omitempty"`
+	FlashDutyChannelId int64                  `json:"flashduty_channel_id,omitempty"`
+	RoutingKey         string                 `json:"routing_key,omitempty"`
+	SiteUrl            string                 `json:"site_url,omitempty"`
+}
+
+// deadLetterSecret 落库前从请求和消息中取出、加密保存的内容
+type deadLetterSecret struct {
+	Headers      map[string]string `json:"headers,omitempty"`
+	Parameters   map[string]string `json:"parameters,omitempty"`
+	CustomParams map[string]string `json:"custom_params,omitempty"`
+	RoutingKey   string            `json:"routing_key,omitempty"`
+}
+
+func (d *NotifyDeadLetter) TableName() string {
+	return "notify_dead_letter"
+}
+
+func NewNotifyDeadLetter(events []*AlertCurEvent, notifyRuleId int64, channel *NotifyChannelConfig, target string, req *HTTPNotifyRequest, msg *DeadLetterMessage, lastErr error) *NotifyDeadLetter {
+	now := time.Now().Unix()
+	d := &NotifyDeadLetter{
+		NotifyRuleId: notifyRuleId,
+		ChannelId:    channel.ID,
+		ChannelName:  channel.Name,
+		Target:       target,
+		Events:       make([]DeadLetterEvent, 0, len(events)),
+		Request:      req,
+		Message:      msg,
+		Status:       DeadLetterStatusPending,
+		NextRetryAt:  now,
+		CreateAt:     now,
+		UpdateAt:     now,
+	}
+
+	if lastErr != nil {
+		d.SetError(lastErr.Error())
+	}
+
+	for _, e := range events {
+		d.Events = append(d.Events, DeadLetterEvent{Id: e.Id, SubRuleId: e.SubRuleId, Hash: e.Hash})
+	}
+
+	return d
+}
+
+func (d *NotifyDeadLetter) SetError(msg string) {
+	if len(msg) > 2000 {
+		msg = msg[:2000]
+	}
+	d.LastError = msg
+}
+
+// AlertEvents 只包含生成通知记录所需的字段
+func (d *NotifyDeadLetter) AlertEvents() []*AlertCurEvent {
+	events := make([]*AlertCurEvent, 0, len(d.Events))
+	for _, e := range d.Events {
+		events = append(events, &AlertCurEvent{Id: e.Id, SubRuleId: e.SubRuleId, Hash: e.Hash})
+	}
+	return events
+}
+
+// Add 边缘机房通过中心端落库，保证重投任务可以统一调度
+func (d *NotifyDeadLetter) Add(ctx *ctx.Context) error {
+	if !ctx.IsCenter {
+		return poster.PostByUrls(ctx, "/v1/n9e/notify-dead-letter", d)
+	}
+
+	d.Id = 0
+	if err := d.encryptSecret(ctx); err != nil {
+		return err
+	}
+
+	return Insert(ctx, d)
+}
+
+func (d *NotifyDeadLetter) encryptSecret(ctx *ctx.Context) error {
+	var secret deadLetterSecret
+	if d.Request != nil {
+		secret.Headers, secret.Parameters = d.Request.Headers, d.Request.Parameters
+		d.Request.Headers, d.Request.Parameters = nil, nil
+	}
+
+	if d.Message != nil {
+		secret.CustomParams, secret.RoutingKey = d.Message.CustomParams, d.Message.RoutingKey
+		d.Message.CustomParams, d.Message.RoutingKey = nil, ""
+	}
+
+	bs, err := json.Marshal(secret)
+	if err != nil {
+		return err
+	}
+
+	key, err := notifyDeadLetterKey(ctx)
+	if err != nil {
+		return err
+	}
+
+	d.Secret, err = secu.DealWithEncrypt(string(bs), key)
+	return err
+}
+
+// DecryptSecret 重投前还原加密保存的请求头、参数等
+func (d *NotifyDeadLetter) DecryptSecret(ctx *ctx.Context) error {
+	if d.Secret == "" {
+		return nil
+	}
+
+	key, err := notifyDeadLetterKey(ctx)
+	if err != nil {
+		return err
+	}
+
+	plain, err := secu.DealWithDecrypt(d.Secret, key)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt dead letter: %v", err)
+	}
+
+	var secret deadLetterSecret
+	if err := json.Unmarshal([]byte(plain), &secret); err != nil {
+		return fmt.Errorf("failed to decrypt dead letter: %v", err)
+	}
+
+	if d.Request != nil {
+		d.Request.Headers, d.Request.Parameters = secret.Headers, secret.Parameters
+	}
+
+	if d.Message != nil {
+		d.Message.CustomParams, d.Message.RoutingKey = secret.CustomParams, secret.RoutingKey
+	}
+
+	return nil
+}
+
+// deadLetterKey 死信加密使用的密钥
+var deadLetterKey = &generatedKey{ckey: NOTIFY_DEAD_LETTER_KEY}
+
+func notifyDeadLetterKey(ctx *ctx.Context) (string, error) {
+	return deadLetterKey.get(ctx)
+}
+
+// Claim 将下次重投时间推后 lease 秒并置为等待重投，只有一个实例或一次手动重发能认领成功，避免重复发送
+func (d *NotifyDeadLetter) Claim(ctx *ctx.Context, lease int64) (bool, error) {
+	next := time.Now().Unix() + lease
+	ret := DB(ctx).Model(&NotifyDeadLetter{}).
+		Where("id = ? and status = ? and next_retry_at = ?", d.Id, d.Status, d.NextRetryAt).
+		Updates(map[string]interface{}{"status": DeadLetterStatusPending, "next_retry_at": next})
+	if ret.Error != nil {
+		return false, ret.Error
+	}
+
+	if ret.RowsAffected != 1 {
+		return false, nil
+	}
+
+	d.Status = DeadLetterStatusPending
+	d.NextRetryAt = next
+	return true, nil
+}
+
+func (d *NotifyDeadLetter) UpdateResult(ctx *ctx.Context) error {
+	d.UpdateAt = time.Now().Unix()
+	return DB(ctx).Model(d).Select("status", "attempts", "next_retry_at", "last_error", "update_at").Updates(d).Error
+}
+
+// NotifyDeadLetterDue 到期需要重投的记录
+func NotifyDeadLetterDue(ctx *ctx.Context, now int64, limit int) ([]*NotifyDeadLetter, error) {
+	var lst []*NotifyDeadLetter
+	err := DB(ctx).Where("status = ? and next_retry_at <= ?", DeadLetterStatusPending, now).
+		Order("next_retry_at").Limit(limit).Find(&lst).Error
+	return lst, err
+}
+
+func notifyDeadLetterQuery(ctx *ctx.Context, notifyRuleId int64, status, query string) *gorm.DB {
+	session := DB(ctx).Model(&NotifyDeadLetter{}).Where("notify_rule_id = ?", notifyRuleId)
+
+	if status != "" {
+		session = session.Where("status = ?", status)
+	}
+
+	if query != "" {
+		q := "%" + query + "%"
+		session = session.Where("channel_name like ? or target like ? or last_error like ?", q, q, q)
+	}
+
+	return session
+}
+
+func NotifyDeadLetterTotal(ctx *ctx.Context, notifyRuleId int64, status, query string) (int64, error) {
+	var total int64
+	err := notifyDeadLetterQuery(ctx, notifyRuleId, status, query).Count(&total).Error
+	return total, err
+}
+
+func NotifyDeadLetterGets(ctx *ctx.Context, notifyRuleId int64, status, query string, limit, offset int) ([]*NotifyDeadLetter, error) {
+	var lst []*NotifyDeadLetter
+	err := notifyDeadLetterQuery(ctx, notifyRuleId, status, query).
+		Order("id desc").Limit(limit).Offset(offset).Find(&lst).Error
+	return lst, err
+}
+
+func NotifyDeadLetterGetsByIds(ctx *ctx.Context, ids []int64) ([]*NotifyDeadLetter, error) {
+	var lst []*NotifyDeadLetter
+	if len(ids) == 0 {
+		return lst, nil
+	}
+
+	err := DB(ctx).Where("id in ?", ids).Find(&lst).Error
+	return lst, err
+}
+
+func NotifyDeadLetterDel(ctx *ctx.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return DB(ctx).Where("id in ?", ids).Delete(&NotifyDeadLetter{}).Error
+}
+
+// DeleteNotifyDeadLettersInBatches 清理已经结束的记录，等待重投的记录保留
+func DeleteNotifyDeadLettersInBatches(ctx *ctx.Context, beforeTime int64, limit int) (int64, error) {
+	var ids []int64
+	err := DB(ctx).Model(&NotifyDeadLetter{}).
+		Where("status <> ? and update_at < ?", DeadLetterStatusPending, beforeTime).
+		Limit(limit).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return 0, err
+	}
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	ret := DB(ctx).Where("id in ?", ids).Delete(&NotifyDeadLetter{})
+	return ret.RowsAffected, ret.Error
+}
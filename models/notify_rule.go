@@ -49,14 +49,44 @@ type NotifyConfig struct {
 	TimeRanges []TimeRanges `json:"time_ranges"` // 适用时段
 	LabelKeys  []TagFilter  `json:"label_keys"`  // 适用标签
 	Attributes []TagFilter  `json:"attributes"`  // 适用属性
+
+	Fallbacks []NotifyFallback `json:"fallbacks,omitempty"` // 发送失败时依次尝试的备用通知媒介
+}
+
+// NotifyFallback 备用通知媒介，适用级别、时段等条件沿用所属的 NotifyConfig
+type NotifyFallback struct {
+	ChannelID  int64                  `json:"channel_id"`
+	TemplateID int64                  `json:"template_id"`
+	Params     map[string]interface{} `json:"params"`
+	Type       string                 `json:"type"`
 }
 
+const MaxNotifyFallbacks = 5
+
 func (n *NotifyConfig) Hash() string {
 	hash := sha256.New()
-	hash.Write([]byte(fmt.Sprintf("%d%d%v%s%v%v%v%v", n.ChannelID, n.TemplateID, n.Params, n.Type, n.Severities, n.TimeRanges, n.LabelKeys, n.Attributes)))
+	hash.Write([]byte(fmt.Sprintf("%d%d%v%s%v%v%v%v%v", n.ChannelID, n.TemplateID, n.Params, n.Type, n.Severities, n.TimeRanges, n.LabelKeys, n.Attributes, n.Fallbacks)))
 	return hex.EncodeToString(hash.Sum(nil))
 }
 
+// Hops 返回主通知媒介和备用媒介组成的发送链，第一个元素是自身
+func (n *NotifyConfig) Hops() []*NotifyConfig {
+	hops := make([]*NotifyConfig, 0, len(n.Fallbacks)+1)
+	hops = append(hops, n)
+
+	for _, fb := range n.Fallbacks {
+		hop := *n
+		hop.ChannelID = fb.ChannelID
+		hop.TemplateID = fb.TemplateID
+		hop.Params = fb.Params
+		hop.Type = fb.Type
+		hop.Fallbacks = nil
+		hops = append(hops, &hop)
+	}
+
+	return hops
+}
+
 type CustomParams struct {
 	UserIDs      []int64 `json:"user_ids"`
 	UserGroupIDs []int64 `json:"user_group_ids"`
@@ -168,6 +198,22 @@ func (c *NotifyConfig) Verify() error {
 		}
 	}
 
+	if len(c.Fallbacks) > MaxNotifyFallbacks {
+		return fmt.Errorf("too many fallback channels, max: %d", MaxNotifyFallbacks)
+	}
+
+	seen := map[int64]struct{}{c.ChannelID: {}}
+	for _, fb := range c.Fallbacks {
+		if fb.ChannelID <= 0 {
+			return errors.New("invalid fallback channel id")
+		}
+
+		if _, has := seen[fb.ChannelID]; has {
+			return fmt.Errorf("fallback channel %d is duplicated", fb.ChannelID)
+		}
+		seen[fb.ChannelID] = struct{}{}
+	}
+
 	return nil
 }
 
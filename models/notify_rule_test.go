@@ -0,0 +1,55 @@
+package models
+
+import "testing"
+
+func TestNotifyConfigHops(t *testing.T) {
+	c := &NotifyConfig{
+		ChannelID:  1,
+		TemplateID: 10,
+		Severities: []int{1, 2},
+		Fallbacks: []NotifyFallback{
+			{ChannelID: 2, TemplateID: 20},
+			{ChannelID: 3, TemplateID: 30, Params: map[string]interface{}{"user_ids": []int64{1}}},
+		},
+	}
+
+	hops := c.Hops()
+	if len(hops) != 3 {
+		t.Fatalf("expected 3 hops, got %d", len(hops))
+	}
+
+	if hops[0] != c {
+		t.Errorf("first hop should be the config itself")
+	}
+
+	for i, want := range []int64{1, 2, 3} {
+		if hops[i].ChannelID != want || hops[i].TemplateID != want*10 {
+			t.Errorf("hop %d: channel %d template %d", i, hops[i].ChannelID, hops[i].TemplateID)
+		}
+
+		if len(hops[i].Severities) != 2 {
+			t.Errorf("hop %d should inherit severities", i)
+		}
+
+		if i > 0 && len(hops[i].Fallbacks) != 0 {
+			t.Errorf("hop %d should not have fallbacks", i)
+		}
+	}
+}
+
+func TestNotifyConfigVerifyFallbacks(t *testing.T) {
+	c := &NotifyConfig{ChannelID: 1, Fallbacks: []NotifyFallback{{ChannelID: 2}}}
+	if err := c.Verify(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	c.Fallbacks = append(c.Fallbacks, NotifyFallback{ChannelID: 1})
+	if err := c.Verify(); err == nil {
+		t.Errorf("expected error for duplicated channel")
+	}
+
+	c.Fallbacks = []NotifyFallback{{ChannelID: 0}}
+	if err := c.Verify(); err == nil {
+		t.Errorf("expected error for invalid channel id")
+	}
+}
@@ -144,6 +144,18 @@ func (re *RecordingRule) Verify() error {
 		if !model.MetricNameRE.MatchString(queryConfig.NewMetric) {
 			return errors.New("Metric Name has invalid chreacters")
 		}
+
+		if len(queryConfig.Queries) == 0 {
+			continue
+		}
+
+		if queryConfig.WriteDatasourceId <= 0 {
+			return fmt.Errorf("write datasource of %s is blank", queryConfig.NewMetric)
+		}
+
+		if len(queryConfig.Queries) > 1 && strings.TrimSpace(queryConfig.Exp) == "" {
+			return fmt.Errorf("exp of %s is blank", queryConfig.NewMetric)
+		}
 	}
 
 	if re.Name == "" && re.PromQl != "" {
@@ -232,6 +244,12 @@ func RecordingRuleDels(ctx *ctx.Context, ids []int64, groupId int64) error {
 		if ret.Error != nil {
 			return ret.Error
 		}
+
+		if ret.RowsAffected > 0 {
+			if err := RecordingRuleBackfillDelByRuleIds(ctx, []int64{ids[i]}); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
@@ -324,6 +342,31 @@ func RecordingRuleEnabledGets(ctx *ctx.Context) ([]*RecordingRule, error) {
 	return lst, nil
 }
 
+// RecordingRuleEnabledGetsByIds 缓存增量更新时使用，只返回启用的规则
+func RecordingRuleEnabledGetsByIds(ctx *ctx.Context, ids []int64) ([]*RecordingRule, error) {
+	if !ctx.IsCenter {
+		lst, err := poster.GetByUrls[[]*RecordingRule](ctx, "/v1/n9e/recording-rules?ids="+JoinIds(ids))
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < len(lst); i++ {
+			lst[i].FE2DB()
+		}
+		return lst, err
+	}
+
+	var lst []*RecordingRule
+	err := DB(ctx).Where("id in ? and disabled = ?", ids, 0).Find(&lst).Error
+	if err != nil {
+		return lst, err
+	}
+
+	for i := 0; i < len(lst); i++ {
+		lst[i].DB2FE()
+	}
+	return lst, nil
+}
+
 func RecordingRuleGetsByCluster(ctx *ctx.Context) ([]*RecordingRule, error) {
 	if !ctx.IsCenter {
 		lst, err := poster.GetByUrls[[]*RecordingRule](ctx, "/v1/n9e/recording-rules")
@@ -0,0 +1,187 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+)
+
+const (
+	BackfillStatusPending   = "pending"
+	BackfillStatusRunning   = "running"
+	BackfillStatusSucceeded = "succeeded"
+	BackfillStatusFailed    = "failed"
+	BackfillStatusCanceled  = "canceled"
+
+	// 单个回填任务最多计算的时间点数量，避免误操作写入过多数据
+	BackfillMaxSteps = 100000
+	BackfillMaxQps   = 100
+)
+
+// RecordingRuleBackfill 记录规则的历史数据回填任务，按 step 逐个时间点计算 [start, end] 区间，写入时保留原始时间戳
+// 进度保存在数据库中，执行任务的实例退出后，其他实例会从已完成的时间点继续
+type RecordingRuleBackfill struct {
+	Id        int64  `json:"id" gorm:"primaryKey"`
+	RuleId    int64  `json:"rule_id" gorm:"type:bigint;not null;default:0;index:idx_backfill_rule"`
+	Start     int64  `json:"start" gorm:"type:bigint;not null;default:0"`
+	End       int64  `json:"end" gorm:"type:bigint;not null;default:0"`
+	Step      int64  `json:"step" gorm:"type:bigint;not null;default:0"`               // unit: s
+	Qps       int    `json:"qps" gorm:"type:int;not null;default:0"`                   // 每秒最多发出的查询数
+	Status    string `json:"status" gorm:"type:varchar(32);not null;default:'';index"` // pending running succeeded failed canceled
+	Total     int64  `json:"total" gorm:"type:bigint;not null;default:0"`              // 需要计算的时间点数量
+	Done      int64  `json:"done" gorm:"type:bigint;not null;default:0"`               // 已完成的时间点数量
+	Samples   int64  `json:"samples" gorm:"type:bigint;not null;default:0"`            // 已写入的样本数量
+	LastError string `json:"last_error" gorm:"type:varchar(2048);not null;default:''"` // 失败原因
+	CreateAt  int64  `json:"create_at" gorm:"type:bigint;not null;default:0"`
+	CreateBy  string `json:"create_by" gorm:"type:varchar(64);not null;default:''"`
+	UpdateAt  int64  `json:"update_at" gorm:"type:bigint;not null;default:0"`
+}
+
+func (b *RecordingRuleBackfill) TableName() string {
+	return "recording_rule_backfill"
+}
+
+func (b *RecordingRuleBackfill) Verify(rule *RecordingRule) error {
+	if b.Start <= 0 || b.End <= b.Start {
+		return fmt.Errorf("invalid time range: %d - %d", b.Start, b.End)
+	}
+
+	if b.End > time.Now().Unix() {
+		return fmt.Errorf("end time should not be in the future")
+	}
+
+	if b.Step <= 0 {
+		b.Step = int64(rule.PromEvalInterval)
+	}
+
+	if b.Step <= 0 {
+		b.Step = 60
+	}
+
+	b.Total = (b.End-b.Start)/b.Step + 1
+	if b.Total > BackfillMaxSteps {
+		return fmt.Errorf("too many steps: %d, max is %d, increase step or narrow the time range", b.Total, BackfillMaxSteps)
+	}
+
+	if b.Qps <= 0 {
+		b.Qps = 1
+	}
+
+	if b.Qps > BackfillMaxQps {
+		b.Qps = BackfillMaxQps
+	}
+
+	return nil
+}
+
+func (b *RecordingRuleBackfill) Add(ctx *ctx.Context) error {
+	now := time.Now().Unix()
+	b.Id = 0
+	b.Status = BackfillStatusPending
+	b.Done = 0
+	b.Samples = 0
+	b.LastError = ""
+	b.CreateAt = now
+	b.UpdateAt = now
+	return Insert(ctx, b)
+}
+
+func (b *RecordingRuleBackfill) SetError(msg string) {
+	if len(msg) > 2000 {
+		msg = msg[:2000]
+	}
+	b.LastError = msg
+}
+
+// StepAt 第 i 个时间点
+func (b *RecordingRuleBackfill) StepAt(i int64) int64 {
+	return b.Start + i*b.Step
+}
+
+// Claim 认领待执行的任务，或 lease 秒内没有更新进度的执行中任务（执行的实例已退出），只有一个实例能认领成功
+func (b *RecordingRuleBackfill) Claim(ctx *ctx.Context, lease int64) (bool, error) {
+	now := time.Now().Unix()
+	if b.Status == BackfillStatusRunning && b.UpdateAt > now-lease {
+		return false, nil
+	}
+
+	ret := DB(ctx).Model(&RecordingRuleBackfill{}).
+		Where("id = ? and status = ? and update_at = ?", b.Id, b.Status, b.UpdateAt).
+		Updates(map[string]interface{}{"status": BackfillStatusRunning, "update_at": now})
+	if ret.Error != nil {
+		return false, ret.Error
+	}
+
+	b.Status = BackfillStatusRunning
+	b.UpdateAt = now
+	return ret.RowsAffected == 1, nil
+}
+
+// UpdateProgress 保存进度，任务已被取消或删除时返回 false
+func (b *RecordingRuleBackfill) UpdateProgress(ctx *ctx.Context) (bool, error) {
+	b.UpdateAt = time.Now().Unix()
+	ret := DB(ctx).Model(&RecordingRuleBackfill{}).
+		Where("id = ? and status = ?", b.Id, BackfillStatusRunning).
+		Updates(map[string]interface{}{"done": b.Done, "samples": b.Samples, "update_at": b.UpdateAt})
+	if ret.Error != nil {
+		return false, ret.Error
+	}
+
+	return ret.RowsAffected == 1, nil
+}
+
+// Finish 任务结束，已取消的任务不会被覆盖为其他状态
+func (b *RecordingRuleBackfill) Finish(ctx *ctx.Context, status string) error {
+	b.Status = status
+	b.UpdateAt = time.Now().Unix()
+	return DB(ctx).Model(&RecordingRuleBackfill{}).
+		Where("id = ? and status = ?", b.Id, BackfillStatusRunning).
+		Updates(map[string]interface{}{
+			"status":     b.Status,
+			"done":       b.Done,
+			"samples":    b.Samples,
+			"last_error": b.LastError,
+			"update_at":  b.UpdateAt,
+		}).Error
+}
+
+// Cancel 取消未结束的任务，执行中的任务在下次保存进度时停止
+func (b *RecordingRuleBackfill) Cancel(ctx *ctx.Context) error {
+	if b.Status != BackfillStatusPending && b.Status != BackfillStatusRunning {
+		return fmt.Errorf("backfill is %s, cannot be canceled", b.Status)
+	}
+
+	b.Status = BackfillStatusCanceled
+	b.UpdateAt = time.Now().Unix()
+	return DB(ctx).Model(&RecordingRuleBackfill{}).
+		Where("id = ? and status in ?", b.Id, []string{BackfillStatusPending, BackfillStatusRunning}).
+		Updates(map[string]interface{}{"status": b.Status, "update_at": b.UpdateAt}).Error
+}
+
+func RecordingRuleBackfillGetById(ctx *ctx.Context, id int64) (*RecordingRuleBackfill, error) {
+	var lst []*RecordingRuleBackfill
+	err := DB(ctx).Where("id = ?", id).Find(&lst).Error
+	if err != nil || len(lst) == 0 {
+		return nil, err
+	}
+	return lst[0], nil
+}
+
+func RecordingRuleBackfillGets(ctx *ctx.Context, ruleId int64) ([]*RecordingRuleBackfill, error) {
+	var lst []*RecordingRuleBackfill
+	err := DB(ctx).Where("rule_id = ?", ruleId).Order("id desc").Find(&lst).Error
+	return lst, err
+}
+
+// RecordingRuleBackfillUnfinished 待执行和执行中的任务
+func RecordingRuleBackfillUnfinished(ctx *ctx.Context) ([]*RecordingRuleBackfill, error) {
+	var lst []*RecordingRuleBackfill
+	err := DB(ctx).Where("status in ?", []string{BackfillStatusPending, BackfillStatusRunning}).
+		Order("id").Find(&lst).Error
+	return lst, err
+}
+
+func RecordingRuleBackfillDelByRuleIds(ctx *ctx.Context, ruleIds []int64) error {
+	return DB(ctx).Where("rule_id in ?", ruleIds).Delete(&RecordingRuleBackfill{}).Error
+}
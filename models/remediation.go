@@ -0,0 +1,349 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+
+	"github.com/pkg/errors"
+	"github.com/toolkits/pkg/str"
+)
+
+const (
+	DefaultRemediationMaxRunsPerHour  = 10
+	DefaultRemediationApprovalTimeout = 3600
+)
+
+// 自愈执行记录的状态
+const (
+	RemediationStatusDryRun          = "dry_run"
+	RemediationStatusPendingApproval = "pending_approval"
+	RemediationStatusApproved        = "approved"
+	RemediationStatusRejected        = "rejected"
+	RemediationStatusExpired         = "expired"
+	RemediationStatusSkipped         = "skipped"
+	RemediationStatusRunning         = "running"
+	RemediationStatusSuccess         = "success"
+	RemediationStatusFailed          = "failed"
+)
+
+// RemediationCountedStatuses 计入冷却时间和每小时执行次数的状态，试运行也计入，便于观察开启后的实际效果
+var RemediationCountedStatuses = []string{
+	RemediationStatusDryRun, RemediationStatusPendingApproval, RemediationStatusApproved,
+	RemediationStatusRunning, RemediationStatusSuccess, RemediationStatusFailed,
+}
+
+// RemediationPolicy 故障自愈策略，告警事件匹配策略时在事件的机器上执行自愈脚本模板。
+// 与告警规则中直接配置的自愈脚本不同，策略提供冷却时间、每小时执行次数上限、试运行和人工审批等保护措施。
+// 策略只作用于同一业务组的事件，执行记录保存在数据库中，只在 center 中执行
+type RemediationPolicy struct {
+	Id           int64       `json:"id" gorm:"primaryKey"`
+	GroupId      int64       `json:"group_id" gorm:"type:bigint;not null;default:0;index"` // busi group id
+	Name         string      `json:"name" gorm:"type:varchar(255);not null;default:''"`
+	Note         string      `json:"note" gorm:"type:varchar(1024);not null;default:''"`
+	Disabled     int         `json:"disabled" gorm:"type:int;not null;default:0"`
+	TaskTplId    int64       `json:"task_tpl_id" gorm:"type:bigint;not null;default:0"`
+	RuleIds      []int64     `json:"rule_ids" gorm:"type:text;serializer:json"`          // 为空时匹配所有告警规则
+	Severities   []int       `json:"severities" gorm:"type:varchar(64);serializer:json"` // 为空时匹配所有级别
+	LabelFilters []TagFilter `json:"label_filters" gorm:"type:text;serializer:json"`
+
+	// 同一台机器两次执行的最小间隔，unit: s
+	Cooldown int64 `json:"cooldown" gorm:"type:bigint;not null;default:0"`
+	// 策略每小时最多执行的次数，所有机器合计
+	MaxRunsPerHour int `json:"max_runs_per_hour" gorm:"type:int;not null;default:0"`
+	// 试运行只记录会执行的任务，不实际执行
+	DryRun bool `json:"dry_run"`
+
+	// 需要审批时先通过 ApprovalWebhook 发送审批链接，审批通过后才执行，超过 ApprovalTimeout 秒未审批则放弃
+	ApprovalRequired bool   `json:"approval_required"`
+	ApprovalWebhook  string `json:"approval_webhook" gorm:"type:varchar(1024);not null;default:''"`
+	ApprovalTimeout  int64  `json:"approval_timeout" gorm:"type:bigint;not null;default:0"` // unit: s
+
+	CreateAt int64  `json:"create_at" gorm:"type:bigint;not null;default:0"`
+	CreateBy string `json:"create_by" gorm:"type:varchar(64);not null;default:''"`
+	UpdateAt int64  `json:"update_at" gorm:"type:bigint;not null;default:0"`
+	UpdateBy string `json:"update_by" gorm:"type:varchar(64);not null;default:''"`
+}
+
+func (p *RemediationPolicy) TableName() string {
+	return "remediation_policy"
+}
+
+func (p *RemediationPolicy) Verify(ctx *ctx.Context) error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return errors.New("name is blank")
+	}
+
+	if str.Dangerous(p.Name) {
+		return errors.New("name has invalid characters")
+	}
+
+	tpl, err := TaskTplGetById(ctx, p.TaskTplId)
+	if err != nil {
+		return err
+	}
+
+	if tpl == nil {
+		return fmt.Errorf("task tpl %d not found", p.TaskTplId)
+	}
+
+	if tpl.GroupId != p.GroupId {
+		return fmt.Errorf("task tpl %d does not belong to busi group %d", p.TaskTplId, p.GroupId)
+	}
+
+	if _, err := ParseTagFilter(p.LabelFilters); err != nil {
+		return fmt.Errorf("invalid label filters: %v", err)
+	}
+
+	if p.Cooldown < 0 {
+		return errors.New("cooldown should not be negative")
+	}
+
+	if p.MaxRunsPerHour <= 0 {
+		p.MaxRunsPerHour = DefaultRemediationMaxRunsPerHour
+	}
+
+	if p.ApprovalTimeout <= 0 {
+		p.ApprovalTimeout = DefaultRemediationApprovalTimeout
+	}
+
+	p.ApprovalWebhook = strings.TrimSpace(p.ApprovalWebhook)
+	if p.ApprovalWebhook != "" && !strings.HasPrefix(p.ApprovalWebhook, "http://") && !strings.HasPrefix(p.ApprovalWebhook, "https://") {
+		return errors.New("approval_webhook should start with http:// or https://")
+	}
+
+	return nil
+}
+
+func (p *RemediationPolicy) Add(ctx *ctx.Context) error {
+	if err := p.Verify(ctx); err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	p.Id = 0
+	p.CreateAt = now
+	p.UpdateAt = now
+	return Insert(ctx, p)
+}
+
+func (p *RemediationPolicy) Update(ctx *ctx.Context, ref RemediationPolicy) error {
+	ref.Id = p.Id
+	ref.GroupId = p.GroupId
+	ref.CreateAt = p.CreateAt
+	ref.CreateBy = p.CreateBy
+	ref.UpdateAt = time.Now().Unix()
+
+	if err := ref.Verify(ctx); err != nil {
+		return err
+	}
+
+	return DB(ctx).Model(p).Select("*").Updates(ref).Error
+}
+
+func RemediationPolicyDels(ctx *ctx.Context, ids []int64, groupId int64) error {
+	return DB(ctx).Where("id in ? and group_id = ?", ids, groupId).Delete(&RemediationPolicy{}).Error
+}
+
+func RemediationPolicyGetById(ctx *ctx.Context, id int64) (*RemediationPolicy, error) {
+	var lst []*RemediationPolicy
+	err := DB(ctx).Where("id = ?", id).Find(&lst).Error
+	if err != nil || len(lst) == 0 {
+		return nil, err
+	}
+	return lst[0], nil
+}
+
+func RemediationPolicyGets(ctx *ctx.Context, groupId int64) ([]*RemediationPolicy, error) {
+	var lst []*RemediationPolicy
+	err := DB(ctx).Where("group_id = ?", groupId).Order("name").Find(&lst).Error
+	return lst, err
+}
+
+func RemediationPolicyGetsEnabled(ctx *ctx.Context) ([]*RemediationPolicy, error) {
+	var lst []*RemediationPolicy
+	err := DB(ctx).Where("disabled = ?", 0).Find(&lst).Error
+	return lst, err
+}
+
+func RemediationPolicyStatistics(ctx *ctx.Context) (*Statistics, error) {
+	return StatisticsGet(ctx, RemediationPolicy{})
+}
+
+// RemediationRun 自愈策略的一次执行记录，包括试运行、被冷却时间或执行次数上限跳过、等待审批和实际执行，
+// 实际执行的任务结束后记录任务的输出
+type RemediationRun struct {
+	Id         int64  `json:"id" gorm:"primaryKey"`
+	PolicyId   int64  `json:"policy_id" gorm:"type:bigint;not null;default:0;index"`
+	PolicyName string `json:"policy_name" gorm:"type:varchar(255);not null;default:''"`
+	GroupId    int64  `json:"group_id" gorm:"type:bigint;not null;default:0;index"`
+	EventId    int64  `json:"event_id" gorm:"type:bigint;not null;default:0"`
+	EventHash  string `json:"event_hash" gorm:"type:varchar(64);not null;default:'';index"`
+	RuleId     int64  `json:"rule_id" gorm:"type:bigint;not null;default:0"`
+	RuleName   string `json:"rule_name" gorm:"type:varchar(255);not null;default:''"`
+	Host       string `json:"host" gorm:"type:varchar(128);not null;default:''"`
+	TaskTplId  int64  `json:"task_tpl_id" gorm:"type:bigint;not null;default:0"`
+	TaskId     int64  `json:"task_id" gorm:"type:bigint;not null;default:0"`
+	Status     string `json:"status" gorm:"type:varchar(32);not null;default:'';index"`
+	Message    string `json:"message" gorm:"type:varchar(1024);not null;default:''"`
+	Output     string `json:"output" gorm:"type:text"`
+	TokenHash  string `json:"-" gorm:"type:varchar(64);not null;default:'';index"` // 审批链接凭证的 sha256 摘要
+	Token      string `json:"-" gorm:"-"`                                          // 审批链接的凭证，只在生成审批链接时使用，不保存
+	ExpireAt   int64  `json:"expire_at" gorm:"type:bigint;not null;default:0"`
+	ApproveBy  string `json:"approve_by" gorm:"type:varchar(64);not null;default:''"`
+	ApproveAt  int64  `json:"approve_at" gorm:"type:bigint;not null;default:0"`
+	CreateAt   int64  `json:"create_at" gorm:"type:bigint;not null;default:0;index"`
+	UpdateAt   int64  `json:"update_at" gorm:"type:bigint;not null;default:0"`
+}
+
+func (r *RemediationRun) TableName() string {
+	return "remediation_run"
+}
+
+func (r *RemediationRun) Add(ctx *ctx.Context) error {
+	now := time.Now().Unix()
+	r.CreateAt = now
+	r.UpdateAt = now
+	r.Message = truncateRemediationMessage(r.Message)
+	if r.Token != "" {
+		r.TokenHash = RemediationTokenHash(r.Token)
+	}
+	return Insert(ctx, r)
+}
+
+// Transit 记录处于 from 状态时更新为 fields 中的状态，多个实例同时处理同一条记录时只有一个能更新成功
+func (r *RemediationRun) Transit(ctx *ctx.Context, from string, fields map[string]interface{}) (bool, error) {
+	if msg, has := fields["message"]; has {
+		fields["message"] = truncateRemediationMessage(fmt.Sprint(msg))
+	}
+	fields["update_at"] = time.Now().Unix()
+
+	ret := DB(ctx).Model(&RemediationRun{}).Where("id = ? and status = ?", r.Id, from).Updates(fields)
+	if ret.Error != nil {
+		return false, ret.Error
+	}
+
+	return ret.RowsAffected > 0, nil
+}
+
+// Approve 审批等待中的记录，审批通过的记录由 center 下发自愈任务
+func (r *RemediationRun) Approve(ctx *ctx.Context, approve bool, username string) error {
+	if r.Status != RemediationStatusPendingApproval {
+		return fmt.Errorf("remediation run is %s, not pending approval", r.Status)
+	}
+
+	now := time.Now().Unix()
+	if r.ExpireAt <= now {
+		return errors.New("approval expired")
+	}
+
+	status := RemediationStatusRejected
+	if approve {
+		status = RemediationStatusApproved
+	}
+
+	ok, err := r.Transit(ctx, RemediationStatusPendingApproval, map[string]interface{}{
+		"status":     status,
+		"approve_by": username,
+		"approve_at": now,
+		"message":    fmt.Sprintf("%s by %s", status, username),
+	})
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return errors.New("remediation run is not pending approval")
+	}
+
+	r.Status = status
+	return nil
+}
+
+func truncateRemediationMessage(msg string) string {
+	if len(msg) > 1024 {
+		return msg[:1024]
+	}
+	return msg
+}
+
+// RemediationRunCount 统计 since 之后计入限制的执行次数，host 为空时统计策略在所有机器上的执行次数
+func RemediationRunCount(ctx *ctx.Context, policyId int64, host string, since int64) (int64, error) {
+	session := DB(ctx).Model(&RemediationRun{}).Where("policy_id = ? and create_at >= ? and status in ?", policyId, since, RemediationCountedStatuses)
+	if host != "" {
+		session = session.Where("host = ?", host)
+	}
+
+	var count int64
+	err := session.Count(&count).Error
+	return count, err
+}
+
+func RemediationRunGetById(ctx *ctx.Context, id int64) (*RemediationRun, error) {
+	var lst []*RemediationRun
+	err := DB(ctx).Where("id = ?", id).Find(&lst).Error
+	if err != nil || len(lst) == 0 {
+		return nil, err
+	}
+	return lst[0], nil
+}
+
+// RemediationTokenHash 审批凭证与个人 token 相同，数据库中只保存 sha256 摘要
+func RemediationTokenHash(token string) string {
+	return UserTokenHash(token)
+}
+
+func RemediationRunGetByToken(ctx *ctx.Context, token string) (*RemediationRun, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	var lst []*RemediationRun
+	err := DB(ctx).Where("token_hash = ?", RemediationTokenHash(token)).Find(&lst).Error
+	if err != nil || len(lst) == 0 {
+		return nil, err
+	}
+	return lst[0], nil
+}
+
+func RemediationRunsByStatus(ctx *ctx.Context, status string, limit int) ([]*RemediationRun, error) {
+	var lst []*RemediationRun
+	err := DB(ctx).Where("status = ?", status).Order("id").Limit(limit).Find(&lst).Error
+	return lst, err
+}
+
+func RemediationRunsByEventHash(ctx *ctx.Context, hash string) ([]*RemediationRun, error) {
+	var lst []*RemediationRun
+	err := DB(ctx).Where("event_hash = ?", hash).Order("id").Find(&lst).Error
+	return lst, err
+}
+
+func RemediationRunGets(ctx *ctx.Context, groupId, policyId int64, status string, limit, offset int) ([]*RemediationRun, int64, error) {
+	session := DB(ctx).Model(&RemediationRun{}).Where("group_id = ?", groupId)
+	if policyId > 0 {
+		session = session.Where("policy_id = ?", policyId)
+	}
+
+	if status != "" {
+		session = session.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := session.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var lst []*RemediationRun
+	err := session.Order("id desc").Limit(limit).Offset(offset).Find(&lst).Error
+	return lst, total, err
+}
+
+// DeleteRemediationRuns 删除已结束的执行记录，等待审批和执行中的记录保留
+func DeleteRemediationRuns(ctx *ctx.Context, beforeTime int64) (int64, error) {
+	ret := DB(ctx).Where("create_at < ? and status not in ?", beforeTime,
+		[]string{RemediationStatusPendingApproval, RemediationStatusApproved, RemediationStatusRunning}).Delete(&RemediationRun{})
+	return ret.RowsAffected, ret.Error
+}
@@ -0,0 +1,296 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+	"github.com/toolkits/pkg/str"
+)
+
+const (
+	ReportStatusSuccess = "success"
+	ReportStatusFailed  = "failed"
+
+	DefaultReportRangeSeconds = 7 * 86400
+	DefaultReportTopN         = 10
+	MaxReportPanels           = 20
+	MaxReportPanelRows        = 100
+)
+
+// Report 定期发送的告警报告，内容包括各业务组的告警数量、最吵的规则、MTTA/MTTR 以及可选的查询结果表格
+// 通过已有的 smtp 或 http 类型通知媒介发送，使用消息模板渲染，模板中通过 $report 引用报告数据
+type Report struct {
+	Id           int64                  `json:"id" gorm:"primaryKey;type:bigint;autoIncrement"`
+	Name         string                 `json:"name" gorm:"type:varchar(255);not null;default:''"`
+	Note         string                 `json:"note" gorm:"type:varchar(1024);not null;default:''"`
+	BusiGroupIds []int64                `json:"busi_group_ids" gorm:"type:varchar(4096);serializer:json"` // 为空表示所有业务组，仅管理员可以设置
+	CronPattern  string                 `json:"cron_pattern" gorm:"type:varchar(64);not null;default:''"`
+	RangeSeconds int64                  `json:"range_seconds" gorm:"type:bigint;not null;default:0"` // 统计最近多长时间，默认 7 天
+	TopN         int                    `json:"top_n" gorm:"type:int;not null;default:0"`
+	Panels       []ReportPanel          `json:"panels" gorm:"type:text;serializer:json"`
+	ChannelId    int64                  `json:"channel_id" gorm:"type:bigint;not null;default:0"`
+	TemplateId   int64                  `json:"template_id" gorm:"type:bigint;not null;default:0"` // 0 表示使用内置模板
+	Params       map[string]interface{} `json:"params" gorm:"type:text;serializer:json"`           // 同 NotifyConfig.Params
+	Disabled     int                    `json:"disabled" gorm:"type:int;not null;default:0"`
+	LastRunAt    int64                  `json:"last_run_at" gorm:"type:bigint;not null;default:0"`
+	LastStatus   string                 `json:"last_status" gorm:"type:varchar(32);not null;default:''"`
+	LastError    string                 `json:"last_error" gorm:"type:varchar(2048);not null;default:''"`
+	CreateAt     int64                  `json:"create_at" gorm:"type:bigint;not null;default:0"`
+	CreateBy     string                 `json:"create_by" gorm:"type:varchar(64);not null;default:''"`
+	UpdateAt     int64                  `json:"update_at" gorm:"type:bigint;not null;default:0"`
+	UpdateBy     string                 `json:"update_by" gorm:"type:varchar(64);not null;default:''"`
+}
+
+// ReportPanel 报告中的查询表格，对 prometheus 类数据源做即时查询
+type ReportPanel struct {
+	Name         string `json:"name"`
+	DatasourceId int64  `json:"datasource_id"`
+	PromQl       string `json:"prom_ql"`
+	Limit        int    `json:"limit"`
+}
+
+func (r *Report) TableName() string {
+	return "report"
+}
+
+func (r *Report) Verify(ctx *ctx.Context) error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return errors.New("name is blank")
+	}
+
+	if str.Dangerous(r.Name) {
+		return errors.New("name has invalid characters")
+	}
+
+	if r.CronPattern == "" {
+		return errors.New("cron_pattern is blank")
+	}
+
+	if _, err := cron.New(cron.WithSeconds()).AddFunc(r.CronPattern, func() {}); err != nil {
+		return fmt.Errorf("invalid cron pattern: %s, error: %v", r.CronPattern, err)
+	}
+
+	if r.RangeSeconds <= 0 {
+		r.RangeSeconds = DefaultReportRangeSeconds
+	}
+
+	if r.TopN <= 0 {
+		r.TopN = DefaultReportTopN
+	}
+
+	if len(r.Panels) > MaxReportPanels {
+		return fmt.Errorf("too many panels, max: %d", MaxReportPanels)
+	}
+
+	for i := range r.Panels {
+		p := &r.Panels[i]
+		p.PromQl = strings.TrimSpace(p.PromQl)
+		if p.DatasourceId <= 0 || p.PromQl == "" {
+			return fmt.Errorf("panel %d: datasource_id and prom_ql are required", i)
+		}
+		if p.Limit <= 0 || p.Limit > MaxReportPanelRows {
+			p.Limit = MaxReportPanelRows
+		}
+	}
+
+	if r.BusiGroupIds == nil {
+		r.BusiGroupIds = []int64{}
+	}
+
+	if r.Params == nil {
+		r.Params = make(map[string]interface{})
+	}
+
+	channel, err := NotifyChannelGet(ctx, "id = ?", r.ChannelId)
+	if err != nil {
+		return err
+	}
+
+	if channel == nil {
+		return fmt.Errorf("notify channel %d not found", r.ChannelId)
+	}
+
+	if channel.RequestType != "smtp" && channel.RequestType != "http" {
+		return fmt.Errorf("notify channel %s is not supported, only smtp and http channels can send reports", channel.Name)
+	}
+
+	if r.TemplateId > 0 {
+		tpl, err := MessageTemplateGet(ctx, "id = ?", r.TemplateId)
+		if err != nil {
+			return err
+		}
+
+		if tpl == nil {
+			return fmt.Errorf("message template %d not found", r.TemplateId)
+		}
+	}
+
+	return nil
+}
+
+func (r *Report) Add(ctx *ctx.Context) error {
+	if err := r.Verify(ctx); err != nil {
+		return err
+	}
+
+	exists, err := ReportExists(ctx, 0, r.Name)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return errors.New("report already exists")
+	}
+
+	now := time.Now().Unix()
+	r.CreateAt = now
+	r.UpdateAt = now
+	return Insert(ctx, r)
+}
+
+func (r *Report) Update(ctx *ctx.Context, ref Report) error {
+	if r.Name != ref.Name {
+		exists, err := ReportExists(ctx, r.Id, ref.Name)
+		if err != nil {
+			return err
+		}
+
+		if exists {
+			return errors.New("report already exists")
+		}
+	}
+
+	ref.Id = r.Id
+	ref.CreateAt = r.CreateAt
+	ref.CreateBy = r.CreateBy
+	ref.LastRunAt = r.LastRunAt
+	ref.LastStatus = r.LastStatus
+	ref.LastError = r.LastError
+	ref.UpdateAt = time.Now().Unix()
+
+	if err := ref.Verify(ctx); err != nil {
+		return err
+	}
+
+	return DB(ctx).Model(r).Select("*").Updates(ref).Error
+}
+
+// UpdateResult 记录最近一次发送结果，不修改 update_at，避免调度器误认为配置有变化
+func (r *Report) UpdateResult(ctx *ctx.Context, runAt int64, err error) error {
+	r.LastRunAt = runAt
+	r.LastStatus = ReportStatusSuccess
+	r.LastError = ""
+	if err != nil {
+		r.LastStatus = ReportStatusFailed
+		r.LastError = err.Error()
+		if len(r.LastError) > 2048 {
+			r.LastError = r.LastError[:2048]
+		}
+	}
+
+	return DB(ctx).Model(r).Select("last_run_at", "last_status", "last_error").Updates(r).Error
+}
+
+func ReportExists(ctx *ctx.Context, id int64, name string) (bool, error) {
+	var count int64
+	err := DB(ctx).Model(&Report{}).Where("id <> ? and name = ?", id, name).Count(&count).Error
+	return count > 0, err
+}
+
+func ReportGets(ctx *ctx.Context, query string) ([]*Report, error) {
+	session := DB(ctx)
+	if query != "" {
+		q := "%" + query + "%"
+		session = session.Where("name like ? or note like ?", q, q)
+	}
+
+	var lst []*Report
+	err := session.Order("name").Find(&lst).Error
+	return lst, err
+}
+
+func ReportEnabledGets(ctx *ctx.Context) ([]*Report, error) {
+	var lst []*Report
+	err := DB(ctx).Where("disabled = 0").Find(&lst).Error
+	return lst, err
+}
+
+func ReportGetById(ctx *ctx.Context, id int64) (*Report, error) {
+	var lst []*Report
+	err := DB(ctx).Where("id = ?", id).Find(&lst).Error
+	if err != nil || len(lst) == 0 {
+		return nil, err
+	}
+	return lst[0], nil
+}
+
+func ReportDels(ctx *ctx.Context, ids []int64) error {
+	return DB(ctx).Where("id in ?", ids).Delete(&Report{}).Error
+}
+
+// ReportData 渲染报告模板使用的数据
+type ReportData struct {
+	Name       string                 `json:"name"`
+	Note       string                 `json:"note"`
+	Stime      int64                  `json:"stime"`
+	Etime      int64                  `json:"etime"`
+	Total      int64                  `json:"total"`
+	BusiGroups []*BusiGroupAlertCount `json:"busi_groups"`
+	TopRules   []*RuleAlertCount      `json:"top_rules"`
+	MTTA       *MeanDuration          `json:"mtta"`
+	MTTR       *MeanDuration          `json:"mttr"`
+	Panels     []*ReportPanelResult   `json:"panels"`
+}
+
+type ReportPanelResult struct {
+	Name    string     `json:"name"`
+	Columns []string   `json:"columns"`
+	Rows    [][]string `json:"rows"`
+	Error   string     `json:"error"`
+}
+
+// BuildReportData 统计报告中告警相关的部分，查询表格由调用方填充
+func BuildReportData(ctx *ctx.Context, r *Report, now time.Time) (*ReportData, error) {
+	q := &AlertAnalyticsQuery{
+		GroupIds: r.BusiGroupIds,
+		Stime:    now.Unix() - r.RangeSeconds,
+		Etime:    now.Unix(),
+	}
+
+	data := &ReportData{
+		Name:   r.Name,
+		Note:   r.Note,
+		Stime:  q.Stime,
+		Etime:  q.Etime,
+		Panels: []*ReportPanelResult{},
+	}
+
+	var err error
+	if data.BusiGroups, err = AlertCountsByBusiGroup(ctx, q); err != nil {
+		return nil, err
+	}
+
+	for _, bg := range data.BusiGroups {
+		data.Total += bg.Total
+	}
+
+	if data.TopRules, err = TopNoisyRules(ctx, q, r.TopN); err != nil {
+		return nil, err
+	}
+
+	if data.MTTA, err = AlertMTTA(ctx, q); err != nil {
+		return nil, err
+	}
+
+	if data.MTTR, err = AlertMTTR(ctx, q); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
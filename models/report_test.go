@@ -0,0 +1,46 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRenderReport(t *testing.T) {
+	data := &ReportData{
+		Name:  "weekly",
+		Stime: 1700000000,
+		Etime: 1700604800,
+		Total: 3,
+		BusiGroups: []*BusiGroupAlertCount{
+			{GroupId: 1, GroupName: "infra", Total: 3, Severities: map[int]int64{1: 1, 2: 2}},
+		},
+		TopRules: []*RuleAlertCount{{RuleId: 1, RuleName: "cpu high", GroupName: "infra", Count: 3}},
+		MTTA:     &MeanDuration{},
+		MTTR:     &MeanDuration{Count: 2, Seconds: 90},
+		Panels: []*ReportPanelResult{
+			{Name: "disk", Columns: []string{"ident", "value"}, Rows: [][]string{{"host-1", "80"}}},
+		},
+	}
+
+	email := DefaultReportTemplate(&NotifyChannelConfig{RequestType: "smtp"}).RenderReport(data, "http://n9e")
+	content, ok := email["content"].(string)
+	if !ok {
+		t.Fatalf("email content should be a string, got %T", email["content"])
+	}
+
+	for _, s := range []string{"<td>infra</td><td>3</td><td>1</td><td>2</td><td>0</td>", "<td>cpu high</td>", "<td>host-1</td><td>80</td>", "MTTA：-", "MTTR：1m 30s"} {
+		if !strings.Contains(content, s) {
+			t.Errorf("email content should contain %q:\n%s", s, content)
+		}
+	}
+
+	if subject, _ := email["subject"].(string); !strings.HasPrefix(subject, "[夜莺报告] weekly") {
+		t.Errorf("unexpected subject: %s", subject)
+	}
+
+	im := DefaultReportTemplate(&NotifyChannelConfig{RequestType: "http", Ident: "dingtalk"}).RenderReport(data, "")
+	if md := fmt.Sprint(im["content"]); !strings.Contains(md, `| host-1 | 80 |\n`) {
+		t.Errorf("unexpected markdown content: %s", md)
+	}
+}
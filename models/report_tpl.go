@@ -0,0 +1,129 @@
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/ccfos/nightingale/v6/pkg/tplx"
+
+	"github.com/toolkits/pkg/logger"
+)
+
+// 报告未指定消息模板时使用的内置模板，邮件使用 html，其他媒介使用 markdown
+var (
+	DefaultReportEmailTpl = map[string]string{
+		"subject": `[夜莺报告] {{$report.Name}} {{timeformat $report.Stime "2006-01-02"}} ~ {{timeformat $report.Etime "2006-01-02"}}`,
+		"content": `<html><body style="font-family: Arial, sans-serif; font-size: 14px;">
+<h2>{{$report.Name}}</h2>
+{{if $report.Note}}<p>{{$report.Note}}</p>{{end}}
+<p>统计时间：{{timeformat $report.Stime}} ~ {{timeformat $report.Etime}}</p>
+<p>告警总数：{{$report.Total}}，MTTA：{{if $report.MTTA.Count}}{{humanizeDurationInterface $report.MTTA.Seconds}}{{else}}-{{end}}，MTTR：{{if $report.MTTR.Count}}{{humanizeDurationInterface $report.MTTR.Seconds}}{{else}}-{{end}}</p>
+<h3>各业务组告警数量</h3>
+<table border="1" cellspacing="0" cellpadding="4">
+<tr><th>业务组</th><th>总数</th><th>一级</th><th>二级</th><th>三级</th></tr>
+{{range $report.BusiGroups}}<tr><td>{{.GroupName}}</td><td>{{.Total}}</td><td>{{index .Severities 1}}</td><td>{{index .Severities 2}}</td><td>{{index .Severities 3}}</td></tr>
+{{end}}</table>
+<h3>告警最多的规则</h3>
+<table border="1" cellspacing="0" cellpadding="4">
+<tr><th>规则</th><th>业务组</th><th>次数</th></tr>
+{{range $report.TopRules}}<tr><td>{{.RuleName}}</td><td>{{.GroupName}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>
+{{range $report.Panels}}<h3>{{.Name}}</h3>
+{{if .Error}}<p>查询失败：{{.Error}}</p>{{else}}<table border="1" cellspacing="0" cellpadding="4">
+<tr>{{range .Columns}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>{{end}}
+{{end}}{{if $domain}}<p><a href="{{$domain}}">{{$domain}}</a></p>{{end}}
+</body></html>`,
+	}
+
+	DefaultReportMarkdownTpl = map[string]string{
+		"title": `{{$report.Name}}`,
+		"content": `## {{$report.Name}}
+- 统计时间：{{timeformat $report.Stime}} ~ {{timeformat $report.Etime}}
+- 告警总数：{{$report.Total}}
+- MTTA：{{if $report.MTTA.Count}}{{humanizeDurationInterface $report.MTTA.Seconds}}{{else}}-{{end}}
+- MTTR：{{if $report.MTTR.Count}}{{humanizeDurationInterface $report.MTTR.Seconds}}{{else}}-{{end}}
+
+**各业务组告警数量**
+{{range $report.BusiGroups}}- {{.GroupName}}：{{.Total}}
+{{end}}
+**告警最多的规则**
+{{range $report.TopRules}}- {{.RuleName}}（{{.GroupName}}）：{{.Count}}
+{{end}}{{range $report.Panels}}
+**{{.Name}}**
+{{if .Error}}查询失败：{{.Error}}
+{{else}}| {{join .Columns " | "}} |
+{{range .Rows}}| {{join . " | "}} |
+{{end}}{{end}}{{end}}`,
+	}
+)
+
+// DefaultReportTemplate 根据通知媒介类型返回内置的报告模板
+func DefaultReportTemplate(channel *NotifyChannelConfig) *MessageTemplate {
+	if channel.RequestType == "smtp" {
+		return &MessageTemplate{Name: "report", NotifyChannelIdent: "email", Content: DefaultReportEmailTpl}
+	}
+	return &MessageTemplate{Name: "report", NotifyChannelIdent: channel.Ident, Content: DefaultReportMarkdownTpl}
+}
+
+// RenderReport 与 RenderEvent 类似，模板中通过 $report 引用报告数据
+// 邮件渲染为 html 原文，其他媒介的渲染结果需要嵌入请求体，做 json 转义
+func (t *MessageTemplate) RenderReport(data *ReportData, siteUrl string) map[string]interface{} {
+	if t == nil {
+		return nil
+	}
+
+	renderData := map[string]interface{}{
+		"report": data,
+		"domain": siteUrl,
+	}
+
+	defs := []string{
+		"{{ $report := .report }}",
+		"{{ $domain := .domain }}",
+	}
+
+	tplContent := make(map[string]interface{})
+	for key, msgTpl := range t.Content {
+		text := strings.Join(append(defs, msgTpl), "")
+
+		var body bytes.Buffer
+		if t.NotifyChannelIdent == "email" {
+			tpl, err := texttemplate.New(key).Funcs(tplx.TemplateFuncMap).Parse(text)
+			if err == nil {
+				err = tpl.Execute(&body, renderData)
+			}
+
+			if err != nil {
+				logger.Errorf("failed to render report template %s: %v", key, err)
+				tplContent[key] = fmt.Sprintf("failed to render template: %v", err)
+				continue
+			}
+
+			tplContent[key] = body.String()
+			continue
+		}
+
+		tpl, err := template.New(key).Funcs(tplx.TemplateFuncMap).Parse(text)
+		if err == nil {
+			err = tpl.Execute(&body, renderData)
+		}
+
+		if err != nil {
+			logger.Errorf("failed to render report template %s: %v", key, err)
+			tplContent[key] = fmt.Sprintf("failed to render template: %v", err)
+			continue
+		}
+
+		escaped := strings.ReplaceAll(body.String(), `"`, `\"`)
+		escaped = strings.ReplaceAll(escaped, "\n", "\\n")
+		escaped = strings.ReplaceAll(escaped, "\r", "\\r")
+		tplContent[key] = template.HTML(escaped)
+	}
+
+	return tplContent
+}
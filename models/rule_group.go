@@ -0,0 +1,232 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+	"github.com/ccfos/nightingale/v6/pkg/poster"
+
+	"github.com/pkg/errors"
+	"github.com/toolkits/pkg/str"
+)
+
+const (
+	RuleGroupMemberRecording = "recording"
+	RuleGroupMemberAlert     = "alert"
+
+	DefaultRuleGroupInterval = 60
+	MaxRuleGroupMembers      = 100
+)
+
+// RuleGroup 把记录规则和告警规则组合在一起，按相同的间隔、按 Rules 中的顺序依次执行，
+// 保证读取记录规则结果的告警规则在记录规则写入之后执行。组内规则不再按各自的 cron 单独调度，
+// 整个组作为一个单位按 DatasourceId 在 alert 实例间分片
+type RuleGroup struct {
+	Id           int64             `json:"id" gorm:"primaryKey"`
+	GroupId      int64             `json:"group_id" gorm:"type:bigint;not null;default:0;index"` // busi group id
+	Name         string            `json:"name" gorm:"type:varchar(255);not null;default:''"`
+	Note         string            `json:"note" gorm:"type:varchar(1024);not null;default:''"`
+	DatasourceId int64             `json:"datasource_id" gorm:"type:bigint;not null;default:0"` // PromQl 记录规则和告警规则在该数据源上执行
+	Interval     int64             `json:"interval" gorm:"type:bigint;not null;default:0"`      // unit: s
+	Rules        []RuleGroupMember `json:"rules" gorm:"type:text;serializer:json"`
+	Disabled     int               `json:"disabled" gorm:"type:int;not null;default:0"`
+	CreateAt     int64             `json:"create_at" gorm:"type:bigint;not null;default:0"`
+	CreateBy     string            `json:"create_by" gorm:"type:varchar(64);not null;default:''"`
+	UpdateAt     int64             `json:"update_at" gorm:"type:bigint;not null;default:0"`
+	UpdateBy     string            `json:"update_by" gorm:"type:varchar(64);not null;default:''"`
+}
+
+// RuleGroupMember 组内的一条规则，Type 为 recording 或 alert
+type RuleGroupMember struct {
+	Type string `json:"type"`
+	Id   int64  `json:"id"`
+}
+
+func (g *RuleGroup) TableName() string {
+	return "rule_group"
+}
+
+func (g *RuleGroup) Verify(ctx *ctx.Context) error {
+	g.Name = strings.TrimSpace(g.Name)
+	if g.Name == "" {
+		return errors.New("name is blank")
+	}
+
+	if str.Dangerous(g.Name) {
+		return errors.New("name has invalid characters")
+	}
+
+	if g.DatasourceId <= 0 {
+		return errors.New("datasource_id is required")
+	}
+
+	if g.Interval <= 0 {
+		g.Interval = DefaultRuleGroupInterval
+	}
+
+	if len(g.Rules) == 0 {
+		return errors.New("rules is empty")
+	}
+
+	if len(g.Rules) > MaxRuleGroupMembers {
+		return fmt.Errorf("too many rules, max: %d", MaxRuleGroupMembers)
+	}
+
+	dsLst, err := GetDatasourceInfosByIds(ctx, []int64{g.DatasourceId})
+	if err != nil {
+		return err
+	}
+	if len(dsLst) == 0 {
+		return fmt.Errorf("datasource %d not found", g.DatasourceId)
+	}
+	ds := dsLst[0]
+
+	seen := make(map[RuleGroupMember]struct{})
+	for _, m := range g.Rules {
+		if _, has := seen[m]; has {
+			return fmt.Errorf("%s rule %d is duplicated", m.Type, m.Id)
+		}
+		seen[m] = struct{}{}
+
+		var groupId int64
+		switch m.Type {
+		case RuleGroupMemberRecording:
+			rule, err := RecordingRuleGetById(ctx, m.Id)
+			if err != nil {
+				return err
+			}
+			if rule == nil {
+				return fmt.Errorf("recording rule %d not found", m.Id)
+			}
+			if strings.TrimSpace(rule.PromQl) != "" && !ruleGroupDatasourceMatched(rule.DatasourceQueries, ds) {
+				return fmt.Errorf("recording rule %d does not query datasource %s", m.Id, ds.Name)
+			}
+			groupId = rule.GroupId
+		case RuleGroupMemberAlert:
+			rule, err := AlertRuleGetById(ctx, m.Id)
+			if err != nil {
+				return err
+			}
+			if rule == nil {
+				return fmt.Errorf("alert rule %d not found", m.Id)
+			}
+			if rule.IsPrometheusRule() && !ruleGroupDatasourceMatched(rule.DatasourceQueries, ds) {
+				return fmt.Errorf("alert rule %d does not query datasource %s", m.Id, ds.Name)
+			}
+			groupId = rule.GroupId
+		default:
+			return fmt.Errorf("invalid rule type: %s", m.Type)
+		}
+
+		if groupId != g.GroupId {
+			return fmt.Errorf("%s rule %d does not belong to busi group %d", m.Type, m.Id, g.GroupId)
+		}
+	}
+
+	// 一条规则只能属于一个组，否则会被重复执行
+	lst, err := RuleGroupGets(ctx, g.GroupId)
+	if err != nil {
+		return err
+	}
+
+	for _, other := range lst {
+		if other.Id == g.Id {
+			continue
+		}
+
+		for _, m := range other.Rules {
+			if _, has := seen[m]; has {
+				return fmt.Errorf("%s rule %d already belongs to rule group %s", m.Type, m.Id, other.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ruleGroupDatasourceMatched PromQl 规则只在组的数据源上执行，规则的数据源范围不包含组的数据源时调度会跳过该规则
+func ruleGroupDatasourceMatched(queries []DatasourceQuery, ds *DatasourceInfo) bool {
+	if ds.PluginType != PROMETHEUS {
+		return false
+	}
+
+	ids := GetDatasourceIDsByDatasourceQueries(queries, map[int64]struct{}{ds.Id: {}}, map[string]int64{ds.Name: ds.Id})
+	return len(ids) > 0
+}
+
+func (g *RuleGroup) Add(ctx *ctx.Context) error {
+	if err := g.Verify(ctx); err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	g.Id = 0
+	g.CreateAt = now
+	g.UpdateAt = now
+	return Insert(ctx, g)
+}
+
+func (g *RuleGroup) Update(ctx *ctx.Context, ref RuleGroup) error {
+	ref.Id = g.Id
+	ref.GroupId = g.GroupId
+	ref.CreateAt = g.CreateAt
+	ref.CreateBy = g.CreateBy
+	ref.UpdateAt = time.Now().Unix()
+
+	if err := ref.Verify(ctx); err != nil {
+		return err
+	}
+
+	return DB(ctx).Model(g).Select("*").Updates(ref).Error
+}
+
+// MemberIds 组内指定类型规则的 id，按执行顺序排列
+func (g *RuleGroup) MemberIds(typ string) []int64 {
+	var ids []int64
+	for _, m := range g.Rules {
+		if m.Type == typ {
+			ids = append(ids, m.Id)
+		}
+	}
+	return ids
+}
+
+func RuleGroupDels(ctx *ctx.Context, ids []int64, groupId int64) error {
+	return DB(ctx).Where("id in ? and group_id = ?", ids, groupId).Delete(&RuleGroup{}).Error
+}
+
+func RuleGroupGetById(ctx *ctx.Context, id int64) (*RuleGroup, error) {
+	var lst []*RuleGroup
+	err := DB(ctx).Where("id = ?", id).Find(&lst).Error
+	if err != nil || len(lst) == 0 {
+		return nil, err
+	}
+	return lst[0], nil
+}
+
+func RuleGroupGets(ctx *ctx.Context, groupId int64) ([]*RuleGroup, error) {
+	var lst []*RuleGroup
+	err := DB(ctx).Where("group_id = ?", groupId).Order("name").Find(&lst).Error
+	return lst, err
+}
+
+// RuleGroupGetsByCluster 所有启用的规则组，边缘机房通过中心端获取
+func RuleGroupGetsByCluster(ctx *ctx.Context) ([]*RuleGroup, error) {
+	if !ctx.IsCenter {
+		return poster.GetByUrls[[]*RuleGroup](ctx, "/v1/n9e/rule-groups")
+	}
+
+	var lst []*RuleGroup
+	err := DB(ctx).Where("disabled = ?", 0).Find(&lst).Error
+	return lst, err
+}
+
+func RuleGroupStatistics(ctx *ctx.Context) (*Statistics, error) {
+	if !ctx.IsCenter {
+		return poster.GetByUrls[*Statistics](ctx, "/v1/n9e/statistic?name=rule_group")
+	}
+
+	return StatisticsGet(ctx, RuleGroup{})
+}
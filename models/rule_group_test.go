@@ -0,0 +1,31 @@
+package models
+
+import "testing"
+
+func TestRuleGroupDatasourceMatched(t *testing.T) {
+	ds := &DatasourceInfo{Id: 2, Name: "prom-bj", PluginType: PROMETHEUS}
+
+	cases := []struct {
+		name    string
+		queries []DatasourceQuery
+		want    bool
+	}{
+		{"all", []DatasourceQuery{{MatchType: 0, Op: "in", Values: []interface{}{float64(DatasourceIdAll)}}}, true},
+		{"by id", []DatasourceQuery{{MatchType: 0, Op: "in", Values: []interface{}{float64(2)}}}, true},
+		{"other id", []DatasourceQuery{{MatchType: 0, Op: "in", Values: []interface{}{float64(3)}}}, false},
+		{"excluded", []DatasourceQuery{{MatchType: 0, Op: "not in", Values: []interface{}{float64(2)}}}, false},
+		{"by name", []DatasourceQuery{{MatchType: 1, Op: "in", Values: []interface{}{"prom-*"}}}, true},
+		{"no queries", nil, false},
+	}
+
+	for _, tc := range cases {
+		if got := ruleGroupDatasourceMatched(tc.queries, ds); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	es := &DatasourceInfo{Id: 2, Name: "es", PluginType: ELASTICSEARCH}
+	if ruleGroupDatasourceMatched([]DatasourceQuery{{MatchType: 0, Op: "in", Values: []interface{}{float64(2)}}}, es) {
+		t.Errorf("non prometheus datasource should not match")
+	}
+}
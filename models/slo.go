@@ -0,0 +1,499 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/toolkits/pkg/str"
+)
+
+const (
+	SloWindowPlaceholder = "$window"
+
+	// 生成的记录规则指标名，slo_id 标签区分不同的 SLO
+	SloErrorRatioMetricPrefix = "slo:sli_error:ratio_rate"
+	SloIdLabel                = "slo_id"
+
+	MaxSloWindowDays = 90
+)
+
+// SloBurnRateTier 多窗口多燃烧率告警的一档，参考 Google SRE Workbook
+// 长窗口内消耗了 BudgetConsumed 比例的错误预算即告警，短窗口用来确认问题仍在持续，恢复得更快
+type SloBurnRateTier struct {
+	LongWindow     time.Duration `json:"long_window"`
+	ShortWindow    time.Duration `json:"short_window"`
+	BudgetConsumed float64       `json:"budget_consumed"`
+	Severity       int           `json:"severity"`
+}
+
+var SloBurnRateTiers = []SloBurnRateTier{
+	{LongWindow: time.Hour, ShortWindow: 5 * time.Minute, BudgetConsumed: 0.02, Severity: SeverityEmergency},
+	{LongWindow: 6 * time.Hour, ShortWindow: 30 * time.Minute, BudgetConsumed: 0.05, Severity: SeverityEmergency},
+	{LongWindow: 24 * time.Hour, ShortWindow: 2 * time.Hour, BudgetConsumed: 0.1, Severity: SeverityWarning},
+	{LongWindow: 72 * time.Hour, ShortWindow: 6 * time.Hour, BudgetConsumed: 0.1, Severity: SeverityNotice},
+}
+
+// Slo SLI 由 good/total 两个查询定义，查询中用 $window 表示时间窗口，例如
+// sum(rate(http_requests_total{code!~"5.."}[$window]))
+// 保存后自动生成各窗口错误率的记录规则，以及多窗口多燃烧率的告警规则
+type Slo struct {
+	Id               int64   `json:"id" gorm:"primaryKey;type:bigint;autoIncrement"`
+	GroupId          int64   `json:"group_id" gorm:"type:bigint;not null;default:0;index"`
+	Name             string  `json:"name" gorm:"type:varchar(255);not null;default:''"`
+	Note             string  `json:"note" gorm:"type:varchar(1024);not null;default:''"`
+	DatasourceId     int64   `json:"datasource_id" gorm:"type:bigint;not null;default:0"`
+	GoodQuery        string  `json:"good_query" gorm:"type:text"`
+	TotalQuery       string  `json:"total_query" gorm:"type:text"`
+	Target           float64 `json:"target" gorm:"not null;default:0"`               // 百分比，如 99.9
+	WindowDays       int     `json:"window_days" gorm:"type:int;not null;default:0"` // 滚动窗口，单位天
+	Disabled         int     `json:"disabled" gorm:"type:int;not null;default:0"`
+	NotifyRuleIds    []int64 `json:"notify_rule_ids" gorm:"type:varchar(1024);serializer:json"`
+	RecordingRuleIds []int64 `json:"recording_rule_ids" gorm:"type:varchar(1024);serializer:json"` // 自动生成，不允许修改
+	AlertRuleId      int64   `json:"alert_rule_id" gorm:"type:bigint;not null;default:0"`          // 自动生成，不允许修改
+	CreateAt         int64   `json:"create_at" gorm:"type:bigint;not null;default:0"`
+	CreateBy         string  `json:"create_by" gorm:"type:varchar(64);not null;default:''"`
+	UpdateAt         int64   `json:"update_at" gorm:"type:bigint;not null;default:0"`
+	UpdateBy         string  `json:"update_by" gorm:"type:varchar(64);not null;default:''"`
+}
+
+func (s *Slo) TableName() string {
+	return "slo"
+}
+
+func (s *Slo) Verify() error {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" {
+		return errors.New("name is blank")
+	}
+
+	if str.Dangerous(s.Name) {
+		return errors.New("name has invalid characters")
+	}
+
+	if s.DatasourceId <= 0 {
+		return errors.New("datasource_id is blank")
+	}
+
+	s.GoodQuery = strings.TrimSpace(s.GoodQuery)
+	s.TotalQuery = strings.TrimSpace(s.TotalQuery)
+	if s.GoodQuery == "" || s.TotalQuery == "" {
+		return errors.New("good_query and total_query are required")
+	}
+
+	if !strings.Contains(s.GoodQuery, SloWindowPlaceholder) || !strings.Contains(s.TotalQuery, SloWindowPlaceholder) {
+		return fmt.Errorf("good_query and total_query must use %s as range of rate or increase", SloWindowPlaceholder)
+	}
+
+	if s.Target <= 0 || s.Target >= 100 {
+		return errors.New("target must be between 0 and 100")
+	}
+
+	if s.WindowDays <= 0 || s.WindowDays > MaxSloWindowDays {
+		return fmt.Errorf("window_days must be between 1 and %d", MaxSloWindowDays)
+	}
+
+	if len(s.BurnRateTiers()) == 0 {
+		return errors.New("window_days is too short to generate burn rate alerts")
+	}
+
+	if s.NotifyRuleIds == nil {
+		s.NotifyRuleIds = []int64{}
+	}
+
+	return nil
+}
+
+// ErrorBudget 窗口内允许的错误率，如目标 99.9% 时为 0.001
+func (s *Slo) ErrorBudget() float64 {
+	return math.Round((1-s.Target/100)*1e9) / 1e9
+}
+
+func (s *Slo) Window() time.Duration {
+	return time.Duration(s.WindowDays) * 24 * time.Hour
+}
+
+// BurnRate 燃烧率为 1 表示窗口结束时恰好用完错误预算
+// 长窗口内消耗 consumed 比例的预算对应的燃烧率为 consumed * 窗口 / 长窗口
+func (s *Slo) BurnRate(tier SloBurnRateTier) float64 {
+	rate := tier.BudgetConsumed * float64(s.Window()) / float64(tier.LongWindow)
+	return math.Round(rate*1e4) / 1e4
+}
+
+// BurnRateTiers 窗口较短时部分档位的燃烧率小于 1，即使触发也不会耗尽预算，这些档位不生成告警
+func (s *Slo) BurnRateTiers() []SloBurnRateTier {
+	var tiers []SloBurnRateTier
+	for _, tier := range SloBurnRateTiers {
+		if tier.LongWindow < s.Window() && s.BurnRate(tier) >= 1 {
+			tiers = append(tiers, tier)
+		}
+	}
+	return tiers
+}
+
+// RecordingWindows 需要记录错误率的窗口，包括告警用到的长短窗口和整个 SLO 窗口
+func (s *Slo) RecordingWindows() []time.Duration {
+	seen := make(map[time.Duration]struct{})
+	var windows []time.Duration
+	add := func(d time.Duration) {
+		if _, ok := seen[d]; !ok {
+			seen[d] = struct{}{}
+			windows = append(windows, d)
+		}
+	}
+
+	for _, tier := range s.BurnRateTiers() {
+		add(tier.ShortWindow)
+		add(tier.LongWindow)
+	}
+	add(s.Window())
+
+	return windows
+}
+
+func SloWindowString(d time.Duration) string {
+	return model.Duration(d).String()
+}
+
+// ErrorRatioQuery 窗口内的错误率：1 - good / total
+func (s *Slo) ErrorRatioQuery(window time.Duration) string {
+	w := SloWindowString(window)
+	good := strings.ReplaceAll(s.GoodQuery, SloWindowPlaceholder, w)
+	total := strings.ReplaceAll(s.TotalQuery, SloWindowPlaceholder, w)
+	return fmt.Sprintf("1 - ((%s) / (%s))", good, total)
+}
+
+func SloErrorRatioMetric(window time.Duration) string {
+	return SloErrorRatioMetricPrefix + SloWindowString(window)
+}
+
+func (s *Slo) errorRatioSelector(window time.Duration) string {
+	return fmt.Sprintf("%s{%s=\"%d\"}", SloErrorRatioMetric(window), SloIdLabel, s.Id)
+}
+
+// BurnRateAlertQuery 长短窗口的错误率都超过燃烧率对应的阈值才告警
+func (s *Slo) BurnRateAlertQuery(tier SloBurnRateTier) string {
+	threshold := strconv.FormatFloat(s.BurnRate(tier)*s.ErrorBudget(), 'g', 6, 64)
+	return fmt.Sprintf("%s > %s and %s > %s",
+		s.errorRatioSelector(tier.LongWindow), threshold,
+		s.errorRatioSelector(tier.ShortWindow), threshold)
+}
+
+func (s *Slo) datasourceQueries() []DatasourceQuery {
+	return []DatasourceQuery{{MatchType: 0, Op: "in", Values: []interface{}{s.DatasourceId}}}
+}
+
+func (s *Slo) generatedNote() string {
+	return fmt.Sprintf("generated by SLO %s(%d), do not edit", s.Name, s.Id)
+}
+
+// RecordingRules 按 RecordingWindows 的顺序生成记录规则
+func (s *Slo) RecordingRules() []*RecordingRule {
+	windows := s.RecordingWindows()
+	lst := make([]*RecordingRule, 0, len(windows))
+	for _, w := range windows {
+		lst = append(lst, &RecordingRule{
+			GroupId:           s.GroupId,
+			DatasourceIdsJson: []int64{s.DatasourceId},
+			DatasourceQueries: s.datasourceQueries(),
+			Name:              SloErrorRatioMetric(w),
+			Disabled:          s.Disabled,
+			PromQl:            s.ErrorRatioQuery(w),
+			PromEvalInterval:  60,
+			CronPattern:       "@every 60s",
+			AppendTagsJSON:    []string{fmt.Sprintf("%s=%d", SloIdLabel, s.Id)},
+			Note:              s.generatedNote(),
+		})
+	}
+	return lst
+}
+
+func (s *Slo) AlertRule() *AlertRule {
+	tiers := s.BurnRateTiers()
+	queries := make([]PromQuery, 0, len(tiers))
+	for _, tier := range tiers {
+		queries = append(queries, PromQuery{
+			PromQl:   s.BurnRateAlertQuery(tier),
+			Severity: tier.Severity,
+		})
+	}
+
+	return &AlertRule{
+		GroupId:           s.GroupId,
+		Cate:              PROMETHEUS,
+		Prod:              METRIC,
+		DatasourceQueries: s.datasourceQueries(),
+		Name:              fmt.Sprintf("SLO %s error budget burn", s.Name),
+		Note:              s.generatedNote(),
+		Disabled:          s.Disabled,
+		RuleConfigJson:    PromRuleConfig{Queries: queries},
+		PromEvalInterval:  60,
+		CronPattern:       "@every 60s",
+		NotifyRecovered:   AlertRuleNotifyRecovered,
+		NotifyRepeatStep:  AlertRuleNotifyRepeatStep60Min,
+		AppendTagsJSON:    []string{fmt.Sprintf("%s=%d", SloIdLabel, s.Id)},
+		AnnotationsJSON: map[string]string{
+			"slo":          s.Name,
+			"slo_target":   strconv.FormatFloat(s.Target, 'f', -1, 64) + "%",
+			"slo_window":   fmt.Sprintf("%dd", s.WindowDays),
+			"error_budget": strconv.FormatFloat(s.ErrorBudget(), 'g', -1, 64),
+		},
+		NotifyVersion: 1,
+		NotifyRuleIds: s.NotifyRuleIds,
+	}
+}
+
+// SyncRules 根据 SLO 生成或更新记录规则和告警规则，已有的规则原地更新，保持规则 id 不变
+func (s *Slo) SyncRules(ctx *ctx.Context) (err error) {
+	var created []int64
+	defer func() {
+		if err != nil {
+			// 记下已经创建的规则，调用方回滚时一起删除
+			s.RecordingRuleIds = append(s.RecordingRuleIds, created...)
+		}
+	}()
+
+	rrs := s.RecordingRules()
+	ids := make([]int64, 0, len(rrs))
+	for i, rr := range rrs {
+		rr.CreateBy = s.UpdateBy
+		rr.UpdateBy = s.UpdateBy
+
+		var old *RecordingRule
+		if i < len(s.RecordingRuleIds) {
+			var err error
+			old, err = RecordingRuleGetById(ctx, s.RecordingRuleIds[i])
+			if err != nil {
+				return err
+			}
+		}
+
+		if old != nil {
+			if err := old.Update(ctx, *rr); err != nil {
+				return err
+			}
+			ids = append(ids, old.Id)
+			continue
+		}
+
+		rr.FE2DB()
+		if err := rr.Add(ctx); err != nil {
+			return err
+		}
+		created = append(created, rr.Id)
+		ids = append(ids, rr.Id)
+	}
+
+	// 窗口或目标变化后不再需要的记录规则
+	var stale []int64
+	for _, id := range s.RecordingRuleIds {
+		found := false
+		for _, nid := range ids {
+			if id == nid {
+				found = true
+				break
+			}
+		}
+		if !found {
+			stale = append(stale, id)
+		}
+	}
+	if err := RecordingRuleDels(ctx, stale, s.GroupId); err != nil {
+		return err
+	}
+	s.RecordingRuleIds = ids
+
+	ar := s.AlertRule()
+	ar.CreateBy = s.UpdateBy
+	ar.UpdateBy = s.UpdateBy
+
+	var old *AlertRule
+	if s.AlertRuleId > 0 {
+		var err error
+		old, err = AlertRuleGetById(ctx, s.AlertRuleId)
+		if err != nil {
+			return err
+		}
+	}
+
+	if old != nil {
+		if err := old.Update(ctx, *ar); err != nil {
+			return err
+		}
+	} else {
+		if err := ar.FE2DB(); err != nil {
+			return err
+		}
+		if err := ar.Add(ctx); err != nil {
+			return err
+		}
+		s.AlertRuleId = ar.Id
+	}
+
+	return DB(ctx).Model(s).Select("recording_rule_ids", "alert_rule_id").Updates(s).Error
+}
+
+func (s *Slo) Add(ctx *ctx.Context) error {
+	if err := s.Verify(); err != nil {
+		return err
+	}
+
+	exists, err := SloExists(ctx, 0, s.GroupId, s.Name)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return errors.New("SLO already exists")
+	}
+
+	now := time.Now().Unix()
+	s.CreateAt = now
+	s.UpdateAt = now
+	s.RecordingRuleIds = []int64{}
+
+	if err := Insert(ctx, s); err != nil {
+		return err
+	}
+
+	// 规则里需要用到 SLO 的 id，只能插入之后再生成
+	if err := s.SyncRules(ctx); err != nil {
+		s.Del(ctx)
+		return err
+	}
+
+	return nil
+}
+
+func (s *Slo) Update(ctx *ctx.Context, ref Slo) error {
+	if s.Name != ref.Name {
+		exists, err := SloExists(ctx, s.Id, s.GroupId, ref.Name)
+		if err != nil {
+			return err
+		}
+
+		if exists {
+			return errors.New("SLO already exists")
+		}
+	}
+
+	ref.Id = s.Id
+	ref.GroupId = s.GroupId
+	ref.CreateAt = s.CreateAt
+	ref.CreateBy = s.CreateBy
+	ref.RecordingRuleIds = s.RecordingRuleIds
+	ref.AlertRuleId = s.AlertRuleId
+	ref.UpdateAt = time.Now().Unix()
+
+	if err := ref.Verify(); err != nil {
+		return err
+	}
+
+	if err := DB(ctx).Model(s).Select("*").Updates(ref).Error; err != nil {
+		return err
+	}
+
+	return ref.SyncRules(ctx)
+}
+
+// Del 同时删除自动生成的规则
+func (s *Slo) Del(ctx *ctx.Context) error {
+	if err := RecordingRuleDels(ctx, s.RecordingRuleIds, s.GroupId); err != nil {
+		return err
+	}
+
+	if s.AlertRuleId > 0 {
+		if err := AlertRuleDels(ctx, []int64{s.AlertRuleId}, s.GroupId); err != nil {
+			return err
+		}
+	}
+
+	return DB(ctx).Where("id = ?", s.Id).Delete(&Slo{}).Error
+}
+
+func SloExists(ctx *ctx.Context, id, groupId int64, name string) (bool, error) {
+	var count int64
+	err := DB(ctx).Model(&Slo{}).Where("id <> ? and group_id = ? and name = ?", id, groupId, name).Count(&count).Error
+	return count > 0, err
+}
+
+func SloGets(ctx *ctx.Context, groupId int64, query string) ([]*Slo, error) {
+	session := DB(ctx).Where("group_id = ?", groupId)
+	if query != "" {
+		q := "%" + query + "%"
+		session = session.Where("name like ? or note like ?", q, q)
+	}
+
+	var lst []*Slo
+	err := session.Order("name").Find(&lst).Error
+	return lst, err
+}
+
+func SloGetById(ctx *ctx.Context, id int64) (*Slo, error) {
+	var lst []*Slo
+	err := DB(ctx).Where("id = ?", id).Find(&lst).Error
+	if err != nil || len(lst) == 0 {
+		return nil, err
+	}
+	return lst[0], nil
+}
+
+// SloBudget 错误预算的使用情况，比例都是 0-1 之间的小数，超出预算时 Remaining 为负数
+type SloBudget struct {
+	SloId      int64              `json:"slo_id"`
+	Target     float64            `json:"target"`
+	WindowDays int                `json:"window_days"`
+	Budget     float64            `json:"budget"`
+	ErrorRatio float64            `json:"error_ratio"`
+	Sli        float64            `json:"sli"` // 百分比
+	Consumed   float64            `json:"consumed"`
+	Remaining  float64            `json:"remaining"`
+	BurnRates  map[string]float64 `json:"burn_rates"` // key: 窗口，如 1h
+}
+
+// SloBudgetPoint 预算消耗历史中的一个点
+type SloBudgetPoint struct {
+	Timestamp  int64   `json:"timestamp"`
+	ErrorRatio float64 `json:"error_ratio"`
+	Consumed   float64 `json:"consumed"`
+	Remaining  float64 `json:"remaining"`
+}
+
+func (s *Slo) BudgetConsumed(errorRatio float64) float64 {
+	budget := s.ErrorBudget()
+	if budget <= 0 {
+		return 0
+	}
+	return errorRatio / budget
+}
+
+func (s *Slo) NewBudget(errorRatio float64) *SloBudget {
+	consumed := s.BudgetConsumed(errorRatio)
+	return &SloBudget{
+		SloId:      s.Id,
+		Target:     s.Target,
+		WindowDays: s.WindowDays,
+		Budget:     s.ErrorBudget(),
+		ErrorRatio: errorRatio,
+		Sli:        (1 - errorRatio) * 100,
+		Consumed:   consumed,
+		Remaining:  1 - consumed,
+		BurnRates:  make(map[string]float64),
+	}
+}
+
+func (s *Slo) NewBudgetPoint(ts int64, errorRatio float64) SloBudgetPoint {
+	consumed := s.BudgetConsumed(errorRatio)
+	return SloBudgetPoint{
+		Timestamp:  ts,
+		ErrorRatio: errorRatio,
+		Consumed:   consumed,
+		Remaining:  1 - consumed,
+	}
+}
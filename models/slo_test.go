@@ -0,0 +1,86 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSloBurnRate(t *testing.T) {
+	slo := &Slo{Id: 3, Target: 99.9, WindowDays: 30}
+
+	if budget := slo.ErrorBudget(); budget != 0.001 {
+		t.Fatalf("ErrorBudget() = %v, expected 0.001", budget)
+	}
+
+	// 30 天窗口下与 SRE Workbook 推荐的燃烧率一致
+	expected := []float64{14.4, 6, 3, 1}
+	tiers := slo.BurnRateTiers()
+	if len(tiers) != len(expected) {
+		t.Fatalf("BurnRateTiers() returns %d tiers, expected %d", len(tiers), len(expected))
+	}
+	for i, tier := range tiers {
+		if rate := slo.BurnRate(tier); rate != expected[i] {
+			t.Errorf("BurnRate(%v) = %v, expected %v", tier.LongWindow, rate, expected[i])
+		}
+	}
+
+	q := slo.BurnRateAlertQuery(tiers[0])
+	if q != `slo:sli_error:ratio_rate1h{slo_id="3"} > 0.0144 and slo:sli_error:ratio_rate5m{slo_id="3"} > 0.0144` {
+		t.Errorf("unexpected alert query: %s", q)
+	}
+
+	// 7 天窗口时 1 天和 3 天的档位燃烧率小于 1，不生成告警
+	slo.WindowDays = 7
+	if tiers := slo.BurnRateTiers(); len(tiers) != 2 {
+		t.Errorf("BurnRateTiers() returns %d tiers for 7d window, expected 2", len(tiers))
+	}
+
+	slo.WindowDays = 2
+	slo.Name = "api"
+	slo.DatasourceId = 1
+	slo.GoodQuery = `sum(rate(http_requests_total{code!~"5.."}[$window]))`
+	slo.TotalQuery = `sum(rate(http_requests_total[$window]))`
+	if err := slo.Verify(); err == nil {
+		t.Errorf("expected error for window too short")
+	}
+}
+
+func TestSloRecordingRules(t *testing.T) {
+	slo := &Slo{
+		Id:           1,
+		Name:         "api",
+		DatasourceId: 2,
+		GoodQuery:    `sum(rate(http_requests_total{code!~"5.."}[$window]))`,
+		TotalQuery:   `sum(rate(http_requests_total[$window]))`,
+		Target:       99.5,
+		WindowDays:   28,
+	}
+
+	if err := slo.Verify(); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+
+	windows := slo.RecordingWindows()
+	if windows[len(windows)-1] != 28*24*time.Hour {
+		t.Errorf("last recording window should be the slo window, got %v", windows[len(windows)-1])
+	}
+
+	rrs := slo.RecordingRules()
+	if len(rrs) != len(windows) {
+		t.Fatalf("RecordingRules() returns %d rules, expected %d", len(rrs), len(windows))
+	}
+
+	if rrs[0].Name != "slo:sli_error:ratio_rate5m" {
+		t.Errorf("unexpected recording rule name: %s", rrs[0].Name)
+	}
+
+	expected := `1 - ((sum(rate(http_requests_total{code!~"5.."}[5m]))) / (sum(rate(http_requests_total[5m]))))`
+	if rrs[0].PromQl != expected {
+		t.Errorf("unexpected recording rule promql: %s", rrs[0].PromQl)
+	}
+
+	budget := slo.NewBudget(0.0025)
+	if budget.Consumed != 0.5 || budget.Remaining != 0.5 {
+		t.Errorf("unexpected budget: %+v", budget)
+	}
+}
@@ -0,0 +1,154 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+)
+
+// Kubernetes 服务发现得到的机器，ident 为 <kind>:<cluster>/<namespace>/<name>，node 没有 namespace，为 node:<cluster>/<name>，
+// 多个集群中同名的对象不会冲突
+const (
+	K8sKindNode    = "node"
+	K8sKindPod     = "pod"
+	K8sKindService = "service"
+
+	// host_tags 中标记所属集群的标签，用于判断机器是否由服务发现维护
+	K8sClusterTagKey = "k8s_cluster"
+)
+
+var (
+	K8sKinds = []string{K8sKindNode, K8sKindPod, K8sKindService}
+
+	invalidTagKeyChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+)
+
+func K8sTargetIdent(cluster, kind, namespace, name string) string {
+	if namespace == "" {
+		return kind + ":" + cluster + "/" + name
+	}
+	return kind + ":" + cluster + "/" + namespace + "/" + name
+}
+
+// K8sTargetIdentsByLabels 根据曲线上 kube-state-metrics、cadvisor 等常见的标签推断关联的 Kubernetes 对象，
+// 集群名取自 k8s_cluster 或 cluster 标签，没有集群名时无法确定对象，不做关联
+func K8sTargetIdentsByLabels(labels map[string]string) []string {
+	cluster := labels[K8sClusterTagKey]
+	if cluster == "" {
+		cluster = labels["cluster"]
+	}
+	if cluster == "" {
+		return nil
+	}
+
+	var idents []string
+
+	namespace := labels["namespace"]
+	if namespace != "" && labels["pod"] != "" {
+		idents = append(idents, K8sTargetIdent(cluster, K8sKindPod, namespace, labels["pod"]))
+	}
+
+	if namespace != "" && labels["service"] != "" {
+		idents = append(idents, K8sTargetIdent(cluster, K8sKindService, namespace, labels["service"]))
+	}
+
+	if labels["node"] != "" {
+		idents = append(idents, K8sTargetIdent(cluster, K8sKindNode, "", labels["node"]))
+	}
+
+	return idents
+}
+
+// K8sTagKey 把 Kubernetes 的 label、annotation 名转换为合法的标签名，如 app.kubernetes.io/name 转换为 app_kubernetes_io_name
+func K8sTagKey(prefix, name string) string {
+	return prefix + invalidTagKeyChars.ReplaceAllString(name, "_")
+}
+
+// K8sTargetsGet 获取某个集群通过服务发现维护的机器
+func K8sTargetsGet(ctx *ctx.Context, cluster string) ([]*Target, error) {
+	session := DB(ctx).Model(&Target{})
+
+	var conds []string
+	var args []interface{}
+	for _, kind := range K8sKinds {
+		conds = append(conds, "ident like ?")
+		args = append(args, kind+":"+cluster+"/%")
+	}
+
+	var lst []*Target
+	err := session.Where(strings.Join(conds, " or "), args...).Find(&lst).Error
+	if err != nil {
+		return nil, err
+	}
+
+	clusterTag := K8sClusterTagKey + "=" + cluster
+	ret := make([]*Target, 0, len(lst))
+	for _, t := range lst {
+		for _, tag := range t.HostTags {
+			if tag == clusterTag {
+				ret = append(ret, t)
+				break
+			}
+		}
+	}
+
+	return ret, nil
+}
+
+// K8sTargetsSave 新增或更新服务发现得到的机器，已存在的机器只更新 host_ip、host_tags，并刷新 update_at 表示存活
+func K8sTargetsSave(ctx *ctx.Context, targets []*Target, existing map[string]*Target) error {
+	now := time.Now().Unix()
+
+	var alive []string
+	for _, t := range targets {
+		old, has := existing[t.Ident]
+		if !has {
+			t.UpdateAt = now
+			if err := DB(ctx).Create(t).Error; err != nil {
+				return err
+			}
+			continue
+		}
+
+		if old.HostIp == t.HostIp && stringSliceEqual(old.HostTags, t.HostTags) {
+			alive = append(alive, t.Ident)
+			continue
+		}
+
+		t.UpdateAt = now
+		err := DB(ctx).Model(&Target{}).Where("ident = ?", t.Ident).
+			Select("host_ip", "host_tags", "update_at").Updates(t).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := 0; i < len(alive); i += 500 {
+		end := i + 500
+		if end > len(alive) {
+			end = len(alive)
+		}
+
+		err := DB(ctx).Model(&Target{}).Where("ident in ?", alive[i:end]).Update("update_at", now).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func stringSliceEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
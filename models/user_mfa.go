@@ -0,0 +1,255 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+	"github.com/ccfos/nightingale/v6/pkg/secu"
+)
+
+const (
+	MfaPolicyConfigKey  = "mfa_policy"
+	mfaRecoveryCodeSize = 10
+	mfaSecretCipher     = "{{cipher}}"
+)
+
+// userMfaKey 加密保存 TOTP 密钥使用的密钥
+var userMfaKey = &generatedKey{ckey: USER_MFA_KEY}
+
+// UserMfa 本地账号的 TOTP 配置，Enabled 为 false 表示已生成密钥但尚未完成绑定
+// Secret 加密保存，LastCounter 为最近一次通过校验的动态码时间步，用于拒绝重放
+type UserMfa struct {
+	Id            int64    `json:"id" gorm:"primaryKey"`
+	UserId        int64    `json:"user_id" gorm:"type:bigint;not null;default:0;uniqueIndex:idx_user_mfa_user_id"`
+	Secret        string   `json:"-" gorm:"type:varchar(128);not null;default:''"`
+	LastCounter   int64    `json:"-" gorm:"type:bigint;not null;default:0"`
+	Enabled       bool     `json:"enabled" gorm:"not null;default:false"`
+	RecoveryCodes []string `json:"-" gorm:"type:text;serializer:json"` // sha256 摘要
+	CreateAt      int64    `json:"create_at" gorm:"type:bigint;not null;default:0"`
+	UpdateAt      int64    `json:"update_at" gorm:"type:bigint;not null;default:0"`
+}
+
+func (m *UserMfa) TableName() string {
+	return "user_mfa"
+}
+
+// MfaPolicy 要求哪些角色必须开启 MFA，保存在 configs 表中
+type MfaPolicy struct {
+	RequiredRoles []string `json:"required_roles"`
+}
+
+func MfaPolicyGet(ctx *ctx.Context) (*MfaPolicy, error) {
+	policy := &MfaPolicy{RequiredRoles: []string{}}
+
+	val, err := ConfigsGet(ctx, MfaPolicyConfigKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if val == "" {
+		return policy, nil
+	}
+
+	err = json.Unmarshal([]byte(val), policy)
+	return policy, err
+}
+
+func MfaPolicySet(ctx *ctx.Context, policy *MfaPolicy, username string) error {
+	bs, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+
+	return ConfigsSetWithUname(ctx, MfaPolicyConfigKey, string(bs), username)
+}
+
+// Required 用户任一角色在策略中即需要 MFA
+func (p *MfaPolicy) Required(user *User) bool {
+	for _, role := range strings.Fields(user.Roles) {
+		for _, r := range p.RequiredRoles {
+			if role == r {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func UserMfaGet(ctx *ctx.Context, userId int64) (*UserMfa, error) {
+	var lst []*UserMfa
+	err := DB(ctx).Where("user_id = ?", userId).Find(&lst).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if len(lst) == 0 {
+		return nil, nil
+	}
+
+	return lst[0], nil
+}
+
+// UserMfaPrepare 生成新的待绑定密钥，覆盖之前未完成绑定的密钥
+func UserMfaPrepare(ctx *ctx.Context, userId int64, secret string) error {
+	now := time.Now().Unix()
+	m, err := UserMfaGet(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := encryptMfaSecret(ctx, secret)
+	if err != nil {
+		return err
+	}
+
+	if m == nil {
+		return Insert(ctx, &UserMfa{
+			UserId:   userId,
+			Secret:   encrypted,
+			CreateAt: now,
+			UpdateAt: now,
+		})
+	}
+
+	return DB(ctx).Model(m).Updates(map[string]interface{}{
+		"secret":       encrypted,
+		"last_counter": 0,
+		"enabled":      false,
+		"update_at":    now,
+	}).Error
+}
+
+func encryptMfaSecret(ctx *ctx.Context, secret string) (string, error) {
+	key, err := userMfaKey.get(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return secu.DealWithEncrypt(secret, key)
+}
+
+// PlainSecret 返回解密后的 TOTP 密钥，兼容迁移前明文保存的密钥
+func (m *UserMfa) PlainSecret(ctx *ctx.Context) (string, error) {
+	if !strings.HasPrefix(m.Secret, mfaSecretCipher) {
+		return m.Secret, nil
+	}
+
+	key, err := userMfaKey.get(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return secu.DealWithDecrypt(m.Secret, key)
+}
+
+// UseCounter 记录通过校验的动态码时间步，时间步不大于上次记录的值时返回 false
+// 使用条件更新，并发提交同一个动态码时只有一个请求能成功
+func (m *UserMfa) UseCounter(ctx *ctx.Context, counter int64) (bool, error) {
+	ret := DB(ctx).Model(&UserMfa{}).Where("id = ? and last_counter < ?", m.Id, counter).Update("last_counter", counter)
+	if ret.Error != nil {
+		return false, ret.Error
+	}
+
+	if ret.RowsAffected != 1 {
+		return false, nil
+	}
+
+	m.LastCounter = counter
+	return true, nil
+}
+
+// UserMfaEncryptSecrets 历史 MFA 密钥以明文保存，迁移为加密保存
+func UserMfaEncryptSecrets(ctx *ctx.Context) error {
+	var lst []*UserMfa
+	err := DB(ctx).Where("secret <> '' and secret not like ?", mfaSecretCipher+"%").Find(&lst).Error
+	if err != nil {
+		return err
+	}
+
+	for _, m := range lst {
+		encrypted, err := encryptMfaSecret(ctx, m.Secret)
+		if err != nil {
+			return err
+		}
+
+		err = DB(ctx).Model(&UserMfa{}).Where("id = ? and secret = ?", m.Id, m.Secret).Update("secret", encrypted).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Enable 完成绑定，返回明文恢复码，数据库中只保存摘要
+func (m *UserMfa) Enable(ctx *ctx.Context) ([]string, error) {
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	m.Enabled = true
+	m.RecoveryCodes = hashes
+	m.UpdateAt = time.Now().Unix()
+
+	err = DB(ctx).Model(m).Select("enabled", "recovery_codes", "update_at").Updates(m).Error
+	return codes, err
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码，之前的恢复码全部失效
+func (m *UserMfa) RegenerateRecoveryCodes(ctx *ctx.Context) ([]string, error) {
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	m.RecoveryCodes = hashes
+	m.UpdateAt = time.Now().Unix()
+
+	err = DB(ctx).Model(m).Select("recovery_codes", "update_at").Updates(m).Error
+	return codes, err
+}
+
+// UseRecoveryCode 校验恢复码，每个恢复码只能使用一次
+func (m *UserMfa) UseRecoveryCode(ctx *ctx.Context, code string) (bool, error) {
+	hash := UserTokenHash(strings.ToLower(strings.TrimSpace(code)))
+	for i, h := range m.RecoveryCodes {
+		if h != hash {
+			continue
+		}
+
+		m.RecoveryCodes = append(m.RecoveryCodes[:i], m.RecoveryCodes[i+1:]...)
+		m.UpdateAt = time.Now().Unix()
+		err := DB(ctx).Model(m).Select("recovery_codes", "update_at").Updates(m).Error
+		return err == nil, err
+	}
+
+	return false, nil
+}
+
+func UserMfaDel(ctx *ctx.Context, userId int64) error {
+	return DB(ctx).Where("user_id = ?", userId).Delete(&UserMfa{}).Error
+}
+
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, mfaRecoveryCodeSize)
+	hashes := make([]string, 0, mfaRecoveryCodeSize)
+
+	for i := 0; i < mfaRecoveryCodeSize; i++ {
+		buf := make([]byte, 5)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+
+		code := hex.EncodeToString(buf)
+		codes = append(codes, code)
+		hashes = append(hashes, UserTokenHash(code))
+	}
+
+	return codes, hashes, nil
+}
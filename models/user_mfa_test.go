@@ -0,0 +1,75 @@
+package models
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestUserMfaSecretAndCounter(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:TestUserMfaSecretAndCounter?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AutoMigrate(&UserMfa{}, &Configs{}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := ctx.NewContext(context.Background(), db, true)
+
+	// 迁移前明文保存的密钥
+	if err := db.Create(&UserMfa{UserId: 1, Secret: "LEGACYSECRET"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := UserMfaPrepare(c, 2, "JBSWY3DPEHPK3PXP"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := UserMfaEncryptSecrets(c); err != nil {
+		t.Fatal(err)
+	}
+
+	for userId, plain := range map[int64]string{1: "LEGACYSECRET", 2: "JBSWY3DPEHPK3PXP"} {
+		m, err := UserMfaGet(c, userId)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.HasPrefix(m.Secret, mfaSecretCipher) {
+			t.Fatalf("user %d secret stored in plaintext: %s", userId, m.Secret)
+		}
+
+		got, err := m.PlainSecret(c)
+		if err != nil || got != plain {
+			t.Fatalf("user %d: want %s, got %s %v", userId, plain, got, err)
+		}
+	}
+
+	m, _ := UserMfaGet(c, 2)
+	for _, tc := range []struct {
+		counter int64
+		want    bool
+	}{{100, true}, {100, false}, {99, false}, {101, true}} {
+		ok, err := m.UseCounter(c, tc.counter)
+		if err != nil || ok != tc.want {
+			t.Fatalf("counter %d: want %v, got %v %v", tc.counter, tc.want, ok, err)
+		}
+	}
+
+	// 重新生成密钥后时间步从头计算
+	if err := UserMfaPrepare(c, 2, "JBSWY3DPEHPK3PXP"); err != nil {
+		t.Fatal(err)
+	}
+
+	m, _ = UserMfaGet(c, 2)
+	if m.LastCounter != 0 {
+		t.Fatalf("last counter should be reset, got %d", m.LastCounter)
+	}
+}
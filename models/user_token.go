@@ -1,47 +1,158 @@
 package models
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
 	"time"
 
 	"github.com/ccfos/nightingale/v6/pkg/ctx"
 )
 
+const (
+	TokenScopeReadWrite = "rw"
+	TokenScopeReadOnly  = "ro"
+)
+
+// UserToken 个人 API token，数据库中只保存 token 的 sha256 摘要，明文只在创建时返回一次
+// Token 字段保存脱敏后的前缀，用于页面展示
 type UserToken struct {
-	Id        int64  `json:"id" gorm:"primaryKey"`
-	Username  string `json:"username" gorm:"type:varchar(255); not null; default ''"`
-	TokenName string `json:"token_name" gorm:"type:varchar(255); not null; default ''"`
-	Token     string `json:"token" gorm:"type:varchar(255); not null; default ''"`
-	CreateAt  int64  `json:"create_at" gorm:"type:bigint; not null; default 0"`
-	LastUsed  int64  `json:"last_used" gorm:"type:bigint; not null; default 0"`
+	Id           int64    `json:"id" gorm:"primaryKey"`
+	Username     string   `json:"username" gorm:"type:varchar(255); not null; default ''"`
+	TokenName    string   `json:"token_name" gorm:"type:varchar(255); not null; default ''"`
+	Token        string   `json:"token" gorm:"type:varchar(255); not null; default ''"`
+	TokenHash    string   `json:"-" gorm:"type:varchar(128); not null; default ''; index:idx_user_token_hash"`
+	Scope        string   `json:"scope" gorm:"type:varchar(16); not null; default 'rw'"`
+	BusiGroupIds []int64  `json:"busi_group_ids" gorm:"type:varchar(1024);serializer:json"`
+	ApiPrefixes  []string `json:"api_prefixes" gorm:"type:varchar(1024);serializer:json"`
+	ExpireAt     int64    `json:"expire_at" gorm:"type:bigint; not null; default 0"`
+	CreateAt     int64    `json:"create_at" gorm:"type:bigint; not null; default 0"`
+	LastUsed     int64    `json:"last_used" gorm:"type:bigint; not null; default 0"`
 }
 
 func (UserToken) TableName() string {
 	return "user_token"
 }
 
+func UserTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// UserTokenMask 只保留 token 的前几位用于展示
+func UserTokenMask(token string) string {
+	if len(token) <= 8 {
+		return "****"
+	}
+	return token[:8] + "****"
+}
+
+func (t *UserToken) Verify() error {
+	if t.Scope == "" {
+		t.Scope = TokenScopeReadWrite
+	}
+
+	if t.Scope != TokenScopeReadWrite && t.Scope != TokenScopeReadOnly {
+		return errors.New("scope must be rw or ro")
+	}
+
+	if t.ExpireAt > 0 && t.ExpireAt <= time.Now().Unix() {
+		return errors.New("expire_at must be in the future")
+	}
+
+	for i := range t.ApiPrefixes {
+		t.ApiPrefixes[i] = strings.TrimSpace(t.ApiPrefixes[i])
+		if !strings.HasPrefix(t.ApiPrefixes[i], "/") {
+			return errors.New("api prefix must start with /")
+		}
+	}
+
+	return nil
+}
+
+func (t *UserToken) Expired(now int64) bool {
+	return t.ExpireAt > 0 && t.ExpireAt <= now
+}
+
+// AllowPath 未配置 api 前缀时不限制
+func (t *UserToken) AllowPath(path string) bool {
+	if len(t.ApiPrefixes) == 0 {
+		return true
+	}
+
+	for _, prefix := range t.ApiPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowBusiGroup 未配置业务组时不限制
+func (t *UserToken) AllowBusiGroup(bgid int64) bool {
+	if len(t.BusiGroupIds) == 0 {
+		return true
+	}
+
+	for _, id := range t.BusiGroupIds {
+		if id == bgid {
+			return true
+		}
+	}
+
+	return false
+}
+
 func CountToken(ctx *ctx.Context, username string) (int64, error) {
 	var count int64
 	err := DB(ctx).Model(&UserToken{}).Where("username = ?", username).Count(&count).Error
 	return count, err
 }
 
-func AddToken(ctx *ctx.Context, username, token, tokenName string) (*UserToken, error) {
+// AddToken 保存 token 的摘要，返回的对象中 Token 为明文，仅用于创建时展示给用户
+func AddToken(ctx *ctx.Context, username, token string, f UserToken) (*UserToken, error) {
+	if err := f.Verify(); err != nil {
+		return nil, err
+	}
+
 	newToken := UserToken{
-		TokenName: tokenName,
-		Username:  username,
-		Token:     token,
-		CreateAt:  time.Now().Unix(),
+		TokenName:    f.TokenName,
+		Username:     username,
+		Token:        UserTokenMask(token),
+		TokenHash:    UserTokenHash(token),
+		Scope:        f.Scope,
+		BusiGroupIds: f.BusiGroupIds,
+		ApiPrefixes:  f.ApiPrefixes,
+		ExpireAt:     f.ExpireAt,
+		CreateAt:     time.Now().Unix(),
 	}
 
 	err := Insert(ctx, &newToken)
+	newToken.Token = token
 	return &newToken, err
 }
 
-func DeleteToken(ctx *ctx.Context, id int64) error {
-	err := DB(ctx).Where("id = ?", id).Delete(&UserToken{}).Error
+func DeleteToken(ctx *ctx.Context, username string, id int64) error {
+	err := DB(ctx).Where("id = ? and username = ?", id, username).Delete(&UserToken{}).Error
 	return err
 }
 
+func UserTokenGet(ctx *ctx.Context, username string, id int64) (*UserToken, error) {
+	var lst []*UserToken
+	err := DB(ctx).Where("id = ? and username = ?", id, username).Find(&lst).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if len(lst) == 0 {
+		return nil, nil
+	}
+
+	return lst[0], nil
+}
+
 func GetTokensByUsername(ctx *ctx.Context, username string) ([]UserToken, error) {
 	var tokens []UserToken
 	err := DB(ctx).Where("username = ?", username).Find(&tokens).Error
@@ -54,12 +165,17 @@ func UserTokenGetAll(ctx *ctx.Context) ([]*UserToken, error) {
 	return lst, err
 }
 
-func UserTokenTotal(ctx *ctx.Context) (int64, error) {
-	var total int64
-	err := DB(ctx).Model(&UserToken{}).Count(&total).Error
-	return total, err
+// UserTokenStatistics 数量和最大 id 任一变化都说明有 token 被创建或删除
+func UserTokenStatistics(ctx *ctx.Context) (*Statistics, error) {
+	var stats []*Statistics
+	err := DB(ctx).Model(&UserToken{}).Select("count(*) as total", "max(id) as last_updated").Find(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return stats[0], nil
 }
 
-func UserTokenUpdateLastUsedTime(ctx *ctx.Context, token string, lastUsedTime int64) error {
-	return DB(ctx).Model(&UserToken{}).Where("token = ?", token).Update("last_used", lastUsedTime).Error
+func UserTokenUpdateLastUsedTime(ctx *ctx.Context, tokenHash string, lastUsedTime int64) error {
+	return DB(ctx).Model(&UserToken{}).Where("token_hash = ?", tokenHash).Update("last_used", lastUsedTime).Error
 }
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUserTokenRestrictions(t *testing.T) {
+	token := &UserToken{
+		Scope:        TokenScopeReadOnly,
+		BusiGroupIds: []int64{1, 2},
+		ApiPrefixes:  []string{"/api/n9e/busi-group/"},
+		ExpireAt:     time.Now().Unix() + 3600,
+	}
+
+	if err := token.Verify(); err != nil {
+		t.Fatalf("unexpected verify error: %v", err)
+	}
+
+	if !token.AllowPath("/api/n9e/busi-group/1/alert-rules") || token.AllowPath("/api/n9e/users") {
+		t.Fatalf("unexpected api prefix check result")
+	}
+
+	if !token.AllowBusiGroup(2) || token.AllowBusiGroup(3) {
+		t.Fatalf("unexpected busi group check result")
+	}
+
+	if token.Expired(time.Now().Unix()) || !token.Expired(token.ExpireAt) {
+		t.Fatalf("unexpected expire check result")
+	}
+
+	if UserTokenHash("abc") == "abc" || UserTokenMask("0123456789") != "01234567****" {
+		t.Fatalf("unexpected hash or mask result")
+	}
+
+	bad := &UserToken{Scope: "admin"}
+	if bad.Verify() == nil {
+		t.Fatalf("expect invalid scope error")
+	}
+}
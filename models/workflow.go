@@ -52,16 +52,47 @@ type NodeOutput struct {
 	// 流式输出支持
 	Stream     bool              `json:"stream,omitempty"` // 是否流式输出
 	StreamChan chan *StreamChunk `json:"-"`                // 流式数据通道（不序列化）
+
+	// 循环节点（foreach）的输出，由引擎对每个元素执行输出 0 连接的子图
+	Loop *LoopOutput `json:"-"`
+
+	// 等待节点（delay）的输出，由引擎暂停工作流，到期后从该节点之后继续执行
+	Wait *WaitOutput `json:"-"`
+}
+
+// WaitOutput 等待节点的输出
+type WaitOutput struct {
+	Seconds int64 // 等待时长
+	Recheck bool  // 到期后是否检查事件仍在告警，已恢复的告警事件不再继续执行
+}
+
+// 循环节点的错误处理方式
+const (
+	LoopErrorModeContinue = "continue"  // 某次迭代失败后继续执行其余迭代（默认）
+	LoopErrorModeFailFast = "fail_fast" // 某次迭代失败后不再启动新的迭代
+)
+
+// LoopOutput 循环节点的输出
+// 输出 0 连接的节点组成循环体，每个元素执行一次；全部迭代结束后走输出 1
+type LoopOutput struct {
+	Items       []interface{} // 迭代的元素
+	ItemVar     string        // 元素在 Vars 中的变量名，迭代序号保存在 Vars[ItemVar+"_index"]
+	Concurrency int           // 同时执行的迭代数量
+	ErrorMode   string        // continue 或 fail_fast
 }
 
 // WorkflowResult 工作流执行结果
 type WorkflowResult struct {
 	Event       *AlertCurEvent         `json:"event"`        // 最终事件
-	Status      string                 `json:"status"`       // success, failed, streaming
+	Status      string                 `json:"status"`       // success, failed, streaming, waiting
 	Message     string                 `json:"message"`      // 汇总消息
 	NodeResults []*NodeExecutionResult `json:"node_results"` // 各节点执行结果
 	ErrorNode   string                 `json:"error_node,omitempty"`
 
+	// 在等待节点暂停时的等待信息
+	WaitNode string      `json:"wait_node,omitempty"`
+	Wait     *WaitOutput `json:"-"`
+
 	// 流式输出支持
 	Stream     bool              `json:"stream,omitempty"` // 是否流式输出
 	StreamChan chan *StreamChunk `json:"-"`                // 流式数据通道（不序列化）
@@ -79,6 +110,18 @@ type NodeExecutionResult struct {
 	DurationMs  int64  `json:"duration_ms"`
 	Error       string `json:"error,omitempty"`
 	BranchIndex *int   `json:"branch_index,omitempty"` // 条件节点的分支选择
+
+	Iterations []*IterationResult `json:"iterations,omitempty"` // 循环节点每次迭代的执行结果
+}
+
+// IterationResult 循环节点一次迭代的执行结果
+type IterationResult struct {
+	Index       int                    `json:"index"`
+	Item        interface{}            `json:"item"`
+	Status      string                 `json:"status"` // success, failed, skipped
+	Message     string                 `json:"message,omitempty"`
+	DurationMs  int64                  `json:"duration_ms"`
+	NodeResults []*NodeExecutionResult `json:"node_results"`
 }
 
 // 触发模式常量
@@ -110,6 +153,14 @@ type WorkflowTriggerContext struct {
 	// 流式输出（API 调用时动态指定）
 	Stream bool `json:"stream"`
 
+	// 通知规则的事件处理流程支持在等待节点暂停，恢复后继续执行剩余的事件处理流程并发送通知
+	Resumable     bool  `json:"resumable,omitempty"`
+	NotifyRuleId  int64 `json:"notify_rule_id,omitempty"`
+	PipelineIndex int   `json:"pipeline_index,omitempty"` // 当前事件处理流程在通知规则中的位置
+
+	// 页面上试运行
+	TryRun bool `json:"tryrun,omitempty"`
+
 	// Cron 相关（后续使用）
 	CronJobID   string `json:"cron_job_id,omitempty"`
 	CronExpr    string `json:"cron_expr,omitempty"`
@@ -128,6 +179,11 @@ type WorkflowContext struct {
 	StreamChan chan *StreamChunk `json:"-"` // 流式数据通道（不序列化）
 }
 
+// IsTryRun 页面上试运行时不应修改限流计数等运行时状态
+func (ctx *WorkflowContext) IsTryRun() bool {
+	return ctx.Metadata["tryrun"] == "true"
+}
+
 // SanitizedEnv 返回脱敏后的环境变量（用于日志和存储）
 func (ctx *WorkflowContext) SanitizedEnv(secretKeys map[string]bool) map[string]string {
 	sanitized := make(map[string]string)
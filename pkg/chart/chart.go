@@ -0,0 +1,341 @@
+// Package chart 使用纯 Go 绘制简单的时序折线图，不依赖浏览器，用于在告警通知中附带曲线截图
+package chart
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	DefaultWidth  = 600
+	DefaultHeight = 300
+
+	// 图例最多展示的曲线数量，超出部分只画线不展示图例
+	maxLegend = 5
+
+	yTicks = 5
+	xTicks = 4
+)
+
+var (
+	background = color.RGBA{255, 255, 255, 255}
+	axisColor  = color.RGBA{160, 160, 160, 255}
+	gridColor  = color.RGBA{235, 235, 235, 255}
+	textColor  = color.RGBA{60, 60, 60, 255}
+
+	palette = []color.RGBA{
+		{99, 71, 255, 255},
+		{245, 106, 0, 255},
+		{24, 160, 88, 255},
+		{230, 50, 80, 255},
+		{23, 145, 230, 255},
+		{160, 90, 200, 255},
+		{200, 160, 0, 255},
+		{0, 170, 170, 255},
+	}
+
+	face = basicfont.Face7x13
+)
+
+type Point struct {
+	T int64 // unix 秒
+	V float64
+}
+
+type Series struct {
+	Name   string
+	Points []Point
+}
+
+type Options struct {
+	Width    int
+	Height   int
+	Title    string
+	Location *time.Location // 时间轴标签使用的时区，默认 time.Local
+}
+
+// Render 把曲线绘制为 PNG，NaN 和 Inf 的点会断开曲线
+func Render(series []Series, opt Options) ([]byte, error) {
+	if opt.Width <= 0 {
+		opt.Width = DefaultWidth
+	}
+	if opt.Height <= 0 {
+		opt.Height = DefaultHeight
+	}
+	if opt.Location == nil {
+		opt.Location = time.Local
+	}
+
+	tmin, tmax, vmin, vmax, ok := bounds(series)
+	if !ok {
+		return nil, errors.New("no data points")
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, opt.Width, opt.Height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{background}, image.Point{}, draw.Src)
+
+	lineHeight := face.Metrics().Height.Ceil()
+
+	legendRows := len(series)
+	if legendRows > maxLegend {
+		legendRows = maxLegend
+	}
+
+	top := 8
+	if opt.Title != "" {
+		drawText(img, 8, top+lineHeight-3, truncate(opt.Title, (opt.Width-16)/face.Advance), textColor)
+		top += lineHeight + 4
+	}
+
+	// 左侧留出 y 轴标签的宽度
+	labels := make([]string, yTicks+1)
+	labelWidth := 0
+	for i := 0; i <= yTicks; i++ {
+		labels[i] = FormatValue(vmin + (vmax-vmin)*float64(i)/yTicks)
+		if w := len(labels[i]) * face.Advance; w > labelWidth {
+			labelWidth = w
+		}
+	}
+
+	plot := image.Rect(labelWidth+14, top+4, opt.Width-12, opt.Height-lineHeight-8-legendRows*lineHeight)
+	if plot.Dx() < 20 || plot.Dy() < 20 {
+		return nil, fmt.Errorf("image size %dx%d is too small", opt.Width, opt.Height)
+	}
+
+	x := func(t int64) int {
+		if tmax == tmin {
+			return plot.Min.X + plot.Dx()/2
+		}
+		return plot.Min.X + int(float64(t-tmin)/float64(tmax-tmin)*float64(plot.Dx()-1))
+	}
+	y := func(v float64) int {
+		return plot.Max.Y - 1 - int((v-vmin)/(vmax-vmin)*float64(plot.Dy()-1))
+	}
+
+	// 网格和坐标轴标签
+	for i := 0; i <= yTicks; i++ {
+		py := plot.Max.Y - 1 - i*(plot.Dy()-1)/yTicks
+		hline(img, plot.Min.X, plot.Max.X, py, gridColor)
+		drawText(img, plot.Min.X-6-len(labels[i])*face.Advance, py+4, labels[i], textColor)
+	}
+
+	layout := "15:04"
+	if tmax-tmin > 86400 {
+		layout = "01-02 15:04"
+	}
+	for i := 0; i <= xTicks; i++ {
+		t := tmin + (tmax-tmin)*int64(i)/xTicks
+		px := x(t)
+		vline(img, px, plot.Min.Y, plot.Max.Y, gridColor)
+
+		label := time.Unix(t, 0).In(opt.Location).Format(layout)
+		lx := px - len(label)*face.Advance/2
+		if lx < 0 {
+			lx = 0
+		}
+		if lx+len(label)*face.Advance > opt.Width {
+			lx = opt.Width - len(label)*face.Advance
+		}
+		drawText(img, lx, plot.Max.Y+lineHeight, label, textColor)
+	}
+
+	hline(img, plot.Min.X, plot.Max.X, plot.Max.Y-1, axisColor)
+	vline(img, plot.Min.X, plot.Min.Y, plot.Max.Y, axisColor)
+
+	// 曲线
+	for i, s := range series {
+		c := palette[i%len(palette)]
+		prevX, prevY, connected := 0, 0, false
+		for _, p := range s.Points {
+			if math.IsNaN(p.V) || math.IsInf(p.V, 0) {
+				connected = false
+				continue
+			}
+
+			px, py := x(p.T), y(p.V)
+			if connected {
+				line(img, prevX, prevY, px, py, c)
+			} else {
+				thickPixel(img, px, py, c)
+			}
+			prevX, prevY, connected = px, py, true
+		}
+	}
+
+	// 图例
+	for i := 0; i < legendRows; i++ {
+		ly := plot.Max.Y + lineHeight + 4 + (i+1)*lineHeight
+		c := palette[i%len(palette)]
+		draw.Draw(img, image.Rect(8, ly-8, 18, ly-2), &image.Uniform{c}, image.Point{}, draw.Src)
+
+		name := series[i].Name
+		if i == maxLegend-1 && len(series) > maxLegend {
+			name = fmt.Sprintf("... and %d more series", len(series)-maxLegend+1)
+		}
+		drawText(img, 24, ly, truncate(name, (opt.Width-32)/face.Advance), textColor)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func bounds(series []Series) (tmin, tmax int64, vmin, vmax float64, ok bool) {
+	tmin, tmax = math.MaxInt64, math.MinInt64
+	vmin, vmax = math.Inf(1), math.Inf(-1)
+
+	for _, s := range series {
+		for _, p := range s.Points {
+			if math.IsNaN(p.V) || math.IsInf(p.V, 0) {
+				continue
+			}
+			ok = true
+			if p.T < tmin {
+				tmin = p.T
+			}
+			if p.T > tmax {
+				tmax = p.T
+			}
+			vmin = math.Min(vmin, p.V)
+			vmax = math.Max(vmax, p.V)
+		}
+	}
+
+	if !ok {
+		return
+	}
+
+	// 曲线是一条直线时上下各留出一些空间
+	if vmin == vmax {
+		delta := math.Abs(vmin) * 0.1
+		if delta == 0 {
+			delta = 1
+		}
+		vmin, vmax = vmin-delta, vmax+delta
+	}
+
+	// 非负的指标 y 轴从 0 开始，避免放大很小的波动
+	if vmin > 0 && vmin < (vmax-vmin) {
+		vmin = 0
+	}
+
+	return
+}
+
+// FormatValue 把数值格式化为较短的字符串，如 1.5K、2.3M
+func FormatValue(v float64) string {
+	abs := math.Abs(v)
+	switch {
+	case abs >= 1e12:
+		return trimZero(v/1e12) + "T"
+	case abs >= 1e9:
+		return trimZero(v/1e9) + "G"
+	case abs >= 1e6:
+		return trimZero(v/1e6) + "M"
+	case abs >= 1e4:
+		return trimZero(v/1e3) + "K"
+	case abs == 0:
+		return "0"
+	case abs < 0.001:
+		return fmt.Sprintf("%.1e", v)
+	}
+	return trimZero(v)
+}
+
+func trimZero(v float64) string {
+	s := fmt.Sprintf("%.2f", v)
+	for s[len(s)-1] == '0' {
+		s = s[:len(s)-1]
+	}
+	if s[len(s)-1] == '.' {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if n <= 3 || len(r) <= n {
+		return s
+	}
+	return string(r[:n-3]) + "..."
+}
+
+func drawText(img *image.RGBA, x, y int, s string, c color.Color) {
+	d := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(s)
+}
+
+func hline(img *image.RGBA, x1, x2, y int, c color.RGBA) {
+	for x := x1; x < x2; x++ {
+		img.SetRGBA(x, y, c)
+	}
+}
+
+func vline(img *image.RGBA, x, y1, y2 int, c color.RGBA) {
+	for y := y1; y < y2; y++ {
+		img.SetRGBA(x, y, c)
+	}
+}
+
+func thickPixel(img *image.RGBA, x, y int, c color.RGBA) {
+	img.SetRGBA(x, y, c)
+	img.SetRGBA(x+1, y, c)
+	img.SetRGBA(x, y+1, c)
+	img.SetRGBA(x+1, y+1, c)
+}
+
+// line Bresenham 画线，线宽 2 像素
+func line(img *image.RGBA, x0, y0, x1, y1 int, c color.RGBA) {
+	dx := abs(x1 - x0)
+	dy := -abs(y1 - y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+
+	err := dx + dy
+	for {
+		thickPixel(img, x0, y0, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,71 @@
+package chart
+
+import (
+	"bytes"
+	"image/png"
+	"math"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	var series []Series
+	for i := 0; i < 7; i++ {
+		s := Series{Name: "instance=10.0.0.1:9100"}
+		for j := 0; j < 60; j++ {
+			v := float64(i*10) + math.Sin(float64(j)/5)*5
+			if j == 30 {
+				v = math.NaN()
+			}
+			s.Points = append(s.Points, Point{T: 1700000000 + int64(j)*60, V: v})
+		}
+		series = append(series, s)
+	}
+
+	data, err := Render(series, Options{Width: 400, Height: 240, Title: "cpu_usage_active > 80"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if img.Bounds().Dx() != 400 || img.Bounds().Dy() != 240 {
+		t.Errorf("unexpected image size: %v", img.Bounds())
+	}
+
+	if _, err := Render([]Series{{Points: []Point{{T: 1, V: math.NaN()}}}}, Options{}); err == nil {
+		t.Errorf("expected error when there is no data point")
+	}
+
+	if _, err := Render(series, Options{Width: 40, Height: 40}); err == nil {
+		t.Errorf("expected error when image is too small")
+	}
+
+	// 只有一个点时也能绘制
+	if _, err := Render([]Series{{Points: []Point{{T: 1, V: 3}}}}, Options{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	cases := map[float64]string{
+		0:          "0",
+		0.5:        "0.5",
+		12.345:     "12.35",
+		1000:       "1000",
+		15000:      "15K",
+		2500000:    "2.5M",
+		-3200000:   "-3.2M",
+		0.00012:    "1.2e-04",
+		1.5e9:      "1.5G",
+		4000000000: "4G",
+	}
+
+	for v, expected := range cases {
+		if got := FormatValue(v); got != expected {
+			t.Errorf("FormatValue(%v) = %s, expected %s", v, got, expected)
+		}
+	}
+}
@@ -0,0 +1,989 @@
+package grafana
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	boardVersion = "3.0.0"
+
+	cateProm  = "prometheus"
+	cateES    = "elasticsearch"
+	cateMySQL = "mysql"
+)
+
+// Board 转换结果，Configs 可以直接作为 board payload 保存，Warnings 记录没有转换或者需要手动调整的内容
+type Board struct {
+	Name     string        `json:"name"`
+	Tags     string        `json:"tags"`
+	Configs  *BoardConfigs `json:"configs"`
+	Warnings []string      `json:"warnings"`
+}
+
+type BoardConfigs struct {
+	Version string   `json:"version"`
+	Var     []*Var   `json:"var"`
+	Panels  []*Panel `json:"panels"`
+	Links   []*Link  `json:"links"`
+}
+
+type Var struct {
+	Name       string         `json:"name"`
+	Label      string         `json:"label"`
+	Type       string         `json:"type"`
+	Definition string         `json:"definition"`
+	Datasource *VarDatasource `json:"datasource,omitempty"`
+	Reg        string         `json:"reg,omitempty"`
+	Multi      bool           `json:"multi"`
+	AllOption  bool           `json:"allOption"`
+	AllValue   string         `json:"allValue,omitempty"`
+	Hide       bool           `json:"hide"`
+}
+
+type VarDatasource struct {
+	Cate  string `json:"cate"`
+	Value string `json:"value"`
+}
+
+type Link struct {
+	Title       string `json:"title"`
+	Url         string `json:"url"`
+	TargetBlank bool   `json:"targetBlank"`
+}
+
+type Layout struct {
+	H           int    `json:"h"`
+	W           int    `json:"w"`
+	X           int    `json:"x"`
+	Y           int    `json:"y"`
+	I           string `json:"i"`
+	IsResizable bool   `json:"isResizable"`
+}
+
+type Panel struct {
+	Type            string                   `json:"type"`
+	Id              string                   `json:"id"`
+	Name            string                   `json:"name"`
+	Description     string                   `json:"description,omitempty"`
+	Version         string                   `json:"version,omitempty"`
+	Layout          Layout                   `json:"layout"`
+	Collapsed       bool                     `json:"collapsed,omitempty"`
+	DatasourceCate  string                   `json:"datasourceCate,omitempty"`
+	DatasourceValue string                   `json:"datasourceValue,omitempty"`
+	Targets         []map[string]interface{} `json:"targets,omitempty"`
+	Custom          map[string]interface{}   `json:"custom,omitempty"`
+	Options         map[string]interface{}   `json:"options,omitempty"`
+	Links           []*Link                  `json:"links,omitempty"`
+	MaxPerRow       int                      `json:"maxPerRow,omitempty"`
+	Panels          []*Panel                 `json:"panels,omitempty"`
+}
+
+// panelTypes Grafana 面板类型与夜莺面板类型的对应关系
+var panelTypes = map[string]string{
+	"timeseries": "timeseries",
+	"graph":      "timeseries",
+	"stat":       "stat",
+	"singlestat": "stat",
+	"gauge":      "gauge",
+	"table":      "table",
+	"table-old":  "table",
+	"bargauge":   "barGauge",
+	"text":       "text",
+	"row":        "row",
+	"piechart":   "pie",
+}
+
+// units Grafana 单位与夜莺单位的对应关系，没有的按 none 处理并记录 warning
+var units = map[string]string{
+	"":                           "none",
+	"none":                       "none",
+	"short":                      "none",
+	"percent":                    "percent",
+	"percentunit":                "percentUnit",
+	"bytes":                      "bytesIEC",
+	"decbytes":                   "bytesSI",
+	"bits":                       "bitsIEC",
+	"decbits":                    "bitsSI",
+	"binBps":                     "bytesSecIEC",
+	"Bps":                        "bytesSecSI",
+	"binbps":                     "bitsSecIEC",
+	"bps":                        "bitsSecSI",
+	"s":                          "seconds",
+	"ms":                         "milliseconds",
+	"dtdurations":                "humantimeSeconds",
+	"dtdurationms":               "humantimeMilliseconds",
+	"dateTimeAsIso":              "datetimeMilliseconds",
+	"dateTimeAsSystem":           "datetimeMilliseconds",
+	"dateTimeFromNow":            "datetimeMilliseconds",
+	"iops":                       "iops",
+	"ops":                        "ops",
+	"reqps":                      "reqps",
+	"pps":                        "packetsSec",
+	"ns":                         "nanoseconds",
+	"µs":                         "microseconds",
+	"m":                          "min",
+	"h":                          "h",
+	"d":                          "d",
+	"locale":                     "none",
+	"sishort":                    "sishort",
+	"dateTimeAsIsoNoDateIfToday": "datetimeMilliseconds",
+}
+
+// calcs Grafana reduceOptions.calcs 与夜莺 calc 的对应关系
+var calcs = map[string]string{
+	"lastNotNull":  "lastNotNull",
+	"last":         "last",
+	"firstNotNull": "first",
+	"first":        "first",
+	"mean":         "avg",
+	"min":          "min",
+	"max":          "max",
+	"sum":          "sum",
+	"count":        "count",
+}
+
+// datasourceCates Grafana 数据源插件与夜莺数据源类型的对应关系
+var datasourceCates = map[string]string{
+	"prometheus":                       cateProm,
+	"elasticsearch":                    cateES,
+	"mysql":                            cateMySQL,
+	"grafana-mysql-datasource":         cateMySQL,
+	"grafana-elasticsearch-datasource": cateES,
+}
+
+type converter struct {
+	board    *Board
+	vars     map[string]*Var
+	inputs   map[string]string // __inputs 中的数据源占位符，value 为插件类型
+	defaults map[string]string // 数据源类型对应的数据源变量名
+}
+
+// Convert 把 Grafana dashboard json 转换为夜莺仪表盘，支持直接导出的 json 和 HTTP API 返回的 {dashboard: ...} 格式
+func Convert(data []byte) (*Board, error) {
+	var d gDashboard
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, fmt.Errorf("invalid grafana dashboard json: %v", err)
+	}
+
+	if d.Dashboard != nil {
+		d = *d.Dashboard
+	}
+
+	if d.Title == "" && len(d.Panels) == 0 && len(d.Rows) == 0 {
+		return nil, fmt.Errorf("invalid grafana dashboard json: no title and panels")
+	}
+
+	c := &converter{
+		board: &Board{
+			Name:     d.Title,
+			Tags:     strings.Join(d.Tags, " "),
+			Configs:  &BoardConfigs{Version: boardVersion, Var: []*Var{}, Panels: []*Panel{}, Links: []*Link{}},
+			Warnings: []string{},
+		},
+		vars:     make(map[string]*Var),
+		inputs:   make(map[string]string),
+		defaults: make(map[string]string),
+	}
+
+	for _, in := range d.Inputs {
+		if in.Type == "datasource" {
+			c.inputs[in.Name] = in.PluginId
+		}
+	}
+
+	for _, l := range d.Links {
+		if l.Url == "" {
+			c.warnf("dashboard link %q is not converted, only absolute links are supported", l.Title)
+			continue
+		}
+		c.board.Configs.Links = append(c.board.Configs.Links, &Link{Title: l.Title, Url: l.Url, TargetBlank: l.TargetBlank})
+	}
+
+	// 先转换变量，面板中引用数据源变量时需要知道变量类型
+	for _, v := range d.Templating.List {
+		c.convertVariable(v)
+	}
+
+	panels := d.Panels
+	if len(panels) == 0 && len(d.Rows) > 0 {
+		panels = legacyRowsToPanels(d.Rows)
+	}
+
+	for _, p := range panels {
+		if np := c.convertPanel(p); np != nil {
+			c.board.Configs.Panels = append(c.board.Configs.Panels, np)
+		}
+	}
+
+	if d.Annotation != nil {
+		for _, a := range d.Annotation.List {
+			if a.BuiltIn == 0 {
+				c.warnf("annotation %q is not converted", a.Name)
+			}
+		}
+	}
+
+	return c.board, nil
+}
+
+func (c *converter) warnf(format string, args ...interface{}) {
+	c.board.Warnings = append(c.board.Warnings, fmt.Sprintf(format, args...))
+}
+
+func panelDesc(p *gPanel) string {
+	return fmt.Sprintf("panel %q (id %d)", p.Title, p.Id)
+}
+
+// legacyRowsToPanels 旧版 dashboard 以 rows 组织，宽度按 12 栅格计算，这里换算成 24 栅格的 gridPos
+func legacyRowsToPanels(rows []*gRow) []*gPanel {
+	var panels []*gPanel
+	y := 0
+	for _, row := range rows {
+		h := 8
+		if px, err := strconv.Atoi(strings.TrimSuffix(row.Height, "px")); err == nil && px > 0 {
+			h = int(math.Ceil(float64(px) / 30))
+		}
+
+		if row.ShowTitle || row.Collapse {
+			panels = append(panels, &gPanel{Type: "row", Title: row.Title, Collapsed: row.Collapse, GridPos: gGridPos{H: 1, W: 24, Y: y}})
+			y++
+		}
+
+		x := 0
+		for _, p := range row.Panels {
+			w := int(p.Span * 2)
+			if w <= 0 || w > 24 {
+				w = 24
+			}
+			if x+w > 24 {
+				x = 0
+				y += h
+			}
+			p.GridPos = gGridPos{H: h, W: w, X: x, Y: y}
+			x += w
+			panels = append(panels, p)
+		}
+		y += h
+	}
+	return panels
+}
+
+func (c *converter) convertVariable(v *gVariable) {
+	nv := &Var{
+		Name:      v.Name,
+		Label:     v.Label,
+		Multi:     v.Multi,
+		AllOption: v.IncludeAll,
+		AllValue:  v.AllValue,
+		Hide:      v.Hide == 2,
+	}
+
+	query := rawQueryString(v.Query)
+	switch v.Type {
+	case "query":
+		nv.Type = "query"
+		nv.Definition = query
+		if nv.Definition == "" {
+			nv.Definition = v.Definition
+		}
+		nv.Reg = v.Regex
+
+		typ, ref := parseDatasource(v.Datasource)
+		cate, value := c.resolveDatasource(typ, ref, fmt.Sprintf("variable %q", v.Name))
+		if cate == "" {
+			return
+		}
+		if cate != cateProm {
+			c.warnf("variable %q: query of %s datasource is kept as is, please check it manually", v.Name, cate)
+		}
+		nv.Datasource = &VarDatasource{Cate: cate, Value: value}
+	case "custom":
+		nv.Type = "custom"
+		nv.Definition = query
+	case "interval":
+		// 夜莺没有 interval 类型的变量，转换为 custom，auto 选项不支持
+		nv.Type = "custom"
+		var opts []string
+		for _, s := range strings.Split(query, ",") {
+			s = strings.TrimSpace(s)
+			if s != "" && s != "auto" {
+				opts = append(opts, s)
+			}
+		}
+		nv.Definition = strings.Join(opts, ",")
+		if len(opts) != len(strings.Split(query, ",")) {
+			c.warnf("variable %q: interval variable is converted to custom variable, auto option is removed", v.Name)
+		}
+	case "datasource":
+		nv.Type = "datasource"
+		nv.Reg = v.Regex
+		cate, has := datasourceCates[query]
+		if !has {
+			c.warnf("variable %q: datasource type %q is not supported", v.Name, query)
+			return
+		}
+		nv.Definition = cate
+	case "constant":
+		nv.Type = "constant"
+		nv.Definition = query
+	case "textbox":
+		nv.Type = "textbox"
+		nv.Definition = query
+	default:
+		c.warnf("variable %q: type %q is not supported", v.Name, v.Type)
+		return
+	}
+
+	c.addVar(nv)
+}
+
+func (c *converter) addVar(v *Var) {
+	if _, has := c.vars[v.Name]; has {
+		return
+	}
+	c.vars[v.Name] = v
+	c.board.Configs.Var = append(c.board.Configs.Var, v)
+}
+
+// rawQueryString 变量的 query 字段在新版本中是 {query: "..."} 对象
+func rawQueryString(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+
+	var obj struct {
+		Query string `json:"query"`
+	}
+	if json.Unmarshal(raw, &obj) == nil {
+		return obj.Query
+	}
+
+	return ""
+}
+
+// resolveDatasource 返回夜莺的数据源类型以及数据源变量引用
+// 夜莺仪表盘中面板通过数据源变量选择数据源，Grafana 中写死的数据源会转换为对应类型的数据源变量
+func (c *converter) resolveDatasource(typ, ref, desc string) (string, string) {
+	if name, ok := variableRef(ref); ok {
+		if v, has := c.vars[name]; has && v.Type == "datasource" {
+			return v.Definition, "${" + name + "}"
+		}
+
+		if plugin, has := c.inputs[name]; has {
+			typ = plugin
+		}
+
+		cate, has := datasourceCates[typ]
+		if !has {
+			c.warnf("%s: datasource %s of type %q is not supported", desc, ref, typ)
+			return "", ""
+		}
+
+		c.addVar(&Var{Name: name, Type: "datasource", Definition: cate})
+		return cate, "${" + name + "}"
+	}
+
+	if typ == "" {
+		// 只写了数据源名称或者使用默认数据源时无法知道类型，按 prometheus 处理
+		typ = cateProm
+		if ref != "" && ref != "default" {
+			c.warnf("%s: type of datasource %q is unknown, assumed to be prometheus", desc, ref)
+		}
+	}
+
+	cate, has := datasourceCates[typ]
+	if !has {
+		c.warnf("%s: datasource type %q is not supported", desc, typ)
+		return "", ""
+	}
+
+	name, has := c.defaults[cate]
+	if !has {
+		for _, v := range c.board.Configs.Var {
+			if v.Type == "datasource" && v.Definition == cate {
+				name = v.Name
+				break
+			}
+		}
+
+		if name == "" {
+			name = "DS_" + strings.ToUpper(cate)
+			c.addVar(&Var{Name: name, Label: cate, Type: "datasource", Definition: cate})
+		}
+		c.defaults[cate] = name
+	}
+
+	return cate, "${" + name + "}"
+}
+
+func (c *converter) convertPanel(p *gPanel) *Panel {
+	typ, has := panelTypes[p.Type]
+	if !has {
+		c.warnf("%s: panel type %q is not supported", panelDesc(p), p.Type)
+		return nil
+	}
+
+	id := uuid.New().String()
+	np := &Panel{
+		Type:        typ,
+		Id:          id,
+		Name:        p.Title,
+		Description: p.Description,
+		Version:     boardVersion,
+		Layout: Layout{
+			H:           p.GridPos.H,
+			W:           p.GridPos.W,
+			X:           p.GridPos.X,
+			Y:           p.GridPos.Y,
+			I:           id,
+			IsResizable: typ != "row",
+		},
+	}
+
+	if np.Layout.W == 0 {
+		np.Layout.W = 12
+	}
+	if np.Layout.H == 0 {
+		np.Layout.H = 8
+	}
+
+	for _, l := range p.Links {
+		np.Links = append(np.Links, &Link{Title: l.Title, Url: l.Url, TargetBlank: l.TargetBlank})
+	}
+
+	if p.Repeat != "" {
+		c.warnf("%s: repeat by variable %q is not supported", panelDesc(p), p.Repeat)
+	}
+
+	if len(p.Transforms) > 0 {
+		c.warnf("%s: transformations are not converted", panelDesc(p))
+	}
+
+	if len(p.FieldConfig.Overrides) > 0 {
+		c.warnf("%s: field overrides are not converted", panelDesc(p))
+	}
+
+	switch typ {
+	case "row":
+		np.Collapsed = p.Collapsed
+		np.Layout.H = 1
+		np.Layout.W = 24
+		np.Layout.X = 0
+		np.Panels = []*Panel{}
+		for _, sub := range p.Panels {
+			if sp := c.convertPanel(sub); sp != nil {
+				np.Panels = append(np.Panels, sp)
+			}
+		}
+		return np
+	case "text":
+		c.convertText(p, np)
+		return np
+	}
+
+	if p.Type == "graph" || p.Type == "singlestat" || p.Type == "table-old" {
+		c.warnf("%s: legacy %s panel is converted to %s, some options may be lost", panelDesc(p), p.Type, typ)
+	}
+
+	typ2, ref := parseDatasource(p.Datasource)
+	if typ2 == "" && ref == "" && len(p.Targets) > 0 {
+		if raw, err := json.Marshal(p.Targets[0]["datasource"]); err == nil {
+			typ2, ref = parseDatasource(raw)
+		}
+	}
+
+	if ref == "-- Mixed --" || typ2 == "datasource" {
+		c.warnf("%s: mixed datasources are not supported, the datasource of the first query is used", panelDesc(p))
+		typ2, ref = "", ""
+		if len(p.Targets) > 0 {
+			if raw, err := json.Marshal(p.Targets[0]["datasource"]); err == nil {
+				typ2, ref = parseDatasource(raw)
+			}
+		}
+	}
+
+	cate, value := c.resolveDatasource(typ2, ref, panelDesc(p))
+	if cate == "" {
+		return nil
+	}
+
+	np.DatasourceCate = cate
+	np.DatasourceValue = value
+	np.MaxPerRow = 4
+	np.Targets = c.convertTargets(p, cate)
+	np.Options = c.standardOptions(p)
+	np.Custom = c.customOptions(p, typ)
+
+	return np
+}
+
+func (c *converter) convertText(p *gPanel, np *Panel) {
+	content := p.Content
+	mode := p.Mode
+	if v, ok := p.Options["content"].(string); ok {
+		content = v
+	}
+	if v, ok := p.Options["mode"].(string); ok {
+		mode = v
+	}
+
+	if mode == "html" {
+		c.warnf("%s: html content is kept as is, the text panel renders markdown", panelDesc(p))
+	}
+
+	np.Custom = map[string]interface{}{
+		"textSize":       12,
+		"justifyContent": "flexStart",
+		"alignItems":     "flexStart",
+		"content":        content,
+	}
+}
+
+func (c *converter) convertTargets(p *gPanel, cate string) []map[string]interface{} {
+	targets := make([]map[string]interface{}, 0, len(p.Targets))
+	for i, t := range p.Targets {
+		refId, _ := t["refId"].(string)
+		if refId == "" {
+			refId = string(rune('A' + i%26))
+		}
+
+		var nt map[string]interface{}
+		switch cate {
+		case cateProm:
+			nt = c.promTarget(p, t)
+		case cateES:
+			nt = c.esTarget(p, t)
+		case cateMySQL:
+			nt = c.mysqlTarget(p, t)
+		}
+
+		if nt == nil {
+			continue
+		}
+
+		nt["refId"] = refId
+		if hide, _ := t["hide"].(bool); hide {
+			nt["hide"] = true
+		}
+		targets = append(targets, nt)
+	}
+	return targets
+}
+
+func (c *converter) promTarget(p *gPanel, t map[string]interface{}) map[string]interface{} {
+	expr, _ := t["expr"].(string)
+	if expr == "" {
+		c.warnf("%s: query without expr is skipped", panelDesc(p))
+		return nil
+	}
+
+	legend, _ := t["legendFormat"].(string)
+	if legend == "__auto" {
+		legend = ""
+	}
+
+	nt := map[string]interface{}{
+		"expr":   expr,
+		"legend": legend,
+	}
+
+	instant, _ := t["instant"].(bool)
+	if format, _ := t["format"].(string); format == "table" {
+		instant = true
+	}
+	if instant {
+		nt["instant"] = true
+	}
+
+	if interval, _ := t["interval"].(string); interval != "" {
+		if d, err := time.ParseDuration(interval); err == nil && d >= time.Second {
+			nt["step"] = int64(d.Seconds())
+		}
+	}
+
+	return nt
+}
+
+// esMetricFuncs Grafana elasticsearch 指标聚合与夜莺的对应关系
+var esMetricFuncs = map[string]string{
+	"count":       "count",
+	"avg":         "avg",
+	"sum":         "sum",
+	"max":         "max",
+	"min":         "min",
+	"cardinality": "unique_count",
+}
+
+func (c *converter) esTarget(p *gPanel, t map[string]interface{}) map[string]interface{} {
+	query := map[string]interface{}{
+		"index":      "",
+		"filter":     t["query"],
+		"date_field": t["timeField"],
+		"values":     []map[string]interface{}{},
+		"group_by":   []map[string]interface{}{},
+	}
+
+	if query["date_field"] == nil {
+		query["date_field"] = "@timestamp"
+	}
+
+	values := []map[string]interface{}{}
+	metrics, _ := t["metrics"].([]interface{})
+	for _, m := range metrics {
+		mm, _ := m.(map[string]interface{})
+		typ, _ := mm["type"].(string)
+		fn, has := esMetricFuncs[typ]
+		if !has {
+			c.warnf("%s: elasticsearch metric %q is not supported", panelDesc(p), typ)
+			continue
+		}
+
+		v := map[string]interface{}{"func": fn}
+		if field, ok := mm["field"].(string); ok && field != "" {
+			v["field"] = field
+		}
+		values = append(values, v)
+	}
+	if len(values) == 0 {
+		values = append(values, map[string]interface{}{"func": "count"})
+	}
+	query["values"] = values
+
+	groupBy := []map[string]interface{}{}
+	aggs, _ := t["bucketAggs"].([]interface{})
+	for _, a := range aggs {
+		am, _ := a.(map[string]interface{})
+		typ, _ := am["type"].(string)
+		field, _ := am["field"].(string)
+		settings, _ := am["settings"].(map[string]interface{})
+
+		switch typ {
+		case "date_histogram":
+			if field != "" {
+				query["date_field"] = field
+			}
+			interval, _ := settings["interval"].(string)
+			if d, err := time.ParseDuration(interval); err == nil && d >= time.Second {
+				query["interval"] = int64(d.Seconds())
+			}
+		case "terms":
+			g := map[string]interface{}{"cate": "terms", "field": field}
+			if size, err := strconv.Atoi(fmt.Sprint(settings["size"])); err == nil && size > 0 {
+				g["size"] = size
+			}
+			groupBy = append(groupBy, g)
+		default:
+			c.warnf("%s: elasticsearch bucket aggregation %q is not supported", panelDesc(p), typ)
+		}
+	}
+	query["group_by"] = groupBy
+
+	c.warnf("%s: index of elasticsearch query is not included in grafana dashboards, please fill it manually", panelDesc(p))
+	return map[string]interface{}{"query": query}
+}
+
+func (c *converter) mysqlTarget(p *gPanel, t map[string]interface{}) map[string]interface{} {
+	sql, _ := t["rawSql"].(string)
+	if sql == "" {
+		c.warnf("%s: mysql query built by query builder is not supported, only raw sql is converted", panelDesc(p))
+		return nil
+	}
+
+	keys := map[string]interface{}{"valueKey": "value", "labelKey": "metric", "timeKey": "time"}
+	if format, _ := t["format"].(string); format == "table" {
+		keys = map[string]interface{}{"valueKey": "", "labelKey": "", "timeKey": ""}
+		c.warnf("%s: mysql query in table format needs valueKey and labelKey, please fill them manually", panelDesc(p))
+	}
+
+	return map[string]interface{}{
+		"sql":  sql,
+		"keys": keys,
+	}
+}
+
+func (c *converter) standardOptions(p *gPanel) map[string]interface{} {
+	defaults := p.FieldConfig.Defaults
+
+	unit := defaults.Unit
+	if unit == "" && len(p.Yaxes) > 0 {
+		unit = p.Yaxes[0].Format
+	}
+	if unit == "" {
+		unit = p.Format
+	}
+
+	util, has := units[unit]
+	if !has {
+		c.warnf("%s: unit %q is not supported, converted to none", panelDesc(p), unit)
+		util = "none"
+	}
+
+	standard := map[string]interface{}{"util": util}
+	if defaults.Decimals != nil {
+		standard["decimals"] = *defaults.Decimals
+	}
+	if defaults.Min != nil {
+		standard["min"] = *defaults.Min
+	}
+	if defaults.Max != nil {
+		standard["max"] = *defaults.Max
+	}
+
+	options := map[string]interface{}{
+		"standardOptions": standard,
+		"valueMappings":   c.valueMappings(p),
+	}
+
+	if th := defaults.Thresholds; th != nil && len(th.Steps) > 0 {
+		steps := make([]map[string]interface{}, 0, len(th.Steps))
+		for _, s := range th.Steps {
+			step := map[string]interface{}{"color": s.Color, "value": s.Value, "type": ""}
+			if s.Value == nil {
+				step["type"] = "base"
+			}
+			steps = append(steps, step)
+		}
+
+		mode := th.Mode
+		if mode == "" {
+			mode = "absolute"
+		}
+		options["thresholds"] = map[string]interface{}{"mode": mode, "steps": steps}
+	}
+
+	if p.Type == "timeseries" || p.Type == "graph" {
+		legend := map[string]interface{}{"displayMode": "list"}
+		tooltip := map[string]interface{}{"mode": "all", "sort": "none"}
+		if l, ok := p.Options["legend"].(map[string]interface{}); ok {
+			if show, ok := l["showLegend"].(bool); ok && !show {
+				legend["displayMode"] = "hidden"
+			} else if mode, _ := l["displayMode"].(string); mode == "table" || mode == "hidden" {
+				legend["displayMode"] = mode
+			}
+		}
+		if t, ok := p.Options["tooltip"].(map[string]interface{}); ok {
+			if mode, _ := t["mode"].(string); mode == "single" {
+				tooltip["mode"] = "single"
+			}
+			if sort, _ := t["sort"].(string); sort == "asc" || sort == "desc" {
+				tooltip["sort"] = sort
+			}
+		}
+		options["legend"] = legend
+		options["tooltip"] = tooltip
+	}
+
+	return options
+}
+
+// valueMappings 只支持值映射和范围映射
+func (c *converter) valueMappings(p *gPanel) []map[string]interface{} {
+	lst := []map[string]interface{}{}
+	for _, m := range p.FieldConfig.Defaults.Mappings {
+		mm, _ := m.(map[string]interface{})
+		typ, _ := mm["type"].(string)
+		options, _ := mm["options"].(map[string]interface{})
+
+		switch typ {
+		case "value":
+			keys := make([]string, 0, len(options))
+			for k := range options {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+
+			for _, k := range keys {
+				result, _ := options[k].(map[string]interface{})
+				v, err := strconv.ParseFloat(k, 64)
+				if err != nil {
+					c.warnf("%s: value mapping of non-numeric value %q is not supported", panelDesc(p), k)
+					continue
+				}
+				lst = append(lst, map[string]interface{}{
+					"type":   "special",
+					"match":  map[string]interface{}{"special": v},
+					"result": mappingResult(result),
+				})
+			}
+		case "range":
+			result, _ := options["result"].(map[string]interface{})
+			match := map[string]interface{}{}
+			if from, ok := options["from"].(float64); ok {
+				match["from"] = from
+			}
+			if to, ok := options["to"].(float64); ok {
+				match["to"] = to
+			}
+			lst = append(lst, map[string]interface{}{
+				"type":   "range",
+				"match":  match,
+				"result": mappingResult(result),
+			})
+		default:
+			c.warnf("%s: value mapping of type %q is not supported", panelDesc(p), typ)
+		}
+	}
+	return lst
+}
+
+func mappingResult(result map[string]interface{}) map[string]interface{} {
+	ret := map[string]interface{}{}
+	if text, ok := result["text"].(string); ok {
+		ret["text"] = text
+	}
+	if color, ok := result["color"].(string); ok {
+		ret["color"] = color
+	}
+	return ret
+}
+
+func (c *converter) calc(p *gPanel) string {
+	reduce, _ := p.Options["reduceOptions"].(map[string]interface{})
+	lst, _ := reduce["calcs"].([]interface{})
+	if len(lst) == 0 {
+		return "lastNotNull"
+	}
+
+	name, _ := lst[0].(string)
+	if calc, has := calcs[name]; has {
+		return calc
+	}
+
+	c.warnf("%s: calculation %q is not supported, converted to lastNotNull", panelDesc(p), name)
+	return "lastNotNull"
+}
+
+func (c *converter) customOptions(p *gPanel, typ string) map[string]interface{} {
+	switch typ {
+	case "timeseries":
+		custom := map[string]interface{}{
+			"drawStyle":         "lines",
+			"lineInterpolation": "smooth",
+			"spanNulls":         false,
+			"lineWidth":         1,
+			"fillOpacity":       0,
+			"gradientMode":      "none",
+			"stack":             "off",
+			"scaleDistribution": map[string]interface{}{"type": "linear"},
+			"showPoints":        "none",
+			"pointSize":         5,
+		}
+
+		gc := p.FieldConfig.Defaults.Custom
+		if p.Type == "graph" {
+			if p.Bars {
+				custom["drawStyle"] = "bars"
+			}
+			if p.Stack {
+				// 夜莺前端使用 noraml 表示堆叠
+				custom["stack"] = "noraml"
+			}
+			return custom
+		}
+
+		if v, ok := gc["drawStyle"].(string); ok && (v == "lines" || v == "bars" || v == "points") {
+			custom["drawStyle"] = v
+		}
+		if v, ok := gc["lineInterpolation"].(string); ok && v != "smooth" {
+			custom["lineInterpolation"] = "linear"
+		}
+		if v, ok := gc["lineWidth"].(float64); ok {
+			custom["lineWidth"] = v
+		}
+		if v, ok := gc["fillOpacity"].(float64); ok {
+			custom["fillOpacity"] = v / 100
+		}
+		if v, ok := gc["spanNulls"].(bool); ok {
+			custom["spanNulls"] = v
+		}
+		if stacking, ok := gc["stacking"].(map[string]interface{}); ok {
+			if mode, _ := stacking["mode"].(string); mode == "normal" || mode == "percent" {
+				custom["stack"] = "noraml"
+				if mode == "percent" {
+					c.warnf("%s: percent stacking is converted to normal stacking", panelDesc(p))
+				}
+			}
+		}
+		return custom
+	case "stat":
+		textMode := "value"
+		if v, _ := p.Options["textMode"].(string); v == "value_and_name" {
+			textMode = "valueAndName"
+		} else if v == "name" {
+			textMode = "name"
+		}
+
+		colorMode := "value"
+		if v, _ := p.Options["colorMode"].(string); strings.HasPrefix(v, "background") {
+			colorMode = "background"
+		}
+
+		graphMode := "none"
+		if v, _ := p.Options["graphMode"].(string); v == "area" {
+			graphMode = "area"
+		}
+
+		return map[string]interface{}{
+			"textMode":    textMode,
+			"colorMode":   colorMode,
+			"graphMode":   graphMode,
+			"calc":        c.calc(p),
+			"valueField":  "Value",
+			"colSpan":     1,
+			"textSize":    map[string]interface{}{},
+			"orientation": "auto",
+		}
+	case "gauge":
+		return map[string]interface{}{
+			"textMode": "value",
+			"calc":     c.calc(p),
+		}
+	case "barGauge":
+		return map[string]interface{}{
+			"calc": c.calc(p),
+		}
+	case "pie":
+		donut := false
+		if v, _ := p.Options["pieType"].(string); v == "donut" {
+			donut = true
+		}
+		return map[string]interface{}{
+			"calc":  c.calc(p),
+			"donut": donut,
+		}
+	case "table":
+		displayMode := "seriesToRows"
+		for _, t := range p.Targets {
+			if format, _ := t["format"].(string); format == "table" {
+				displayMode = "labelValuesToRows"
+				break
+			}
+		}
+
+		showHeader := true
+		if v, ok := p.Options["showHeader"].(bool); ok {
+			showHeader = v
+		}
+
+		return map[string]interface{}{
+			"showHeader":  showHeader,
+			"colorMode":   "value",
+			"calc":        c.calc(p),
+			"displayMode": displayMode,
+		}
+	}
+	return map[string]interface{}{}
+}
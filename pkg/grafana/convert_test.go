@@ -0,0 +1,139 @@
+package grafana
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	data, err := os.ReadFile("testdata/node.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	board, err := Convert(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if board.Name != "Node Exporter" || board.Tags != "linux node" {
+		t.Errorf("unexpected name or tags: %s, %s", board.Name, board.Tags)
+	}
+
+	vars := make(map[string]*Var)
+	for _, v := range board.Configs.Var {
+		vars[v.Name] = v
+	}
+
+	if v := vars["DS_PROMETHEUS"]; v == nil || v.Type != "datasource" || v.Definition != "prometheus" {
+		t.Errorf("datasource input should be converted to datasource variable: %+v", v)
+	}
+
+	if v := vars["job"]; v == nil || v.Definition != "label_values(node_uname_info, job)" || v.Datasource.Value != "${DS_PROMETHEUS}" || !v.AllOption {
+		t.Errorf("unexpected query variable: %+v", v)
+	}
+
+	if v := vars["interval"]; v == nil || v.Type != "custom" || v.Definition != "1m,5m,10m" {
+		t.Errorf("unexpected interval variable: %+v", v)
+	}
+
+	if v := vars["env"]; v == nil || !v.Hide {
+		t.Errorf("unexpected custom variable: %+v", v)
+	}
+
+	if _, has := vars["filter"]; has {
+		t.Errorf("adhoc variable should be skipped")
+	}
+
+	// 写死的 elasticsearch 和 mysql 数据源会生成对应的数据源变量
+	if v := vars["DS_ELASTICSEARCH"]; v == nil || v.Definition != "elasticsearch" {
+		t.Errorf("elasticsearch datasource variable should be added: %+v", v)
+	}
+
+	panels := board.Configs.Panels
+	if len(panels) != 5 {
+		t.Fatalf("expected 5 panels, got %d", len(panels))
+	}
+
+	cpu := panels[0]
+	if cpu.Type != "timeseries" || cpu.DatasourceCate != "prometheus" || cpu.DatasourceValue != "${DS_PROMETHEUS}" {
+		t.Errorf("unexpected cpu panel: %+v", cpu)
+	}
+	if cpu.Layout.W != 12 || cpu.Layout.H != 8 || cpu.Layout.I != cpu.Id {
+		t.Errorf("unexpected cpu layout: %+v", cpu.Layout)
+	}
+	if cpu.Targets[0]["legend"] != "{{instance}}" || cpu.Targets[0]["step"] != int64(30) {
+		t.Errorf("unexpected cpu target: %+v", cpu.Targets[0])
+	}
+	if cpu.Options["standardOptions"].(map[string]interface{})["util"] != "percentUnit" {
+		t.Errorf("unexpected cpu unit: %+v", cpu.Options["standardOptions"])
+	}
+	if steps := cpu.Options["thresholds"].(map[string]interface{})["steps"].([]map[string]interface{}); len(steps) != 2 || steps[0]["type"] != "base" {
+		t.Errorf("unexpected cpu thresholds: %+v", steps)
+	}
+	if cpu.Custom["stack"] != "noraml" || cpu.Custom["fillOpacity"] != 0.1 {
+		t.Errorf("unexpected cpu custom options: %+v", cpu.Custom)
+	}
+	if cpu.Options["legend"].(map[string]interface{})["displayMode"] != "table" {
+		t.Errorf("unexpected cpu legend: %+v", cpu.Options["legend"])
+	}
+
+	uptime := panels[1]
+	if uptime.Custom["calc"] != "avg" || uptime.Custom["textMode"] != "valueAndName" || uptime.Custom["colorMode"] != "background" {
+		t.Errorf("unexpected stat custom options: %+v", uptime.Custom)
+	}
+	if mappings := uptime.Options["valueMappings"].([]map[string]interface{}); len(mappings) != 1 || mappings[0]["type"] != "special" {
+		t.Errorf("unexpected value mappings: %+v", mappings)
+	}
+
+	row := panels[2]
+	if row.Type != "row" || !row.Collapsed || len(row.Panels) != 1 {
+		t.Fatalf("unexpected row: %+v", row)
+	}
+
+	errors := row.Panels[0]
+	query := errors.Targets[0]["query"].(map[string]interface{})
+	if errors.DatasourceCate != "elasticsearch" || query["filter"] != "level:error" || query["date_field"] != "ts" || query["interval"] != int64(60) {
+		t.Errorf("unexpected elasticsearch target: %+v", errors.Targets[0])
+	}
+
+	if panels[3].Type != "text" || panels[3].Custom["content"] != "# Hello" {
+		t.Errorf("unexpected text panel: %+v", panels[3])
+	}
+
+	conn := panels[4]
+	if conn.Type != "gauge" || conn.DatasourceCate != "mysql" || !strings.HasPrefix(conn.Targets[0]["sql"].(string), "SELECT") {
+		t.Errorf("unexpected mysql panel: %+v", conn)
+	}
+
+	warnings := strings.Join(board.Warnings, "\n")
+	for _, s := range []string{`"filter": type "adhoc"`, `panel "Latency" (id 6): panel type "heatmap"`, `unit "flops"`, "index of elasticsearch", `annotation "deploys"`, "auto option"} {
+		if !strings.Contains(warnings, s) {
+			t.Errorf("warnings should contain %q:\n%s", s, warnings)
+		}
+	}
+}
+
+func TestConvertInvalid(t *testing.T) {
+	if _, err := Convert([]byte(`{"foo": 1}`)); err == nil {
+		t.Errorf("expected error for non grafana json")
+	}
+
+	if _, err := Convert([]byte(`[`)); err == nil {
+		t.Errorf("expected error for invalid json")
+	}
+}
+
+func TestVariableRef(t *testing.T) {
+	cases := map[string]string{"$ds": "ds", "${ds}": "ds", "${ds:raw}": "ds", "[[ds]]": "ds"}
+	for s, expected := range cases {
+		if name, ok := variableRef(s); !ok || name != expected {
+			t.Errorf("variableRef(%q) = %q, %v", s, name, ok)
+		}
+	}
+
+	if _, ok := variableRef("prometheus-1"); ok {
+		t.Errorf("plain datasource name should not be a variable")
+	}
+}
@@ -0,0 +1,163 @@
+package grafana
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// 以下为转换用到的 Grafana dashboard json 字段，不同版本的字段类型不完全一致，变化较大的字段使用 json.RawMessage
+
+type gDashboard struct {
+	Title      string        `json:"title"`
+	Tags       []string      `json:"tags"`
+	Panels     []*gPanel     `json:"panels"`
+	Rows       []*gRow       `json:"rows"` // schemaVersion < 16 的旧版布局
+	Templating gTemplating   `json:"templating"`
+	Links      []gLink       `json:"links"`
+	Dashboard  *gDashboard   `json:"dashboard"` // 通过 HTTP API 导出时外层还有一层
+	Inputs     []gInput      `json:"__inputs"`
+	Annotation *gAnnotations `json:"annotations"`
+}
+
+type gInput struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	PluginId string `json:"pluginId"`
+}
+
+type gAnnotations struct {
+	List []struct {
+		BuiltIn int    `json:"builtIn"`
+		Name    string `json:"name"`
+	} `json:"list"`
+}
+
+type gRow struct {
+	Title     string    `json:"title"`
+	Collapse  bool      `json:"collapse"`
+	Height    string    `json:"height"`
+	Panels    []*gPanel `json:"panels"`
+	ShowTitle bool      `json:"showTitle"`
+}
+
+type gLink struct {
+	Title       string `json:"title"`
+	Url         string `json:"url"`
+	TargetBlank bool   `json:"targetBlank"`
+}
+
+type gTemplating struct {
+	List []*gVariable `json:"list"`
+}
+
+type gVariable struct {
+	Name       string          `json:"name"`
+	Label      string          `json:"label"`
+	Type       string          `json:"type"`
+	Query      json.RawMessage `json:"query"`
+	Definition string          `json:"definition"`
+	Datasource json.RawMessage `json:"datasource"`
+	Multi      bool            `json:"multi"`
+	IncludeAll bool            `json:"includeAll"`
+	AllValue   string          `json:"allValue"`
+	Hide       int             `json:"hide"`
+	Regex      string          `json:"regex"`
+}
+
+type gGridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type gPanel struct {
+	Id          int                      `json:"id"`
+	Type        string                   `json:"type"`
+	Title       string                   `json:"title"`
+	Description string                   `json:"description"`
+	GridPos     gGridPos                 `json:"gridPos"`
+	Span        float64                  `json:"span"` // 旧版布局中的宽度，总宽 12
+	Datasource  json.RawMessage          `json:"datasource"`
+	Targets     []map[string]interface{} `json:"targets"`
+	FieldConfig gFieldConfig             `json:"fieldConfig"`
+	Options     map[string]interface{}   `json:"options"`
+	Collapsed   bool                     `json:"collapsed"`
+	Panels      []*gPanel                `json:"panels"`
+	Links       []gLink                  `json:"links"`
+	Repeat      string                   `json:"repeat"`
+	Transforms  []interface{}            `json:"transformations"`
+
+	// graph、singlestat 等旧版面板的字段
+	Yaxes   []gYaxis `json:"yaxes"`
+	Format  string   `json:"format"`
+	Content string   `json:"content"`
+	Mode    string   `json:"mode"`
+	Stack   bool     `json:"stack"`
+	Bars    bool     `json:"bars"`
+}
+
+type gYaxis struct {
+	Format string `json:"format"`
+}
+
+type gFieldConfig struct {
+	Defaults struct {
+		Unit       string                 `json:"unit"`
+		Decimals   *float64               `json:"decimals"`
+		Min        *float64               `json:"min"`
+		Max        *float64               `json:"max"`
+		Thresholds *gThresholds           `json:"thresholds"`
+		Mappings   []interface{}          `json:"mappings"`
+		Custom     map[string]interface{} `json:"custom"`
+	} `json:"defaults"`
+	Overrides []interface{} `json:"overrides"`
+}
+
+type gThresholds struct {
+	Mode  string `json:"mode"`
+	Steps []struct {
+		Color string   `json:"color"`
+		Value *float64 `json:"value"`
+	} `json:"steps"`
+}
+
+// parseDatasource Grafana 的 datasource 字段可能是字符串（数据源名称或变量），也可能是 {type, uid} 对象
+func parseDatasource(raw json.RawMessage) (typ, ref string) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", ""
+	}
+
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return "", s
+	}
+
+	var obj struct {
+		Type string `json:"type"`
+		Uid  string `json:"uid"`
+	}
+	if json.Unmarshal(raw, &obj) == nil {
+		return obj.Type, obj.Uid
+	}
+
+	return "", ""
+}
+
+// variableRef 解析 $name、${name}、[[name]] 形式的变量引用
+func variableRef(s string) (string, bool) {
+	switch {
+	case strings.HasPrefix(s, "${") && strings.HasSuffix(s, "}"):
+		name := strings.TrimSuffix(strings.TrimPrefix(s, "${"), "}")
+		if i := strings.Index(name, ":"); i > 0 {
+			name = name[:i]
+		}
+		return name, name != ""
+	case strings.HasPrefix(s, "[[") && strings.HasSuffix(s, "]]"):
+		name := strings.TrimSuffix(strings.TrimPrefix(s, "[["), "]]")
+		return name, name != ""
+	case strings.HasPrefix(s, "$") && len(s) > 1 && !strings.ContainsAny(s[1:], " ${}"):
+		return s[1:], true
+	}
+	return "", false
+}
@@ -132,6 +132,11 @@ var I18N = `{
     "Self-healing-Job - View": "自愈任务 - 查看",
     "Self-healing-Job - Add": "自愈任务 - 新增",
     "Self-healing-Job - Modify": "自愈任务 - 修改",
+    "Self-healing-Policy - View": "自愈策略 - 查看",
+    "Self-healing-Policy - Add": "自愈策略 - 新增",
+    "Self-healing-Policy - Modify": "自愈策略 - 修改",
+    "Self-healing-Policy - Delete": "自愈策略 - 删除",
+    "Self-healing-Policy - Approve Run": "自愈策略 - 审批执行",
     "Active Event - View": "活跃事件 - 查看",
     "Active Event - Delete": "活跃事件 - 删除",
     "Historical Event - View": "历史事件 - 查看",
@@ -341,6 +346,11 @@ var I18N = `{
     "Self-healing-Job - View": "自愈任務 - 查看",
     "Self-healing-Job - Add": "自愈任務 - 新增",
     "Self-healing-Job - Modify": "自愈任務 - 修改",
+    "Self-healing-Policy - View": "自愈策略 - 查看",
+    "Self-healing-Policy - Add": "自愈策略 - 新增",
+    "Self-healing-Policy - Modify": "自愈策略 - 修改",
+    "Self-healing-Policy - Delete": "自愈策略 - 刪除",
+    "Self-healing-Policy - Approve Run": "自愈策略 - 審批執行",
     "Active Event - View": "活躍事件 - 查看",
     "Active Event - Delete": "活躍事件 - 删除",
     "Historical Event - View": "歷史事件 - 查看",
@@ -547,6 +557,11 @@ var I18N = `{
     "Self-healing-Job - View": "一時的なタスク - 閲覧",
     "Self-healing-Job - Add": "一時的なタスク - 追加",
     "Self-healing-Job - Modify": "一時的なタスク - 修正",
+    "Self-healing-Policy - View": "自己修復ポリシー - 閲覧",
+    "Self-healing-Policy - Add": "自己修復ポリシー - 追加",
+    "Self-healing-Policy - Modify": "自己修復ポリシー - 修正",
+    "Self-healing-Policy - Delete": "自己修復ポリシー - 削除",
+    "Self-healing-Policy - Approve Run": "自己修復ポリシー - 実行承認",
     "Active Event - View": "アクティブアラート - 閲覧",
     "Active Event - Delete": "アクティブアラート - 削除",
     "Historical Event - View": "過去のアラート - 閲覧",
@@ -753,6 +768,11 @@ var I18N = `{
     "Self-healing-Job - View": "Задачи самоисцеления - Просмотр",
     "Self-healing-Job - Add": "Задачи самоисцеления - Добавить",
     "Self-healing-Job - Modify": "Задачи самоисцеления - Изменить",
+    "Self-healing-Policy - View": "Политики самоисцеления - Просмотр",
+    "Self-healing-Policy - Add": "Политики самоисцеления - Добавить",
+    "Self-healing-Policy - Modify": "Политики самоисцеления - Изменить",
+    "Self-healing-Policy - Delete": "Политики самоисцеления - Удалить",
+    "Self-healing-Policy - Approve Run": "Политики самоисцеления - Утвердить запуск",
     "Active Event - View": "Активные события - Просмотр",
     "Active Event - Delete": "Активные события - Удалить",
     "Historical Event - View": "Исторические события - Просмотр",
@@ -3,6 +3,7 @@ package poster
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
@@ -23,10 +24,66 @@ type DataResponse[T any] struct {
 }
 
 func GetByUrls[T any](ctx *ctx.Context, path string) (T, error) {
-	addrs := ctx.CenterApi.Addrs
+	var dat T
+
+	raw, err := getRawByUrls(ctx.CenterApi, path)
+	if err != nil {
+		// 中心端不可达时从本地快照加载，保证边缘机房可以继续工作
+		// 中心端返回了错误（如参数错误、没有权限）时快照中的数据同样不可信，直接返回错误
+		if !isUnreachable(err) {
+			return dat, err
+		}
+
+		snap, ok := loadSnapshot(ctx.CenterApi, path)
+		if !ok {
+			return dat, err
+		}
+		raw = snap
+	}
+
+	if err := json.Unmarshal(raw, &dat); err != nil {
+		return dat, fmt.Errorf("failed to decode data of %s: %w", path, err)
+	}
+
+	return dat, nil
+}
+
+// unreachableError 请求没有到达中心端或中心端没有正常响应：网络错误、超时、网关返回 5xx
+type unreachableError struct {
+	error
+}
+
+func (e unreachableError) Unwrap() error {
+	return e.error
+}
+
+func isUnreachable(err error) bool {
+	var u unreachableError
+	return errors.As(err, &u)
+}
+
+// StatusError 中心端返回了非 200 的状态码
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+}
+
+// ServerError 中心端处理请求失败，响应中带有 err 字段
+type ServerError struct {
+	Msg string
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("error from server: %s", e.Msg)
+}
+
+func getRawByUrls(cfg conf.CenterApi, path string) (json.RawMessage, error) {
+	addrs := cfg.Addrs
 	if len(addrs) == 0 {
-		var dat T
-		return dat, fmt.Errorf("no center api addresses configured")
+		return nil, fmt.Errorf("no center api addresses configured")
 	}
 
 	// 随机选择起始位置
@@ -34,29 +91,51 @@ func GetByUrls[T any](ctx *ctx.Context, path string) (T, error) {
 
 	// 从随机位置开始遍历所有地址
 
-	var dat T
+	var raw json.RawMessage
 	var err error
 	for i := 0; i < len(addrs); i++ {
 		idx := (startIdx + i) % len(addrs)
 		url := fmt.Sprintf("%s%s", addrs[idx], path)
 
-		dat, err = GetByUrl[T](url, ctx.CenterApi)
+		raw, err = getRawByUrl(url, cfg)
 		if err != nil {
 			logger.Warningf("failed to get data from center, url: %s, err: %v", url, err)
 			continue
 		}
-		return dat, nil
+
+		setCenterReachable(true)
+		saveSnapshot(cfg, path, raw)
+		return raw, nil
 	}
 
-	return dat, fmt.Errorf("failed to get data from center, path= %s, addrs= %v err: %v", path, addrs, err)
+	if isUnreachable(err) {
+		setCenterReachable(false)
+	} else {
+		setCenterReachable(true)
+	}
+	return nil, fmt.Errorf("failed to get data from center, path= %s, addrs= %v err: %w", path, addrs, err)
 }
 
 func GetByUrl[T any](url string, cfg conf.CenterApi) (T, error) {
 	var dat T
 
+	raw, err := getRawByUrl(url, cfg)
+	if err != nil {
+		return dat, err
+	}
+
+	if err := json.Unmarshal(raw, &dat); err != nil {
+		return dat, fmt.Errorf("failed to decode:%s response: %w", string(raw), err)
+	}
+
+	return dat, nil
+}
+
+// getRawByUrl 返回中心端响应中未解码的 dat 字段
+func getRawByUrl(url string, cfg conf.CenterApi) (json.RawMessage, error) {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return dat, fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	if len(cfg.BasicAuthUser) > 0 {
@@ -77,31 +156,38 @@ func GetByUrl[T any](url string, cfg conf.CenterApi) (T, error) {
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return dat, fmt.Errorf("failed to fetch from url: %w", err)
+		return nil, unreachableError{fmt.Errorf("failed to fetch from url: %w", err)}
 	}
 
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, unreachableError{&StatusError{StatusCode: resp.StatusCode}}
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		return dat, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, &StatusError{StatusCode: resp.StatusCode}
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return dat, fmt.Errorf("failed to read response body: %w", err)
+		return nil, unreachableError{fmt.Errorf("failed to read response body: %w", err)}
 	}
 
-	var dataResp DataResponse[T]
+	var dataResp DataResponse[json.RawMessage]
 	err = json.Unmarshal(body, &dataResp)
 	if err != nil {
-		return dat, fmt.Errorf("failed to decode:%s response: %w", string(body), err)
+		return nil, fmt.Errorf("failed to decode:%s response: %w", string(body), err)
 	}
 
 	if dataResp.Err != "" {
-		return dat, fmt.Errorf("error from server: %s", dataResp.Err)
+		return nil, &ServerError{Msg: dataResp.Err}
 	}
 
-	logger.Debugf("get data from %s, data: %+v", url, dataResp.Dat)
+	logger.Debugf("get data from %s, data: %s", url, string(dataResp.Dat))
+	if len(dataResp.Dat) == 0 {
+		return json.RawMessage("null"), nil
+	}
 	return dataResp.Dat, nil
 }
 
@@ -125,9 +211,11 @@ func PostByUrls(ctx *ctx.Context, path string, v interface{}) error {
 			logger.Warningf("failed to post data to center, url: %s, err: %v", url, err)
 			continue
 		}
+		setCenterReachable(true)
 		return nil
 	}
 
+	setCenterReachable(false)
 	return fmt.Errorf("failed to post data to center, path= %s, addrs= %v", path, addrs)
 }
 
@@ -152,9 +240,12 @@ func PostByUrlsWithResp[T any](ctx *ctx.Context, path string, v interface{}) (t
 			logger.Warningf("failed to post data to center, url: %s, err: %v", url, err)
 			continue
 		}
+		setCenterReachable(true)
 		return t, nil
 	}
 
+	setCenterReachable(false)
+
 	return t, fmt.Errorf("failed to post data to center, path= %s, addrs= %v err: %v", path, addrs, err)
 }
 
@@ -193,7 +284,7 @@ func PostByUrl[T any](url string, cfg conf.CenterApi, v interface{}) (t T, err e
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return t, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return t, &StatusError{StatusCode: resp.StatusCode}
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
@@ -208,7 +299,7 @@ func PostByUrl[T any](url string, cfg conf.CenterApi, v interface{}) (t T, err e
 	}
 
 	if dataResp.Err != "" {
-		return t, fmt.Errorf("error from server: %s", dataResp.Err)
+		return t, &ServerError{Msg: dataResp.Err}
 	}
 
 	logger.Debugf("get data from %s, data: %+v", url, dataResp.Dat)
@@ -0,0 +1,199 @@
+package poster
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/conf"
+
+	"github.com/toolkits/pkg/logger"
+)
+
+// snapshotPaths 边缘机房启动和运行需要的配置接口，同步成功后落盘
+// 只保存只读的查询接口，带 ids 参数的增量查询不保存
+var snapshotPaths = []string{
+	"/v1/n9e/statistic",
+	"/v1/n9e/alert-rules",
+	"/v1/n9e/targets-of-alert-rule",
+	"/v1/n9e/recording-rules",
+	"/v1/n9e/rule-groups",
+	"/v1/n9e/active-alert-mutes",
+	"/v1/n9e/alert-subscribes",
+	"/v1/n9e/notify-rules",
+	"/v1/n9e/notify-channels",
+	"/v1/n9e/notify-tpls",
+	"/v1/n9e/message-templates",
+	"/v1/n9e/event-pipelines",
+	"/v1/n9e/users",
+	"/v1/n9e/user-groups",
+	"/v1/n9e/user-group-members",
+	"/v1/n9e/busi-groups",
+	"/v1/n9e/targets",
+	"/v1/n9e/datasources",
+	"/v1/n9e/datasource-ids",
+	"/v1/n9e/datasource-rsa-config",
+	"/v1/n9e/es-index-pattern-list",
+	"/v1/n9e/all-configs",
+	"/v1/n9e/config?",
+	"/v1/n9e/task-tpls",
+	"/v1/n9e/task-tpl/statistics",
+}
+
+type snapshotFile struct {
+	Path    string          `json:"path"`
+	SavedAt int64           `json:"saved_at"`
+	Data    json.RawMessage `json:"data"`
+	Sign    string          `json:"sign"`
+}
+
+var (
+	centerOffline atomic.Bool
+
+	// 记录每个接口最近一次落盘内容的摘要，内容没变时不重复写文件
+	snapshotDigests sync.Map
+)
+
+// CenterOffline 最近一次访问中心端是否全部失败
+func CenterOffline() bool {
+	return centerOffline.Load()
+}
+
+func setCenterReachable(ok bool) {
+	if ok {
+		if centerOffline.Swap(false) {
+			logger.Info("center api is reachable again")
+		}
+		return
+	}
+
+	if !centerOffline.Swap(true) {
+		logger.Warning("center api is unreachable, edge is running in offline mode")
+	}
+}
+
+func snapshotable(path string) bool {
+	if strings.Contains(path, "ids=") {
+		return false
+	}
+
+	for _, p := range snapshotPaths {
+		if path == p || strings.HasPrefix(path, p) && (strings.HasSuffix(p, "?") || strings.HasPrefix(path[len(p):], "?")) {
+			return true
+		}
+	}
+	return false
+}
+
+func snapshotDir(cfg conf.CenterApi) string {
+	return filepath.Join(cfg.OfflineDir, "snapshots")
+}
+
+func snapshotFilename(cfg conf.CenterApi, path string) string {
+	sum := sha256.Sum256([]byte(path))
+	return filepath.Join(snapshotDir(cfg), hex.EncodeToString(sum[:])+".json")
+}
+
+func snapshotSign(cfg conf.CenterApi, path string, savedAt int64, data []byte) string {
+	key := cfg.SnapshotKey
+	if key == "" {
+		key = cfg.BasicAuthPass
+	}
+
+	mac := hmac.New(sha256.New, []byte(key))
+	fmt.Fprintf(mac, "%s\n%d\n", path, savedAt)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func saveSnapshot(cfg conf.CenterApi, path string, data json.RawMessage) {
+	if cfg.OfflineDir == "" || !snapshotable(path) {
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	if last, ok := snapshotDigests.Load(path); ok && last.(string) == digest {
+		return
+	}
+
+	if err := writeSnapshot(cfg, path, data, time.Now().Unix()); err != nil {
+		logger.Warningf("failed to save snapshot of %s: %v", path, err)
+		return
+	}
+
+	snapshotDigests.Store(path, digest)
+}
+
+func writeSnapshot(cfg conf.CenterApi, path string, data json.RawMessage, savedAt int64) error {
+	if err := os.MkdirAll(snapshotDir(cfg), 0700); err != nil {
+		return err
+	}
+
+	bs, err := json.Marshal(snapshotFile{
+		Path:    path,
+		SavedAt: savedAt,
+		Data:    data,
+		Sign:    snapshotSign(cfg, path, savedAt, data),
+	})
+	if err != nil {
+		return err
+	}
+
+	// 先写临时文件再改名，避免进程退出时留下写了一半的快照
+	filename := snapshotFilename(cfg, path)
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, bs, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, filename)
+}
+
+func loadSnapshot(cfg conf.CenterApi, path string) (json.RawMessage, bool) {
+	if cfg.OfflineDir == "" || !snapshotable(path) {
+		return nil, false
+	}
+
+	data, savedAt, err := readSnapshot(cfg, path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Errorf("failed to load snapshot of %s: %v", path, err)
+		}
+		return nil, false
+	}
+
+	logger.Warningf("center api is unreachable, use snapshot of %s saved at %s", path, time.Unix(savedAt, 0).Format(time.RFC3339))
+	return data, true
+}
+
+func readSnapshot(cfg conf.CenterApi, path string) (json.RawMessage, int64, error) {
+	bs, err := os.ReadFile(snapshotFilename(cfg, path))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var f snapshotFile
+	if err := json.Unmarshal(bs, &f); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+
+	if f.Path != path {
+		return nil, 0, fmt.Errorf("snapshot path mismatch: %s", f.Path)
+	}
+
+	expected := snapshotSign(cfg, f.Path, f.SavedAt, f.Data)
+	if !hmac.Equal([]byte(expected), []byte(f.Sign)) {
+		return nil, 0, fmt.Errorf("snapshot signature mismatch")
+	}
+
+	return f.Data, f.SavedAt, nil
+}
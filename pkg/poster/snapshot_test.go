@@ -0,0 +1,231 @@
+package poster
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ccfos/nightingale/v6/conf"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+)
+
+func TestSnapshotFallback(t *testing.T) {
+	var down atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		json.NewEncoder(w).Encode(DataResponse[[]int64]{Dat: []int64{1, 2, 3}})
+	}))
+	defer server.Close()
+
+	c := &ctx.Context{CenterApi: conf.CenterApi{
+		Addrs:      []string{server.URL},
+		OfflineDir: t.TempDir(),
+	}}
+
+	if _, err := GetByUrls[[]int64](c, "/v1/n9e/datasource-ids?name=x"); err != nil {
+		t.Fatalf("GetByUrls() error = %v", err)
+	}
+
+	down.Store(true)
+	lst, err := GetByUrls[[]int64](c, "/v1/n9e/datasource-ids?name=x")
+	if err != nil || len(lst) != 3 {
+		t.Fatalf("expected data from snapshot, got %v, err: %v", lst, err)
+	}
+	if !CenterOffline() {
+		t.Errorf("expected center to be marked offline")
+	}
+
+	if _, err := GetByUrls[[]int64](c, "/v1/n9e/datasource-ids?name=y"); err == nil {
+		t.Errorf("expected error without snapshot")
+	}
+
+	// 篡改后的快照不能使用
+	filename := snapshotFilename(c.CenterApi, "/v1/n9e/datasource-ids?name=x")
+	bs, _ := os.ReadFile(filename)
+	var f snapshotFile
+	json.Unmarshal(bs, &f)
+	f.Data = json.RawMessage(`[4]`)
+	bs, _ = json.Marshal(f)
+	os.WriteFile(filename, bs, 0600)
+
+	if _, err := GetByUrls[[]int64](c, "/v1/n9e/datasource-ids?name=x"); err == nil {
+		t.Errorf("expected error with tampered snapshot")
+	}
+}
+
+func TestSnapshotServerError(t *testing.T) {
+	var status atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch status.Load() {
+		case 1:
+			json.NewEncoder(w).Encode(DataResponse[[]int64]{Err: "forbidden"})
+		case 2:
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			json.NewEncoder(w).Encode(DataResponse[[]int64]{Dat: []int64{1, 2, 3}})
+		}
+	}))
+	defer server.Close()
+
+	c := &ctx.Context{CenterApi: conf.CenterApi{
+		Addrs:      []string{server.URL},
+		OfflineDir: t.TempDir(),
+	}}
+
+	if _, err := GetByUrls[[]int64](c, "/v1/n9e/datasource-ids?name=x"); err != nil {
+		t.Fatalf("GetByUrls() error = %v", err)
+	}
+
+	// 中心端可达但返回了错误，不能用快照掩盖
+	for _, s := range []int32{1, 2} {
+		status.Store(s)
+		if lst, err := GetByUrls[[]int64](c, "/v1/n9e/datasource-ids?name=x"); err == nil {
+			t.Errorf("status %d: expected server error, got %v", s, lst)
+		}
+		if CenterOffline() {
+			t.Errorf("status %d: center should not be marked offline", s)
+		}
+	}
+}
+
+func TestSnapshotable(t *testing.T) {
+	tests := map[string]bool{
+		"/v1/n9e/alert-rules?disabled=0":               true,
+		"/v1/n9e/alert-rules?disabled=0&ids=1,2":       false,
+		"/v1/n9e/targets":                              true,
+		"/v1/n9e/targets-of-alert-rule?engine_name=a":  true,
+		"/v1/n9e/config?key=a":                         true,
+		"/v1/n9e/task-tpl/1":                           false,
+		"/v1/n9e/alert-cur-events-del-by-hash?hash=aa": false,
+	}
+
+	for path, expected := range tests {
+		if got := snapshotable(path); got != expected {
+			t.Errorf("snapshotable(%s) = %v, expected %v", path, got, expected)
+		}
+	}
+}
+
+func TestSpool(t *testing.T) {
+	var down atomic.Bool
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		received = append(received, body["v"])
+		json.NewEncoder(w).Encode(DataResponse[interface{}]{})
+	}))
+	defer server.Close()
+
+	cfg := conf.CenterApi{Addrs: []string{server.URL}, OfflineDir: t.TempDir()}
+	s, err := NewSpool(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Append("/v1/n9e/event-persist", "", map[string]string{"v": "e1"})
+	s.Append("/v1/n9e/server-heartbeat", "a", map[string]string{"v": "h1"})
+	s.Append("/v1/n9e/event-persist", "", map[string]string{"v": "e2"})
+	s.Append("/v1/n9e/server-heartbeat", "a", map[string]string{"v": "h2"})
+
+	down.Store(true)
+	if err := s.Flush(); err == nil {
+		t.Fatalf("expected flush error while center is down")
+	}
+
+	down.Store(false)
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	expected := []string{"e1", "e2", "h2"}
+	if len(received) != len(expected) {
+		t.Fatalf("received %v, expected %v", received, expected)
+	}
+	for i := range expected {
+		if received[i] != expected[i] {
+			t.Fatalf("received %v, expected %v", received, expected)
+		}
+	}
+
+	if _, err := os.Stat(s.filename); !os.IsNotExist(err) {
+		t.Errorf("expected spool file to be removed")
+	}
+}
+
+func TestSpoolRejected(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/forbidden":
+			w.WriteHeader(http.StatusForbidden)
+			return
+		case "/invalid":
+			json.NewEncoder(w).Encode(DataResponse[interface{}]{Err: "invalid"})
+			return
+		}
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		received = append(received, body["v"])
+		json.NewEncoder(w).Encode(DataResponse[interface{}]{})
+	}))
+	defer server.Close()
+
+	cfg := conf.CenterApi{Addrs: []string{server.URL}, OfflineDir: t.TempDir()}
+	s, err := NewSpool(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Append("/forbidden", "", map[string]string{"v": "e1"})
+	s.Append("/invalid", "", map[string]string{"v": "e2"})
+	s.Append("/v1/n9e/event-persist", "", map[string]string{"v": "e3"})
+
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	if len(received) != 1 || received[0] != "e3" {
+		t.Fatalf("received %v, expected [e3]", received)
+	}
+
+	entries, err := readSpoolFile(s.rejected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Path != "/forbidden" || entries[1].Path != "/invalid" {
+		t.Fatalf("unexpected rejected entries: %+v", entries)
+	}
+}
+
+func TestIsRejected(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{&StatusError{StatusCode: http.StatusUnauthorized}, true},
+		{&StatusError{StatusCode: http.StatusForbidden}, true},
+		{&StatusError{StatusCode: http.StatusTooManyRequests}, false},
+		{&StatusError{StatusCode: http.StatusBadGateway}, false},
+		{unreachableError{&StatusError{StatusCode: http.StatusServiceUnavailable}}, false},
+		{&ServerError{Msg: "invalid"}, true},
+		{errors.New("connection refused"), false},
+	}
+
+	for _, tt := range tests {
+		if got := isRejected(tt.err); got != tt.expected {
+			t.Errorf("isRejected(%v) = %v, expected %v", tt.err, got, tt.expected)
+		}
+	}
+}
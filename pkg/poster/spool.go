@@ -0,0 +1,380 @@
+package poster
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ccfos/nightingale/v6/conf"
+	"github.com/ccfos/nightingale/v6/pkg/ctx"
+
+	"github.com/toolkits/pkg/logger"
+)
+
+const (
+	spoolFilename       = "spool.jsonl"
+	rejectedFilename    = "spool.rejected.jsonl"
+	defaultMaxSpoolSize = 256 // unit: MB
+	spoolFlushInterval  = 10 * time.Second
+)
+
+// spoolEntry 离线期间暂存的一次上报，Key 不为空时重放前只保留同一个 Key 的最后一条
+type spoolEntry struct {
+	Path string          `json:"path"`
+	Key  string          `json:"key,omitempty"`
+	Body json.RawMessage `json:"body"`
+}
+
+// rejectedEntry 中心端拒绝的暂存数据，写入单独的文件便于排查，不再重放
+type rejectedEntry struct {
+	*spoolEntry
+	Reason string `json:"reason"`
+}
+
+// Spool 中心端不可达时把事件、心跳等上报数据追加到本地文件，恢复后按顺序重放
+type Spool struct {
+	sync.Mutex
+	cfg      conf.CenterApi
+	filename string
+	rejected string
+	maxSize  int64
+}
+
+var spool *Spool
+
+// StartOfflineSpool 边缘机房启用离线暂存，未配置 OfflineDir 时不启用
+func StartOfflineSpool(cfg conf.CenterApi) error {
+	if cfg.OfflineDir == "" {
+		return nil
+	}
+
+	s, err := NewSpool(cfg)
+	if err != nil {
+		return err
+	}
+
+	spool = s
+	go s.loop()
+	return nil
+}
+
+func NewSpool(cfg conf.CenterApi) (*Spool, error) {
+	if err := os.MkdirAll(cfg.OfflineDir, 0700); err != nil {
+		return nil, err
+	}
+
+	maxSize := cfg.MaxSpoolSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxSpoolSize
+	}
+
+	return &Spool{
+		cfg:      cfg,
+		filename: filepath.Join(cfg.OfflineDir, spoolFilename),
+		rejected: filepath.Join(cfg.OfflineDir, rejectedFilename),
+		maxSize:  maxSize * 1024 * 1024,
+	}, nil
+}
+
+// PostByUrlsOrSpool 上报失败且启用了离线暂存时写入本地文件，返回 spooled 为 true
+func PostByUrlsOrSpool(ctx *ctx.Context, path, key string, v interface{}) (spooled bool, err error) {
+	err = PostByUrls(ctx, path, v)
+	if err == nil || spool == nil {
+		return false, err
+	}
+
+	if serr := spool.Append(path, key, v); serr != nil {
+		return false, fmt.Errorf("%v, and failed to spool: %v", err, serr)
+	}
+
+	return true, nil
+}
+
+// SpoolByUrls 启用了离线暂存时直接写入本地文件，保证在之前暂存的数据之后重放，如引用了暂存事件的通知记录
+// 未启用离线暂存时直接上报
+func SpoolByUrls(ctx *ctx.Context, path, key string, v interface{}) error {
+	if spool == nil {
+		return PostByUrls(ctx, path, v)
+	}
+
+	return spool.Append(path, key, v)
+}
+
+// PostByUrlsWithRespOrSpool 同 PostByUrlsOrSpool，暂存时返回零值
+func PostByUrlsWithRespOrSpool[T any](ctx *ctx.Context, path, key string, v interface{}) (t T, spooled bool, err error) {
+	t, err = PostByUrlsWithResp[T](ctx, path, v)
+	if err == nil || spool == nil {
+		return t, false, err
+	}
+
+	if serr := spool.Append(path, key, v); serr != nil {
+		return t, false, fmt.Errorf("%v, and failed to spool: %v", err, serr)
+	}
+
+	return t, true, nil
+}
+
+func (s *Spool) Append(path, key string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(spoolEntry{Path: path, Key: key, Body: body})
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if fi, err := os.Stat(s.filename); err == nil && fi.Size()+int64(len(line)) > s.maxSize {
+		// 超过上限先合并重复的心跳，仍然放不下就丢弃新数据
+		if err := s.compact(); err != nil {
+			return err
+		}
+		if fi, err := os.Stat(s.filename); err == nil && fi.Size()+int64(len(line)) > s.maxSize {
+			return fmt.Errorf("spool is full, size: %d", fi.Size())
+		}
+	}
+
+	f, err := os.OpenFile(s.filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+func (s *Spool) loop() {
+	for {
+		time.Sleep(spoolFlushInterval)
+		if err := s.Flush(); err != nil {
+			logger.Warningf("failed to replay spooled data: %v", err)
+		}
+	}
+}
+
+// Flush 按写入顺序重放暂存数据，遇到失败停止，剩余数据留待下次重放
+// 重放前把文件改名，重放期间新写入的数据追加到新文件，结束后再合并
+func (s *Spool) Flush() error {
+	replaying := s.filename + ".replaying"
+
+	s.Lock()
+	if _, err := os.Stat(replaying); os.IsNotExist(err) {
+		if err := os.Rename(s.filename, replaying); err != nil {
+			s.Unlock()
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+	}
+	s.Unlock()
+
+	entries, err := readSpoolFile(replaying)
+	if err != nil {
+		return err
+	}
+	entries = dedupSpoolEntries(entries)
+
+	done := 0
+	var ferr error
+	for ; done < len(entries); done++ {
+		if ferr = s.post(entries[done]); ferr != nil {
+			break
+		}
+	}
+
+	if done > 0 {
+		logger.Infof("replayed %d spooled entries, %d left", done, len(entries)-done)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	current, err := readSpoolFile(s.filename)
+	if err != nil {
+		return err
+	}
+
+	if done == 0 && len(current) == 0 {
+		// 中心端仍然不可达，原样放回，避免每次都重写整个文件
+		if err := os.Rename(replaying, s.filename); err != nil {
+			return err
+		}
+		return ferr
+	}
+
+	if err := s.write(append(entries[done:], current...)); err != nil {
+		return err
+	}
+
+	if err := os.Remove(replaying); err != nil {
+		return err
+	}
+
+	return ferr
+}
+
+func (s *Spool) post(e *spoolEntry) error {
+	var err error
+	for _, addr := range s.cfg.Addrs {
+		_, err = PostByUrl[interface{}](addr+e.Path, s.cfg, e.Body)
+		if err == nil {
+			setCenterReachable(true)
+			return nil
+		}
+
+		// 中心端明确拒绝的数据重放多少次都不会成功，移到单独的文件避免阻塞后面的数据
+		if isRejected(err) {
+			setCenterReachable(true)
+			s.deadLetter(e, err)
+			return nil
+		}
+	}
+
+	setCenterReachable(false)
+	return err
+}
+
+// isRejected 中心端返回了错误信息或者 4xx 状态码（如参数错误、认证失败），408、429 是临时性的，仍然重试
+func isRejected(err error) bool {
+	var se *ServerError
+	if errors.As(err, &se) {
+		return true
+	}
+
+	var st *StatusError
+	if errors.As(err, &st) {
+		return st.StatusCode >= http.StatusBadRequest && st.StatusCode < http.StatusInternalServerError &&
+			st.StatusCode != http.StatusRequestTimeout && st.StatusCode != http.StatusTooManyRequests
+	}
+
+	return false
+}
+
+// deadLetter 追加到被拒绝数据的文件，同样受 MaxSpoolSize 限制，超过后直接丢弃
+func (s *Spool) deadLetter(e *spoolEntry, reason error) {
+	line, err := json.Marshal(rejectedEntry{spoolEntry: e, Reason: reason.Error()})
+	if err != nil {
+		logger.Errorf("drop spooled entry of %s: %v", e.Path, reason)
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if fi, err := os.Stat(s.rejected); err == nil && fi.Size()+int64(len(line)) > s.maxSize {
+		logger.Errorf("drop spooled entry of %s: %v, rejected file is full", e.Path, reason)
+		return
+	}
+
+	f, err := os.OpenFile(s.rejected, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		logger.Errorf("drop spooled entry of %s: %v, failed to open rejected file: %v", e.Path, reason, err)
+		return
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		logger.Errorf("drop spooled entry of %s: %v, failed to write rejected file: %v", e.Path, reason, err)
+		return
+	}
+
+	logger.Errorf("spooled entry of %s rejected by center: %v, moved to %s", e.Path, reason, s.rejected)
+}
+
+// compact 同一个 Key 只保留最后一条，调用方持有锁
+func (s *Spool) compact() error {
+	entries, err := readSpoolFile(s.filename)
+	if err != nil {
+		return err
+	}
+
+	return s.write(dedupSpoolEntries(entries))
+}
+
+func dedupSpoolEntries(entries []*spoolEntry) []*spoolEntry {
+	last := make(map[string]int)
+	for i, e := range entries {
+		if e.Key != "" {
+			last[e.Path+"\x00"+e.Key] = i
+		}
+	}
+
+	ret := make([]*spoolEntry, 0, len(entries))
+	for i, e := range entries {
+		if e.Key != "" && last[e.Path+"\x00"+e.Key] != i {
+			continue
+		}
+		ret = append(ret, e)
+	}
+	return ret
+}
+
+func readSpoolFile(filename string) ([]*spoolEntry, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []*spoolEntry
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var e spoolEntry
+			if jerr := json.Unmarshal(line, &e); jerr != nil {
+				// 进程异常退出可能留下不完整的最后一行
+				logger.Warningf("drop broken spooled entry: %v", jerr)
+			} else {
+				entries = append(entries, &e)
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	return entries, nil
+}
+
+func (s *Spool) write(entries []*spoolEntry) error {
+	if len(entries) == 0 {
+		err := os.Remove(s.filename)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var buf bytes.Buffer
+	for _, e := range entries {
+		bs, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buf.Write(bs)
+		buf.WriteByte('\n')
+	}
+
+	tmp := s.filename + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.filename)
+}
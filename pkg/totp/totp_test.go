@@ -0,0 +1,40 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+func TestCode(t *testing.T) {
+	// RFC 6238 SHA1 test vectors, truncated to 6 digits
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	cases := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+
+	for ts, expect := range cases {
+		got, err := Code(secret, time.Unix(ts, 0))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != expect {
+			t.Fatalf("time %d expect %s got %s", ts, expect, got)
+		}
+	}
+
+	if !Validate(secret, "287082", time.Unix(59+Period, 0), 1) {
+		t.Fatalf("expect code of previous period to be valid with skew 1")
+	}
+
+	if Validate(secret, "287082", time.Unix(59+3*Period, 0), 1) {
+		t.Fatalf("expect code out of skew to be invalid")
+	}
+
+	if counter, ok := Match(secret, "287082", time.Unix(59+Period, 0), 1); !ok || counter != 59/Period {
+		t.Fatalf("expect match at counter %d, got %d %v", 59/Period, counter, ok)
+	}
+}
@@ -2,7 +2,10 @@ package idents
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -101,6 +104,19 @@ type TargetUpdate struct {
 	Now int64    `json:"now"`
 }
 
+func targetUpdateKey(lst []string) string {
+	idents := make([]string, len(lst))
+	copy(idents, lst)
+	sort.Strings(idents)
+
+	h := sha1.New()
+	for _, ident := range idents {
+		h.Write([]byte(ident))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 func (s *Set) UpdateTargets(lst []string, now int64) error {
 	if len(lst) == 0 {
 		return nil
@@ -125,7 +141,8 @@ func (s *Set) UpdateTargets(lst []string, now int64) error {
 		go func() {
 			defer s.sema.Release()
 			// 修改为异步发送，防止机器太多，每个请求耗时比较长导致机器心跳时间更新不及时
-			err := poster.PostByUrls(s.ctx, "/v1/n9e/target-update", t)
+			// 离线暂存时同一批机器只保留最新的心跳时间
+			_, err := poster.PostByUrlsOrSpool(s.ctx, "/v1/n9e/target-update", targetUpdateKey(lst), t)
 			if err != nil {
 				logger.Errorf("failed to post target update: %v", err)
 			}
@@ -29,9 +29,12 @@ func (rt *Router) heartbeat(c *gin.Context) {
 		api = rt.HeartbeatApi
 	}
 
-	ret, err := poster.PostByUrlsWithResp[map[string]interface{}](rt.Ctx, fmt.Sprintf("%s?gid=%s&overwrite_gids=%t", api, gid, overwriteGids), req)
+	// 中心端不可达时暂存心跳，每台机器只保留最新的一条，恢复后再上报
+	ret, spooled, err := poster.PostByUrlsWithRespOrSpool[map[string]interface{}](rt.Ctx, fmt.Sprintf("%s?gid=%s&overwrite_gids=%t", api, gid, overwriteGids), req.Hostname, req)
 	if err != nil {
 		logger.Warningf("req:%v heartbeat failed to post to center, centerApi:%v err:%v", req, rt.Ctx.CenterApi, err)
+	} else if spooled {
+		logger.Debugf("req:%v heartbeat spooled, center api is unreachable", req.Hostname)
 	}
 
 	ginx.NewRender(c).Data(ret, err)